@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/pkg/capabilities"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "Prints the package types and features the source and target support",
+	Long:  "Detects the GitHub Enterprise Server version of the source and target and prints which package types and migration features (repository linking, team access, visibility, referrers) are supported between them",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_SOURCE_HOSTNAME": "source-hostname",
+			"GHMPKG_SOURCE_TOKEN":    "source-token",
+			"GHMPKG_TARGET_HOSTNAME": "target-hostname",
+			"GHMPKG_TARGET_TOKEN":    "target-token",
+			"GHMPKG_PACKAGE_TYPES":   "package-types",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		// Tokens are only needed to detect GitHub Enterprise Server versions
+		logger := zap.L()
+		exitOnError("print capabilities", capabilities.Capabilities(logger))
+	},
+}
+
+func init() {
+	capabilitiesCmd.Flags().StringP("source-hostname", "u", "", "Source GitHub Enterprise Server hostname (optional)")
+	capabilitiesCmd.Flags().StringP("source-token", "s", "", "Source GitHub token (required for GitHub Enterprise Server)")
+	capabilitiesCmd.Flags().StringP("target-hostname", "n", "", "Target GitHub Enterprise Server hostname (optional)")
+	capabilitiesCmd.Flags().StringP("target-token", "t", "", "Target GitHub token (required for GitHub Enterprise Server)")
+	capabilitiesCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to check (can be specified multiple times)")
+}
@@ -3,16 +3,46 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
+	"github.com/mona-actions/gh-migrate-packages/internal/credentials"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// githubTokens are the settings holding GitHub tokens. Only they are checked
+// to be GitHub tokens, the secrets of other registries have their own formats.
+var githubTokens = []string{"GHMPKG_SOURCE_TOKEN", "GHMPKG_TARGET_TOKEN"}
+
+// GetFlagOrEnv resolves the given settings from their flags and environment
+// variables, exiting when a required one is missing or a GitHub token is invalid
 func GetFlagOrEnv(cmd *cobra.Command, flags map[string]bool) map[string]string {
+	values, err := resolveFlags(cmd, flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return values
+}
+
+func resolveFlags(cmd *cobra.Command, flags map[string]bool) (map[string]string, error) {
 	values := make(map[string]string)
 	var missing []string
-	var isTokenValid bool
+
+	// Tokens from a credential provider replace the flags and environment variables
+	var sides []string
+	if _, ok := flags["GHMPKG_SOURCE_TOKEN"]; ok {
+		sides = append(sides, credentials.Source)
+	}
+	if _, ok := flags["GHMPKG_TARGET_TOKEN"]; ok {
+		sides = append(sides, credentials.Target)
+	}
+	if err := credentials.Resolve(sides...); err != nil {
+		return nil, err
+	}
 
 	for name, required := range flags {
 		// For CLI flags, strip GHMPKG_ prefix if present
@@ -41,34 +71,90 @@ func GetFlagOrEnv(cmd *cobra.Command, flags map[string]bool) map[string]string {
 			viper.Set(flagName, value)
 			viper.Set(envName, value)
 			values[name] = value
+		} else if token := packageTypesToken(name); token != "" {
+			// Organization level requests, such as listing repositories, use
+			// the token of the first package type
+			viper.Set(envName, token)
 		} else if required {
 			missing = append(missing, flagName)
 		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required values: %s", strings.Join(missing, ", "))
+	}
 
-		//if flagname contains `-token` or envName container `TOKEN`check if token is valid
-		if strings.Contains(flagName, "token") || strings.Contains(envName, "TOKEN") {
-			isTokenValid = checkToken(value)
+	for _, name := range githubTokens {
+		if value, ok := values[name]; ok && !checkToken(value) {
+			return nil, fmt.Errorf("%s must be a GitHub Personal Access Token", name)
+		}
+		if _, ok := flags[name]; !ok {
+			continue
+		}
+		packageTypes, _ := common.PackageTypeFilter()
+		for _, packageType := range packageTypes {
+			key := utils.PackageTypeKey(name, packageType)
+			if value := viper.GetString(key); value != "" && !checkToken(value) {
+				return nil, fmt.Errorf("%s must be a GitHub Personal Access Token", key)
+			}
 		}
 	}
 
-	if len(missing) > 0 {
-		fmt.Fprintf(os.Stderr, "Error: missing required values: %s\n", strings.Join(missing, ", "))
-		os.Exit(1)
+	return values, nil
+}
+
+// packageTypesToken returns the token of the first package type the command
+// processes when a GitHub token setting is set for every one of them, which
+// then do not need the global one. It is empty otherwise.
+func packageTypesToken(name string) string {
+	if !utils.Contains(githubTokens, name) {
+		return ""
 	}
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil || len(packageTypes) == 0 {
+		return ""
+	}
+	for _, packageType := range packageTypes {
+		if viper.GetString(utils.PackageTypeKey(name, packageType)) == "" {
+			return ""
+		}
+	}
+	return viper.GetString(utils.PackageTypeKey(name, packageTypes[0]))
+}
 
-	if !isTokenValid {
-		fmt.Println("Error: token must be a GitHub Personal Access Token.")
-		os.Exit(1)
+// exitOnError ends a command that failed with the exit code of its error:
+// ExitPartial or ExitCriteria for runs that completed with failures, which
+// their summary already lists, ExitFatal for the others
+func exitOnError(action string, err error) {
+	if err == nil {
+		return
+	}
+	code := common.ExitCode(err)
+	if code == common.ExitFatal {
+		fmt.Fprintf(os.Stderr, "failed to %s: %v\n", action, err)
 	}
+	os.Exit(code)
+}
 
-	return values
+// bindFlags binds command flags to their viper keys. Several commands share the
+// same keys, so the binding has to be made for the command that actually runs.
+// A flag the command does not define is a bug, the setting would be ignored.
+func bindFlags(cmd *cobra.Command, flags map[string]string) {
+	for key, name := range flags {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			panic(fmt.Sprintf("%s has no --%s flag to bind %s to", cmd.CommandPath(), name, key))
+		}
+		viper.BindPFlag(key, flag)
+	}
 }
 
 func ShowConnectionStatus(actionType string) {
 	var endpoint string
 
 	switch actionType {
-	case "export", "pull":
+	case "export", "pull", "verify":
 		endpoint = "source-hostname"
 	case "sync":
 		endpoint = "target-hostname"
@@ -77,6 +163,7 @@ func ShowConnectionStatus(actionType string) {
 	hostname := getNormalizedEndpoint(endpoint)
 
 	fmt.Println(getHostnameMessage(hostname))
+	fmt.Printf("🔒 Using: %s\n", utils.TLSDescription())
 	//fmt.Println(getProxyStatus())
 }
 
@@ -109,5 +196,12 @@ func getProxyStatus() string {
 }
 
 func checkToken(token string) bool {
+	// gh CLI logins are OAuth tokens, GitHub Apps get installation tokens
+	if strings.HasPrefix(token, "gho_") && viper.GetString("GHMPKG_CREDENTIAL_PROVIDER") == credentials.GH {
+		return true
+	}
+	if strings.HasPrefix(token, "ghs_") && viper.GetString("GHMPKG_CREDENTIAL_PROVIDER") == credentials.App {
+		return true
+	}
 	return strings.HasPrefix(token, "ghp_") || strings.HasPrefix(token, "github_pat_")
 }
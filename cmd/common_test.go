@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestBindFlags(t *testing.T) {
+	defer viper.Reset()
+	if syncCmd.Flags().Lookup("keep-work-files") == nil {
+		t.Fatal("sync has no --keep-work-files flag")
+	}
+
+	// Every flag a command binds when it runs must be defined on it
+	var check func(cmd *cobra.Command)
+	check = func(cmd *cobra.Command) {
+		if cmd.PreRun != nil {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%s: %v", cmd.CommandPath(), r)
+					}
+				}()
+				cmd.PreRun(cmd, nil)
+			}()
+		}
+		for _, sub := range cmd.Commands() {
+			check(sub)
+		}
+	}
+	check(rootCmd)
+}
+
+func TestSyncFlagsExternalTarget(t *testing.T) {
+	tests := []struct {
+		registry string
+		settings map[string]string
+	}{
+		{"artifactory", map[string]string{
+			"GHMPKG_ARTIFACTORY_URL":     "https://artifactory.example.com/artifactory",
+			"GHMPKG_ARTIFACTORY_API_KEY": "AKCp8key",
+		}},
+		{"nexus", map[string]string{
+			"GHMPKG_NEXUS_URL":      "https://nexus.example.com",
+			"GHMPKG_NEXUS_USER":     "migration",
+			"GHMPKG_NEXUS_PASSWORD": "s3cret",
+		}},
+		// The Azure DevOps PAT is not a GitHub token
+		{"azure", map[string]string{
+			"GHMPKG_AZURE_ORGANIZATION": "contoso",
+			"GHMPKG_AZURE_FEED":         "packages",
+			"GHMPKG_AZURE_TOKEN":        "4kq7azuredevopspat",
+		}},
+		// CodeArtifact authenticates with the AWS credentials of the environment
+		{"codeartifact", map[string]string{
+			"GHMPKG_CODEARTIFACT_DOMAIN":     "contoso",
+			"GHMPKG_CODEARTIFACT_REPOSITORY": "packages",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.registry, func(t *testing.T) {
+			defer viper.Reset()
+			viper.Set("GHMPKG_TARGET_REGISTRY", test.registry)
+			for key, value := range test.settings {
+				viper.Set(key, value)
+			}
+			values, err := resolveFlags(syncCmd, syncFlags())
+			if err != nil {
+				t.Fatalf("resolveFlags: %v", err)
+			}
+			for key, value := range test.settings {
+				if values[key] != value {
+					t.Errorf("%s = %q, want %q", key, values[key], value)
+				}
+			}
+
+			// The settings of the target registry are still required
+			for key := range test.settings {
+				viper.Set(key, "")
+			}
+			if _, err := resolveFlags(syncCmd, syncFlags()); err == nil || !strings.Contains(err.Error(), "missing required values") {
+				t.Errorf("resolveFlags without the target settings = %v, want missing required values", err)
+			}
+		})
+	}
+}
+
+func TestResolveFlagsGitHubToken(t *testing.T) {
+	defer viper.Reset()
+	flags := map[string]bool{"GHMPKG_TARGET_ORGANIZATION": true, "GHMPKG_TARGET_TOKEN": true}
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_TOKEN", "not-a-token")
+	if _, err := resolveFlags(syncCmd, flags); err == nil || !strings.Contains(err.Error(), "GitHub Personal Access Token") {
+		t.Errorf("resolveFlags with an invalid token = %v, want a GitHub Personal Access Token error", err)
+	}
+	viper.Set("GHMPKG_TARGET_TOKEN", "ghp_xxx")
+	if _, err := resolveFlags(syncCmd, flags); err != nil {
+		t.Errorf("resolveFlags with a valid token: %v", err)
+	}
+}
+
+func TestResolveFlagsPackageTypeTokens(t *testing.T) {
+	defer viper.Reset()
+	flags := map[string]bool{"GHMPKG_SOURCE_ORGANIZATION": true, "GHMPKG_SOURCE_TOKEN": true}
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_PACKAGE_TYPES", []string{"npm", "container"})
+	viper.Set("GHMPKG_NPM_SOURCE_TOKEN", "ghp_npm")
+
+	// A package type without its own token needs the global one
+	if _, err := resolveFlags(pullCmd, flags); err == nil || !strings.Contains(err.Error(), "source-token") {
+		t.Errorf("resolveFlags without a container token = %v, want source-token missing", err)
+	}
+
+	viper.Set("GHMPKG_CONTAINER_SOURCE_TOKEN", "ghp_container")
+	if _, err := resolveFlags(pullCmd, flags); err != nil {
+		t.Errorf("resolveFlags with a token for every package type: %v", err)
+	}
+	if token := viper.GetString("GHMPKG_SOURCE_TOKEN"); token != "ghp_npm" {
+		t.Errorf("GHMPKG_SOURCE_TOKEN = %q, want the npm token for organization level requests", token)
+	}
+	viper.Set("GHMPKG_SOURCE_TOKEN", "")
+
+	viper.Set("GHMPKG_CONTAINER_SOURCE_TOKEN", "not-a-token")
+	if _, err := resolveFlags(pullCmd, flags); err == nil || !strings.Contains(err.Error(), "GHMPKG_CONTAINER_SOURCE_TOKEN") {
+		t.Errorf("resolveFlags with an invalid container token = %v, want a GHMPKG_CONTAINER_SOURCE_TOKEN error", err)
+	}
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mona-actions/gh-migrate-packages/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspects the configuration",
+	Long:  "Inspects the settings read from the config file and the environment",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the config file and environment and prints the effective configuration",
+	Long:  "Checks every setting of the .env or YAML config file and of the GHMPKG_ environment variables, flags unknown or misspelled names and invalid values, and prints the effective value of every setting",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := config.Validate(viper.ConfigFileUsed())
+		if err != nil {
+			fmt.Printf("failed to validate configuration: %v\n", err)
+			os.Exit(1)
+		}
+		config.Print(result)
+		if result.HasErrors() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/mona-actions/gh-migrate-packages/pkg/export"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -11,21 +9,37 @@ import (
 
 var exportCmd = &cobra.Command{
 	Use:   "export",
-	Short: "Exports a list of package data to a CSV file",
-	Long:  "Exports a list of package data to a CSV file",
+	Short: "Exports a list of package data to a CSV file or JSON manifest",
+	Long:  "Exports a list of package data to a CSV file or JSON manifest",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_PACKAGES":            "packages",
+			"GHMPKG_INCLUDE":             "include",
+			"GHMPKG_EXCLUDE":             "exclude",
+			"GHMPKG_VERSIONS":            "versions",
+			"GHMPKG_SINCE":               "since",
+			"GHMPKG_SNAPSHOT":            "snapshot",
+			"GHMPKG_STORE":               "store",
+			"GHMPKG_REPORT_JSON":         "report-json",
+			"GHMPKG_MAX_FAILED_FILES":    "max-failed-files",
+			"GHMPKG_MAX_FAILED_PACKAGES": "max-failed-packages",
+			"GHMPKG_REPOSITORY":          "repository",
+			"GHMPKG_EXPORT_FORMAT":       "format",
+			"GHMPKG_EXPORT_PERMISSIONS":  "permissions",
+			"GHMPKG_MIGRATION_PATH":      "migration-path",
+		})
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		GetFlagOrEnv(cmd, map[string]bool{
 			"GHMPKG_SOURCE_HOSTNAME":     false,
 			"GHMPKG_SOURCE_ORGANIZATION": true,
 			"GHMPKG_SOURCE_TOKEN":        true,
-			"GHMPKG_PACKAGE_TYPE":        false,
+			"GHMPKG_PACKAGE_TYPES":       false,
 		})
 
 		logger := zap.L()
 		ShowConnectionStatus("export")
-		if err := export.Export(logger); err != nil {
-			fmt.Printf("failed to export packages: %v\n", err)
-		}
+		exitOnError("export packages", export.Export(logger))
 	},
 }
 
@@ -34,6 +48,20 @@ func init() {
 	exportCmd.Flags().StringP("source-organization", "o", "", "Organization (required)")
 	exportCmd.Flags().StringP("source-token", "t", "", "GitHub token (required)")
 	exportCmd.Flags().StringSliceP("package-types", "p", []string{}, "Package type(s) to process (can be specified multiple times)")
+	exportCmd.Flags().StringSliceP("repository", "r", []string{}, "Repositories to export packages of, can be repeated or comma separated (optional, exports all repositories if not specified)")
+	exportCmd.Flags().StringSlice("packages", []string{}, "Only export the packages with these exact names (can be specified multiple times)")
+	exportCmd.Flags().StringSlice("include", []string{}, "Only export packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	exportCmd.Flags().StringSlice("exclude", []string{}, "Skip packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	exportCmd.Flags().StringSlice("versions", []string{}, "Only export versions matching these semver constraints, e.g. >=2.0.0, ^1.4 or latest:5")
+	exportCmd.Flags().String("since", "", "Only export versions created on or after this date (2023-01-01) or RFC 3339 timestamp")
+	exportCmd.Flags().String("snapshot", "", "Label the export with this snapshot name, e.g. wave-3-freeze, for pull, sync and verify to select")
+	exportCmd.Flags().String("store", "csv", "Also record the inventory in an SQLite database in the migration path for pull and sync to query: csv or sqlite")
+	exportCmd.Flags().String("format", "csv", "Inventory format: csv, json or both")
+	exportCmd.Flags().Bool("permissions", false, "Also write the visibility of every package and the teams with access to its repository to a permissions CSV")
+	exportCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+	exportCmd.Flags().String("report-json", "", "Write the report with the result of every package, version and file as JSON to this path")
+	exportCmd.Flags().String("max-failed-files", "", "Fail the run when more files than this failed, a number such as 10 or a share such as 1%")
+	exportCmd.Flags().String("max-failed-packages", "", "Fail the run when more packages than this failed, a number such as 10 or a share such as 1%")
 
 	//viper.BindPFlag("GHMPKG_SOURCE_HOSTNAME", exportCmd.Flags().Lookup("source-hostname"))
 	viper.BindPFlag("GHMPKG_SOURCE_ORGANIZATION", exportCmd.Flags().Lookup("source-organization"))
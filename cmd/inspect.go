@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/pkg/inspect"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect-http <recording.jsonl>",
+	Short: "Inspects HTTP traffic recorded with --record-http",
+	Long:  "Lists the requests of a recording written with --record-http, optionally replaying GET and HEAD requests against the source to debug mismatched URLs",
+	Args:  cobra.ExactArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_SOURCE_TOKEN": "source-token",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		status, _ := cmd.Flags().GetInt("status")
+		failedOnly, _ := cmd.Flags().GetBool("failed")
+		replay, _ := cmd.Flags().GetBool("replay")
+		if replay {
+			GetFlagOrEnv(cmd, map[string]bool{
+				"GHMPKG_SOURCE_TOKEN": true,
+			})
+		}
+
+		logger := zap.L()
+		exitOnError("inspect recording", inspect.Inspect(logger, args[0], status, failedOnly, replay))
+	},
+}
+
+func init() {
+	inspectCmd.Flags().Int("status", 0, "Only show requests that returned this status code")
+	inspectCmd.Flags().Bool("failed", false, "Only show requests that failed or returned a status of 400 or above")
+	inspectCmd.Flags().Bool("replay", false, "Replay GET and HEAD requests with the source token and compare the status codes")
+	inspectCmd.Flags().StringP("source-token", "s", "", "Source GitHub token (required with --replay)")
+
+	viper.BindPFlag("GHMPKG_SOURCE_TOKEN", inspectCmd.Flags().Lookup("source-token"))
+}
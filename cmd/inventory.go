@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/pkg/export"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var inventoryTargetCmd = &cobra.Command{
+	Use:   "inventory-target",
+	Short: "Inventories the packages the target organization already has",
+	Long:  "Writes the packages, versions and files the target organization already has to CSV files in the export schema, read by sync to report conflicts with that content and by verify to tell it apart from migrated packages",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_TARGET_ORGANIZATION": "target-organization",
+			"GHMPKG_TARGET_TOKEN":        "target-token",
+			"GHMPKG_PACKAGE_TYPES":       "package-types",
+			"GHMPKG_MIGRATION_PATH":      "migration-path",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMPKG_TARGET_HOSTNAME":     false,
+			"GHMPKG_TARGET_ORGANIZATION": true,
+			"GHMPKG_TARGET_TOKEN":        true,
+		})
+
+		logger := zap.L()
+		ShowConnectionStatus("sync")
+		exitOnError("inventory target packages", export.Target(logger))
+	},
+}
+
+func init() {
+	inventoryTargetCmd.Flags().StringP("target-organization", "p", "", "Target Organization (required)")
+	inventoryTargetCmd.Flags().StringP("target-token", "t", "", "Target GitHub token (required)")
+	inventoryTargetCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to inventory (can be specified multiple times)")
+	inventoryTargetCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+
+	viper.BindPFlag("GHMPKG_TARGET_ORGANIZATION", inventoryTargetCmd.Flags().Lookup("target-organization"))
+	viper.BindPFlag("GHMPKG_TARGET_TOKEN", inventoryTargetCmd.Flags().Lookup("target-token"))
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mona-actions/gh-migrate-packages/pkg/ledger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var ledgerCmd = &cobra.Command{
+	Use:   "ledger",
+	Short: "Writes a signed ledger of every pulled and uploaded file",
+	Long:  "Assembles the digests recorded by pull and sync into a ledger mapping every migrated file to its source digest, target digest and whether it was rewritten, signed with an ed25519 key",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_SOURCE_ORGANIZATION": "source-organization",
+			"GHMPKG_TARGET_ORGANIZATION": "target-organization",
+			"GHMPKG_MIGRATION_PATH":      "migration-path",
+			"GHMPKG_LEDGER_SIGNING_KEY":  "signing-key",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		// No API calls are made, only the source organization is required
+		if viper.GetString("GHMPKG_SOURCE_ORGANIZATION") == "" {
+			fmt.Fprintln(os.Stderr, "Error: missing required values: source-organization")
+			os.Exit(1)
+		}
+
+		logger := zap.L()
+		exitOnError("write ledger", ledger.Ledger(logger))
+	},
+}
+
+func init() {
+	ledgerCmd.Flags().StringP("source-organization", "o", "", "Source Organization (required)")
+	ledgerCmd.Flags().StringP("target-organization", "p", "", "Target Organization")
+	ledgerCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+	ledgerCmd.Flags().String("signing-key", "", "PEM encoded PKCS#8 ed25519 private key used to sign the ledger")
+}
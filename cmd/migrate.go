@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/mona-actions/gh-migrate-packages/pkg/migrate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Runs export, pull and sync one after the other",
+	Long:  "Exports the packages of the source organization, pulls them and syncs them to the target organization with the same settings, writing a report per phase and a combined one",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_SOURCE_ORGANIZATION":                "source-organization",
+			"GHMPKG_SOURCE_TOKEN":                       "source-token",
+			"GHMPKG_SOURCE_HOSTNAME":                    "source-hostname",
+			"GHMPKG_SOURCE_SUBDOMAIN_ISOLATION":         "source-subdomain-isolation",
+			"GHMPKG_TARGET_ORGANIZATION":                "target-organization",
+			"GHMPKG_TARGET_TOKEN":                       "target-token",
+			"GHMPKG_PACKAGE_TYPES":                      "package-types",
+			"GHMPKG_REPOSITORY":                         "repository",
+			"GHMPKG_MIGRATION_PATH":                     "migration-path",
+			"GHMPKG_PACKAGES":                           "packages",
+			"GHMPKG_INCLUDE":                            "include",
+			"GHMPKG_EXCLUDE":                            "exclude",
+			"GHMPKG_VERSIONS":                           "versions",
+			"GHMPKG_SINCE":                              "since",
+			"GHMPKG_SNAPSHOT":                           "snapshot",
+			"GHMPKG_STORE":                              "store",
+			"GHMPKG_REQUIRE_SNAPSHOT":                   "require-snapshot",
+			"GHMPKG_SHARD":                              "shard",
+			"GHMPKG_SHARD_SPLIT_TAGS":                   "shard-split-tags",
+			"GHMPKG_CRITICAL":                           "critical",
+			"GHMPKG_CRITICAL_FILE":                      "critical-file",
+			"GHMPKG_VERIFY_CRITICAL":                    "verify-critical",
+			"GHMPKG_RESUME":                             "resume",
+			"GHMPKG_EXISTING_PACKAGES":                  "existing-packages",
+			"GHMPKG_CONFLICT_POLICY":                    "conflict-policy",
+			"GHMPKG_RENAME_SUFFIX":                      "rename-suffix",
+			"GHMPKG_MAPPING_FILE":                       "mapping-file",
+			"GHMPKG_TRANSFER":                           "transfer",
+			"GHMPKG_TRANSFER_DELETE":                    "transfer-delete",
+			"GHMPKG_VERIFY_CHECKSUMS":                   "verify-checksums",
+			"GHMPKG_STAGING_NAMES":                      "staging-names",
+			"GHMPKG_COPY_REFERRERS":                     "copy-referrers",
+			"GHMPKG_VERIFY_UPLOADS":                     "verify-uploads",
+			"GHMPKG_ROLLBACK_PARTIAL":                   "rollback-partial",
+			"GHMPKG_SMOKE_TEST":                         "smoke-test",
+			"GHMPKG_SMOKE_TEST_SAMPLE":                  "smoke-test-sample",
+			"GHMPKG_REPORT_JSON":                        "report-json",
+			"GHMPKG_MAX_FAILED_FILES":                   "max-failed-files",
+			"GHMPKG_MAX_FAILED_PACKAGES":                "max-failed-packages",
+			"GHMPKG_FAIL_ON_CRITICAL":                   "fail-on-critical",
+			"GHMPKG_MIGRATE_FROM":                       "from",
+			"GHMPKG_FAIL_FAST":                          "fail-fast",
+			"GHMPKG_WATCH":                              "watch",
+			"GHMPKG_WATCH_INTERVAL":                     "interval",
+			"GHMPKG_WATCH_UNTIL":                        "watch-until",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY":          "source-container-registry",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY_USER":     "source-container-registry-user",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY_PASSWORD": "source-container-registry-password",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY":          "target-container-registry",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY_USER":     "target-container-registry-user",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY_PASSWORD": "target-container-registry-password",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMPKG_SOURCE_HOSTNAME":     false,
+			"GHMPKG_SOURCE_ORGANIZATION": true,
+			"GHMPKG_SOURCE_TOKEN":        true,
+			"GHMPKG_TARGET_ORGANIZATION": true,
+			"GHMPKG_TARGET_TOKEN":        true,
+		})
+
+		logger := zap.L()
+		ShowConnectionStatus("export")
+		if viper.GetBool("GHMPKG_WATCH") {
+			exitOnError("watch packages", migrate.Watch(logger))
+			return
+		}
+		exitOnError("migrate packages", migrate.Migrate(logger))
+	},
+}
+
+func init() {
+	migrateCmd.Flags().StringP("source-organization", "o", "", "Source Organization (required)")
+	migrateCmd.Flags().StringP("source-token", "s", "", "Source GitHub token (required)")
+	migrateCmd.Flags().StringP("source-hostname", "n", "", "Source GitHub Enterprise Server hostname URL (optional)")
+	migrateCmd.Flags().Bool("source-subdomain-isolation", true, "The source GitHub Enterprise Server serves its registries on subdomains, such as maven.HOSTNAME, instead of HOSTNAME/_registry/maven")
+	migrateCmd.Flags().StringP("target-organization", "p", "", "Target Organization (required)")
+	migrateCmd.Flags().StringP("target-token", "t", "", "Target GitHub token (required)")
+	migrateCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to migrate (can be specified multiple times)")
+	migrateCmd.Flags().StringSliceP("repository", "r", []string{}, "Repositories to migrate packages of, can be repeated or comma separated (optional, migrates all repositories if not specified)")
+	migrateCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+	migrateCmd.Flags().StringSlice("packages", []string{}, "Only migrate the packages with these exact names (can be specified multiple times)")
+	migrateCmd.Flags().StringSlice("include", []string{}, "Only migrate packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	migrateCmd.Flags().StringSlice("exclude", []string{}, "Skip packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	migrateCmd.Flags().StringSlice("versions", []string{}, "Only migrate versions matching these semver constraints, e.g. >=2.0.0, ^1.4 or latest:5")
+	migrateCmd.Flags().String("since", "", "Only migrate versions created on or after this date (2023-01-01) or RFC 3339 timestamp")
+	migrateCmd.Flags().String("snapshot", "", "Label the export with this snapshot name and pull and sync it, e.g. wave-3-freeze")
+	migrateCmd.Flags().String("store", "csv", "Also record the inventory in an SQLite database in the migration path and the status of every file: csv or sqlite")
+	migrateCmd.Flags().Bool("require-snapshot", false, "Refuse to run without --snapshot, so everyone operates on the same frozen export")
+	migrateCmd.Flags().String("shard", "", "Only migrate the share of the packages dealt to this shard of a migration split into runs side by side, e.g. 2/4")
+	migrateCmd.Flags().Int("shard-split-tags", common.DefaultShardSplitTags, "Deal the versions of container packages with more tags than this to the shards one digest at a time, 0 to never split a package")
+	migrateCmd.Flags().StringSlice("critical", []string{}, "Packages to pull and sync before every other one, names or globs optionally prefixed with a package type, e.g. npm/web-*")
+	migrateCmd.Flags().String("critical-file", "", "File listing more critical packages, one per line")
+	migrateCmd.Flags().Bool("verify-critical", false, "Read the files of critical packages back from the target even when --verify-uploads is off")
+	migrateCmd.Flags().Bool("resume", false, "Resume interrupted pulls and syncs, skipping files recorded as completed in the state file")
+	migrateCmd.Flags().String("existing-packages", "new-versions", "How to handle packages already in the target organization: new-versions, skip or all")
+	migrateCmd.Flags().String("conflict-policy", "fail", "How to handle package names deleted from the target organization: fail or rename")
+	migrateCmd.Flags().String("rename-suffix", "-migrated", "Suffix appended to package names that cannot be reused when --conflict-policy is rename")
+	migrateCmd.Flags().String("mapping-file", "", "Rename repositories, packages and npm scopes on the target as listed in this YAML or CSV file")
+	migrateCmd.Flags().Bool("transfer", false, "Move the packages of the repositories the mapping file maps to other repositories of the same organization, publishing them again from their new repository")
+	migrateCmd.Flags().Bool("transfer-delete", false, "With --transfer, delete the npm, Maven, NuGet and RubyGems packages that can only be published again once deleted")
+	migrateCmd.Flags().String("verify-checksums", "fail", "How to treat downloads that do not match the checksum recorded at export: fail, warn or off")
+	migrateCmd.Flags().String("staging-names", "normalized", "How to name the staged npm and container image tarballs: normalized (<name>-<version>.tgz, <name>-<tag>.tar) or original (the registry filename, name:tag for images)")
+	migrateCmd.Flags().Bool("copy-referrers", false, "Also copy the cosign signatures, attestations and OCI referrers attached to container images")
+	migrateCmd.Flags().Bool("verify-uploads", true, "Read every uploaded file back from the target and fail it when its digest differs from the upload")
+	migrateCmd.Flags().Bool("rollback-partial", false, "Delete a version from the target when it fails after some of its files were uploaded, so that it is synced again as a whole")
+	migrateCmd.Flags().Bool("smoke-test", false, "Once synced, resolve a sample of the synced versions from the target with mvn, npm, gem or docker, as their consumers would")
+	migrateCmd.Flags().Int("smoke-test-sample", 1, "Number of synced versions of each package type resolved by --smoke-test")
+	migrateCmd.Flags().String("report-json", "", "Write the combined report of every phase as JSON to this path")
+	migrateCmd.Flags().String("max-failed-files", "", "Fail the run when more files than this failed, a number such as 10 or a share such as 1%")
+	migrateCmd.Flags().String("max-failed-packages", "", "Fail the run when more packages than this failed, a number such as 10 or a share such as 1%")
+	migrateCmd.Flags().Bool("fail-on-critical", false, "Fail the run when a package of --critical or --critical-file failed")
+	migrateCmd.Flags().String("from", "", "Start from this phase (export, pull or sync), after fixing the failure that stopped a previous run")
+	migrateCmd.Flags().Bool("fail-fast", false, "Stop when a phase completes with failed packages instead of carrying on with the packages that succeeded")
+	migrateCmd.Flags().Bool("watch", false, "Keep migrating the versions published since the last cycle, every --interval, until interrupted or --watch-until")
+	migrateCmd.Flags().String("interval", "6h", "Time between the starts of two --watch cycles")
+	migrateCmd.Flags().String("source-container-registry", "", "Registry to pull container images from, e.g. docker.io/acme (default: ghcr.io, or containers.<source hostname> on GitHub Enterprise Server)")
+	migrateCmd.Flags().String("source-container-registry-user", "", "User of the source container registry (default: the source organization)")
+	migrateCmd.Flags().String("source-container-registry-password", "", "Password or token of the source container registry (default: the source token)")
+	migrateCmd.Flags().String("target-container-registry", "", "Registry to push container images to, e.g. docker.io/acme (default: ghcr.io, or containers.<target hostname> on GitHub Enterprise Server)")
+	migrateCmd.Flags().String("target-container-registry-user", "", "User of the target container registry (default: the target organization)")
+	migrateCmd.Flags().String("target-container-registry-password", "", "Password or token of the target container registry (default: the target token)")
+	migrateCmd.Flags().String("watch-until", "", "Date (2023-01-01) or RFC 3339 timestamp after which --watch starts no new cycle, e.g. the cutover")
+}
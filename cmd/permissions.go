@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/pkg/permissions"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var applyPermissionsCmd = &cobra.Command{
+	Use:   "apply-permissions",
+	Short: "Re-grants on the target the package access recorded by export --permissions",
+	Long:  "Gives the teams recorded by export --permissions their permission on the target repositories the packages are linked to, and lists the access that has to be granted by hand",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_SOURCE_ORGANIZATION": "source-organization",
+			"GHMPKG_TARGET_ORGANIZATION": "target-organization",
+			"GHMPKG_TARGET_TOKEN":        "target-token",
+			"GHMPKG_PACKAGE_TYPES":       "package-types",
+			"GHMPKG_MIGRATION_PATH":      "migration-path",
+			"GHMPKG_DRY_RUN":             "dry-run",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMPKG_SOURCE_ORGANIZATION": true,
+			"GHMPKG_TARGET_ORGANIZATION": true,
+			"GHMPKG_TARGET_TOKEN":        true,
+		})
+
+		logger := zap.L()
+		ShowConnectionStatus("sync")
+		exitOnError("apply permissions", permissions.ApplyPermissions(logger))
+	},
+}
+
+func init() {
+	applyPermissionsCmd.Flags().StringP("source-organization", "o", "", "Source Organization the permissions were exported from (required)")
+	applyPermissionsCmd.Flags().StringP("target-organization", "p", "", "Target Organization (required)")
+	applyPermissionsCmd.Flags().StringP("target-token", "t", "", "Target GitHub token with admin:org scope (required)")
+	applyPermissionsCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to apply permissions of (can be specified multiple times)")
+	applyPermissionsCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+	applyPermissionsCmd.Flags().Bool("dry-run", false, "Only print the grants that would be applied")
+
+	viper.BindPFlag("GHMPKG_SOURCE_ORGANIZATION", applyPermissionsCmd.Flags().Lookup("source-organization"))
+	viper.BindPFlag("GHMPKG_TARGET_ORGANIZATION", applyPermissionsCmd.Flags().Lookup("target-organization"))
+	viper.BindPFlag("GHMPKG_TARGET_TOKEN", applyPermissionsCmd.Flags().Lookup("target-token"))
+}
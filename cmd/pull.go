@@ -1,8 +1,7 @@
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
 	"github.com/mona-actions/gh-migrate-packages/pkg/pull"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -13,6 +12,40 @@ var pullCmd = &cobra.Command{
 	Use:   "pull",
 	Short: "pulls packages locally from the source organization",
 	Long:  "pulls packages locally from the source organization",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_PACKAGE_TYPES":                      "package-types",
+			"GHMPKG_PACKAGES":                           "packages",
+			"GHMPKG_INCLUDE":                            "include",
+			"GHMPKG_EXCLUDE":                            "exclude",
+			"GHMPKG_VERSIONS":                           "versions",
+			"GHMPKG_SINCE":                              "since",
+			"GHMPKG_SNAPSHOT":                           "snapshot",
+			"GHMPKG_STORE":                              "store",
+			"GHMPKG_REQUIRE_SNAPSHOT":                   "require-snapshot",
+			"GHMPKG_SHARD":                              "shard",
+			"GHMPKG_SHARD_SPLIT_TAGS":                   "shard-split-tags",
+			"GHMPKG_CRITICAL":                           "critical",
+			"GHMPKG_CRITICAL_FILE":                      "critical-file",
+			"GHMPKG_RESUME":                             "resume",
+			"GHMPKG_REPORT_JSON":                        "report-json",
+			"GHMPKG_MAX_FAILED_FILES":                   "max-failed-files",
+			"GHMPKG_MAX_FAILED_PACKAGES":                "max-failed-packages",
+			"GHMPKG_FAIL_ON_CRITICAL":                   "fail-on-critical",
+			"GHMPKG_REPOSITORY":                         "repository",
+			"GHMPKG_RETRY_FAILED":                       "retry-failed",
+			"GHMPKG_VERIFY_CHECKSUMS":                   "verify-checksums",
+			"GHMPKG_STAGING_NAMES":                      "staging-names",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY":          "source-container-registry",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY_USER":     "source-container-registry-user",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY_PASSWORD": "source-container-registry-password",
+			"GHMPKG_CONTAINER_STORAGE_LIMIT":            "container-storage-limit",
+			"GHMPKG_KEEP_IMAGES":                        "keep-images",
+			"GHMPKG_COPY_REFERRERS":                     "copy-referrers",
+			"GHMPKG_SOURCE_SUBDOMAIN_ISOLATION":         "source-subdomain-isolation",
+			"GHMPKG_MIGRATION_PATH":                     "migration-path",
+		})
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		GetFlagOrEnv(cmd, map[string]bool{
 			"GHMPKG_SOURCE_HOSTNAME":     false,
@@ -22,16 +55,44 @@ var pullCmd = &cobra.Command{
 
 		logger := zap.L()
 		ShowConnectionStatus("pull")
-		if err := pull.Pull(logger); err != nil {
-			fmt.Printf("failed to pull packages: %v\n", err)
-		}
+		exitOnError("pull packages", pull.Pull(logger))
 	},
 }
 
 func init() {
 	pullCmd.Flags().StringP("source-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional)")
+	pullCmd.Flags().Bool("source-subdomain-isolation", true, "The source GitHub Enterprise Server serves its registries on subdomains, such as maven.HOSTNAME, instead of HOSTNAME/_registry/maven")
 	pullCmd.Flags().StringP("source-organization", "o", "", "Organization (required)")
 	pullCmd.Flags().StringP("source-token", "t", "", "GitHub token (required)")
+	pullCmd.Flags().StringSliceP("repository", "r", []string{}, "Repositories to pull packages of, can be repeated or comma separated (optional, pulls all repositories if not specified)")
+	pullCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to pull (can be specified multiple times)")
+	pullCmd.Flags().StringSlice("packages", []string{}, "Only pull the packages with these exact names (can be specified multiple times)")
+	pullCmd.Flags().StringSlice("include", []string{}, "Only pull packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	pullCmd.Flags().StringSlice("exclude", []string{}, "Skip packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	pullCmd.Flags().StringSlice("versions", []string{}, "Only pull versions matching these semver constraints, e.g. >=2.0.0, ^1.4 or latest:5")
+	pullCmd.Flags().String("since", "", "Only pull versions created on or after this date (2023-01-01) or RFC 3339 timestamp")
+	pullCmd.Flags().String("snapshot", "", "Pull the export labeled with this snapshot name instead of the most recent one")
+	pullCmd.Flags().String("store", "csv", "Read the inventory from, and record the status of every file in, the SQLite database of the migration path: csv or sqlite")
+	pullCmd.Flags().Bool("require-snapshot", false, "Refuse to run without --snapshot, so everyone operates on the same frozen export")
+	pullCmd.Flags().String("shard", "", "Only pull the share of the packages dealt to this shard of a migration split into runs side by side, e.g. 2/4")
+	pullCmd.Flags().Int("shard-split-tags", common.DefaultShardSplitTags, "Deal the versions of container packages with more tags than this to the shards one digest at a time, 0 to never split a package")
+	pullCmd.Flags().StringSlice("critical", []string{}, "Packages to pull before every other one, names or globs optionally prefixed with a package type, e.g. npm/web-*")
+	pullCmd.Flags().String("critical-file", "", "File listing more critical packages, one per line")
+	pullCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+	pullCmd.Flags().String("report-json", "", "Write the report with the result of every package, version and file as JSON to this path")
+	pullCmd.Flags().String("max-failed-files", "", "Fail the run when more files than this failed, a number such as 10 or a share such as 1%")
+	pullCmd.Flags().String("max-failed-packages", "", "Fail the run when more packages than this failed, a number such as 10 or a share such as 1%")
+	pullCmd.Flags().Bool("fail-on-critical", false, "Fail the run when a package of --critical or --critical-file failed")
+	pullCmd.Flags().String("retry-failed", "", "Only process the entries that failed in this --report-json report of a previous pull")
+	pullCmd.Flags().String("verify-checksums", "fail", "How to treat downloads that do not match the checksum recorded at export: fail, warn or off")
+	pullCmd.Flags().String("staging-names", "normalized", "How to name the staged npm and container image tarballs: normalized (<name>-<version>.tgz, <name>-<tag>.tar) or original (the registry filename, name:tag for images)")
+	pullCmd.Flags().String("source-container-registry", "", "Registry to pull container images from, e.g. docker.io/acme (default: ghcr.io, or containers.<source hostname> on GitHub Enterprise Server)")
+	pullCmd.Flags().String("source-container-registry-user", "", "User of the source container registry (default: the source organization)")
+	pullCmd.Flags().String("source-container-registry-password", "", "Password or token of the source container registry (default: the source token)")
+	pullCmd.Flags().String("container-storage-limit", "", "Wait before pulling more images while images take more than this in the Docker daemon, e.g. 50GB")
+	pullCmd.Flags().Bool("keep-images", false, "Keep pulled images in the Docker daemon instead of removing them once saved")
+	pullCmd.Flags().Bool("copy-referrers", false, "Also pull the cosign signatures, attestations and OCI referrers attached to container images")
+	pullCmd.Flags().Bool("resume", false, "Resume an interrupted pull, skipping files recorded as completed in the state file")
 
 	viper.BindPFlag("GHMPKG_SOURCE_HOSTNAME", pullCmd.Flags().Lookup("source-hostname"))
 	viper.BindPFlag("GHMPKG_SOURCE_ORGANIZATION", pullCmd.Flags().Lookup("source-organization"))
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/pkg/references"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var referencesCmd = &cobra.Command{
+	Use:   "references",
+	Short: "Lists the workflows, Dockerfiles and package configs referencing the source registries",
+	Long:  "Scans the GitHub Actions workflows, Dockerfiles, .npmrc files and Maven poms of the source repositories for references to the source registries, such as ghcr.io/<source org>/ images and the @<source org> npm scope, and writes the files needing an update after the cutover to a CSV",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_SOURCE_ORGANIZATION":        "source-organization",
+			"GHMPKG_SOURCE_TOKEN":               "source-token",
+			"GHMPKG_SOURCE_HOSTNAME":            "source-hostname",
+			"GHMPKG_SOURCE_SUBDOMAIN_ISOLATION": "source-subdomain-isolation",
+			"GHMPKG_TARGET_ORGANIZATION":        "target-organization",
+			"GHMPKG_PACKAGE_TYPES":              "package-types",
+			"GHMPKG_REPOSITORY":                 "repository",
+			"GHMPKG_MIGRATION_PATH":             "migration-path",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMPKG_SOURCE_HOSTNAME":     false,
+			"GHMPKG_SOURCE_ORGANIZATION": true,
+			"GHMPKG_SOURCE_TOKEN":        true,
+		})
+
+		logger := zap.L()
+		ShowConnectionStatus("export")
+		exitOnError("scan references", references.References(logger))
+	},
+}
+
+func init() {
+	referencesCmd.Flags().StringP("source-organization", "o", "", "Source Organization (required)")
+	referencesCmd.Flags().StringP("source-token", "s", "", "Source GitHub token with repo scope (required)")
+	referencesCmd.Flags().StringP("source-hostname", "n", "", "Source GitHub Enterprise Server hostname URL (optional)")
+	referencesCmd.Flags().Bool("source-subdomain-isolation", true, "The source GitHub Enterprise Server serves its registries on subdomains, such as maven.HOSTNAME, instead of HOSTNAME/_registry/maven")
+	referencesCmd.Flags().StringP("target-organization", "p", "", "Target Organization, to suggest the reference each one becomes (optional)")
+	referencesCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to look for references of (can be specified multiple times)")
+	referencesCmd.Flags().StringSliceP("repository", "r", []string{}, "Repositories to scan, can be repeated or comma separated (optional, scans all repositories if not specified)")
+	referencesCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mona-actions/gh-migrate-packages/pkg/report"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Works with the reports written by --report-json",
+	Long:  "Works with the reports written by --report-json",
+}
+
+var reportAggregateCmd = &cobra.Command{
+	Use:   "aggregate <report or directory>...",
+	Short: "Merges the reports of sharded runs into one summary",
+	Long:  "Merges the --report-json reports of many runs, such as the shards of a migration, into one summary: the totals of every command, the items still failed across the shards and the items more than one shard processed",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		logger := zap.L()
+		aggregate, err := report.Build(logger, args)
+		if err != nil {
+			fmt.Printf("failed to aggregate reports: %v\n", err)
+			os.Exit(1)
+		}
+		aggregate.Print()
+		if output != "" {
+			if err := aggregate.Write(output); err != nil {
+				fmt.Printf("failed to aggregate reports: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	reportAggregateCmd.Flags().String("output", "", "Write the aggregate, with every failure and duplicate, as JSON to this path")
+	reportCmd.AddCommand(reportAggregateCmd)
+}
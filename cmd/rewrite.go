@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mona-actions/gh-migrate-packages/pkg/references"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var rewriteReferencesCmd = &cobra.Command{
+	Use:   "rewrite-references",
+	Short: "Updates the target repositories still referencing the source registries",
+	Long:  "Rewrites the references to the source registries in the GitHub Actions workflows, Dockerfiles, .npmrc files and Maven poms of the target repositories to use the target registries, as a patch per repository or as pull requests",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_SOURCE_ORGANIZATION":        "source-organization",
+			"GHMPKG_SOURCE_HOSTNAME":            "source-hostname",
+			"GHMPKG_SOURCE_SUBDOMAIN_ISOLATION": "source-subdomain-isolation",
+			"GHMPKG_TARGET_ORGANIZATION":        "target-organization",
+			"GHMPKG_TARGET_TOKEN":               "target-token",
+			"GHMPKG_PACKAGE_TYPES":              "package-types",
+			"GHMPKG_REPOSITORY":                 "repository",
+			"GHMPKG_OPEN_PULL_REQUESTS":         "open-pull-requests",
+			"GHMPKG_REWRITE_BRANCH":             "branch",
+			"GHMPKG_MIGRATION_PATH":             "migration-path",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMPKG_SOURCE_ORGANIZATION": true,
+			"GHMPKG_TARGET_ORGANIZATION": true,
+			"GHMPKG_TARGET_TOKEN":        true,
+		})
+
+		logger := zap.L()
+		ShowConnectionStatus("sync")
+		if err := references.RewriteReferences(logger); err != nil {
+			fmt.Printf("failed to rewrite references: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rewriteReferencesCmd.Flags().StringP("source-organization", "o", "", "Source Organization the packages were migrated from (required)")
+	rewriteReferencesCmd.Flags().StringP("source-hostname", "n", "", "Source GitHub Enterprise Server hostname URL (optional)")
+	rewriteReferencesCmd.Flags().Bool("source-subdomain-isolation", true, "The source GitHub Enterprise Server serves its registries on subdomains, such as maven.HOSTNAME, instead of HOSTNAME/_registry/maven")
+	rewriteReferencesCmd.Flags().StringP("target-organization", "p", "", "Target Organization holding the repositories to update (required)")
+	rewriteReferencesCmd.Flags().StringP("target-token", "t", "", "Target GitHub token with repo scope, and workflow scope to update workflows (required)")
+	rewriteReferencesCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to rewrite references of (can be specified multiple times)")
+	rewriteReferencesCmd.Flags().StringSliceP("repository", "r", []string{}, "Repositories to update, can be repeated or comma separated (optional, updates all repositories if not specified)")
+	rewriteReferencesCmd.Flags().Bool("open-pull-requests", false, "Push the changes to a branch of every repository and open a pull request, instead of writing patches")
+	rewriteReferencesCmd.Flags().String("branch", references.DefaultRewriteBranch, "Branch the changes are pushed to with --open-pull-requests")
+	rewriteReferencesCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+}
@@ -2,27 +2,113 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/mona-actions/gh-migrate-packages/internal/credentials"
+	"github.com/mona-actions/gh-migrate-packages/internal/logging"
+	"github.com/mona-actions/gh-migrate-packages/internal/progress"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/mona-actions/gh-migrate-packages/pkg/config"
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "migrate-packages",
 	Short: "gh cli extension to migrate packages between organizations",
 	Long:  "gh cli extension to migrate packages between organizations",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Refuse to connect anywhere with TLS settings that cannot be honored
+		if _, err := utils.TLSConfig(); err != nil {
+			return err
+		}
+		run.SetCommandLine(redactArgs(cmd, os.Args))
+		run.SetConfig(resolvedConfig)
+		if utils.ReadOnly() {
+			if err := checkReadOnly(cmd); err != nil {
+				return err
+			}
+			consoleToStderr()
+		}
+		if viper.GetBool("GHMPKG_RECORD_HTTP") && cmd.Name() != inspectCmd.Name() {
+			path, err := utils.StartHTTPRecording(cmd.Name())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("🎙️ Recording HTTP traffic to %s\n", path)
+		}
+		if path := viper.GetString("GHMPKG_PROGRESS_SOCKET"); path != "" {
+			server, err := progress.Listen(path, cmd.Name())
+			if err != nil {
+				return fmt.Errorf("failed to listen on progress socket %s: %w", path, err)
+			}
+			progressServer = server
+		}
+		return nil
+	},
 }
 
+// readOnlyCommands are the commands --read-only allows, the ones that only
+// read the source organization and the local files
+var readOnlyCommands = []string{"export", "simulate", "validate", "version", "help"}
+
+// checkReadOnly refuses the commands and settings that would write in
+// read-only mode: files, credentials or anything on the target
+func checkReadOnly(cmd *cobra.Command) error {
+	if !utils.Contains(readOnlyCommands, cmd.Name()) {
+		return fmt.Errorf("%s is not allowed with --read-only, only %s are", cmd.CommandPath(), strings.Join(readOnlyCommands, ", "))
+	}
+	if viper.GetBool("GHMPKG_RECORD_HTTP") {
+		return fmt.Errorf("--record-http writes a recording file, which --read-only does not allow")
+	}
+	if viper.GetString("GHMPKG_PROGRESS_SOCKET") != "" {
+		return fmt.Errorf("--progress-socket creates a socket file, which --read-only does not allow")
+	}
+	if viper.GetString("GHMPKG_STORAGE") != "" {
+		return fmt.Errorf("--storage copies files to object storage, which --read-only does not allow")
+	}
+	// The other providers create tokens or leases to get them
+	if provider := strings.ToLower(viper.GetString("GHMPKG_CREDENTIAL_PROVIDER")); provider != "" && provider != credentials.Env && provider != credentials.GH {
+		return fmt.Errorf("--credential-provider %s is not allowed with --read-only, use %s or %s", provider, credentials.Env, credentials.GH)
+	}
+	return nil
+}
+
+// consoleToStderr moves the console output to stderr, so a read-only run
+// writes nothing but its results to stdout
+func consoleToStderr() {
+	utils.ReadOnlyOutput = os.Stdout
+	os.Stdout = os.Stderr
+	pterm.SetDefaultOutput(os.Stderr)
+	// The prefix printers took the default output when pterm was loaded
+	for _, printer := range []*pterm.PrefixPrinter{&pterm.Info, &pterm.Success, &pterm.Warning, &pterm.Error, &pterm.Fatal, &pterm.Debug, &pterm.Description} {
+		printer.Writer = os.Stderr
+	}
+}
+
+// progressServer publishes the progress of the run, nil without --progress-socket
+var progressServer *progress.Server
+
 func Execute() error {
-	return rootCmd.Execute()
+	logRunAfterPreRun(rootCmd)
+	err := rootCmd.Execute()
+	if progressServer != nil {
+		progressServer.Close()
+	}
+	return err
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	rootCmd.Version = run.VersionString()
 
 	// Define root command flags
 	// rootCmd.PersistentFlags().String("http-proxy", "", "HTTP proxy")
@@ -30,6 +116,27 @@ func init() {
 	// rootCmd.PersistentFlags().String("no-proxy", "", "No proxy list")
 	rootCmd.PersistentFlags().Int("retry-max", 3, "Maximum retry attempts")
 	rootCmd.PersistentFlags().String("retry-delay", "1s", "Delay between retries")
+	rootCmd.PersistentFlags().Int("concurrency", 1, "Number of packages processed in parallel by pull and sync")
+	rootCmd.PersistentFlags().Int("container-concurrency", 0, "Most container images processed in parallel, below --concurrency (0: no separate limit)")
+	rootCmd.PersistentFlags().Int("file-concurrency", 5, "Number of files of a version downloaded or uploaded in parallel")
+	rootCmd.PersistentFlags().String("tls-min-version", "1.2", "Minimum TLS version for HTTPS connections (1.2 or 1.3)")
+	rootCmd.PersistentFlags().String("tls-cipher-policy", "", "TLS cipher policy: default or fips (fips is enforced in FIPS builds)")
+	rootCmd.PersistentFlags().StringSlice("package-type-alias", []string{}, "Extra package type aliases as alias=type, e.g. podman=container (docker and gradle are built in)")
+	rootCmd.PersistentFlags().Bool("record-http", false, "Record sanitized metadata of every HTTP request to the migration directory")
+	rootCmd.PersistentFlags().Int("error-rate-threshold", 50, "Back off from a registry when this percentage of its recent operations failed (0 disables)")
+	rootCmd.PersistentFlags().Int("error-rate-window", 20, "Number of recent operations the error rate of a registry is measured over")
+	rootCmd.PersistentFlags().String("error-rate-backoff", "30s", "First pause after an error rate spike, doubled on every new spike up to 10m")
+	rootCmd.PersistentFlags().String("storage", "", "Object storage pulled files are copied to and synced from: s3://bucket/prefix, azblob://account/container/prefix or gs://bucket/prefix")
+	rootCmd.PersistentFlags().String("credential-provider", "env", "Where tokens come from: env (flags and environment variables), gh, app, vault or aws")
+	rootCmd.PersistentFlags().String("config", "", "Config file to read the settings from, .env or YAML (default: ./.env)")
+	rootCmd.PersistentFlags().Bool("user", false, "The source organization is a user account (detected automatically otherwise)")
+	rootCmd.PersistentFlags().String("progress-socket", "", "Publish the progress of pull and sync as JSON lines on a unix socket at this path")
+	rootCmd.PersistentFlags().String("progress-bars", common.ProgressBarsAuto, "Draw progress bars with an ETA during pull and sync: auto (in a terminal), always or never")
+	rootCmd.PersistentFlags().String("log-level", "", "Also write log entries of this level and above to stderr: debug, info, warn or error")
+	rootCmd.PersistentFlags().Bool("verbose", false, "Write debug log entries to stderr, like --log-level debug")
+	rootCmd.PersistentFlags().String("log-format", "console", "Format of the log entries written to stderr: console or json")
+	rootCmd.PersistentFlags().Bool("read-only", false, "Audit the source without writing anything: no files, credentials or target calls, results go to stdout")
+	rootCmd.PersistentFlags().String("run-id", "", "Identifier recorded in the logs, reports and CSV files of the run, to tie several commands together (default: generated)")
 
 	// Bind flags to viper
 	// viper.BindPFlag("HTTP_PROXY", rootCmd.PersistentFlags().Lookup("http-proxy"))
@@ -37,11 +144,44 @@ func init() {
 	// viper.BindPFlag("NO_PROXY", rootCmd.PersistentFlags().Lookup("no-proxy"))
 	viper.BindPFlag("RETRY_MAX", rootCmd.PersistentFlags().Lookup("retry-max"))
 	viper.BindPFlag("RETRY_DELAY", rootCmd.PersistentFlags().Lookup("retry-delay"))
+	viper.BindPFlag("GHMPKG_CONCURRENCY", rootCmd.PersistentFlags().Lookup("concurrency"))
+	viper.BindPFlag("GHMPKG_CONTAINER_CONCURRENCY", rootCmd.PersistentFlags().Lookup("container-concurrency"))
+	viper.BindPFlag("GHMPKG_FILE_CONCURRENCY", rootCmd.PersistentFlags().Lookup("file-concurrency"))
+	viper.BindPFlag("GHMPKG_TLS_MIN_VERSION", rootCmd.PersistentFlags().Lookup("tls-min-version"))
+	viper.BindPFlag("GHMPKG_TLS_CIPHER_POLICY", rootCmd.PersistentFlags().Lookup("tls-cipher-policy"))
+	viper.BindPFlag("GHMPKG_RECORD_HTTP", rootCmd.PersistentFlags().Lookup("record-http"))
+	viper.BindPFlag("GHMPKG_PACKAGE_TYPE_ALIASES", rootCmd.PersistentFlags().Lookup("package-type-alias"))
+	viper.BindPFlag("GHMPKG_ERROR_RATE_THRESHOLD", rootCmd.PersistentFlags().Lookup("error-rate-threshold"))
+	viper.BindPFlag("GHMPKG_ERROR_RATE_WINDOW", rootCmd.PersistentFlags().Lookup("error-rate-window"))
+	viper.BindPFlag("GHMPKG_ERROR_RATE_BACKOFF", rootCmd.PersistentFlags().Lookup("error-rate-backoff"))
+	viper.BindPFlag("GHMPKG_STORAGE", rootCmd.PersistentFlags().Lookup("storage"))
+	viper.BindPFlag("GHMPKG_CREDENTIAL_PROVIDER", rootCmd.PersistentFlags().Lookup("credential-provider"))
+	viper.BindPFlag("GHMPKG_USER", rootCmd.PersistentFlags().Lookup("user"))
+	viper.BindPFlag("GHMPKG_RUN_ID", rootCmd.PersistentFlags().Lookup("run-id"))
+	viper.BindPFlag("GHMPKG_PROGRESS_SOCKET", rootCmd.PersistentFlags().Lookup("progress-socket"))
+	viper.BindPFlag("GHMPKG_PROGRESS_BARS", rootCmd.PersistentFlags().Lookup("progress-bars"))
+	viper.BindPFlag("GHMPKG_LOG_LEVEL", rootCmd.PersistentFlags().Lookup("log-level"))
+	viper.BindPFlag("GHMPKG_VERBOSE", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("GHMPKG_LOG_FORMAT", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("GHMPKG_READ_ONLY", rootCmd.PersistentFlags().Lookup("read-only"))
 
 	// Add subcommands
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(pullCmd)
 	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(inventoryTargetCmd)
+	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(ledgerCmd)
+	rootCmd.AddCommand(applyPermissionsCmd)
+	rootCmd.AddCommand(capabilitiesCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(simulateCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(referencesCmd)
+	rootCmd.AddCommand(rewriteReferencesCmd)
+	rootCmd.AddCommand(versionCmd)
 
 	// hide -h, --help from global/proxy flags
 	rootCmd.Flags().BoolP("help", "h", false, "")
@@ -49,10 +189,14 @@ func init() {
 }
 
 func initConfig() {
-	// Allow .env file
-	viper.SetConfigType("env")
-	viper.AddConfigPath(".")
-	viper.SetConfigName(".env")
+	// Allow .env file, or the .env or YAML file given with --config
+	if configFile, _ := rootCmd.PersistentFlags().GetString("config"); configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigType("env")
+		viper.AddConfigPath(".")
+		viper.SetConfigName(".env")
+	}
 
 	// Read config file
 	if err := viper.ReadInConfig(); err != nil {
@@ -64,37 +208,160 @@ func initConfig() {
 	// Read from environment
 	viper.AutomaticEnv()
 
-	// Create a timestamp for the log file name
-	timestamp := time.Now().Format("2006-01-02T15-04-05")
+	// Read-only runs write no log file, --log-level still logs to stderr
+	var logFile io.Writer = io.Discard
+	if !utils.ReadOnly() {
+		// Create a timestamp for the log file name
+		timestamp := time.Now().Format("2006-01-02T15-04-05")
 
-	// Define the log directory and file path
-	logDir := "./migration-packages/logs"
-	logFilePath := fmt.Sprintf("%s/%s.log", logDir, timestamp)
+		// Define the log directory and file path
+		logDir := filepath.Join(logMigrationPath(), "logs")
+		logFilePath := fmt.Sprintf("%s/%s.log", logDir, timestamp)
 
-	// Create log directory if it doesn't exist
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create log directory: %v\n", err)
-		os.Exit(1)
+		// Create log directory if it doesn't exist
+		if err := os.MkdirAll(logDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create log directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Create the log file
+		file, err := os.Create(logFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create log file: %v\n", err)
+			os.Exit(1)
+		}
+		logFile = file
 	}
 
-	// Create the log file
-	logFile, err := os.Create(logFilePath)
+	// Configure the logger to write to the file, and to stderr when asked to
+	logger, err := logging.New(logFile, os.Stderr, logging.Options{
+		Level:   viper.GetString("GHMPKG_LOG_LEVEL"),
+		Verbose: viper.GetBool("GHMPKG_VERBOSE"),
+		Format:  viper.GetString("GHMPKG_LOG_FORMAT"),
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create log file: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Configure the logger to write to the file
-	encoderConfig := zap.NewProductionEncoderConfig()
-	encoderConfig.TimeKey = "timestamp"
-	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	core := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		zapcore.AddSync(logFile),
-		zap.InfoLevel,
-	)
-	logger := zap.New(core)
+	logger = logger.With(zap.String("runId", run.ID()), zap.String("version", run.VersionString()))
 
 	// Replace the global logger with the configured one
 	zap.ReplaceGlobals(logger)
 }
+
+// logMigrationPath is the migration directory the log is written to. Loggers are
+// set up before flags are bound to viper, so the --migration-path flag of the
+// command run is read first, then GHMPKG_MIGRATION_PATH.
+func logMigrationPath() string {
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil {
+		if flag := cmd.Flags().Lookup("migration-path"); flag != nil && flag.Changed {
+			return flag.Value.String()
+		}
+	}
+	return utils.MigrationPath()
+}
+
+// logRunAfterPreRun logs the run every command starts, once its PreRun has bound
+// the flags so the configuration logged is the one the command runs with
+func logRunAfterPreRun(cmd *cobra.Command) {
+	for _, sub := range cmd.Commands() {
+		preRun := sub.PreRun
+		sub.PreRun = func(cmd *cobra.Command, args []string) {
+			if preRun != nil {
+				preRun(cmd, args)
+			}
+			metadata := run.Current()
+			zap.L().Info("Starting run",
+				zap.String("command", cmd.CommandPath()),
+				zap.Strings("commandLine", metadata.CommandLine),
+				zap.Any("config", metadata.Config))
+		}
+		logRunAfterPreRun(sub)
+	}
+}
+
+// secretFlags are the flag name suffixes whose values are redacted from the
+// command line recorded for the run
+var secretFlags = []string{"token", "password", "api-key", "signing-key"}
+
+func isSecretFlag(name string) bool {
+	for _, suffix := range secretFlags {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactArgs replaces the values of secret flags, given as --name value,
+// --name=value, -t value or -tvalue
+func redactArgs(cmd *cobra.Command, args []string) []string {
+	redacted := append([]string{filepath.Base(args[0])}, args[1:]...)
+	for i := 1; i < len(redacted); i++ {
+		arg := redacted[i]
+		if arg == "--" {
+			break
+		}
+		var name, inline string
+		var hasInline bool
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name, inline, hasInline = strings.Cut(arg[2:], "=")
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			flag := cmd.Flags().ShorthandLookup(arg[1:2])
+			if flag == nil {
+				continue
+			}
+			name, inline, hasInline = flag.Name, arg[2:], len(arg) > 2
+			inline = strings.TrimPrefix(inline, "=")
+		default:
+			continue
+		}
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || !isSecretFlag(flag.Name) {
+			continue
+		}
+		if hasInline {
+			redacted[i] = strings.TrimSuffix(arg, inline) + "***"
+		} else if i+1 < len(redacted) {
+			redacted[i+1] = "***"
+			i++
+		}
+	}
+	return redacted
+}
+
+// resolvedConfig is the configuration of the run, every documented setting with
+// a value, secrets redacted
+func resolvedConfig() map[string]string {
+	settings := make(map[string]string)
+	for _, name := range viper.AllKeys() {
+		key, ok := config.Lookup(name)
+		if !ok {
+			continue
+		}
+		var value string
+		switch v := viper.Get(name).(type) {
+		case nil:
+			continue
+		case []string:
+			value = strings.Join(v, ",")
+		case []interface{}:
+			values := make([]string, len(v))
+			for i, item := range v {
+				values[i] = fmt.Sprint(item)
+			}
+			value = strings.Join(values, ",")
+		default:
+			value = fmt.Sprint(v)
+		}
+		if value == "" {
+			continue
+		}
+		if key.IsSecret() {
+			value = "***"
+		}
+		settings[key.Name] = value
+	}
+	return settings
+}
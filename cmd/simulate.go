@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/mona-actions/gh-migrate-packages/pkg/simulate"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Projects the timeline and API requests of a migration from the export",
+	Long:  "Simulates pulling and syncing the exported packages with the configured concurrency, warm-up and shards, without any network access, to project how long the migration takes and how many API requests it makes per hour",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_SOURCE_ORGANIZATION":  "source-organization",
+			"GHMPKG_MIGRATION_PATH":       "migration-path",
+			"GHMPKG_PACKAGE_TYPES":        "package-types",
+			"GHMPKG_REPOSITORY":           "repository",
+			"GHMPKG_PACKAGES":             "packages",
+			"GHMPKG_INCLUDE":              "include",
+			"GHMPKG_EXCLUDE":              "exclude",
+			"GHMPKG_VERSIONS":             "versions",
+			"GHMPKG_SINCE":                "since",
+			"GHMPKG_SNAPSHOT":             "snapshot",
+			"GHMPKG_VERIFY_UPLOADS":       "verify-uploads",
+			"GHMPKG_WARMUP":               "warmup",
+			"GHMPKG_WARMUP_OPERATIONS":    "warmup-operations",
+			"GHMPKG_WARMUP_INTERVAL":      "warmup-interval",
+			"GHMPKG_TARGET_REGISTRY":      "target-registry",
+			"GHMPKG_SIMULATE_PHASES":      "phases",
+			"GHMPKG_SIMULATE_FILE_TIME":   "file-time",
+			"GHMPKG_SIMULATE_API_LATENCY": "api-latency",
+			"GHMPKG_SIMULATE_SHARDS":      "shards",
+			"GHMPKG_SHARD_SPLIT_TAGS":     "shard-split-tags",
+			"GHMPKG_SIMULATE_BUCKET":      "bucket",
+			"GHMPKG_SIMULATE_START":       "start",
+			"GHMPKG_SIMULATE_WINDOW":      "window",
+			"GHMPKG_SIMULATE_OUTPUT":      "output",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zap.L()
+		exitOnError("simulate migration", simulate.Simulate(logger))
+	},
+}
+
+func init() {
+	simulateCmd.Flags().StringP("source-organization", "o", "", "Source Organization, to pick its export when the migration directory has several (optional)")
+	simulateCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+	simulateCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to simulate (can be specified multiple times)")
+	simulateCmd.Flags().StringSliceP("repository", "r", []string{}, "Repositories to simulate, can be repeated or comma separated (optional, simulates all repositories if not specified)")
+	simulateCmd.Flags().StringSlice("packages", []string{}, "Only simulate the packages with these exact names (can be specified multiple times)")
+	simulateCmd.Flags().StringSlice("include", []string{}, "Only simulate packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	simulateCmd.Flags().StringSlice("exclude", []string{}, "Skip packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	simulateCmd.Flags().StringSlice("versions", []string{}, "Only simulate versions matching these semver constraints, e.g. >=2.0.0, ^1.4 or latest:5")
+	simulateCmd.Flags().String("since", "", "Only simulate versions created on or after this date (2023-01-01) or RFC 3339 timestamp")
+	simulateCmd.Flags().String("snapshot", "", "Simulate the export labeled with this snapshot name instead of the most recent one")
+	simulateCmd.Flags().Bool("verify-uploads", true, "Count a read back from the target for every uploaded file, as sync does by default")
+	simulateCmd.Flags().Bool("warmup", false, "Simulate the sync warm-up, ramping up to --concurrency")
+	simulateCmd.Flags().Int("warmup-operations", 200, "Number of versions the warm-up ramps up over")
+	simulateCmd.Flags().String("warmup-interval", "2s", "Time between two uploads at the start of the warm-up")
+	simulateCmd.Flags().String("target-registry", "github", "Where the packages are published: github, artifactory, nexus, azure or codeartifact")
+	simulateCmd.Flags().StringSlice("phases", []string{}, "Phases to simulate: pull, sync or both (default: both)")
+	simulateCmd.Flags().StringSlice("file-time", []string{}, "Time to transfer a file, for every type (5s) or per type (container=1m) (default: 2s, 20s for images)")
+	simulateCmd.Flags().String("api-latency", "250ms", "Time added for every API or registry request")
+	simulateCmd.Flags().Int("shards", 1, "Number of migrations run side by side, each with a share of the packages")
+	simulateCmd.Flags().Int("shard-split-tags", common.DefaultShardSplitTags, "Deal the versions of container packages with more tags than this to the shards one digest at a time, 0 to never split a package")
+	simulateCmd.Flags().String("bucket", "1h", "Length of a window of the timeline")
+	simulateCmd.Flags().String("start", "", "Planned start, e.g. 2024-06-01T22:00, to show the timeline in clock time")
+	simulateCmd.Flags().String("window", "", "Length of the maintenance window, e.g. 8h, to check the migration fits and suggest a number of shards")
+	simulateCmd.Flags().String("output", "", "Write the simulation as JSON to this path")
+}
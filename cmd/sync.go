@@ -1,8 +1,8 @@
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
 	"github.com/mona-actions/gh-migrate-packages/pkg/sync"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -13,33 +13,191 @@ var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "syncs packages to the target organization",
 	Long:  "syncs packages to the target organization",
-	Run: func(cmd *cobra.Command, args []string) {
-		GetFlagOrEnv(cmd, map[string]bool{
-			"GHMPKG_TARGET_HOSTNAME":     false,
-			"GHMPKG_TARGET_ORGANIZATION": true,
-			"GHMPKG_TARGET_TOKEN":        true,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_PACKAGE_TYPES":                      "package-types",
+			"GHMPKG_PACKAGES":                           "packages",
+			"GHMPKG_INCLUDE":                            "include",
+			"GHMPKG_EXCLUDE":                            "exclude",
+			"GHMPKG_VERSIONS":                           "versions",
+			"GHMPKG_SINCE":                              "since",
+			"GHMPKG_SNAPSHOT":                           "snapshot",
+			"GHMPKG_STORE":                              "store",
+			"GHMPKG_REQUIRE_SNAPSHOT":                   "require-snapshot",
+			"GHMPKG_SHARD":                              "shard",
+			"GHMPKG_SHARD_SPLIT_TAGS":                   "shard-split-tags",
+			"GHMPKG_CRITICAL":                           "critical",
+			"GHMPKG_CRITICAL_FILE":                      "critical-file",
+			"GHMPKG_VERIFY_CRITICAL":                    "verify-critical",
+			"GHMPKG_RESUME":                             "resume",
+			"GHMPKG_KEEP_WORK_FILES":                    "keep-work-files",
+			"GHMPKG_EXISTING_PACKAGES":                  "existing-packages",
+			"GHMPKG_CONFLICT_POLICY":                    "conflict-policy",
+			"GHMPKG_RENAME_SUFFIX":                      "rename-suffix",
+			"GHMPKG_REPORT_JSON":                        "report-json",
+			"GHMPKG_MAX_FAILED_FILES":                   "max-failed-files",
+			"GHMPKG_MAX_FAILED_PACKAGES":                "max-failed-packages",
+			"GHMPKG_FAIL_ON_CRITICAL":                   "fail-on-critical",
+			"GHMPKG_RETRY_FAILED":                       "retry-failed",
+			"GHMPKG_REPOSITORY":                         "repository",
+			"GHMPKG_SOURCE_TOKEN":                       "source-token",
+			"GHMPKG_MAX_INVENTORY_AGE":                  "max-inventory-age",
+			"GHMPKG_STRICT":                             "strict",
+			"GHMPKG_VERIFY_UPLOADS":                     "verify-uploads",
+			"GHMPKG_ROLLBACK_PARTIAL":                   "rollback-partial",
+			"GHMPKG_SMOKE_TEST":                         "smoke-test",
+			"GHMPKG_SMOKE_TEST_SAMPLE":                  "smoke-test-sample",
+			"GHMPKG_WARMUP":                             "warmup",
+			"GHMPKG_WARMUP_OPERATIONS":                  "warmup-operations",
+			"GHMPKG_WARMUP_INTERVAL":                    "warmup-interval",
+			"GHMPKG_STREAM":                             "stream",
+			"GHMPKG_VERIFY_CHECKSUMS":                   "verify-checksums",
+			"GHMPKG_STAGING_NAMES":                      "staging-names",
+			"GHMPKG_TARGET_REGISTRY":                    "target-registry",
+			"GHMPKG_ARTIFACTORY_URL":                    "artifactory-url",
+			"GHMPKG_ARTIFACTORY_REPOS":                  "artifactory-repos",
+			"GHMPKG_ARTIFACTORY_USER":                   "artifactory-user",
+			"GHMPKG_ARTIFACTORY_API_KEY":                "artifactory-api-key",
+			"GHMPKG_ARTIFACTORY_DOCKER_REGISTRY":        "artifactory-docker-registry",
+			"GHMPKG_NEXUS_URL":                          "nexus-url",
+			"GHMPKG_NEXUS_REPOS":                        "nexus-repos",
+			"GHMPKG_NEXUS_USER":                         "nexus-user",
+			"GHMPKG_NEXUS_PASSWORD":                     "nexus-password",
+			"GHMPKG_AZURE_URL":                          "azure-url",
+			"GHMPKG_AZURE_ORGANIZATION":                 "azure-organization",
+			"GHMPKG_AZURE_PROJECT":                      "azure-project",
+			"GHMPKG_AZURE_FEED":                         "azure-feed",
+			"GHMPKG_AZURE_TOKEN":                        "azure-token",
+			"GHMPKG_CODEARTIFACT_DOMAIN":                "codeartifact-domain",
+			"GHMPKG_CODEARTIFACT_DOMAIN_OWNER":          "codeartifact-domain-owner",
+			"GHMPKG_CODEARTIFACT_REPOSITORY":            "codeartifact-repository",
+			"GHMPKG_CODEARTIFACT_ROLE_ARN":              "codeartifact-role-arn",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY":          "source-container-registry",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY_USER":     "source-container-registry-user",
+			"GHMPKG_SOURCE_CONTAINER_REGISTRY_PASSWORD": "source-container-registry-password",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY":          "target-container-registry",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY_USER":     "target-container-registry-user",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY_PASSWORD": "target-container-registry-password",
+			"GHMPKG_CONTAINER_STORAGE_LIMIT":            "container-storage-limit",
+			"GHMPKG_KEEP_IMAGES":                        "keep-images",
+			"GHMPKG_COPY_REFERRERS":                     "copy-referrers",
+			"GHMPKG_MAPPING_FILE":                       "mapping-file",
+			"GHMPKG_TRANSFER":                           "transfer",
+			"GHMPKG_TRANSFER_DELETE":                    "transfer-delete",
 		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, syncFlags())
 
 		logger := zap.L()
 		ShowConnectionStatus("sync")
-		if err := sync.Sync(logger); err != nil {
-			fmt.Printf("failed to sync packages: %v\n", err)
-		}
+		exitOnError("sync packages", sync.Sync(logger))
 	},
 }
 
+// syncFlags returns the settings sync requires, those of the target registry
+// when it is not GitHub Packages
+func syncFlags() map[string]bool {
+	flags := map[string]bool{
+		"GHMPKG_TARGET_HOSTNAME":     false,
+		"GHMPKG_TARGET_ORGANIZATION": true,
+		"GHMPKG_TARGET_TOKEN":        true,
+	}
+	// Another registry replaces the target organization
+	if providers.ExternalTarget() {
+		flags = map[string]bool{}
+		for _, setting := range providers.TargetRequiredSettings() {
+			flags[setting] = true
+		}
+	}
+	// Streaming downloads from the source during the sync
+	if viper.GetBool("GHMPKG_STREAM") {
+		flags["GHMPKG_SOURCE_ORGANIZATION"] = true
+		flags["GHMPKG_SOURCE_TOKEN"] = true
+	}
+	return flags
+}
+
 func init() {
 	//syncCmd.Flags().StringP("target-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional)")
 	syncCmd.Flags().StringP("source-organization", "o", "", "Organization (required)")
 	syncCmd.Flags().StringP("target-organization", "p", "", "Organization (required)")
 	syncCmd.Flags().StringP("target-token", "t", "", "GitHub token (required)")
+	syncCmd.Flags().StringP("source-token", "s", "", "Source GitHub token, used to check the inventory for packages added or removed since export (optional)")
 	syncCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
-	syncCmd.Flags().StringP("repository", "r", "", "Repository to sync (optional, syncs all repositories if not specified)")
+	syncCmd.Flags().StringSliceP("repository", "r", []string{}, "Repositories to sync, can be repeated or comma separated (optional, syncs all repositories if not specified)")
+
+	syncCmd.Flags().Bool("keep-work-files", false, "Keep extracted archives and publish logs in the migration directory after a successful upload")
+	syncCmd.Flags().String("existing-packages", "new-versions", "How to handle packages already in the target organization: new-versions, skip or all")
+	syncCmd.Flags().String("conflict-policy", "fail", "How to handle package names deleted from the target organization: fail or rename")
+	syncCmd.Flags().String("mapping-file", "", "Rename repositories, packages and npm scopes on the target as listed in this YAML or CSV file")
+	syncCmd.Flags().Bool("transfer", false, "Move the packages of the repositories the mapping file maps to other repositories of the same organization, publishing them again from their new repository")
+	syncCmd.Flags().Bool("transfer-delete", false, "With --transfer, delete the npm, Maven, NuGet and RubyGems packages that can only be published again once deleted")
+	syncCmd.Flags().String("rename-suffix", "-migrated", "Suffix appended to package names that cannot be reused when --conflict-policy is rename")
+	syncCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to sync (can be specified multiple times)")
+	syncCmd.Flags().StringSlice("packages", []string{}, "Only sync the packages with these exact names (can be specified multiple times)")
+	syncCmd.Flags().StringSlice("include", []string{}, "Only sync packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	syncCmd.Flags().StringSlice("exclude", []string{}, "Skip packages whose name matches one of these globs (prefix with re: for a regular expression)")
+	syncCmd.Flags().StringSlice("versions", []string{}, "Only sync versions matching these semver constraints, e.g. >=2.0.0, ^1.4 or latest:5")
+	syncCmd.Flags().String("since", "", "Only sync versions created on or after this date (2023-01-01) or RFC 3339 timestamp")
+	syncCmd.Flags().String("snapshot", "", "Sync the export labeled with this snapshot name instead of the most recent one")
+	syncCmd.Flags().String("store", "csv", "Read the inventory from, and record the status of every file in, the SQLite database of the migration path: csv or sqlite")
+	syncCmd.Flags().Bool("require-snapshot", false, "Refuse to run without --snapshot, so everyone operates on the same frozen export")
+	syncCmd.Flags().String("shard", "", "Only sync the share of the packages dealt to this shard of a migration split into runs side by side, e.g. 2/4")
+	syncCmd.Flags().Int("shard-split-tags", common.DefaultShardSplitTags, "Deal the versions of container packages with more tags than this to the shards one digest at a time, 0 to never split a package")
+	syncCmd.Flags().StringSlice("critical", []string{}, "Packages to sync before every other one, names or globs optionally prefixed with a package type, e.g. npm/web-*")
+	syncCmd.Flags().String("critical-file", "", "File listing more critical packages, one per line")
+	syncCmd.Flags().Bool("verify-critical", false, "Read the files of critical packages back from the target even when --verify-uploads is off")
+	syncCmd.Flags().String("report-json", "", "Write the report with the result of every package, version and file as JSON to this path")
+	syncCmd.Flags().String("max-failed-files", "", "Fail the run when more files than this failed, a number such as 10 or a share such as 1%")
+	syncCmd.Flags().String("max-failed-packages", "", "Fail the run when more packages than this failed, a number such as 10 or a share such as 1%")
+	syncCmd.Flags().Bool("fail-on-critical", false, "Fail the run when a package of --critical or --critical-file failed")
+	syncCmd.Flags().String("retry-failed", "", "Only process the entries that failed in this --report-json report of a previous sync")
+	syncCmd.Flags().String("max-inventory-age", "7d", "Warn when the export CSVs are older than this (e.g. 12h or 7d, 0 disables the check)")
+	syncCmd.Flags().Bool("strict", false, "Fail instead of warning when the inventory is older than --max-inventory-age or the source organization changed since export")
+	syncCmd.Flags().Bool("verify-uploads", true, "Read every uploaded file back from the target and fail it when its digest differs from the upload")
+	syncCmd.Flags().Bool("rollback-partial", false, "Delete a version from the target when it fails after some of its files were uploaded, so that it is synced again as a whole")
+	syncCmd.Flags().Bool("smoke-test", false, "Once synced, resolve a sample of the synced versions from the target with mvn, npm, gem or docker, as their consumers would")
+	syncCmd.Flags().Int("smoke-test-sample", 1, "Number of synced versions of each package type resolved by --smoke-test")
+	syncCmd.Flags().Bool("warmup", false, "Pace the first uploads into a brand new target organization, ramping up to --concurrency")
+	syncCmd.Flags().Int("warmup-operations", 200, "Number of versions the warm-up ramps up over")
+	syncCmd.Flags().String("warmup-interval", "2s", "Time between two uploads at the start of the warm-up, shrinking to none as it ramps up")
+	syncCmd.Flags().Bool("stream", false, "Copy files straight from the source organization to the target without storing them in the migration directory (maven and container only)")
+	syncCmd.Flags().String("verify-checksums", "fail", "With --stream, how to treat downloads that do not match the checksum recorded at export: fail, warn or off")
+	syncCmd.Flags().String("staging-names", "normalized", "How the npm and container image tarballs of versions pulled without a staging.json are named: normalized or original")
+	syncCmd.Flags().String("source-container-registry", "", "Registry the container images were pulled from, read when streaming with --stream (default: ghcr.io, or containers.<source hostname>)")
+	syncCmd.Flags().String("source-container-registry-user", "", "User of the source container registry (default: the source organization)")
+	syncCmd.Flags().String("source-container-registry-password", "", "Password or token of the source container registry (default: the source token)")
+	syncCmd.Flags().String("target-container-registry", "", "Registry to push container images to, e.g. docker.io/acme (default: ghcr.io, or containers.<target hostname> on GitHub Enterprise Server)")
+	syncCmd.Flags().String("target-container-registry-user", "", "User of the target container registry (default: the target organization)")
+	syncCmd.Flags().String("target-container-registry-password", "", "Password or token of the target container registry (default: the target token)")
+	syncCmd.Flags().String("container-storage-limit", "", "Wait before loading more images while images take more than this in the Docker daemon, e.g. 50GB")
+	syncCmd.Flags().Bool("keep-images", false, "Keep loaded and pushed images in the Docker daemon instead of removing them")
+	syncCmd.Flags().Bool("copy-referrers", false, "Also push the cosign signatures, attestations and OCI referrers attached to container images, streamed or pulled with --copy-referrers")
+	syncCmd.Flags().String("target-registry", "github", "Where to publish the packages: github, artifactory, nexus, azure or codeartifact")
+	syncCmd.Flags().String("artifactory-url", "", "JFrog Artifactory base URL, e.g. https://acme.jfrog.io (with --target-registry artifactory)")
+	syncCmd.Flags().StringSlice("artifactory-repos", []string{}, "Artifactory repository key of each package type, e.g. maven=libs-release-local,npm=npm-local,nuget=nuget-local,container=docker-local")
+	syncCmd.Flags().String("artifactory-user", "", "Artifactory user the API key belongs to, required for container images")
+	syncCmd.Flags().String("artifactory-api-key", "", "Artifactory API key")
+	syncCmd.Flags().String("artifactory-docker-registry", "", "Docker registry host of Artifactory when it differs from the --artifactory-url host")
+	syncCmd.Flags().String("nexus-url", "", "Sonatype Nexus Repository 3 base URL, e.g. https://nexus.example.com (with --target-registry nexus)")
+	syncCmd.Flags().StringSlice("nexus-repos", []string{}, "Nexus hosted repository of each package type, e.g. maven=maven-releases,npm=npm-hosted,nuget=nuget-hosted")
+	syncCmd.Flags().String("nexus-user", "", "Nexus user allowed to deploy to the repositories")
+	syncCmd.Flags().String("nexus-password", "", "Password or user token of the Nexus user")
+	syncCmd.Flags().String("azure-url", "", "Packaging URL of Azure DevOps Server, https://pkgs.dev.azure.com when empty")
+	syncCmd.Flags().String("azure-organization", "", "Azure DevOps organization of the Azure Artifacts feed (with --target-registry azure)")
+	syncCmd.Flags().String("azure-project", "", "Azure DevOps project of the feed, empty for an organization scoped feed")
+	syncCmd.Flags().String("azure-feed", "", "Azure Artifacts feed the packages are published to")
+	syncCmd.Flags().String("azure-token", "", "Azure DevOps personal access token with the Packaging (Read & write) scope")
+	syncCmd.Flags().String("codeartifact-domain", "", "AWS CodeArtifact domain of the repository (with --target-registry codeartifact)")
+	syncCmd.Flags().String("codeartifact-domain-owner", "", "AWS account ID owning the CodeArtifact domain, when it is not the account of the credentials")
+	syncCmd.Flags().String("codeartifact-repository", "", "AWS CodeArtifact repository the packages are published to")
+	syncCmd.Flags().String("codeartifact-role-arn", "", "IAM role to assume with STS before requesting CodeArtifact authorization tokens")
+	syncCmd.Flags().Bool("resume", false, "Resume an interrupted sync, skipping files recorded as completed in the state file")
 
 	//viper.BindPFlag("GHMPKG_TARGET_HOSTNAME", syncCmd.Flags().Lookup("target-hostname"))
 	viper.BindPFlag("GHMPKG_SOURCE_ORGANIZATION", syncCmd.Flags().Lookup("source-organization"))
 	viper.BindPFlag("GHMPKG_TARGET_ORGANIZATION", syncCmd.Flags().Lookup("target-organization"))
 	viper.BindPFlag("GHMPKG_TARGET_TOKEN", syncCmd.Flags().Lookup("target-token"))
 	viper.BindPFlag("GHMPKG_MIGRATION_PATH", syncCmd.Flags().Lookup("migration-path"))
-	viper.BindPFlag("GHMPKG_REPOSITORY", syncCmd.Flags().Lookup("repository"))
 }
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/pkg/verify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Compares packages in the source and target organizations",
+	Long:  "Compares packages in the source and target organizations and writes every missing package, version or file to a CSV file",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_PACKAGE_TYPES":                      "package-types",
+			"GHMPKG_MIGRATION_PATH":                     "migration-path",
+			"GHMPKG_SNAPSHOT":                           "snapshot",
+			"GHMPKG_REQUIRE_SNAPSHOT":                   "require-snapshot",
+			"GHMPKG_VERIFY_SAMPLE":                      "verify-sample",
+			"GHMPKG_MAPPING_FILE":                       "mapping-file",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY":          "target-container-registry",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY_USER":     "target-container-registry-user",
+			"GHMPKG_TARGET_CONTAINER_REGISTRY_PASSWORD": "target-container-registry-password",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMPKG_SOURCE_ORGANIZATION": true,
+			"GHMPKG_SOURCE_TOKEN":        true,
+			"GHMPKG_TARGET_ORGANIZATION": true,
+			"GHMPKG_TARGET_TOKEN":        true,
+		})
+
+		logger := zap.L()
+		ShowConnectionStatus("verify")
+		exitOnError("verify packages", verify.Verify(logger))
+	},
+}
+
+func init() {
+	verifyCmd.Flags().StringP("source-organization", "o", "", "Source Organization (required)")
+	verifyCmd.Flags().StringP("source-token", "s", "", "Source GitHub token (required)")
+	verifyCmd.Flags().StringP("target-organization", "p", "", "Target Organization (required)")
+	verifyCmd.Flags().StringP("target-token", "t", "", "Target GitHub token (required)")
+	verifyCmd.Flags().StringSliceP("package-types", "k", []string{}, "Package type(s) to verify (can be specified multiple times)")
+	verifyCmd.Flags().String("verify-sample", "", "Download this share of the synced files of each package type from the target and compare digests, e.g. 5% (skips the full listing comparison)")
+	verifyCmd.Flags().String("mapping-file", "", "Mapping file the packages were synced with, to compare them under their target names")
+	verifyCmd.Flags().StringP("migration-path", "m", "./migration-packages", "Path to the migration directory (default: ./migration-packages)")
+	verifyCmd.Flags().String("snapshot", "", "Only compare the packages and versions of the export labeled with this snapshot name")
+	verifyCmd.Flags().Bool("require-snapshot", false, "Refuse to run without --snapshot, so everyone operates on the same frozen export")
+	verifyCmd.Flags().String("target-container-registry", "", "Registry the container images were pushed to, read by --verify-sample (default: ghcr.io, or containers.<target hostname> on GitHub Enterprise Server)")
+	verifyCmd.Flags().String("target-container-registry-user", "", "User of the target container registry (default: the target organization)")
+	verifyCmd.Flags().String("target-container-registry-password", "", "Password or token of the target container registry (default: the target token)")
+
+	viper.BindPFlag("GHMPKG_SOURCE_ORGANIZATION", verifyCmd.Flags().Lookup("source-organization"))
+	viper.BindPFlag("GHMPKG_SOURCE_TOKEN", verifyCmd.Flags().Lookup("source-token"))
+	viper.BindPFlag("GHMPKG_TARGET_ORGANIZATION", verifyCmd.Flags().Lookup("target-organization"))
+	viper.BindPFlag("GHMPKG_TARGET_TOKEN", verifyCmd.Flags().Lookup("target-token"))
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mona-actions/gh-migrate-packages/pkg/version"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Prints the version, commit, build date and Go version of the tool",
+	Long:  "Prints the version, commit, build date and Go version of the tool, and with --check whether a newer release of the extension is available",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd, map[string]string{
+			"GHMPKG_CHECK_UPDATE": "check",
+		})
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := zap.L()
+		if err := version.Version(logger); err != nil {
+			fmt.Printf("failed to check for updates: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	versionCmd.Flags().Bool("check", false, "Check the releases of the extension for a newer version")
+}
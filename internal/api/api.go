@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
 )
@@ -68,33 +69,32 @@ func newGitHubClientWithProxy(token string, proxyConfig *ProxyConfig) (*github.C
 		&oauth2.Token{AccessToken: token},
 	)
 
-	transport := &http.Transport{
-		Proxy: func(req *http.Request) (*url.URL, error) {
-			if proxyConfig != nil && proxyConfig.NoProxy != "" {
-				noProxyURLs := strings.Split(proxyConfig.NoProxy, ",")
-				reqHost := req.URL.Host
-				for _, noProxy := range noProxyURLs {
-					if strings.TrimSpace(noProxy) == reqHost {
-						return nil, nil
-					}
+	transport := utils.NewTransport()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxyConfig != nil && proxyConfig.NoProxy != "" {
+			noProxyURLs := strings.Split(proxyConfig.NoProxy, ",")
+			reqHost := req.URL.Host
+			for _, noProxy := range noProxyURLs {
+				if strings.TrimSpace(noProxy) == reqHost {
+					return nil, nil
 				}
 			}
+		}
 
-			if proxyConfig != nil {
-				if req.URL.Scheme == "https" && proxyConfig.HTTPSProxy != "" {
-					return url.Parse(proxyConfig.HTTPSProxy)
-				}
-				if req.URL.Scheme == "http" && proxyConfig.HTTPProxy != "" {
-					return url.Parse(proxyConfig.HTTPProxy)
-				}
+		if proxyConfig != nil {
+			if req.URL.Scheme == "https" && proxyConfig.HTTPSProxy != "" {
+				return url.Parse(proxyConfig.HTTPSProxy)
 			}
-			return nil, nil
-		},
+			if req.URL.Scheme == "http" && proxyConfig.HTTPProxy != "" {
+				return url.Parse(proxyConfig.HTTPProxy)
+			}
+		}
+		return nil, nil
 	}
 
 	tc := oauth2.NewClient(ctx, ts)
 	tc.Transport = &oauth2.Transport{
-		Base:   transport,
+		Base:   utils.GuardTransport(transport),
 		Source: ts,
 	}
 
@@ -137,8 +137,24 @@ func retryOperation(operation func() error) error {
 }
 
 func FetchPackages(packageType string) ([]*github.Package, error) {
-	client, err := newGitHubClientWithHostname(viper.GetString("GHMPKG_SOURCE_TOKEN"), "")
+	return fetchPackages(utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", packageType), viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), packageType)
+}
+
+// FetchTargetPackages lists the active packages of the given type in the target organization
+func FetchTargetPackages(packageType string) ([]*github.Package, error) {
+	return fetchPackages(utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", packageType), viper.GetString("GHMPKG_TARGET_ORGANIZATION"), packageType)
+}
+
+func fetchPackages(token, org, packageType string) ([]*github.Package, error) {
+	client, err := newGitHubClientWithHostname(token, "")
+	if err != nil {
+		return nil, err
+	}
 	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	namespace, err := resolveOwner(ctx, client, org)
+	if err != nil {
+		return nil, err
+	}
 	state := "active"
 	var packages []*github.Package
 	var page int
@@ -148,11 +164,18 @@ func FetchPackages(packageType string) ([]*github.Package, error) {
 		page = 1
 
 		for {
-			packagesPage, response, err := client.Organizations.ListPackages(ctx, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), &github.PackageListOptions{
+			opts := &github.PackageListOptions{
 				PackageType: &packageType,
 				State:       &state,
 				ListOptions: github.ListOptions{PerPage: 100, Page: page},
-			})
+			}
+			var packagesPage []*github.Package
+			var response *github.Response
+			if namespace.isUser {
+				packagesPage, response, err = client.Users.ListPackages(ctx, namespace.user, opts)
+			} else {
+				packagesPage, response, err = client.Organizations.ListPackages(ctx, org, opts)
+			}
 
 			if err != nil {
 				return err
@@ -178,8 +201,24 @@ func FetchPackages(packageType string) ([]*github.Package, error) {
 }
 
 func FetchPackageVersions(pkg *github.Package) ([]*github.PackageVersion, error) {
-	client, err := newGitHubClientWithHostname(viper.GetString("GHMPKG_SOURCE_TOKEN"), getHostname(""))
+	return fetchPackageVersions(utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", pkg.GetPackageType()), viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), pkg)
+}
+
+// FetchTargetPackageVersions lists the active versions of a package in the target organization
+func FetchTargetPackageVersions(pkg *github.Package) ([]*github.PackageVersion, error) {
+	return fetchPackageVersions(utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", pkg.GetPackageType()), viper.GetString("GHMPKG_TARGET_ORGANIZATION"), pkg)
+}
+
+func fetchPackageVersions(token, org string, pkg *github.Package) ([]*github.PackageVersion, error) {
+	client, err := newGitHubClientWithHostname(token, getHostname(""))
+	if err != nil {
+		return nil, err
+	}
 	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	namespace, err := resolveOwner(ctx, client, org)
+	if err != nil {
+		return nil, err
+	}
 	state := "active"
 	var versions []*github.PackageVersion
 	var page int
@@ -189,11 +228,18 @@ func FetchPackageVersions(pkg *github.Package) ([]*github.PackageVersion, error)
 		page = 1
 
 		for {
-			versionsPage, response, err := client.Organizations.PackageGetAllVersions(ctx, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), *pkg.PackageType, *pkg.Name, &github.PackageListOptions{
+			opts := &github.PackageListOptions{
 				PackageType: pkg.PackageType,
 				State:       &state,
 				ListOptions: github.ListOptions{PerPage: 100, Page: page},
-			})
+			}
+			var versionsPage []*github.PackageVersion
+			var response *github.Response
+			if namespace.isUser {
+				versionsPage, response, err = client.Users.PackageGetAllVersions(ctx, namespace.user, *pkg.PackageType, *pkg.Name, opts)
+			} else {
+				versionsPage, response, err = client.Organizations.PackageGetAllVersions(ctx, org, *pkg.PackageType, *pkg.Name, opts)
+			}
 
 			if err != nil {
 				return err
@@ -219,12 +265,19 @@ func FetchPackageVersions(pkg *github.Package) ([]*github.PackageVersion, error)
 }
 
 func PackageExists(packageName, packageType string) (bool, error) {
-	client, err := newGitHubClientWithHostname(viper.GetString("GHMPKG_TARGET_TOKEN"), "")
+	client, err := newGitHubClientWithHostname(utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", packageType), "")
+	if err != nil {
+		return false, err
+	}
 	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	namespace, err := resolveOwner(ctx, client, viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
+	if err != nil {
+		return false, err
+	}
 
 	var exists = true
 	err = retryOperation(func() error {
-		_, response, err := client.Organizations.GetPackage(ctx, viper.GetString("GHMPKG_TARGET_ORGANIZATION"), packageType, packageName)
+		_, response, err := namespace.getPackage(ctx, client, packageType, packageName)
 
 		if response.StatusCode != http.StatusOK {
 			exists = false
@@ -242,3 +295,189 @@ func PackageExists(packageName, packageType string) (bool, error) {
 
 	return true, nil
 }
+
+// EnterpriseApiUrl returns the REST API url of a GitHub Enterprise Server
+// hostname, or an empty string for GitHub.com
+func EnterpriseApiUrl(hostname string) string {
+	hostname = strings.TrimPrefix(hostname, "http://")
+	hostname = strings.TrimPrefix(hostname, "https://")
+	hostname = strings.TrimSuffix(hostname, "/")
+	hostname = strings.TrimSuffix(hostname, "/api/v3")
+	if hostname == "" || hostname == "github.com" || hostname == "api.github.com" {
+		return ""
+	}
+	return fmt.Sprintf("https://%s/api/v3/", hostname)
+}
+
+// FetchServerVersion returns the installed version of a GitHub Enterprise Server,
+// it is empty for GitHub.com
+func FetchServerVersion(token, hostname string) (string, error) {
+	apiUrl := EnterpriseApiUrl(hostname)
+	if apiUrl == "" {
+		return "", nil
+	}
+	client, err := newGitHubClientWithHostname(token, apiUrl)
+	if err != nil {
+		return "", err
+	}
+
+	var meta struct {
+		InstalledVersion string `json:"installed_version"`
+	}
+	var response *github.Response
+	err = retryOperation(func() error {
+		req, err := client.NewRequest(http.MethodGet, "meta", nil)
+		if err != nil {
+			return err
+		}
+		response, err = client.Do(context.Background(), req, &meta)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get server version of %s: %w", hostname, err)
+	}
+
+	if meta.InstalledVersion == "" && response != nil {
+		meta.InstalledVersion = response.Header.Get("X-GitHub-Enterprise-Version")
+	}
+	return meta.InstalledVersion, nil
+}
+
+// FetchLatestRelease returns the latest release of a public repository on
+// GitHub.com, owner/name. No token is needed.
+func FetchLatestRelease(repository string) (*github.RepositoryRelease, error) {
+	owner, name, ok := strings.Cut(repository, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid repository %q, expected owner/name", repository)
+	}
+	client := github.NewClient(utils.NewHTTPClient())
+
+	var release *github.RepositoryRelease
+	err := retryOperation(func() error {
+		var err error
+		release, _, err = client.Repositories.GetLatestRelease(context.Background(), owner, name)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the latest release of %s: %w", repository, err)
+	}
+	return release, nil
+}
+
+// FetchRepositoryTeams lists the teams with access to a repository of the source
+// organization, with the permission each one has
+func FetchRepositoryTeams(repository string) ([]*github.Team, error) {
+	client, err := newGitHubClientWithHostname(viper.GetString("GHMPKG_SOURCE_TOKEN"), "")
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	var teams []*github.Team
+
+	err = retryOperation(func() error {
+		teams = nil
+		opts := &github.ListOptions{PerPage: 100}
+		for {
+			teamsPage, response, err := client.Repositories.ListTeams(ctx, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, opts)
+			if err != nil {
+				return err
+			}
+			teams = append(teams, teamsPage...)
+			if response.NextPage == 0 {
+				return nil
+			}
+			opts.Page = response.NextPage
+		}
+	})
+
+	return teams, err
+}
+
+// FetchTargetPackage returns a package of the target organization, nil when it does not exist
+func FetchTargetPackage(packageType, packageName string) (*github.Package, error) {
+	client, err := newGitHubClientWithHostname(utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", packageType), "")
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	var pkg *github.Package
+
+	namespace, err := resolveOwner(ctx, client, viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = retryOperation(func() error {
+		found, response, err := namespace.getPackage(ctx, client, packageType, packageName)
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		pkg = found
+		return err
+	})
+
+	return pkg, err
+}
+
+// DeleteTargetPackageVersion deletes a version of a package of the target
+// organization. GitHub does not delete the last version of a package, the
+// package is deleted instead when the version is its only one.
+func DeleteTargetPackageVersion(packageType, packageName string, versionID int64, lastVersion bool) error {
+	client, err := newGitHubClientWithHostname(utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", packageType), "")
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+
+	namespace, err := resolveOwner(ctx, client, viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
+	if err != nil {
+		return err
+	}
+
+	return retryOperation(func() error {
+		var response *github.Response
+		var err error
+		if lastVersion {
+			response, err = namespace.deletePackage(ctx, client, packageType, packageName)
+		} else {
+			response, err = namespace.deletePackageVersion(ctx, client, packageType, packageName, versionID)
+		}
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			// Already gone
+			return nil
+		}
+		return err
+	})
+}
+
+// DeleteTargetPackage deletes a package of the target organization with all
+// its versions
+func DeleteTargetPackage(packageType, packageName string) error {
+	return DeleteTargetPackageVersion(packageType, packageName, 0, true)
+}
+
+// GrantTargetTeamRepository gives a team of the target organization a permission
+// on one of its repositories. The team must already exist on the target.
+func GrantTargetTeamRepository(teamSlug, repository, permission string) error {
+	client, err := newGitHubClientWithHostname(viper.GetString("GHMPKG_TARGET_TOKEN"), "")
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	targetOwner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+
+	notFound := false
+	err = retryOperation(func() error {
+		response, err := client.Teams.AddTeamRepoBySlug(ctx, targetOwner, teamSlug, targetOwner, repository, &github.TeamAddTeamRepoOptions{Permission: permission})
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			// Retrying does not create the team or repository
+			notFound = true
+			return nil
+		}
+		return err
+	})
+	if notFound {
+		return fmt.Errorf("team %s or repository %s not found in %s", teamSlug, repository, targetOwner)
+	}
+	return err
+}
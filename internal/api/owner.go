@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/spf13/viper"
+)
+
+// owner is the namespace packages are listed in, an organization or a user account
+type owner struct {
+	name   string
+	isUser bool
+	// user is the login passed to the users API, empty for the authenticated
+	// user as only that endpoint lists their private packages
+	user string
+}
+
+// owners caches the resolved owners by host and name, the source and target
+// hosts may have accounts of the same name
+var owners sync.Map
+
+// ownerKey is the cache key of an owner on the host of a client
+func ownerKey(client *github.Client, name string) string {
+	return strings.ToLower(client.BaseURL.Host + "/" + name)
+}
+
+// IsUser reports whether an owner is a user account rather than an organization.
+// The source owner is one when GHMPKG_USER is set, otherwise the account type is
+// looked up.
+func IsUser(token, name string) (bool, error) {
+	client, err := newGitHubClientWithHostname(token, "")
+	if err != nil {
+		return false, err
+	}
+	resolved, err := resolveOwner(context.Background(), client, name)
+	if err != nil {
+		return false, err
+	}
+	return resolved.isUser, nil
+}
+
+func resolveOwner(ctx context.Context, client *github.Client, name string) (*owner, error) {
+	key := ownerKey(client, name)
+	if cached, ok := owners.Load(key); ok {
+		return cached.(*owner), nil
+	}
+
+	resolved := &owner{name: name, user: name}
+	if viper.GetBool("GHMPKG_USER") && strings.EqualFold(name, viper.GetString("GHMPKG_SOURCE_ORGANIZATION")) {
+		resolved.isUser = true
+	} else {
+		var account *github.User
+		err := retryOperation(func() error {
+			var err error
+			account, _, err = client.Users.Get(ctx, name)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up account %s: %w", name, err)
+		}
+		resolved.isUser = account.GetType() == "User"
+	}
+
+	if resolved.isUser {
+		// Another user's namespace only lists their public packages
+		if viewer, _, err := client.Users.Get(ctx, ""); err == nil && strings.EqualFold(viewer.GetLogin(), name) {
+			resolved.user = ""
+		}
+	}
+	owners.Store(key, resolved)
+	return resolved, nil
+}
+
+func (o *owner) getPackage(ctx context.Context, client *github.Client, packageType, packageName string) (*github.Package, *github.Response, error) {
+	if o.isUser {
+		return client.Users.GetPackage(ctx, o.user, packageType, packageName)
+	}
+	return client.Organizations.GetPackage(ctx, o.name, packageType, packageName)
+}
+
+func (o *owner) deletePackage(ctx context.Context, client *github.Client, packageType, packageName string) (*github.Response, error) {
+	if o.isUser {
+		return client.Users.DeletePackage(ctx, o.user, packageType, packageName)
+	}
+	return client.Organizations.DeletePackage(ctx, o.name, packageType, packageName)
+}
+
+func (o *owner) deletePackageVersion(ctx context.Context, client *github.Client, packageType, packageName string, versionID int64) (*github.Response, error) {
+	if o.isUser {
+		return client.Users.PackageDeleteVersion(ctx, o.user, packageType, packageName, versionID)
+	}
+	return client.Organizations.PackageDeleteVersion(ctx, o.name, packageType, packageName, versionID)
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/spf13/viper"
+)
+
+// FetchSourceRepositories lists the repositories of the source organization or
+// user account
+func FetchSourceRepositories() ([]*github.Repository, error) {
+	return fetchRepositories(viper.GetString("GHMPKG_SOURCE_TOKEN"), viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
+}
+
+// FetchTargetRepositories lists the repositories of the target organization
+func FetchTargetRepositories() ([]*github.Repository, error) {
+	return fetchRepositories(viper.GetString("GHMPKG_TARGET_TOKEN"), viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
+}
+
+func fetchRepositories(token, org string) ([]*github.Repository, error) {
+	client, err := newGitHubClientWithHostname(token, "")
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	namespace, err := resolveOwner(ctx, client, org)
+	if err != nil {
+		return nil, err
+	}
+	var repositories []*github.Repository
+
+	err = retryOperation(func() error {
+		repositories = nil
+		listOptions := github.ListOptions{PerPage: 100}
+		for {
+			var repositoriesPage []*github.Repository
+			var response *github.Response
+			var err error
+			if namespace.isUser {
+				repositoriesPage, response, err = client.Repositories.ListByUser(ctx, org, &github.RepositoryListByUserOptions{ListOptions: listOptions})
+			} else {
+				repositoriesPage, response, err = client.Repositories.ListByOrg(ctx, org, &github.RepositoryListByOrgOptions{ListOptions: listOptions})
+			}
+			if err != nil {
+				return err
+			}
+			repositories = append(repositories, repositoriesPage...)
+			if response.NextPage == 0 {
+				return nil
+			}
+			listOptions.Page = response.NextPage
+		}
+	})
+
+	return repositories, err
+}
+
+// FetchSourceTree lists every file of a branch of a source repository, nil for
+// an empty repository. The tree is truncated by the API past 100,000 entries,
+// which is reported.
+func FetchSourceTree(repository, branch string) ([]*github.TreeEntry, bool, error) {
+	return fetchTree(viper.GetString("GHMPKG_SOURCE_TOKEN"), viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, branch)
+}
+
+// FetchTargetTree lists every file of a branch of a target repository, like FetchSourceTree
+func FetchTargetTree(repository, branch string) ([]*github.TreeEntry, bool, error) {
+	return fetchTree(viper.GetString("GHMPKG_TARGET_TOKEN"), viper.GetString("GHMPKG_TARGET_ORGANIZATION"), repository, branch)
+}
+
+func fetchTree(token, org, repository, branch string) ([]*github.TreeEntry, bool, error) {
+	client, err := newGitHubClientWithHostname(token, "")
+	if err != nil {
+		return nil, false, err
+	}
+	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	var tree *github.Tree
+
+	err = retryOperation(func() error {
+		found, response, err := client.Git.GetTree(ctx, org, repository, branch, true)
+		if response != nil && (response.StatusCode == http.StatusConflict || response.StatusCode == http.StatusNotFound) {
+			// Empty repositories have no tree
+			return nil
+		}
+		tree = found
+		return err
+	})
+	if err != nil || tree == nil {
+		return nil, false, err
+	}
+
+	return tree.Entries, tree.GetTruncated(), nil
+}
+
+// FetchSourceBlob returns the content of a file of a source repository by its blob SHA
+func FetchSourceBlob(repository, sha string) ([]byte, error) {
+	return fetchBlob(viper.GetString("GHMPKG_SOURCE_TOKEN"), viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, sha)
+}
+
+// FetchTargetBlob returns the content of a file of a target repository by its blob SHA
+func FetchTargetBlob(repository, sha string) ([]byte, error) {
+	return fetchBlob(viper.GetString("GHMPKG_TARGET_TOKEN"), viper.GetString("GHMPKG_TARGET_ORGANIZATION"), repository, sha)
+}
+
+func fetchBlob(token, org, repository, sha string) ([]byte, error) {
+	client, err := newGitHubClientWithHostname(token, "")
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	var content []byte
+
+	err = retryOperation(func() error {
+		content, _, err = client.Git.GetBlobRaw(ctx, org, repository, sha)
+		return err
+	})
+
+	return content, err
+}
+
+// CreateTargetPullRequest commits the files to a branch of a target repository,
+// created from the base branch, and opens a pull request of it. A branch left by
+// a previous run is reset to the new commit and its open pull request reused.
+// Returns the URL of the pull request.
+func CreateTargetPullRequest(repository, base, branch, title, body string, files []*github.TreeEntry) (string, error) {
+	client, err := newGitHubClientWithHostname(viper.GetString("GHMPKG_TARGET_TOKEN"), "")
+	if err != nil {
+		return "", err
+	}
+	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	targetOwner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+
+	var baseCommit *github.Commit
+	err = retryOperation(func() error {
+		ref, _, err := client.Git.GetRef(ctx, targetOwner, repository, "heads/"+base)
+		if err != nil {
+			return err
+		}
+		baseCommit, _, err = client.Git.GetCommit(ctx, targetOwner, repository, ref.GetObject().GetSHA())
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read branch %s of %s: %w", base, repository, err)
+	}
+
+	var commit *github.Commit
+	err = retryOperation(func() error {
+		tree, _, err := client.Git.CreateTree(ctx, targetOwner, repository, baseCommit.GetTree().GetSHA(), files)
+		if err != nil {
+			return err
+		}
+		commit, _, err = client.Git.CreateCommit(ctx, targetOwner, repository, &github.Commit{
+			Message: github.String(title),
+			Tree:    &github.Tree{SHA: tree.SHA},
+			Parents: []*github.Commit{{SHA: baseCommit.SHA}},
+		}, nil)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit to %s: %w", repository, err)
+	}
+
+	err = retryOperation(func() error {
+		ref := &github.Reference{Ref: github.String("refs/heads/" + branch), Object: &github.GitObject{SHA: commit.SHA}}
+		_, response, err := client.Git.CreateRef(ctx, targetOwner, repository, ref)
+		if response != nil && response.StatusCode == http.StatusUnprocessableEntity {
+			// The branch exists, from a previous run
+			_, _, err = client.Git.UpdateRef(ctx, targetOwner, repository, ref, true)
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create branch %s of %s: %w", branch, repository, err)
+	}
+
+	var pullRequest *github.PullRequest
+	err = retryOperation(func() error {
+		open, _, err := client.PullRequests.List(ctx, targetOwner, repository, &github.PullRequestListOptions{
+			State: "open",
+			Head:  targetOwner + ":" + branch,
+			Base:  base,
+		})
+		if err != nil {
+			return err
+		}
+		if len(open) > 0 {
+			pullRequest = open[0]
+			return nil
+		}
+		pullRequest, _, err = client.PullRequests.Create(ctx, targetOwner, repository, &github.NewPullRequest{
+			Title: github.String(title),
+			Head:  github.String(branch),
+			Base:  github.String(base),
+			Body:  github.String(body),
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to open a pull request on %s: %w", repository, err)
+	}
+
+	return pullRequest.GetHTMLURL(), nil
+}
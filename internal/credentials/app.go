@@ -0,0 +1,126 @@
+package credentials
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/spf13/viper"
+)
+
+// appProvider mints an installation token of a GitHub App for each side, from
+// GHMPKG_<SIDE>_APP_ID, GHMPKG_<SIDE>_APP_PRIVATE_KEY and optionally
+// GHMPKG_<SIDE>_APP_INSTALLATION_ID (the installation on the organization otherwise)
+type appProvider struct{}
+
+func (appProvider) Token(side string) (string, error) {
+	appID := viper.GetString(key(side, "APP_ID"))
+	if appID == "" {
+		return "", fmt.Errorf("%s is not set", key(side, "APP_ID"))
+	}
+	privateKey, err := readPrivateKey(viper.GetString(key(side, "APP_PRIVATE_KEY")))
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", key(side, "APP_PRIVATE_KEY"), err)
+	}
+	jwt, err := appJWT(appID, privateKey, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	client := github.NewClient(httpClient()).WithAuthToken(jwt)
+	if host := hostname(side); host != "github.com" {
+		baseUrl := fmt.Sprintf("https://%s/api/v3/", host)
+		if client, err = client.WithEnterpriseURLs(baseUrl, baseUrl); err != nil {
+			return "", err
+		}
+	}
+	ctx := context.Background()
+
+	installationID := viper.GetInt64(key(side, "APP_INSTALLATION_ID"))
+	if installationID == 0 {
+		organization := viper.GetString(key(side, "ORGANIZATION"))
+		installation, _, err := client.Apps.FindOrganizationInstallation(ctx, organization)
+		if err != nil {
+			return "", fmt.Errorf("failed to find the installation of app %s on %s: %w", appID, organization, err)
+		}
+		installationID = installation.GetID()
+	}
+	token, _, err := client.Apps.CreateInstallationToken(ctx, installationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create an installation token: %w", err)
+	}
+	return token.GetToken(), nil
+}
+
+// readPrivateKey parses a PEM encoded RSA key, given inline or as a path to a file
+func readPrivateKey(value string) (*rsa.PrivateKey, error) {
+	if value == "" {
+		return nil, fmt.Errorf("no private key")
+	}
+	content := []byte(value)
+	if !strings.Contains(value, "-----BEGIN") {
+		var err error
+		if content, err = os.ReadFile(value); err != nil {
+			return nil, err
+		}
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key")
+	}
+	return key, nil
+}
+
+// appJWT signs the JSON web token a GitHub App authenticates with. It is issued
+// a minute in the past to allow for clock drift and expires after 10 minutes.
+func appJWT(appID string, key *rsa.PrivateKey, now time.Time) (string, error) {
+	encode := func(v interface{}) (string, error) {
+		content, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(content), nil
+	}
+	header, err := encode(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := encode(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + claims
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
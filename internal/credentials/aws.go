@@ -0,0 +1,232 @@
+package credentials
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsProvider reads the token of each side from AWS Secrets Manager,
+// GHMPKG_<SIDE>_TOKEN_SECRET=secret-id, or secret-id#key for a JSON secret.
+// Credentials come from the standard AWS_* environment variables.
+type awsProvider struct{}
+
+func (awsProvider) Token(side string) (string, error) {
+	secretID, field, err := secretReference(side, "")
+	if err != nil {
+		return "", err
+	}
+	credentials, region, err := AWSFromEnv()
+	if err != nil {
+		return "", err
+	}
+	endpoint := fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", region)
+	return readAWSSecret(endpoint, region, credentials, secretID, field)
+}
+
+// AWSCredentials sign requests to AWS services
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSFromEnv reads the AWS credentials and region from the standard AWS_*
+// environment variables
+func AWSFromEnv() (AWSCredentials, string, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return AWSCredentials{}, "", fmt.Errorf("AWS_REGION is not set")
+	}
+	credentials := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return AWSCredentials{}, "", fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+	return credentials, region, nil
+}
+
+// AWSEndpoint returns the endpoint of an AWS service in a region, or the one
+// set in AWS_ENDPOINT_URL_<SERVICE> or AWS_ENDPOINT_URL
+func AWSEndpoint(service, region string) string {
+	for _, name := range []string{"AWS_ENDPOINT_URL_" + strings.ToUpper(service), "AWS_ENDPOINT_URL"} {
+		if endpoint := os.Getenv(name); endpoint != "" {
+			return strings.TrimSuffix(endpoint, "/")
+		}
+	}
+	return fmt.Sprintf("https://%s.%s.amazonaws.com", service, region)
+}
+
+// AssumeRole calls STS AssumeRole and returns the temporary credentials of the role
+func AssumeRole(credentials AWSCredentials, region, roleArn, sessionName string) (AWSCredentials, error) {
+	body := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {roleArn},
+		"RoleSessionName": {sessionName},
+	}.Encode()
+	req, err := http.NewRequest(http.MethodPost, AWSEndpoint("sts", region)+"/", strings.NewReader(body))
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	payloadHash := sha256.Sum256([]byte(body))
+	SignV4(req, hex.EncodeToString(payloadHash[:]), credentials, region, "sts", time.Now())
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return AWSCredentials{}, fmt.Errorf("sts returned %s assuming %s: %s", resp.Status, roleArn, strings.TrimSpace(string(content)))
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyId     string
+			SecretAccessKey string
+			SessionToken    string
+		} `xml:"AssumeRoleResult>Credentials"`
+	}
+	if err := xml.Unmarshal(content, &result); err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to parse the credentials of %s: %w", roleArn, err)
+	}
+	if result.Credentials.AccessKeyId == "" {
+		return AWSCredentials{}, fmt.Errorf("sts returned no credentials for %s", roleArn)
+	}
+	return AWSCredentials{
+		AccessKeyID:     result.Credentials.AccessKeyId,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		SessionToken:    result.Credentials.SessionToken,
+	}, nil
+}
+
+// readAWSSecret calls GetSecretValue and returns the secret string, or one of
+// its keys when it holds JSON
+func readAWSSecret(endpoint, region string, credentials AWSCredentials, secretID, field string) (string, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	payloadHash := sha256.Sum256(body)
+	SignV4(req, hex.EncodeToString(payloadHash[:]), credentials, region, "secretsmanager", time.Now())
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("secrets manager returned %s for %s: %s", resp.Status, secretID, strings.TrimSpace(string(content)))
+	}
+
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(content, &secret); err != nil {
+		return "", fmt.Errorf("failed to parse secret %s: %w", secretID, err)
+	}
+	if field == "" {
+		return strings.TrimSpace(secret.SecretString), nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not JSON, cannot read its %s key: %w", secretID, field, err)
+	}
+	token, ok := fields[field].(string)
+	if !ok || token == "" {
+		return "", fmt.Errorf("secret %s has no %s key", secretID, field)
+	}
+	return token, nil
+}
+
+// SignV4 signs a request with AWS Signature Version 4, covering the host and
+// every header already set on the request. payloadHash is the hex SHA-256 of
+// the body, or UNSIGNED-PAYLOAD where the service allows it.
+func SignV4(req *http.Request, payloadHash string, credentials AWSCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	signingKey := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,117 @@
+package credentials
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// Credential providers selected with GHMPKG_CREDENTIAL_PROVIDER
+const (
+	Env   = "env"
+	GH    = "gh"
+	App   = "app"
+	Vault = "vault"
+	AWS   = "aws"
+)
+
+// PROVIDERS are the values accepted by --credential-provider
+var PROVIDERS = []string{Env, GH, App, Vault, AWS}
+
+// Sides of a migration, each one has its own token
+const (
+	Source = "source"
+	Target = "target"
+)
+
+// Provider acquires the token of the source or target side of a migration
+type Provider interface {
+	Token(side string) (string, error)
+}
+
+// New returns the credential provider with the given name, env when it is empty
+func New(name string) (Provider, error) {
+	switch strings.ToLower(name) {
+	case "", Env:
+		return envProvider{}, nil
+	case GH:
+		return ghProvider{}, nil
+	case App:
+		return appProvider{}, nil
+	case Vault:
+		return vaultProvider{}, nil
+	case AWS:
+		return awsProvider{}, nil
+	}
+	return nil, fmt.Errorf("invalid --credential-provider %q, expected one of: %s", name, strings.Join(PROVIDERS, ", "))
+}
+
+// key returns the viper key of a setting of a side, e.g. GHMPKG_SOURCE_TOKEN
+func key(side, setting string) string {
+	return fmt.Sprintf("GHMPKG_%s_%s", strings.ToUpper(side), setting)
+}
+
+// Resolve sets GHMPKG_SOURCE_TOKEN and GHMPKG_TARGET_TOKEN from the configured
+// credential provider, for the sides a command uses. The env provider, the
+// default, keeps the tokens given with flags and environment variables.
+func Resolve(sides ...string) error {
+	name := viper.GetString("GHMPKG_CREDENTIAL_PROVIDER")
+	provider, err := New(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := provider.(envProvider); ok {
+		return nil
+	}
+	for _, side := range sides {
+		token, err := provider.Token(side)
+		if err != nil {
+			return fmt.Errorf("failed to get the %s token from %s: %w", side, name, err)
+		}
+		viper.Set(key(side, "TOKEN"), token)
+	}
+	return nil
+}
+
+// envProvider reads the tokens from flags, environment variables and the .env file
+type envProvider struct{}
+
+func (envProvider) Token(side string) (string, error) {
+	token := viper.GetString(key(side, "TOKEN"))
+	if token == "" {
+		return "", fmt.Errorf("%s is not set", key(side, "TOKEN"))
+	}
+	return token, nil
+}
+
+// secretReference splits the GHMPKG_<SIDE>_TOKEN_SECRET reference of a side into
+// the secret and the field holding the token, "path#field"
+func secretReference(side, defaultField string) (string, string, error) {
+	reference := viper.GetString(key(side, "TOKEN_SECRET"))
+	if reference == "" {
+		return "", "", fmt.Errorf("%s is not set", key(side, "TOKEN_SECRET"))
+	}
+	secret, field, ok := strings.Cut(reference, "#")
+	if !ok || field == "" {
+		field = defaultField
+	}
+	return secret, field, nil
+}
+
+// hostname is the GitHub hostname of a side, github.com when not set
+func hostname(side string) string {
+	host := viper.GetString(key(side, "HOSTNAME"))
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	host = strings.TrimSuffix(host, "/")
+	host = strings.TrimSuffix(host, "/api/v3")
+	if host == "" {
+		return "github.com"
+	}
+	return host
+}
+
+// httpClient is used to reach the secret stores
+var httpClient = utils.NewHTTPClient
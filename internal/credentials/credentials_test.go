@@ -0,0 +1,169 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	credentials := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	SignV4(req, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", credentials, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nwant %s", got, want)
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRole" || r.Form.Get("RoleArn") != "arn:aws:iam::111122223333:role/migration" ||
+			!strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") || !strings.Contains(r.Header.Get("Authorization"), "/sts/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>
+			<AccessKeyId>ASIATEMP</AccessKeyId><SecretAccessKey>temp-secret</SecretAccessKey><SessionToken>session</SessionToken>
+		</Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL_STS", server.URL)
+
+	got, err := AssumeRole(AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, "us-east-1", "arn:aws:iam::111122223333:role/migration", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (AWSCredentials{AccessKeyID: "ASIATEMP", SecretAccessKey: "temp-secret", SessionToken: "session"}); got != want {
+		t.Errorf("AssumeRole = %+v, want %+v", got, want)
+	}
+	if _, err := AssumeRole(AWSCredentials{AccessKeyID: "OTHER", SecretAccessKey: "secret"}, "us-east-1", "arn:aws:iam::111122223333:role/migration", "test"); err == nil {
+		t.Error("AssumeRole succeeded on a refused request")
+	}
+}
+
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	jwt, err := appJWT("12345", key, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %d", len(parts))
+	}
+	content, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}
+	if err := json.Unmarshal(content, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Iss != "12345" || claims.Iat != now.Unix()-60 || claims.Exp != now.Unix()+540 {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestReadVaultSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/migration":
+			w.Write([]byte(`{"data":{"data":{"source":"ghp_source"},"metadata":{"version":3}}}`))
+		case "/v1/kv/migration":
+			w.Write([]byte(`{"data":{"token":"ghp_v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &vaultClient{address: server.URL, token: "vault-token"}
+	if token, _, err := readVaultSecret(client, "secret/data/migration", "source"); err != nil || token != "ghp_source" {
+		t.Errorf("KV v2 = %q, %v", token, err)
+	}
+	if token, _, err := readVaultSecret(client, "kv/migration", "token"); err != nil || token != "ghp_v1" {
+		t.Errorf("KV v1 = %q, %v", token, err)
+	}
+	if _, _, err := readVaultSecret(client, "kv/migration", "missing"); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+	if _, _, err := readVaultSecret(&vaultClient{address: server.URL, token: "wrong"}, "kv/migration", "token"); err == nil {
+		t.Error("expected an error when vault refuses the token")
+	}
+}
+
+func TestVaultKubernetesLoginAndRenewal(t *testing.T) {
+	var renewed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v1/auth/k8s/login":
+			if body["role"] != "migration" || body["jwt"] != "service-account-jwt" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"auth":{"client_token":"hvs.k8s","lease_duration":3600,"renewable":true}}`))
+		case "/v1/github/token":
+			w.Write([]byte(`{"lease_id":"github/token/abc","lease_duration":600,"renewable":true,"data":{"token":"ghs_dynamic"}}`))
+		case "/v1/auth/token/renew-self":
+			renewed = append(renewed, r.Header.Get("X-Vault-Token"))
+			w.Write([]byte(`{"auth":{"client_token":"hvs.k8s","lease_duration":1800,"renewable":true}}`))
+		case "/v1/sys/leases/renew":
+			renewed = append(renewed, body["lease_id"].(string))
+			w.Write([]byte(`{"lease_id":"github/token/abc","lease_duration":300,"renewable":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("service-account-jwt\n"), 0600)
+	client := &vaultClient{address: server.URL}
+	if err := client.loginKubernetes("k8s", "migration", tokenFile); err != nil || client.token != "hvs.k8s" {
+		t.Fatalf("loginKubernetes = %q, %v", client.token, err)
+	}
+	if err := (&vaultClient{address: server.URL}).loginKubernetes("k8s", "other", tokenFile); err == nil {
+		t.Error("expected an error when vault refuses the role")
+	}
+
+	token, secret, err := readVaultSecret(client, "github/token", "token")
+	if err != nil || token != "ghs_dynamic" {
+		t.Fatalf("dynamic secret = %q, %v", token, err)
+	}
+	if duration, err := client.renew(secret.lease("github/token")); err != nil || duration != 5*time.Minute {
+		t.Errorf("lease renewal = %s, %v", duration, err)
+	}
+	if duration, err := client.renew(vaultLease{name: "vault token", duration: time.Hour}); err != nil || duration != 30*time.Minute {
+		t.Errorf("token renewal = %s, %v", duration, err)
+	}
+	if len(renewed) != 2 || renewed[0] != "github/token/abc" || renewed[1] != "hvs.k8s" {
+		t.Errorf("renewed %v", renewed)
+	}
+}
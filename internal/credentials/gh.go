@@ -0,0 +1,26 @@
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ghProvider uses the token the gh CLI is logged in with for the hostname of each side
+type ghProvider struct{}
+
+func (ghProvider) Token(side string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("gh", "auth", "token", "--hostname", hostname(side))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gh auth token --hostname %s: %w: %s", hostname(side), err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("gh is not logged in to %s, run gh auth login", hostname(side))
+	}
+	return token, nil
+}
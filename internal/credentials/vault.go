@@ -0,0 +1,255 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultK8sTokenFile is where Kubernetes mounts the service account token of a pod
+const defaultK8sTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// vaultProvider reads the token of each side from a HashiCorp Vault secret,
+// GHMPKG_<SIDE>_TOKEN_SECRET=path#field. It authenticates with VAULT_TOKEN, or
+// with the Kubernetes auth method when VAULT_K8S_ROLE is set, and keeps the
+// Vault token and the secret leases renewed while the migration runs.
+type vaultProvider struct{}
+
+var (
+	vaultMutex   sync.Mutex
+	vaultSession *vaultClient
+)
+
+func (vaultProvider) Token(side string) (string, error) {
+	path, field, err := secretReference(side, "token")
+	if err != nil {
+		return "", err
+	}
+	vaultMutex.Lock()
+	defer vaultMutex.Unlock()
+	if vaultSession == nil {
+		client, err := vaultLogin()
+		if err != nil {
+			return "", err
+		}
+		vaultSession = client
+	}
+	token, secret, err := readVaultSecret(vaultSession, path, field)
+	if err != nil {
+		return "", err
+	}
+	if secret.LeaseID != "" {
+		go keepRenewed(vaultSession, secret.lease(path))
+	}
+	return token, nil
+}
+
+// vaultClient calls the Vault HTTP API with a Vault token
+type vaultClient struct {
+	address   string
+	namespace string
+	token     string
+}
+
+// vaultResponse holds the fields of a Vault response the tool uses, secrets in
+// data and logins or token renewals in auth
+type vaultResponse struct {
+	Data          map[string]interface{} `json:"data"`
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// vaultLease is something that expires unless renewed, the Vault token itself
+// (no id) or the lease of a dynamic secret
+type vaultLease struct {
+	id        string
+	name      string
+	duration  time.Duration
+	renewable bool
+}
+
+func (r *vaultResponse) lease(name string) vaultLease {
+	return vaultLease{id: r.LeaseID, name: name, duration: time.Duration(r.LeaseDuration) * time.Second, renewable: r.Renewable}
+}
+
+func (r *vaultResponse) authLease() vaultLease {
+	return vaultLease{name: "vault token", duration: time.Duration(r.Auth.LeaseDuration) * time.Second, renewable: r.Auth.Renewable}
+}
+
+func (c *vaultClient) do(method, path string, body interface{}) (*vaultResponse, error) {
+	var content []byte
+	if body != nil {
+		var err error
+		if content, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+	var response vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse the vault response for %s: %w", path, err)
+	}
+	return &response, nil
+}
+
+// vaultLogin returns a client authenticated with VAULT_TOKEN or, when
+// VAULT_K8S_ROLE is set, logged in with the service account of the pod
+func vaultLogin() (*vaultClient, error) {
+	client := &vaultClient{address: os.Getenv("VAULT_ADDR"), namespace: os.Getenv("VAULT_NAMESPACE")}
+	if client.address == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is not set")
+	}
+	role := os.Getenv("VAULT_K8S_ROLE")
+	if role == "" {
+		if client.token = os.Getenv("VAULT_TOKEN"); client.token == "" {
+			return nil, fmt.Errorf("VAULT_TOKEN or VAULT_K8S_ROLE is not set")
+		}
+		// Tokens that are not allowed to look themselves up are used as they are
+		if lease, err := client.lookupSelf(); err == nil {
+			go keepRenewed(client, lease)
+		}
+		return client, nil
+	}
+
+	tokenFile := os.Getenv("VAULT_K8S_TOKEN_FILE")
+	if tokenFile == "" {
+		tokenFile = defaultK8sTokenFile
+	}
+	mount := os.Getenv("VAULT_K8S_MOUNT")
+	if mount == "" {
+		mount = "kubernetes"
+	}
+	if err := client.loginKubernetes(mount, role, tokenFile); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// loginKubernetes exchanges the service account token of the pod for a Vault
+// token with the Kubernetes auth method mounted at mount
+func (c *vaultClient) loginKubernetes(mount, role, tokenFile string) error {
+	jwt, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the service account token: %w", err)
+	}
+	response, err := c.do(http.MethodPost, fmt.Sprintf("auth/%s/login", strings.Trim(mount, "/")), map[string]string{
+		"role": role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return fmt.Errorf("vault kubernetes login as %s failed: %w", role, err)
+	}
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return fmt.Errorf("vault kubernetes login as %s returned no token", role)
+	}
+	c.token = response.Auth.ClientToken
+	go keepRenewed(c, response.authLease())
+	return nil
+}
+
+// lookupSelf returns the lease of the Vault token the client uses
+func (c *vaultClient) lookupSelf() (vaultLease, error) {
+	response, err := c.do(http.MethodGet, "auth/token/lookup-self", nil)
+	if err != nil {
+		return vaultLease{}, err
+	}
+	ttl, _ := response.Data["ttl"].(float64)
+	renewable, _ := response.Data["renewable"].(bool)
+	return vaultLease{name: "vault token", duration: time.Duration(ttl) * time.Second, renewable: renewable}, nil
+}
+
+// readVaultSecret reads a field of a secret. Version 2 KV engines nest the
+// fields under data.data, version 1 KV and other secret engines under data.
+func readVaultSecret(client *vaultClient, path, field string) (string, *vaultResponse, error) {
+	secret, err := client.do(http.MethodGet, path, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	token, ok := data[field].(string)
+	if !ok || token == "" {
+		return "", nil, fmt.Errorf("vault secret %s has no %s field", path, field)
+	}
+	return token, secret, nil
+}
+
+// renew extends a lease and returns its new duration. The Vault token renews
+// itself, secret leases are renewed by id.
+func (c *vaultClient) renew(lease vaultLease) (time.Duration, error) {
+	increment := int(lease.duration.Seconds())
+	if lease.id == "" {
+		response, err := c.do(http.MethodPost, "auth/token/renew-self", map[string]int{"increment": increment})
+		if err != nil {
+			return 0, err
+		}
+		if response.Auth == nil {
+			return 0, fmt.Errorf("vault returned no lease for the token renewal")
+		}
+		return time.Duration(response.Auth.LeaseDuration) * time.Second, nil
+	}
+	response, err := c.do(http.MethodPut, "sys/leases/renew", map[string]interface{}{"lease_id": lease.id, "increment": increment})
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(response.LeaseDuration) * time.Second, nil
+}
+
+// keepRenewed renews a lease when two thirds of it have passed, for as long as
+// the process runs. Leases that cannot be renewed are only reported, the
+// migration fails with authentication errors once they expire.
+func keepRenewed(client *vaultClient, lease vaultLease) {
+	logger := zap.L()
+	if lease.duration <= 0 {
+		return
+	}
+	if !lease.renewable {
+		logger.Warn("Vault lease is not renewable", zap.String("lease", lease.name), zap.Duration("expiresIn", lease.duration))
+		return
+	}
+	for {
+		time.Sleep(lease.duration * 2 / 3)
+		duration, err := client.renew(lease)
+		if err != nil {
+			logger.Warn("Failed to renew vault lease", zap.String("lease", lease.name), zap.Error(err))
+			return
+		}
+		logger.Debug("Renewed vault lease", zap.String("lease", lease.name), zap.Duration("duration", duration))
+		if duration <= 0 {
+			return
+		}
+		lease.duration = duration
+	}
+}
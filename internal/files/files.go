@@ -1,11 +1,15 @@
 package files
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
 	"github.com/mona-actions/gh-migrate-packages/internal/utils"
 )
 
@@ -29,45 +33,42 @@ func RemoveFile(fileName string) error {
 }
 
 func CreateJSON(data interface{}, filename string) error {
-	// Create a new file
-	file, err := os.Create(filename)
-	if err != nil {
+	// Encode in memory and write the file in one go
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	if err := encoder.Encode(data); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	// Create a new JSON encoder and write to the file
-	encoder := json.NewEncoder(file)
-	err = encoder.Encode(data)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return utils.WriteFileAtomic(filename, buffer.Bytes(), 0644)
 }
 
+// CreateCSV writes the rows with encoding/csv, quoting values that contain
+// commas, quotes or newlines. The file starts with # comment lines naming the
+// version, run and configuration that wrote it, which ReadCSV skips.
 func CreateCSV(data [][]string, filename string) error {
-	utils.EnsureDirExists(filename)
-	// Create a new file
-	file, err := os.Create(filename)
-	if err != nil {
+	// Build the CSV in memory and write the file in one go
+	var buffer bytes.Buffer
+	if err := WriteCSV(&buffer, data); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	// Create a new CSV writer and write to the file
-	writer := bufio.NewWriter(file)
-	for _, record := range data {
-		_, err = writer.WriteString(strings.Join(record, ",") + "\n")
-		if err != nil {
+	return utils.WriteFileAtomic(filename, buffer.Bytes(), 0644)
+}
+
+// WriteCSV writes the rows as CreateCSV does, to a writer instead of a file
+func WriteCSV(w io.Writer, data [][]string) error {
+	for _, line := range run.Current().Header() {
+		if _, err := io.WriteString(w, "# "+strings.NewReplacer("\r", " ", "\n", " ").Replace(line)+"\n"); err != nil {
 			return err
 		}
 	}
-	writer.Flush()
-
-	return nil
+	return csv.NewWriter(w).WriteAll(data)
 }
 
+// ReadCSV reads every row of a CSV file. Rows may have different lengths, older
+// exports have fewer columns, and the space after a comma is ignored so
+// hand-written files such as `"organization", "repository"` are accepted.
 func ReadCSV(filename string) ([][]string, error) {
 	// Open the file
 	file, err := os.Open(filename)
@@ -76,20 +77,24 @@ func ReadCSV(filename string) ([][]string, error) {
 	}
 	defer file.Close()
 
-	// Create a new CSV reader and read from the file
-	reader := bufio.NewReader(file)
-	var data [][]string
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		data = append(data, strings.Split(strings.TrimSpace(line), ","))
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	// Files written before values were quoted may contain bare quotes
+	reader.LazyQuotes = true
+	// Run metadata lines written by CreateCSV
+	reader.Comment = '#'
+	data, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
 	}
 
 	return data, nil
 }
 
 func EnsureDir(dir string) error {
+	if err := utils.RefuseWrite(dir); err != nil {
+		return err
+	}
 	return os.MkdirAll(dir, 0755)
 }
@@ -2,6 +2,9 @@ package files_test
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/mona-actions/gh-migrate-packages/internal/files"
@@ -82,3 +85,57 @@ func TestRemoveFile(t *testing.T) {
 		t.Errorf("RemoveFile did not remove the file")
 	}
 }
+
+func TestCSVRoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"organization", "repository", "package_type", "package_name", "package_version", "package_filename"},
+		{"mona-actions", "repo", "maven", "com.example:lib", "1.0,beta", "lib-1.0,beta.jar"},
+		{"mona-actions", "repo", "npm", "quoted", `2.0.0-"rc"`, `say "hi".tgz`},
+		{"mona-actions", "repo", "nuget", "multi", "3.0.0", "line\nbreak.nupkg"},
+		{"mona-actions", "", "container", "  padded", "sha256:abc", "padded:latest "},
+		{"mona-actions", "repo", "rubygems", "unicode-ü", "1.0.0", "unicode-ü-1.0.0.gem"},
+	}
+	filename := filepath.Join(t.TempDir(), "packages.csv")
+
+	if err := files.CreateCSV(rows, filename); err != nil {
+		t.Fatalf("CreateCSV returned an error: %v", err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "# gh-migrate-packages ") || !strings.Contains(string(content), "\n# run: ") {
+		t.Errorf("CreateCSV did not write the run metadata:\n%s", content)
+	}
+	got, err := files.ReadCSV(filename)
+	if err != nil {
+		t.Fatalf("ReadCSV returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("round trip mismatch:\n got %q\nwant %q", got, rows)
+	}
+}
+
+func TestReadCSVHandWritten(t *testing.T) {
+	content := "\"organization\", \"repository\", \"type\"\r\n" +
+		"mona-actions,repo,npm\r\n" +
+		"\r\n" +
+		"mona-actions,repo,maven,extra,columns"
+	filename := filepath.Join(t.TempDir(), "packages.csv")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := files.ReadCSV(filename)
+	if err != nil {
+		t.Fatalf("ReadCSV returned an error: %v", err)
+	}
+	want := [][]string{
+		{"organization", "repository", "type"},
+		{"mona-actions", "repo", "npm"},
+		{"mona-actions", "repo", "maven", "extra", "columns"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadCSV = %q, want %q", got, want)
+	}
+}
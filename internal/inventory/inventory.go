@@ -0,0 +1,256 @@
+package inventory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+const FileName = "inventory.db"
+
+// Statuses of a file recorded by a phase
+const (
+	Completed = "completed"
+	Failed    = "failed"
+	// Incomplete files succeeded in a version another file of failed, they
+	// are processed again with it
+	Incomplete = "incomplete"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS exports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	organization TEXT NOT NULL,
+	package_type TEXT NOT NULL,
+	snapshot TEXT NOT NULL DEFAULT '',
+	exported_at TEXT NOT NULL,
+	header TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS exports_lookup ON exports (package_type, organization, snapshot);
+CREATE TABLE IF NOT EXISTS items (
+	export_id INTEGER NOT NULL REFERENCES exports (id),
+	position INTEGER NOT NULL,
+	organization TEXT NOT NULL,
+	repository TEXT NOT NULL,
+	package_type TEXT NOT NULL,
+	package_name TEXT NOT NULL,
+	version TEXT NOT NULL,
+	filename TEXT NOT NULL,
+	row TEXT NOT NULL,
+	PRIMARY KEY (export_id, position)
+);
+CREATE INDEX IF NOT EXISTS items_package ON items (export_id, package_name, version);
+CREATE TABLE IF NOT EXISTS statuses (
+	phase TEXT NOT NULL,
+	key TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	updated_at TEXT NOT NULL,
+	PRIMARY KEY (phase, key)
+);
+`
+
+// ErrNoExport is returned by Latest when no export of the package type was
+// written to the inventory
+var ErrNoExport = errors.New("no export found in the inventory")
+
+// Store is the SQLite inventory of a migration directory: the rows of every
+// export, indexed by package and version, and the status of every file per
+// phase. It is safe for concurrent use.
+type Store struct {
+	db   *sql.DB
+	path string
+}
+
+// stores holds one Store per database so every user in the process shares it
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*Store)
+)
+
+// Open opens the inventory database in the migration directory, creating it
+// when it does not exist yet. Subsequent calls for the same directory return
+// the same Store.
+func Open(migrationPath string) (*Store, error) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	path := filepath.Join(migrationPath, FileName)
+	if store, ok := stores[path]; ok {
+		return store, nil
+	}
+	if err := utils.EnsureDirExists(path); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory %s: %w", path, err)
+	}
+	// SQLite allows a single writer, serializing the connections avoids busy errors
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open inventory %s: %w", path, err)
+	}
+	store := &Store{db: db, path: path}
+	stores[path] = store
+	return store, nil
+}
+
+// Path returns the path of the database
+func (s *Store) Path() string {
+	return s.path
+}
+
+// WriteExport records the rows of an export of a package type, the first row
+// being the header like in the export CSV. The export is named like its files,
+// after the time it started.
+func (s *Store) WriteExport(name, owner, packageType, snapshot string, rows [][]string) error {
+	if len(rows) == 0 {
+		return fmt.Errorf("export of %s has no header", packageType)
+	}
+	header, err := json.Marshal(rows[0])
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO exports (name, organization, package_type, snapshot, exported_at, header) VALUES (?, ?, ?, ?, ?, ?)",
+		name, owner, packageType, snapshot, time.Now().UTC().Format(time.RFC3339Nano), string(header))
+	if err != nil {
+		return fmt.Errorf("failed to record the export of %s: %w", packageType, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	insert, err := tx.Prepare("INSERT INTO items (export_id, position, organization, repository, package_type, package_name, version, filename, row) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for position, row := range rows[1:] {
+		if len(row) < 6 {
+			continue
+		}
+		content, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if _, err := insert.Exec(id, position, row[0], row[1], row[2], row[3], row[4], row[5], string(content)); err != nil {
+			return fmt.Errorf("failed to record the export of %s: %w", packageType, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Latest returns the rows of the most recent export of a package type, header
+// first, and its name. Exports of the organization are preferred over older ones
+// recorded without it. With a snapshot, only the exports labeled with it are
+// considered.
+func (s *Store) Latest(owner, packageType, snapshot string) ([][]string, string, error) {
+	var (
+		id           int64
+		name, header string
+	)
+	query := "SELECT id, name, header FROM exports WHERE package_type = ? AND organization IN (?, '')"
+	args := []interface{}{packageType, owner}
+	if snapshot != "" {
+		query += " AND snapshot = ?"
+		args = append(args, snapshot)
+	}
+	query += " ORDER BY organization = ? DESC, id DESC LIMIT 1"
+	args = append(args, owner)
+	err := s.db.QueryRow(query, args...).Scan(&id, &name, &header)
+	if errors.Is(err, sql.ErrNoRows) {
+		if snapshot != "" {
+			return nil, "", fmt.Errorf("%w of snapshot %s for %s in %s", ErrNoExport, snapshot, packageType, s.path)
+		}
+		return nil, "", fmt.Errorf("%w for %s in %s", ErrNoExport, packageType, s.path)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var columns []string
+	if err := json.Unmarshal([]byte(header), &columns); err != nil {
+		return nil, "", fmt.Errorf("failed to parse the header of export %s: %w", name, err)
+	}
+	rows := [][]string{columns}
+
+	result, err := s.db.Query("SELECT row FROM items WHERE export_id = ? ORDER BY position", id)
+	if err != nil {
+		return nil, "", err
+	}
+	defer result.Close()
+	for result.Next() {
+		var content string
+		if err := result.Scan(&content); err != nil {
+			return nil, "", err
+		}
+		var row []string
+		if err := json.Unmarshal([]byte(content), &row); err != nil {
+			return nil, "", fmt.Errorf("failed to parse a row of export %s: %w", name, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, name, result.Err()
+}
+
+// ResetStatuses forgets the statuses recorded by a phase
+func (s *Store) ResetStatuses(phase string) error {
+	_, err := s.db.Exec("DELETE FROM statuses WHERE phase = ?", phase)
+	return err
+}
+
+// SetStatus records the status of files in a phase, keyed like the state
+// file, with the error that failed them
+func (s *Store) SetStatus(phase, status string, err error, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	var message string
+	if err != nil {
+		message = err.Error()
+	}
+	updatedAt := time.Now().UTC().Format(time.RFC3339Nano)
+
+	tx, txErr := s.db.Begin()
+	if txErr != nil {
+		return txErr
+	}
+	defer tx.Rollback()
+	for _, key := range keys {
+		if _, err := tx.Exec("INSERT INTO statuses (phase, key, status, error, updated_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT (phase, key) DO UPDATE SET status = excluded.status, error = excluded.error, updated_at = excluded.updated_at",
+			phase, key, status, message, updatedAt); err != nil {
+			return fmt.Errorf("failed to record the status of %s: %w", key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Status returns the status of a file in a phase and the error that failed it,
+// the status is empty when none was recorded
+func (s *Store) Status(phase, key string) (string, string, error) {
+	var status, message string
+	err := s.db.QueryRow("SELECT status, error FROM statuses WHERE phase = ? AND key = ?", phase, key).Scan(&status, &message)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", nil
+	}
+	return status, message, err
+}
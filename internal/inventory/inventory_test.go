@@ -0,0 +1,78 @@
+//go:build cgo
+
+package inventory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var header = []string{"organization", "repository", "package_type", "package_name", "package_version", "package_filename"}
+
+func TestInventoryLatest(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := [][]string{header, {"mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz"}}
+	second := [][]string{header,
+		{"mona", "app", "npm", "client", "1.1.0", "client-1.1.0.tgz"},
+		{"mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz"},
+	}
+	if err := store.WriteExport("2026-01-01_10-00-00_mona_npm_packages", "mona", "npm", "wave-1", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteExport("2026-01-02_10-00-00_mona_npm_packages", "mona", "npm", "", second); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, name, err := store.Latest("mona", "npm", "")
+	if err != nil || name != "2026-01-02_10-00-00_mona_npm_packages" || !reflect.DeepEqual(rows, second) {
+		t.Errorf("Latest() = %v, %s, %v, want %v", rows, name, err, second)
+	}
+	rows, name, err = store.Latest("mona", "npm", "wave-1")
+	if err != nil || name != "2026-01-01_10-00-00_mona_npm_packages" || !reflect.DeepEqual(rows, first) {
+		t.Errorf("Latest(wave-1) = %v, %s, %v, want %v", rows, name, err, first)
+	}
+	if _, _, err := store.Latest("mona", "npm", "wave-2"); !errors.Is(err, ErrNoExport) {
+		t.Errorf("Latest(wave-2) error = %v, want ErrNoExport", err)
+	}
+	if _, _, err := store.Latest("mona", "maven", ""); !errors.Is(err, ErrNoExport) {
+		t.Errorf("Latest(maven) error = %v, want ErrNoExport", err)
+	}
+}
+
+func TestInventoryStatus(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetStatus("sync", Failed, errors.New("upload failed"), "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetStatus("sync", Completed, nil, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		phase, key, status, message string
+	}{
+		{"sync", "a", Failed, "upload failed"},
+		{"sync", "b", Completed, ""},
+		{"pull", "a", "", ""},
+	}
+	for _, test := range tests {
+		status, message, err := store.Status(test.phase, test.key)
+		if err != nil || status != test.status || message != test.message {
+			t.Errorf("Status(%s, %s) = %s, %q, %v, want %s, %q", test.phase, test.key, status, message, err, test.status, test.message)
+		}
+	}
+
+	if err := store.ResetStatuses("sync"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _, err := store.Status("sync", "b"); err != nil || status != "" {
+		t.Errorf("Status(sync, b) after reset = %s, %v, want none", status, err)
+	}
+}
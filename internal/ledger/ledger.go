@@ -0,0 +1,232 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+const FileName = "ledger.json"
+
+// JournalFileName holds the records made since the ledger was last saved, one
+// JSON entry per line
+const JournalFileName = "ledger.jsonl"
+
+// Entry follows a single file through the migration: the digest of what was
+// pulled from the source and of what was uploaded to the target, after any
+// organization rewrite
+type Entry struct {
+	Organization string `json:"organization"`
+	Repository   string `json:"repository"`
+	PackageType  string `json:"package_type"`
+	PackageName  string `json:"package_name"`
+	Version      string `json:"version"`
+	Filename     string `json:"filename"`
+	SourceDigest string `json:"source_digest,omitempty"`
+	PulledAt     string `json:"pulled_at,omitempty"`
+	TargetDigest string `json:"target_digest,omitempty"`
+	SyncedAt     string `json:"synced_at,omitempty"`
+}
+
+// Rewritten reports whether the uploaded content differs from what was pulled,
+// it is nil until both digests are known
+func (e Entry) Rewritten() *bool {
+	if e.SourceDigest == "" || e.TargetDigest == "" {
+		return nil
+	}
+	rewritten := e.SourceDigest != e.TargetDigest
+	return &rewritten
+}
+
+// Store records the digests of every pulled and uploaded file in the migration
+// directory. Every record is appended to a journal, Save folds the journal into
+// the JSON ledger. It is safe for concurrent use.
+type Store struct {
+	mu          sync.Mutex
+	path        string
+	journalPath string
+	journal     *os.File
+	dirty       bool
+	Entries     map[string]*Entry `json:"entries"`
+}
+
+// stores holds one Store per ledger file so every user in the process shares it
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*Store)
+)
+
+// Load reads the ledger file in the migration directory and replays its journal,
+// starting empty when neither exists yet. Subsequent calls for the same directory
+// return the same Store.
+func Load(migrationPath string) (*Store, error) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	path := filepath.Join(migrationPath, FileName)
+	if store, ok := stores[path]; ok {
+		return store, nil
+	}
+
+	store := &Store{path: path, journalPath: filepath.Join(migrationPath, JournalFileName), Entries: make(map[string]*Entry)}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read ledger file: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, store); err != nil {
+			return nil, fmt.Errorf("failed to parse ledger file %s: %w", path, err)
+		}
+		if store.Entries == nil {
+			store.Entries = make(map[string]*Entry)
+		}
+	}
+	if err := store.replay(); err != nil {
+		return nil, err
+	}
+	stores[path] = store
+	return store, nil
+}
+
+func (s *Store) entry(owner, repository, packageType, packageName, version, filename string) *Entry {
+	key := state.Key(owner, repository, packageType, packageName, version, filename)
+	entry, ok := s.Entries[key]
+	if !ok {
+		entry = &Entry{
+			Organization: owner,
+			Repository:   repository,
+			PackageType:  packageType,
+			PackageName:  packageName,
+			Version:      version,
+			Filename:     filename,
+		}
+		s.Entries[key] = entry
+	}
+	return entry
+}
+
+// replay applies the entries of the journal left by a run that did not save the
+// ledger. A truncated last line, from a write interrupted mid-way, is ignored.
+func (s *Store) replay() error {
+	content, err := os.ReadFile(s.journalPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ledger journal: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		s.Entries[state.Key(entry.Organization, entry.Repository, entry.PackageType, entry.PackageName, entry.Version, entry.Filename)] = &entry
+		s.dirty = true
+	}
+	return scanner.Err()
+}
+
+// appendJournal writes an entry to the journal, the caller holds the lock
+func (s *Store) appendJournal(entry *Entry) error {
+	if s.journal == nil {
+		if err := utils.RefuseWrite(s.journalPath); err != nil {
+			return err
+		}
+		if err := utils.EnsureDirExists(s.journalPath); err != nil {
+			return fmt.Errorf("failed to create ledger directory: %w", err)
+		}
+		journal, err := os.OpenFile(s.journalPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open ledger journal: %w", err)
+		}
+		s.journal = journal
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger entry: %w", err)
+	}
+	if _, err := s.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger journal: %w", err)
+	}
+	s.dirty = true
+	return nil
+}
+
+// RecordSource records the digest of a pulled file in the journal
+func (s *Store) RecordSource(owner, repository, packageType, packageName, version, filename, digest string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entry(owner, repository, packageType, packageName, version, filename)
+	entry.SourceDigest = digest
+	entry.PulledAt = time.Now().UTC().Format(time.RFC3339)
+	return s.appendJournal(entry)
+}
+
+// RecordTarget records the digest of an uploaded file in the journal
+func (s *Store) RecordTarget(owner, repository, packageType, packageName, version, filename, digest string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entry(owner, repository, packageType, packageName, version, filename)
+	entry.TargetDigest = digest
+	entry.SyncedAt = time.Now().UTC().Format(time.RFC3339)
+	return s.appendJournal(entry)
+}
+
+// Get returns a copy of the entry of a file, if any
+func (s *Store) Get(owner, repository, packageType, packageName, version, filename string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.Entries[state.Key(owner, repository, packageType, packageName, version, filename)]
+	if !ok {
+		return Entry{}, false
+	}
+	return *entry, true
+}
+
+// List returns a copy of every entry
+func (s *Store) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.Entries))
+	for _, entry := range s.Entries {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+// Save writes the ledger atomically and removes the journal it now includes. It
+// does nothing when nothing was recorded since the last save.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger: %w", err)
+	}
+	if err := utils.WriteFileAtomic(s.path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write ledger file: %w", err)
+	}
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	if err := os.Remove(s.journalPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove ledger journal: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
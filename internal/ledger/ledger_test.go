@@ -0,0 +1,85 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// reload drops the cached store so the ledger is read from disk like a new run would
+func reload(t *testing.T, dir string) *Store {
+	t.Helper()
+	storesMu.Lock()
+	delete(stores, filepath.Join(dir, FileName))
+	storesMu.Unlock()
+	store, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestLedgerRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := reload(t, dir)
+	if err := store.RecordSource("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz", "sha256:aaa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RecordTarget("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz", "sha256:bbb"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, FileName)); !os.IsNotExist(err) {
+		t.Fatalf("recording a digest rewrote the ledger: %v", err)
+	}
+
+	// A run that stops before saving leaves its records in the journal
+	entry, ok := reload(t, dir).Get("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz")
+	if !ok || entry.SourceDigest != "sha256:aaa" || entry.TargetDigest != "sha256:bbb" {
+		t.Fatalf("journal was not replayed: %+v", entry)
+	}
+
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, JournalFileName)); !os.IsNotExist(err) {
+		t.Errorf("the saved journal was not removed: %v", err)
+	}
+	reloaded := reload(t, dir)
+	entry, ok = reloaded.Get("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz")
+	if !ok || entry.PulledAt == "" || entry.SyncedAt == "" {
+		t.Fatalf("saved entry was not reloaded: %+v", entry)
+	}
+	if rewritten := entry.Rewritten(); rewritten == nil || !*rewritten {
+		t.Errorf("Rewritten() = %v, want true", rewritten)
+	}
+	if len(reloaded.List()) != 1 {
+		t.Errorf("List() = %d entries, want 1", len(reloaded.List()))
+	}
+}
+
+func TestLedgerTruncatedJournal(t *testing.T) {
+	dir := t.TempDir()
+	store := reload(t, dir)
+	if err := store.RecordSource("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz", "sha256:aaa"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a run killed in the middle of appending the next record
+	journal, err := os.OpenFile(filepath.Join(dir, JournalFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.WriteString(`{"organization":"mona","repository":"app","package_ty`)
+	journal.Close()
+
+	reloaded := reload(t, dir)
+	if len(reloaded.List()) != 1 {
+		t.Fatalf("List() = %d entries, want the 1 complete record", len(reloaded.List()))
+	}
+	if err := reloaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reload(t, dir).Get("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz"); !ok {
+		t.Error("the replayed record was not saved")
+	}
+}
@@ -0,0 +1,84 @@
+// Package logging builds the logger of a run: JSON lines in the log file of the
+// migration directory and, on request, the same entries on stderr for people
+// following a migration live.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LEVELS are the levels --log-level accepts
+var LEVELS = []string{"debug", "info", "warn", "error"}
+
+// FORMATS are the encodings of the entries written to stderr
+var FORMATS = []string{"console", "json"}
+
+// Options selects what the run logs to stderr
+type Options struct {
+	// Level of the entries written to stderr, nothing is when empty
+	Level string
+	// Verbose writes debug entries to stderr when no Level is set
+	Verbose bool
+	// Format of the entries written to stderr, console unless set
+	Format string
+}
+
+// consoleLevel returns the level of the entries written to stderr, and whether
+// any are
+func (o Options) consoleLevel() (zapcore.Level, bool, error) {
+	level := strings.ToLower(strings.TrimSpace(o.Level))
+	if level == "" && o.Verbose {
+		level = "debug"
+	}
+	if level == "" {
+		return zapcore.InfoLevel, false, nil
+	}
+	if !utils.Contains(LEVELS, level) {
+		return zapcore.InfoLevel, false, fmt.Errorf("unsupported log level: %s (expected one of %v)", o.Level, LEVELS)
+	}
+	parsed, err := zapcore.ParseLevel(level)
+	return parsed, true, err
+}
+
+// New returns a logger writing JSON lines to the log file, at info level or
+// debug when stderr gets debug entries, and, when a level is set, the entries
+// of that level and above to stderr
+func New(logFile, stderr io.Writer, options Options) (*zap.Logger, error) {
+	level, console, err := options.consoleLevel()
+	if err != nil {
+		return nil, err
+	}
+	format := strings.ToLower(strings.TrimSpace(options.Format))
+	if format == "" {
+		format = "console"
+	}
+	if !utils.Contains(FORMATS, format) {
+		return nil, fmt.Errorf("unsupported log format: %s (expected one of %v)", options.Format, FORMATS)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = "timestamp"
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	fileLevel := zapcore.InfoLevel
+	if console && level < fileLevel {
+		fileLevel = level
+	}
+	cores := []zapcore.Core{zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(logFile), fileLevel)}
+
+	if console {
+		encoder := zapcore.NewJSONEncoder(encoderConfig)
+		if format == "console" {
+			consoleConfig := encoderConfig
+			consoleConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+			encoder = zapcore.NewConsoleEncoder(consoleConfig)
+		}
+		cores = append(cores, zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(stderr)), level))
+	}
+	return zap.New(zapcore.NewTee(cores...)), nil
+}
@@ -0,0 +1,60 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/logging"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     logging.Options
+		fileLines   int
+		stderrLines int
+	}{
+		{"file only", logging.Options{}, 2, 0},
+		{"warnings on stderr", logging.Options{Level: "warn"}, 2, 1},
+		{"verbose", logging.Options{Verbose: true}, 3, 3},
+		{"level over verbose", logging.Options{Level: "error", Verbose: true}, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file, stderr bytes.Buffer
+			logger, err := logging.New(&file, &stderr, tt.options)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			logger.Debug("debug entry")
+			logger.Info("info entry")
+			logger.Warn("warn entry")
+			if lines := strings.Count(file.String(), "\n"); lines != tt.fileLines {
+				t.Errorf("log file has %d entries, want %d:\n%s", lines, tt.fileLines, file.String())
+			}
+			if lines := strings.Count(stderr.String(), "\n"); lines != tt.stderrLines {
+				t.Errorf("stderr has %d entries, want %d:\n%s", lines, tt.stderrLines, stderr.String())
+			}
+		})
+	}
+
+	var file, stderr bytes.Buffer
+	logger, err := logging.New(&file, &stderr, logging.Options{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("info entry")
+	var entry map[string]interface{}
+	if err := json.Unmarshal(stderr.Bytes(), &entry); err != nil || entry["msg"] != "info entry" {
+		t.Errorf("json stderr entry = %q, %v", stderr.String(), err)
+	}
+
+	if _, err := logging.New(&file, &stderr, logging.Options{Level: "trace"}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := logging.New(&file, &stderr, logging.Options{Level: "info", Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
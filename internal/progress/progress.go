@@ -0,0 +1,238 @@
+// Package progress publishes the progress of a run on a local socket, as one
+// JSON event per line, for wrappers that would otherwise scrape the console
+package progress
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"go.uber.org/zap"
+)
+
+// Types of the events published
+const (
+	// RunStarted is the first event of a run, subscribers connected later get it first
+	RunStarted = "run_started"
+	// PhaseStarted opens a pull or sync with the number of packages and files it processes
+	PhaseStarted = "phase_started"
+	// ItemDone reports the result of a file, or of a version or package stopped before its files
+	ItemDone = "item"
+	// PackageDone reports a finished package with the counters of the phase so far
+	PackageDone = "package"
+	// PhaseFinished closes a phase with its final counters
+	PhaseFinished = "phase_finished"
+	// RunFinished is the last event, the socket is closed after it
+	RunFinished = "run_finished"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is
+// disconnected, a slow reader never holds up the run
+const subscriberBuffer = 1024
+
+// Event is a line of the progress protocol. Fields that do not apply to an
+// event type are omitted.
+type Event struct {
+	Type    string    `json:"type"`
+	RunID   string    `json:"run_id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command,omitempty"`
+	Phase   string    `json:"phase,omitempty"`
+
+	Organization string `json:"organization,omitempty"`
+	Repository   string `json:"repository,omitempty"`
+	PackageType  string `json:"package_type,omitempty"`
+	PackageName  string `json:"package_name,omitempty"`
+	Version      string `json:"version,omitempty"`
+	Filename     string `json:"filename,omitempty"`
+	State        string `json:"state,omitempty"`
+	SkipReason   string `json:"skip_reason,omitempty"`
+	Error        string `json:"error,omitempty"`
+
+	// Counters of the phase: packages processed out of Packages, files out of Files
+	Packages        int `json:"packages,omitempty"`
+	Files           int `json:"files,omitempty"`
+	PackagesDone    int `json:"packages_done,omitempty"`
+	PackagesSuccess int `json:"packages_success,omitempty"`
+	PackagesSkipped int `json:"packages_skipped,omitempty"`
+	PackagesFailed  int `json:"packages_failed,omitempty"`
+	FilesSuccess    int `json:"files_success,omitempty"`
+	FilesSkipped    int `json:"files_skipped,omitempty"`
+	FilesFailed     int `json:"files_failed,omitempty"`
+	DurationSeconds int `json:"duration_seconds,omitempty"`
+}
+
+// Server accepts subscribers on a unix socket and sends them every event
+type Server struct {
+	listener net.Listener
+	path     string
+
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+	// Subscribers that connect late first get the start of the run and of the
+	// current phase, and its last counters
+	started []byte
+	phase   []byte
+	last    []byte
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+var (
+	mu      sync.Mutex
+	current *Server
+)
+
+// Listen starts publishing the progress of the run on a unix socket at path.
+// A stale socket left by a previous run is replaced.
+func Listen(path, command string) (*Server, error) {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, errors.New(path + " exists and is not a socket")
+		}
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{listener: listener, path: path, subscribers: make(map[chan []byte]struct{})}
+	server.wg.Add(1)
+	go server.accept()
+
+	mu.Lock()
+	current = server
+	mu.Unlock()
+	Publish(Event{Type: RunStarted, Command: command})
+	return server, nil
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		events := make(chan []byte, subscriberBuffer)
+		for _, line := range [][]byte{s.started, s.phase, s.last} {
+			if line != nil {
+				events <- line
+			}
+		}
+		s.subscribers[events] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.send(conn, events)
+	}
+}
+
+// send writes events to a subscriber until its channel is closed or it hangs up
+func (s *Server) send(conn net.Conn, events chan []byte) {
+	defer s.wg.Done()
+	defer conn.Close()
+	for line := range events {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := conn.Write(line); err != nil {
+			s.unsubscribe(events)
+			// Drain until the channel is closed
+			for range events {
+			}
+			return
+		}
+	}
+}
+
+func (s *Server) unsubscribe(events chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[events]; ok {
+		delete(s.subscribers, events)
+		close(events)
+	}
+}
+
+func (s *Server) publish(event Event) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		zap.L().Warn("Failed to encode progress event", zap.Error(err))
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch event.Type {
+	case RunStarted:
+		s.started = line
+	case PhaseStarted:
+		s.phase, s.last = line, nil
+	case PackageDone, PhaseFinished:
+		s.last = line
+	}
+	for events := range s.subscribers {
+		select {
+		case events <- line:
+		default:
+			zap.L().Warn("Disconnecting a progress subscriber that fell behind")
+			delete(s.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// Close publishes the end of the run, waits for the subscribers to receive
+// every event and removes the socket
+func (s *Server) Close() error {
+	s.publish(Event{Type: RunFinished, RunID: run.ID(), Time: time.Now().UTC()})
+	mu.Lock()
+	if current == s {
+		current = nil
+	}
+	mu.Unlock()
+
+	err := s.listener.Close()
+	s.mu.Lock()
+	s.closed = true
+	for events := range s.subscribers {
+		delete(s.subscribers, events)
+		close(events)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	os.Remove(s.path)
+	return err
+}
+
+// Publish sends an event to the subscribers of the run, it does nothing when
+// no progress socket was requested
+func Publish(event Event) {
+	mu.Lock()
+	server := current
+	mu.Unlock()
+	if server == nil {
+		return
+	}
+	event.RunID = run.ID()
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	server.publish(event)
+}
+
+// Enabled reports whether a progress socket is listening
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return current != nil
+}
@@ -0,0 +1,89 @@
+package progress_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/progress"
+	"github.com/spf13/viper"
+)
+
+// readEvent reads the next event a subscriber receives
+func readEvent(t *testing.T, scanner *bufio.Scanner) progress.Event {
+	t.Helper()
+	if !scanner.Scan() {
+		t.Fatalf("subscriber got no event: %v", scanner.Err())
+	}
+	var event progress.Event
+	if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+		t.Fatalf("event %s is not JSON: %v", scanner.Text(), err)
+	}
+	return event
+}
+
+func TestServer(t *testing.T) {
+	viper.Set("GHMPKG_RUN_ID", "run-1")
+	defer viper.Reset()
+	// Socket paths are limited to about 100 characters, shorter than some temp dirs
+	dir, err := os.MkdirTemp("", "ghmpkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "progress.sock")
+
+	// Publishing without a socket does nothing
+	progress.Publish(progress.Event{Type: progress.PhaseStarted})
+	if progress.Enabled() {
+		t.Fatal("progress is enabled before Listen")
+	}
+
+	server, err := progress.Listen(path, "sync")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	progress.Publish(progress.Event{Type: progress.PhaseStarted, Phase: "sync", Packages: 2})
+	progress.Publish(progress.Event{Type: progress.PackageDone, Phase: "sync", PackageName: "lib", PackagesDone: 1})
+
+	// A late subscriber catches up with the run, its phase and the last counters
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	if event := readEvent(t, scanner); event.Type != progress.RunStarted || event.Command != "sync" || event.RunID != "run-1" {
+		t.Errorf("first event = %+v, want run_started", event)
+	}
+	if event := readEvent(t, scanner); event.Type != progress.PhaseStarted || event.Packages != 2 {
+		t.Errorf("second event = %+v, want phase_started", event)
+	}
+	if event := readEvent(t, scanner); event.Type != progress.PackageDone || event.PackagesDone != 1 {
+		t.Errorf("third event = %+v, want the last package", event)
+	}
+
+	progress.Publish(progress.Event{Type: progress.ItemDone, PackageName: "app", State: "Failed", Error: "boom"})
+	if event := readEvent(t, scanner); event.Type != progress.ItemDone || event.Error != "boom" || event.Time.IsZero() {
+		t.Errorf("item event = %+v", event)
+	}
+
+	if err := server.Close(); err != nil {
+		t.Errorf("Close returned an error: %v", err)
+	}
+	if event := readEvent(t, scanner); event.Type != progress.RunFinished {
+		t.Errorf("last event = %+v, want run_finished", event)
+	}
+	if scanner.Scan() {
+		t.Errorf("event after run_finished: %s", scanner.Text())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket left behind: %v", err)
+	}
+	if progress.Enabled() {
+		t.Error("progress is enabled after Close")
+	}
+}
@@ -0,0 +1,306 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/mona-actions/gh-migrate-packages/internal/registry"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// ARTIFACTORY_PACKAGE_TYPES are the package types that can be published to Artifactory
+var ARTIFACTORY_PACKAGE_TYPES = []string{"container", "maven", "npm", "nuget"}
+
+// ArtifactoryRepositories returns the repository key of each package type, from
+// type=key entries in GHMPKG_ARTIFACTORY_REPOS
+func ArtifactoryRepositories() (map[string]string, error) {
+	return targetRepositories(TargetArtifactory, "GHMPKG_ARTIFACTORY_REPOS", ARTIFACTORY_PACKAGE_TYPES)
+}
+
+// ArtifactoryProvider publishes the packages pulled from GitHub to a JFrog
+// Artifactory repository. It wraps the provider of the package type, which
+// still exports and pulls, and only replaces the upload.
+type ArtifactoryProvider struct {
+	Provider
+	base       BaseProvider
+	baseUrl    *url.URL
+	repository string
+	user       string
+	apiKey     string
+	// dockerHost, dockerAuth and registry push images, with the repository key
+	// as the first path segment of the image name
+	dockerHost string
+	dockerAuth string
+	registry   *registry.Client
+}
+
+func newArtifactoryProvider(provider Provider) *ArtifactoryProvider {
+	packageType := provider.GetPackageType()
+	return &ArtifactoryProvider{
+		Provider: provider,
+		base:     NewBaseProvider(packageType, "", "", packageType == "container"),
+	}
+}
+
+// Connect connects the wrapped provider and checks the Artifactory settings
+func (p *ArtifactoryProvider) Connect(logger *zap.Logger) error {
+	if err := p.Provider.Connect(logger); err != nil {
+		return err
+	}
+	baseUrl := targetBaseUrl(viper.GetString("GHMPKG_ARTIFACTORY_URL"))
+	if baseUrl == "" {
+		return fmt.Errorf("GHMPKG_ARTIFACTORY_URL is required to publish to artifactory")
+	}
+	// Both https://acme.jfrog.io and https://acme.jfrog.io/artifactory are accepted
+	if !strings.HasSuffix(baseUrl, "/artifactory") {
+		baseUrl += "/artifactory"
+	}
+	p.baseUrl = utils.ParseUrl(baseUrl + "/")
+	p.user = viper.GetString("GHMPKG_ARTIFACTORY_USER")
+	p.apiKey = viper.GetString("GHMPKG_ARTIFACTORY_API_KEY")
+	if p.apiKey == "" {
+		return fmt.Errorf("GHMPKG_ARTIFACTORY_API_KEY is required to publish to artifactory")
+	}
+
+	repositories, err := ArtifactoryRepositories()
+	if err != nil {
+		return err
+	}
+	p.repository = repositories[p.base.PackageType]
+
+	if p.base.PackageType == "container" && p.repository != "" {
+		if p.user == "" {
+			return fmt.Errorf("GHMPKG_ARTIFACTORY_USER is required to push images to artifactory")
+		}
+		p.dockerHost = viper.GetString("GHMPKG_ARTIFACTORY_DOCKER_REGISTRY")
+		if p.dockerHost == "" {
+			p.dockerHost = p.baseUrl.Host
+		}
+		p.registry = registry.NewClient(p.dockerHost, p.user, p.apiKey)
+		if containers, ok := p.Provider.(*ContainerProvider); ok && containers.client != nil {
+			if p.dockerAuth, err = containers.login(logger, p.dockerHost, p.user, p.apiKey); err != nil {
+				return fmt.Errorf("failed to login to artifactory registry %s: %w", p.dockerHost, err)
+			}
+		}
+	}
+	return nil
+}
+
+// artifactPath is where Artifactory expects a file in the default layout of
+// the repository type, relative to the repository
+func (p *ArtifactoryProvider) artifactPath(packageName, version, filename string) string {
+	switch p.base.PackageType {
+	case "maven":
+		// GitHub names Maven packages groupId.artifactId
+		return path.Join(strings.ReplaceAll(packageName, ".", "/"), version, filename)
+	case "npm":
+		scope := "@" + strings.ToLower(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
+		return path.Join(scope, packageName, "-", scope, npmTarballName(packageName, version))
+	}
+	return path.Join(packageName, filename)
+}
+
+// npmTarballName is the name pull stores an npm version under with normalized --staging-names
+func npmTarballName(packageName, version string) string {
+	return fmt.Sprintf("%s-%s.tgz", packageName, version)
+}
+
+// GetUploadUrl returns the Artifactory URL a file is deployed to, or the image
+// reference for containers
+func (p *ArtifactoryProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	if p.repository == "" {
+		return "", fmt.Errorf("no artifactory repository is configured for %s packages, set GHMPKG_ARTIFACTORY_REPOS", p.base.PackageType)
+	}
+	if p.base.PackageType == "container" {
+		return path.Join(p.dockerHost, p.repository, NormalizeName(p.base.PackageType, NameField, packageName)+":"+imageTag(filename)), nil
+	}
+	uploadUrl := utils.JoinUrlPath(*p.baseUrl, append([]string{p.repository}, strings.Split(p.artifactPath(packageName, version, filename), "/")...)...)
+	return uploadUrl.String(), nil
+}
+
+// imageTag is the tag of a container filename, name:tag
+func imageTag(filename string) string {
+	_, tag, _ := strings.Cut(filename, ":")
+	return tag
+}
+
+// Upload deploys a pulled file to the Artifactory repository of its package type
+func (p *ArtifactoryProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	if !utils.Contains(ARTIFACTORY_PACKAGE_TYPES, packageType) {
+		return Skip(SkipTargetUnsupported, fmt.Sprintf("%s packages cannot be published to artifactory", packageType))
+	}
+	if p.repository == "" {
+		return Skip(SkipTargetUnsupported, fmt.Sprintf("no artifactory repository is configured for %s packages", packageType))
+	}
+	if packageType == "container" {
+		return p.uploadImage(logger, repository, packageName, version, filename)
+	}
+
+	return p.base.uploadPackage(
+		logger, owner, repository, packageType, packageName, version, filename,
+		func() (string, error) {
+			return p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
+		},
+		func(uploadUrl, packageDir string) (ResultState, error) {
+			localFile := filepath.Join(packageDir, filename)
+			if packageType == "npm" {
+				localFile = stagedPath(packageDir, npmTarballName(packageName, version), filename)
+			}
+			result, digest, outcome, err := p.deploy(logger, uploadUrl, localFile)
+			if err == nil && digest != "" {
+				p.base.recordTargetDigest(logger, repository, packageName, version, filename, "sha256:"+digest)
+				p.base.recordUploadDigest(repository, packageName, version, filename, outcome)
+			}
+			return result, err
+		},
+	)
+}
+
+// deploy PUTs a file with its digest headers, which Artifactory verifies. A
+// file the repository already has with the same SHA-256 is skipped. It returns
+// the SHA-256 of the file and whether Artifactory validated it.
+func (p *ArtifactoryProvider) deploy(logger *zap.Logger, uploadUrl, localFile string) (ResultState, string, string, error) {
+	file, err := os.Open(localFile)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return Failed, "", "", err
+	}
+	size := stat.Size()
+	digestHeader, err := utils.DigestHeaders(file)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	digest := digestHeader.Get("X-Checksum-Sha256")
+
+	client := utils.NewHTTPClient()
+	head, err := http.NewRequest(http.MethodHead, uploadUrl, nil)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	p.authorize(head)
+	if resp, err := client.Do(head); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && strings.EqualFold(resp.Header.Get("X-Checksum-Sha256"), digest) {
+			logger.Info("File already deployed", zap.String("url", uploadUrl))
+			return Skipped, "", "", nil
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Failed, "", "", err
+	}
+	req, err := http.NewRequest(http.MethodPut, uploadUrl, file)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	for name, values := range digestHeader {
+		req.Header[name] = values
+	}
+	p.authorize(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Failed, "", "", fmt.Errorf("artifactory returned %s for %s: %s", resp.Status, uploadUrl, strings.TrimSpace(string(body)))
+	}
+	// The deploy response lists the checksums Artifactory computed from what it stored
+	var deployed struct {
+		Checksums struct {
+			Sha256 string `json:"sha256"`
+		} `json:"checksums"`
+	}
+	outcome := DigestSent
+	if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&deployed) == nil && strings.EqualFold(deployed.Checksums.Sha256, digest) {
+		outcome = DigestValidated
+	}
+	return Success, digest, outcome, nil
+}
+
+// authorize adds the API key to a request, with basic auth when a user is set
+func (p *ArtifactoryProvider) authorize(req *http.Request) {
+	if p.user != "" {
+		req.SetBasicAuth(p.user, p.apiKey)
+	} else {
+		req.Header.Set("X-JFrog-Art-Api", p.apiKey)
+	}
+}
+
+// uploadImage pushes an image to the Artifactory Docker repository, manifest
+// lists and OCI artifacts from their OCI layout and single images through the
+// Docker daemon.
+// The image source label is kept, there is no target organization to point it at.
+func (p *ArtifactoryProvider) uploadImage(logger *zap.Logger, repository, packageName, version, filename string) (ResultState, error) {
+	containers, ok := p.Provider.(*ContainerProvider)
+	if !ok {
+		return Failed, fmt.Errorf("unexpected container provider %T", p.Provider)
+	}
+	ledgerRepository, ledgerName := repository, packageName
+	sourceOwner, _, imageName := containers.normalizeNames(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, packageName)
+	tag := imageTag(filename)
+
+	return containers.uploadPackage(
+		logger, sourceOwner, repository, "container", imageName, version, filename,
+		func() (string, error) {
+			return p.GetUploadUrl(logger, sourceOwner, repository, packageName, version, filename)
+		},
+		func(targetRef, packageDir string) (ResultState, error) {
+			layoutDir := filepath.Join(packageDir, layoutName(imageName, tag))
+			if utils.FileExists(layoutDir) {
+				layout := registry.Layout{Dir: layoutDir}
+				if err := registry.Push(containers.ctx, p.registry, path.Join(p.repository, imageName), tag, layout); err != nil {
+					logger.Error("Failed to push manifest list or artifact to artifactory", zap.Error(err))
+					return Failed, err
+				}
+				if desc, err := layout.ReadIndex(); err == nil {
+					p.base.recordTargetDigest(logger, ledgerRepository, ledgerName, version, filename, desc.Digest)
+				}
+				return Success, nil
+			}
+
+			sourceRef, err := containers.GetDownloadUrl(logger, sourceOwner, repository, packageName, version, filename)
+			if err != nil {
+				return Failed, err
+			}
+			if err := containers.client.ImageTag(containers.ctx, sourceRef, targetRef); err != nil {
+				logger.Error("Failed to tag image for artifactory", zap.String("image", sourceRef), zap.Error(err))
+				return Failed, err
+			}
+			pushResp, err := containers.client.ImagePush(containers.ctx, targetRef, image.PushOptions{RegistryAuth: p.dockerAuth})
+			if err != nil {
+				logger.Error("Failed to push image to artifactory", zap.Error(err))
+				return Failed, err
+			}
+			defer pushResp.Close()
+			digest, err := pushedDigest(pushResp)
+			if err != nil {
+				logger.Error("Failed to read push response", zap.Error(err))
+				return Failed, err
+			}
+			if digest != "" {
+				p.base.recordTargetDigest(logger, ledgerRepository, ledgerName, version, filename, digest)
+			}
+			return Success, nil
+		},
+	)
+}
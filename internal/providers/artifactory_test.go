@@ -0,0 +1,120 @@
+package providers_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestArtifactoryRepositories(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("GHMPKG_ARTIFACTORY_REPOS", []string{"maven=libs-release-local, NPM=npm-local", "nuget=nuget-local"})
+	repositories, err := providers.ArtifactoryRepositories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"maven": "libs-release-local", "npm": "npm-local", "nuget": "nuget-local"}
+	if len(repositories) != len(want) {
+		t.Errorf("ArtifactoryRepositories = %v, want %v", repositories, want)
+	}
+	for packageType, key := range want {
+		if repositories[packageType] != key {
+			t.Errorf("repository of %s = %q, want %q", packageType, repositories[packageType], key)
+		}
+	}
+
+	for _, invalid := range []string{"maven", "maven=", "rubygems=gems-local"} {
+		viper.Set("GHMPKG_ARTIFACTORY_REPOS", []string{invalid})
+		if _, err := providers.ArtifactoryRepositories(); err == nil {
+			t.Errorf("ArtifactoryRepositories accepted %q", invalid)
+		}
+	}
+}
+
+func artifactorySettings(url string, repositories ...string) {
+	targetSettings("artifactory", map[string]interface{}{
+		"GHMPKG_ARTIFACTORY_URL":     url,
+		"GHMPKG_ARTIFACTORY_USER":    "deployer",
+		"GHMPKG_ARTIFACTORY_API_KEY": "key",
+		"GHMPKG_ARTIFACTORY_REPOS":   repositories,
+	})
+}
+
+func TestArtifactoryUploadUrls(t *testing.T) {
+	defer viper.Reset()
+	artifactorySettings("acme.jfrog.io", "maven=libs-release-local,npm=npm-local,nuget=nuget-local")
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "Mona")
+
+	checkUploadUrls(t, []uploadUrlTest{
+		{"maven", "com.example.app", "1.0", "app-1.0.jar", "https://acme.jfrog.io/artifactory/libs-release-local/com/example/app/1.0/app-1.0.jar"},
+		{"npm", "app", "1.0.0", "app-1.0.0.tgz", "https://acme.jfrog.io/artifactory/npm-local/@mona/app/-/@mona/app-1.0.0.tgz"},
+		{"nuget", "App", "1.0.0", "App-1.0.0.nupkg", "https://acme.jfrog.io/artifactory/nuget-local/App/App-1.0.0.nupkg"},
+	})
+}
+
+func TestArtifactoryDeploy(t *testing.T) {
+	defer viper.Reset()
+	migrationPath := t.TempDir()
+	writePulled(t, migrationPath, "maven", "com.example.app", "1.0", "app-1.0.jar", "jar")
+	sum := sha256.Sum256([]byte("jar"))
+	digest := hex.EncodeToString(sum[:])
+
+	registry := &fakeRegistry{
+		authorized: func(r *http.Request) bool {
+			user, key, ok := r.BasicAuth()
+			return ok && user == "deployer" && key == "key"
+		},
+		head: func(w http.ResponseWriter, content string) {
+			stored := sha256.Sum256([]byte(content))
+			w.Header().Set("X-Checksum-Sha256", hex.EncodeToString(stored[:]))
+		},
+	}
+	registry.handle = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		if r.Header.Get("X-Checksum-Sha256") != digest || r.Header.Get("X-Checksum-Sha1") == "" || r.Header.Get("Content-MD5") == "" {
+			w.WriteHeader(http.StatusConflict)
+			return true
+		}
+		body, _ := io.ReadAll(r.Body)
+		registry.put(r.URL.EscapedPath(), string(body))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"checksums":{"sha256":"%x"}}`, sha256.Sum256(body))
+		return true
+	}
+	server := registry.start(t)
+	viper.Set("GHMPKG_MIGRATION_PATH", migrationPath)
+	artifactorySettings(server.URL+"/artifactory/", "maven=libs-release-local")
+
+	provider := connectTarget(t, "maven")
+	result, err := provider.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar")
+	if err != nil || result != providers.Success {
+		t.Fatalf("Upload = %v, %v", result, err)
+	}
+	if content, _ := registry.get("/artifactory/libs-release-local/com/example/app/1.0/app-1.0.jar"); content != "jar" {
+		t.Errorf("deployed files = %v", registry.stored)
+	}
+	if outcome := providers.UploadDigest("repo", "maven", "com.example.app", "1.0", "app-1.0.jar"); outcome != providers.DigestValidated {
+		t.Errorf("UploadDigest = %q, want %s", outcome, providers.DigestValidated)
+	}
+
+	result, err = provider.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar")
+	if result != providers.Skipped || !providers.IsSkip(err) {
+		t.Errorf("second Upload = %v, %v, want a skip", result, err)
+	}
+
+	viper.Set("GHMPKG_ARTIFACTORY_REPOS", []string{})
+	provider = connectTarget(t, "maven")
+	if _, err := provider.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar"); !providers.IsSkip(err) {
+		t.Errorf("Upload without a repository = %v, want a skip", err)
+	}
+}
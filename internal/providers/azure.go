@@ -0,0 +1,139 @@
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// AZURE_PACKAGE_TYPES are the package types that can be published to Azure Artifacts
+var AZURE_PACKAGE_TYPES = []string{"maven", "npm", "nuget"}
+
+// DefaultAzureUrl is the packaging host of Azure DevOps Services
+const DefaultAzureUrl = "https://pkgs.dev.azure.com"
+
+// AzureFeedUrl returns the URL of the Azure Artifacts feed, scoped to the
+// project when one is set and to the organization otherwise
+func AzureFeedUrl() *url.URL {
+	baseUrl := targetBaseUrl(viper.GetString("GHMPKG_AZURE_URL"))
+	if baseUrl == "" {
+		baseUrl = DefaultAzureUrl
+	}
+	feedUrl := utils.JoinUrlPath(*utils.ParseUrl(baseUrl),
+		viper.GetString("GHMPKG_AZURE_ORGANIZATION"),
+		viper.GetString("GHMPKG_AZURE_PROJECT"),
+		"_packaging",
+		viper.GetString("GHMPKG_AZURE_FEED"))
+	return &feedUrl
+}
+
+// AzureProvider publishes the packages pulled from GitHub to an Azure
+// Artifacts feed, which hosts every package type. A feed version cannot be
+// replaced, even once deleted, so versions the feed has are skipped.
+type AzureProvider struct {
+	Provider
+	base    BaseProvider
+	feed    feedPublisher
+	feedUrl *url.URL
+	token   string
+}
+
+func newAzureProvider(provider Provider) *AzureProvider {
+	return &AzureProvider{
+		Provider: provider,
+		base:     NewBaseProvider(provider.GetPackageType(), "", "", false),
+	}
+}
+
+// Connect connects the wrapped provider and checks the Azure Artifacts settings
+func (p *AzureProvider) Connect(logger *zap.Logger) error {
+	if err := p.Provider.Connect(logger); err != nil {
+		return err
+	}
+	if viper.GetString("GHMPKG_AZURE_ORGANIZATION") == "" || viper.GetString("GHMPKG_AZURE_FEED") == "" {
+		return fmt.Errorf("GHMPKG_AZURE_ORGANIZATION and GHMPKG_AZURE_FEED are required to publish to azure artifacts")
+	}
+	p.token = viper.GetString("GHMPKG_AZURE_TOKEN")
+	if p.token == "" {
+		return fmt.Errorf("GHMPKG_AZURE_TOKEN is required to publish to azure artifacts")
+	}
+	p.feedUrl = AzureFeedUrl()
+	p.feed = feedPublisher{registry: "azure artifacts", authorize: p.authorize}
+	return nil
+}
+
+// npmRegistryUrl is the npm registry of the feed
+func (p *AzureProvider) npmRegistryUrl() url.URL {
+	return utils.JoinUrlPath(*p.feedUrl, "npm", "registry")
+}
+
+// GetUploadUrl returns the URL a file is published to: the file itself for
+// Maven, the package for npm and the push endpoint for NuGet
+func (p *AzureProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	switch p.base.PackageType {
+	case "maven":
+		// GitHub names Maven packages groupId.artifactId
+		segments := append([]string{"maven", "v1"}, strings.Split(packageName, ".")...)
+		uploadUrl := utils.JoinUrlPath(*p.feedUrl, append(segments, version, filename)...)
+		return uploadUrl.String(), nil
+	case "npm":
+		scope := "@" + strings.ToLower(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
+		uploadUrl := utils.JoinUrlPath(p.npmRegistryUrl(), scope+"/"+packageName)
+		return uploadUrl.String(), nil
+	case "nuget":
+		uploadUrl := utils.JoinUrlPath(*p.feedUrl, "nuget", "v2")
+		return uploadUrl.String() + "/", nil
+	}
+	return "", fmt.Errorf("%s packages cannot be published to azure artifacts", p.base.PackageType)
+}
+
+// Upload publishes a pulled file to the feed
+func (p *AzureProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	if !utils.Contains(AZURE_PACKAGE_TYPES, packageType) {
+		return Skip(SkipTargetUnsupported, fmt.Sprintf("%s packages cannot be published to azure artifacts", packageType))
+	}
+
+	return p.base.uploadPackage(
+		logger, owner, repository, packageType, packageName, version, filename,
+		func() (string, error) {
+			return p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
+		},
+		func(uploadUrl, packageDir string) (ResultState, error) {
+			localFile := filepath.Join(packageDir, filename)
+			if packageType == "npm" {
+				localFile = stagedPath(packageDir, npmTarballName(packageName, version), filename)
+			}
+			content, err := os.ReadFile(localFile)
+			if err != nil {
+				return Failed, err
+			}
+
+			var result ResultState
+			switch packageType {
+			case "maven":
+				result, err = p.feed.deployMaven(logger, uploadUrl, content)
+			case "npm":
+				result, err = p.feed.publishNpm(logger, p.npmRegistryUrl(), content)
+			case "nuget":
+				result, err = p.feed.pushNuget(logger, uploadUrl, filepath.Base(localFile), content)
+			}
+			if result == Success {
+				p.base.recordTargetFile(logger, repository, packageName, version, filename, localFile)
+			}
+			return result, err
+		},
+	)
+}
+
+// authorize adds the personal access token, Azure DevOps ignores the user name
+func (p *AzureProvider) authorize(req *http.Request) error {
+	req.SetBasicAuth(viper.GetString("GHMPKG_AZURE_ORGANIZATION"), p.token)
+	return nil
+}
@@ -0,0 +1,118 @@
+package providers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func azureSettings(url, project string) {
+	targetSettings("azure", map[string]interface{}{
+		"GHMPKG_AZURE_URL":          url,
+		"GHMPKG_AZURE_ORGANIZATION": "contoso",
+		"GHMPKG_AZURE_PROJECT":      project,
+		"GHMPKG_AZURE_FEED":         "packages",
+		"GHMPKG_AZURE_TOKEN":        "pat",
+	})
+}
+
+func TestAzureUploadUrls(t *testing.T) {
+	defer viper.Reset()
+	azureSettings("", "platform")
+
+	checkUploadUrls(t, []uploadUrlTest{
+		{"maven", "com.example.app", "1.0", "app-1.0.jar", "https://pkgs.dev.azure.com/contoso/platform/_packaging/packages/maven/v1/com/example/app/1.0/app-1.0.jar"},
+		{"npm", "app", "1.0.0", "app-1.0.0.tgz", "https://pkgs.dev.azure.com/contoso/platform/_packaging/packages/npm/registry/@mona%2Fapp"},
+		{"nuget", "App", "1.0.0", "App-1.0.0.nupkg", "https://pkgs.dev.azure.com/contoso/platform/_packaging/packages/nuget/v2/"},
+	})
+
+	// Organization scoped feeds have no project
+	viper.Set("GHMPKG_AZURE_PROJECT", "")
+	if got := providers.AzureFeedUrl().String(); got != "https://pkgs.dev.azure.com/contoso/_packaging/packages" {
+		t.Errorf("AzureFeedUrl = %s", got)
+	}
+}
+
+func TestAzurePublish(t *testing.T) {
+	defer viper.Reset()
+	migrationPath := t.TempDir()
+	writePulled(t, migrationPath, "maven", "com.example.app", "1.0", "app-1.0.jar", "jar")
+	writePulled(t, migrationPath, "maven", "com.example.app", "1.0", "app-1.0.pom", "pom")
+	writePulled(t, migrationPath, "npm", "app", "1.0.0", "app-1.0.0.tgz", npmTarball(t, `{"name": "@mona/app", "version": "1.0.0"}`))
+	writePulled(t, migrationPath, "nuget", "App", "1.0.0", "App-1.0.0.nupkg", "nupkg")
+
+	registry := &fakeRegistry{
+		authorized: func(r *http.Request) bool {
+			_, token, ok := r.BasicAuth()
+			return ok && token == "pat"
+		},
+	}
+	registry.handle = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/contoso/_packaging/packages/nuget/v2/" {
+			return false
+		}
+		file, _, err := r.FormFile("package")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return true
+		}
+		body, _ := io.ReadAll(file)
+		if _, ok := registry.get(r.URL.Path); ok {
+			w.WriteHeader(http.StatusConflict)
+			return true
+		}
+		registry.put(r.URL.Path, string(body))
+		w.WriteHeader(http.StatusCreated)
+		return true
+	}
+	server := registry.start(t)
+	viper.Set("GHMPKG_MIGRATION_PATH", migrationPath)
+	azureSettings(server.URL, "")
+
+	maven := connectTarget(t, "maven")
+	for i, want := range []providers.ResultState{providers.Success, providers.Skipped} {
+		if result, _ := maven.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar"); result != want {
+			t.Errorf("maven Upload %d = %v, want %v", i, result, want)
+		}
+	}
+	if content, _ := registry.get("/contoso/_packaging/packages/maven/v1/com/example/app/1.0/app-1.0.jar"); content != "jar" {
+		t.Errorf("published = %v", registry.stored)
+	}
+
+	// The feed has another pom for the version, it cannot be replaced
+	registry.put("/contoso/_packaging/packages/maven/v1/com/example/app/1.0/app-1.0.pom", "other pom")
+	if result, err := maven.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.pom"); result != providers.Failed || err == nil || providers.IsSkip(err) {
+		t.Errorf("maven Upload of a different file = %v, %v, want a failure", result, err)
+	}
+	if content, _ := registry.get("/contoso/_packaging/packages/maven/v1/com/example/app/1.0/app-1.0.pom"); content != "other pom" {
+		t.Errorf("the published pom was replaced with %q", content)
+	}
+
+	npm := connectTarget(t, "npm")
+	if result, err := npm.Upload(zap.NewNop(), "mona", "repo", "npm", "app", "1.0.0", "app-1.0.0.tgz"); err != nil || result != providers.Success {
+		t.Fatalf("npm Upload = %v, %v", result, err)
+	}
+	var document map[string]interface{}
+	content, _ := registry.get("/contoso/_packaging/packages/npm/registry/@mona%2Fapp")
+	if err := json.Unmarshal([]byte(content), &document); err != nil || document["name"] != "@mona/app" {
+		t.Errorf("npm publish document = %v, %v", document, err)
+	}
+
+	nuget := connectTarget(t, "nuget")
+	if result, err := nuget.Upload(zap.NewNop(), "mona", "repo", "nuget", "App", "1.0.0", "App-1.0.0.nupkg"); err != nil || result != providers.Success {
+		t.Fatalf("nuget Upload = %v, %v", result, err)
+	}
+	if result, err := nuget.Upload(zap.NewNop(), "mona", "repo", "nuget", "App", "1.0.0", "App-1.0.0.nupkg"); result != providers.Skipped || !providers.IsSkip(err) {
+		t.Errorf("second nuget Upload = %v, %v, want a skip", result, err)
+	}
+
+	container := connectTarget(t, "container")
+	if _, err := container.Upload(zap.NewNop(), "mona", "repo", "container", "app", "1.0.0", "app:1.0.0"); !providers.IsSkip(err) {
+		t.Errorf("container Upload = %v, want a skip", err)
+	}
+}
@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// How strictly pull treats a downloaded file whose checksum differs from the export
+const (
+	ChecksumsFail = "fail"
+	ChecksumsWarn = "warn"
+	ChecksumsOff  = "off"
+)
+
+// CHECKSUM_MODES are the values accepted by --verify-checksums
+var CHECKSUM_MODES = []string{ChecksumsFail, ChecksumsWarn, ChecksumsOff}
+
+// Checksummer is implemented by providers that know the SHA-256 of the source
+// files when exporting them
+type Checksummer interface {
+	FileChecksum(logger *zap.Logger, owner, packageName, version, filename string) (string, error)
+}
+
+// Sizer is implemented by providers that know the size in bytes of the source
+// files when exporting them
+type Sizer interface {
+	FileSize(logger *zap.Logger, owner, repository, packageName, version, filename string) (int64, error)
+}
+
+// checksumCache holds the files, checksums and sizes, of the source packages,
+// loaded from the GraphQL API on first use
+type checksumCache struct {
+	once  sync.Once
+	files map[string]FileNode
+	err   error
+}
+
+// expectedChecksums holds the checksums pull expects for the files it
+// downloads, keyed like the state. Container images expect their manifest digest.
+var expectedChecksums sync.Map
+
+// ExpectChecksum registers the checksum the export recorded for a file
+func ExpectChecksum(repository, packageType, packageName, version, filename, checksum string) {
+	if checksum == "" {
+		return
+	}
+	expectedChecksums.Store(state.Key(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, packageType, packageName, version, filename), checksum)
+}
+
+// ChecksumMode returns the GHMPKG_VERIFY_CHECKSUMS mode, fail when unset
+func ChecksumMode() (string, error) {
+	mode := strings.ToLower(viper.GetString("GHMPKG_VERIFY_CHECKSUMS"))
+	if mode == "" {
+		return ChecksumsFail, nil
+	}
+	if !utils.Contains(CHECKSUM_MODES, mode) {
+		return "", fmt.Errorf("invalid --verify-checksums %q, expected one of: %s", mode, strings.Join(CHECKSUM_MODES, ", "))
+	}
+	return mode, nil
+}
+
+// SameChecksum compares two SHA-256 checksums, with or without the sha256: prefix
+func SameChecksum(a, b string) bool {
+	return strings.EqualFold(strings.TrimPrefix(a, "sha256:"), strings.TrimPrefix(b, "sha256:"))
+}
+
+// expectedChecksum returns the checksum registered for a file with ExpectChecksum
+func (p *BaseProvider) expectedChecksum(repository, packageName, version, filename string) (string, bool) {
+	expected, ok := expectedChecksums.Load(state.Key(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, p.PackageType, packageName, version, filename))
+	if !ok {
+		return "", false
+	}
+	return expected.(string), true
+}
+
+// checkChecksum compares the digest of a downloaded file with the checksum the
+// export recorded for it. A mismatch is an error unless the mode is warn or off.
+func (p *BaseProvider) checkChecksum(logger *zap.Logger, repository, packageName, version, filename, digest string) error {
+	mode, _ := ChecksumMode()
+	if mode == ChecksumsOff {
+		return nil
+	}
+	expected, ok := p.expectedChecksum(repository, packageName, version, filename)
+	if !ok || SameChecksum(expected, digest) {
+		return nil
+	}
+	err := fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filename, expected, digest)
+	if mode == ChecksumsWarn {
+		logger.Warn("Checksum mismatch", zap.String("filename", filename), zap.Error(err))
+		return nil
+	}
+	return err
+}
+
+// checkExisting checks a file left by an earlier run against the exported checksum
+func (p *BaseProvider) checkExisting(logger *zap.Logger, repository, packageName, version, filename, outputPath string) error {
+	digest, err := utils.FileDigest(outputPath)
+	if err != nil {
+		return nil
+	}
+	return p.checkChecksum(logger, repository, packageName, version, filename, digest)
+}
+
+// sourceFile returns the file GitHub reports for a source package version
+func (p *BaseProvider) sourceFile(logger *zap.Logger, owner, packageName, version, filename string) (FileNode, error) {
+	if p.checksums == nil {
+		return FileNode{}, nil
+	}
+	p.checksums.once.Do(func() {
+		var nodes []PackageNode
+		nodes, _, p.checksums.err = FetchFromGraphQL(logger, owner, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", p.PackageType), p.PackageType)
+		p.checksums.files = indexFiles(nodes)
+	})
+	return p.checksums.files[packageName+"|"+version+"|"+filename], p.checksums.err
+}
+
+// FileChecksum returns the SHA-256 GitHub reports for a source file, empty when unknown
+func (p *BaseProvider) FileChecksum(logger *zap.Logger, owner, packageName, version, filename string) (string, error) {
+	file, err := p.sourceFile(logger, owner, packageName, version, filename)
+	return string(file.Sha256), err
+}
+
+// FileSize returns the size GitHub reports for a source file, 0 when unknown
+func (p *BaseProvider) FileSize(logger *zap.Logger, owner, repository, packageName, version, filename string) (int64, error) {
+	file, err := p.sourceFile(logger, owner, packageName, version, filename)
+	return int64(file.Size), err
+}
+
+// sourceFile reuses the package files fetched for the export instead of
+// crawling the GraphQL API a second time
+func (p *MavenProvider) sourceFile(logger *zap.Logger, owner, packageName, version, filename string) (FileNode, error) {
+	p.packageFilesMu.Lock()
+	if p.packageFileIndex == nil && len(p.packageFiles) > 0 {
+		p.packageFileIndex = indexFiles(p.packageFiles)
+	}
+	files := p.packageFileIndex
+	p.packageFilesMu.Unlock()
+	if files == nil {
+		return p.BaseProvider.sourceFile(logger, owner, packageName, version, filename)
+	}
+	return files[packageName+"|"+version+"|"+filename], nil
+}
+
+// FileChecksum returns the SHA-256 of a maven file from the exported package files
+func (p *MavenProvider) FileChecksum(logger *zap.Logger, owner, packageName, version, filename string) (string, error) {
+	file, err := p.sourceFile(logger, owner, packageName, version, filename)
+	return string(file.Sha256), err
+}
+
+// FileSize returns the size of a maven file from the exported package files
+func (p *MavenProvider) FileSize(logger *zap.Logger, owner, repository, packageName, version, filename string) (int64, error) {
+	file, err := p.sourceFile(logger, owner, packageName, version, filename)
+	return int64(file.Size), err
+}
+
+// FileChecksum returns nothing for container images, their version is the digest of the manifest
+func (p *ContainerProvider) FileChecksum(logger *zap.Logger, owner, packageName, version, filename string) (string, error) {
+	return "", nil
+}
+
+func indexFiles(nodes []PackageNode) map[string]FileNode {
+	files := make(map[string]FileNode)
+	for _, pkg := range nodes {
+		for _, version := range pkg.Versions.Nodes {
+			for _, file := range version.Files.Nodes {
+				files[string(pkg.Name)+"|"+string(version.Version)+"|"+string(file.Name)] = file
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,39 @@
+package providers_test
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+)
+
+func TestSameChecksum(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"sha256:9F86D081", "9f86d081", true},
+		{"9f86d081", "sha256:9f86d081", true},
+		{"sha256:9f86d081", "sha256:60303ae2", false},
+	}
+	for _, test := range tests {
+		if got := providers.SameChecksum(test.a, test.b); got != test.want {
+			t.Errorf("SameChecksum(%s, %s) = %t, want %t", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestChecksumMode(t *testing.T) {
+	defer viper.Set("GHMPKG_VERIFY_CHECKSUMS", "")
+
+	for value, want := range map[string]string{"": providers.ChecksumsFail, "Warn": providers.ChecksumsWarn, "off": providers.ChecksumsOff} {
+		viper.Set("GHMPKG_VERIFY_CHECKSUMS", value)
+		if mode, err := providers.ChecksumMode(); err != nil || mode != want {
+			t.Errorf("ChecksumMode(%q) = %s, %v, want %s", value, mode, err, want)
+		}
+	}
+	viper.Set("GHMPKG_VERIFY_CHECKSUMS", "strict")
+	if _, err := providers.ChecksumMode(); err == nil {
+		t.Error("ChecksumMode accepted an unknown mode")
+	}
+}
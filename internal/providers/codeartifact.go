@@ -0,0 +1,261 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/credentials"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// CODEARTIFACT_PACKAGE_TYPES are the package types that can be published to CodeArtifact
+var CODEARTIFACT_PACKAGE_TYPES = []string{"maven", "npm", "nuget"}
+
+// codeArtifactTokenMargin is how long before its expiration a token is replaced
+const codeArtifactTokenMargin = 5 * time.Minute
+
+// CodeArtifactLocation names the CodeArtifact repository packages are published to
+func CodeArtifactLocation() string {
+	return fmt.Sprintf("codeartifact://%s/%s", viper.GetString("GHMPKG_CODEARTIFACT_DOMAIN"), viper.GetString("GHMPKG_CODEARTIFACT_REPOSITORY"))
+}
+
+// checkCodeArtifact checks the AWS credentials CodeArtifact is called with are set
+func checkCodeArtifact() error {
+	if _, _, err := credentials.AWSFromEnv(); err != nil {
+		return fmt.Errorf("codeartifact: %w", err)
+	}
+	return nil
+}
+
+// codeArtifactAuth hands out the authorization token of a CodeArtifact domain.
+// The token is fetched with the AWS credentials of the environment, or those
+// of GHMPKG_CODEARTIFACT_ROLE_ARN assumed through STS, and fetched again, the
+// role assumed again, shortly before it expires.
+type codeArtifactAuth struct {
+	mu          sync.Mutex
+	credentials credentials.AWSCredentials
+	region      string
+	roleArn     string
+	domain      string
+	owner       string
+	token       string
+	expires     time.Time
+}
+
+func newCodeArtifactAuth() (*codeArtifactAuth, error) {
+	awsCredentials, region, err := credentials.AWSFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("codeartifact: %w", err)
+	}
+	return &codeArtifactAuth{
+		credentials: awsCredentials,
+		region:      region,
+		roleArn:     viper.GetString("GHMPKG_CODEARTIFACT_ROLE_ARN"),
+		domain:      viper.GetString("GHMPKG_CODEARTIFACT_DOMAIN"),
+		owner:       viper.GetString("GHMPKG_CODEARTIFACT_DOMAIN_OWNER"),
+	}, nil
+}
+
+// call performs a signed request to the CodeArtifact API and decodes its JSON response
+func (a *codeArtifactAuth) call(awsCredentials credentials.AWSCredentials, method, path string, query url.Values, out interface{}) error {
+	query.Set("domain", a.domain)
+	if a.owner != "" {
+		query.Set("domain-owner", a.owner)
+	}
+	req, err := http.NewRequest(method, credentials.AWSEndpoint("codeartifact", a.region)+path+"?"+strings.ReplaceAll(query.Encode(), "+", "%20"), nil)
+	if err != nil {
+		return err
+	}
+	emptyHash := sha256.Sum256(nil)
+	credentials.SignV4(req, hex.EncodeToString(emptyHash[:]), awsCredentials, a.region, "codeartifact", time.Now())
+
+	resp, err := utils.NewHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("codeartifact returned %s for %s: %s", resp.Status, path, strings.TrimSpace(string(content)))
+	}
+	return json.Unmarshal(content, out)
+}
+
+// signingCredentials returns the credentials of the role, when one is set
+func (a *codeArtifactAuth) signingCredentials() (credentials.AWSCredentials, error) {
+	if a.roleArn == "" {
+		return a.credentials, nil
+	}
+	return credentials.AssumeRole(a.credentials, a.region, a.roleArn, "gh-migrate-packages")
+}
+
+// Token returns an authorization token valid for a few more minutes at least
+func (a *codeArtifactAuth) Token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Until(a.expires) > codeArtifactTokenMargin {
+		return a.token, nil
+	}
+
+	awsCredentials, err := a.signingCredentials()
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		AuthorizationToken string  `json:"authorizationToken"`
+		Expiration         float64 `json:"expiration"`
+	}
+	if err := a.call(awsCredentials, http.MethodPost, "/v1/authorization-token", url.Values{}, &result); err != nil {
+		return "", fmt.Errorf("failed to get the authorization token of %s: %w", a.domain, err)
+	}
+	a.token = result.AuthorizationToken
+	a.expires = time.Unix(int64(result.Expiration), 0)
+	return a.token, nil
+}
+
+// repositoryEndpoint returns the URL of a repository for a package format
+func (a *codeArtifactAuth) repositoryEndpoint(repository, format string) (*url.URL, error) {
+	awsCredentials, err := a.signingCredentials()
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		RepositoryEndpoint string `json:"repositoryEndpoint"`
+	}
+	query := url.Values{"repository": {repository}, "format": {format}}
+	if err := a.call(awsCredentials, http.MethodGet, "/v1/repository/endpoint", query, &result); err != nil {
+		return nil, fmt.Errorf("failed to get the %s endpoint of %s: %w", format, repository, err)
+	}
+	return utils.ParseUrl(result.RepositoryEndpoint), nil
+}
+
+// CodeArtifactProvider publishes the packages pulled from GitHub to an AWS
+// CodeArtifact repository, which hosts every package type
+type CodeArtifactProvider struct {
+	Provider
+	base     BaseProvider
+	feed     feedPublisher
+	auth     *codeArtifactAuth
+	endpoint *url.URL
+}
+
+func newCodeArtifactProvider(provider Provider) *CodeArtifactProvider {
+	return &CodeArtifactProvider{
+		Provider: provider,
+		base:     NewBaseProvider(provider.GetPackageType(), "", "", false),
+	}
+}
+
+// Connect connects the wrapped provider and looks up the repository endpoint
+// of the package type
+func (p *CodeArtifactProvider) Connect(logger *zap.Logger) error {
+	if err := p.Provider.Connect(logger); err != nil {
+		return err
+	}
+	repository := viper.GetString("GHMPKG_CODEARTIFACT_REPOSITORY")
+	if viper.GetString("GHMPKG_CODEARTIFACT_DOMAIN") == "" || repository == "" {
+		return fmt.Errorf("GHMPKG_CODEARTIFACT_DOMAIN and GHMPKG_CODEARTIFACT_REPOSITORY are required to publish to codeartifact")
+	}
+	auth, err := newCodeArtifactAuth()
+	if err != nil {
+		return err
+	}
+	p.auth = auth
+	p.feed = feedPublisher{registry: "codeartifact", authorize: p.authorize}
+	if !utils.Contains(CODEARTIFACT_PACKAGE_TYPES, p.base.PackageType) {
+		return nil
+	}
+	if p.endpoint, err = auth.repositoryEndpoint(repository, p.base.PackageType); err != nil {
+		return err
+	}
+	logger.Info("CodeArtifact repository endpoint", zap.String("endpoint", p.endpoint.String()))
+	return nil
+}
+
+// authorize adds the authorization token, as npm expects it for npm and as
+// the user aws for the other formats
+func (p *CodeArtifactProvider) authorize(req *http.Request) error {
+	token, err := p.auth.Token()
+	if err != nil {
+		return err
+	}
+	if p.base.PackageType == "npm" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.SetBasicAuth("aws", token)
+	}
+	return nil
+}
+
+// GetUploadUrl returns the URL a file is published to: the file itself for
+// Maven, the package for npm and the push endpoint for NuGet
+func (p *CodeArtifactProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	if p.endpoint == nil {
+		return "", fmt.Errorf("%s packages cannot be published to codeartifact", p.base.PackageType)
+	}
+	var uploadUrl url.URL
+	switch p.base.PackageType {
+	case "maven":
+		// GitHub names Maven packages groupId.artifactId
+		uploadUrl = utils.JoinUrlPath(*p.endpoint, append(strings.Split(packageName, "."), version, filename)...)
+	case "npm":
+		scope := "@" + strings.ToLower(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
+		uploadUrl = utils.JoinUrlPath(*p.endpoint, scope+"/"+packageName)
+	case "nuget":
+		uploadUrl = utils.JoinUrlPath(*p.endpoint, "v2", "package")
+	}
+	return uploadUrl.String(), nil
+}
+
+// Upload publishes a pulled file to the repository
+func (p *CodeArtifactProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	if !utils.Contains(CODEARTIFACT_PACKAGE_TYPES, packageType) {
+		return Skip(SkipTargetUnsupported, fmt.Sprintf("%s packages cannot be published to codeartifact", packageType))
+	}
+
+	return p.base.uploadPackage(
+		logger, owner, repository, packageType, packageName, version, filename,
+		func() (string, error) {
+			return p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
+		},
+		func(uploadUrl, packageDir string) (ResultState, error) {
+			localFile := filepath.Join(packageDir, filename)
+			if packageType == "npm" {
+				localFile = stagedPath(packageDir, npmTarballName(packageName, version), filename)
+			}
+			content, err := os.ReadFile(localFile)
+			if err != nil {
+				return Failed, err
+			}
+
+			var result ResultState
+			switch packageType {
+			case "maven":
+				result, err = p.feed.deployMaven(logger, uploadUrl, content)
+			case "npm":
+				result, err = p.feed.publishNpm(logger, *p.endpoint, content)
+			case "nuget":
+				result, err = p.feed.pushNuget(logger, uploadUrl, filepath.Base(localFile), content)
+			}
+			if result == Success {
+				p.base.recordTargetFile(logger, repository, packageName, version, filename, localFile)
+			}
+			return result, err
+		},
+	)
+}
@@ -0,0 +1,107 @@
+package providers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestCodeArtifactPublish(t *testing.T) {
+	defer viper.Reset()
+	migrationPath := t.TempDir()
+	writePulled(t, migrationPath, "maven", "com.example.app", "1.0", "app-1.0.jar", "jar")
+	writePulled(t, migrationPath, "npm", "app", "1.0.0", "app-1.0.0.tgz", npmTarball(t, `{"name": "@mona/app", "version": "1.0.0"}`))
+
+	tokens := 0
+	registry := &fakeRegistry{
+		authorized: func(r *http.Request) bool {
+			if r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, "/v1/") {
+				// AWS APIs are signed rather than authorized with a token
+				return true
+			}
+			_, basic, _ := r.BasicAuth()
+			return strings.HasPrefix(basic, "token-") || strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-")
+		},
+	}
+	var server *httptest.Server
+	registry.handle = func(w http.ResponseWriter, r *http.Request) bool {
+		switch {
+		case r.URL.Path == "/" && r.Method == http.MethodPost:
+			// STS, called with the credentials of the environment
+			if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") {
+				w.WriteHeader(http.StatusForbidden)
+				return true
+			}
+			w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>ASIATEMP</AccessKeyId><SecretAccessKey>s</SecretAccessKey><SessionToken>t</SessionToken></Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+		case strings.HasPrefix(r.URL.Path, "/v1/"):
+			// CodeArtifact, called with the credentials of the role
+			if !strings.Contains(r.Header.Get("Authorization"), "Credential=ASIATEMP/") || r.URL.Query().Get("domain") != "acme" || r.URL.Query().Get("domain-owner") != "111122223333" {
+				w.WriteHeader(http.StatusForbidden)
+				return true
+			}
+			if r.URL.Path == "/v1/authorization-token" {
+				tokens++
+				// Expiring within the margin, the next request fetches another one
+				json.NewEncoder(w).Encode(map[string]interface{}{"authorizationToken": fmt.Sprintf("token-%d", tokens), "expiration": time.Now().Add(time.Minute).Unix()})
+				return true
+			}
+			json.NewEncoder(w).Encode(map[string]string{"repositoryEndpoint": fmt.Sprintf("%s/%s/%s/", server.URL, r.URL.Query().Get("format"), r.URL.Query().Get("repository"))})
+		default:
+			return false
+		}
+		return true
+	}
+	server = registry.start(t)
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	viper.Set("GHMPKG_MIGRATION_PATH", migrationPath)
+	targetSettings("codeartifact", map[string]interface{}{
+		"GHMPKG_CODEARTIFACT_DOMAIN":       "acme",
+		"GHMPKG_CODEARTIFACT_DOMAIN_OWNER": "111122223333",
+		"GHMPKG_CODEARTIFACT_REPOSITORY":   "packages",
+		"GHMPKG_CODEARTIFACT_ROLE_ARN":     "arn:aws:iam::111122223333:role/migration",
+	})
+
+	if err := providers.CheckTargetRegistry(); err != nil {
+		t.Fatal(err)
+	}
+	if got := providers.TargetUrl(); got != "codeartifact://acme/packages" {
+		t.Errorf("TargetUrl = %s", got)
+	}
+
+	maven := connectTarget(t, "maven")
+	uploadUrl, _ := maven.GetUploadUrl(zap.NewNop(), "mona", "repo", "com.example.app", "1.0", "app-1.0.jar")
+	if uploadUrl != server.URL+"/maven/packages/com/example/app/1.0/app-1.0.jar" {
+		t.Errorf("maven upload URL = %s", uploadUrl)
+	}
+	if result, err := maven.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar"); err != nil || result != providers.Success {
+		t.Fatalf("maven Upload = %v, %v", result, err)
+	}
+	if content, _ := registry.get("/maven/packages/com/example/app/1.0/app-1.0.jar"); content != "jar" {
+		t.Errorf("published = %v", registry.stored)
+	}
+
+	npm := connectTarget(t, "npm")
+	if result, err := npm.Upload(zap.NewNop(), "mona", "repo", "npm", "app", "1.0.0", "app-1.0.0.tgz"); err != nil || result != providers.Success {
+		t.Fatalf("npm Upload = %v, %v", result, err)
+	}
+	if _, ok := registry.get("/npm/packages/@mona%2Fapp"); !ok {
+		t.Errorf("published = %v", registry.stored)
+	}
+	// Every token expires within the margin, each request got a new one
+	if tokens < 3 {
+		t.Errorf("%d tokens requested, want one per request", tokens)
+	}
+}
@@ -9,9 +9,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/ledger"
 	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/pterm/pterm"
 	"github.com/shurcooL/githubv4"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -22,6 +26,7 @@ type DownloadCallback func(string, string) error
 
 var providerLookup = map[string]func(*zap.Logger, string) Provider{
 	"container": NewContainerProvider,
+	"docker":    NewDockerProvider,
 	"maven":     NewMavenProvider,
 	"npm":       NewNPMProvider,
 	"rubygems":  NewRubyGemsProvider,
@@ -32,12 +37,64 @@ func NewProvider(logger *zap.Logger, packageType string) (Provider, error) {
 	if providerFunc, ok := providerLookup[packageType]; !ok {
 		return nil, errors.New(fmt.Sprintf("provider not found: %s", packageType))
 	} else {
-		return providerFunc(logger, packageType), nil
+		provider := providerFunc(logger, packageType)
+		if utils.ReadOnly() {
+			return newReadOnlyProvider(provider), nil
+		}
+		return wrapTarget(provider), nil
 	}
 }
 
+// ProviderSet hands out one connected provider per package type. Providers are
+// shared by every worker of a run, so their implementations must be safe for
+// concurrent use.
+type ProviderSet struct {
+	mu      sync.Mutex
+	entries map[string]*providerEntry
+}
+
+type providerEntry struct {
+	once     sync.Once
+	provider Provider
+	err      error
+}
+
+func NewProviderSet() *ProviderSet {
+	return &ProviderSet{entries: make(map[string]*providerEntry)}
+}
+
+// Get returns the provider for a package type, creating and connecting it on first use
+func (s *ProviderSet) Get(logger *zap.Logger, packageType string) (Provider, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[packageType]
+	if !ok {
+		entry = &providerEntry{}
+		s.entries[packageType] = entry
+	}
+	s.mu.Unlock()
+
+	entry.once.Do(func() {
+		logger.Info("Creating provider", zap.String("packageType", packageType))
+		provider, err := NewProvider(logger, packageType)
+		if err != nil {
+			entry.err = err
+			return
+		}
+		if provider == nil {
+			entry.err = fmt.Errorf("provider is nil")
+			return
+		}
+		if err := provider.Connect(logger); err != nil {
+			entry.err = err
+			return
+		}
+		entry.provider = provider
+	})
+	return entry.provider, entry.err
+}
+
 func newHTTPClient(proxyURL string) (*http.Client, error) {
-	transport := &http.Transport{}
+	transport := utils.NewTransport()
 	if proxyURL != "" {
 		proxy, err := url.Parse(proxyURL)
 		if err != nil {
@@ -45,7 +102,7 @@ func newHTTPClient(proxyURL string) (*http.Client, error) {
 		}
 		transport.Proxy = http.ProxyURL(proxy)
 	}
-	return &http.Client{Transport: transport}, nil
+	return &http.Client{Transport: utils.GuardTransport(transport)}, nil
 }
 
 func FetchFromGraphQL(logger *zap.Logger, owner, token, packageType string) ([]PackageNode, ResultState, error) {
@@ -67,98 +124,136 @@ func FetchFromGraphQL(logger *zap.Logger, owner, token, packageType string) ([]P
 	oauth2Client := oauth2.NewClient(oauth2Ctx, tokenSource)
 	client := githubv4.NewClient(oauth2Client)
 	ctx := context.WithValue(context.Background(), github.SleepUntilPrimaryRateLimitResetWhenRateLimited, true)
+	isUser, err := api.IsUser(token, owner)
+	if err != nil {
+		return nil, Failed, err
+	}
 
+	// Pages shrink when GitHub times out resolving them, and stay small for the rest of the crawl
+	packagesFirst := GRAPHQL_PAGE_SIZE
 	for {
-		var query Query
-		variables := map[string]interface{}{
-			"owner":         githubv4.String(owner),
-			"packageType":   githubv4.PackageType(strings.ToUpper(packageType)),
-			"packagesFirst": githubv4.Int(10),
-			"packagesAfter": packagesAfter,
-			"versionsFirst": githubv4.Int(10),
-			"versionsAfter": (*githubv4.String)(nil),
-			"filesFirst":    githubv4.Int(10),
-			"filesAfter":    (*githubv4.String)(nil),
-		}
-
-		err := client.Query(ctx, &query, variables)
+		var packages PackagesNode
+		packagesFirst, err = QueryInChunks(logger, packagesFirst, func(first int) error {
+			variables := map[string]interface{}{
+				"owner":         githubv4.String(owner),
+				"packageType":   githubv4.PackageType(strings.ToUpper(packageType)),
+				"packagesFirst": githubv4.Int(first),
+				"packagesAfter": packagesAfter,
+				"versionsFirst": githubv4.Int(first),
+				"versionsAfter": (*githubv4.String)(nil),
+				"filesFirst":    githubv4.Int(first),
+				"filesAfter":    (*githubv4.String)(nil),
+			}
+			if isUser {
+				var query UserQuery
+				err := client.Query(ctx, &query, variables)
+				packages = query.User.Packages
+				return err
+			}
+			var query Query
+			err := client.Query(ctx, &query, variables)
+			packages = query.Organization.Packages
+			return err
+		})
 		if err != nil {
 			return nil, Failed, fmt.Errorf("error querying packages: %w", err)
 		}
 
-		for _, pkg := range query.Organization.Packages.Nodes {
+		for _, pkg := range packages.Nodes {
 
 			// Skip deleted packages
 			if strings.HasPrefix(string(pkg.Name), "deleted_") {
 				continue
 			}
 
-			var allVersions []VersionNode
-			versionsAfter := (*githubv4.String)(nil)
-
-			for {
-				versionVariables := map[string]interface{}{
-					"packageID":     githubv4.ID(pkg.ID),
-					"versionsFirst": githubv4.Int(10),
-					"versionsAfter": versionsAfter,
-					"filesFirst":    githubv4.Int(10),
-					"filesAfter":    (*githubv4.String)(nil),
+			allVersions, err := fetchVersionsFromGraphQL(logger, ctx, client, pkg)
+			if err != nil {
+				if !IsGraphQLTimeout(err) {
+					return nil, Failed, err
 				}
+				// The other packages are still exported, this one is listed for a manual export
+				logger.Error("Package files could not be listed, the package needs a manual export",
+					zap.String("package", string(pkg.Name)),
+					zap.Error(err))
+				pterm.Warning.Printf("⚠️  %s timed out even one version at a time, it needs a manual export\n", pkg.Name)
+				RecordManualExport(packageType, string(pkg.Name), err)
+				continue
+			}
+
+			pkg.Versions.Nodes = allVersions
+			allPackages = append(allPackages, pkg)
+		}
+
+		if !packages.PageInfo.HasNextPage {
+			break
+		}
+		packagesAfter = &packages.PageInfo.EndCursor
+	}
 
-				var versionQuery VersionQuery
+	// Cache(fmt.Sprintf("%s-%s-packages.json", owner, strings.ToLower(packageType)), allPackages)
+	return allPackages, Success, nil
+}
 
-				err := client.Query(ctx, &versionQuery, versionVariables)
+// fetchVersionsFromGraphQL lists the versions of a package with their files
+func fetchVersionsFromGraphQL(logger *zap.Logger, ctx context.Context, client *githubv4.Client, pkg PackageNode) ([]VersionNode, error) {
+	var allVersions []VersionNode
+	versionsAfter := (*githubv4.String)(nil)
+	versionsFirst := GRAPHQL_PAGE_SIZE
+	filesFirst := GRAPHQL_PAGE_SIZE
+
+	for {
+		var versionQuery VersionQuery
+		var err error
+		versionsFirst, err = QueryInChunks(logger, versionsFirst, func(first int) error {
+			versionQuery = VersionQuery{}
+			return client.Query(ctx, &versionQuery, map[string]interface{}{
+				"packageID":     githubv4.ID(pkg.ID),
+				"versionsFirst": githubv4.Int(first),
+				"versionsAfter": versionsAfter,
+				"filesFirst":    githubv4.Int(first),
+				"filesAfter":    (*githubv4.String)(nil),
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error querying versions: %w", err)
+		}
+
+		for _, version := range versionQuery.Node.Package.Versions.Nodes {
+			var allFiles []FileNode
+			filesAfter := (*githubv4.String)(nil)
+
+			for {
+				var fileQuery FileQuery
+				filesFirst, err = QueryInChunks(logger, filesFirst, func(first int) error {
+					fileQuery = FileQuery{}
+					return client.Query(ctx, &fileQuery, map[string]interface{}{
+						"versionID":  githubv4.ID(version.ID),
+						"filesFirst": githubv4.Int(first),
+						"filesAfter": filesAfter,
+					})
+				})
 				if err != nil {
-					return nil, Failed, fmt.Errorf("error querying versions: %w", err)
+					return nil, fmt.Errorf("error querying files: %w", err)
 				}
 
-				for _, version := range versionQuery.Node.Package.Versions.Nodes {
-					var allFiles []FileNode
-					filesAfter := (*githubv4.String)(nil)
-
-					for {
-						fileVariables := map[string]interface{}{
-							"versionID":  githubv4.ID(version.ID),
-							"filesFirst": githubv4.Int(10),
-							"filesAfter": filesAfter,
-						}
-
-						var fileQuery FileQuery
-						err := client.Query(ctx, &fileQuery, fileVariables)
-						if err != nil {
-							return nil, Failed, fmt.Errorf("error querying files: %w", err)
-						}
-
-						allFiles = append(allFiles, fileQuery.Node.PackageVersion.Files.Nodes...)
-
-						if !fileQuery.Node.PackageVersion.Files.PageInfo.HasNextPage {
-							break
-						}
-						filesAfter = &fileQuery.Node.PackageVersion.Files.PageInfo.EndCursor
-					}
-
-					version.Files.Nodes = allFiles
-					allVersions = append(allVersions, version)
-				}
+				allFiles = append(allFiles, fileQuery.Node.PackageVersion.Files.Nodes...)
 
-				if !versionQuery.Node.Package.Versions.PageInfo.HasNextPage {
+				if !fileQuery.Node.PackageVersion.Files.PageInfo.HasNextPage {
 					break
 				}
-				versionsAfter = &versionQuery.Node.Package.Versions.PageInfo.EndCursor
+				filesAfter = &fileQuery.Node.PackageVersion.Files.PageInfo.EndCursor
 			}
 
-			pkg.Versions.Nodes = allVersions
-			allPackages = append(allPackages, pkg)
+			version.Files.Nodes = allFiles
+			allVersions = append(allVersions, version)
 		}
 
-		if !query.Organization.Packages.PageInfo.HasNextPage {
+		if !versionQuery.Node.Package.Versions.PageInfo.HasNextPage {
 			break
 		}
-		packagesAfter = &query.Organization.Packages.PageInfo.EndCursor
+		versionsAfter = &versionQuery.Node.Package.Versions.PageInfo.EndCursor
 	}
-
-	// Cache(fmt.Sprintf("%s-%s-packages.json", owner, strings.ToLower(packageType)), allPackages)
-	return allPackages, Success, nil
+	return allVersions, nil
 }
 
 func (p *BaseProvider) downloadPackage(
@@ -171,15 +266,30 @@ func (p *BaseProvider) downloadPackage(
 	if downloadedFilename == nil {
 		downloadedFilename = &filename
 	}
-	migrationPath := viper.GetString("GHMPKG_MIGRATION_PATH")
-	if migrationPath == "" {
-		migrationPath = "./migration-packages"
-	}
+	migrationPath := utils.MigrationPath()
 	outputPath := filepath.Join(migrationPath, "packages", owner, packageType, packageName, version, *downloadedFilename)
 
 	if utils.FileExists(outputPath) {
-		logger.Warn("File already exists", zap.String("outputPath", outputPath))
-		return Skipped, nil
+		// A file left corrupt by an earlier run is downloaded again
+		_, expected := p.expectedChecksum(repository, packageName, version, filename)
+		if IsImage(packageType) || !expected || p.checkExisting(logger, repository, packageName, version, filename, outputPath) == nil {
+			logger.Warn("File already exists", zap.String("outputPath", outputPath))
+			// A run interrupted before the file was stored stores it now
+			if !p.isStored(logger, outputPath) {
+				if err := p.storeOutput(logger, outputPath); err != nil {
+					return Failed, err
+				}
+			}
+			p.recordStaged(logger, filepath.Dir(outputPath), owner, repository, packageName, version, filename, *downloadedFilename)
+			return Skip(SkipAlreadyPulled, outputPath)
+		}
+		logger.Warn("Existing file does not match the exported checksum, downloading it again", zap.String("outputPath", outputPath))
+		os.Remove(outputPath)
+	}
+
+	if p.isStored(logger, outputPath) {
+		logger.Warn("File already stored", zap.String("outputPath", outputPath))
+		return Skip(SkipAlreadyPulled, outputPath)
 	}
 
 	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
@@ -199,20 +309,57 @@ func (p *BaseProvider) downloadPackage(
 		return Failed, err
 	}
 
+	// Download next to the final path and rename once complete, an interrupted
+	// download must not be taken for an existing file by the next run
+	partPath := outputPath + ".part"
+	os.RemoveAll(partPath)
+
 	logger.Info("Downloading file", zap.String("url", downloadUrl))
-	result, err := download(downloadUrl, outputPath)
+	result, err := download(downloadUrl, partPath)
+	if IsSkip(err) {
+		os.RemoveAll(partPath)
+		logger.Info("Skipped download", zap.String("outputPath", outputPath), zap.Error(err))
+		return Skipped, err
+	}
 	if err != nil {
+		os.RemoveAll(partPath)
 		logger.Error("Error downloading file",
 			zap.String("package", packageName),
 			zap.String("version", version),
 			zap.Error(err))
 		return Failed, err
 	}
+	if result == Success {
+		if err := os.Rename(partPath, outputPath); err != nil {
+			os.RemoveAll(partPath)
+			return Failed, fmt.Errorf("failed to move downloaded file into place: %w", err)
+		}
+	} else {
+		os.RemoveAll(partPath)
+	}
 
 	if result == Skipped {
 		logger.Info("File already exists", zap.String("outputPath", outputPath))
 	} else {
 		logger.Info("Successfully downloaded file", zap.String("outputPath", outputPath))
+		// Container images are recorded by their manifest digest instead
+		if !IsImage(packageType) {
+			if digest, err := utils.FileDigest(outputPath); err == nil {
+				if err := p.checkChecksum(logger, repository, packageName, version, filename, digest); err != nil {
+					os.Remove(outputPath)
+					logger.Error("Downloaded file does not match the exported checksum", zap.String("outputPath", outputPath), zap.Error(err))
+					return Failed, err
+				}
+				p.recordSourceDigest(logger, repository, packageName, version, filename, digest)
+			} else {
+				logger.Warn("Failed to compute digest of downloaded file", zap.String("outputPath", outputPath), zap.Error(err))
+			}
+		}
+		if err := p.storeOutput(logger, outputPath); err != nil {
+			logger.Error("Failed to store downloaded file", zap.String("outputPath", outputPath), zap.Error(err))
+			return Failed, err
+		}
+		p.recordStaged(logger, filepath.Dir(outputPath), owner, repository, packageName, version, filename, *downloadedFilename)
 	}
 	return result, nil
 }
@@ -223,22 +370,17 @@ func (p *BaseProvider) uploadPackage(
 	getUrl func() (string, error),
 	upload func(string, string) (ResultState, error),
 ) (ResultState, error) {
-	migrationPath := viper.GetString("GHMPKG_MIGRATION_PATH")
-	if migrationPath == "" {
-		migrationPath = "./migration-packages"
-	}
-	var packageDir string
-	if packageType == "container" {
-		parts := strings.Split(filename, ":")
-		tag := parts[1]
-		packageDir = filepath.Join(migrationPath, "packages", viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), packageType, packageName, tag)
-	} else {
-		packageDir = filepath.Join(migrationPath, "packages", viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), packageType, packageName, version)
-	}
+	migrationPath := utils.MigrationPath()
+	packageDir := stagedDir(migrationPath, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), packageType, packageName, version, filename)
 
 	if !utils.FileExists(packageDir) {
-		logger.Warn("Package directory does not exist", zap.String("packageDir", packageDir))
-		return Skipped, nil
+		if fetched, err := p.fetchStored(logger, packageDir); err != nil {
+			logger.Error("Failed to fetch stored files", zap.String("packageDir", packageDir), zap.Error(err))
+			return Failed, err
+		} else if !fetched {
+			logger.Warn("Package directory does not exist", zap.String("packageDir", packageDir))
+			return Skip(SkipLocalFilesMissing, fmt.Sprintf("%s not found, was the package pulled?", packageDir))
+		}
 	}
 
 	uploadUrl, err := getUrl()
@@ -248,36 +390,94 @@ func (p *BaseProvider) uploadPackage(
 	}
 
 	logger.Info("Uploading file", zap.String("url", uploadUrl))
-	var result ResultState
-	if result, err = upload(uploadUrl, packageDir); err != nil {
+	result, err := upload(uploadUrl, packageDir)
+	if IsSkip(err) {
+		logger.Warn("Skipped upload", zap.String("packagePath", packageDir), zap.Error(err))
+		return Skipped, err
+	}
+	if err != nil {
 		logger.Error("Error uploading file", zap.Error(err))
 		return Failed, err
 	}
-
 	if result == Skipped {
-		logger.Warn("File already exists", zap.String("packagePath", packageDir))
-	} else {
-		logger.Info("Successfully uploaded file", zap.String("packageDir", packageDir))
+		// Upload functions only skip files the target already has
+		return Skip(SkipExistsOnTarget, filename)
 	}
+
+	logger.Info("Successfully uploaded file", zap.String("packageDir", packageDir))
 	return Success, nil
 }
 
+// recordSourceDigest adds the digest of a pulled file to the checksum ledger. The
+// ledger must never stop a migration, failures are only logged.
+func (p *BaseProvider) recordSourceDigest(logger *zap.Logger, repository, packageName, version, filename, digest string) {
+	store, err := ledger.Load(utils.MigrationPath())
+	if err == nil {
+		err = store.RecordSource(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, p.PackageType, packageName, version, filename, digest)
+	}
+	if err != nil {
+		logger.Warn("Failed to record source digest in ledger", zap.String("filename", filename), zap.Error(err))
+	}
+}
+
+// recordTargetDigest adds the digest of an uploaded file, after any rewrite, to the checksum ledger
+func (p *BaseProvider) recordTargetDigest(logger *zap.Logger, repository, packageName, version, filename, digest string) {
+	store, err := ledger.Load(utils.MigrationPath())
+	if err == nil {
+		err = store.RecordTarget(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, p.PackageType, packageName, version, filename, digest)
+	}
+	if err != nil {
+		logger.Warn("Failed to record target digest in ledger", zap.String("filename", filename), zap.Error(err))
+	}
+}
+
+// recordTargetFile records the digest of the file that was uploaded
+func (p *BaseProvider) recordTargetFile(logger *zap.Logger, repository, packageName, version, filename, uploadedPath string) {
+	digest, err := utils.FileDigest(uploadedPath)
+	if err != nil {
+		logger.Warn("Failed to compute digest of uploaded file", zap.String("path", uploadedPath), zap.Error(err))
+		return
+	}
+	p.recordTargetDigest(logger, repository, packageName, version, filename, digest)
+}
+
+// removeWorkFiles deletes scratch artifacts (extracted archives, CLI logs) created
+// while uploading a version. Nothing is removed when GHMPKG_KEEP_WORK_FILES is set,
+// the return value reports whether the files were removed.
+func (p *BaseProvider) removeWorkFiles(logger *zap.Logger, paths ...string) bool {
+	if viper.GetBool("GHMPKG_KEEP_WORK_FILES") {
+		return false
+	}
+	for _, workPath := range paths {
+		if err := os.RemoveAll(workPath); err != nil {
+			logger.Warn("Failed to remove work file", zap.String("path", workPath), zap.Error(err))
+		}
+	}
+	return true
+}
+
 // NewBaseProvider creates a new BaseProvider with common initialization logic
 func NewBaseProvider(packageType, sourceHostname, targetHostname string, isContainer bool) BaseProvider {
+	// Registries of a package type can live on their own host, the hostnames
+	// of the sides are read from the settings unless given
 	if sourceHostname == "" {
-		sourceHostname = "github.com"
+		sourceHostname = sideHostname("SOURCE", packageType)
+	} else {
+		sourceHostname = bareHostname(sourceHostname)
 	}
 	if targetHostname == "" {
-		targetHostname = "github.com"
+		targetHostname = sideHostname("TARGET", packageType)
+	} else {
+		targetHostname = bareHostname(targetHostname)
 	}
 
 	var sourceRegistryUrl, targetRegistryUrl string
 	if isContainer {
-		sourceRegistryUrl = "ghcr.io"
-		targetRegistryUrl = "ghcr.io"
+		sourceRegistryUrl = containerRegistry("SOURCE", packageType).Host
+		targetRegistryUrl = containerRegistry("TARGET", packageType).Host
 	} else {
-		sourceRegistryUrl = fmt.Sprintf("https://%s.pkg.%s/", packageType, sourceHostname)
-		targetRegistryUrl = fmt.Sprintf("https://%s.pkg.%s/", packageType, targetHostname)
+		sourceRegistryUrl = packageRegistryUrl(sourceHostname, packageType, subdomainIsolation("SOURCE"))
+		targetRegistryUrl = packageRegistryUrl(targetHostname, packageType, subdomainIsolation("TARGET"))
 	}
 
 	return BaseProvider{
@@ -286,14 +486,90 @@ func NewBaseProvider(packageType, sourceHostname, targetHostname string, isConta
 		TargetRegistryUrl: utils.ParseUrl(targetRegistryUrl),
 		SourceHostnameUrl: utils.ParseUrl(fmt.Sprintf("https://%s/", sourceHostname)),
 		TargetHostnameUrl: utils.ParseUrl(fmt.Sprintf("https://%s/", targetHostname)),
+		checksums:         &checksumCache{},
+	}
+}
+
+// nameReusedMessages are fragments of the errors the registries return when a
+// deleted package name is published again
+var nameReusedMessages = []string{
+	"previously deleted",
+	"was deleted",
+	"name is reserved",
+	"cannot be reused",
+	"name has already been used",
+}
+
+// CheckNameReused wraps err in a NameReusedError when it reports a deleted package
+// name being reused, other errors are returned unchanged
+func CheckNameReused(packageName string, err error) error {
+	var reused *NameReusedError
+	if err == nil || errors.As(err, &reused) {
+		return err
+	}
+	message := strings.ToLower(err.Error())
+	for _, fragment := range nameReusedMessages {
+		if strings.Contains(message, fragment) && !namesOtherPackage(message, packageName) {
+			return &NameReusedError{PackageName: packageName, Err: err}
+		}
+	}
+	return err
+}
+
+// namesOtherPackage reports whether a message names another package whose
+// name contains packageName, e.g. foo-bar for foo, the error is then not about
+// the package published. Names are compared exactly and case-insensitively,
+// without a type or scope prefix such as npm/ or @mona/ and a version suffix
+// such as @1.0.0. A message naming no package is about the one published.
+func namesOtherPackage(message, packageName string) bool {
+	// Scoped npm packages are published as @scope/name
+	packageName = bareName(strings.ToLower(packageName))
+	for _, token := range strings.FieldsFunc(message, func(r rune) bool {
+		return strings.ContainsRune(" \t\n\"'`,;:()[]{}<>", r)
+	}) {
+		token = strings.TrimRight(token, ".")
+		if !strings.Contains(token, packageName) {
+			continue
+		}
+		if bareName(token) != packageName {
+			return true
+		}
+	}
+	return false
+}
+
+// bareName strips a type or scope prefix such as npm/ or @mona/ and a version
+// suffix such as @1.0.0 from a package name
+func bareName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(name, "@"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// conflictName returns the name to publish under when a deleted package name
+// cannot be reused, it is empty unless the conflict policy is rename
+func conflictName(name string) string {
+	if viper.GetString("GHMPKG_CONFLICT_POLICY") != "rename" {
+		return ""
+	}
+	suffix := viper.GetString("GHMPKG_RENAME_SUFFIX")
+	if suffix == "" {
+		suffix = "-migrated"
 	}
+	return name + suffix
 }
 
-// CheckOrganizationsMatch checks if source and target organizations are identical
+// CheckOrganizationsMatch checks if source and target organizations are
+// identical, leaving nothing to rewrite in the packages. A transfer rewrites
+// the repository of packages within their organization, it never matches.
 func (p *BaseProvider) CheckOrganizationsMatch(logger *zap.Logger) bool {
 	sourceOrg := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
 	targetOrg := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
-	if sourceOrg == targetOrg {
+	if sourceOrg == targetOrg && !Transferring() {
 		logger.Debug("Source and target organizations are identical",
 			zap.String("sourceOrg", sourceOrg),
 			zap.String("targetOrg", targetOrg))
@@ -0,0 +1,37 @@
+package providers_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+)
+
+func TestCheckNameReused(t *testing.T) {
+	for _, test := range []struct {
+		packageName string
+		message     string
+		reused      bool
+	}{
+		{"foo", "Package foo was previously deleted and cannot be reused", true},
+		{"foo", `package "npm/Foo" was deleted`, true},
+		{"foo", "@mona/foo@1.0.0: name has already been used", true},
+		{"foo", "this name is reserved", true},
+		// npm publishes scoped names
+		{"@mona/foo", "failed to publish package @mona/foo, status: 403, message: @mona/foo was previously deleted", true},
+		{"@mona/foo", "name is reserved", true},
+		{"@mona/foo", "failed to publish package @mona/foo, status: 403, message: @mona/foo-bar was deleted", false},
+		// Another package whose name contains the one published
+		{"foo", "Package foo-bar was previously deleted and cannot be reused", false},
+		{"foo", "npm/foo.bar was deleted", false},
+		{"foo", "status: 500, message: internal error", false},
+		// The errors of the providers name the package published first
+		{"foo", "failed to publish package foo, status: 403, message: foo-bar was deleted", false},
+	} {
+		err := providers.CheckNameReused(test.packageName, errors.New(test.message))
+		var reused *providers.NameReusedError
+		if errors.As(err, &reused) != test.reused {
+			t.Errorf("CheckNameReused(%s, %q) = %v, want reused %t", test.packageName, test.message, err, test.reused)
+		}
+	}
+}
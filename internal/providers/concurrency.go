@@ -0,0 +1,21 @@
+package providers
+
+import (
+	"strconv"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+// DefaultFileConcurrency is the number of files of a version transferred in
+// parallel when GHMPKG_FILE_CONCURRENCY is not set
+const DefaultFileConcurrency = 5
+
+// FileConcurrency returns how many files of a version of the package type are
+// downloaded or uploaded in parallel, GHMPKG_<TYPE>_FILE_CONCURRENCY falling
+// back to GHMPKG_FILE_CONCURRENCY
+func FileConcurrency(packageType string) int {
+	if n, err := strconv.Atoi(utils.GetPackageTypeString("GHMPKG_FILE_CONCURRENCY", packageType)); err == nil && n > 0 {
+		return n
+	}
+	return DefaultFileConcurrency
+}
@@ -0,0 +1,29 @@
+package providers_test
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+)
+
+func TestFileConcurrency(t *testing.T) {
+	defer viper.Reset()
+	if n := providers.FileConcurrency("maven"); n != providers.DefaultFileConcurrency {
+		t.Errorf("FileConcurrency without settings = %d, want %d", n, providers.DefaultFileConcurrency)
+	}
+
+	viper.Set("GHMPKG_FILE_CONCURRENCY", 3)
+	viper.Set("GHMPKG_MAVEN_FILE_CONCURRENCY", 10)
+	if n := providers.FileConcurrency("maven"); n != 10 {
+		t.Errorf("FileConcurrency(maven) = %d, want its own 10", n)
+	}
+	if n := providers.FileConcurrency("nuget"); n != 3 {
+		t.Errorf("FileConcurrency(nuget) = %d, want the global 3", n)
+	}
+
+	viper.Set("GHMPKG_FILE_CONCURRENCY", 0)
+	if n := providers.FileConcurrency("nuget"); n != providers.DefaultFileConcurrency {
+		t.Errorf("FileConcurrency with 0 = %d, want %d", n, providers.DefaultFileConcurrency)
+	}
+}
@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"go.uber.org/zap"
+)
+
+// Consumer is implemented by providers whose packages can be resolved from the
+// target registry with the client of their ecosystem, proving a migrated
+// version can be consumed and not only that its upload succeeded
+type Consumer interface {
+	// ConsumeCommand returns the command resolving a version from the target.
+	// Its configuration and credentials are written to the workspace, an empty
+	// directory removed once the command ran, which is also its home.
+	ConsumeCommand(logger *zap.Logger, workspace, owner, repository, packageName, version string, filenames []string) (*exec.Cmd, error)
+}
+
+// consumeCommand runs a client in the workspace, with the workspace as home so
+// the configuration of the operator is never read
+func consumeCommand(workspace string, env []string, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = workspace
+	cmd.Env = append(os.Environ(), "HOME="+workspace, "USERPROFILE="+workspace)
+	cmd.Env = append(cmd.Env, env...)
+	return cmd
+}
+
+// mavenCoordinates returns the group and artifact of a Maven version from the
+// name of its pom, GitHub names Maven packages groupId.artifactId
+func mavenCoordinates(packageName, version string, filenames []string) (string, string, error) {
+	for _, filename := range filenames {
+		artifact, ok := strings.CutSuffix(filename, "-"+version+".pom")
+		if !ok {
+			continue
+		}
+		if group, ok := strings.CutSuffix(packageName, "."+artifact); ok && group != "" {
+			return group, artifact, nil
+		}
+	}
+	return "", "", fmt.Errorf("no pom names the group and artifact of %s %s", packageName, version)
+}
+
+// ConsumeCommand resolves a Maven artifact with mvn dependency:get, into a
+// local repository in the workspace
+func (p *MavenProvider) ConsumeCommand(logger *zap.Logger, workspace, owner, repository, packageName, version string, filenames []string) (*exec.Cmd, error) {
+	group, artifact, err := mavenCoordinates(packageName, version, filenames)
+	if err != nil {
+		return nil, err
+	}
+	repositoryUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, owner, TargetRepository(repository))
+	settings := fmt.Sprintf("<settings><servers><server><id>github</id><username>%s</username><password>%s</password></server></servers></settings>\n",
+		html.EscapeString(owner), html.EscapeString(utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType)))
+	settingsPath := filepath.Join(workspace, "settings.xml")
+	if err := os.WriteFile(settingsPath, []byte(settings), 0600); err != nil {
+		return nil, err
+	}
+	return consumeCommand(workspace, nil, "mvn", "--batch-mode", "--quiet", "--settings", settingsPath,
+		"-Dmaven.repo.local="+filepath.Join(workspace, "repository"),
+		"dependency:get",
+		"-Dtransitive=false",
+		"-DremoteRepositories=github::default::"+repositoryUrl.String(),
+		fmt.Sprintf("-Dartifact=%s:%s:%s", group, artifact, version)), nil
+}
+
+// ConsumeCommand fetches an npm package with npm pack, through a user config in
+// the workspace pointing its scope at the target registry
+func (p *NPMProvider) ConsumeCommand(logger *zap.Logger, workspace, owner, repository, packageName, version string, filenames []string) (*exec.Cmd, error) {
+	name := p.targetName(owner, packageName)
+	scope, _, _ := strings.Cut(name, "/")
+	registryUrl := strings.TrimSuffix(p.TargetRegistryUrl.String(), "/") + "/"
+	npmrc := fmt.Sprintf("%s:registry=%s\n//%s:_authToken=%s\n",
+		scope, registryUrl, strings.TrimPrefix(registryUrl, p.TargetRegistryUrl.Scheme+"://"), utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType))
+	npmrcPath := filepath.Join(workspace, ".npmrc")
+	if err := os.WriteFile(npmrcPath, []byte(npmrc), 0600); err != nil {
+		return nil, err
+	}
+	return consumeCommand(workspace, nil, "npm", "pack", "--userconfig", npmrcPath, "--cache", filepath.Join(workspace, "cache"), name+"@"+version), nil
+}
+
+// ConsumeCommand fetches a gem with gem fetch, through a gemrc in the workspace
+// listing the target registry as its only source
+func (p *RubyGemsProvider) ConsumeCommand(logger *zap.Logger, workspace, owner, repository, packageName, version string, filenames []string) (*exec.Cmd, error) {
+	source := utils.JoinUrlPath(*p.TargetRegistryUrl, owner)
+	source.User = url.UserPassword(owner, utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType))
+	gemrcPath := filepath.Join(workspace, ".gemrc")
+	if err := os.WriteFile(gemrcPath, []byte(fmt.Sprintf(":sources:\n- %s/\n", strings.TrimSuffix(source.String(), "/"))), 0600); err != nil {
+		return nil, err
+	}
+	return consumeCommand(workspace, []string{"GEM_HOME=" + filepath.Join(workspace, "gems")},
+		"gem", "fetch", packageName, "--version", version, "--config-file", gemrcPath), nil
+}
+
+// ConsumeCommand inspects the manifest of an image with docker manifest
+// inspect, logged in through a Docker config in the workspace
+func (p *ContainerProvider) ConsumeCommand(logger *zap.Logger, workspace, owner, repository, packageName, version string, filenames []string) (*exec.Cmd, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("%s %s has no tag", packageName, version)
+	}
+	ref, err := p.GetUploadUrl(logger, owner, repository, packageName, version, filenames[0])
+	if err != nil {
+		return nil, err
+	}
+	auths := map[string]map[string]string{}
+	if p.target.HasCredentials() {
+		auths[p.target.LoginAddress()] = map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte(p.target.Username + ":" + p.target.Password))}
+	}
+	config, err := json.Marshal(map[string]interface{}{"auths": auths})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "config.json"), config, 0600); err != nil {
+		return nil, err
+	}
+	return consumeCommand(workspace, []string{"DOCKER_CONFIG=" + workspace}, "docker", "manifest", "inspect", ref), nil
+}
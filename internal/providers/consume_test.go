@@ -0,0 +1,54 @@
+package providers_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestConsumeCommand(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "mona-emu")
+	viper.Set("GHMPKG_TARGET_TOKEN", "ghp_target")
+	logger := zap.NewNop()
+
+	workspace := t.TempDir()
+	maven := providers.NewMavenProvider(logger, "maven").(providers.Consumer)
+	cmd, err := maven.ConsumeCommand(logger, workspace, "mona-emu", "app", "com.example.app", "1.0", []string{"app-1.0.jar", "app-1.0.pom"})
+	if err != nil {
+		t.Fatalf("maven ConsumeCommand = %v", err)
+	}
+	args := strings.Join(cmd.Args, " ")
+	if !strings.Contains(args, "-Dartifact=com.example:app:1.0") || !strings.Contains(args, "https://maven.pkg.github.com/mona-emu/app") || cmd.Dir != workspace {
+		t.Errorf("mvn command = %s in %s", args, cmd.Dir)
+	}
+	if settings, _ := os.ReadFile(filepath.Join(workspace, "settings.xml")); !strings.Contains(string(settings), "<password>ghp_target</password>") {
+		t.Errorf("settings.xml = %s", settings)
+	}
+	if _, err := maven.ConsumeCommand(logger, t.TempDir(), "mona-emu", "app", "com.example.app", "1.0", []string{"app-1.0.jar"}); err == nil {
+		t.Error("maven ConsumeCommand without a pom succeeded")
+	}
+
+	workspace = t.TempDir()
+	npm := providers.NewNPMProvider(logger, "npm").(providers.Consumer)
+	cmd, err = npm.ConsumeCommand(logger, workspace, "mona-emu", "web", "web", "1.1.0", []string{"web-1.1.0.tgz"})
+	if err != nil {
+		t.Fatalf("npm ConsumeCommand = %v", err)
+	}
+	if args := strings.Join(cmd.Args, " "); !strings.HasSuffix(args, "@mona-emu/web@1.1.0") {
+		t.Errorf("npm command = %s", args)
+	}
+	npmrc, _ := os.ReadFile(filepath.Join(workspace, ".npmrc"))
+	if !strings.Contains(string(npmrc), "@mona-emu:registry=https://npm.pkg.github.com/") || !strings.Contains(string(npmrc), "//npm.pkg.github.com/:_authToken=ghp_target") {
+		t.Errorf(".npmrc = %s", npmrc)
+	}
+	// The token is only in the workspace, never on the command line
+	if strings.Contains(strings.Join(cmd.Args, " "), "ghp_target") {
+		t.Error("the npm command line holds the token")
+	}
+}
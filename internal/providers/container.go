@@ -4,17 +4,23 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 
-	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/api/types/registry"
+	dockerregistry "github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
 	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/registry"
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -30,7 +36,28 @@ type ContainerProvider struct {
 	client        *client.Client
 	sourceAuthStr string
 	targetAuthStr string
-	recreatedShas map[string]string
+	// sourceRegistry and targetRegistry copy manifest lists and OCI artifacts
+	// registry to registry, the Docker daemon only ever pulls a single
+	// platform of a runnable image
+	sourceRegistry *registry.Client
+	targetRegistry *registry.Client
+	// source and target are where images are pulled from and pushed to
+	source ContainerRegistry
+	target ContainerRegistry
+	// recreated maps source image IDs to the target reference they were
+	// relabeled as, persisted in the state file so re-runs can reuse it
+	recreated *state.Store
+	// renameMu serializes Rename so concurrent workers never relabel the same image twice
+	renameMu sync.Mutex
+	// storageLimit is the most storage images may take in the daemon before pulls wait
+	storageLimit int64
+	// pulling counts the images being pulled or loaded into the daemon
+	pulling atomic.Int32
+	// sizeRegistry reads the size of the source images when exporting them,
+	// sizes caches them by version since every tag of a version is the same image
+	sizeRegistry     *registry.Client
+	sizeRegistryOnce sync.Once
+	sizes            sync.Map
 }
 
 // Constructor
@@ -39,8 +66,9 @@ type ContainerProvider struct {
 // NewContainerProvider creates a new ContainerProvider instance.
 func NewContainerProvider(logger *zap.Logger, packageType string) Provider {
 	return &ContainerProvider{
-		BaseProvider:  NewBaseProvider(packageType, "", "", true),
-		recreatedShas: make(map[string]string),
+		BaseProvider: NewBaseProvider(packageType, "", "", true),
+		source:       containerRegistry("SOURCE", packageType),
+		target:       containerRegistry("TARGET", packageType),
 	}
 }
 
@@ -48,7 +76,7 @@ func NewContainerProvider(logger *zap.Logger, packageType string) Provider {
 // -------------
 
 // encodeAuthToBase64 converts Docker registry authentication config to base64 encoded string.
-func encodeAuthToBase64(auth registry.AuthConfig) (string, error) {
+func encodeAuthToBase64(auth dockerregistry.AuthConfig) (string, error) {
 	authBytes, err := json.Marshal(auth)
 	if err != nil {
 		return "", err
@@ -58,7 +86,7 @@ func encodeAuthToBase64(auth registry.AuthConfig) (string, error) {
 
 // login authenticates with a Docker registry and returns the encoded auth string.
 func (p *ContainerProvider) login(logger *zap.Logger, addr, username, password string) (string, error) {
-	auth := registry.AuthConfig{
+	auth := dockerregistry.AuthConfig{
 		Username:      username,
 		Password:      password,
 		ServerAddress: addr,
@@ -84,10 +112,6 @@ func (p *ContainerProvider) login(logger *zap.Logger, addr, username, password s
 
 // Connect initializes the Docker client and authenticates with both source and target registries.
 func (p *ContainerProvider) Connect(logger *zap.Logger) error {
-	// Add validation for required environment variables
-	sourceOrg := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
-	sourceToken := viper.GetString("GHMPKG_SOURCE_TOKEN")
-
 	ctx := context.Background()
 
 	// Create Docker client
@@ -100,24 +124,45 @@ func (p *ContainerProvider) Connect(logger *zap.Logger) error {
 	p.ctx = ctx
 	p.client = client
 
-	if sourceOrg != "" && sourceToken != "" {
-		sourceAuthStr, err := p.login(logger, p.SourceRegistryUrl.String(), sourceOrg, sourceToken)
+	migrationPath := utils.MigrationPath()
+	if p.recreated, err = state.Load(migrationPath); err != nil {
+		logger.Error("Failed to load state", zap.Error(err))
+		return err
+	}
+
+	// Streaming copies registry to registry, the Docker daemon is not used
+	if viper.GetBool("GHMPKG_STREAM") {
+		if p.source.HasCredentials() {
+			p.sourceRegistry = registry.NewClient(p.source.ApiHost(), p.source.Username, p.source.Password)
+		}
+		if p.target.HasCredentials() {
+			p.targetRegistry = registry.NewClient(p.target.ApiHost(), p.target.Username, p.target.Password)
+		}
+		return nil
+	}
+
+	if p.storageLimit, err = ContainerStorageLimit(); err != nil {
+		return err
+	}
+
+	if p.source.HasCredentials() {
+		sourceAuthStr, err := p.login(logger, p.source.LoginAddress(), p.source.Username, p.source.Password)
 		if err != nil {
-			logger.Error("Failed to login to source registry", zap.Error(err))
+			logger.Error("Failed to login to source registry", zap.String("registry", p.source.Host), zap.Error(err))
 			return err
 		}
 		p.sourceAuthStr = sourceAuthStr
+		p.sourceRegistry = registry.NewClient(p.source.ApiHost(), p.source.Username, p.source.Password)
 	}
 
-	targetOrg := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
-	targetToken := viper.GetString("GHMPKG_TARGET_TOKEN")
-	if targetOrg != "" && targetToken != "" { //if targetOrg and token are empty, we don't need to login
-		targetAuthStr, err := p.login(logger, p.TargetRegistryUrl.String(), targetOrg, targetToken)
+	if p.target.HasCredentials() { // without credentials, we don't need to login
+		targetAuthStr, err := p.login(logger, p.target.LoginAddress(), p.target.Username, p.target.Password)
 		if err != nil {
-			logger.Error("Failed to login to target registry", zap.Error(err))
+			logger.Error("Failed to login to target registry", zap.String("registry", p.target.Host), zap.Error(err))
 			return err
 		}
 		p.targetAuthStr = targetAuthStr
+		p.targetRegistry = registry.NewClient(p.target.ApiHost(), p.target.Username, p.target.Password)
 	}
 
 	return nil
@@ -128,8 +173,15 @@ func (p *ContainerProvider) Connect(logger *zap.Logger) error {
 
 // FetchPackageFiles retrieves the list of container image tags for a package.
 func (p *ContainerProvider) FetchPackageFiles(logger *zap.Logger, owner, repository, packageType, packageName, version string, metadata *github.PackageMetadata) ([]string, ResultState, error) {
+	var tags []string
+	if metadata != nil && metadata.Container != nil {
+		tags = metadata.Container.Tags
+	} else if !strings.HasPrefix(version, "sha256:") {
+		// Legacy docker packages have no container metadata, their versions are named after the tag
+		tags = []string{version}
+	}
 	filenames := []string{}
-	for _, tag := range metadata.Container.Tags {
+	for _, tag := range tags {
 		filenames = append(filenames, fmt.Sprintf("%s:%s", packageName, tag))
 	}
 	// Reverse the slice to upload the latest version last
@@ -140,14 +192,139 @@ func (p *ContainerProvider) FetchPackageFiles(logger *zap.Logger, owner, reposit
 	return filenames, Success, nil
 }
 
+// FileSize returns the bytes of the image a tag points at in the source
+// registry. Every tag of a version names the same image, it is looked up once.
+func (p *ContainerProvider) FileSize(logger *zap.Logger, owner, repository, packageName, version, filename string) (int64, error) {
+	if !p.source.HasCredentials() {
+		return 0, nil
+	}
+	owner, repository, packageName = p.normalizeNames(owner, repository, packageName)
+	imageRepository := p.source.Repository(owner, repository, packageName)
+	key := imageRepository + "@" + version
+	if size, ok := p.sizes.Load(key); ok {
+		return size.(int64), nil
+	}
+	p.sizeRegistryOnce.Do(func() {
+		p.sizeRegistry = registry.NewClient(p.source.ApiHost(), p.source.Username, p.source.Password)
+	})
+	size, err := registry.ImageSize(context.Background(), p.sizeRegistry, imageRepository, version)
+	if err != nil {
+		return 0, err
+	}
+	p.sizes.Store(key, size)
+	return size, nil
+}
+
 // Download pulls a container image from the source registry and saves it locally.
 func (p *ContainerProvider) Download(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	// The ledger is keyed by the names of the inventory, before normalization
+	ledgerRepository, ledgerName := repository, packageName
+
 	// Normalize names for container images
 	owner, repository, packageName = p.normalizeNames(owner, repository, packageName)
 
 	parts := strings.Split(filename, ":")
 	tag := parts[1]
-	downloadedFilename := fmt.Sprintf("%s-%s.tar", packageName, tag)
+
+	raw, desc, found := p.sourceManifest(logger, owner, repository, packageName, tag)
+	if found {
+		// The tag must still point at the version that was exported
+		if err := p.checkChecksum(logger, ledgerRepository, ledgerName, version, filename, desc.Digest); err != nil {
+			logger.Error("Image does not match the exported digest", zap.String("filename", filename), zap.Error(err))
+			return Failed, err
+		}
+	}
+	var result ResultState
+	var err error
+	if found && (registry.IsIndex(desc.MediaType) || registry.IsArtifact(raw)) {
+		result, err = p.downloadLayout(logger, owner, repository, packageType, packageName, version, filename, tag)
+	} else {
+		result, err = p.downloadImage(logger, owner, repository, packageType, packageName, version, filename, tag)
+	}
+	if result == Success && found {
+		p.recordSourceDigest(logger, ledgerRepository, ledgerName, version, filename, desc.Digest)
+	}
+	if result != Failed && viper.GetBool("GHMPKG_COPY_REFERRERS") {
+		if !found {
+			logger.Warn("Source registry could not be queried, the signatures and attestations of the image are not copied",
+				zap.String("package", packageName),
+				zap.String("tag", tag))
+		} else if err := p.downloadReferrers(logger, owner, repository, packageType, packageName, version, tag, desc.Digest); err != nil {
+			logger.Error("Failed to pull referrers", zap.String("filename", filename), zap.Error(err))
+			return Failed, err
+		}
+	}
+	return result, err
+}
+
+// referrersName is the name of the OCI image layout directory the signatures,
+// attestations and other referrers of an image are staged in
+func referrersName(packageName, tag string) string {
+	return fmt.Sprintf("%s-%s.referrers.oci", packageName, tag)
+}
+
+// downloadReferrers stages the artifacts attached to an image digest next to
+// the image, unless an earlier run already did
+func (p *ContainerProvider) downloadReferrers(logger *zap.Logger, owner, repository, packageType, packageName, version, tag, digest string) error {
+	migrationPath := utils.MigrationPath()
+	outputPath := filepath.Join(migrationPath, "packages", owner, packageType, packageName, version, referrersName(packageName, tag))
+	if utils.FileExists(outputPath) || p.isStored(logger, outputPath) {
+		return nil
+	}
+
+	partPath := outputPath + ".part"
+	os.RemoveAll(partPath)
+	count, err := registry.PullReferrers(p.ctx, p.sourceRegistry, p.source.Repository(owner, repository, packageName), digest, registry.Layout{Dir: partPath})
+	if err != nil {
+		os.RemoveAll(partPath)
+		return err
+	}
+	if err := os.Rename(partPath, outputPath); err != nil {
+		os.RemoveAll(partPath)
+		return fmt.Errorf("failed to move referrers into place: %w", err)
+	}
+	logger.Info("Pulled referrers", zap.String("package", packageName), zap.String("tag", tag), zap.Int("count", count))
+	return p.storeOutput(logger, outputPath)
+}
+
+// uploadReferrers pushes the artifacts staged with an image once the image is
+// pushed. digest is the digest the image was pushed with, empty when unknown.
+func (p *ContainerProvider) uploadReferrers(logger *zap.Logger, packageDir, packageName, tag, targetRepository, digest string) error {
+	layoutDir := filepath.Join(packageDir, referrersName(packageName, tag))
+	if !viper.GetBool("GHMPKG_COPY_REFERRERS") || !utils.FileExists(layoutDir) {
+		return nil
+	}
+	if p.targetRegistry == nil {
+		return fmt.Errorf("target registry credentials are required to push the referrers of %s:%s", packageName, tag)
+	}
+	subject, count, err := registry.PushReferrers(p.ctx, p.targetRegistry, targetRepository, registry.Layout{Dir: layoutDir})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		logger.Info("Pushed referrers", zap.String("package", packageName), zap.String("tag", tag), zap.Int("count", count))
+		warnRecreated(logger, packageName, tag, subject, digest)
+	}
+	return nil
+}
+
+// warnRecreated warns when an image with referrers got a new digest on the
+// target: signatures and attestations are bound to the digest they were made
+// for, so they no longer verify the image
+func warnRecreated(logger *zap.Logger, packageName, tag, sourceDigest, targetDigest string) {
+	if sourceDigest == "" || targetDigest == "" || sourceDigest == targetDigest {
+		return
+	}
+	logger.Warn("Image was relabeled with a new digest, its signatures and attestations were copied but do not verify it",
+		zap.String("package", packageName),
+		zap.String("tag", tag),
+		zap.String("sourceDigest", sourceDigest),
+		zap.String("targetDigest", targetDigest))
+}
+
+// downloadImage pulls a single platform image with docker and saves it as a tarball
+func (p *ContainerProvider) downloadImage(logger *zap.Logger, owner, repository, packageType, packageName, version, filename, tag string) (ResultState, error) {
+	downloadedFilename := stagedName(fmt.Sprintf("%s-%s.tar", packageName, tag), filename)
 
 	return p.downloadPackage(
 		logger, owner, repository, packageType, packageName, version, filename, &downloadedFilename,
@@ -157,6 +334,13 @@ func (p *ContainerProvider) Download(logger *zap.Logger, owner, repository, pack
 		},
 		// Download function
 		func(downloadUrl, outputPath string) (ResultState, error) {
+			if err := p.waitForStorage(logger); err != nil {
+				logger.Error("Docker daemon is out of storage", zap.String("image", downloadUrl), zap.Error(err))
+				return Failed, err
+			}
+			p.pulling.Add(1)
+			defer p.pulling.Add(-1)
+
 			pullResp, err := p.client.ImagePull(p.ctx, downloadUrl, image.PullOptions{
 				RegistryAuth: p.sourceAuthStr,
 			})
@@ -207,90 +391,153 @@ func (p *ContainerProvider) Download(logger *zap.Logger, owner, repository, pack
 					zap.Error(err))
 				return Failed, err
 			}
+
+			// The tarball is all sync needs, free the daemon's storage for the next pulls
+			p.removeImages(logger, downloadUrl)
 			return Success, nil
 		},
 	)
 }
 
-// Rename creates a new image with updated metadata for the target registry.
-func (p *ContainerProvider) Rename(logger *zap.Logger, owner, repository, packageName, version, filename string) error {
-	// Skip if source and target organizations are the same
-	if p.CheckOrganizationsMatch(logger) {
-		return nil
+// layoutName is the name of the OCI image layout directory a manifest list or
+// an OCI artifact is staged in
+func layoutName(packageName, tag string) string {
+	return fmt.Sprintf("%s-%s.oci", packageName, tag)
+}
+
+// sourceManifest returns the manifest a source tag points at and its
+// descriptor, reporting false when the registry cannot be queried
+func (p *ContainerProvider) sourceManifest(logger *zap.Logger, owner, repository, packageName, tag string) ([]byte, registry.Descriptor, bool) {
+	if p.sourceRegistry == nil {
+		return nil, registry.Descriptor{}, false
 	}
+	raw, desc, err := p.sourceRegistry.GetManifest(p.ctx, p.source.Repository(owner, repository, packageName), tag)
+	if err != nil {
+		logger.Warn("Failed to inspect source manifest, falling back to docker pull",
+			zap.String("package", packageName),
+			zap.String("tag", tag),
+			zap.Error(err))
+		return nil, registry.Descriptor{}, false
+	}
+	return raw, desc, true
+}
+
+// downloadLayout copies a manifest list with every platform image, or an OCI
+// artifact such as a Helm chart, into an OCI image layout, so the target
+// receives every architecture and every artifact byte for byte
+func (p *ContainerProvider) downloadLayout(logger *zap.Logger, owner, repository, packageType, packageName, version, filename, tag string) (ResultState, error) {
+	downloadedFilename := layoutName(packageName, tag)
+	return p.downloadPackage(
+		logger, owner, repository, packageType, packageName, version, filename, &downloadedFilename,
+		func() (string, error) {
+			return p.GetDownloadUrl(logger, owner, repository, packageName, version, filename)
+		},
+		func(downloadUrl, outputPath string) (ResultState, error) {
+			logger.Info("Copying manifest list or artifact", zap.String("image", downloadUrl))
+			// outputPath is a staging path, it is only moved into place once the copy completed
+			layout := registry.Layout{Dir: outputPath}
+			if _, err := registry.Pull(p.ctx, p.sourceRegistry, p.source.Repository(owner, repository, packageName), tag, layout); err != nil {
+				logger.Error("Failed to copy manifest list or artifact",
+					zap.String("image", downloadUrl),
+					zap.Error(err))
+				return Failed, err
+			}
+			return Success, nil
+		},
+	)
+}
+
+// Rename loads a pulled image into the Docker daemon under its target
+// reference, with its org.opencontainers.image.source label pointing at the
+// target organization. The label is changed in the image configuration of the
+// tarball, the layers, history and creation time of the image are kept. It
+// returns the ID of the image it relabeled, empty when the source image was
+// reused.
+func (p *ContainerProvider) Rename(logger *zap.Logger, owner, repository, packageName, version, filename, tarPath string) (string, error) {
+	p.renameMu.Lock()
+	defer p.renameMu.Unlock()
 
-	// Tag image for target registry
 	sourceOrg := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
 	targetOrg := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
 	sourceRef, err := p.GetDownloadUrl(logger, sourceOrg, repository, packageName, version, filename)
 	if err != nil {
 		logger.Error("Failed to get download URL", zap.Error(err))
-		return err
+		return "", err
 	}
 	targetRef, err := p.GetUploadUrl(logger, targetOrg, repository, packageName, version, filename)
 	if err != nil {
 		logger.Error("Failed to get upload URL", zap.Error(err))
-		return err
+		return "", err
+	}
+	// Load as is when the image keeps its organization, registry and name,
+	// unless a transfer moves it to another repository
+	if sourceRef == targetRef && !Transferring() {
+		return "", p.loadImage(logger, sourceRef, tarPath)
 	}
 
-	// Get existing image details
-	inspect, _, err := p.client.ImageInspectWithRaw(p.ctx, sourceRef)
+	saved, err := ReadSavedImage(tarPath)
 	if err != nil {
-		return fmt.Errorf("failed to inspect image: %w", err)
+		return "", fmt.Errorf("image %s is not pulled: %w", sourceRef, err)
 	}
 
-	// check if inspect.ID is in recreatedShas
-	if origTargetRef, ok := p.recreatedShas[inspect.ID]; ok {
+	// Reuse an image already relabeled for the target org, by this or a previous run
+	imageKey := fmt.Sprintf("%s|%s", saved.ID(), targetOrg)
+	if origTargetRef, ok := p.recreated.ContainerRef(imageKey); ok {
 		err = p.client.ImageTag(p.ctx, origTargetRef, targetRef)
-		if err != nil {
-			logger.Error("Failed to tag image", zap.Error(err))
-			return err
+		if err == nil {
+			return "", nil
 		}
-		return nil
+		// The relabeled image may have been removed from the daemon since, relabel it again
+		logger.Info("Failed to tag relabeled image, relabeling it",
+			zap.String("recreatedRef", origTargetRef),
+			zap.Error(err))
 	}
 
-	// Create new labels map, copying existing labels
+	// Copy the existing labels, only the source label changes
+	labels, err := saved.Labels()
+	if err != nil {
+		return "", err
+	}
 	newLabels := make(map[string]string)
-	for k, v := range inspect.Config.Labels {
+	for k, v := range labels {
 		newLabels[k] = v
 	}
+	newLabels[sourceLabel] = targetSourceLabel(labels[sourceLabel], sourceOrg, targetOrg)
 
-	// Update the specific label
-	newLabels["org.opencontainers.image.source"] = strings.Replace(
-		newLabels["org.opencontainers.image.source"],
-		sourceOrg,
-		targetOrg,
-		1,
-	)
+	// An image without a label to rewrite is pushed as is, with its digest
+	if newLabels[sourceLabel] == labels[sourceLabel] {
+		if err := p.loadImage(logger, sourceRef, tarPath); err != nil {
+			return "", err
+		}
+		if targetRef == sourceRef {
+			return "", nil
+		}
+		return "", p.client.ImageTag(p.ctx, sourceRef, targetRef)
+	}
 
-	// Create a container with the new labels
-	resp, err := p.client.ContainerCreate(p.ctx, &container.Config{
-		Image:  sourceRef,
-		Labels: newLabels,
-	}, nil, nil, nil, "")
-	if err != nil {
-		return fmt.Errorf("failed to create container: %w", err)
+	// An image an earlier attempt left at the target reference would be left
+	// dangling once the load moves the reference. A transfer loads over the
+	// source reference.
+	if targetRef != sourceRef {
+		p.removeImages(logger, targetRef)
 	}
-	defer p.client.ContainerRemove(p.ctx, resp.ID, container.RemoveOptions{})
 
-	// Commit the container as a new image
-	_, err = p.client.ContainerCommit(p.ctx, resp.ID, container.CommitOptions{
-		Reference: targetRef,
-		Config: &container.Config{
-			Labels: newLabels,
-		},
-	})
+	id, err := p.loadRelabeled(logger, targetRef, saved, newLabels)
 	if err != nil {
-		return fmt.Errorf("failed to commit container: %w", err)
+		return "", err
 	}
 
-	p.recreatedShas[inspect.ID] = targetRef
+	if err := p.recreated.SetContainerRef(imageKey, targetRef); err != nil {
+		logger.Warn("Failed to save relabeled image", zap.Error(err))
+	}
 
-	return nil
+	return id, nil
 }
 
 // Upload pushes a container image to the target registry.
 func (p *ContainerProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	ledgerRepository, ledgerName := repository, packageName
+
 	// Normalize names for container images
 	owner, repository, packageName = p.normalizeNames(owner, repository, packageName)
 
@@ -300,9 +547,48 @@ func (p *ContainerProvider) Upload(logger *zap.Logger, owner, repository, packag
 			return p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
 		},
 		func(uploadUrl, packageDir string) (ResultState, error) {
-			if err := p.Rename(logger, owner, repository, packageName, version, filename); err != nil {
+			tag := strings.Split(filename, ":")[1]
+			targetOwner := strings.ToLower(viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
+			targetRepository, targetName, _ := p.targetNames(repository, packageName, filename)
+			targetRepository, targetName = NormalizeName(p.PackageType, RepositoryField, targetRepository), NormalizeName(p.PackageType, NameField, targetName)
+			// Other registries are not a GitHub organization, tags already there are skipped
+			if !p.target.IsGitHub() && p.targetRegistry != nil {
+				if _, _, err := p.targetRegistry.GetManifest(p.ctx, p.target.Repository(targetOwner, targetRepository, targetName), tag); err == nil {
+					return Skip(SkipExistsOnTarget, fmt.Sprintf("%s is already in %s", filename, p.target.Host))
+				}
+			}
+
+			// Manifest lists and artifacts are pushed from their OCI layout as is, every platform included
+			layoutDir := filepath.Join(packageDir, layoutName(packageName, tag))
+			if utils.FileExists(layoutDir) {
+				if p.targetRegistry == nil {
+					return Failed, fmt.Errorf("target registry credentials are required to push manifest list or artifact %s", filename)
+				}
+				layout := registry.Layout{Dir: layoutDir}
+				if err := registry.Push(p.ctx, p.targetRegistry, p.target.Repository(targetOwner, targetRepository, targetName), tag, layout); err != nil {
+					logger.Error("Failed to push manifest list or artifact", zap.Error(err))
+					return Failed, err
+				}
+				desc, err := layout.ReadIndex()
+				if err == nil {
+					p.recordTargetDigest(logger, ledgerRepository, ledgerName, version, filename, desc.Digest)
+				}
+				if err := p.uploadReferrers(logger, packageDir, packageName, tag, p.target.Repository(targetOwner, targetRepository, targetName), desc.Digest); err != nil {
+					logger.Error("Failed to push referrers", zap.Error(err))
+					return Failed, err
+				}
+				return Success, nil
+			}
 
-				logger.Error("Failed to rename image", zap.Error(err))
+			// Pull removes images once saved, load the tarball back into the daemon
+			sourceRef, err := p.GetDownloadUrl(logger, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, packageName, version, filename)
+			if err != nil {
+				logger.Error("Failed to get download URL", zap.Error(err))
+				return Failed, err
+			}
+			committed, err := p.Rename(logger, owner, repository, packageName, version, filename, stagedPath(packageDir, fmt.Sprintf("%s-%s.tar", packageName, tag), filename))
+			if err != nil {
+				logger.Error("Failed to load image", zap.Error(err))
 				return Failed, err
 			}
 			targetRef, err := p.GetUploadUrl(logger, viper.GetString("GHMPKG_TARGET_ORGANIZATION"), repository, packageName, version, filename)
@@ -321,16 +607,55 @@ func (p *ContainerProvider) Upload(logger *zap.Logger, owner, repository, packag
 			defer pushResp.Close()
 
 			// Must read the response to complete the push
-			_, err = io.Copy(io.Discard, pushResp)
+			digest, err := pushedDigest(pushResp)
 			if err != nil {
 				logger.Error("Failed to read push response", zap.Error(err))
 				return Failed, err
 			}
+			if digest != "" {
+				p.recordTargetDigest(logger, ledgerRepository, ledgerName, version, filename, digest)
+			}
+			if err := p.uploadReferrers(logger, packageDir, packageName, tag, p.target.Repository(targetOwner, targetRepository, targetName), digest); err != nil {
+				logger.Error("Failed to push referrers", zap.Error(err))
+				p.removeImages(logger, targetRef, committed, sourceRef)
+				return Failed, err
+			}
+
+			// The tag is confirmed pushed, nothing created for it is needed anymore
+			p.removeImages(logger, targetRef, committed, sourceRef)
 			return Success, nil
 		},
 	)
 }
 
+// pushedDigest reads a docker push response to the end and returns the manifest
+// digest the daemon reports in its final aux message, or the error it reports
+// when the push failed
+func pushedDigest(pushResp io.Reader) (string, error) {
+	var digest string
+	decoder := json.NewDecoder(pushResp)
+	for {
+		var message struct {
+			Aux struct {
+				Digest string `json:"Digest"`
+			} `json:"aux"`
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return digest, nil
+		} else if err != nil {
+			return "", err
+		}
+		// The daemon reports a failed push in the stream, after a 200 response
+		if message.Error != "" {
+			return "", errors.New(message.Error)
+		}
+		if message.Aux.Digest != "" {
+			digest = message.Aux.Digest
+		}
+	}
+}
+
 // URL Generation
 // -------------
 
@@ -346,19 +671,16 @@ func (p *ContainerProvider) GetDownloadUrl(logger *zap.Logger, owner, repository
 	// Normalize names for container images
 	owner, repository, packageName = p.normalizeNames(owner, repository, packageName)
 
-	downloadUrl := *p.SourceRegistryUrl
-	downloadUrl.Path = path.Join(downloadUrl.Path, owner, filename)
-	return downloadUrl.String(), nil
+	return p.source.Reference(owner, repository, filename), nil
 }
 
 // GetUploadUrl generates the URL for uploading a container image to the target registry.
 func (p *ContainerProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	repository, packageName, filename = p.targetNames(repository, packageName, filename)
 	// Normalize names for container images
 	owner, repository, packageName = p.normalizeNames(owner, repository, packageName)
 
-	uploadUrl := *p.TargetRegistryUrl
-	uploadUrl.Path = path.Join(uploadUrl.Path, owner, filename)
-	return uploadUrl.String(), nil
+	return p.target.Reference(owner, repository, filename), nil
 }
 
 // Required Interface Methods
@@ -371,8 +693,27 @@ func (p *ContainerProvider) Export(logger *zap.Logger, owner string, content int
 
 // Add these methods near the top of the ContainerProvider struct methods
 
+// targetNames are the repository, name and name:tag filename of an image on
+// the target, renamed by the mapping file
+func (p *ContainerProvider) targetNames(repository, packageName, filename string) (string, string, string) {
+	targetName := TargetPackageName(p.PackageType, packageName)
+	if targetName != packageName {
+		if _, tag, ok := strings.Cut(filename, ":"); ok {
+			filename = NormalizeName(p.PackageType, NameField, targetName) + ":" + tag
+		}
+	}
+	return TargetRepository(repository), targetName, filename
+}
+
+// targetSourceLabel points an org.opencontainers.image.source label at the
+// target organization, and at the target name of a mapped repository
+func targetSourceLabel(source, sourceOrg, targetOrg string) string {
+	source = currentMapping().RepositoryUrls(source, "/"+sourceOrg+"/", "/"+targetOrg+"/")
+	return strings.Replace(source, sourceOrg, targetOrg, 1)
+}
+
 func (p *ContainerProvider) normalizeNames(owner, repository, packageName string) (string, string, string) {
-	return strings.ToLower(owner),
-		strings.ToLower(repository),
-		strings.ToLower(packageName)
+	return NormalizeName(p.PackageType, OwnerField, owner),
+		NormalizeName(p.PackageType, RepositoryField, repository),
+		NormalizeName(p.PackageType, NameField, packageName)
 }
@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-units"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// storagePollInterval is how often the disk usage of the Docker daemon is
+// checked while waiting for other pulls to free storage
+var storagePollInterval = 5 * time.Second
+
+// ContainerStorageLimit reads GHMPKG_CONTAINER_STORAGE_LIMIT, the most storage
+// images may take in the Docker daemon before new pulls wait, e.g. 50GB.
+// Zero disables the limit.
+func ContainerStorageLimit() (int64, error) {
+	value := viper.GetString("GHMPKG_CONTAINER_STORAGE_LIMIT")
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	limit, err := units.FromHumanSize(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid container storage limit %q, expected a size such as 50GB", value)
+	}
+	return limit, nil
+}
+
+// KeepImages reports whether images are left in the Docker daemon once saved or
+// pushed, GHMPKG_KEEP_IMAGES. They are removed by default so pulling thousands
+// of images doesn't fill the daemon's storage.
+func KeepImages() bool {
+	return viper.GetBool("GHMPKG_KEEP_IMAGES")
+}
+
+// waitForStorage blocks a pull while the images of the Docker daemon take more
+// than the storage limit. Pulls in flight free their images once saved, when
+// none are left the daemon is full of images this run doesn't own.
+func (p *ContainerProvider) waitForStorage(logger *zap.Logger) error {
+	if p.storageLimit <= 0 {
+		return nil
+	}
+	for {
+		usage, err := p.client.DiskUsage(p.ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.ImageObject}})
+		if err != nil {
+			logger.Warn("Failed to read docker disk usage, not waiting for storage", zap.Error(err))
+			return nil
+		}
+		if usage.LayersSize < p.storageLimit {
+			return nil
+		}
+		if p.pulling.Load() == 0 {
+			return fmt.Errorf("docker images take %s, over the %s container storage limit: remove unused images with docker image prune",
+				units.HumanSize(float64(usage.LayersSize)), units.HumanSize(float64(p.storageLimit)))
+		}
+		logger.Info("Waiting for the docker daemon to free storage",
+			zap.Int64("layersSize", usage.LayersSize),
+			zap.Int64("storageLimit", p.storageLimit),
+			zap.Int32("pulling", p.pulling.Load()))
+		time.Sleep(storagePollInterval)
+	}
+}
+
+// removeImages untags images from the Docker daemon, deleting their layers once
+// no other tag uses them, in order: images before the images they were built
+// from. Empty and already removed references are ignored, failures only leave
+// the image behind.
+func (p *ContainerProvider) removeImages(logger *zap.Logger, refs ...string) {
+	if KeepImages() {
+		return
+	}
+	removed := map[string]bool{}
+	for _, ref := range refs {
+		if ref == "" || removed[ref] {
+			continue
+		}
+		removed[ref] = true
+		if _, err := p.client.ImageRemove(p.ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil && !errdefs.IsNotFound(err) {
+			logger.Warn("Failed to remove image", zap.String("image", ref), zap.Error(err))
+		}
+	}
+}
+
+// loadImage loads a pulled tarball back into the Docker daemon when pull
+// removed its image
+func (p *ContainerProvider) loadImage(logger *zap.Logger, ref, tarPath string) error {
+	if _, _, err := p.client.ImageInspectWithRaw(p.ctx, ref); err == nil {
+		return nil
+	}
+	if err := p.waitForStorage(logger); err != nil {
+		return err
+	}
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return fmt.Errorf("image %s is neither in the docker daemon nor pulled: %w", ref, err)
+	}
+	defer file.Close()
+
+	p.pulling.Add(1)
+	defer p.pulling.Add(-1)
+	loadResp, err := p.client.ImageLoad(p.ctx, file, true)
+	if err != nil {
+		logger.Error("Failed to load image", zap.String("image", ref), zap.String("path", tarPath), zap.Error(err))
+		return err
+	}
+	defer loadResp.Body.Close()
+	// Must read the response to complete the load
+	if _, err := io.Copy(io.Discard, loadResp.Body); err != nil {
+		return fmt.Errorf("failed to load image %s: %w", ref, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPushedDigest(t *testing.T) {
+	tests := []struct {
+		name, stream, digest, err string
+	}{
+		{"pushed", `{"status":"Pushing"}` + "\n" + `{"status":"1.0: digest: sha256:abc size: 528"}` + "\n" + `{"aux":{"Tag":"1.0","Digest":"sha256:abc","Size":528}}`, "sha256:abc", ""},
+		{"no aux message", `{"status":"Pushed"}`, "", ""},
+		{"failed after the digest", `{"aux":{"Digest":"sha256:abc"}}` + "\n" + `{"errorDetail":{"message":"denied"},"error":"denied: permission_denied"}`, "", "denied: permission_denied"},
+		{"truncated", `{"status":"Pushing"}` + "\n" + `{"aux":{"Dig`, "", "unexpected EOF"},
+	}
+	for _, test := range tests {
+		digest, err := pushedDigest(strings.NewReader(test.stream))
+		if digest != test.digest {
+			t.Errorf("%s: digest = %q, want %q", test.name, digest, test.digest)
+		}
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+// fakeDaemon answers image removals, the images in missing are not found
+func fakeDaemon(t *testing.T, missing ...string) (*ContainerProvider, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var removed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ref, ok := strings.Cut(r.URL.Path, "/images/")
+		if r.Method != http.MethodDelete || !ok {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		ref, _ = url.PathUnescape(ref)
+		mu.Lock()
+		removed = append(removed, ref)
+		mu.Unlock()
+		for _, name := range missing {
+			if name == ref {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"No such image: ` + ref + `"}`))
+				return
+			}
+		}
+		w.Write([]byte(`[{"Untagged":"` + ref + `"}]`))
+	}))
+	t.Cleanup(server.Close)
+
+	dockerClient, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")), client.WithVersion("1.43"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &ContainerProvider{ctx: context.Background(), client: dockerClient}
+	return provider, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), removed...)
+	}
+}
+
+func TestRemoveImages(t *testing.T) {
+	defer viper.Reset()
+	targetRef, sourceRef := "ghcr.io/octo/app:1.0", "ghcr.io/mona/app:1.0"
+	committed := "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+
+	// The relabeled image was built from the source image, it goes first
+	provider, removed := fakeDaemon(t, sourceRef)
+	core, logs := observer.New(zap.WarnLevel)
+	provider.removeImages(zap.New(core), targetRef, committed, sourceRef)
+	if got := strings.Join(removed(), ","); got != strings.Join([]string{targetRef, committed, sourceRef}, ",") {
+		t.Errorf("removed %s, want the target tag, the relabeled image and the source image in order", got)
+	}
+	// Removing the relabeled image may already have deleted the source image
+	if logs.Len() != 0 {
+		t.Errorf("an image that is already removed was reported: %v", logs.All())
+	}
+
+	// Without a rename the target tag points at the source image
+	provider, removed = fakeDaemon(t)
+	provider.removeImages(zap.NewNop(), targetRef, "", sourceRef, targetRef)
+	if got := strings.Join(removed(), ","); got != targetRef+","+sourceRef {
+		t.Errorf("removed %s, want each reference once", got)
+	}
+
+	viper.Set("GHMPKG_KEEP_IMAGES", true)
+	provider, removed = fakeDaemon(t)
+	provider.removeImages(zap.NewNop(), targetRef, committed, sourceRef)
+	if got := removed(); len(got) != 0 {
+		t.Errorf("removed %v with GHMPKG_KEEP_IMAGES set", got)
+	}
+}
@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// What became of the digest headers sent with an upload
+const (
+	// DigestValidated: the registry answered with the digest of what it
+	// stored, the one sent
+	DigestValidated = "validated"
+	// DigestSent: the registry does not tell whether it checked the headers
+	DigestSent = "sent"
+)
+
+// uploadDigests holds, keyed like the state, what became of the digest headers
+// of the files uploaded with them
+var uploadDigests sync.Map
+
+// digestOutcome tells whether the registry validated the digest headers of an upload
+func digestOutcome(resp *http.Response) string {
+	if utils.DigestValidated(resp) {
+		return DigestValidated
+	}
+	return DigestSent
+}
+
+// recordUploadDigest records what became of the digest headers of an upload
+func (p *BaseProvider) recordUploadDigest(repository, packageName, version, filename, outcome string) {
+	uploadDigests.Store(state.Key(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, p.PackageType, packageName, version, filename), outcome)
+}
+
+// UploadDigest returns whether the registry validated the digest headers sent
+// with a file, DigestValidated or DigestSent, and forgets it. It is empty for
+// files uploaded without them: streamed, multipart and container uploads.
+func UploadDigest(repository, packageType, packageName, version, filename string) string {
+	outcome, ok := uploadDigests.LoadAndDelete(state.Key(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, packageType, packageName, version, filename))
+	if !ok {
+		return ""
+	}
+	return outcome.(string)
+}
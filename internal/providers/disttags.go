@@ -0,0 +1,14 @@
+package providers
+
+import "go.uber.org/zap"
+
+// DistTagger is implemented by providers whose registry points tags such as
+// latest, next or beta at versions. The tags are not part of the package files,
+// export records them and sync applies them once the versions are published.
+// TargetVersions lets sync point latest at the newest version the target has
+// when the source tag is not known.
+type DistTagger interface {
+	DistTags(logger *zap.Logger, owner, packageName string) (map[string]string, error)
+	TargetVersions(logger *zap.Logger, owner, packageName string) ([]string, error)
+	SetDistTag(logger *zap.Logger, owner, packageName, tag, version string) error
+}
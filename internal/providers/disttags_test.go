@@ -0,0 +1,61 @@
+package providers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestNpmDistTags(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+
+	tagged := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.EscapedPath() == "/@mona/app":
+			w.Write([]byte(`{"name": "@mona/app", "dist-tags": {"latest": "1.0.0", "next": "2.0.0-beta.1"}}`))
+		case r.Method == http.MethodGet && r.URL.EscapedPath() == "/@octo%2Fapp":
+			w.Write([]byte(`{"name": "@octo/app", "versions": {"1.0.0": {}, "2.0.0-beta.1": {}}}`))
+		case r.Method == http.MethodPut && r.URL.EscapedPath() == "/-/package/@octo%2Fapp/dist-tags/next":
+			var version string
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &version); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tagged["next"] = version
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := connectTarget(t, "npm").(*providers.NPMProvider)
+	provider.SourceRegistryUrl = utils.ParseUrl(server.URL + "/")
+	provider.TargetRegistryUrl = utils.ParseUrl(server.URL + "/")
+
+	tags, err := provider.DistTags(zap.NewNop(), "mona", "app")
+	if err != nil || len(tags) != 2 || tags["next"] != "2.0.0-beta.1" {
+		t.Fatalf("DistTags = %v, %v", tags, err)
+	}
+	versions, err := provider.TargetVersions(zap.NewNop(), "octo", "app")
+	if err != nil || len(versions) != 2 {
+		t.Errorf("TargetVersions = %v, %v", versions, err)
+	}
+	if err := provider.SetDistTag(zap.NewNop(), "octo", "app", "next", "2.0.0-beta.1"); err != nil || tagged["next"] != "2.0.0-beta.1" {
+		t.Errorf("SetDistTag = %v, tagged %v", err, tagged)
+	}
+	if err := provider.SetDistTag(zap.NewNop(), "octo", "other", "latest", "1.0.0"); err == nil {
+		t.Error("SetDistTag succeeded on a missing package")
+	}
+}
@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// NewDockerProvider creates a provider for the images of the legacy
+// docker.pkg.github.com registry, which older GitHub Enterprise Server instances
+// still serve next to, or instead of, the Container registry. Images are pulled
+// from docker.<source hostname>/OWNER/REPOSITORY/IMAGE and pushed to the
+// container registry of the target like container packages.
+func NewDockerProvider(logger *zap.Logger, packageType string) Provider {
+	source := legacyDockerRegistry(packageType)
+	base := NewBaseProvider(packageType, "", "", true)
+	base.SourceRegistryUrl = utils.ParseUrl(source.Host)
+	return &ContainerProvider{
+		BaseProvider: base,
+		source:       source,
+		target:       containerRegistry("TARGET", packageType),
+	}
+}
+
+// legacyDockerRegistry is the legacy Docker registry of the source GitHub host,
+// logged in to with the source organization and token
+func legacyDockerRegistry(packageType string) ContainerRegistry {
+	// GitHub Enterprise Server serves it on docker.HOSTNAME, or on the hostname
+	// itself without subdomain isolation
+	hostname := sideHostname("SOURCE", packageType)
+	host := fmt.Sprintf("docker.pkg.%s", hostname)
+	if isServer(hostname) {
+		host = hostname
+		if subdomainIsolation("SOURCE") {
+			host = "docker." + hostname
+		}
+	}
+	return ContainerRegistry{
+		Host:             host,
+		RepositoryScoped: true,
+		Username:         viper.GetString("GHMPKG_SOURCE_ORGANIZATION"),
+		Password:         utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", packageType),
+	}
+}
+
+// SourceLegacyDockerRegistry is the registry legacy images are pulled from
+func SourceLegacyDockerRegistry() ContainerRegistry {
+	return legacyDockerRegistry("docker")
+}
@@ -0,0 +1,159 @@
+package providers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"go.uber.org/zap"
+)
+
+// feedPublisher publishes to the maven, npm and NuGet endpoints of a registry
+// hosting every format in one feed, where a published version cannot be
+// replaced: a conflict means the feed already has it.
+type feedPublisher struct {
+	// registry names the registry in errors
+	registry string
+	// authorize authenticates a request to the feed
+	authorize func(req *http.Request) error
+}
+
+// deployMaven PUTs a Maven file unless the feed already has it. A file the feed
+// already has with another sha1 checksum is reported as failed, the feed would
+// refuse to replace it.
+func (f feedPublisher) deployMaven(logger *zap.Logger, uploadUrl string, content []byte) (ResultState, error) {
+	head, err := http.NewRequest(http.MethodHead, uploadUrl, nil)
+	if err != nil {
+		return Failed, err
+	}
+	if err := f.authorize(head); err != nil {
+		return Failed, err
+	}
+	if resp, err := utils.NewHTTPClient().Do(head); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			sum := sha1.Sum(content)
+			digest := hex.EncodeToString(sum[:])
+			published, err := f.mavenChecksum(uploadUrl + ".sha1")
+			switch {
+			case err != nil:
+				// Without the checksum the PUT tells whether the feed has the file
+				logger.Warn("Failed to read the checksum of a published file", zap.String("url", uploadUrl), zap.Error(err))
+			case published == digest:
+				logger.Info("File already published", zap.String("url", uploadUrl))
+				return Skipped, nil
+			default:
+				return Failed, fmt.Errorf("%s already has %s with sha1 %s, not %s", f.registry, uploadUrl, published, digest)
+			}
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPut, uploadUrl, bytes.NewReader(content))
+	if err != nil {
+		return Failed, err
+	}
+	return f.send(logger, req, uploadUrl)
+}
+
+// mavenChecksum reads the checksum file the feed serves next to a Maven file
+func (f feedPublisher) mavenChecksum(checksumUrl string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, checksumUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := f.authorize(req); err != nil {
+		return "", err
+	}
+	resp, err := utils.NewHTTPClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", f.registry, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	// Checksum files may be followed by the file name
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file")
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// publishNpm PUTs the publish document of a tarball to an npm registry, under
+// the name in its package.json
+func (f feedPublisher) publishNpm(logger *zap.Logger, registryUrl url.URL, tarball []byte) (ResultState, error) {
+	manifest, err := readNpmManifest(tarball)
+	if err != nil {
+		return Failed, err
+	}
+	name, document, err := npmPublishDocument(registryUrl, manifest, tarball)
+	if err != nil {
+		return Failed, fmt.Errorf("failed to build publish document: %w", err)
+	}
+	publishUrl := utils.JoinUrlPath(registryUrl, name)
+	req, err := http.NewRequest(http.MethodPut, publishUrl.String(), bytes.NewReader(document))
+	if err != nil {
+		return Failed, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return f.send(logger, req, name)
+}
+
+// pushNuget uploads a nupkg to a NuGet push endpoint
+func (f feedPublisher) pushNuget(logger *zap.Logger, uploadUrl, nupkg string, content []byte) (ResultState, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("package", nupkg)
+	if err != nil {
+		return Failed, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return Failed, err
+	}
+	if err := form.Close(); err != nil {
+		return Failed, err
+	}
+	req, err := http.NewRequest(http.MethodPut, uploadUrl, &body)
+	if err != nil {
+		return Failed, err
+	}
+	// The feeds ignore the API key, the credentials authenticate the push
+	req.Header.Set("X-NuGet-ApiKey", "key")
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return f.send(logger, req, nupkg)
+}
+
+// send performs an upload, a conflict meaning the feed already has the version
+func (f feedPublisher) send(logger *zap.Logger, req *http.Request, name string) (ResultState, error) {
+	if err := f.authorize(req); err != nil {
+		return Failed, err
+	}
+	resp, err := utils.NewHTTPClient().Do(req)
+	if err != nil {
+		return Failed, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		logger.Warn("Package version already exists", zap.String("package", name))
+		return Skip(SkipExistsOnTarget, fmt.Sprintf("%s is already published", name))
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Success, nil
+	default:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Failed, fmt.Errorf("%s returned %s for %s: %s", f.registry, resp.Status, name, strings.TrimSpace(string(message)))
+	}
+}
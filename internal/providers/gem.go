@@ -2,9 +2,9 @@ package providers
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"strings"
 
@@ -55,7 +55,7 @@ func (p *RubyGemsProvider) Download(logger *zap.Logger, owner, repository, packa
 		},
 		// Download function
 		func(downloadUrl, outputPath string) (ResultState, error) {
-			if err := utils.DownloadFile(downloadUrl, outputPath, viper.GetString("GHMPKG_SOURCE_TOKEN")); err != nil {
+			if err := utils.DownloadFile(downloadUrl, outputPath, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", p.PackageType)); err != nil {
 				return Failed, err
 			}
 			return Success, nil
@@ -64,64 +64,88 @@ func (p *RubyGemsProvider) Download(logger *zap.Logger, owner, repository, packa
 }
 
 func (p *RubyGemsProvider) Rename(logger *zap.Logger, repository, filename string) error {
-	// Skip if source and target organizations are the same
-	if p.CheckOrganizationsMatch(logger) {
+	// Skip if source and target organizations are the same and nothing is mapped
+	mapping := currentMapping()
+	if p.CheckOrganizationsMatch(logger) && mapping.IsEmpty() {
 		return nil
 	}
 
-	// Replace the organization name in the content
-	sourceHostname := utils.ParseUrl(viper.GetString("GHMPKG_SOURCE_HOSTNAME"))
-	targetHostname := utils.ParseUrl(viper.GetString("GHMPKG_TARGET_HOSTNAME"))
-	sourceHostname.Path = path.Join(sourceHostname.Path, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
-	targetHostname.Path = path.Join(targetHostname.Path, viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
-	if err := utils.RenameFileOccurances(filename, sourceHostname.String(), targetHostname.String(), -1); err != nil {
-		return err
+	sourceOrg := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOrg := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+
+	// Point the URLs of mapped repositories at their target repository first
+	if len(mapping.Repositories) > 0 {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		sourceUrl := utils.JoinUrlPath(*p.SourceHostnameUrl, sourceOrg)
+		targetUrl := utils.JoinUrlPath(*p.TargetHostnameUrl, targetOrg)
+		if renamed := mapping.RepositoryUrls(string(content), sourceUrl.String()+"/", targetUrl.String()+"/"); renamed != string(content) {
+			if err := utils.WriteFileAtomic(filename, []byte(renamed), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Replace the organization name in the content, in the URLs of the GitHub
+	// host and of the registry, then the registry host itself
+	replacements := [][2]url.URL{
+		{utils.JoinUrlPath(*p.SourceHostnameUrl, sourceOrg), utils.JoinUrlPath(*p.TargetHostnameUrl, targetOrg)},
+		{utils.JoinUrlPath(*p.SourceRegistryUrl, sourceOrg), utils.JoinUrlPath(*p.TargetRegistryUrl, targetOrg)},
+		{*p.SourceRegistryUrl, *p.TargetRegistryUrl},
 	}
-	if err := utils.RenameFileOccurances(filename, p.SourceRegistryUrl.String(), p.TargetRegistryUrl.String(), -1); err != nil {
-		return err
+	for _, replacement := range replacements {
+		if err := utils.RenameFileOccurances(filename, replacement[0].String(), replacement[1].String(), -1); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// ensureGemCredentials sets up the necessary credentials for gem operations
-func (p *RubyGemsProvider) ensureGemCredentials(logger *zap.Logger) error {
-	// Check if credentials file exists
-	homeDir, err := os.UserHomeDir()
+// writeGemCredentials creates a temporary home directory holding a gem credentials
+// file for the target token, so the operator's ~/.gem/credentials is never touched.
+// The caller is responsible for removing the returned directory.
+func (p *RubyGemsProvider) writeGemCredentials(logger *zap.Logger) (string, error) {
+	homeDir, err := os.MkdirTemp("", "ghmpkg-gem-")
 	if err != nil {
-		logger.Error("failed to get home directory", zap.Error(err))
-		return err
+		logger.Error("failed to create temporary gem home", zap.Error(err))
+		return "", err
 	}
 
 	credentialsDir := filepath.Join(homeDir, ".gem")
 	if err := os.MkdirAll(credentialsDir, 0700); err != nil {
 		logger.Error("failed to create credentials directory", zap.Error(err))
-		return err
+		os.RemoveAll(homeDir)
+		return "", err
 	}
 
-	// Create or update credentials file
 	credentialsFile := filepath.Join(credentialsDir, "credentials")
-	content := fmt.Sprintf("---\n:github: %s\n", viper.GetString("GHMPKG_TARGET_TOKEN"))
+	content := fmt.Sprintf("---\n:github: %s\n", utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType))
 
 	if err := os.WriteFile(credentialsFile, []byte(content), 0600); err != nil {
 		logger.Error("failed to write credentials file", zap.Error(err))
-		return err
+		os.RemoveAll(homeDir)
+		return "", err
 	}
 
-	return nil
+	return homeDir, nil
 }
 
 // push publishes a gem to the target registry
-func (p *RubyGemsProvider) push(owner, dir, gemFile string) error {
-	// Ensure gem credentials are set up
-	if err := p.ensureGemCredentials(nil); err != nil {
+func (p *RubyGemsProvider) push(logger *zap.Logger, owner, dir, gemFile string) error {
+	// Point the gem CLI at a throwaway home so credentials live only for this push
+	gemHome, err := p.writeGemCredentials(logger)
+	if err != nil {
 		return fmt.Errorf("failed to setup gem credentials: %w", err)
 	}
+	defer os.RemoveAll(gemHome)
+
 	// Run gem publish
-	pushUrl := *p.TargetRegistryUrl
-	pushUrl.Path = path.Join(pushUrl.Path, owner)
+	pushUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, owner)
 	pushCmd := exec.Command("gem", "push", "--key", "github", "--host", pushUrl.String(), gemFile)
 	pushCmd.Dir = dir
-	pushCmd.Env = append(os.Environ(), "HTTPS_PROXY=", "GITHUB_TOKEN="+viper.GetString("GHMPKG_TARGET_TOKEN"))
+	pushCmd.Env = append(os.Environ(), "HTTPS_PROXY=", "HOME="+gemHome, "GITHUB_TOKEN="+utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType))
 
 	// Capture output to gemlog file
 	pushLogFile, err := os.Create(filepath.Join(pushCmd.Dir, "gempush.log"))
@@ -147,15 +171,18 @@ func (p *RubyGemsProvider) Upload(logger *zap.Logger, owner, repository, package
 			return p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
 		},
 		func(uploadUrl, packageDir string) (ResultState, error) {
-			// Extract the gem file
+			gemBasename := strings.TrimSuffix(filename, ".gem")
+			gemUnpackedDir := filepath.Join(packageDir, gemBasename)
+
+			// Extract the gem file, discarding anything left over from a failed run
+			if err := os.RemoveAll(gemUnpackedDir); err != nil {
+				return Failed, fmt.Errorf("failed to remove stale unpacked gem: %w", err)
+			}
 			cmd := exec.Command("gem", "unpack", filename)
 			cmd.Dir = packageDir
 			if err := cmd.Run(); err != nil {
 				return Failed, fmt.Errorf("failed to extract package: %w", err)
 			}
-
-			gemBasename := strings.TrimSuffix(filename, ".gem")
-			gemUnpackedDir := filepath.Join(packageDir, gemBasename)
 			possibleGemFiles := []string{
 				gemBasename,
 				packageName,
@@ -191,19 +218,26 @@ func (p *RubyGemsProvider) Upload(logger *zap.Logger, owner, repository, package
 					return Failed, fmt.Errorf("failed to build package: %w", err)
 				}
 
-				if err = p.push(owner, gemUnpackedDir, fmt.Sprintf("%s-%s.gem", packageName, version)); err != nil {
+				if err = p.push(logger, owner, gemUnpackedDir, fmt.Sprintf("%s-%s.gem", packageName, version)); err != nil {
 					logger.Error("Failed to push package", zap.Error(err))
 					return Failed, err
 				}
+				buildLogFile.Close()
+				p.recordTargetFile(logger, repository, packageName, version, filename, filepath.Join(gemUnpackedDir, fmt.Sprintf("%s-%s.gem", packageName, version)))
+
+				p.removeWorkFiles(logger, gemUnpackedDir, filepath.Join(packageDir, "gembuild.log"))
 
 				return Success, nil
 			}
 
 			logger.Warn("Gemspec file not found, pushing what was downloaded", zap.String("possibleGemFiles", fmt.Sprintf("%v", possibleGemFiles)))
-			if err := p.push(owner, packageDir, filename); err != nil {
+			if err := p.push(logger, owner, packageDir, filename); err != nil {
 				logger.Error("Failed to push package", zap.Error(err))
 				return Failed, err
 			}
+			p.recordTargetFile(logger, repository, packageName, version, filename, filepath.Join(packageDir, filename))
+
+			p.removeWorkFiles(logger, gemUnpackedDir, filepath.Join(packageDir, "gempush.log"))
 
 			return Success, nil
 		},
@@ -212,14 +246,12 @@ func (p *RubyGemsProvider) Upload(logger *zap.Logger, owner, repository, package
 
 // GetDownloadUrl generates the URL for downloading a gem from the source registry
 func (p *RubyGemsProvider) GetDownloadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
-	downloadUrl := *p.SourceRegistryUrl
-	downloadUrl.Path = path.Join(downloadUrl.Path, owner, "gems", filename)
+	downloadUrl := utils.JoinUrlPath(*p.SourceRegistryUrl, owner, "gems", filename)
 	return downloadUrl.String(), nil
 }
 
 // GetUploadUrl generates the URL for uploading a gem to the target registry
 func (p *RubyGemsProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version string, filename string) (string, error) {
-	uploadUrl := *p.TargetRegistryUrl
-	uploadUrl.Path = path.Join(uploadUrl.Path, owner, repository, packageName, version, filename)
+	uploadUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, owner, TargetRepository(repository), packageName, version, filename)
 	return uploadUrl.String(), nil
 }
@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// GRAPHQL_PAGE_SIZE is the number of packages, versions and files the GraphQL
+// crawl asks for per page before any timeout
+const GRAPHQL_PAGE_SIZE = 10
+
+// graphqlRetryDelay is the pause before a query is retried with a smaller page
+var graphqlRetryDelay = time.Second
+
+// IsGraphQLTimeout reports whether a GraphQL query failed because GitHub could not
+// resolve it in time, which a smaller page usually avoids
+func IsGraphQLTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range []string{
+		"timeout",
+		"timed out",
+		"deadline exceeded",
+		"something went wrong while executing your query",
+		"502 bad gateway",
+		"504 gateway timeout",
+	} {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryInChunks runs a page of a GraphQL query, halving the page size each time
+// it times out down to a single node. It returns the page size that worked so the
+// following pages of the same connection keep using it.
+func QueryInChunks(logger *zap.Logger, first int, query func(first int) error) (int, error) {
+	for {
+		err := query(first)
+		if err == nil || !IsGraphQLTimeout(err) {
+			return first, err
+		}
+		if first <= 1 {
+			return first, fmt.Errorf("query timed out with a single node per page: %w", err)
+		}
+		first /= 2
+		logger.Warn("GraphQL query timed out, retrying with a smaller page",
+			zap.Int("pageSize", first),
+			zap.Error(err))
+		time.Sleep(graphqlRetryDelay)
+	}
+}
+
+// ManualExport is a package the GraphQL crawl could not list the files of, even
+// one node at a time. Its versions have to be exported by hand.
+type ManualExport struct {
+	PackageType string
+	Name        string
+	Reason      string
+}
+
+var (
+	manualExportsMu sync.Mutex
+	manualExports   = map[string]ManualExport{}
+)
+
+// RecordManualExport records a package whose files could not be listed
+func RecordManualExport(packageType, name string, err error) {
+	manualExportsMu.Lock()
+	defer manualExportsMu.Unlock()
+	manualExports[packageType+"|"+name] = ManualExport{PackageType: packageType, Name: name, Reason: err.Error()}
+}
+
+// NeedsManualExport returns why the files of a package could not be listed, if so
+func NeedsManualExport(packageType, name string) error {
+	manualExportsMu.Lock()
+	defer manualExportsMu.Unlock()
+	if export, ok := manualExports[packageType+"|"+name]; ok {
+		return errors.New(export.Reason)
+	}
+	return nil
+}
+
+// ManualExports lists the packages of a package type that need a manual export, by name
+func ManualExports(packageType string) []ManualExport {
+	manualExportsMu.Lock()
+	defer manualExportsMu.Unlock()
+	var exports []ManualExport
+	for _, export := range manualExports {
+		if export.PackageType == packageType {
+			exports = append(exports, export)
+		}
+	}
+	sort.Slice(exports, func(i, j int) bool { return exports[i].Name < exports[j].Name })
+	return exports
+}
@@ -0,0 +1,67 @@
+package providers_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"go.uber.org/zap"
+)
+
+func TestIsGraphQLTimeout(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{errors.New("Something went wrong while executing your query. This may be the result of a timeout, or it could be a GitHub bug."), true},
+		{errors.New("non-200 OK status code: 502 Bad Gateway body: \"\""), true},
+		{errors.New("Post \"https://api.github.com/graphql\": context deadline exceeded"), true},
+		{errors.New("Could not resolve to an Organization with the login of 'mona'."), false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := providers.IsGraphQLTimeout(test.err); got != test.expected {
+			t.Errorf("IsGraphQLTimeout(%v) = %v, expected %v", test.err, got, test.expected)
+		}
+	}
+}
+
+func TestQueryInChunks(t *testing.T) {
+	timeout := errors.New("Something went wrong while executing your query. This may be the result of a timeout")
+
+	var sizes []int
+	first, err := providers.QueryInChunks(zap.NewNop(), 10, func(first int) error {
+		sizes = append(sizes, first)
+		if first > 5 {
+			return timeout
+		}
+		return nil
+	})
+	if err != nil || first != 5 || len(sizes) != 2 {
+		t.Errorf("QueryInChunks = %d, %v after pages %v, expected 5", first, err, sizes)
+	}
+
+	if _, err := providers.QueryInChunks(zap.NewNop(), 1, func(int) error { return timeout }); !providers.IsGraphQLTimeout(err) {
+		t.Errorf("QueryInChunks with a single node = %v, expected a timeout", err)
+	}
+
+	calls := 0
+	denied := errors.New("Resource not accessible by integration")
+	if _, err := providers.QueryInChunks(zap.NewNop(), 10, func(int) error { calls++; return denied }); !errors.Is(err, denied) || calls != 1 {
+		t.Errorf("QueryInChunks retried %d times on %v", calls, err)
+	}
+}
+
+func TestManualExports(t *testing.T) {
+	providers.RecordManualExport("maven", "com.example.huge", errors.New("query timed out"))
+	if err := providers.NeedsManualExport("maven", "com.example.huge"); err == nil {
+		t.Error("NeedsManualExport did not return the recorded package")
+	}
+	if err := providers.NeedsManualExport("npm", "com.example.huge"); err != nil {
+		t.Errorf("NeedsManualExport returned %v for another package type", err)
+	}
+	exports := providers.ManualExports("maven")
+	if len(exports) != 1 || exports[0].Name != "com.example.huge" {
+		t.Errorf("ManualExports = %v", exports)
+	}
+}
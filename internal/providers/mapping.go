@@ -0,0 +1,279 @@
+package providers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// MAPPING_KINDS are the kinds of rows of a CSV mapping file
+var MAPPING_KINDS = []string{"repository", "package", "scope", "prefix"}
+
+// renamedPackageTypes are the package types whose packages can be published
+// under another name. Maven coordinates and gem names live inside the files
+// they are published from, which sync does not rebuild.
+var renamedPackageTypes = []string{"container", "docker", "npm", "nuget"}
+
+// Mapping renames what sync publishes on the target, for restructurings an
+// organization swap can't express, such as merging two organizations into one
+// with their package names prefixed:
+//
+//	prefix: team-a-
+//	repositories:
+//	  api: team-a-api
+//	packages:
+//	  npm/ui-kit: design-system
+//	scopes:
+//	  team-b: acme
+//
+// Repositories and packages are matched case-insensitively by their name on
+// the source, packages optionally as type/name. Packages without an entry get
+// the prefix. Scopes are other npm scopes the packages depend on, rewritten
+// like the source organization's own scope.
+type Mapping struct {
+	Prefix       string            `yaml:"prefix"`
+	Repositories map[string]string `yaml:"repositories"`
+	Packages     map[string]string `yaml:"packages"`
+	Scopes       map[string]string `yaml:"scopes"`
+
+	// repositoryPattern matches a mapped repository name at the start of a
+	// string, on a name boundary and with or without .git
+	repositoryPattern *regexp.Regexp
+	compileOnce       sync.Once
+}
+
+// LoadMapping reads a mapping file, CSV when its extension is .csv with
+// kind,source,target rows and YAML otherwise
+func LoadMapping(path string) (*Mapping, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %w", err)
+	}
+	mapping := &Mapping{}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = mapping.readCSV(string(content))
+	} else if err = yaml.Unmarshal(content, mapping); err != nil {
+		err = fmt.Errorf("failed to parse mapping file %s: %w", path, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("invalid mapping file %s: %w", path, err)
+	}
+	mapping.compileOnce.Do(mapping.compile)
+	return mapping, nil
+}
+
+// compile builds the pattern of the mapped repositories
+func (m *Mapping) compile() {
+	if len(m.Repositories) == 0 {
+		return
+	}
+	names := make([]string, 0, len(m.Repositories))
+	for source := range m.Repositories {
+		names = append(names, regexp.QuoteMeta(source))
+	}
+	m.repositoryPattern = regexp.MustCompile(`(?i)^(` + strings.Join(names, "|") + `)(?:\.git)?(?:[^A-Za-z0-9_.\-]|$)`)
+}
+
+// readCSV reads kind,source,target rows, the header is optional
+func (m *Mapping) readCSV(content string) error {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to parse mapping file: %w", err)
+	}
+	for i, row := range rows {
+		if i == 0 && len(row) > 0 && strings.EqualFold(row[0], "kind") {
+			continue
+		}
+		if len(row) != 3 {
+			return fmt.Errorf("mapping row %d has %d columns, expected kind,source,target", i+1, len(row))
+		}
+		kind, source, target := strings.ToLower(strings.TrimSpace(row[0])), strings.TrimSpace(row[1]), strings.TrimSpace(row[2])
+		switch kind {
+		case "repository":
+			m.Repositories = setEntry(m.Repositories, source, target)
+		case "package":
+			m.Packages = setEntry(m.Packages, source, target)
+		case "scope":
+			m.Scopes = setEntry(m.Scopes, source, target)
+		case "prefix":
+			m.Prefix = target
+		default:
+			return fmt.Errorf("mapping row %d has unknown kind %q, expected one of: %s", i+1, kind, strings.Join(MAPPING_KINDS, ", "))
+		}
+	}
+	return nil
+}
+
+func setEntry(entries map[string]string, source, target string) map[string]string {
+	if entries == nil {
+		entries = make(map[string]string)
+	}
+	entries[source] = target
+	return entries
+}
+
+// validate refuses empty names and renames of packages that can't be renamed
+func (m *Mapping) validate() error {
+	for _, entries := range []map[string]string{m.Repositories, m.Packages, m.Scopes} {
+		for source, target := range entries {
+			if source == "" || target == "" {
+				return fmt.Errorf("empty name in %q: %q", source, target)
+			}
+		}
+	}
+	for source := range m.Packages {
+		// Image names hold slashes too, only a package type is a type prefix
+		if packageType, _, ok := strings.Cut(source, "/"); ok && providerLookup[packageType] != nil && !utils.Contains(renamedPackageTypes, packageType) {
+			return fmt.Errorf("%s packages cannot be renamed: %s", packageType, source)
+		}
+	}
+	return nil
+}
+
+func lookupFold(entries map[string]string, key string) (string, bool) {
+	if value, ok := entries[key]; ok {
+		return value, true
+	}
+	for source, target := range entries {
+		if strings.EqualFold(source, key) {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// IsEmpty reports whether the mapping renames nothing
+func (m *Mapping) IsEmpty() bool {
+	return m == nil || m.Prefix == "" && len(m.Repositories) == 0 && len(m.Packages) == 0 && len(m.Scopes) == 0
+}
+
+// Repository is the name a repository has on the target
+func (m *Mapping) Repository(repository string) string {
+	if m == nil || repository == "" {
+		return repository
+	}
+	if target, ok := lookupFold(m.Repositories, repository); ok {
+		return target
+	}
+	return repository
+}
+
+// PackageName is the name a package is published under on the target
+func (m *Mapping) PackageName(packageType, packageName string) string {
+	if m == nil || !utils.Contains(renamedPackageTypes, packageType) {
+		return packageName
+	}
+	if target, ok := lookupFold(m.Packages, packageType+"/"+packageName); ok {
+		return target
+	}
+	if target, ok := lookupFold(m.Packages, packageName); ok {
+		return target
+	}
+	return m.Prefix + packageName
+}
+
+// UnprefixedTypes returns the package types among packageTypes the prefix of
+// the mapping is not applied to, as their packages cannot be renamed
+func (m *Mapping) UnprefixedTypes(packageTypes []string) []string {
+	if m == nil || m.Prefix == "" {
+		return nil
+	}
+	var unprefixed []string
+	for _, packageType := range packageTypes {
+		if !utils.Contains(renamedPackageTypes, packageType) {
+			unprefixed = append(unprefixed, packageType)
+		}
+	}
+	return unprefixed
+}
+
+// Scope is the npm scope a scope of another source organization becomes
+func (m *Mapping) Scope(scope string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	return lookupFold(m.Scopes, scope)
+}
+
+// RepositoryUrls points the URLs of mapped repositories under sourcePrefix, such
+// as https://github.com/<source org>/, at their target repository under
+// targetPrefix. Repositories are matched on name boundaries, with or without .git.
+func (m *Mapping) RepositoryUrls(content, sourcePrefix, targetPrefix string) string {
+	if m == nil || sourcePrefix == "" {
+		return content
+	}
+	m.compileOnce.Do(m.compile)
+	if m.repositoryPattern == nil {
+		return content
+	}
+	var out strings.Builder
+	for i := 0; i < len(content); {
+		if end := i + len(sourcePrefix); end <= len(content) && strings.EqualFold(content[i:end], sourcePrefix) {
+			if match := m.repositoryPattern.FindStringSubmatchIndex(content[end:]); match != nil {
+				target, _ := lookupFold(m.Repositories, content[end+match[2]:end+match[3]])
+				out.WriteString(targetPrefix + target)
+				i = end + match[3]
+				continue
+			}
+		}
+		out.WriteByte(content[i])
+		i++
+	}
+	return out.String()
+}
+
+// mappingCache holds the mapping of the GHMPKG_MAPPING_FILE it was loaded from
+var mappingCache struct {
+	mu      sync.Mutex
+	path    string
+	mapping *Mapping
+	err     error
+}
+
+// TargetMapping returns the mapping of GHMPKG_MAPPING_FILE, nil when it is not
+// set. The file is read once.
+func TargetMapping() (*Mapping, error) {
+	path := viper.GetString("GHMPKG_MAPPING_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	mappingCache.mu.Lock()
+	defer mappingCache.mu.Unlock()
+	if mappingCache.path != path {
+		mappingCache.path = path
+		mappingCache.mapping, mappingCache.err = LoadMapping(path)
+	}
+	return mappingCache.mapping, mappingCache.err
+}
+
+// currentMapping is the mapping providers apply. Sync refuses to start on an
+// invalid mapping file, so errors are reported there.
+func currentMapping() *Mapping {
+	mapping, _ := TargetMapping()
+	return mapping
+}
+
+// TargetRepository is the name a repository has on the target
+func TargetRepository(repository string) string {
+	return currentMapping().Repository(repository)
+}
+
+// TargetPackageName is the name a package is published under on the target
+func TargetPackageName(packageType, packageName string) string {
+	return currentMapping().PackageName(packageType, packageName)
+}
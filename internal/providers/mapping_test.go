@@ -0,0 +1,141 @@
+package providers_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+)
+
+func writeMapping(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write mapping file: %v", err)
+	}
+	return path
+}
+
+func TestLoadMapping(t *testing.T) {
+	yamlPath := writeMapping(t, "mapping.yaml", `prefix: team-a-
+repositories:
+  api: team-a-api
+packages:
+  npm/ui-kit: design-system
+  tools: shared-tools
+scopes:
+  team-b: acme
+`)
+	csvPath := writeMapping(t, "mapping.csv", `kind,source,target
+# merged from team-a
+prefix,,team-a-
+repository,api,team-a-api
+package,npm/ui-kit,design-system
+package,tools,shared-tools
+scope,team-b,acme
+`)
+
+	for _, path := range []string{yamlPath, csvPath} {
+		mapping, err := providers.LoadMapping(path)
+		if err != nil {
+			t.Fatalf("LoadMapping(%s) returned an error: %v", filepath.Base(path), err)
+		}
+		checks := []struct{ got, expected string }{
+			{mapping.Repository("API"), "team-a-api"},
+			{mapping.Repository("web"), "web"},
+			{mapping.PackageName("npm", "UI-Kit"), "design-system"},
+			{mapping.PackageName("nuget", "ui-kit"), "team-a-ui-kit"},
+			{mapping.PackageName("container", "tools"), "shared-tools"},
+			{mapping.PackageName("container", "api/server"), "team-a-api/server"},
+			{mapping.PackageName("maven", "com.example.app"), "com.example.app"},
+		}
+		for _, check := range checks {
+			if check.got != check.expected {
+				t.Errorf("%s: got %q, expected %q", filepath.Base(path), check.got, check.expected)
+			}
+		}
+		if scope, ok := mapping.Scope("Team-B"); !ok || scope != "acme" {
+			t.Errorf("%s: Scope(Team-B) returned %q, %v", filepath.Base(path), scope, ok)
+		}
+		if unprefixed := mapping.UnprefixedTypes([]string{"maven", "npm", "rubygems"}); strings.Join(unprefixed, ",") != "maven,rubygems" {
+			t.Errorf("%s: UnprefixedTypes returned %v", filepath.Base(path), unprefixed)
+		}
+	}
+}
+
+func TestLoadMappingErrors(t *testing.T) {
+	tests := []struct {
+		name, filename, content, expected string
+	}{
+		{"maven rename", "mapping.yaml", "packages:\n  maven/com.example.app: com.acme.app\n", "maven packages cannot be renamed"},
+		{"empty target", "mapping.yaml", "repositories:\n  api: \"\"\n", "empty name"},
+		{"unknown kind", "mapping.csv", "team,a,b\n", "unknown kind"},
+		{"missing column", "mapping.csv", "repository,api\n", "expected kind,source,target"},
+	}
+	for _, test := range tests {
+		_, err := providers.LoadMapping(writeMapping(t, test.filename, test.content))
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: got %v, expected an error containing %q", test.name, err, test.expected)
+		}
+	}
+}
+
+func TestMappingRepositoryUrls(t *testing.T) {
+	mapping := &providers.Mapping{Repositories: map[string]string{"api": "team-a-api", "web.app": "team-a-web", "cli": "api"}}
+	tests := []struct{ content, expected string }{
+		{"https://github.com/mona/api", "https://github.com/octo/team-a-api"},
+		{"https://github.com/mona/web.app.git", "https://github.com/octo/team-a-web.git"},
+		{"https://github.com/mona/cli and https://github.com/mona/api", "https://github.com/octo/api and https://github.com/octo/team-a-api"},
+		{"git+https://github.com/mona/api.git#main", "git+https://github.com/octo/team-a-api.git#main"},
+		{`"url": "https://github.com/Mona/API/tree/main"`, `"url": "https://github.com/octo/team-a-api/tree/main"`},
+		{"https://github.com/mona/api-client", "https://github.com/mona/api-client"},
+		{"https://github.com/other/api", "https://github.com/other/api"},
+	}
+	for _, test := range tests {
+		if got := mapping.RepositoryUrls(test.content, "https://github.com/mona/", "https://github.com/octo/"); got != test.expected {
+			t.Errorf("RepositoryUrls(%q) returned %q, expected %q", test.content, got, test.expected)
+		}
+	}
+}
+
+func TestMappedUploadUrls(t *testing.T) {
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+	viper.Set("GHMPKG_MAPPING_FILE", writeMapping(t, "mapping.yaml", `prefix: team-a-
+repositories:
+  repo: team-a-repo
+packages:
+  npm/package: renamed
+`))
+	defer viper.Reset()
+
+	checkUrls(t, "npm", []urlTest{
+		{
+			name: "mapped package", owner: "octo", repository: "repo", packageName: "package", version: "1.0.0", filename: "package-1.0.0.tgz",
+			download: "https://npm.pkg.github.com/download/@octo/package/1.0.0/package-1.0.0.tgz",
+			upload:   "https://npm.pkg.github.com/@octo%2Frenamed",
+		},
+		{
+			name: "prefixed package", owner: "octo", repository: "repo", packageName: "other", version: "1.0.0", filename: "other-1.0.0.tgz",
+			download: "https://npm.pkg.github.com/download/@octo/other/1.0.0/other-1.0.0.tgz",
+			upload:   "https://npm.pkg.github.com/@octo%2Fteam-a-other",
+		},
+	})
+	checkUrls(t, "maven", []urlTest{
+		{
+			name: "mapped repository", owner: "mona", repository: "repo", packageName: "com.example.app", version: "1.0", filename: "app-1.0.jar",
+			download: "https://maven.pkg.github.com/mona/repo/com.example.app/1.0/app-1.0.jar",
+			upload:   "https://maven.pkg.github.com/octo/team-a-repo/com.example.app/1.0/app-1.0.jar",
+		},
+	})
+	checkUrls(t, "container", []urlTest{
+		{
+			name: "prefixed image", owner: "octo", repository: "repo", packageName: "app", version: "1.0", filename: "app:1.0",
+			download: "ghcr.io/octo/app:1.0",
+			upload:   "ghcr.io/octo/team-a-app:1.0",
+		},
+	})
+}
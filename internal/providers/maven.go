@@ -2,10 +2,17 @@ package providers
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -26,6 +33,10 @@ type MavenProvider struct {
 	client       *githubv4.Client
 	ctx          context.Context
 	packageFiles []PackageNode
+	// packageFileIndex indexes packageFiles, with their checksums and sizes
+	packageFileIndex map[string]FileNode
+	// packageFilesMu guards the lazily fetched packageFiles
+	packageFilesMu sync.Mutex
 }
 
 // Constructor
@@ -49,13 +60,20 @@ func (p *MavenProvider) Connect(logger *zap.Logger) error {
 
 // FetchPackageFiles retrieves package files information from GitHub GraphQL API
 func (p *MavenProvider) FetchPackageFiles(logger *zap.Logger, owner, repository, packageType, packageName, version string, metadata *github.PackageMetadata) ([]string, ResultState, error) {
+	p.packageFilesMu.Lock()
 	if p.packageFiles == nil || len(p.packageFiles) == 0 {
-		packageFiles, _, err := FetchFromGraphQL(logger, owner, viper.GetString("GHMPKG_SOURCE_TOKEN"), string(p.PackageType))
+		packageFiles, _, err := FetchFromGraphQL(logger, owner, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", p.PackageType), string(p.PackageType))
 		if err != nil {
+			p.packageFilesMu.Unlock()
 			return nil, Failed, err
 		}
 		p.packageFiles = packageFiles
 	}
+	p.packageFilesMu.Unlock()
+
+	if err := NeedsManualExport(p.PackageType, packageName); err != nil {
+		return nil, Failed, fmt.Errorf("files of %s could not be listed, export it manually: %w", packageName, err)
+	}
 
 	var filenames []string
 	for _, cachedPkg := range p.packageFiles {
@@ -77,6 +95,10 @@ func (p *MavenProvider) FetchPackageFiles(logger *zap.Logger, owner, repository,
 
 // Download retrieves a Maven artifact from the source registry
 func (p *MavenProvider) Download(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	// The source checksums would not match the rewritten poms, sync regenerates them
+	if IsMavenChecksum(filename) {
+		return Skip(SkipChecksumRegenerated, filename)
+	}
 	return p.downloadPackage(
 		logger, owner, repository, packageType, packageName, version, filename, nil,
 		// URL generator function
@@ -85,7 +107,7 @@ func (p *MavenProvider) Download(logger *zap.Logger, owner, repository, packageT
 		},
 		// Download function
 		func(downloadUrl, outputPath string) (ResultState, error) {
-			if err := utils.DownloadFile(downloadUrl, outputPath, viper.GetString("GHMPKG_SOURCE_TOKEN")); err != nil {
+			if err := utils.DownloadFile(downloadUrl, outputPath, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", p.PackageType)); err != nil {
 				return Failed, err
 			}
 			return Success, nil
@@ -100,9 +122,10 @@ func (p *MavenProvider) Rename(logger *zap.Logger, repository, packageName, vers
 		return nil
 	}
 
-	// Check if the file is a pom.xml or any .pom file
-	if !strings.HasSuffix(filename, "pom.xml") && !strings.HasSuffix(filename, ".pom") {
-		logger.Debug("File is not a pom file, skipping",
+	// Only pom files and Gradle module metadata reference the organization
+	rewrite := p.rewriter(filename)
+	if rewrite == nil {
+		logger.Debug("File is not a pom or Gradle module file, skipping",
 			zap.String("filename", filename))
 		return nil
 	}
@@ -110,22 +133,15 @@ func (p *MavenProvider) Rename(logger *zap.Logger, repository, packageName, vers
 	// Read the file content
 	content, err := os.ReadFile(filename)
 	if err != nil {
-		logger.Warn("Failed to read pom file",
+		logger.Warn("Failed to read maven metadata file",
 			zap.String("filename", filename),
 			zap.Error(err))
 		return nil // Continue with warning
 	}
 
-	// Create the search and replace strings
-	sourceUrl := fmt.Sprintf("https://maven.pkg.github.com/%s/packages", viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
-	targetUrl := fmt.Sprintf("https://maven.pkg.github.com/%s/packages", viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
-
-	// Replace the content
-	newContent := strings.ReplaceAll(string(content), sourceUrl, targetUrl)
-
 	// Write the file back
-	if err := os.WriteFile(filename, []byte(newContent), 0644); err != nil {
-		logger.Warn("Failed to write updated pom file",
+	if err := utils.WriteFileAtomic(filename, rewrite(content), 0644); err != nil {
+		logger.Warn("Failed to write updated maven metadata file",
 			zap.String("filename", filename),
 			zap.Error(err))
 		return nil // Continue with warning
@@ -139,12 +155,123 @@ func (p *MavenProvider) Rename(logger *zap.Logger, repository, packageName, vers
 	return nil
 }
 
+// rewriter returns how the content of a file is pointed at the target
+// organization, nil for the files that are uploaded as they are
+func (p *MavenProvider) rewriter(filename string) func([]byte) []byte {
+	switch {
+	case strings.HasSuffix(filename, "pom.xml"), strings.HasSuffix(filename, ".pom"):
+		return rewritePom
+	case strings.HasSuffix(filename, ".module"):
+		return p.rewriteModule
+	}
+	return nil
+}
+
+// rewritePom points the repository URLs of a pom at the target organization
+func rewritePom(content []byte) []byte {
+	sourceUrl := fmt.Sprintf("https://maven.pkg.github.com/%s/packages", viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
+	targetUrl := fmt.Sprintf("https://maven.pkg.github.com/%s/packages", viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
+	return []byte(strings.ReplaceAll(string(content), sourceUrl, targetUrl))
+}
+
+// rewriteModule points the absolute URLs of a Gradle module metadata file, such
+// as the available-at URL of variants published in another module, at the
+// target organization. The URLs of mapped repositories are pointed at their
+// target repository first.
+func (p *MavenProvider) rewriteModule(content []byte) []byte {
+	sourceOrg := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOrg := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	replacements := [][2]url.URL{
+		{utils.JoinUrlPath(*p.SourceRegistryUrl, sourceOrg), utils.JoinUrlPath(*p.TargetRegistryUrl, targetOrg)},
+		{utils.JoinUrlPath(*p.SourceHostnameUrl, sourceOrg), utils.JoinUrlPath(*p.TargetHostnameUrl, targetOrg)},
+	}
+	module := string(content)
+	for _, replacement := range replacements {
+		sourceUrl, targetUrl := replacement[0].String()+"/", replacement[1].String()+"/"
+		module = currentMapping().RepositoryUrls(module, sourceUrl, targetUrl)
+		module = strings.ReplaceAll(module, sourceUrl, targetUrl)
+	}
+	return []byte(module)
+}
+
+// MAVEN_CHECKSUM_EXTENSIONS are the checksum companions Maven builds verify,
+// regenerated for every uploaded artifact
+var MAVEN_CHECKSUM_EXTENSIONS = []string{".md5", ".sha1", ".sha256", ".sha512"}
+
+// IsMavenChecksum reports whether a file is the checksum companion of another.
+// The source ones no longer match once poms are rewritten.
+func IsMavenChecksum(filename string) bool {
+	for _, extension := range MAVEN_CHECKSUM_EXTENSIONS {
+		if strings.HasSuffix(filename, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// mavenChecksums hashes the uploaded content of an artifact with every
+// algorithm of MAVEN_CHECKSUM_EXTENSIONS
+type mavenChecksums struct {
+	hashes map[string]hash.Hash
+}
+
+func newMavenChecksums() *mavenChecksums {
+	return &mavenChecksums{hashes: map[string]hash.Hash{
+		".md5":    md5.New(),
+		".sha1":   sha1.New(),
+		".sha256": sha256.New(),
+		".sha512": sha512.New(),
+	}}
+}
+
+func (c *mavenChecksums) Write(content []byte) (int, error) {
+	for _, h := range c.hashes {
+		h.Write(content)
+	}
+	return len(content), nil
+}
+
+// upload PUTs the checksum companions next to the artifact uploaded to uploadUrl.
+// Companions the target already has are left as they are.
+func (c *mavenChecksums) upload(logger *zap.Logger, packageType, uploadUrl, filename string) error {
+	for _, extension := range MAVEN_CHECKSUM_EXTENSIONS {
+		checksum := hex.EncodeToString(c.hashes[extension].Sum(nil))
+		response, err := utils.UploadStream(uploadUrl+extension, filename+extension, strings.NewReader(checksum), int64(len(checksum)), utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", packageType))
+		if err != nil {
+			return fmt.Errorf("failed to upload %s%s: %w", filename, extension, err)
+		}
+		response.Body.Close()
+		if response.StatusCode > 299 && response.StatusCode != http.StatusConflict {
+			return fmt.Errorf("error uploading file: %s%s, status: %s", filename, extension, response.Status)
+		}
+		logger.Debug("Uploaded checksum", zap.String("url", uploadUrl+extension))
+	}
+	return nil
+}
+
+// uploadChecksums hashes an uploaded file and uploads its checksum companions
+func (p *MavenProvider) uploadChecksums(logger *zap.Logger, uploadUrl, filename, inputPath string) error {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	checksums := newMavenChecksums()
+	if _, err := io.Copy(checksums, file); err != nil {
+		return err
+	}
+	return checksums.upload(logger, p.PackageType, uploadUrl, filename)
+}
+
 // Upload sends a Maven artifact to the target registry
 func (p *MavenProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	// Checksum companions are uploaded along with the artifact they describe
+	if IsMavenChecksum(filename) {
+		return Skip(SkipChecksumRegenerated, filename)
+	}
 
-	// Create a semaphore with size 5 to limit concurrent uploads
-	const maxConcurrent = 5
-	sem := make(chan struct{}, maxConcurrent)
+	// Limit concurrent uploads to GHMPKG_FILE_CONCURRENCY
+	sem := make(chan struct{}, FileConcurrency(packageType))
 
 	// Start upload in goroutine
 	resultChan := make(chan struct {
@@ -174,16 +301,21 @@ func (p *MavenProvider) Upload(logger *zap.Logger, owner, repository, packageTyp
 					// Continue with upload even if rename fails
 				}
 
-				response, err := utils.UploadFile(uploadPackageUrl, inputPath, viper.GetString("GHMPKG_TARGET_TOKEN"))
+				response, err := utils.UploadFile(uploadPackageUrl, inputPath, utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType))
 				if err != nil {
 					return Failed, err
 				}
 
 				if response.StatusCode == http.StatusConflict {
-					return Skipped, nil
+					return Skip(SkipExistsOnTarget, fmt.Sprintf("%s is already published", filename))
 				} else if response.StatusCode > 299 {
 					return Failed, fmt.Errorf("error uploading file: %s", filename)
 				}
+				p.recordTargetFile(logger, repository, packageName, version, filename, inputPath)
+				p.recordUploadDigest(repository, packageName, version, filename, digestOutcome(response))
+				if err := p.uploadChecksums(logger, uploadPackageUrl, filename, inputPath); err != nil {
+					return Failed, err
+				}
 				return Success, nil
 			},
 		)
@@ -201,13 +333,14 @@ func (p *MavenProvider) Upload(logger *zap.Logger, owner, repository, packageTyp
 // Batch Operations
 // ---------------
 
-// UploadBatch handles concurrent upload of multiple Maven artifacts
-func (p *MavenProvider) UploadBatch(logger *zap.Logger, owner, repository, packageType, packageName, version string, filenames []string) ([]ResultState, error) {
-	const maxConcurrent = 5
+// UploadBatch handles concurrent upload of multiple Maven artifacts. Along with
+// the result of every file it returns the SkipError of the skipped ones.
+func (p *MavenProvider) UploadBatch(logger *zap.Logger, owner, repository, packageType, packageName, version string, filenames []string) ([]ResultState, []error, error) {
 	results := make([]ResultState, len(filenames))
+	skips := make([]error, len(filenames))
 	errChan := make(chan error, len(filenames))
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, maxConcurrent)
+	sem := make(chan struct{}, FileConcurrency(packageType))
 
 	for i, filename := range filenames {
 		wg.Add(1)
@@ -217,7 +350,10 @@ func (p *MavenProvider) UploadBatch(logger *zap.Logger, owner, repository, packa
 			defer func() { <-sem }() // Release semaphore
 
 			state, err := p.Upload(logger, owner, repository, packageType, packageName, version, fname)
-			if err != nil {
+			if IsSkip(err) {
+				skips[idx] = err
+			} else if err != nil {
+				results[idx] = Failed
 				errChan <- err
 				return
 			}
@@ -231,11 +367,11 @@ func (p *MavenProvider) UploadBatch(logger *zap.Logger, owner, repository, packa
 	// Check for any errors
 	for err := range errChan {
 		if err != nil {
-			return results, err
+			return results, skips, err
 		}
 	}
 
-	return results, nil
+	return results, skips, nil
 }
 
 // URL Generation
@@ -243,15 +379,13 @@ func (p *MavenProvider) UploadBatch(logger *zap.Logger, owner, repository, packa
 
 // GetDownloadUrl generates the URL for downloading a Maven artifact
 func (p *MavenProvider) GetDownloadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
-	downloadUrl := *p.SourceRegistryUrl
-	downloadUrl.Path = path.Join(downloadUrl.Path, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, packageName, version, filename)
+	downloadUrl := utils.JoinUrlPath(*p.SourceRegistryUrl, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"), repository, packageName, version, filename)
 	return downloadUrl.String(), nil
 }
 
 // GetUploadUrl generates the URL for uploading a Maven artifact
 func (p *MavenProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version string, filename string) (string, error) {
-	uploadUrl := *p.TargetRegistryUrl
-	uploadUrl.Path = path.Join(uploadUrl.Path, viper.GetString("GHMPKG_TARGET_ORGANIZATION"), repository, packageName, version, filename)
+	uploadUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, viper.GetString("GHMPKG_TARGET_ORGANIZATION"), TargetRepository(repository), packageName, version, filename)
 	return uploadUrl.String(), nil
 }
 
@@ -0,0 +1,118 @@
+package providers_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestMavenUploadChecksums(t *testing.T) {
+	defer viper.Reset()
+
+	migrationPath := t.TempDir()
+	packageDir := filepath.Join(migrationPath, "packages", "mona", "maven", "com.example.app", "1.0")
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("jar")
+	if err := os.WriteFile(filepath.Join(packageDir, "app-1.0.jar"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	uploaded := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploaded[r.URL.Path] = string(body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	viper.Set("GHMPKG_MIGRATION_PATH", migrationPath)
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "mona")
+
+	provider := connectTarget(t, "maven").(*providers.MavenProvider)
+	provider.TargetRegistryUrl = utils.ParseUrl(server.URL + "/")
+
+	result, err := provider.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar")
+	if err != nil || result != providers.Success {
+		t.Fatalf("Upload = %v, %v", result, err)
+	}
+	sum := sha1.Sum(content)
+	base := "/mona/repo/com.example.app/1.0/app-1.0.jar"
+	if uploaded[base] != "jar" || uploaded[base+".sha1"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("uploaded files = %v", uploaded)
+	}
+	for _, extension := range providers.MAVEN_CHECKSUM_EXTENSIONS {
+		if _, ok := uploaded[base+extension]; !ok {
+			t.Errorf("%s was not uploaded", extension)
+		}
+	}
+
+	result, err = provider.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar.sha1")
+	var skip *providers.SkipError
+	if result != providers.Skipped || !errors.As(err, &skip) || skip.Reason != providers.SkipChecksumRegenerated {
+		t.Errorf("Upload of a checksum = %v, %v, want a checksum_regenerated skip", result, err)
+	}
+}
+
+func TestMavenRenameModule(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+	viper.Set("GHMPKG_MAPPING_FILE", writeMapping(t, "mapping.yaml", "repositories:\n  app: team-a-app\n"))
+
+	module := `{
+  "formatVersion": "1.1",
+  "component": {"group": "com.example", "module": "app", "version": "1.0"},
+  "variants": [
+    {"name": "jvm", "available-at": {"url": "https://maven.pkg.github.com/mona/app/com/example/app-jvm/1.0/app-jvm-1.0.module"}},
+    {"name": "js", "available-at": {"url": "https://maven.pkg.github.com/mona/lib/com/example/app-js/1.0/app-js-1.0.module"}}
+  ],
+  "createdBy": {"gradle": {"buildScan": "https://github.com/mona/app/actions"}}
+}`
+	expected := `{
+  "formatVersion": "1.1",
+  "component": {"group": "com.example", "module": "app", "version": "1.0"},
+  "variants": [
+    {"name": "jvm", "available-at": {"url": "https://maven.pkg.github.com/octo/team-a-app/com/example/app-jvm/1.0/app-jvm-1.0.module"}},
+    {"name": "js", "available-at": {"url": "https://maven.pkg.github.com/octo/lib/com/example/app-js/1.0/app-js-1.0.module"}}
+  ],
+  "createdBy": {"gradle": {"buildScan": "https://github.com/octo/team-a-app/actions"}}
+}`
+	filename := filepath.Join(t.TempDir(), "app-1.0.module")
+	if err := os.WriteFile(filename, []byte(module), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := connectTarget(t, "maven").(*providers.MavenProvider)
+	if err := provider.Rename(zap.NewNop(), "app", "com.example.app", "1.0", filename); err != nil {
+		t.Fatalf("Rename returned an error: %v", err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Errorf("Rename wrote:\n%s\nexpected:\n%s", content, expected)
+	}
+}
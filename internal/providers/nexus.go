@@ -0,0 +1,226 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// NEXUS_PACKAGE_TYPES are the package types that can be published to Nexus
+var NEXUS_PACKAGE_TYPES = []string{"maven", "npm", "nuget"}
+
+// NexusRepositories returns the hosted repository of each package type, from
+// type=repository entries in GHMPKG_NEXUS_REPOS
+func NexusRepositories() (map[string]string, error) {
+	return targetRepositories(TargetNexus, "GHMPKG_NEXUS_REPOS", NEXUS_PACKAGE_TYPES)
+}
+
+// NexusProvider publishes the packages pulled from GitHub to the hosted
+// repositories of a Sonatype Nexus Repository 3 server. Maven files are PUT
+// in the repository layout, npm tarballs and NuGet packages are uploaded with
+// the components API, which reads their name and version from the package.
+type NexusProvider struct {
+	Provider
+	base       BaseProvider
+	baseUrl    *url.URL
+	repository string
+	user       string
+	password   string
+}
+
+func newNexusProvider(provider Provider) *NexusProvider {
+	return &NexusProvider{
+		Provider: provider,
+		base:     NewBaseProvider(provider.GetPackageType(), "", "", false),
+	}
+}
+
+// Connect connects the wrapped provider and checks the Nexus settings
+func (p *NexusProvider) Connect(logger *zap.Logger) error {
+	if err := p.Provider.Connect(logger); err != nil {
+		return err
+	}
+	baseUrl := targetBaseUrl(viper.GetString("GHMPKG_NEXUS_URL"))
+	if baseUrl == "" {
+		return fmt.Errorf("GHMPKG_NEXUS_URL is required to publish to nexus")
+	}
+	p.baseUrl = utils.ParseUrl(baseUrl + "/")
+	p.user = viper.GetString("GHMPKG_NEXUS_USER")
+	p.password = viper.GetString("GHMPKG_NEXUS_PASSWORD")
+	if p.user == "" || p.password == "" {
+		return fmt.Errorf("GHMPKG_NEXUS_USER and GHMPKG_NEXUS_PASSWORD are required to publish to nexus")
+	}
+
+	repositories, err := NexusRepositories()
+	if err != nil {
+		return err
+	}
+	p.repository = repositories[p.base.PackageType]
+	return nil
+}
+
+// assetPath is where Nexus serves a file of a hosted repository, relative to the repository
+func (p *NexusProvider) assetPath(packageName, version, filename string) string {
+	switch p.base.PackageType {
+	case "maven":
+		// GitHub names Maven packages groupId.artifactId
+		return path.Join(strings.ReplaceAll(packageName, ".", "/"), version, filename)
+	case "npm":
+		scope := "@" + strings.ToLower(viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
+		return path.Join(scope, packageName, "-", npmTarballName(packageName, version))
+	case "nuget":
+		return path.Join(packageName, version)
+	}
+	return path.Join(packageName, filename)
+}
+
+// GetUploadUrl returns the URL Nexus serves an uploaded file from
+func (p *NexusProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	if p.repository == "" {
+		return "", fmt.Errorf("no nexus repository is configured for %s packages, set GHMPKG_NEXUS_REPOS", p.base.PackageType)
+	}
+	uploadUrl := utils.JoinUrlPath(*p.baseUrl, append([]string{"repository", p.repository}, strings.Split(p.assetPath(packageName, version, filename), "/")...)...)
+	return uploadUrl.String(), nil
+}
+
+// Upload publishes a pulled file to the Nexus repository of its package type
+func (p *NexusProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	if !utils.Contains(NEXUS_PACKAGE_TYPES, packageType) {
+		return Skip(SkipTargetUnsupported, fmt.Sprintf("%s packages cannot be published to nexus", packageType))
+	}
+	if p.repository == "" {
+		return Skip(SkipTargetUnsupported, fmt.Sprintf("no nexus repository is configured for %s packages", packageType))
+	}
+
+	return p.base.uploadPackage(
+		logger, owner, repository, packageType, packageName, version, filename,
+		func() (string, error) {
+			return p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
+		},
+		func(uploadUrl, packageDir string) (ResultState, error) {
+			localFile := filepath.Join(packageDir, filename)
+			if packageType == "npm" {
+				localFile = stagedPath(packageDir, npmTarballName(packageName, version), filename)
+			}
+			result, digest, outcome, err := p.publish(logger, uploadUrl, localFile)
+			if err == nil && digest != "" {
+				p.base.recordTargetDigest(logger, repository, packageName, version, filename, "sha256:"+digest)
+				if outcome != "" {
+					p.base.recordUploadDigest(repository, packageName, version, filename, outcome)
+				}
+			}
+			return result, err
+		},
+	)
+}
+
+// publish uploads a file unless Nexus already serves it. Maven files are
+// compared by SHA-1, which Nexus returns as the ETag; npm and NuGet versions
+// cannot be replaced, an existing one is skipped. Maven files are PUT with
+// their digest headers, it returns what became of them, empty for the
+// components API, and the SHA-256 of the file.
+func (p *NexusProvider) publish(logger *zap.Logger, uploadUrl, localFile string) (ResultState, string, string, error) {
+	file, err := os.Open(localFile)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return Failed, "", "", err
+	}
+	size := stat.Size()
+	digestHeader, err := utils.DigestHeaders(file)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	digest, sha1Digest := digestHeader.Get("X-Checksum-Sha256"), digestHeader.Get("X-Checksum-Sha1")
+
+	client := utils.NewHTTPClient()
+	head, err := http.NewRequest(http.MethodHead, uploadUrl, nil)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	head.SetBasicAuth(p.user, p.password)
+	if resp, err := client.Do(head); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && (p.base.PackageType != "maven" || strings.Contains(strings.ToLower(resp.Header.Get("ETag")), sha1Digest)) {
+			logger.Info("File already published", zap.String("url", uploadUrl))
+			return Skipped, "", "", nil
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Failed, "", "", err
+	}
+	var req *http.Request
+	if p.base.PackageType == "maven" {
+		req, err = http.NewRequest(http.MethodPut, uploadUrl, file)
+		if err != nil {
+			return Failed, "", "", err
+		}
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		for name, values := range digestHeader {
+			req.Header[name] = values
+		}
+	} else {
+		if req, err = p.componentRequest(file, filepath.Base(localFile)); err != nil {
+			return Failed, "", "", err
+		}
+	}
+	req.SetBasicAuth(p.user, p.password)
+	resp, err := client.Do(req)
+	if err != nil {
+		return Failed, "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Failed, "", "", fmt.Errorf("nexus returned %s for %s: %s", resp.Status, req.URL, strings.TrimSpace(string(body)))
+	}
+	if p.base.PackageType != "maven" {
+		return Success, digest, "", nil
+	}
+	return Success, digest, digestOutcome(resp), nil
+}
+
+// componentRequest streams a package to the components API of the repository,
+// as the <format>.asset field of a multipart form
+func (p *NexusProvider) componentRequest(file io.Reader, filename string) (*http.Request, error) {
+	componentsUrl := utils.JoinUrlPath(*p.baseUrl, "service", "rest", "v1", "components")
+	componentsUrl.RawQuery = url.Values{"repository": {p.repository}}.Encode()
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile(p.base.PackageType+".asset", filename)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, componentsUrl.String(), body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req, nil
+}
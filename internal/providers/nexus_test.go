@@ -0,0 +1,137 @@
+package providers_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestNexusRepositories(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("GHMPKG_NEXUS_REPOS", []string{"maven=maven-releases,npm=npm-hosted"})
+	repositories, err := providers.NexusRepositories()
+	if err != nil || repositories["maven"] != "maven-releases" || repositories["npm"] != "npm-hosted" {
+		t.Errorf("NexusRepositories = %v, %v", repositories, err)
+	}
+
+	viper.Set("GHMPKG_NEXUS_REPOS", []string{"container=docker-hosted"})
+	if _, err := providers.NexusRepositories(); err == nil {
+		t.Error("NexusRepositories accepted a container repository")
+	}
+}
+
+func TestCheckTargetRegistry(t *testing.T) {
+	defer viper.Reset()
+
+	for _, target := range []string{"", "github", "Nexus", "azure", "artifactory"} {
+		viper.Set("GHMPKG_TARGET_REGISTRY", target)
+		if err := providers.CheckTargetRegistry(); err != nil {
+			t.Errorf("CheckTargetRegistry(%q) = %v", target, err)
+		}
+	}
+	if !providers.ExternalTarget() || providers.TargetRegistry() != "artifactory" {
+		t.Error("artifactory is not an external target")
+	}
+	viper.Set("GHMPKG_TARGET_REGISTRY", "github")
+	if providers.ExternalTarget() || providers.TargetRequiredSettings() != nil {
+		t.Error("github is an external target")
+	}
+	viper.Set("GHMPKG_TARGET_REGISTRY", "proget")
+	if err := providers.CheckTargetRegistry(); err == nil {
+		t.Error("CheckTargetRegistry accepted an unknown registry")
+	}
+}
+
+func nexusSettings(url string) {
+	targetSettings("nexus", map[string]interface{}{
+		"GHMPKG_NEXUS_URL":      url,
+		"GHMPKG_NEXUS_USER":     "deployer",
+		"GHMPKG_NEXUS_PASSWORD": "secret",
+		"GHMPKG_NEXUS_REPOS":    []string{"maven=maven-releases,npm=npm-hosted,nuget=nuget-hosted"},
+	})
+}
+
+func TestNexusUploadUrls(t *testing.T) {
+	defer viper.Reset()
+	nexusSettings("nexus.example.com/")
+
+	checkUploadUrls(t, []uploadUrlTest{
+		{"maven", "com.example.app", "1.0", "app-1.0.jar", "https://nexus.example.com/repository/maven-releases/com/example/app/1.0/app-1.0.jar"},
+		{"npm", "app", "1.0.0", "app-1.0.0.tgz", "https://nexus.example.com/repository/npm-hosted/@mona/app/-/app-1.0.0.tgz"},
+		{"nuget", "App", "1.0.0", "App.1.0.0.nupkg", "https://nexus.example.com/repository/nuget-hosted/App/1.0.0"},
+	})
+}
+
+func TestNexusPublish(t *testing.T) {
+	defer viper.Reset()
+	migrationPath := t.TempDir()
+	writePulled(t, migrationPath, "maven", "com.example.app", "1.0", "app-1.0.jar", "jar")
+	writePulled(t, migrationPath, "npm", "app", "1.0.0", "app-1.0.0.tgz", "tarball")
+
+	registry := &fakeRegistry{
+		authorized: func(r *http.Request) bool {
+			user, password, ok := r.BasicAuth()
+			return ok && user == "deployer" && password == "secret"
+		},
+		head: func(w http.ResponseWriter, content string) {
+			sum := sha1.Sum([]byte(content))
+			w.Header().Set("ETag", fmt.Sprintf(`"{SHA1{%s}}"`, hex.EncodeToString(sum[:])))
+		},
+	}
+	registry.handle = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost {
+			return false
+		}
+		if r.URL.Path != "/service/rest/v1/components" || r.URL.Query().Get("repository") != "npm-hosted" {
+			w.WriteHeader(http.StatusBadRequest)
+			return true
+		}
+		file, header, err := r.FormFile("npm.asset")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return true
+		}
+		body, _ := io.ReadAll(file)
+		registry.put("/repository/npm-hosted/@mona/app/-/"+header.Filename, string(body))
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	server := registry.start(t)
+	viper.Set("GHMPKG_MIGRATION_PATH", migrationPath)
+	nexusSettings(server.URL)
+
+	maven := connectTarget(t, "maven")
+	if result, err := maven.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar"); err != nil || result != providers.Success {
+		t.Fatalf("maven Upload = %v, %v", result, err)
+	}
+	if content, _ := registry.get("/repository/maven-releases/com/example/app/1.0/app-1.0.jar"); content != "jar" {
+		t.Errorf("stored files = %v", registry.stored)
+	}
+	if result, err := maven.Upload(zap.NewNop(), "mona", "repo", "maven", "com.example.app", "1.0", "app-1.0.jar"); result != providers.Skipped || !providers.IsSkip(err) {
+		t.Errorf("second maven Upload = %v, %v, want a skip", result, err)
+	}
+
+	npm := connectTarget(t, "npm")
+	if result, err := npm.Upload(zap.NewNop(), "mona", "repo", "npm", "app", "1.0.0", "package.json"); err != nil || result != providers.Success {
+		t.Fatalf("npm Upload = %v, %v", result, err)
+	}
+	if content, _ := registry.get("/repository/npm-hosted/@mona/app/-/app-1.0.0.tgz"); content != "tarball" {
+		t.Errorf("stored files = %v", registry.stored)
+	}
+	if result, err := npm.Upload(zap.NewNop(), "mona", "repo", "npm", "app", "1.0.0", "package.json"); result != providers.Skipped || !providers.IsSkip(err) {
+		t.Errorf("second npm Upload = %v, %v, want a skip", result, err)
+	}
+
+	rubygems := connectTarget(t, "rubygems")
+	if _, err := rubygems.Upload(zap.NewNop(), "mona", "repo", "rubygems", "app", "1.0.0", "app-1.0.0.gem"); !providers.IsSkip(err) {
+		t.Errorf("rubygems Upload = %v, want a skip", err)
+	}
+}
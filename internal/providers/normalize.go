@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Fields of a package coordinate that can be normalized
+const (
+	OwnerField      = "owner"
+	RepositoryField = "repository"
+	NameField       = "name"
+	VersionField    = "version"
+)
+
+// NameChange records a value rewritten to the form a registry expects
+type NameChange struct {
+	PackageType string
+	Field       string
+	Original    string
+	Normalized  string
+}
+
+// nameAudit collects every distinct NameChange of the process
+var nameAudit struct {
+	mu      sync.Mutex
+	seen    map[NameChange]bool
+	changes []NameChange
+}
+
+// NormalizeName returns a field of a package coordinate as the registry of the
+// package type expects it in urls, recording it when it had to be changed:
+//   - container and docker: OCI repository names are lowercase
+//   - npm: package names and scopes are lowercase
+//   - nuget: ids are case insensitive and served lowercase, versions are
+//     normalized (three parts at least, no leading zeros, no trailing .0
+//     revision, no build metadata)
+//   - maven and rubygems: names are case sensitive and kept as they are
+func NormalizeName(packageType, field, value string) string {
+	normalized := value
+	switch packageType {
+	case "container", "docker", "npm":
+		normalized = strings.ToLower(value)
+	case "nuget":
+		switch field {
+		case NameField:
+			normalized = strings.ToLower(value)
+		case VersionField:
+			normalized = normalizeNugetVersion(value)
+		}
+	}
+
+	if normalized != value {
+		recordNameChange(NameChange{packageType, field, value, normalized})
+	}
+	return normalized
+}
+
+func normalizeNugetVersion(version string) string {
+	version, _, _ = strings.Cut(version, "+")
+	release, prerelease, hasPrerelease := strings.Cut(version, "-")
+
+	parts := strings.Split(release, ".")
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return strings.ToLower(version)
+		}
+		parts[i] = strconv.Itoa(number)
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	if len(parts) == 4 && parts[3] == "0" {
+		parts = parts[:3]
+	}
+
+	normalized := strings.Join(parts, ".")
+	if hasPrerelease {
+		normalized += "-" + prerelease
+	}
+	return strings.ToLower(normalized)
+}
+
+func recordNameChange(change NameChange) {
+	nameAudit.mu.Lock()
+	defer nameAudit.mu.Unlock()
+	if nameAudit.seen == nil {
+		nameAudit.seen = make(map[NameChange]bool)
+	}
+	if nameAudit.seen[change] {
+		return
+	}
+	nameAudit.seen[change] = true
+	nameAudit.changes = append(nameAudit.changes, change)
+}
+
+// NameChanges returns every value normalized so far, in the order they were first seen
+func NameChanges() []NameChange {
+	nameAudit.mu.Lock()
+	defer nameAudit.mu.Unlock()
+	return append([]NameChange{}, nameAudit.changes...)
+}
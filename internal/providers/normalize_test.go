@@ -0,0 +1,58 @@
+package providers_test
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+)
+
+func TestNormalizeNugetVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"1.0.0":               "1.0.0",
+		"1.0":                 "1.0.0",
+		"1":                   "1.0.0",
+		"01.002.0003":         "1.2.3",
+		"1.00.0-beta":         "1.0.0-beta",
+		"1.2.3.0":             "1.2.3",
+		"1.2.3.4":             "1.2.3.4",
+		"1.2.0.0":             "1.2.0",
+		"1.2.3-RC.1":          "1.2.3-rc.1",
+		"1.2.3+Build.42":      "1.2.3",
+		"1.2.3-Beta+SHA.ABCD": "1.2.3-beta",
+		"01.2.3.0-Alpha+meta": "1.2.3-alpha",
+		"Latest":              "latest",
+	} {
+		if got := providers.NormalizeName("nuget", providers.VersionField, version); got != want {
+			t.Errorf("NormalizeName(nuget, version, %s) = %s, want %s", version, got, want)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	for _, test := range []struct {
+		packageType, field, value, want string
+	}{
+		{"container", providers.NameField, "Mona/App", "mona/app"},
+		{"npm", providers.NameField, "Client", "client"},
+		{"nuget", providers.NameField, "Mona.Client", "mona.client"},
+		{"maven", providers.NameField, "com.Mona.Client", "com.Mona.Client"},
+		{"rubygems", providers.VersionField, "1.0.0.RC1", "1.0.0.RC1"},
+	} {
+		if got := providers.NormalizeName(test.packageType, test.field, test.value); got != test.want {
+			t.Errorf("NormalizeName(%s, %s, %s) = %s, want %s", test.packageType, test.field, test.value, got, test.want)
+		}
+	}
+
+	found := false
+	for _, change := range providers.NameChanges() {
+		if change == (providers.NameChange{PackageType: "npm", Field: providers.NameField, Original: "Client", Normalized: "client"}) {
+			found = true
+		}
+		if change.PackageType == "maven" {
+			t.Errorf("an unchanged maven name was audited: %+v", change)
+		}
+	}
+	if !found {
+		t.Error("the normalized npm name was not audited")
+	}
+}
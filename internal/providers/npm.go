@@ -1,19 +1,29 @@
 package providers
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path"
-	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/registry"
 	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -86,43 +96,120 @@ func (p *NPMProvider) Connect(logger *zap.Logger) error {
 
 func (p *NPMProvider) FetchPackageFiles(logger *zap.Logger, owner, repository, packageType, packageName, version string, metadata *github.PackageMetadata) ([]string, ResultState, error) {
 	logger.Info("Loading package files from NPM package registry")
-	fetchUrl, err := p.GetFetchUrl(logger, owner, packageName, version)
+	npmPackage, err := p.fetchPackument(logger, owner, packageName)
 	if err != nil {
 		return nil, Failed, err
 	}
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", fetchUrl, nil)
+	tarballUrl, err := url.Parse(npmPackage.Versions[version].Dist.Tarball)
+	logger.Info("Tarball url", zap.String("tarballUrl", tarballUrl.String()))
 	if err != nil {
 		return nil, Failed, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", viper.GetString("GHMPKG_SOURCE_TOKEN")))
+	filename := path.Base(tarballUrl.Path)
+	var filenames []string
+	filenames = append(filenames, filename)
+	logger.Info("Package files", zap.String("filename", filename))
+	return filenames, Success, nil
+}
+
+// fetchPackument returns the package document of the source registry, listing
+// the versions and dist-tags of the package
+func (p *NPMProvider) fetchPackument(logger *zap.Logger, owner, packageName string) (*NpmPackage, error) {
+	fetchUrl, err := p.GetFetchUrl(logger, owner, packageName, "")
+	if err != nil {
+		return nil, err
+	}
+	return p.getPackument(fetchUrl, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", p.PackageType))
+}
+
+func (p *NPMProvider) getPackument(packumentUrl, token string) (*NpmPackage, error) {
+	client := utils.NewHTTPClient()
+	req, err := http.NewRequest("GET", packumentUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, Failed, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, Failed, fmt.Errorf("failed to fetch package %s, status: %d, message: %s", fetchUrl, resp.StatusCode, resp.Status)
+		return nil, fmt.Errorf("failed to fetch package %s, status: %d, message: %s", packumentUrl, resp.StatusCode, resp.Status)
 	}
-	// print json response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, Failed, err
+		return nil, err
 	}
 	var npmPackage NpmPackage
 	if err := json.Unmarshal(body, &npmPackage); err != nil {
-		return nil, Failed, err
+		return nil, err
 	}
-	tarballUrl, err := url.Parse(npmPackage.Versions[version].Dist.Tarball)
-	logger.Info("Tarball url", zap.String("tarballUrl", tarballUrl.String()))
+	return &npmPackage, nil
+}
+
+// targetName is the scoped name of a package on the target, @owner/name
+func (p *NPMProvider) targetName(owner, packageName string) string {
+	packageName = TargetPackageName(p.PackageType, packageName)
+	return fmt.Sprintf("@%s/%s", NormalizeName(p.PackageType, OwnerField, owner), NormalizeName(p.PackageType, NameField, packageName))
+}
+
+// DistTags returns the dist-tags of the source package, tag to version
+func (p *NPMProvider) DistTags(logger *zap.Logger, owner, packageName string) (map[string]string, error) {
+	npmPackage, err := p.fetchPackument(logger, owner, packageName)
 	if err != nil {
-		return nil, Failed, err
+		return nil, err
 	}
-	filename := path.Base(tarballUrl.Path)
-	var filenames []string
-	filenames = append(filenames, filename)
-	logger.Info("Package files", zap.String("filename", filename))
-	return filenames, Success, nil
+	return npmPackage.DistTags, nil
+}
+
+// TargetVersions returns the versions the target package has
+func (p *NPMProvider) TargetVersions(logger *zap.Logger, owner, packageName string) ([]string, error) {
+	packumentUrl := p.packageUrl(p.targetName(owner, packageName))
+	npmPackage, err := p.getPackument(packumentUrl.String(), utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(npmPackage.Versions))
+	for version := range npmPackage.Versions {
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// SetDistTag points a dist-tag of the target package at a version, as npm
+// dist-tag add does
+func (p *NPMProvider) SetDistTag(logger *zap.Logger, owner, packageName, tag, version string) error {
+	name := p.targetName(owner, packageName)
+	tagUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, "-", "package", name, "dist-tags", tag)
+
+	for !utils.CanMakeRequest() {
+		pterm.Warning.Println("Approaching rate limit. Sleeping for 1 minute...")
+		time.Sleep(time.Minute)
+	}
+
+	body, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", tagUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := utils.NewHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to set dist-tag %s of %s to %s, status: %d, message: %s", tag, name, version, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	logger.Info("Set dist-tag", zap.String("package", name), zap.String("tag", tag), zap.String("version", version))
+	return nil
 }
 
 func (p *NPMProvider) Export(logger *zap.Logger, owner string, content interface{}) error {
@@ -131,7 +218,7 @@ func (p *NPMProvider) Export(logger *zap.Logger, owner string, content interface
 
 func (p *NPMProvider) Download(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
 	logger.Info("Downloading package", zap.String("packageName", packageName), zap.String("version", version), zap.String("filename", filename))
-	downloadedFilename := fmt.Sprintf("%s-%s.tgz", packageName, version)
+	downloadedFilename := stagedName(fmt.Sprintf("%s-%s.tgz", packageName, version), filename)
 	logger.Info("Downloaded filename", zap.String("downloadedFilename", downloadedFilename))
 	return p.downloadPackage(
 		logger, owner, repository, packageType, packageName, version, filename, &downloadedFilename,
@@ -142,7 +229,7 @@ func (p *NPMProvider) Download(logger *zap.Logger, owner, repository, packageTyp
 		},
 		// Download function
 		func(downloadUrl, outputPath string) (ResultState, error) {
-			if err := utils.DownloadFile(downloadUrl, outputPath, viper.GetString("GHMPKG_SOURCE_TOKEN")); err != nil {
+			if err := utils.DownloadFile(downloadUrl, outputPath, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", p.PackageType)); err != nil {
 				return Failed, err
 			}
 			return Success, nil
@@ -151,8 +238,8 @@ func (p *NPMProvider) Download(logger *zap.Logger, owner, repository, packageTyp
 }
 
 func (p *NPMProvider) Rename(logger *zap.Logger, filename string) error {
-	// Skip if source and target organizations are the same
-	if p.CheckOrganizationsMatch(logger) {
+	// Skip if source and target organizations are the same, without renames
+	if p.CheckOrganizationsMatch(logger) && currentMapping().IsEmpty() {
 		return nil
 	}
 
@@ -162,27 +249,226 @@ func (p *NPMProvider) Rename(logger *zap.Logger, filename string) error {
 		return fmt.Errorf("failed to read package.json: %w", err)
 	}
 
+	// Write back to file
+	err = utils.WriteFileAtomic(filename, p.renameContent(content), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write package.json: %w", err)
+	}
+
+	return nil
+}
+
+// renameContent replaces the source organization scope and repository url in package.json content
+func (p *NPMProvider) renameContent(content []byte) []byte {
 	sourceOrg := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
 	targetOrg := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
 
-	// Replace the organization name in the content, @sourceOrg -> @targetOrg
-	oldScope := fmt.Sprintf("@%s/", sourceOrg)
-	newScope := fmt.Sprintf("@%s/", targetOrg)
-	newContent := strings.Replace(string(content), oldScope, newScope, -1)
+	mapping := currentMapping()
+
+	// Replace the organization name in the content, @sourceOrg -> @targetOrg. npm
+	// scopes are lowercase whatever the case of the organization name. Packages
+	// of the source organization, the package itself and its dependencies, get
+	// the name the mapping file gives them.
+	oldScope := regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(sourceOrg) + `/([A-Za-z0-9._~-]*)`)
+	newScope := fmt.Sprintf("@%s/", NormalizeName(p.PackageType, OwnerField, targetOrg))
+	newContent := oldScope.ReplaceAllStringFunc(string(content), func(reference string) string {
+		name := reference[len(sourceOrg)+2:]
+		if name == "" {
+			return newScope
+		}
+		return newScope + mapping.PackageName(p.PackageType, name)
+	})
+	// Scopes of other source organizations merged into the target
+	if mapping != nil {
+		for scope, target := range mapping.Scopes {
+			otherScope := regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(scope) + `/`)
+			newContent = otherScope.ReplaceAllLiteralString(newContent, fmt.Sprintf("@%s/", NormalizeName(p.PackageType, OwnerField, target)))
+		}
+	}
 
 	// Replace the repository url in the content
 	// todo: we do not support GHES yet
 	oldRepoUrl := fmt.Sprintf("https://github.com/%s/", sourceOrg)
 	newRepoUrl := fmt.Sprintf("https://github.com/%s/", targetOrg)
+	newContent = mapping.RepositoryUrls(newContent, oldRepoUrl, newRepoUrl)
 	newContent = strings.Replace(newContent, oldRepoUrl, newRepoUrl, -1)
 
-	// Write back to file
-	err = os.WriteFile(filename, []byte(newContent), 0644)
+	return []byte(newContent)
+}
+
+// rewriteTarball renames the package.json inside a gzipped package tarball and
+// returns the new tarball along with the parsed package.json. A non empty newName
+// replaces the package name.
+func (p *NPMProvider) rewriteTarball(logger *zap.Logger, tarball []byte, newName string) ([]byte, map[string]interface{}, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(tarball))
 	if err != nil {
-		return fmt.Errorf("failed to write package.json: %w", err)
+		return nil, nil, fmt.Errorf("failed to read package: %w", err)
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+
+	var out bytes.Buffer
+	gzipWriter := gzip.NewWriter(&out)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	var manifest map[string]interface{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read package: %w", err)
+		}
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+
+		// The package.json sits in the single top level directory, usually package/
+		if dir, file := path.Split(header.Name); file == "package.json" && strings.Count(dir, "/") == 1 && manifest == nil {
+			if !p.CheckOrganizationsMatch(logger) || !currentMapping().IsEmpty() {
+				content = p.renameContent(content)
+			}
+			decoder := json.NewDecoder(bytes.NewReader(content))
+			decoder.UseNumber()
+			if err := decoder.Decode(&manifest); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse %s: %w", header.Name, err)
+			}
+			if newName != "" {
+				manifest["name"] = newName
+				if content, err = json.MarshalIndent(manifest, "", "  "); err != nil {
+					return nil, nil, err
+				}
+			}
+			header.Size = int64(len(content))
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return nil, nil, err
+		}
+		if _, err := tarWriter.Write(content); err != nil {
+			return nil, nil, err
+		}
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("package.json not found in package")
 	}
 
-	return nil
+	if err := tarWriter.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, nil, err
+	}
+	return out.Bytes(), manifest, nil
+}
+
+// readNpmManifest returns the package.json of a gzipped package tarball
+func readNpmManifest(tarball []byte) (map[string]interface{}, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package: %w", err)
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("package.json not found in package")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read package: %w", err)
+		}
+		if dir, file := path.Split(header.Name); file == "package.json" && strings.Count(dir, "/") == 1 {
+			var manifest map[string]interface{}
+			decoder := json.NewDecoder(tarReader)
+			decoder.UseNumber()
+			if err := decoder.Decode(&manifest); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", header.Name, err)
+			}
+			return manifest, nil
+		}
+	}
+}
+
+// publishDocument builds the document npm publish sends: the version manifest
+// with its dist information and the tarball attached as base64
+func (p *NPMProvider) publishDocument(manifest map[string]interface{}, tarball []byte) (string, []byte, error) {
+	return npmPublishDocument(*p.TargetRegistryUrl, manifest, tarball)
+}
+
+// npmPublishDocument builds the publish document of a tarball for a registry
+func npmPublishDocument(registryUrl url.URL, manifest map[string]interface{}, tarball []byte) (string, []byte, error) {
+	name, _ := manifest["name"].(string)
+	version, _ := manifest["version"].(string)
+	if name == "" || version == "" {
+		return "", nil, fmt.Errorf("package.json has no name or version")
+	}
+
+	sha1Sum := sha1.Sum(tarball)
+	sha512Sum := sha512.Sum512(tarball)
+	tarballName := fmt.Sprintf("%s-%s.tgz", path.Base(name), version)
+	// Tarball urls keep the scope separator as a path separator
+	tarballUrl := utils.JoinUrlPath(registryUrl, append(strings.SplitN(name, "/", 2), "-", tarballName)...)
+
+	manifest["_id"] = fmt.Sprintf("%s@%s", name, version)
+	manifest["dist"] = map[string]interface{}{
+		"integrity": "sha512-" + base64.StdEncoding.EncodeToString(sha512Sum[:]),
+		"shasum":    hex.EncodeToString(sha1Sum[:]),
+		"tarball":   tarballUrl.String(),
+	}
+
+	document, err := json.Marshal(map[string]interface{}{
+		"_id":         name,
+		"name":        name,
+		"description": manifest["description"],
+		"dist-tags":   map[string]string{"latest": version},
+		"versions":    map[string]interface{}{version: manifest},
+		"access":      nil,
+		"_attachments": map[string]interface{}{
+			fmt.Sprintf("%s-%s.tgz", name, version): map[string]interface{}{
+				"content_type": "application/octet-stream",
+				"data":         base64.StdEncoding.EncodeToString(tarball),
+				"length":       len(tarball),
+			},
+		},
+	})
+	return name, document, err
+}
+
+// publish PUTs the publish document to the registry, as npm publish does
+func (p *NPMProvider) publish(logger *zap.Logger, name string, document []byte) (ResultState, error) {
+	publishUrl := p.packageUrl(name)
+
+	for !utils.CanMakeRequest() {
+		pterm.Warning.Println("Approaching rate limit. Sleeping for 1 minute...")
+		time.Sleep(time.Minute)
+	}
+
+	req, err := http.NewRequest("PUT", publishUrl.String(), bytes.NewReader(document))
+	if err != nil {
+		return Failed, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := utils.NewHTTPClient().Do(req)
+	if err != nil {
+		return Failed, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		logger.Warn("Package version already exists", zap.String("package", name))
+		return Skip(SkipExistsOnTarget, fmt.Sprintf("%s is already published", name))
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Success, nil
+	default:
+		message, _ := io.ReadAll(resp.Body)
+		return Failed, CheckNameReused(name, fmt.Errorf("failed to publish package %s, status: %d, message: %s", name, resp.StatusCode, strings.TrimSpace(string(message))))
+	}
 }
 
 func (p *NPMProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
@@ -192,89 +478,78 @@ func (p *NPMProvider) Upload(logger *zap.Logger, owner, repository, packageType,
 			return p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
 		},
 		func(uploadUrl, packageDir string) (ResultState, error) {
-			npmrcPath := filepath.Join(packageDir, ".npmrc")
-			tgz := fmt.Sprintf("%s-%s.tgz", packageName, version)
-
-			// Create .npmrc content
-			npmrcContent := fmt.Sprintf("//npm.pkg.github.com/:_authToken=%s\nregistry=https://npm.pkg.github.com/%s",
-				viper.GetString("GHMPKG_TARGET_TOKEN"), owner)
-
-			// Write .npmrc file
-			if err := os.WriteFile(npmrcPath, []byte(npmrcContent), 0644); err != nil {
-				return Failed, fmt.Errorf("failed to write .npmrc: %w", err)
-			}
+			tgz := stagedPath(packageDir, fmt.Sprintf("%s-%s.tgz", packageName, version), filename)
 
-			// Rename the original tgz file to .orig
-			origTgz := tgz + ".orig"
-			if err := os.Rename(filepath.Join(packageDir, tgz), filepath.Join(packageDir, origTgz)); err != nil {
-				return Failed, fmt.Errorf("failed to rename original package: %w", err)
+			// Earlier versions rewrote the tarball on disk and kept the pulled one as .orig
+			if origTgz := tgz + ".orig"; utils.FileExists(origTgz) {
+				if err := os.Rename(origTgz, tgz); err != nil {
+					return Failed, fmt.Errorf("failed to restore original package: %w", err)
+				}
 			}
 
-			// Extract the tgz file
-			cmd := exec.Command("tar", "-xzf", origTgz)
-			cmd.Dir = packageDir
-			if err := cmd.Run(); err != nil {
-				return Failed, fmt.Errorf("failed to extract package: %w", err)
-			}
-
-			// Rename package.json contents
-			packageJson := filepath.Join(packageDir, "package", "package.json")
-			if err := p.Rename(logger, packageJson); err != nil {
-				return Failed, fmt.Errorf("failed to rename package.json: %w", err)
+			tarball, err := os.ReadFile(tgz)
+			if err != nil {
+				return Failed, fmt.Errorf("failed to read package: %w", err)
 			}
 
-			// Repackage the modified contents
-			repackageCmd := exec.Command("tar", "-czf", tgz, "package/")
-			repackageCmd.Dir = packageDir
-			if err := repackageCmd.Run(); err != nil {
-				return Failed, fmt.Errorf("failed to repackage modified contents: %w", err)
+			// The staged tarball is left untouched, the renamed one only lives in memory
+			name, digest, result, err := p.rewriteAndPublish(logger, tarball, "")
+			var reused *NameReusedError
+			if errors.As(err, &reused) {
+				if newName := conflictName(name); newName != "" {
+					logger.Warn("Package name cannot be reused, publishing under a new name",
+						zap.String("package", name),
+						zap.String("newName", newName))
+					pterm.Warning.Printf("⚠️  %s was deleted from the target organization, publishing as %s\n", name, newName)
+					_, digest, result, err = p.rewriteAndPublish(logger, tarball, newName)
+				}
 			}
-			// remove the package directory
-			if err := os.RemoveAll(filepath.Join(packageDir, "package")); err != nil {
-				return Failed, fmt.Errorf("failed to remove package directory: %w", err)
+			if result == Success {
+				p.recordTargetDigest(logger, repository, packageName, version, filename, digest)
 			}
+			return result, err
+		},
+	)
+}
 
-			// Run npm publish with the repackaged file
-			publishCmd := exec.Command("npm", "publish", tgz, "--registry=https://npm.pkg.github.com", "--verbose", "--ignore-scripts", "--no-engine-strict", "--userconfig", npmrcPath)
-			publishCmd.Dir = filepath.Join(packageDir)
-			publishCmd.Env = append(os.Environ(),
-				"HTTPS_PROXY=",
-			)
-
-			// Capture output to npmlog file
-			logFile, err := os.Create(filepath.Join(packageDir, "npmlog"))
-			if err != nil {
-				return Failed, fmt.Errorf("failed to create log file: %w", err)
-			}
-			defer logFile.Close()
+// rewriteAndPublish renames the tarball and publishes it, returning the name it was
+// published under and the digest of the published tarball
+func (p *NPMProvider) rewriteAndPublish(logger *zap.Logger, tarball []byte, newName string) (string, string, ResultState, error) {
+	renamed, manifest, err := p.rewriteTarball(logger, tarball, newName)
+	if err != nil {
+		return "", "", Failed, fmt.Errorf("failed to rename package.json: %w", err)
+	}
 
-			publishCmd.Stdout = logFile
-			publishCmd.Stderr = logFile
+	name, document, err := p.publishDocument(manifest, renamed)
+	if err != nil {
+		return "", "", Failed, fmt.Errorf("failed to build publish document: %w", err)
+	}
 
-			if err := publishCmd.Run(); err != nil {
-				return Failed, fmt.Errorf("failed to publish package: %w", err)
-			}
+	result, err := p.publish(logger, name, document)
+	return name, registry.Digest(renamed), result, err
+}
 
-			return Success, nil
-		},
-	)
+// packageUrl is the registry url of a scoped package, with the scope separator escaped
+func (p *NPMProvider) packageUrl(name string) url.URL {
+	return utils.JoinUrlPath(*p.TargetRegistryUrl, name)
 }
 
 func (p *NPMProvider) GetFetchUrl(logger *zap.Logger, owner, packageName, version string) (string, error) {
-	fetchUrl := *p.SourceRegistryUrl
-	fetchUrl.Path = path.Join(fetchUrl.Path, fmt.Sprintf("@%s", owner), packageName)
+	owner = NormalizeName(p.PackageType, OwnerField, owner)
+	packageName = NormalizeName(p.PackageType, NameField, packageName)
+	fetchUrl := utils.JoinUrlPath(*p.SourceRegistryUrl, fmt.Sprintf("@%s", owner), packageName)
 	return fetchUrl.String(), nil
 }
 
 func (p *NPMProvider) GetDownloadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
-	downloadUrl := *p.SourceRegistryUrl
-	downloadUrl.Path = path.Join(downloadUrl.Path, "download", fmt.Sprintf("@%s", owner), packageName, version, filename)
+	owner = NormalizeName(p.PackageType, OwnerField, owner)
+	packageName = NormalizeName(p.PackageType, NameField, packageName)
+	downloadUrl := utils.JoinUrlPath(*p.SourceRegistryUrl, "download", fmt.Sprintf("@%s", owner), packageName, version, filename)
 	logger.Info("Download url", zap.String("downloadUrl", downloadUrl.String()))
 	return downloadUrl.String(), nil
 }
 
 func (p *NPMProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version string, filename string) (string, error) {
-	uploadUrl := *p.TargetRegistryUrl
-	uploadUrl.Path = path.Join(uploadUrl.Path, fmt.Sprintf("@%s", owner), repository, packageName, version, filename)
+	uploadUrl := p.packageUrl(p.targetName(owner, packageName))
 	return uploadUrl.String(), nil
 }
@@ -1,14 +1,23 @@
 package providers
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/v62/github"
 	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -47,7 +56,7 @@ func (p *NugetProvider) Download(logger *zap.Logger, owner, repository, packageT
 		},
 		// Download function
 		func(downloadUrl, outputPath string) (ResultState, error) {
-			if err := utils.DownloadFile(downloadUrl, outputPath, viper.GetString("GHMPKG_SOURCE_TOKEN")); err != nil {
+			if err := utils.DownloadFile(downloadUrl, outputPath, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", p.PackageType)); err != nil {
 				return Failed, err
 			}
 			return Success, nil
@@ -60,7 +69,7 @@ func (p *NugetProvider) Rename(logger *zap.Logger, filename string) error {
 	if p.CheckOrganizationsMatch(logger) {
 		return nil
 	}
-	
+
 	zipCmd := exec.Command("zip", "-d", filename, "_rels/.rels", "\\[Content_Types\\].xml")
 	if err := zipCmd.Run(); err != nil {
 		if err.Error() == "exit status 12" {
@@ -73,6 +82,188 @@ func (p *NugetProvider) Rename(logger *zap.Logger, filename string) error {
 	return nil
 }
 
+// nuspecRepositoryPattern matches the repository element of a nuspec, self-closing or not
+var nuspecRepositoryPattern = regexp.MustCompile(`(?s)<repository\b[^>]*?(/>|>.*?</repository>)`)
+
+// nuspecUrlAttributePattern and nuspecTypeAttributePattern match the attributes
+// of the repository element
+var (
+	nuspecUrlAttributePattern  = regexp.MustCompile(`\burl=("[^"]*"|'[^']*')`)
+	nuspecTypeAttributePattern = regexp.MustCompile(`\btype=("[^"]*"|'[^']*')`)
+)
+
+// nuspecProjectUrlPattern matches the projectUrl element of a nuspec
+var nuspecProjectUrlPattern = regexp.MustCompile(`(?s)<projectUrl>.*?</projectUrl>`)
+
+// nuspecIdPattern matches the package id element of a nuspec
+var nuspecIdPattern = regexp.MustCompile(`<id>[^<]*</id>`)
+
+// RewriteNuspec points the package's nuspec at the target: the repository
+// element at the target repository, which GitHub links the pushed package to,
+// and a projectUrl on the source organization at the same page of the target
+// organization. Mapped repositories are pointed at their target repository.
+func (p *NugetProvider) RewriteNuspec(logger *zap.Logger, filename, repositoryUrl string) error {
+	return rewriteNuspec(filename, filename, func(content []byte) []byte {
+		content = setNuspecRepository(content, repositoryUrl)
+		content = nuspecProjectUrlPattern.ReplaceAllFunc(content, func(element []byte) []byte {
+			return []byte(p.targetUrls(string(element)))
+		})
+		logger.Info("Updated nuspec repository", zap.String("nupkg", filename), zap.String("url", repositoryUrl))
+		return content
+	})
+}
+
+// setNuspecRepository sets the url of the repository element, keeping its
+// type, branch and commit, or adds the element when the nuspec has none
+func setNuspecRepository(content []byte, repositoryUrl string) []byte {
+	urlAttribute := fmt.Sprintf(`url="%s"`, repositoryUrl)
+	element := nuspecRepositoryPattern.Find(content)
+	if element == nil {
+		return bytes.Replace(content, []byte("</metadata>"), []byte(fmt.Sprintf(`<repository type="git" %s />`, urlAttribute)+"</metadata>"), 1)
+	}
+	// Only the attributes of the opening tag are edited
+	end := bytes.IndexByte(element, '>')
+	tag := string(element[:end])
+	if nuspecUrlAttributePattern.MatchString(tag) {
+		tag = nuspecUrlAttributePattern.ReplaceAllLiteralString(tag, urlAttribute)
+	} else {
+		attributes := strings.TrimRight(tag, " /")
+		tag = attributes + " " + urlAttribute + tag[len(attributes):]
+	}
+	if !nuspecTypeAttributePattern.MatchString(tag) {
+		tag = strings.Replace(tag, "<repository", `<repository type="git"`, 1)
+	}
+	rewritten := append([]byte(tag), element[end:]...)
+	return bytes.Replace(content, element, rewritten, 1)
+}
+
+// targetUrls points the URLs of the source organization on the source host at
+// the target organization, mapped repositories at their target repository
+func (p *NugetProvider) targetUrls(content string) string {
+	sourceUrl := utils.JoinUrlPath(*p.SourceHostnameUrl, viper.GetString("GHMPKG_SOURCE_ORGANIZATION"))
+	targetUrl := utils.JoinUrlPath(*p.TargetHostnameUrl, viper.GetString("GHMPKG_TARGET_ORGANIZATION"))
+	content = currentMapping().RepositoryUrls(content, sourceUrl.String()+"/", targetUrl.String()+"/")
+	return strings.ReplaceAll(content, sourceUrl.String()+"/", targetUrl.String()+"/")
+}
+
+// SetPackageId writes a copy of the package with the id in its nuspec replaced
+func (p *NugetProvider) SetPackageId(logger *zap.Logger, filename, output, packageId string) error {
+	return rewriteNuspec(filename, output, func(content []byte) []byte {
+		logger.Info("Updated nuspec id", zap.String("nupkg", output), zap.String("id", packageId))
+		return nuspecIdPattern.ReplaceAllLiteral(content, []byte(fmt.Sprintf("<id>%s</id>", packageId)))
+	})
+}
+
+// rewriteNuspec copies the package to output, passing the nuspec through edit
+func rewriteNuspec(filename, output string, edit func([]byte) []byte) error {
+	reader, err := zip.OpenReader(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer reader.Close()
+
+	tmpPath := output + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmpPath, err)
+	}
+	defer os.Remove(tmpPath)
+	writer := zip.NewWriter(out)
+
+	for _, file := range reader.File {
+		content, err := readZipFile(file)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		// The nuspec is the only .nuspec file at the root of the package
+		if !strings.Contains(file.Name, "/") && strings.HasSuffix(file.Name, ".nuspec") {
+			content = edit(content)
+		}
+		header := file.FileHeader
+		entry, err := writer.CreateHeader(&header)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		if _, err := entry.Write(content); err != nil {
+			out.Close()
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	reader.Close()
+	return os.Rename(tmpPath, output)
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// push uploads the nupkg to the NuGet push endpoint as a multipart form
+func (p *NugetProvider) push(logger *zap.Logger, uploadUrl, packageId, nupkg string) (ResultState, error) {
+	content, err := os.ReadFile(nupkg)
+	if err != nil {
+		return Failed, fmt.Errorf("failed to read %s: %w", nupkg, err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("package", filepath.Base(nupkg))
+	if err != nil {
+		return Failed, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return Failed, err
+	}
+	if err := form.Close(); err != nil {
+		return Failed, err
+	}
+
+	for !utils.CanMakeRequest() {
+		pterm.Warning.Println("Approaching rate limit. Sleeping for 1 minute...")
+		time.Sleep(time.Minute)
+	}
+
+	req, err := http.NewRequest("PUT", uploadUrl, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return Failed, fmt.Errorf("failed to create request: %w", err)
+	}
+	token := utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType)
+	req.SetBasicAuth(viper.GetString("GHMPKG_TARGET_ORGANIZATION"), token)
+	req.Header.Set("X-NuGet-ApiKey", token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := utils.NewHTTPClient().Do(req)
+	if err != nil {
+		return Failed, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		logger.Warn("Package version already exists", zap.String("nupkg", nupkg))
+		return Skip(SkipExistsOnTarget, fmt.Sprintf("%s is already published", filepath.Base(nupkg)))
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Success, nil
+	default:
+		message, _ := io.ReadAll(resp.Body)
+		return Failed, CheckNameReused(packageId, fmt.Errorf("failed to push %s, status: %d, message: %s", filepath.Base(nupkg), resp.StatusCode, strings.TrimSpace(string(message))))
+	}
+}
+
 func (p *NugetProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
 	return p.uploadPackage(
 		logger, owner, repository, packageType, packageName, version, filename,
@@ -86,47 +277,62 @@ func (p *NugetProvider) Upload(logger *zap.Logger, owner, repository, packageTyp
 				return Failed, fmt.Errorf("failed to rename %s: %w", nupkg, err)
 			}
 
-			uploadUrl, err := p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
-			if err != nil {
-				logger.Error("Error getting upload URL", zap.Error(err))
-				return Failed, err
+			repositoryUrl := utils.JoinUrlPath(*p.TargetHostnameUrl, owner, TargetRepository(repository))
+			if err := p.RewriteNuspec(logger, nupkg, repositoryUrl.String()); err != nil {
+				return Failed, fmt.Errorf("failed to update nuspec of %s: %w", nupkg, err)
 			}
-			// Run nuget publish
-			pushCmd := exec.Command("./tool/gpr", "push", nupkg, "--repository", uploadUrl, "-k", viper.GetString("GHMPKG_TARGET_TOKEN"))
 
-			// // Capture output to nugetlog file
-			logFile, err := os.Create(filepath.Join(packageDir, "nugetlog"))
-			if err != nil {
-				return Failed, fmt.Errorf("failed to create log file: %w", err)
+			// The mapping file can publish the package under another id
+			pushed, packageId := nupkg, packageName
+			if targetId := TargetPackageName(p.PackageType, packageName); targetId != packageName {
+				mappedNupkg := filepath.Join(packageDir, fmt.Sprintf("%s-%s.nupkg", targetId, version))
+				if err := p.SetPackageId(logger, nupkg, mappedNupkg, targetId); err != nil {
+					return Failed, fmt.Errorf("failed to update nuspec of %s: %w", nupkg, err)
+				}
+				defer p.removeWorkFiles(logger, mappedNupkg)
+				pushed, packageId = mappedNupkg, targetId
 			}
-			defer logFile.Close()
-
-			pushCmd.Stdout = logFile
-			pushCmd.Stderr = logFile
 
-			if err := pushCmd.Run(); err != nil {
-				return Failed, fmt.Errorf("failed to publish package: %w", err)
+			result, err := p.push(logger, uploadUrl, packageId, pushed)
+			var reused *NameReusedError
+			if errors.As(err, &reused) {
+				if newId := conflictName(packageId); newId != "" {
+					logger.Warn("Package name cannot be reused, pushing under a new id",
+						zap.String("package", packageId),
+						zap.String("newId", newId))
+					pterm.Warning.Printf("⚠️  %s was deleted from the target organization, pushing as %s\n", packageId, newId)
+					renamedNupkg := filepath.Join(packageDir, fmt.Sprintf("%s-%s.nupkg", newId, version))
+					if err := p.SetPackageId(logger, nupkg, renamedNupkg, newId); err != nil {
+						return Failed, fmt.Errorf("failed to update nuspec of %s: %w", nupkg, err)
+					}
+					pushed = renamedNupkg
+					result, err = p.push(logger, uploadUrl, newId, renamedNupkg)
+					if err == nil {
+						defer p.removeWorkFiles(logger, renamedNupkg)
+					}
+				}
 			}
-
-			return Success, nil
+			if result == Success {
+				p.recordTargetFile(logger, repository, packageName, version, filename, pushed)
+			}
+			return result, err
 		},
 	)
 }
 
 func (p *NugetProvider) GetFetchUrl(logger *zap.Logger, owner, packageName, version string) (string, error) {
-	fetchUrl := *p.SourceRegistryUrl
-	fetchUrl.Path = path.Join(fetchUrl.Path, owner, "download", packageName, version)
+	fetchUrl := utils.JoinUrlPath(*p.SourceRegistryUrl, owner, "download", packageName, version)
 	return fetchUrl.String(), nil
 }
 
 func (p *NugetProvider) GetDownloadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
-	downloadUrl := *p.SourceRegistryUrl
-	downloadUrl.Path = path.Join(downloadUrl.Path, owner, "download", packageName, version, filename)
+	packageName = NormalizeName(p.PackageType, NameField, packageName)
+	version = NormalizeName(p.PackageType, VersionField, version)
+	downloadUrl := utils.JoinUrlPath(*p.SourceRegistryUrl, owner, "download", packageName, version, filename)
 	return downloadUrl.String(), nil
 }
 
 func (p *NugetProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version string, filename string) (string, error) {
-	uploadUrl := *p.TargetHostnameUrl
-	uploadUrl.Path = path.Join(uploadUrl.Path, owner, repository)
-	return uploadUrl.String(), nil
+	uploadUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, owner)
+	return uploadUrl.String() + "/", nil
 }
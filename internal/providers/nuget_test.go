@@ -0,0 +1,107 @@
+package providers_test
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// writeNupkg creates a package holding a nuspec and a library
+func writeNupkg(t *testing.T, nuspec string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "App-1.0.0.nupkg")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(file)
+	for name, content := range map[string]string{"App.nuspec": nuspec, "lib/net8.0/App.dll": "dll"} {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	return path
+}
+
+// readNuspec returns the nuspec of a package
+func readNuspec(t *testing.T, path string) string {
+	t.Helper()
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	for _, file := range reader.File {
+		if file.Name == "App.nuspec" {
+			content, _ := file.Open()
+			defer content.Close()
+			nuspec, _ := io.ReadAll(content)
+			return string(nuspec)
+		}
+	}
+	t.Fatalf("%s has no nuspec", path)
+	return ""
+}
+
+func TestNugetRewriteNuspec(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "team-a")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "acme")
+	viper.Set("GHMPKG_MAPPING_FILE", writeMapping(t, "mapping.yaml", "repositories:\n  api: team-a-api\n"))
+	provider := connectTarget(t, "nuget").(*providers.NugetProvider)
+
+	tests := []struct {
+		name, metadata string
+		want           []string
+	}{
+		{
+			"mapped repository and project",
+			`<id>App</id><projectUrl>https://github.com/team-a/api</projectUrl><repository type="git" url="https://github.com/team-a/api.git" branch="main" commit="abc123" />`,
+			[]string{
+				`<projectUrl>https://github.com/acme/team-a-api</projectUrl>`,
+				`<repository type="git" url="https://github.com/acme/team-a-api" branch="main" commit="abc123" />`,
+			},
+		},
+		{
+			"project page of another repository",
+			`<id>App</id><projectUrl>https://github.com/team-a/web/wiki</projectUrl><repository type="git"></repository>`,
+			[]string{
+				`<projectUrl>https://github.com/acme/web/wiki</projectUrl>`,
+				`<repository type="git" url="https://github.com/acme/team-a-api"></repository>`,
+			},
+		},
+		{
+			"external project, no repository",
+			`<id>App</id><projectUrl>https://example.com/team-a/</projectUrl>`,
+			[]string{
+				`<projectUrl>https://example.com/team-a/</projectUrl>`,
+				`<repository type="git" url="https://github.com/acme/team-a-api" /></metadata>`,
+			},
+		},
+	}
+	for _, test := range tests {
+		nupkg := writeNupkg(t, `<?xml version="1.0"?><package><metadata>`+test.metadata+`</metadata></package>`)
+		if err := provider.RewriteNuspec(zap.NewNop(), nupkg, "https://github.com/acme/team-a-api"); err != nil {
+			t.Fatalf("%s: RewriteNuspec returned an error: %v", test.name, err)
+		}
+		nuspec := readNuspec(t, nupkg)
+		for _, want := range test.want {
+			if !strings.Contains(nuspec, want) {
+				t.Errorf("%s: nuspec %s does not contain %s", test.name, nuspec, want)
+			}
+		}
+	}
+}
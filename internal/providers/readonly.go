@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"go.uber.org/zap"
+)
+
+// ReadOnlyProvider is the provider of a package type in read-only mode. It
+// wraps the provider, which still lists the source packages and their files,
+// and replaces everything that logs in, downloads or publishes with no-ops, so
+// no target is wrapped and no path can write whatever a command asks of it.
+type ReadOnlyProvider struct {
+	Provider
+}
+
+func newReadOnlyProvider(provider Provider) *ReadOnlyProvider {
+	return &ReadOnlyProvider{Provider: provider}
+}
+
+// Connect does nothing: connecting logs in to the registries with the Docker
+// daemon, which stores the credentials, and opens the target
+func (p *ReadOnlyProvider) Connect(logger *zap.Logger) error {
+	logger.Info("Read-only mode, not connecting to the registries", zap.String("packageType", p.GetPackageType()))
+	return nil
+}
+
+// Download skips every file, pulling writes it to disk
+func (p *ReadOnlyProvider) Download(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	return Skip(SkipReadOnly, "files are not downloaded in read-only mode")
+}
+
+// Upload skips every file, nothing is published in read-only mode
+func (p *ReadOnlyProvider) Upload(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	return Skip(SkipReadOnly, "files are not published in read-only mode")
+}
+
+// GetUploadUrl refuses to point at the target
+func (p *ReadOnlyProvider) GetUploadUrl(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	return "", fmt.Errorf("%w: upload URL of %s", utils.ErrReadOnly, filename)
+}
+
+// FileChecksum returns the checksum of a source file when the wrapped provider knows it
+func (p *ReadOnlyProvider) FileChecksum(logger *zap.Logger, owner, packageName, version, filename string) (string, error) {
+	if checksummer, ok := p.Provider.(Checksummer); ok {
+		return checksummer.FileChecksum(logger, owner, packageName, version, filename)
+	}
+	return "", nil
+}
+
+// FileSize returns the size of a source file when the wrapped provider knows it
+func (p *ReadOnlyProvider) FileSize(logger *zap.Logger, owner, repository, packageName, version, filename string) (int64, error) {
+	if sizer, ok := p.Provider.(Sizer); ok {
+		return sizer.FileSize(logger, owner, repository, packageName, version, filename)
+	}
+	return 0, nil
+}
+
+// DistTags returns the dist-tags of a source package when the wrapped provider knows them
+func (p *ReadOnlyProvider) DistTags(logger *zap.Logger, owner, packageName string) (map[string]string, error) {
+	if tagger, ok := p.Provider.(DistTagger); ok {
+		return tagger.DistTags(logger, owner, packageName)
+	}
+	return nil, nil
+}
+
+// TargetVersions refuses to read the target
+func (p *ReadOnlyProvider) TargetVersions(logger *zap.Logger, owner, packageName string) ([]string, error) {
+	return nil, fmt.Errorf("%w: target versions of %s", utils.ErrReadOnly, packageName)
+}
+
+// SetDistTag refuses to tag a version on the target
+func (p *ReadOnlyProvider) SetDistTag(logger *zap.Logger, owner, packageName, tag, version string) error {
+	return fmt.Errorf("%w: dist-tag %s of %s", utils.ErrReadOnly, tag, packageName)
+}
@@ -0,0 +1,49 @@
+package providers_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestReadOnlyProvider(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_READ_ONLY", true)
+	// Publishing to Artifactory is not set up in read-only mode
+	viper.Set("GHMPKG_TARGET_REGISTRY", providers.TargetArtifactory)
+	logger := zap.NewNop()
+
+	for _, packageType := range []string{"container", "maven", "npm", "nuget", "rubygems"} {
+		provider, err := providers.NewProvider(logger, packageType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := provider.(*providers.ReadOnlyProvider); !ok {
+			t.Fatalf("NewProvider(%s) = %T, want a read-only provider", packageType, provider)
+		}
+		if err := provider.Connect(logger); err != nil {
+			t.Errorf("%s Connect = %v", packageType, err)
+		}
+		for name, transfer := range map[string]func(*zap.Logger, string, string, string, string, string, string) (providers.ResultState, error){
+			"Download": provider.Download,
+			"Upload":   provider.Upload,
+		} {
+			result, err := transfer(logger, "mona", "app", packageType, "client", "1.0.0", "client-1.0.0.tgz")
+			var skip *providers.SkipError
+			if result != providers.Skipped || !errors.As(err, &skip) || skip.Reason != providers.SkipReadOnly {
+				t.Errorf("%s %s = %s, %v, want a read_only skip", packageType, name, result, err)
+			}
+		}
+		if _, err := provider.GetUploadUrl(logger, "mona", "app", "client", "1.0.0", "client-1.0.0.tgz"); !errors.Is(err, utils.ErrReadOnly) {
+			t.Errorf("%s GetUploadUrl = %v, want ErrReadOnly", packageType, err)
+		}
+		tagger := provider.(providers.DistTagger)
+		if err := tagger.SetDistTag(logger, "mona", "client", "latest", "1.0.0"); !errors.Is(err, utils.ErrReadOnly) {
+			t.Errorf("%s SetDistTag = %v, want ErrReadOnly", packageType, err)
+		}
+	}
+}
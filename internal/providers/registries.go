@@ -0,0 +1,220 @@
+package providers
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// DefaultContainerRegistry is the registry of GitHub Packages container images
+// on GitHub.com, GitHub Enterprise Server serves it at containers.HOSTNAME
+const DefaultContainerRegistry = "ghcr.io"
+
+// dockerHubHosts are the names Docker Hub is referenced by in image references
+var dockerHubHosts = []string{"docker.io", "index.docker.io", "registry-1.docker.io"}
+
+// ContainerRegistry is the registry container images are pulled from or pushed
+// to: the Container registry of the GitHub host, or any OCI registry (Docker Hub, ECR, GAR, Harbor...) set with
+// GHMPKG_SOURCE_CONTAINER_REGISTRY or GHMPKG_TARGET_CONTAINER_REGISTRY.
+type ContainerRegistry struct {
+	// Host is the registry of image references, e.g. docker.io
+	Host string
+	// Namespace replaces the organization in image names when set, e.g. acme in docker.io/acme
+	Namespace string
+	// RepositoryScoped registries name images after the repository they belong
+	// to, OWNER/REPOSITORY/IMAGE, like docker.pkg.github.com
+	RepositoryScoped bool
+	Username         string
+	Password         string
+	// github is set for the Container registry of the side's GitHub host
+	github bool
+}
+
+// containerRegistry reads the container registry of a side, "SOURCE" or "TARGET",
+// the Container registry of the side's GitHub host unless set. The organization
+// and token of the side are its credentials unless the registry has its own.
+func containerRegistry(side, packageType string) ContainerRegistry {
+	github := githubContainerRegistry(side, packageType)
+	value := viper.GetString(fmt.Sprintf("GHMPKG_%s_CONTAINER_REGISTRY", side))
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "https://"), "http://")
+	value = strings.Trim(value, "/")
+	if value == "" {
+		value = github
+	}
+	host, namespace, _ := strings.Cut(value, "/")
+	registry := ContainerRegistry{
+		Host:      strings.ToLower(host),
+		Namespace: namespace,
+		Username:  viper.GetString(fmt.Sprintf("GHMPKG_%s_CONTAINER_REGISTRY_USER", side)),
+		Password:  viper.GetString(fmt.Sprintf("GHMPKG_%s_CONTAINER_REGISTRY_PASSWORD", side)),
+	}
+	registry.github = registry.Host == github
+	if registry.Username == "" && registry.Password == "" {
+		registry.Username = viper.GetString(fmt.Sprintf("GHMPKG_%s_ORGANIZATION", side))
+		registry.Password = utils.GetPackageTypeString(fmt.Sprintf("GHMPKG_%s_TOKEN", side), packageType)
+	}
+	return registry
+}
+
+// bareHostname strips the scheme and path of a hostname setting, such as
+// https://ghes.example.com/api/v3, github.com when empty
+func bareHostname(hostname string) string {
+	hostname = strings.TrimPrefix(strings.TrimPrefix(hostname, "https://"), "http://")
+	hostname, _, _ = strings.Cut(hostname, "/")
+	hostname = strings.ToLower(hostname)
+	if hostname == "" || hostname == "api.github.com" {
+		return "github.com"
+	}
+	return hostname
+}
+
+// sideHostname is the bare GitHub hostname of a side, github.com when unset
+func sideHostname(side, packageType string) string {
+	return bareHostname(utils.GetPackageTypeString(fmt.Sprintf("GHMPKG_%s_HOSTNAME", side), packageType))
+}
+
+// SourceHosts returns the hosts of the source GitHub instance the source token
+// is sent to for the given package types: its API and its package registries
+func SourceHosts(packageTypes []string) []string {
+	var hosts []string
+	add := func(host string) {
+		if host = strings.ToLower(host); !utils.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	for _, packageType := range packageTypes {
+		hostname := sideHostname("SOURCE", packageType)
+		add(hostname)
+		if !isServer(hostname) {
+			add("api." + hostname)
+		}
+		registry := utils.ParseUrl(packageRegistryUrl(hostname, packageType, subdomainIsolation("SOURCE")))
+		add(registry.Host)
+		add(githubContainerRegistry("SOURCE", packageType))
+	}
+	return hosts
+}
+
+// isServer reports whether a bare hostname is a GitHub Enterprise Server
+// instance. GitHub.com and GHE.com serve their registries on TYPE.pkg.HOSTNAME.
+func isServer(hostname string) bool {
+	return hostname != "github.com" && !strings.HasSuffix(hostname, ".ghe.com")
+}
+
+// subdomainIsolation reports whether the GitHub Enterprise Server of a side
+// serves its registries on subdomains, GHMPKG_<SIDE>_SUBDOMAIN_ISOLATION. It is
+// enabled unless set to false.
+func subdomainIsolation(side string) bool {
+	value := viper.GetString(fmt.Sprintf("GHMPKG_%s_SUBDOMAIN_ISOLATION", side))
+	isolated, err := strconv.ParseBool(value)
+	return value == "" || err != nil || isolated
+}
+
+// packageRegistryUrl is the registry of a package type on a GitHub host:
+// TYPE.pkg.HOSTNAME on GitHub.com and GHE.com, TYPE.HOSTNAME on GitHub
+// Enterprise Server or HOSTNAME/_registry/TYPE/ without subdomain isolation
+func packageRegistryUrl(hostname, packageType string, isolated bool) string {
+	switch {
+	case !isServer(hostname):
+		return fmt.Sprintf("https://%s.pkg.%s/", packageType, hostname)
+	case isolated:
+		return fmt.Sprintf("https://%s.%s/", packageType, hostname)
+	default:
+		return fmt.Sprintf("https://%s/_registry/%s/", hostname, packageType)
+	}
+}
+
+// githubContainerRegistry is the Container registry of a side's GitHub host:
+// ghcr.io for GitHub.com, containers.HOSTNAME for GitHub Enterprise Server 3.8+
+// and GHE.com
+func githubContainerRegistry(side, packageType string) string {
+	hostname := sideHostname(side, packageType)
+	if hostname == "github.com" {
+		return DefaultContainerRegistry
+	}
+	return "containers." + hostname
+}
+
+// SourceContainerRegistry is the registry images are pulled from
+func SourceContainerRegistry() ContainerRegistry {
+	return containerRegistry("SOURCE", "container")
+}
+
+// TargetContainerRegistry is the registry images are pushed to
+func TargetContainerRegistry() ContainerRegistry {
+	return containerRegistry("TARGET", "container")
+}
+
+// IsGitHub reports whether the registry is the GitHub Packages Container
+// registry of the side, whose organization can be queried for its packages
+func (r ContainerRegistry) IsGitHub() bool {
+	return r.github
+}
+
+// HasCredentials reports whether the registry can be logged in to
+func (r ContainerRegistry) HasCredentials() bool {
+	return r.Username != "" && r.Password != ""
+}
+
+// Repository is the repository of an image in the registry, under the
+// namespace of the registry or else the owner of the package
+func (r ContainerRegistry) Repository(owner, repository, imageName string) string {
+	namespace := owner
+	if r.Namespace != "" {
+		namespace = r.Namespace
+	}
+	if r.RepositoryScoped {
+		return path.Join(namespace, repository, imageName)
+	}
+	return path.Join(namespace, imageName)
+}
+
+// Reference is the image reference of a name:tag filename, as docker pulls it
+func (r ContainerRegistry) Reference(owner, repository, filename string) string {
+	return path.Join(r.Host, r.Repository(owner, repository, filename))
+}
+
+// ApiHost is the host serving the registry API, Docker Hub serves it apart
+// from the name images are referenced by
+func (r ContainerRegistry) ApiHost() string {
+	if utils.Contains(dockerHubHosts, r.Host) {
+		return "registry-1.docker.io"
+	}
+	return r.Host
+}
+
+// LoginAddress is the server address the Docker daemon logs in to
+func (r ContainerRegistry) LoginAddress() string {
+	if utils.Contains(dockerHubHosts, r.Host) {
+		return "https://index.docker.io/v1/"
+	}
+	return r.Host
+}
+
+// IsImage reports whether packages of a type are container images: container,
+// or docker for the legacy docker.pkg.github.com registry
+func IsImage(packageType string) bool {
+	return packageType == "container" || packageType == "docker"
+}
+
+// TargetPackageType is the package type packages of a type are published as
+// on the target. Legacy docker images are pushed to the Container registry.
+func TargetPackageType(packageType string) string {
+	if packageType == "docker" {
+		return "container"
+	}
+	return packageType
+}
+
+// TargetOnGitHub reports whether packages of a type are published to a GitHub
+// organization, which can be queried for the packages it already has
+func TargetOnGitHub(packageType string) bool {
+	if ExternalTarget() {
+		return false
+	}
+	return !IsImage(packageType) || TargetContainerRegistry().IsGitHub()
+}
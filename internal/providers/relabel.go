@@ -0,0 +1,232 @@
+package providers
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/registry"
+	"go.uber.org/zap"
+)
+
+// sourceLabel links an image to the repository it was built from
+const sourceLabel = "org.opencontainers.image.source"
+
+// SavedImage describes the image of a docker save tarball
+type SavedImage struct {
+	// path is the tarball
+	path string
+	// manifest is the entry of manifest.json, with the fields this tool does not
+	// rewrite kept as they are
+	manifest   map[string]json.RawMessage
+	configPath string
+	config     []byte
+}
+
+// ID is the image ID, the digest of its configuration
+func (image *SavedImage) ID() string {
+	return registry.Digest(image.config)
+}
+
+// Labels returns the labels of the image configuration
+func (image *SavedImage) Labels() (map[string]string, error) {
+	var config struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(image.config, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse image config %s: %w", image.configPath, err)
+	}
+	return config.Config.Labels, nil
+}
+
+// readTarEntry returns the content of an entry of a tarball, nil when it has none
+func readTarEntry(tarPath, name string) ([]byte, error) {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	// Entries are skipped by seeking the file, only the wanted one is read
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path.Clean(header.Name) == name {
+			return io.ReadAll(reader)
+		}
+	}
+}
+
+// ReadSavedImage reads the manifest and configuration of a docker save tarball.
+// Both the legacy layout and the OCI layout Docker 25 saves list the image in
+// manifest.json.
+func ReadSavedImage(tarPath string) (*SavedImage, error) {
+	content, err := readTarEntry(tarPath, "manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	var manifests []map[string]json.RawMessage
+	if content != nil {
+		if err := json.Unmarshal(content, &manifests); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest.json of %s: %w", tarPath, err)
+		}
+	}
+	if len(manifests) != 1 {
+		return nil, fmt.Errorf("%s does not hold a single image saved by docker", tarPath)
+	}
+	image := &SavedImage{path: tarPath, manifest: manifests[0]}
+	if err := json.Unmarshal(image.manifest["Config"], &image.configPath); err != nil || image.configPath == "" {
+		return nil, fmt.Errorf("manifest.json of %s names no image config", tarPath)
+	}
+	image.configPath = path.Clean(image.configPath)
+	if image.config, err = readTarEntry(tarPath, image.configPath); err != nil {
+		return nil, err
+	}
+	if image.config == nil {
+		return nil, fmt.Errorf("image config %s is missing from %s", image.configPath, tarPath)
+	}
+	return image, nil
+}
+
+// relabelConfig sets the labels of an image configuration. Every other field,
+// the creation time, history and root filesystem included, is kept.
+func relabelConfig(config []byte, labels map[string]string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return nil, err
+	}
+	var runConfig map[string]json.RawMessage
+	if raw, ok := fields["config"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &runConfig); err != nil {
+			return nil, err
+		}
+	}
+	if runConfig == nil {
+		runConfig = map[string]json.RawMessage{}
+	}
+	var err error
+	if runConfig["Labels"], err = json.Marshal(labels); err != nil {
+		return nil, err
+	}
+	if fields["config"], err = json.Marshal(runConfig); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// WriteRelabeled writes a docker save tarball of the image with new labels,
+// tagged ref. Only the configuration changes: the layers are copied as they
+// are and the image keeps their history and creation time. The tarball is in
+// the legacy layout, which every Docker daemon loads. It returns the ID of the
+// relabeled image.
+func (image *SavedImage) WriteRelabeled(ref string, labels map[string]string, w io.Writer) (string, error) {
+	config, err := relabelConfig(image.config, labels)
+	if err != nil {
+		return "", fmt.Errorf("failed to relabel image config %s: %w", image.configPath, err)
+	}
+	id := registry.Digest(config)
+	configPath := strings.TrimPrefix(id, "sha256:") + ".json"
+	if strings.HasPrefix(image.configPath, "blobs/") {
+		configPath = path.Join(path.Dir(image.configPath), strings.TrimPrefix(id, "sha256:"))
+	}
+
+	manifest := make(map[string]json.RawMessage, len(image.manifest))
+	for key, value := range image.manifest {
+		manifest[key] = value
+	}
+	manifest["Config"], _ = json.Marshal(configPath)
+	manifest["RepoTags"], _ = json.Marshal([]string{ref})
+	manifests, err := json.Marshal([]map[string]json.RawMessage{manifest})
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(image.path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	reader := tar.NewReader(file)
+	writer := tar.NewWriter(w)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		// The OCI index and the legacy repositories file name the source image
+		switch path.Clean(header.Name) {
+		case "manifest.json", "index.json", "oci-layout", "repositories", configPath:
+			continue
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(writer, reader); err != nil {
+			return "", err
+		}
+	}
+	for _, entry := range []struct {
+		name    string
+		content []byte
+	}{{configPath, config}, {"manifest.json", manifests}} {
+		if err := writer.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}); err != nil {
+			return "", err
+		}
+		if _, err := writer.Write(entry.content); err != nil {
+			return "", err
+		}
+	}
+	return id, writer.Close()
+}
+
+// loadRelabeled loads a pulled image into the Docker daemon as ref, with new
+// labels. It returns the ID of the loaded image.
+func (p *ContainerProvider) loadRelabeled(logger *zap.Logger, ref string, image *SavedImage, labels map[string]string) (string, error) {
+	if err := p.waitForStorage(logger); err != nil {
+		return "", err
+	}
+	p.pulling.Add(1)
+	defer p.pulling.Add(-1)
+
+	reader, writer := io.Pipe()
+	written := make(chan error, 1)
+	var id string
+	go func() {
+		var err error
+		id, err = image.WriteRelabeled(ref, labels, writer)
+		writer.CloseWithError(err)
+		written <- err
+	}()
+
+	loadResp, err := p.client.ImageLoad(p.ctx, reader, true)
+	if err != nil {
+		reader.CloseWithError(err)
+		<-written
+		logger.Error("Failed to load relabeled image", zap.String("image", ref), zap.String("path", image.path), zap.Error(err))
+		return "", err
+	}
+	defer loadResp.Body.Close()
+	// Must read the response to complete the load
+	_, err = io.Copy(io.Discard, loadResp.Body)
+	if writeErr := <-written; writeErr != nil {
+		return "", fmt.Errorf("failed to relabel image %s: %w", ref, writeErr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load image %s: %w", ref, err)
+	}
+	return id, nil
+}
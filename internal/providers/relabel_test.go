@@ -0,0 +1,134 @@
+package providers_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/registry"
+)
+
+// writeSavedImage writes a docker save tarball with the given entries
+func writeSavedImage(t *testing.T, entries map[string]string) string {
+	t.Helper()
+	tarPath := filepath.Join(t.TempDir(), "app-1.0.tar")
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for name, content := range entries {
+		writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		writer.Write([]byte(content))
+	}
+	writer.Close()
+	if err := os.WriteFile(tarPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write tarball: %v", err)
+	}
+	return tarPath
+}
+
+// readTarball returns the entries of a tarball
+func readTarball(t *testing.T, content []byte) map[string][]byte {
+	t.Helper()
+	entries := map[string][]byte{}
+	reader := tar.NewReader(bytes.NewReader(content))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("Failed to read relabeled tarball: %v", err)
+		}
+		entries[header.Name], _ = io.ReadAll(reader)
+	}
+}
+
+func TestWriteRelabeled(t *testing.T) {
+	config := `{"architecture":"amd64","created":"2023-01-01T00:00:00Z","config":{"Cmd":["app"],"Labels":{"org.opencontainers.image.source":"https://github.com/mona/app","team":"a"}},"history":[{"created":"2023-01-01T00:00:00Z","created_by":"COPY app /"}],"rootfs":{"type":"layers","diff_ids":["sha256:aaa"]}}`
+	configDigest := registry.Digest([]byte(config))[len("sha256:"):]
+
+	layouts := map[string]map[string]string{
+		"legacy": {
+			configDigest + ".json": config,
+			"abc/layer.tar":        "layer",
+			"manifest.json":        `[{"Config":"` + configDigest + `.json","RepoTags":["ghcr.io/mona/app:1.0"],"Layers":["abc/layer.tar"]}]`,
+			"repositories":         `{"ghcr.io/mona/app":{"1.0":"abc"}}`,
+		},
+		"oci": {
+			"blobs/sha256/" + configDigest: config,
+			"blobs/sha256/abc":             "layer",
+			"index.json":                   `{"schemaVersion":2,"manifests":[]}`,
+			"oci-layout":                   `{"imageLayoutVersion":"1.0.0"}`,
+			"manifest.json":                `[{"Config":"blobs/sha256/` + configDigest + `","RepoTags":["ghcr.io/mona/app:1.0"],"Layers":["blobs/sha256/abc"]}]`,
+		},
+	}
+	for name, entries := range layouts {
+		t.Run(name, func(t *testing.T) {
+			image, err := providers.ReadSavedImage(writeSavedImage(t, entries))
+			if err != nil {
+				t.Fatalf("ReadSavedImage returned an error: %v", err)
+			}
+			if image.ID() != "sha256:"+configDigest {
+				t.Errorf("ID = %s, want the digest of the config", image.ID())
+			}
+			labels, err := image.Labels()
+			if err != nil || labels["team"] != "a" {
+				t.Fatalf("Labels = %v, %v", labels, err)
+			}
+
+			labels["org.opencontainers.image.source"] = "https://github.com/octo/app"
+			var out bytes.Buffer
+			id, err := image.WriteRelabeled("ghcr.io/octo/app:1.0", labels, &out)
+			if err != nil {
+				t.Fatalf("WriteRelabeled returned an error: %v", err)
+			}
+			written := readTarball(t, out.Bytes())
+			for _, dropped := range []string{"index.json", "oci-layout", "repositories"} {
+				if _, ok := written[dropped]; ok {
+					t.Errorf("%s naming the source image was copied", dropped)
+				}
+			}
+
+			var manifests []struct {
+				Config   string
+				RepoTags []string
+				Layers   []string
+			}
+			if err := json.Unmarshal(written["manifest.json"], &manifests); err != nil || len(manifests) != 1 {
+				t.Fatalf("manifest.json = %s, %v", written["manifest.json"], err)
+			}
+			if manifests[0].RepoTags[0] != "ghcr.io/octo/app:1.0" || string(written[manifests[0].Layers[0]]) != "layer" {
+				t.Errorf("manifest.json = %+v, want the target tag and the same layers", manifests[0])
+			}
+			relabeled := written[manifests[0].Config]
+			if registry.Digest(relabeled) != id {
+				t.Errorf("WriteRelabeled returned %s, the config is %s", id, registry.Digest(relabeled))
+			}
+
+			var got struct {
+				Created string
+				Config  struct {
+					Cmd    []string
+					Labels map[string]string
+				}
+				History []json.RawMessage
+				Rootfs  struct {
+					DiffIDs []string `json:"diff_ids"`
+				}
+			}
+			if err := json.Unmarshal(relabeled, &got); err != nil {
+				t.Fatalf("relabeled config = %s, %v", relabeled, err)
+			}
+			if got.Config.Labels["org.opencontainers.image.source"] != "https://github.com/octo/app" || got.Config.Labels["team"] != "a" {
+				t.Errorf("labels = %v", got.Config.Labels)
+			}
+			if got.Created != "2023-01-01T00:00:00Z" || len(got.History) != 1 || len(got.Rootfs.DiffIDs) != 1 || got.Config.Cmd[0] != "app" {
+				t.Errorf("relabeling changed more than the labels: %s", relabeled)
+			}
+		})
+	}
+}
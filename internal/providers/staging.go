@@ -0,0 +1,269 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// How pull names the npm tarballs and container image tarballs it stages
+const (
+	// StagingNormalized stages them as <name>-<version>.tgz and <name>-<tag>.tar
+	StagingNormalized = "normalized"
+	// StagingOriginal keeps the name of the tarball in the registry, name:tag for images
+	StagingOriginal = "original"
+)
+
+// STAGING_NAME_SCHEMES are the values accepted by --staging-names
+var STAGING_NAME_SCHEMES = []string{StagingNormalized, StagingOriginal}
+
+// StagingManifestFile is the file, in every staged version directory, recording the
+// name each file of the inventory was staged under
+const StagingManifestFile = "staging.json"
+
+// StagingManifest describes a staged version: what it was pulled from, and the
+// file staged for each of its inventory filenames, relative to its directory
+type StagingManifest struct {
+	Organization string            `json:"organization,omitempty"`
+	Repository   string            `json:"repository,omitempty"`
+	PackageType  string            `json:"package_type,omitempty"`
+	Package      string            `json:"package,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	Scheme       string            `json:"scheme"`
+	Files        map[string]string `json:"files"`
+}
+
+// StagingLayoutFile describes the staging store, at the root of its packages directory
+const StagingLayoutFile = "layout.json"
+
+// STAGING_LAYOUT_VERSION is the version of the layout pull writes:
+// packages/<organization>/<type>/<package>/<version or tag>/<file>
+const STAGING_LAYOUT_VERSION = 1
+
+// StagingLayout lists the source organizations staged under the packages
+// directory, each in a directory named after it. Paths are relative to the
+// migration directory, so the store can be copied to another machine.
+type StagingLayout struct {
+	Version       int      `json:"version"`
+	Organizations []string `json:"organizations"`
+}
+
+// stagingMutex keeps concurrent downloads of a version from overwriting each
+// other's entries in its manifest
+var stagingMutex sync.Mutex
+
+// StagingNames returns the GHMPKG_STAGING_NAMES scheme, normalized when unset
+func StagingNames() (string, error) {
+	scheme := strings.ToLower(viper.GetString("GHMPKG_STAGING_NAMES"))
+	if scheme == "" {
+		return StagingNormalized, nil
+	}
+	if !utils.Contains(STAGING_NAME_SCHEMES, scheme) {
+		return "", fmt.Errorf("invalid --staging-names %q, expected one of: %s", scheme, strings.Join(STAGING_NAME_SCHEMES, ", "))
+	}
+	return scheme, nil
+}
+
+// stagedName is the name a file is staged under with the configured scheme,
+// given its normalized name and its filename in the inventory
+func stagedName(normalized, filename string) string {
+	if scheme, _ := StagingNames(); scheme == StagingOriginal {
+		return filepath.Base(filename)
+	}
+	return normalized
+}
+
+// ReadStagingManifest reads the manifest of a staged version directory, nil
+// when the version was pulled before manifests were written
+func ReadStagingManifest(dir string) (*StagingManifest, error) {
+	content, err := os.ReadFile(filepath.Join(dir, StagingManifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest StagingManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, StagingManifestFile), err)
+	}
+	return &manifest, nil
+}
+
+// recordStaged adds a staged file to the manifest of its version directory
+func (p *BaseProvider) recordStaged(logger *zap.Logger, dir, owner, repository, packageName, version, filename, staged string) {
+	stagingMutex.Lock()
+	defer stagingMutex.Unlock()
+	manifest, err := ReadStagingManifest(dir)
+	if err == nil && manifest == nil {
+		manifest = &StagingManifest{}
+	}
+	if err == nil {
+		manifest.Organization, manifest.Repository, manifest.PackageType = owner, repository, p.PackageType
+		manifest.Package, manifest.Version = packageName, version
+		manifest.Scheme, _ = StagingNames()
+		if manifest.Files == nil {
+			manifest.Files = make(map[string]string)
+		}
+		manifest.Files[filename] = staged
+		var content []byte
+		if content, err = json.MarshalIndent(manifest, "", "  "); err == nil {
+			path := filepath.Join(dir, StagingManifestFile)
+			if err = utils.WriteFileAtomic(path, content, 0644); err == nil {
+				err = p.storeOutput(logger, path)
+			}
+		}
+	}
+	if err != nil {
+		logger.Warn("Failed to record staged file", zap.String("dir", dir), zap.String("filename", filename), zap.Error(err))
+	}
+}
+
+// stagedPath returns the path of the file staged for an inventory file: the
+// name the manifest recorded when the version was pulled, the name of the
+// configured scheme otherwise
+func stagedPath(dir, normalized, filename string) string {
+	if manifest, err := ReadStagingManifest(dir); err == nil && manifest != nil {
+		if staged, ok := manifest.Files[filename]; ok {
+			return filepath.Join(dir, staged)
+		}
+	}
+	return filepath.Join(dir, stagedName(normalized, filename))
+}
+
+// stagedDir is the directory a version is staged in, the directory of its tag
+// for container images
+func stagedDir(migrationPath, owner, packageType, packageName, version, filename string) string {
+	if IsImage(packageType) {
+		_, tag, _ := strings.Cut(filename, ":")
+		return filepath.Join(migrationPath, "packages", owner, packageType, packageName, tag)
+	}
+	return filepath.Join(migrationPath, "packages", owner, packageType, packageName, version)
+}
+
+// IsStaged reports whether pull staged the version of an inventory file, in
+// the migration directory or the --storage backend
+func IsStaged(logger *zap.Logger, owner, packageType, packageName, version, filename string) bool {
+	migrationPath := utils.MigrationPath()
+	if IsImage(packageType) {
+		owner, packageName = NormalizeName(packageType, OwnerField, owner), NormalizeName(packageType, NameField, packageName)
+	}
+	dir := stagedDir(migrationPath, owner, packageType, packageName, version, filename)
+	return utils.FileExists(dir) || (&BaseProvider{}).isStored(logger, dir)
+}
+
+// VerifyStaged checks the file pull staged for an inventory file before sync
+// --transfer deletes the package it is published again from. The file must be
+// recorded in the manifest of its version directory, fetched from the --storage
+// backend when missing, and match the checksum the export recorded, if any.
+func VerifyStaged(logger *zap.Logger, owner, packageType, packageName, version, filename, checksum string) error {
+	dir := stagedDir(utils.MigrationPath(), owner, packageType, packageName, version, filename)
+	if _, err := (&BaseProvider{PackageType: packageType}).fetchStored(logger, dir); err != nil {
+		return err
+	}
+	manifest, err := ReadStagingManifest(dir)
+	if err != nil {
+		return err
+	}
+	if manifest == nil || manifest.Files[filename] == "" {
+		return fmt.Errorf("%s of %s %s is not staged, run pull before transferring the package", filename, packageName, version)
+	}
+	digest, err := utils.FileDigest(filepath.Join(dir, manifest.Files[filename]))
+	if err != nil {
+		return fmt.Errorf("%s of %s %s is not staged, run pull before transferring the package: %w", filename, packageName, version, err)
+	}
+	if checksum != "" && !SameChecksum(checksum, digest) {
+		return fmt.Errorf("staged %s of %s %s does not match the exported checksum: expected %s, got %s", filename, packageName, version, checksum, digest)
+	}
+	return nil
+}
+
+// ReadStagingLayout reads the layout of the staging store of a migration
+// directory. Stores pulled before the layout was written are described by the
+// organization directories they have.
+func ReadStagingLayout(migrationPath string) (*StagingLayout, error) {
+	packagesDir := filepath.Join(migrationPath, "packages")
+	content, err := os.ReadFile(filepath.Join(packagesDir, StagingLayoutFile))
+	if err == nil {
+		var layout StagingLayout
+		if err := json.Unmarshal(content, &layout); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(packagesDir, StagingLayoutFile), err)
+		}
+		return &layout, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	layout := &StagingLayout{Version: STAGING_LAYOUT_VERSION}
+	entries, err := os.ReadDir(packagesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			layout.Organizations = append(layout.Organizations, entry.Name())
+		}
+	}
+	return layout, nil
+}
+
+// RecordStagingLayout adds the source organization pull stages to the layout
+// of the staging store, and copies the layout to the --storage backend
+func RecordStagingLayout(logger *zap.Logger, migrationPath, owner string) error {
+	layout, err := ReadStagingLayout(migrationPath)
+	if err != nil {
+		return err
+	}
+	if !utils.Contains(layout.Organizations, owner) {
+		layout.Organizations = append(layout.Organizations, owner)
+	}
+	layout.Version = STAGING_LAYOUT_VERSION
+	content, err := json.MarshalIndent(layout, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(migrationPath, "packages", StagingLayoutFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(path, content, 0644); err != nil {
+		return err
+	}
+	return (&BaseProvider{}).storeOutput(logger, path)
+}
+
+// StagedOrganization returns the source organization whose staged packages
+// sync publishes: the configured one, or the only one the staging store has
+// when sync runs without it, such as on another machine than the pull
+func StagedOrganization(migrationPath, owner string) (string, error) {
+	if owner != "" {
+		return owner, nil
+	}
+	// A store only copied to object storage is described by the stored layout
+	path := filepath.Join(migrationPath, "packages", StagingLayoutFile)
+	if store, err := ArtifactStorage(); err == nil && store != nil && !utils.FileExists(path) {
+		if key, err := storageKey(path); err == nil {
+			if err := fetchFile(store, key, path); err != nil {
+				os.Remove(path)
+			}
+		}
+	}
+	layout, err := ReadStagingLayout(migrationPath)
+	if err != nil {
+		return "", err
+	}
+	switch len(layout.Organizations) {
+	case 0:
+		return "", nil
+	case 1:
+		return layout.Organizations[0], nil
+	}
+	return "", fmt.Errorf("%s stages the packages of %s, set the source organization to sync", filepath.Join(migrationPath, "packages"), strings.Join(layout.Organizations, ", "))
+}
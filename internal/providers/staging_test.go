@@ -0,0 +1,127 @@
+package providers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestStagingNames(t *testing.T) {
+	defer viper.Set("GHMPKG_STAGING_NAMES", "")
+
+	for value, want := range map[string]string{"": providers.StagingNormalized, "Original": providers.StagingOriginal} {
+		viper.Set("GHMPKG_STAGING_NAMES", value)
+		if scheme, err := providers.StagingNames(); err != nil || scheme != want {
+			t.Errorf("StagingNames(%q) = %s, %v, want %s", value, scheme, err, want)
+		}
+	}
+	viper.Set("GHMPKG_STAGING_NAMES", "flat")
+	if _, err := providers.StagingNames(); err == nil {
+		t.Error("StagingNames accepted an unknown scheme")
+	}
+}
+
+func TestUploadStagedName(t *testing.T) {
+	defer viper.Reset()
+	migrationPath := t.TempDir()
+	packageDir := filepath.Join(migrationPath, "packages", "mona", "npm", "web", "1.2.0")
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	deployed := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			body, _ := io.ReadAll(r.Body)
+			deployed[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	viper.Set("GHMPKG_MIGRATION_PATH", migrationPath)
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_REGISTRY", "artifactory")
+	viper.Set("GHMPKG_ARTIFACTORY_URL", server.URL+"/artifactory/")
+	viper.Set("GHMPKG_ARTIFACTORY_USER", "deployer")
+	viper.Set("GHMPKG_ARTIFACTORY_API_KEY", "key")
+	viper.Set("GHMPKG_ARTIFACTORY_REPOS", []string{"npm=npm-local"})
+	target := "/artifactory/npm-local/@mona/web/-/@mona/web-1.2.0.tgz"
+
+	tests := []struct {
+		name, scheme, staged string
+		manifest             map[string]string
+	}{
+		{"recorded in staging.json", providers.StagingNormalized, "web-1.2.0-registry.tgz", map[string]string{"web-1.2.0-registry.tgz": "web-1.2.0-registry.tgz"}},
+		{"configured scheme without staging.json", providers.StagingOriginal, "web-1.2.0-registry.tgz", nil},
+		{"normalized without staging.json", providers.StagingNormalized, "web-1.2.0.tgz", nil},
+	}
+	for _, test := range tests {
+		os.RemoveAll(packageDir)
+		if err := os.MkdirAll(packageDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(packageDir, test.staged), []byte(test.name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if test.manifest != nil {
+			content, _ := json.Marshal(providers.StagingManifest{Scheme: providers.StagingOriginal, Files: test.manifest})
+			if err := os.WriteFile(filepath.Join(packageDir, providers.StagingManifestFile), content, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		viper.Set("GHMPKG_STAGING_NAMES", test.scheme)
+
+		provider := connectTarget(t, "npm")
+		result, err := provider.Upload(zap.NewNop(), "mona", "repo", "npm", "web", "1.2.0", "web-1.2.0-registry.tgz")
+		if err != nil || result != providers.Success {
+			t.Errorf("%s: Upload = %v, %v", test.name, result, err)
+			continue
+		}
+		if deployed[target] != test.name {
+			t.Errorf("%s: deployed %q, want the staged %s", test.name, deployed[target], test.staged)
+		}
+	}
+}
+
+func TestStagedOrganization(t *testing.T) {
+	defer viper.Reset()
+	migrationPath := t.TempDir()
+	viper.Set("GHMPKG_MIGRATION_PATH", migrationPath)
+
+	if owner, err := providers.StagedOrganization(migrationPath, ""); err != nil || owner != "" {
+		t.Errorf("StagedOrganization of an empty store = %q, %v", owner, err)
+	}
+
+	// Stores pulled before the layout was written are described by their directories
+	if err := os.MkdirAll(filepath.Join(migrationPath, "packages", "team-a", "npm"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if owner, err := providers.StagedOrganization(migrationPath, ""); err != nil || owner != "team-a" {
+		t.Errorf("StagedOrganization = %q, %v, want team-a", owner, err)
+	}
+
+	if err := providers.RecordStagingLayout(zap.NewNop(), migrationPath, "team-b"); err != nil {
+		t.Fatal(err)
+	}
+	layout, err := providers.ReadStagingLayout(migrationPath)
+	if err != nil || len(layout.Organizations) != 2 || layout.Version != providers.STAGING_LAYOUT_VERSION {
+		t.Fatalf("ReadStagingLayout = %+v, %v, want team-a and team-b", layout, err)
+	}
+	if _, err := providers.StagedOrganization(migrationPath, ""); err == nil {
+		t.Error("StagedOrganization picked one of two staged organizations")
+	}
+	if owner, err := providers.StagedOrganization(migrationPath, "team-b"); err != nil || owner != "team-b" {
+		t.Errorf("StagedOrganization(team-b) = %q, %v", owner, err)
+	}
+}
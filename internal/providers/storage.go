@@ -0,0 +1,166 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/storage"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var (
+	storageOnce     sync.Once
+	artifactStorage storage.Storage
+	storageErr      error
+	// fetchMutex keeps concurrent uploads of a version from fetching its files twice
+	fetchMutex sync.Mutex
+)
+
+// ArtifactStorage returns the --storage backend pulled files are copied to and
+// synced from, nil when they are only kept in the migration directory
+func ArtifactStorage() (storage.Storage, error) {
+	storageOnce.Do(func() {
+		artifactStorage, storageErr = storage.New(viper.GetString("GHMPKG_STORAGE"))
+	})
+	return artifactStorage, storageErr
+}
+
+// storageKey is the key of a path under migration-packages/packages
+func storageKey(localPath string) (string, error) {
+	relative, err := filepath.Rel(filepath.Join(utils.MigrationPath(), "packages"), localPath)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return "", fmt.Errorf("%s is not in the packages directory", localPath)
+	}
+	return filepath.ToSlash(relative), nil
+}
+
+// storedKeys lists the objects stored for a file, or for every file of a
+// directory such as an OCI image layout
+func storedKeys(store storage.Storage, localPath string) ([]string, error) {
+	key, err := storageKey(localPath)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := store.List(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	var matching []string
+	for _, stored := range keys {
+		if stored == key || strings.HasPrefix(stored, key+"/") {
+			matching = append(matching, stored)
+		}
+	}
+	return matching, nil
+}
+
+// isStored reports whether a pulled file is in the --storage backend
+func (p *BaseProvider) isStored(logger *zap.Logger, localPath string) bool {
+	store, err := ArtifactStorage()
+	if err != nil || store == nil {
+		return false
+	}
+	keys, err := storedKeys(store, localPath)
+	if err != nil {
+		logger.Warn("Failed to list stored files", zap.String("path", localPath), zap.Error(err))
+		return false
+	}
+	return len(keys) > 0
+}
+
+// storeOutput copies a pulled file, or directory, to the --storage backend
+func (p *BaseProvider) storeOutput(logger *zap.Logger, localPath string) error {
+	store, err := ArtifactStorage()
+	if err != nil || store == nil {
+		return err
+	}
+	return filepath.Walk(localPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		key, err := storageKey(filePath)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		logger.Info("Storing file", zap.String("path", filePath), zap.String("storage", store.String()))
+		if err := store.Put(context.Background(), key, file, info.Size()); err != nil {
+			return fmt.Errorf("failed to store %s in %s: %w", filePath, store, err)
+		}
+		return nil
+	})
+}
+
+// fetchStored copies the files stored under a package directory from the
+// --storage backend into the migration directory, reporting whether any were
+func (p *BaseProvider) fetchStored(logger *zap.Logger, packageDir string) (bool, error) {
+	store, err := ArtifactStorage()
+	if err != nil || store == nil {
+		return false, err
+	}
+	fetchMutex.Lock()
+	defer fetchMutex.Unlock()
+	if _, err := os.Stat(packageDir); err == nil {
+		return true, nil
+	}
+	dirKey, err := storageKey(packageDir)
+	if err != nil {
+		return false, err
+	}
+	keys, err := storedKeys(store, packageDir)
+	if err != nil || len(keys) == 0 {
+		return false, err
+	}
+	// Fetch into a staging directory, a partial fetch must not be taken for a pulled version
+	stagingDir := packageDir + ".part"
+	os.RemoveAll(stagingDir)
+	for _, key := range keys {
+		localPath := filepath.Join(stagingDir, filepath.FromSlash(strings.TrimPrefix(key, dirKey+"/")))
+		logger.Info("Fetching stored file", zap.String("key", key), zap.String("storage", store.String()))
+		if err := fetchFile(store, key, localPath); err != nil {
+			os.RemoveAll(stagingDir)
+			return false, fmt.Errorf("failed to fetch %s from %s: %w", key, store, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(packageDir), 0755); err != nil {
+		os.RemoveAll(stagingDir)
+		return false, err
+	}
+	if err := os.Rename(stagingDir, packageDir); err != nil {
+		os.RemoveAll(stagingDir)
+		return false, err
+	}
+	return true, nil
+}
+
+// fetchFile downloads an object to a local path
+func fetchFile(store storage.Storage, key, localPath string) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	content, err := store.Get(context.Background(), key)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	file, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
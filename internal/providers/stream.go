@@ -0,0 +1,216 @@
+package providers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/registry"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Streamer is implemented by providers that can copy a file from the source
+// registry to the target in one pass, without storing it in the migration directory
+type Streamer interface {
+	Stream(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error)
+}
+
+func hashDigest(h hash.Hash) string {
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// streamFile downloads a file and uploads it at the same time through a pipe.
+// The download is checked against the exported checksum before the upload is
+// completed, a mismatch aborts it. Files that rewrite changes are read in memory
+// first, which is only meant for small metadata files. The uploaded content is
+// also written to uploaded when it is not nil.
+func (p *BaseProvider) streamFile(logger *zap.Logger, repository, packageName, version, filename, downloadUrl, uploadUrl string, rewrite func([]byte) []byte, uploaded io.Writer) (ResultState, error) {
+	logger.Info("Streaming file", zap.String("from", downloadUrl), zap.String("to", uploadUrl))
+	body, size, err := utils.OpenDownload(downloadUrl, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", p.PackageType))
+	if err != nil {
+		return Failed, err
+	}
+	defer body.Close()
+
+	sourceHash := sha256.New()
+	var content io.Reader
+	var sourceDigest, targetDigest string
+	copyErr := make(chan error, 1)
+
+	if rewrite != nil {
+		raw, err := io.ReadAll(io.TeeReader(body, sourceHash))
+		if err != nil {
+			return Failed, fmt.Errorf("failed to download %s: %w", filename, err)
+		}
+		sourceDigest = hashDigest(sourceHash)
+		if err := p.checkChecksum(logger, repository, packageName, version, filename, sourceDigest); err != nil {
+			return Failed, err
+		}
+		raw = rewrite(raw)
+		targetDigest = registry.Digest(raw)
+		content, size = bytes.NewReader(raw), int64(len(raw))
+		copyErr <- nil
+	} else {
+		reader, writer := io.Pipe()
+		defer reader.Close()
+		go func() {
+			_, err := io.Copy(io.MultiWriter(writer, sourceHash), body)
+			if err == nil {
+				err = p.checkChecksum(logger, repository, packageName, version, filename, hashDigest(sourceHash))
+			}
+			// Closing with an error fails the upload before the registry gets the whole file
+			writer.CloseWithError(err)
+			copyErr <- err
+		}()
+		content = reader
+	}
+
+	upload := content
+	if uploaded != nil {
+		upload = io.TeeReader(content, uploaded)
+	}
+	response, uploadErr := utils.UploadStream(uploadUrl, filename, upload, size, utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType))
+	if closer, ok := content.(io.Closer); ok {
+		closer.Close()
+	}
+	// A closed pipe only means the registry answered before reading the whole
+	// file, the response says why
+	downloadErr := <-copyErr
+	if downloadErr != nil && !errors.Is(downloadErr, io.ErrClosedPipe) {
+		return Failed, downloadErr
+	}
+	if uploadErr != nil {
+		return Failed, uploadErr
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusConflict {
+		return Skip(SkipExistsOnTarget, fmt.Sprintf("%s is already published", filename))
+	} else if response.StatusCode > 299 {
+		return Failed, fmt.Errorf("error uploading file: %s, status: %s", filename, response.Status)
+	} else if downloadErr != nil {
+		return Failed, fmt.Errorf("upload of %s ended before the download: %w", filename, downloadErr)
+	}
+
+	if sourceDigest == "" {
+		sourceDigest = hashDigest(sourceHash)
+		targetDigest = sourceDigest
+	}
+	p.recordSourceDigest(logger, repository, packageName, version, filename, sourceDigest)
+	p.recordTargetDigest(logger, repository, packageName, version, filename, targetDigest)
+	return Success, nil
+}
+
+// Stream copies a Maven artifact from the source registry to the target, poms
+// and Gradle module files get their URLs rewritten like in Rename
+func (p *MavenProvider) Stream(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	if IsMavenChecksum(filename) {
+		return Skip(SkipChecksumRegenerated, filename)
+	}
+	downloadUrl, err := p.GetDownloadUrl(logger, owner, repository, packageName, version, filename)
+	if err != nil {
+		return Failed, err
+	}
+	uploadUrl, err := p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
+	if err != nil {
+		return Failed, err
+	}
+	var rewrite func([]byte) []byte
+	if !p.CheckOrganizationsMatch(logger) {
+		rewrite = p.rewriter(filename)
+	}
+	checksums := newMavenChecksums()
+	result, err := p.streamFile(logger, repository, packageName, version, filename, downloadUrl, uploadUrl, rewrite, checksums)
+	if err != nil && !IsSkip(err) {
+		logger.Error("Error streaming file", zap.String("filename", filename), zap.Error(err))
+	}
+	if result == Success {
+		if err := checksums.upload(logger, p.PackageType, uploadUrl, filename); err != nil {
+			return Failed, err
+		}
+	}
+	return result, err
+}
+
+// rewriteSourceLabel points the org.opencontainers.image.source label of an image
+// config at the target organization, like Rename does through the Docker daemon.
+// The config is returned unchanged when the label does not change.
+func rewriteSourceLabel(config []byte, sourceOrg, targetOrg string) ([]byte, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(config, &document); err != nil {
+		return nil, err
+	}
+	imageConfig, _ := document["config"].(map[string]interface{})
+	labels, _ := imageConfig["Labels"].(map[string]interface{})
+	source, _ := labels["org.opencontainers.image.source"].(string)
+	target := targetSourceLabel(source, sourceOrg, targetOrg)
+	if source == "" || target == source {
+		return config, nil
+	}
+	labels["org.opencontainers.image.source"] = target
+	return json.Marshal(document)
+}
+
+// Stream copies an image, a manifest list with every platform or an OCI
+// artifact from the source registry to the target without the Docker daemon
+func (p *ContainerProvider) Stream(logger *zap.Logger, owner, repository, packageType, packageName, version, filename string) (ResultState, error) {
+	if p.sourceRegistry == nil || p.targetRegistry == nil {
+		return Failed, fmt.Errorf("source and target registry credentials are required to stream %s", filename)
+	}
+	ledgerRepository, ledgerName := repository, packageName
+	sourceOrg := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOrg := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	sourceOwner, repositoryName, packageName := p.normalizeNames(sourceOrg, repository, packageName)
+	targetRepository, targetName, _ := p.targetNames(repository, packageName, filename)
+	targetOwner, targetRepository, targetName := p.normalizeNames(targetOrg, targetRepository, targetName)
+
+	_, tag, ok := strings.Cut(filename, ":")
+	if !ok {
+		return Failed, fmt.Errorf("container filename %s has no tag", filename)
+	}
+	sourceRepository := p.source.Repository(sourceOwner, repositoryName, packageName)
+	_, desc, err := p.sourceRegistry.GetManifest(p.ctx, sourceRepository, tag)
+	if err != nil {
+		return Failed, err
+	}
+	// The tag must still point at the version that was exported
+	if err := p.checkChecksum(logger, ledgerRepository, ledgerName, version, filename, desc.Digest); err != nil {
+		logger.Error("Image does not match the exported digest", zap.String("filename", filename), zap.Error(err))
+		return Failed, err
+	}
+
+	var rewrite func([]byte) ([]byte, error)
+	if !p.CheckOrganizationsMatch(logger) || !currentMapping().IsEmpty() {
+		rewrite = func(config []byte) ([]byte, error) {
+			return rewriteSourceLabel(config, sourceOrg, targetOrg)
+		}
+	}
+	logger.Info("Streaming image", zap.String("from", sourceRepository), zap.String("tag", tag))
+	pushed, err := registry.Copy(p.ctx, p.sourceRegistry, sourceRepository, desc.Digest, p.targetRegistry, p.target.Repository(targetOwner, targetRepository, targetName), tag, rewrite)
+	if err != nil {
+		logger.Error("Failed to stream image", zap.String("filename", filename), zap.Error(err))
+		return Failed, err
+	}
+	p.recordSourceDigest(logger, ledgerRepository, ledgerName, version, filename, desc.Digest)
+	p.recordTargetDigest(logger, ledgerRepository, ledgerName, version, filename, pushed.Digest)
+	if viper.GetBool("GHMPKG_COPY_REFERRERS") {
+		count, err := registry.CopyReferrers(p.ctx, p.sourceRegistry, sourceRepository, p.targetRegistry, p.target.Repository(targetOwner, targetRepository, targetName), desc.Digest)
+		if err != nil {
+			logger.Error("Failed to stream referrers", zap.String("filename", filename), zap.Error(err))
+			return Failed, err
+		}
+		if count > 0 {
+			logger.Info("Streamed referrers", zap.String("filename", filename), zap.Int("count", count))
+			warnRecreated(logger, packageName, tag, desc.Digest, pushed.Digest)
+		}
+	}
+	return Success, nil
+}
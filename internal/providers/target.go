@@ -0,0 +1,100 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/registry"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"go.uber.org/zap"
+)
+
+// TargetReader is implemented by providers that can read a migrated file back
+// from the target registry, so it can be compared with what was uploaded
+type TargetReader interface {
+	TargetDigest(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error)
+}
+
+// fetchDigest downloads a file from the target registry to a temporary file
+// and returns its digest
+func (p *BaseProvider) fetchDigest(logger *zap.Logger, fileUrl string) (string, error) {
+	tmp, err := os.CreateTemp("", "ghmpkg-target-*")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	logger.Info("Fetching file from target", zap.String("url", fileUrl))
+	if err := utils.DownloadFile(fileUrl, tmp.Name(), utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", p.PackageType)); err != nil {
+		return "", err
+	}
+	return utils.FileDigest(tmp.Name())
+}
+
+// TargetDigest downloads a Maven artifact from the target registry
+func (p *MavenProvider) TargetDigest(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	fileUrl, err := p.GetUploadUrl(logger, owner, repository, packageName, version, filename)
+	if err != nil {
+		return "", err
+	}
+	return p.fetchDigest(logger, fileUrl)
+}
+
+// TargetDigest downloads an npm tarball from the target registry
+func (p *NPMProvider) TargetDigest(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	owner = NormalizeName(p.PackageType, OwnerField, owner)
+	targetName := TargetPackageName(p.PackageType, packageName)
+	// Tarballs are named after the package
+	if rest, ok := strings.CutPrefix(filename, packageName+"-"); ok && targetName != packageName {
+		filename = targetName + "-" + rest
+	}
+	targetName = NormalizeName(p.PackageType, NameField, targetName)
+	fileUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, "download", fmt.Sprintf("@%s", owner), targetName, version, filename)
+	return p.fetchDigest(logger, fileUrl.String())
+}
+
+// TargetDigest downloads a nupkg from the target registry
+func (p *NugetProvider) TargetDigest(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	if targetName := TargetPackageName(p.PackageType, packageName); targetName != packageName {
+		filename = targetName + strings.TrimPrefix(filename, packageName)
+		packageName = targetName
+	}
+	packageName = NormalizeName(p.PackageType, NameField, packageName)
+	version = NormalizeName(p.PackageType, VersionField, version)
+	fileUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, owner, "download", packageName, version, filename)
+	return p.fetchDigest(logger, fileUrl.String())
+}
+
+// TargetDigest downloads a gem from the target registry
+func (p *RubyGemsProvider) TargetDigest(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	fileUrl := utils.JoinUrlPath(*p.TargetRegistryUrl, owner, "gems", filename)
+	return p.fetchDigest(logger, fileUrl.String())
+}
+
+// TargetDigest returns the digest of the manifest a tag points at in the target
+// registry. It talks to the registry directly, no Docker daemon is needed.
+func (p *ContainerProvider) TargetDigest(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	_, tag, ok := strings.Cut(filename, ":")
+	if !ok {
+		return "", fmt.Errorf("container filename %s has no tag", filename)
+	}
+	repository, packageName, _ = p.targetNames(repository, packageName, filename)
+	owner, repository, packageName = p.normalizeNames(owner, repository, packageName)
+
+	client := p.targetRegistry
+	if client == nil {
+		client = registry.NewClient(p.target.ApiHost(), p.target.Username, p.target.Password)
+	}
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, desc, err := client.GetManifest(ctx, p.target.Repository(owner, repository, packageName), tag)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest, nil
+}
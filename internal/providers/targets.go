@@ -0,0 +1,151 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// Target registries selected with GHMPKG_TARGET_REGISTRY
+const (
+	TargetGitHub       = "github"
+	TargetArtifactory  = "artifactory"
+	TargetNexus        = "nexus"
+	TargetAzure        = "azure"
+	TargetCodeArtifact = "codeartifact"
+)
+
+// TARGET_REGISTRIES are the values accepted by --target-registry
+var TARGET_REGISTRIES = []string{TargetGitHub, TargetArtifactory, TargetNexus, TargetAzure, TargetCodeArtifact}
+
+// externalTarget is a registry other than GitHub Packages sync can publish to.
+// Its provider wraps the provider of the package type, which still exports and
+// pulls from GitHub, and only replaces the upload.
+type externalTarget struct {
+	// location is the URL of the registry, shown instead of a target organization
+	location func() string
+	// required are the settings without which nothing can be published
+	required []string
+	// check validates the other settings before anything is published
+	check func() error
+	wrap  func(Provider) Provider
+}
+
+var externalTargets = map[string]externalTarget{
+	TargetArtifactory: {
+		location: func() string { return viper.GetString("GHMPKG_ARTIFACTORY_URL") },
+		required: []string{"GHMPKG_ARTIFACTORY_URL", "GHMPKG_ARTIFACTORY_API_KEY"},
+		check: func() error {
+			_, err := ArtifactoryRepositories()
+			return err
+		},
+		wrap: func(provider Provider) Provider { return newArtifactoryProvider(provider) },
+	},
+	TargetNexus: {
+		location: func() string { return viper.GetString("GHMPKG_NEXUS_URL") },
+		required: []string{"GHMPKG_NEXUS_URL", "GHMPKG_NEXUS_USER", "GHMPKG_NEXUS_PASSWORD"},
+		check: func() error {
+			_, err := NexusRepositories()
+			return err
+		},
+		wrap: func(provider Provider) Provider { return newNexusProvider(provider) },
+	},
+	TargetAzure: {
+		location: func() string { return AzureFeedUrl().String() },
+		required: []string{"GHMPKG_AZURE_ORGANIZATION", "GHMPKG_AZURE_FEED", "GHMPKG_AZURE_TOKEN"},
+		check:    func() error { return nil },
+		wrap:     func(provider Provider) Provider { return newAzureProvider(provider) },
+	},
+	TargetCodeArtifact: {
+		location: CodeArtifactLocation,
+		required: []string{"GHMPKG_CODEARTIFACT_DOMAIN", "GHMPKG_CODEARTIFACT_REPOSITORY"},
+		check:    checkCodeArtifact,
+		wrap:     func(provider Provider) Provider { return newCodeArtifactProvider(provider) },
+	},
+}
+
+// TargetRegistry returns the registry sync publishes to, github unless set
+func TargetRegistry() string {
+	if target := strings.ToLower(strings.TrimSpace(viper.GetString("GHMPKG_TARGET_REGISTRY"))); target != "" {
+		return target
+	}
+	return TargetGitHub
+}
+
+// ExternalTarget reports whether sync publishes to a registry other than
+// GitHub Packages, which has no target organization
+func ExternalTarget() bool {
+	_, ok := externalTargets[TargetRegistry()]
+	return ok
+}
+
+// CheckTargetRegistry checks the target registry is supported and its
+// settings are valid
+func CheckTargetRegistry() error {
+	target := TargetRegistry()
+	if target == TargetGitHub {
+		return nil
+	}
+	external, ok := externalTargets[target]
+	if !ok {
+		return fmt.Errorf("unsupported target registry: %s (expected one of %v)", target, TARGET_REGISTRIES)
+	}
+	return external.check()
+}
+
+// TargetRequiredSettings returns the settings the target registry requires
+// instead of the target organization and token, nil for GitHub Packages
+func TargetRequiredSettings() []string {
+	return externalTargets[TargetRegistry()].required
+}
+
+// TargetUrl returns the URL of the target registry, empty for GitHub Packages
+func TargetUrl() string {
+	if external, ok := externalTargets[TargetRegistry()]; ok {
+		return external.location()
+	}
+	return ""
+}
+
+// wrapTarget wraps a provider to publish to the target registry, if it is not GitHub Packages
+func wrapTarget(provider Provider) Provider {
+	if external, ok := externalTargets[TargetRegistry()]; ok {
+		return external.wrap(provider)
+	}
+	return provider
+}
+
+// targetRepositories returns the repository of each package type, from
+// type=repository entries in a setting
+func targetRepositories(name, setting string, packageTypes []string) (map[string]string, error) {
+	repositories := make(map[string]string)
+	for _, value := range viper.GetStringSlice(setting) {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			packageType, key, ok := strings.Cut(entry, "=")
+			packageType = strings.ToLower(strings.TrimSpace(packageType))
+			if !ok || strings.TrimSpace(key) == "" {
+				return nil, fmt.Errorf("invalid %s repository %q, expected type=repository", name, entry)
+			}
+			if !utils.Contains(packageTypes, packageType) {
+				return nil, fmt.Errorf("%s repository %q is for an unsupported package type: %s (expected one of %v)", name, entry, packageType, packageTypes)
+			}
+			repositories[packageType] = strings.TrimSpace(key)
+		}
+	}
+	return repositories, nil
+}
+
+// targetBaseUrl normalizes the URL of a target registry, https when no scheme is given
+func targetBaseUrl(value string) string {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "/")
+	if value != "" && !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+	return value
+}
@@ -0,0 +1,152 @@
+package providers_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func connectTarget(t *testing.T, packageType string) providers.Provider {
+	t.Helper()
+	provider, err := providers.NewProvider(zap.NewNop(), packageType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Connect(zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+// targetSettings selects a target registry for packages of the mona organization
+func targetSettings(registry string, settings map[string]interface{}) {
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_REGISTRY", registry)
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+}
+
+type uploadUrlTest struct {
+	packageType, packageName, version, filename, want string
+}
+
+func checkUploadUrls(t *testing.T, tests []uploadUrlTest) {
+	t.Helper()
+	for _, test := range tests {
+		provider := connectTarget(t, test.packageType)
+		got, err := provider.GetUploadUrl(zap.NewNop(), "mona", "repo", test.packageName, test.version, test.filename)
+		if err != nil || got != test.want {
+			t.Errorf("%s upload URL = %s, %v, want %s", test.packageType, got, err, test.want)
+		}
+	}
+}
+
+func writePulled(t *testing.T, migrationPath, packageType, packageName, version, filename, content string) {
+	t.Helper()
+	packageDir := filepath.Join(migrationPath, "packages", "mona", packageType, packageName, version)
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(packageDir, filename), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// npmTarball is a package tarball with the given package.json
+func npmTarball(t *testing.T, manifest string) string {
+	t.Helper()
+	var out bytes.Buffer
+	gzipWriter := gzip.NewWriter(&out)
+	tarWriter := tar.NewWriter(gzipWriter)
+	if err := tarWriter.WriteHeader(&tar.Header{Name: "package/package.json", Mode: 0644, Size: int64(len(manifest))}); err != nil {
+		t.Fatal(err)
+	}
+	tarWriter.Write([]byte(manifest))
+	tarWriter.Close()
+	gzipWriter.Close()
+	return out.String()
+}
+
+// fakeRegistry stores the files PUT to it by escaped path. A HEAD request finds
+// a stored file and a GET of its .sha1 returns its checksum, like Maven feeds do.
+type fakeRegistry struct {
+	// authorized rejects the requests without the expected credentials
+	authorized func(r *http.Request) bool
+	// head sets the headers of a HEAD response for a stored file
+	head func(w http.ResponseWriter, content string)
+	// handle serves the requests specific to a registry, it reports whether it did
+	handle func(w http.ResponseWriter, r *http.Request) bool
+
+	mu     sync.Mutex
+	stored map[string]string
+}
+
+func (f *fakeRegistry) start(t *testing.T) *httptest.Server {
+	t.Helper()
+	f.stored = map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.authorized != nil && !f.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if f.handle != nil && f.handle(w, r) {
+			return
+		}
+		path := r.URL.EscapedPath()
+		switch r.Method {
+		case http.MethodHead:
+			content, ok := f.get(path)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if f.head != nil {
+				f.head(w, content)
+			}
+		case http.MethodGet:
+			content, ok := f.get(strings.TrimSuffix(path, ".sha1"))
+			if !ok || !strings.HasSuffix(path, ".sha1") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			sum := sha1.Sum([]byte(content))
+			w.Write([]byte(hex.EncodeToString(sum[:])))
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			f.put(path, string(body))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeRegistry) get(path string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	content, ok := f.stored[path]
+	return content, ok
+}
+
+func (f *fakeRegistry) put(path, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stored[path] = content
+}
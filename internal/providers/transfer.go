@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Transferring reports whether sync re-homes packages to other repositories of
+// their own organization, with GHMPKG_TRANSFER
+func Transferring() bool {
+	return viper.GetBool("GHMPKG_TRANSFER")
+}
+
+// TransferDeleting reports whether a transfer may delete the packages that can
+// only be published again once deleted, with GHMPKG_TRANSFER_DELETE
+func TransferDeleting() bool {
+	return Transferring() && viper.GetBool("GHMPKG_TRANSFER_DELETE")
+}
+
+// CheckTransfer validates the settings of a transfer: the source and target are
+// the same organization and the mapping file moves repositories. Packages are
+// published again from the files pull staged, they can't be streamed.
+func CheckTransfer() error {
+	if !Transferring() {
+		if viper.GetBool("GHMPKG_TRANSFER_DELETE") {
+			return fmt.Errorf("--transfer-delete only applies to --transfer")
+		}
+		return nil
+	}
+	sourceOrg := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOrg := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	if !strings.EqualFold(sourceOrg, targetOrg) {
+		return fmt.Errorf("--transfer moves packages between the repositories of one organization, the source organization %s and target organization %s differ", sourceOrg, targetOrg)
+	}
+	if !strings.EqualFold(viper.GetString("GHMPKG_SOURCE_HOSTNAME"), viper.GetString("GHMPKG_TARGET_HOSTNAME")) {
+		return fmt.Errorf("--transfer moves packages within one organization, the source and target hostnames differ")
+	}
+	if ExternalTarget() {
+		return fmt.Errorf("--transfer is not supported when publishing to %s", TargetRegistry())
+	}
+	if viper.GetBool("GHMPKG_STREAM") {
+		return fmt.Errorf("--transfer publishes the packages again from the files pull staged, pull the packages instead of streaming them")
+	}
+	mapping, err := TargetMapping()
+	if err != nil {
+		return err
+	}
+	if mapping == nil || len(mapping.Repositories) == 0 {
+		return fmt.Errorf("--transfer needs a --mapping-file with the repositories the packages move to")
+	}
+	return nil
+}
+
+// Transferred reports whether a transfer moves the packages of a repository,
+// the mapping file pointing it at another repository
+func Transferred(repository string) bool {
+	return repository != "" && !strings.EqualFold(TargetRepository(repository), repository)
+}
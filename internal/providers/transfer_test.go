@@ -0,0 +1,48 @@
+package providers_test
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+)
+
+func TestCheckTransfer(t *testing.T) {
+	defer viper.Reset()
+	if err := providers.CheckTransfer(); err != nil {
+		t.Fatalf("CheckTransfer without --transfer = %v", err)
+	}
+
+	viper.Set("GHMPKG_TRANSFER", true)
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+	viper.Set("GHMPKG_MAPPING_FILE", writeMapping(t, "mapping.yaml", "repositories:\n  monolith: api\n"))
+	if err := providers.CheckTransfer(); err == nil {
+		t.Error("a transfer between organizations was accepted")
+	}
+
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "Mona")
+	if err := providers.CheckTransfer(); err != nil {
+		t.Errorf("CheckTransfer = %v", err)
+	}
+	if !providers.Transferred("Monolith") || providers.Transferred("web") {
+		t.Error("Transferred does not follow the repositories of the mapping file")
+	}
+
+	viper.Set("GHMPKG_STREAM", true)
+	if err := providers.CheckTransfer(); err == nil {
+		t.Error("a streamed transfer was accepted")
+	}
+	viper.Set("GHMPKG_STREAM", false)
+
+	viper.Set("GHMPKG_MAPPING_FILE", writeMapping(t, "prefix.yaml", "prefix: team-a-\n"))
+	if err := providers.CheckTransfer(); err == nil {
+		t.Error("a transfer without repositories to move was accepted")
+	}
+
+	viper.Set("GHMPKG_TRANSFER", false)
+	viper.Set("GHMPKG_TRANSFER_DELETE", true)
+	if err := providers.CheckTransfer(); err == nil || providers.TransferDeleting() {
+		t.Error("--transfer-delete was accepted without --transfer")
+	}
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 
@@ -27,12 +28,101 @@ func (r ResultState) String() string {
 	return [...]string{"Success", "Skipped", "Failed"}[r]
 }
 
+// MarshalText lets result states appear by name in JSON output
+func (r ResultState) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText reads result states written by MarshalText
+func (r *ResultState) UnmarshalText(text []byte) error {
+	for _, state := range []ResultState{Success, Skipped, Failed} {
+		if state.String() == string(text) {
+			*r = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown result state: %s", text)
+}
+
+// SkipReason tells why an item was skipped, so benign skips can be told apart
+// from files that were never migrated
+type SkipReason string
+
+const (
+	// SkipExistsOnTarget: the target registry already has the file or version
+	SkipExistsOnTarget SkipReason = "exists_on_target"
+	// SkipPackageExistsOnTarget: the package already exists in the target organization
+	SkipPackageExistsOnTarget SkipReason = "package_exists_on_target"
+	// SkipAlreadyPulled: the file was downloaded by a previous pull
+	SkipAlreadyPulled SkipReason = "already_pulled"
+	// SkipLocalFilesMissing: sync found no pulled files for the version
+	SkipLocalFilesMissing SkipReason = "local_files_missing"
+	// SkipCompletedInPreviousRun: the state file records the file as completed
+	SkipCompletedInPreviousRun SkipReason = "completed_in_previous_run"
+	// SkipNoFiles: the version has no files to migrate
+	SkipNoFiles SkipReason = "version_has_no_files"
+	// SkipStreamUnsupported: sync --stream cannot copy this package type without staging it on disk
+	SkipStreamUnsupported SkipReason = "stream_unsupported"
+	// SkipTargetUnsupported: the target registry, e.g. Artifactory, cannot host this package type
+	SkipTargetUnsupported SkipReason = "target_unsupported"
+	// SkipChecksumRegenerated: the Maven checksum file is computed again from the migrated artifact
+	SkipChecksumRegenerated SkipReason = "checksum_regenerated"
+	// SkipNotTransferred: sync --transfer leaves the packages of repositories the mapping file does not move
+	SkipNotTransferred SkipReason = "not_transferred"
+	// SkipReadOnly: --read-only refuses to download or publish anything
+	SkipReadOnly SkipReason = "read_only"
+)
+
+// SkipError is returned along with Skipped to tell why an item was skipped. It
+// is not a failure, callers check IsSkip before treating an error as one.
+type SkipError struct {
+	Reason SkipReason
+	Detail string
+}
+
+func (e *SkipError) Error() string {
+	if e.Detail == "" {
+		return string(e.Reason)
+	}
+	return fmt.Sprintf("%s: %s", e.Reason, e.Detail)
+}
+
+// Skip returns Skipped with the reason
+func Skip(reason SkipReason, detail string) (ResultState, error) {
+	return Skipped, &SkipError{Reason: reason, Detail: detail}
+}
+
+// IsSkip reports whether err only tells why an item was skipped
+func IsSkip(err error) bool {
+	var skip *SkipError
+	return errors.As(err, &skip)
+}
+
+// NameReusedCode identifies uploads rejected because the target organization
+// deleted a package of the same name, which GitHub Packages does not let be reused
+const NameReusedCode = "GHMPKG_NAME_REUSED"
+
+// NameReusedError is returned when the target refuses a package name that was deleted before
+type NameReusedError struct {
+	PackageName string
+	Err         error
+}
+
+func (e *NameReusedError) Error() string {
+	return fmt.Sprintf("[%s] package name %s was deleted from the target organization and cannot be reused: %v", NameReusedCode, e.PackageName, e.Err)
+}
+
+func (e *NameReusedError) Unwrap() error {
+	return e.Err
+}
+
 type BaseProvider struct {
 	PackageType       string
 	SourceRegistryUrl *url.URL
 	TargetRegistryUrl *url.URL
 	SourceHostnameUrl *url.URL
 	TargetHostnameUrl *url.URL
+	checksums         *checksumCache
 }
 
 type Provider interface {
@@ -80,7 +170,9 @@ func LoadCache(path string) ([]PackageNode, error) {
 }
 
 type FileNode struct {
-	Name githubv4.String
+	Name   githubv4.String
+	Sha256 githubv4.String
+	Size   githubv4.Int
 }
 
 type FilesNode struct {
@@ -120,18 +212,27 @@ type PackageNode struct {
 	Versions    VersionsNode   `graphql:"versions(first: $versionsFirst, after: $versionsAfter)"`
 }
 
+type PackagesNode struct {
+	Nodes    []PackageNode
+	PageInfo struct {
+		EndCursor   githubv4.String
+		HasNextPage bool
+	}
+}
+
 type Query struct {
 	Organization struct {
-		Packages struct {
-			Nodes    []PackageNode
-			PageInfo struct {
-				EndCursor   githubv4.String
-				HasNextPage bool
-			}
-		} `graphql:"packages(first: $packagesFirst, after: $packagesAfter, packageType: $packageType)"`
+		Packages PackagesNode `graphql:"packages(first: $packagesFirst, after: $packagesAfter, packageType: $packageType)"`
 	} `graphql:"organization(login: $owner)"`
 }
 
+// UserQuery lists the packages of a user account, which has no organization
+type UserQuery struct {
+	User struct {
+		Packages PackagesNode `graphql:"packages(first: $packagesFirst, after: $packagesAfter, packageType: $packageType)"`
+	} `graphql:"user(login: $owner)"`
+}
+
 type VersionQuery struct {
 	Node struct {
 		Package struct {
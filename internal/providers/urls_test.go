@@ -0,0 +1,261 @@
+package providers_test
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+type urlTest struct {
+	name                                              string
+	owner, repository, packageName, version, filename string
+	download, upload                                  string
+}
+
+func checkUrls(t *testing.T, packageType string, tests []urlTest) {
+	t.Helper()
+	logger := zap.NewNop()
+	provider, err := providers.NewProvider(logger, packageType)
+	if err != nil {
+		t.Fatalf("Failed to create %s provider: %v", packageType, err)
+	}
+
+	for _, test := range tests {
+		download, err := provider.GetDownloadUrl(logger, test.owner, test.repository, test.packageName, test.version, test.filename)
+		if err != nil {
+			t.Errorf("%s: GetDownloadUrl returned an error: %v", test.name, err)
+		} else if download != test.download {
+			t.Errorf("%s: GetDownloadUrl returned %s, expected %s", test.name, download, test.download)
+		}
+
+		upload, err := provider.GetUploadUrl(logger, test.owner, test.repository, test.packageName, test.version, test.filename)
+		if err != nil {
+			t.Errorf("%s: GetUploadUrl returned an error: %v", test.name, err)
+		} else if upload != test.upload {
+			t.Errorf("%s: GetUploadUrl returned %s, expected %s", test.name, upload, test.upload)
+		}
+	}
+}
+
+func TestNPMUrls(t *testing.T) {
+	checkUrls(t, "npm", []urlTest{
+		{
+			name: "plain name", owner: "mona", repository: "repo", packageName: "package", version: "1.0.0", filename: "package-1.0.0.tgz",
+			download: "https://npm.pkg.github.com/download/@mona/package/1.0.0/package-1.0.0.tgz",
+			upload:   "https://npm.pkg.github.com/@mona%2Fpackage",
+		},
+		{
+			name: "mixed case scope and name", owner: "Mona", repository: "repo", packageName: "My-Package", version: "1.0.0-beta.1", filename: "my-package-1.0.0-beta.1.tgz",
+			download: "https://npm.pkg.github.com/download/@mona/my-package/1.0.0-beta.1/my-package-1.0.0-beta.1.tgz",
+			upload:   "https://npm.pkg.github.com/@mona%2Fmy-package",
+		},
+	})
+}
+
+func TestMavenUrls(t *testing.T) {
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+	defer viper.Reset()
+
+	checkUrls(t, "maven", []urlTest{
+		{
+			name: "plain artifact", owner: "mona", repository: "repo", packageName: "com.example.app", version: "1.0", filename: "app-1.0.jar",
+			download: "https://maven.pkg.github.com/mona/repo/com.example.app/1.0/app-1.0.jar",
+			upload:   "https://maven.pkg.github.com/octo/repo/com.example.app/1.0/app-1.0.jar",
+		},
+		{
+			name: "unusual characters", owner: "mona", repository: "repo", packageName: "com.example.App Name#1", version: "1.0?x", filename: "App Name#1-1.0.jar",
+			download: "https://maven.pkg.github.com/mona/repo/com.example.App%20Name%231/1.0%3Fx/App%20Name%231-1.0.jar",
+			upload:   "https://maven.pkg.github.com/octo/repo/com.example.App%20Name%231/1.0%3Fx/App%20Name%231-1.0.jar",
+		},
+	})
+}
+
+func TestRubyGemsUrls(t *testing.T) {
+	checkUrls(t, "rubygems", []urlTest{
+		{
+			name: "mixed case gem", owner: "mona", repository: "repo", packageName: "MyGem", version: "1.0.0", filename: "MyGem-1.0.0.gem",
+			download: "https://rubygems.pkg.github.com/mona/gems/MyGem-1.0.0.gem",
+			upload:   "https://rubygems.pkg.github.com/mona/repo/MyGem/1.0.0/MyGem-1.0.0.gem",
+		},
+		{
+			name: "percent in filename", owner: "mona", repository: "repo", packageName: "gem", version: "1.0.0", filename: "gem%1.gem",
+			download: "https://rubygems.pkg.github.com/mona/gems/gem%251.gem",
+			upload:   "https://rubygems.pkg.github.com/mona/repo/gem/1.0.0/gem%251.gem",
+		},
+	})
+}
+
+func TestNugetUrls(t *testing.T) {
+	checkUrls(t, "nuget", []urlTest{
+		{
+			name: "mixed case id", owner: "mona", repository: "repo", packageName: "My.Package", version: "1.0.0", filename: "My.Package.1.0.0.nupkg",
+			download: "https://nuget.pkg.github.com/mona/download/my.package/1.0.0/My.Package.1.0.0.nupkg",
+			upload:   "https://nuget.pkg.github.com/mona/",
+		},
+		{
+			name: "version with build metadata", owner: "mona", repository: "repo", packageName: "Package", version: "1.0.0.0+sha.1", filename: "Package.1.0.0.nupkg",
+			download: "https://nuget.pkg.github.com/mona/download/package/1.0.0/Package.1.0.0.nupkg",
+			upload:   "https://nuget.pkg.github.com/mona/",
+		},
+	})
+}
+
+func TestEnterpriseServerUrls(t *testing.T) {
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+	// The API URL the commands normalize the hostname to
+	viper.Set("GHMPKG_SOURCE_HOSTNAME", "https://GHES.example.com/api/v3")
+	defer viper.Reset()
+
+	checkUrls(t, "maven", []urlTest{
+		{
+			name: "GitHub Enterprise Server to GitHub.com", owner: "mona", repository: "repo", packageName: "com.example.app", version: "1.0", filename: "app-1.0.jar",
+			download: "https://maven.ghes.example.com/mona/repo/com.example.app/1.0/app-1.0.jar",
+			upload:   "https://maven.pkg.github.com/octo/repo/com.example.app/1.0/app-1.0.jar",
+		},
+	})
+
+	viper.Set("GHMPKG_SOURCE_SUBDOMAIN_ISOLATION", "false")
+	viper.Set("GHMPKG_TARGET_HOSTNAME", "acme.ghe.com")
+	checkUrls(t, "npm", []urlTest{
+		{
+			name: "without subdomain isolation to GHE.com", owner: "mona", repository: "repo", packageName: "package", version: "1.0.0", filename: "package-1.0.0.tgz",
+			download: "https://ghes.example.com/_registry/npm/download/@mona/package/1.0.0/package-1.0.0.tgz",
+			upload:   "https://npm.pkg.acme.ghe.com/@mona%2Fpackage",
+		},
+	})
+	checkUrls(t, "nuget", []urlTest{
+		{
+			name: "without subdomain isolation to GHE.com", owner: "mona", repository: "repo", packageName: "Package", version: "1.0.0", filename: "Package.1.0.0.nupkg",
+			download: "https://ghes.example.com/_registry/nuget/mona/download/package/1.0.0/Package.1.0.0.nupkg",
+			upload:   "https://nuget.pkg.acme.ghe.com/mona/",
+		},
+	})
+}
+
+func TestContainerUrls(t *testing.T) {
+	defer viper.Reset()
+
+	checkUrls(t, "container", []urlTest{
+		{
+			name: "ghcr.io", owner: "Mona", repository: "repo", packageName: "app", version: "sha256:abc", filename: "app:1.0.0",
+			download: "ghcr.io/mona/app:1.0.0",
+			upload:   "ghcr.io/mona/app:1.0.0",
+		},
+	})
+
+	viper.Set("GHMPKG_SOURCE_HOSTNAME", "https://GHES.example.com/")
+	checkUrls(t, "container", []urlTest{
+		{
+			name: "GitHub Enterprise Server to ghcr.io", owner: "Mona", repository: "repo", packageName: "app", version: "sha256:abc", filename: "app:1.0.0",
+			download: "containers.ghes.example.com/mona/app:1.0.0",
+			upload:   "ghcr.io/mona/app:1.0.0",
+		},
+	})
+	if !providers.SourceContainerRegistry().IsGitHub() {
+		t.Error("containers.ghes.example.com is not the Container registry of the source")
+	}
+
+	viper.Set("GHMPKG_SOURCE_CONTAINER_REGISTRY", "https://123456789012.dkr.ecr.us-east-1.amazonaws.com/")
+	viper.Set("GHMPKG_TARGET_CONTAINER_REGISTRY", "docker.io/acme")
+	checkUrls(t, "container", []urlTest{
+		{
+			name: "ECR to a Docker Hub namespace", owner: "Mona", repository: "repo", packageName: "app", version: "sha256:abc", filename: "app:1.0.0",
+			download: "123456789012.dkr.ecr.us-east-1.amazonaws.com/mona/app:1.0.0",
+			upload:   "docker.io/acme/app:1.0.0",
+		},
+	})
+}
+
+func TestContainerRegistry(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+	viper.Set("GHMPKG_TARGET_TOKEN", "ghp_target")
+	target := providers.TargetContainerRegistry()
+	if !target.IsGitHub() || target.Username != "octo" || target.Password != "ghp_target" {
+		t.Errorf("default target registry = %+v, want ghcr.io with the target organization and token", target)
+	}
+	if !providers.TargetOnGitHub("container") {
+		t.Error("TargetOnGitHub(container) = false for ghcr.io")
+	}
+
+	viper.Set("GHMPKG_TARGET_CONTAINER_REGISTRY", "docker.io/acme")
+	viper.Set("GHMPKG_TARGET_CONTAINER_REGISTRY_USER", "acme-bot")
+	viper.Set("GHMPKG_TARGET_CONTAINER_REGISTRY_PASSWORD", "dckr_pat")
+	target = providers.TargetContainerRegistry()
+	if target.IsGitHub() || target.Username != "acme-bot" || target.Password != "dckr_pat" {
+		t.Errorf("Docker Hub target registry = %+v, want its own credentials", target)
+	}
+	if got := target.ApiHost(); got != "registry-1.docker.io" {
+		t.Errorf("ApiHost() = %s, want registry-1.docker.io", got)
+	}
+	if got := target.Repository("octo", "repo", "app"); got != "acme/app" {
+		t.Errorf("Repository() = %s, want acme/app", got)
+	}
+	if providers.TargetOnGitHub("container") {
+		t.Error("TargetOnGitHub(container) = true for Docker Hub")
+	}
+	if !providers.TargetOnGitHub("npm") {
+		t.Error("TargetOnGitHub(npm) = false, only images go to Docker Hub")
+	}
+}
+
+func TestDockerUrls(t *testing.T) {
+	viper.Set("GHMPKG_SOURCE_HOSTNAME", "https://GHES.example.com")
+	defer viper.Reset()
+
+	checkUrls(t, "docker", []urlTest{
+		{
+			name: "legacy registry to ghcr.io", owner: "Mona", repository: "Repo", packageName: "App", version: "1.0.0", filename: "app:1.0.0",
+			download: "docker.ghes.example.com/mona/repo/app:1.0.0",
+			upload:   "ghcr.io/mona/app:1.0.0",
+		},
+	})
+
+	viper.Set("GHMPKG_SOURCE_SUBDOMAIN_ISOLATION", "false")
+	checkUrls(t, "docker", []urlTest{
+		{
+			name: "without subdomain isolation", owner: "mona", repository: "repo", packageName: "app", version: "1.0.0", filename: "app:1.0.0",
+			download: "ghes.example.com/mona/repo/app:1.0.0",
+			upload:   "ghcr.io/mona/app:1.0.0",
+		},
+	})
+	viper.Set("GHMPKG_SOURCE_SUBDOMAIN_ISOLATION", "")
+
+	provider, err := providers.NewProvider(zap.NewNop(), "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Legacy versions have no container metadata, they are named after their tag
+	filenames, _, err := provider.FetchPackageFiles(zap.NewNop(), "mona", "repo", "docker", "app", "1.0.0", nil)
+	if err != nil || len(filenames) != 1 || filenames[0] != "app:1.0.0" {
+		t.Errorf("FetchPackageFiles = %v, %v, want [app:1.0.0]", filenames, err)
+	}
+}
+
+func TestContainerStorageLimit(t *testing.T) {
+	defer viper.Reset()
+
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"50GB", 50_000_000_000, false},
+		{"512mb", 512_000_000, false},
+		{"lots", 0, true},
+	}
+	for _, test := range tests {
+		viper.Set("GHMPKG_CONTAINER_STORAGE_LIMIT", test.value)
+		got, err := providers.ContainerStorageLimit()
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ContainerStorageLimit(%q) = %d, %v, want %d (error %v)", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Copy copies a manifest (and, for an index, every platform manifest) with all
+// referenced blobs from one registry to another. Blobs are streamed from the
+// source to the target without being stored. rewriteConfig, when set, may
+// change the config blob of a single image, e.g. to update its labels, which
+// gives the image a new digest; the config of artifacts is never rewritten. It
+// returns the descriptor pushed under the tag.
+func Copy(ctx context.Context, source *Client, sourceRepository, reference string, target *Client, targetRepository, tag string, rewriteConfig func([]byte) ([]byte, error)) (Descriptor, error) {
+	raw, desc, err := source.GetManifest(ctx, sourceRepository, reference)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	if IsIndex(desc.MediaType) || IsArtifact(raw) {
+		rewriteConfig = nil
+	}
+	raw, err = copyManifest(ctx, source, sourceRepository, target, targetRepository, raw, rewriteConfig)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	if err := target.PutManifest(ctx, targetRepository, tag, desc.MediaType, raw); err != nil {
+		return Descriptor{}, err
+	}
+	desc.Digest = Digest(raw)
+	desc.Size = int64(len(raw))
+	return desc, nil
+}
+
+// copyManifest copies the children and blobs of a manifest and returns the
+// manifest to push, rewritten when its config changed
+func copyManifest(ctx context.Context, source *Client, sourceRepository string, target *Client, targetRepository string, raw []byte, rewriteConfig func([]byte) ([]byte, error)) ([]byte, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	for _, child := range manifest.Manifests {
+		childRaw, childDesc, err := source.GetManifest(ctx, sourceRepository, child.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s manifest: %w", child.Platform, err)
+		}
+		if _, err := copyManifest(ctx, source, sourceRepository, target, targetRepository, childRaw, nil); err != nil {
+			return nil, err
+		}
+		mediaType := child.MediaType
+		if mediaType == "" {
+			mediaType = childDesc.MediaType
+		}
+		if err := target.PutManifest(ctx, targetRepository, child.Digest, mediaType, childRaw); err != nil {
+			return nil, err
+		}
+	}
+
+	if rewriteConfig != nil && manifest.Config != nil {
+		rewritten, err := rewriteManifestConfig(ctx, source, sourceRepository, target, targetRepository, &manifest, rewriteConfig)
+		if err != nil {
+			return nil, err
+		}
+		if rewritten {
+			if raw, err = json.Marshal(manifest); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, blob := range blobs(manifest) {
+		if err := copyBlob(ctx, source, sourceRepository, target, targetRepository, blob); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// rewriteManifestConfig uploads the rewritten config of an image and points the
+// manifest at it, reporting whether the config changed
+func rewriteManifestConfig(ctx context.Context, source *Client, sourceRepository string, target *Client, targetRepository string, manifest *Manifest, rewriteConfig func([]byte) ([]byte, error)) (bool, error) {
+	content, err := source.GetBlob(ctx, sourceRepository, manifest.Config.Digest)
+	if err != nil {
+		return false, err
+	}
+	config, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		return false, err
+	}
+	rewritten, err := rewriteConfig(config)
+	if err != nil {
+		return false, fmt.Errorf("failed to rewrite image config: %w", err)
+	}
+	if bytes.Equal(config, rewritten) {
+		return false, nil
+	}
+
+	digest := Digest(rewritten)
+	if err := target.PutBlob(ctx, targetRepository, digest, int64(len(rewritten)), func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(rewritten)), nil
+	}); err != nil {
+		return false, err
+	}
+	manifest.Config.Digest = digest
+	manifest.Config.Size = int64(len(rewritten))
+	return true, nil
+}
+
+// copyBlob streams a blob the target does not have yet from the source
+func copyBlob(ctx context.Context, source *Client, sourceRepository string, target *Client, targetRepository string, blob Descriptor) error {
+	exists, err := target.BlobExists(ctx, targetRepository, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return target.PutBlob(ctx, targetRepository, blob.Digest, blob.Size, func() (io.ReadCloser, error) {
+		return source.GetBlob(ctx, sourceRepository, blob.Digest)
+	})
+}
@@ -0,0 +1,218 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// Layout is an OCI image layout directory holding a copied image (or index)
+// and every manifest and blob it references, byte for byte
+type Layout struct {
+	Dir string
+}
+
+type layoutIndex struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+func (l Layout) blobPath(digest string) string {
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	return filepath.Join(l.Dir, "blobs", algorithm, encoded)
+}
+
+// HasBlob reports whether the layout already holds the blob
+func (l Layout) HasBlob(digest string) bool {
+	_, err := os.Stat(l.blobPath(digest))
+	return err == nil
+}
+
+// WriteBlob stores content under its digest, failing when the content does not match it
+func (l Layout) WriteBlob(digest string, content io.Reader) error {
+	blobPath := l.blobPath(digest)
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		return err
+	}
+	tmpPath := blobPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), content); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if actual := "sha256:" + hex.EncodeToString(hash.Sum(nil)); actual != digest {
+		os.Remove(tmpPath)
+		return fmt.Errorf("digest mismatch for blob %s: got %s", digest, actual)
+	}
+	return os.Rename(tmpPath, blobPath)
+}
+
+// ReadBlob returns the content of a blob
+func (l Layout) ReadBlob(digest string) ([]byte, error) {
+	return os.ReadFile(l.blobPath(digest))
+}
+
+// OpenBlob opens a blob for streaming
+func (l Layout) OpenBlob(digest string) (io.ReadCloser, error) {
+	return os.Open(l.blobPath(digest))
+}
+
+// WriteIndex records the top level descriptor of the layout and the tag it was copied from
+func (l Layout) WriteIndex(desc Descriptor, tag string) error {
+	desc.Annotations = map[string]string{refNameAnnotation: tag}
+	return l.writeIndex(layoutIndex{SchemaVersion: 2, Manifests: []Descriptor{desc}})
+}
+
+func (l Layout) writeIndex(index layoutIndex) error {
+	if err := os.WriteFile(filepath.Join(l.Dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		return err
+	}
+	content, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(l.Dir, "index.json"), content, 0644)
+}
+
+// ReadIndex returns the top level descriptor of the layout
+func (l Layout) ReadIndex() (Descriptor, error) {
+	content, err := os.ReadFile(filepath.Join(l.Dir, "index.json"))
+	if err != nil {
+		return Descriptor{}, err
+	}
+	var index layoutIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return Descriptor{}, err
+	}
+	if len(index.Manifests) == 0 {
+		return Descriptor{}, fmt.Errorf("no manifest found in %s", l.Dir)
+	}
+	return index.Manifests[0], nil
+}
+
+// Pull copies a manifest (and, for an index, every platform manifest) with all
+// referenced blobs from the registry into the layout
+func Pull(ctx context.Context, client *Client, repository, reference string, layout Layout) (Descriptor, error) {
+	raw, desc, err := client.GetManifest(ctx, repository, reference)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	if err := pullManifest(ctx, client, repository, raw, layout); err != nil {
+		return Descriptor{}, err
+	}
+	if err := layout.WriteIndex(desc, reference); err != nil {
+		return Descriptor{}, err
+	}
+	return desc, nil
+}
+
+func pullManifest(ctx context.Context, client *Client, repository string, raw []byte, layout Layout) error {
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	for _, child := range manifest.Manifests {
+		childRaw, _, err := client.GetManifest(ctx, repository, child.Digest)
+		if err != nil {
+			return fmt.Errorf("failed to get %s manifest: %w", child.Platform, err)
+		}
+		if err := pullManifest(ctx, client, repository, childRaw, layout); err != nil {
+			return err
+		}
+	}
+
+	for _, blob := range blobs(manifest) {
+		if layout.HasBlob(blob.Digest) {
+			continue
+		}
+		content, err := client.GetBlob(ctx, repository, blob.Digest)
+		if err != nil {
+			return err
+		}
+		err = layout.WriteBlob(blob.Digest, content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return layout.WriteBlob(Digest(raw), bytes.NewReader(raw))
+}
+
+// Push uploads the layout to the registry: blobs first, then platform manifests
+// by digest and finally the top level manifest under the tag
+func Push(ctx context.Context, client *Client, repository, tag string, layout Layout) error {
+	desc, err := layout.ReadIndex()
+	if err != nil {
+		return err
+	}
+	return pushManifest(ctx, client, repository, tag, desc, layout)
+}
+
+func pushManifest(ctx context.Context, client *Client, repository, reference string, desc Descriptor, layout Layout) error {
+	raw, err := layout.ReadBlob(desc.Digest)
+	if err != nil {
+		return err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("failed to parse manifest %s: %w", desc.Digest, err)
+	}
+
+	for _, child := range manifest.Manifests {
+		if err := pushManifest(ctx, client, repository, child.Digest, child, layout); err != nil {
+			return err
+		}
+	}
+
+	for _, blob := range blobs(manifest) {
+		exists, err := client.BlobExists(ctx, repository, blob.Digest)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		digest := blob.Digest
+		if err := client.PutBlob(ctx, repository, digest, blob.Size, func() (io.ReadCloser, error) {
+			return layout.OpenBlob(digest)
+		}); err != nil {
+			return err
+		}
+	}
+
+	mediaType := desc.MediaType
+	if mediaType == "" {
+		mediaType = manifest.MediaType
+	}
+	return client.PutManifest(ctx, repository, reference, mediaType, raw)
+}
+
+// blobs lists the config and layer blobs of an image manifest
+func blobs(manifest Manifest) []Descriptor {
+	var result []Descriptor
+	if manifest.Config != nil {
+		result = append(result, *manifest.Config)
+	}
+	return append(result, manifest.Layers...)
+}
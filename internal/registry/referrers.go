@@ -0,0 +1,178 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// COSIGN_TAG_SUFFIXES are the tags cosign stores the signatures, attestations
+// and SBOMs of an image under, sha256-<digest>.<suffix>, on registries without
+// the referrers API such as ghcr.io
+var COSIGN_TAG_SUFFIXES = []string{"sig", "att", "sbom"}
+
+// subjectAnnotation records, in the index of a referrers layout, the digest of
+// the image the artifacts are attached to
+const subjectAnnotation = "io.github.mona-actions.gh-migrate-packages.subject"
+
+// CosignTags returns the tags cosign attaches artifacts to an image digest under
+func CosignTags(digest string) []string {
+	var tags []string
+	for _, suffix := range COSIGN_TAG_SUFFIXES {
+		tags = append(tags, strings.Replace(digest, ":", "-", 1)+"."+suffix)
+	}
+	return tags
+}
+
+// referrersTag is the tag of the fallback index listing the referrers of a
+// digest on registries without the referrers API
+func referrersTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1)
+}
+
+// Tag returns the tag a referrer is stored under, empty for one only
+// referenced by digest
+func (d Descriptor) Tag() string {
+	return d.Annotations[refNameAnnotation]
+}
+
+// listReferrers returns the manifests whose subject is the digest, from the
+// referrers API or, when the registry does not have it, the fallback tag
+func (c *Client) listReferrers(ctx context.Context, repository, digest string) ([]Descriptor, error) {
+	header := http.Header{"Accept": []string{MediaTypeOCIIndex}}
+	resp, err := c.do(ctx, repository, http.MethodGet, c.url(fmt.Sprintf("%s/referrers/%s", repository, digest)), header, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var raw []byte
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if raw, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	case http.StatusNotFound:
+		var found bool
+		if raw, _, found, err = c.FindManifest(ctx, repository, referrersTag(digest)); err != nil || !found {
+			return nil, err
+		}
+	default:
+		return nil, statusError(resp, fmt.Sprintf("list referrers of %s@%s", repository, digest))
+	}
+	var index Manifest
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse referrers of %s@%s: %w", repository, digest, err)
+	}
+	return index.Manifests, nil
+}
+
+// Referrers discovers the artifacts attached to an image: cosign signatures,
+// attestations and SBOMs under their tags, and the manifests the referrers API
+// lists with the image as subject. Referrers stored under a tag are annotated
+// with it.
+func Referrers(ctx context.Context, client *Client, repository, digest string) ([]Descriptor, error) {
+	var referrers []Descriptor
+	seen := make(map[string]bool)
+	for _, tag := range CosignTags(digest) {
+		_, desc, found, err := client.FindManifest(ctx, repository, tag)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			desc.Annotations = map[string]string{refNameAnnotation: tag}
+			referrers = append(referrers, desc)
+			seen[desc.Digest] = true
+		}
+	}
+
+	listed, err := client.listReferrers(ctx, repository, digest)
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range listed {
+		if seen[desc.Digest] {
+			continue
+		}
+		seen[desc.Digest] = true
+		referrers = append(referrers, Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size, ArtifactType: desc.ArtifactType})
+	}
+	return referrers, nil
+}
+
+// reference is the tag of a referrer, or its digest
+func reference(desc Descriptor) string {
+	if tag := desc.Tag(); tag != "" {
+		return tag
+	}
+	return desc.Digest
+}
+
+// CopyReferrers copies the artifacts attached to an image digest, with their
+// blobs, from one registry to another. They keep their digest and tag, so they
+// stay attached to the image when it kept its digest on the target. It returns
+// the number of artifacts copied.
+func CopyReferrers(ctx context.Context, source *Client, sourceRepository string, target *Client, targetRepository, digest string) (int, error) {
+	referrers, err := Referrers(ctx, source, sourceRepository, digest)
+	if err != nil {
+		return 0, err
+	}
+	for _, desc := range referrers {
+		if _, err := Copy(ctx, source, sourceRepository, desc.Digest, target, targetRepository, reference(desc), nil); err != nil {
+			return 0, fmt.Errorf("failed to copy referrer %s: %w", reference(desc), err)
+		}
+	}
+	return len(referrers), nil
+}
+
+// PullReferrers copies the artifacts attached to an image digest into a
+// layout, every one of them listed in its index. It returns the number of
+// artifacts pulled.
+func PullReferrers(ctx context.Context, client *Client, repository, digest string, layout Layout) (int, error) {
+	referrers, err := Referrers(ctx, client, repository, digest)
+	if err != nil {
+		return 0, err
+	}
+	for _, desc := range referrers {
+		raw, _, err := client.GetManifest(ctx, repository, desc.Digest)
+		if err != nil {
+			return 0, err
+		}
+		if err := pullManifest(ctx, client, repository, raw, layout); err != nil {
+			return 0, fmt.Errorf("failed to pull referrer %s: %w", reference(desc), err)
+		}
+	}
+	if err := os.MkdirAll(layout.Dir, 0755); err != nil {
+		return 0, err
+	}
+	if referrers == nil {
+		referrers = []Descriptor{}
+	}
+	index := layoutIndex{SchemaVersion: 2, Manifests: referrers, Annotations: map[string]string{subjectAnnotation: digest}}
+	return len(referrers), layout.writeIndex(index)
+}
+
+// PushReferrers uploads the artifacts of a layout written by PullReferrers. It
+// returns the digest of the image they are attached to on the source and the
+// number of artifacts pushed.
+func PushReferrers(ctx context.Context, client *Client, repository string, layout Layout) (string, int, error) {
+	content, err := os.ReadFile(filepath.Join(layout.Dir, "index.json"))
+	if err != nil {
+		return "", 0, err
+	}
+	var index layoutIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return "", 0, err
+	}
+	for _, desc := range index.Manifests {
+		if err := pushManifest(ctx, client, repository, reference(desc), desc, layout); err != nil {
+			return "", 0, fmt.Errorf("failed to push referrer %s: %w", reference(desc), err)
+		}
+	}
+	return index.Annotations[subjectAnnotation], len(index.Manifests), nil
+}
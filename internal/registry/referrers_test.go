@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry serves manifests and blobs from memory. Only the repositories in
+// referrersApi answer the referrers API.
+type fakeRegistry struct {
+	mu           sync.Mutex
+	manifests    map[string][]byte
+	blobs        map[string][]byte
+	referrersApi map[string]bool
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case path == "":
+		w.WriteHeader(http.StatusOK)
+	case strings.Contains(path, "/manifests/"):
+		if r.Method == http.MethodPut {
+			raw, _ := io.ReadAll(r.Body)
+			repository, _, _ := strings.Cut(path, "/manifests/")
+			f.manifests[path] = raw
+			f.manifests[repository+"/manifests/"+Digest(raw)] = raw
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		raw, ok := f.manifests[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var manifest Manifest
+		json.Unmarshal(raw, &manifest)
+		w.Header().Set("Content-Type", manifest.MediaType)
+		w.Write(raw)
+	case strings.Contains(path, "/referrers/"):
+		repository, digest, _ := strings.Cut(path, "/referrers/")
+		if !f.referrersApi[repository] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		index := Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIIndex, Manifests: []Descriptor{}}
+		for key, raw := range f.manifests {
+			var manifest Manifest
+			json.Unmarshal(raw, &manifest)
+			if strings.HasPrefix(key, repository+"/manifests/sha256:") && manifest.Subject != nil && manifest.Subject.Digest == digest {
+				index.Manifests = append(index.Manifests, Descriptor{MediaType: manifest.MediaType, Digest: Digest(raw), Size: int64(len(raw)), ArtifactType: manifest.ArtifactType})
+			}
+		}
+		json.NewEncoder(w).Encode(index)
+	case strings.HasSuffix(path, "/blobs/uploads/"):
+		w.Header().Set("Location", "/v2/"+path+"upload")
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasSuffix(path, "/blobs/uploads/upload"):
+		content, _ := io.ReadAll(r.Body)
+		repository, _, _ := strings.Cut(path, "/blobs/")
+		f.blobs[repository+"/blobs/"+r.URL.Query().Get("digest")] = content
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		content, ok := f.blobs[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// put stores a manifest with its blobs under a tag, or its digest when the tag is empty
+func (f *fakeRegistry) put(repository, tag string, manifest Manifest, blobs ...string) string {
+	for _, blob := range blobs {
+		f.blobs[repository+"/blobs/"+Digest([]byte(blob))] = []byte(blob)
+	}
+	raw, _ := json.Marshal(manifest)
+	digest := Digest(raw)
+	f.manifests[repository+"/manifests/"+digest] = raw
+	if tag != "" {
+		f.manifests[repository+"/manifests/"+tag] = raw
+	}
+	return digest
+}
+
+// blob describes content for a manifest
+func blob(mediaType, content string) *Descriptor {
+	return &Descriptor{MediaType: mediaType, Digest: Digest([]byte(content)), Size: int64(len(content))}
+}
+
+func TestReferrers(t *testing.T) {
+	fake := &fakeRegistry{manifests: map[string][]byte{}, blobs: map[string][]byte{}, referrersApi: map[string]bool{"source/app": true}}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	client := NewClient(strings.TrimPrefix(server.URL, "https://"), "", "")
+	client.httpClient = server.Client()
+	ctx := context.Background()
+
+	image := fake.put("source/app", "1.0", Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest, Config: blob("application/vnd.oci.image.config.v1+json", "{}"), Layers: []Descriptor{*blob("application/vnd.oci.image.layer.v1.tar", "layer")}}, "{}", "layer")
+	signature := fake.put("source/app", strings.Replace(image, ":", "-", 1)+".sig", Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest, Config: blob("application/vnd.oci.image.config.v1+json", "{}"), Layers: []Descriptor{*blob("application/vnd.dev.cosign.simplesigning.v1+json", "signature")}}, "{}", "signature")
+	attestation := fake.put("source/app", "", Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest, ArtifactType: "application/vnd.in-toto+json", Config: blob("application/vnd.oci.empty.v1+json", "{}"), Layers: []Descriptor{*blob("application/vnd.in-toto+json", "provenance")}, Subject: &Descriptor{MediaType: MediaTypeOCIManifest, Digest: image}}, "{}", "provenance")
+
+	referrers, err := Referrers(ctx, client, "source/app", image)
+	if err != nil {
+		t.Fatalf("Referrers returned an error: %v", err)
+	}
+	if len(referrers) != 2 || referrers[0].Digest != signature || referrers[0].Tag() == "" || referrers[1].Digest != attestation || referrers[1].Tag() != "" {
+		t.Fatalf("Referrers = %+v, want the tagged signature and the attestation", referrers)
+	}
+
+	// The target has no referrers API, the attestation is only pushed by digest
+	count, err := CopyReferrers(ctx, client, "source/app", client, "target/app", image)
+	if err != nil || count != 2 {
+		t.Fatalf("CopyReferrers = %d, %v, want 2", count, err)
+	}
+	for _, key := range []string{"target/app/manifests/" + referrers[0].Tag(), "target/app/manifests/" + attestation, "target/app/blobs/" + Digest([]byte("provenance"))} {
+		if _, ok := fake.manifests[key]; !ok && fake.blobs[key] == nil {
+			t.Errorf("CopyReferrers did not copy %s", key)
+		}
+	}
+
+	layout := Layout{Dir: t.TempDir() + "/app-1.0.referrers.oci"}
+	if count, err := PullReferrers(ctx, client, "source/app", image, layout); err != nil || count != 2 {
+		t.Fatalf("PullReferrers = %d, %v, want 2", count, err)
+	}
+	subject, count, err := PushReferrers(ctx, client, "copy/app", layout)
+	if err != nil || count != 2 || subject != image {
+		t.Fatalf("PushReferrers = %s, %d, %v, want 2 referrers of %s", subject, count, err, image)
+	}
+	if _, ok := fake.manifests["copy/app/manifests/"+referrers[0].Tag()]; !ok {
+		t.Error("PushReferrers did not push the signature under its tag")
+	}
+	if _, ok := fake.blobs["copy/app/blobs/"+Digest([]byte("signature"))]; !ok {
+		t.Error("PushReferrers did not push the signature blob")
+	}
+
+	// Images without artifacts have nothing to copy
+	untagged := fake.put("source/app", "2.0", Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest, Config: blob("application/vnd.oci.image.config.v1+json", "{}")}, "{}")
+	if count, err := CopyReferrers(ctx, client, "source/app", client, "target/app", untagged); err != nil || count != 0 {
+		t.Errorf("CopyReferrers of an image without artifacts = %d, %v", count, err)
+	}
+}
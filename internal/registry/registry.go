@@ -0,0 +1,446 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+// Package registry implements the parts of the OCI distribution API needed to
+// copy images between registries without going through a Docker daemon.
+
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// Config media types of the images the Docker daemon can pull and run
+const (
+	MediaTypeDockerConfig = "application/vnd.docker.container.image.v1+json"
+	MediaTypeOCIConfig    = "application/vnd.oci.image.config.v1+json"
+)
+
+var manifestMediaTypes = []string{
+	MediaTypeOCIIndex,
+	MediaTypeDockerManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeDockerManifest,
+}
+
+// Platform describes the platform an image manifest in an index is built for
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p *Platform) String() string {
+	if p == nil {
+		return ""
+	}
+	platform := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		platform += "/" + p.Variant
+	}
+	return platform
+}
+
+// Descriptor references content (a manifest or blob) by digest
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Platform     *Platform         `json:"platform,omitempty"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is the union of an image manifest and an image index
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        *Descriptor       `json:"config,omitempty"`
+	Layers        []Descriptor      `json:"layers,omitempty"`
+	Manifests     []Descriptor      `json:"manifests,omitempty"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// IsIndex reports whether a media type is a manifest list / image index
+func IsIndex(mediaType string) bool {
+	return mediaType == MediaTypeOCIIndex || mediaType == MediaTypeDockerManifestList
+}
+
+// IsArtifact reports whether a manifest is an OCI artifact, such as a Helm
+// chart, rather than an image the Docker daemon can pull: an image manifest
+// with an artifact type or a config that is not an image config
+func IsArtifact(raw []byte) bool {
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil || IsIndex(manifest.MediaType) || manifest.Config == nil {
+		return false
+	}
+	if manifest.ArtifactType != "" {
+		return true
+	}
+	return manifest.Config.MediaType != MediaTypeDockerConfig && manifest.Config.MediaType != MediaTypeOCIConfig
+}
+
+// Digest returns the sha256 digest of content
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Client talks to a single registry host with basic credentials, exchanging
+// them for bearer tokens when the registry asks for it
+type Client struct {
+	Host       string
+	username   string
+	password   string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	challenge *challenge
+	tokens    map[string]string
+}
+
+type challenge struct {
+	scheme  string
+	realm   string
+	service string
+}
+
+func NewClient(host, username, password string) *Client {
+	return &Client{
+		Host:       strings.TrimSuffix(host, "/"),
+		username:   username,
+		password:   password,
+		httpClient: utils.NewHTTPClient(),
+		tokens:     make(map[string]string),
+	}
+}
+
+func (c *Client) url(path string) string {
+	return fmt.Sprintf("https://%s/v2/%s", c.Host, strings.TrimPrefix(path, "/"))
+}
+
+func parseChallenge(header string) *challenge {
+	scheme, params, _ := strings.Cut(header, " ")
+	ch := &challenge{scheme: strings.ToLower(scheme)}
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(key) {
+		case "realm":
+			ch.realm = value
+		case "service":
+			ch.service = value
+		}
+	}
+	return ch
+}
+
+// authorization returns the Authorization header for a repository, fetching a token when needed
+func (c *Client) authorization(ctx context.Context, repository string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	scope := fmt.Sprintf("repository:%s:pull,push", repository)
+	if header, ok := c.tokens[scope]; ok {
+		return header, nil
+	}
+
+	if c.challenge == nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(""), nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to reach registry %s: %w", c.Host, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			c.challenge = parseChallenge(resp.Header.Get("WWW-Authenticate"))
+		} else {
+			c.challenge = &challenge{}
+		}
+	}
+
+	var header string
+	switch c.challenge.scheme {
+	case "basic":
+		header = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.username+":"+c.password))
+	case "bearer":
+		token, err := c.fetchToken(ctx, scope)
+		if err != nil {
+			return "", err
+		}
+		header = "Bearer " + token
+	}
+	c.tokens[scope] = header
+	return header, nil
+}
+
+func (c *Client) fetchToken(ctx context.Context, scope string) (string, error) {
+	tokenUrl, err := url.Parse(c.challenge.realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", c.challenge.realm, err)
+	}
+	query := tokenUrl.Query()
+	if c.challenge.service != "" {
+		query.Set("service", c.challenge.service)
+	}
+	query.Set("scope", scope)
+	tokenUrl.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenUrl.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch registry token for %s, status: %s", scope, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// do sends a request for a repository. The body is produced by a function so the
+// request can be replayed once with a fresh token when the cached one expired.
+func (c *Client) do(ctx context.Context, repository, method, target string, header http.Header, body func() (io.Reader, int64, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		authorization, err := c.authorization(ctx, repository)
+		if err != nil {
+			return nil, err
+		}
+
+		var reader io.Reader
+		var length int64 = -1
+		if body != nil {
+			if reader, length, err = body(); err != nil {
+				return nil, err
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, reader)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if length >= 0 {
+			req.ContentLength = length
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			resp.Body.Close()
+			c.mu.Lock()
+			c.tokens = make(map[string]string)
+			c.mu.Unlock()
+			continue
+		}
+		return resp, nil
+	}
+}
+
+func statusError(resp *http.Response, action string) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("failed to %s, status: %s, message: %s", action, resp.Status, strings.TrimSpace(string(message)))
+}
+
+// GetManifest fetches a manifest by tag or digest, returning its raw bytes and descriptor
+func (c *Client) GetManifest(ctx context.Context, repository, reference string) ([]byte, Descriptor, error) {
+	raw, desc, found, err := c.FindManifest(ctx, repository, reference)
+	if err == nil && !found {
+		err = fmt.Errorf("failed to get manifest %s:%s, status: 404 Not Found", repository, reference)
+	}
+	return raw, desc, err
+}
+
+// FindManifest fetches a manifest by tag or digest like GetManifest, reporting
+// false instead of failing when the repository does not have it
+func (c *Client) FindManifest(ctx context.Context, repository, reference string) ([]byte, Descriptor, bool, error) {
+	header := http.Header{"Accept": []string{strings.Join(manifestMediaTypes, ", ")}}
+	resp, err := c.do(ctx, repository, http.MethodGet, c.url(fmt.Sprintf("%s/manifests/%s", repository, reference)), header, nil)
+	if err != nil {
+		return nil, Descriptor{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, Descriptor{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, Descriptor{}, false, statusError(resp, fmt.Sprintf("get manifest %s:%s", repository, reference))
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Descriptor{}, false, err
+	}
+	mediaType := resp.Header.Get("Content-Type")
+	if mediaType == "" || mediaType == "application/json" {
+		var manifest Manifest
+		if err := json.Unmarshal(raw, &manifest); err == nil {
+			mediaType = manifest.MediaType
+		}
+	}
+	return raw, Descriptor{MediaType: mediaType, Digest: Digest(raw), Size: int64(len(raw))}, true, nil
+}
+
+// ImageSize returns the bytes of an image in the repository: its manifest,
+// config and layers, and for an index those of every manifest it lists
+func ImageSize(ctx context.Context, client *Client, repository, reference string) (int64, error) {
+	raw, _, err := client.GetManifest(ctx, repository, reference)
+	if err != nil {
+		return 0, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return 0, fmt.Errorf("invalid manifest %s:%s: %w", repository, reference, err)
+	}
+	size := int64(len(raw))
+	for _, blob := range blobs(manifest) {
+		size += blob.Size
+	}
+	for _, desc := range manifest.Manifests {
+		child, err := ImageSize(ctx, client, repository, desc.Digest)
+		if err != nil {
+			return 0, err
+		}
+		size += child
+	}
+	return size, nil
+}
+
+// PutManifest uploads a manifest under a tag or digest
+func (c *Client) PutManifest(ctx context.Context, repository, reference, mediaType string, raw []byte) error {
+	header := http.Header{"Content-Type": []string{mediaType}}
+	resp, err := c.do(ctx, repository, http.MethodPut, c.url(fmt.Sprintf("%s/manifests/%s", repository, reference)), header, func() (io.Reader, int64, error) {
+		return bytes.NewReader(raw), int64(len(raw)), nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return statusError(resp, fmt.Sprintf("put manifest %s:%s", repository, reference))
+	}
+	return nil
+}
+
+// BlobExists reports whether a blob is already present in the repository
+func (c *Client) BlobExists(ctx context.Context, repository, digest string) (bool, error) {
+	resp, err := c.do(ctx, repository, http.MethodHead, c.url(fmt.Sprintf("%s/blobs/%s", repository, digest)), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check blob %s, status: %s", digest, resp.Status)
+	}
+}
+
+// GetBlob streams a blob from the repository, the caller must close the reader
+func (c *Client) GetBlob(ctx context.Context, repository, digest string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, repository, http.MethodGet, c.url(fmt.Sprintf("%s/blobs/%s", repository, digest)), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(resp, fmt.Sprintf("get blob %s", digest))
+	}
+	return resp.Body, nil
+}
+
+// PutBlob uploads a blob in a single request. open is called for every attempt.
+func (c *Client) PutBlob(ctx context.Context, repository, digest string, size int64, open func() (io.ReadCloser, error)) error {
+	resp, err := c.do(ctx, repository, http.MethodPost, c.url(fmt.Sprintf("%s/blobs/uploads/", repository)), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to start blob upload %s, status: %s", digest, resp.Status)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location: %w", err)
+	}
+	base, _ := url.Parse(c.url(""))
+	uploadUrl := base.ResolveReference(location)
+	query := uploadUrl.Query()
+	query.Set("digest", digest)
+	uploadUrl.RawQuery = query.Encode()
+
+	var blob io.ReadCloser
+	defer func() {
+		if blob != nil {
+			blob.Close()
+		}
+	}()
+	header := http.Header{"Content-Type": []string{"application/octet-stream"}}
+	resp, err = c.do(ctx, repository, http.MethodPut, uploadUrl.String(), header, func() (io.Reader, int64, error) {
+		if blob != nil {
+			blob.Close()
+		}
+		blob, err = open()
+		return blob, size, err
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return statusError(resp, fmt.Sprintf("upload blob %s", digest))
+	}
+	return nil
+}
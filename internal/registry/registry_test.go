@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsArtifact(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+		want     bool
+	}{
+		{"docker image", Manifest{MediaType: MediaTypeDockerManifest, Config: blob(MediaTypeDockerConfig, "{}")}, false},
+		{"oci image", Manifest{MediaType: MediaTypeOCIManifest, Config: blob(MediaTypeOCIConfig, "{}")}, false},
+		{"helm chart", Manifest{MediaType: MediaTypeOCIManifest, Config: blob("application/vnd.cncf.helm.config.v1+json", "{}")}, true},
+		{"artifact with an empty config", Manifest{MediaType: MediaTypeOCIManifest, ArtifactType: "application/vnd.example+json", Config: blob(MediaTypeOCIConfig, "{}")}, true},
+		{"index", Manifest{MediaType: MediaTypeOCIIndex, Manifests: []Descriptor{*blob(MediaTypeOCIManifest, "{}")}}, false},
+	}
+	for _, test := range tests {
+		raw, _ := json.Marshal(test.manifest)
+		if got := IsArtifact(raw); got != test.want {
+			t.Errorf("%s: IsArtifact = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCopyArtifact(t *testing.T) {
+	fake := &fakeRegistry{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	client := NewClient(strings.TrimPrefix(server.URL, "https://"), "", "")
+	client.httpClient = server.Client()
+
+	chart := `{"name":"app","version":"1.0.0"}`
+	digest := fake.put("source/charts/app", "1.0.0", Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest, Config: blob("application/vnd.cncf.helm.config.v1+json", chart), Layers: []Descriptor{*blob("application/vnd.cncf.helm.chart.content.v1.tar+gzip", "chart")}}, chart, "chart")
+
+	rewritten := false
+	pushed, err := Copy(context.Background(), client, "source/charts/app", "1.0.0", client, "target/charts/app", "1.0.0", func(config []byte) ([]byte, error) {
+		rewritten = true
+		return []byte(`{}`), nil
+	})
+	if err != nil {
+		t.Fatalf("Copy returned an error: %v", err)
+	}
+	if rewritten || pushed.Digest != digest {
+		t.Errorf("Copy rewrote the chart config: digest %s, want %s", pushed.Digest, digest)
+	}
+	if string(fake.blobs["target/charts/app/blobs/"+Digest([]byte(chart))]) != chart {
+		t.Error("Copy did not copy the chart config")
+	}
+}
+
+func TestImageSize(t *testing.T) {
+	fake := &fakeRegistry{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	client := NewClient(strings.TrimPrefix(server.URL, "https://"), "", "")
+	client.httpClient = server.Client()
+
+	amd64 := Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest, Config: blob(MediaTypeOCIConfig, "{}"), Layers: []Descriptor{*blob("application/vnd.oci.image.layer.v1.tar", "amd64 layer")}}
+	arm64 := Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest, Config: blob(MediaTypeOCIConfig, "{}"), Layers: []Descriptor{*blob("application/vnd.oci.image.layer.v1.tar", "arm64 layer!")}}
+	amd64Digest := fake.put("source/app", "amd64", amd64)
+	arm64Digest := fake.put("source/app", "", arm64)
+	index := Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIIndex, Manifests: []Descriptor{{MediaType: MediaTypeOCIManifest, Digest: amd64Digest}, {MediaType: MediaTypeOCIManifest, Digest: arm64Digest}}}
+	fake.put("source/app", "1.0", index)
+
+	manifestSize := func(manifest Manifest) int64 {
+		raw, _ := json.Marshal(manifest)
+		return int64(len(raw))
+	}
+	single := manifestSize(amd64) + 2 + int64(len("amd64 layer"))
+	if size, err := ImageSize(context.Background(), client, "source/app", "amd64"); err != nil || size != single {
+		t.Errorf("ImageSize(amd64) = %d, %v, want %d", size, err, single)
+	}
+	want := manifestSize(index) + single + manifestSize(arm64) + 2 + int64(len("arm64 layer!"))
+	if size, err := ImageSize(context.Background(), client, "source/app", "1.0"); err != nil || size != want {
+		t.Errorf("ImageSize(1.0) = %d, %v, want %d", size, err, want)
+	}
+	if _, err := ImageSize(context.Background(), client, "source/app", "2.0"); err == nil {
+		t.Error("ImageSize of a missing tag succeeded")
+	}
+}
@@ -0,0 +1,195 @@
+package run
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Version, Commit and Date of the build, set at build time with
+// -ldflags "-X github.com/mona-actions/gh-migrate-packages/internal/run.Version=v1.2.3".
+// The module version, VCS revision and VCS time of the build info are used otherwise.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// BuildInfo describes the build of the tool
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Metadata identifies the run an artifact was written by, so a migration can be
+// audited and reproduced from its logs, reports and CSV files
+type Metadata struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	// Snapshot is the name of the frozen export the run labeled or operated on
+	Snapshot    string            `json:"snapshot,omitempty"`
+	CommandLine []string          `json:"command_line,omitempty"`
+	Config      map[string]string `json:"config,omitempty"`
+}
+
+var (
+	mu          sync.Mutex
+	id          string
+	commandLine []string
+	config      func() map[string]string
+)
+
+// ID is the identifier of the run, GHMPKG_RUN_ID when set so that several
+// invocations can share one, otherwise generated from the start time
+func ID() string {
+	mu.Lock()
+	defer mu.Unlock()
+	if id == "" {
+		if id = viper.GetString("GHMPKG_RUN_ID"); id == "" {
+			id = newID(time.Now())
+		}
+	}
+	return id
+}
+
+func newID(now time.Time) string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// SetCommandLine records the arguments the tool was started with, with the
+// values of secret flags already redacted
+func SetCommandLine(args []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	commandLine = append([]string{}, args...)
+}
+
+// SetConfig registers how the redacted configuration of the run is resolved.
+// It is resolved when an artifact is written, once the flags are bound.
+func SetConfig(resolve func() map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	config = resolve
+}
+
+// Current returns the metadata of the run
+func Current() *Metadata {
+	metadata := &Metadata{ID: ID(), Version: VersionString(), Snapshot: viper.GetString("GHMPKG_SNAPSHOT")}
+	mu.Lock()
+	metadata.CommandLine = append([]string{}, commandLine...)
+	resolve := config
+	mu.Unlock()
+	if resolve != nil {
+		metadata.Config = resolve()
+	}
+	return metadata
+}
+
+// Build returns the build metadata, from the ldflags variables first and the
+// build info embedded by the go command otherwise
+func Build() BuildInfo {
+	build := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	info, ok := debug.ReadBuildInfo()
+	if ok {
+		if build.Version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			build.Version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if build.Commit == "" {
+					build.Commit = setting.Value
+				}
+			case "vcs.time":
+				if build.Date == "" {
+					build.Date = setting.Value
+				}
+			case "vcs.modified":
+				build.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if build.Version == "" {
+		build.Version = "devel"
+	}
+	return build
+}
+
+// VersionString is the version of the tool followed by the commit it was built
+// from, when known
+func VersionString() string {
+	build := Build()
+	revision := build.Commit
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	// Pseudo-versions stamped by the go command already name the revision
+	if revision == "" || strings.Contains(build.Version, revision) {
+		return build.Version
+	}
+	if build.Modified {
+		revision += "-dirty"
+	}
+	return fmt.Sprintf("%s (%s)", build.Version, revision)
+}
+
+// SnapshotPrefix starts the CSV comment line naming the snapshot of a run
+const SnapshotPrefix = "snapshot: "
+
+// Header is the metadata as the comment lines written at the top of CSV files,
+// without the leading #
+func (m *Metadata) Header() []string {
+	lines := []string{
+		fmt.Sprintf("gh-migrate-packages %s", m.Version),
+		fmt.Sprintf("run: %s", m.ID),
+	}
+	if m.Snapshot != "" {
+		lines = append(lines, fmt.Sprintf("%s%s", SnapshotPrefix, m.Snapshot))
+	}
+	if len(m.CommandLine) > 0 {
+		lines = append(lines, fmt.Sprintf("command: %s", strings.Join(m.CommandLine, " ")))
+	}
+	if len(m.Config) > 0 {
+		lines = append(lines, fmt.Sprintf("config: %s", m.ConfigString()))
+	}
+	return lines
+}
+
+// ConfigString is the configuration as sorted KEY=value pairs
+func (m *Metadata) ConfigString() string {
+	keys := make([]string, 0, len(m.Config))
+	for key := range m.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		value := m.Config[key]
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"") {
+			value = strconv.Quote(value)
+		}
+		pairs[i] = key + "=" + value
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,50 @@
+package run_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/spf13/viper"
+)
+
+func TestID(t *testing.T) {
+	viper.Set("GHMPKG_RUN_ID", "cutover-1")
+	defer viper.Reset()
+
+	if id := run.ID(); id != "cutover-1" {
+		t.Errorf("ID() = %q, want cutover-1", id)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	metadata := &run.Metadata{
+		ID:          "cutover-1",
+		Version:     "v1.2.0",
+		Snapshot:    "wave-3-freeze",
+		CommandLine: []string{"gh-migrate-packages", "sync", "-t", "***"},
+		Config: map[string]string{
+			"GHMPKG_TARGET_TOKEN":        "***",
+			"GHMPKG_MAPPING_FILE":        "my mapping.yaml",
+			"GHMPKG_TARGET_ORGANIZATION": "acme",
+		},
+	}
+	want := []string{
+		"gh-migrate-packages v1.2.0",
+		"run: cutover-1",
+		"snapshot: wave-3-freeze",
+		"command: gh-migrate-packages sync -t ***",
+		`config: GHMPKG_MAPPING_FILE="my mapping.yaml" GHMPKG_TARGET_ORGANIZATION=acme GHMPKG_TARGET_TOKEN=***`,
+	}
+	if got := metadata.Header(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Header() = %q, want %q", got, want)
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	// A version, followed by the revision when the build info has one
+	if version := run.VersionString(); !regexp.MustCompile(`^\S+( \([0-9a-f]+(-dirty)?\))?$`).MatchString(version) {
+		t.Errorf("VersionString() = %q", version)
+	}
+}
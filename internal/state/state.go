@@ -0,0 +1,288 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+const FileName = "state.json"
+
+// JournalFileName holds the files completed and the images recreated since the
+// state was last saved, one JSON entry per line
+const JournalFileName = "state.jsonl"
+
+// Store records which files each phase (pull, sync) has completed so an
+// interrupted run can resume where it stopped. Completed files and recreated
+// images are appended to a journal, Save folds the journal into the JSON state
+// file in the migration directory. It is safe for concurrent use.
+type Store struct {
+	mu          sync.Mutex
+	path        string
+	journalPath string
+	journal     *os.File
+	dirty       bool
+	Completed   map[string]map[string]string `json:"completed"`
+	Containers  map[string]string            `json:"containers,omitempty"`
+	// Snapshots names, per phase, the snapshot whose export the phase completed
+	// files of
+	Snapshots map[string]string `json:"snapshots,omitempty"`
+}
+
+// journalEntry is a line of the journal: a file completed by a phase or an
+// image recreated on the target
+type journalEntry struct {
+	Phase       string `json:"phase,omitempty"`
+	Key         string `json:"key,omitempty"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	Image       string `json:"image,omitempty"`
+	TargetRef   string `json:"target_ref,omitempty"`
+}
+
+// stores holds one Store per state file so every user in the process shares it
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*Store)
+)
+
+// Key identifies a single file of a package version
+func Key(owner, repository, packageType, packageName, version, filename string) string {
+	return strings.Join([]string{owner, repository, packageType, packageName, version, filename}, "|")
+}
+
+// PackageKey identifies a package, it prefixes every Key of the package's files
+func PackageKey(owner, repository, packageType, packageName string) string {
+	return strings.Join([]string{owner, repository, packageType, packageName}, "|")
+}
+
+// Load reads the state file in the migration directory and replays its journal,
+// starting empty when neither exists yet. Subsequent calls for the same directory return the same Store.
+func Load(migrationPath string) (*Store, error) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	path := filepath.Join(migrationPath, FileName)
+	if store, ok := stores[path]; ok {
+		return store, nil
+	}
+
+	store, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	stores[path] = store
+	return store, nil
+}
+
+func load(path string) (*Store, error) {
+	store := &Store{
+		path:        path,
+		journalPath: filepath.Join(filepath.Dir(path), JournalFileName),
+		Completed:   make(map[string]map[string]string),
+		Containers:  make(map[string]string),
+		Snapshots:   make(map[string]string),
+	}
+
+	content, err := os.ReadFile(store.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, store); err != nil {
+			return nil, fmt.Errorf("failed to parse state file %s: %w", store.path, err)
+		}
+	}
+	if store.Completed == nil {
+		store.Completed = make(map[string]map[string]string)
+	}
+	if store.Containers == nil {
+		store.Containers = make(map[string]string)
+	}
+	if store.Snapshots == nil {
+		store.Snapshots = make(map[string]string)
+	}
+	if err := store.replay(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// replay applies the entries of the journal left by a run that did not save the
+// state. A truncated last line, from a write interrupted mid-way, is ignored.
+func (s *Store) replay() error {
+	content, err := os.ReadFile(s.journalPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state journal: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		s.apply(entry)
+		s.dirty = true
+	}
+	return scanner.Err()
+}
+
+// apply records a journal entry in the state, the caller holds the lock
+func (s *Store) apply(entry journalEntry) {
+	if entry.Phase != "" && entry.Key != "" {
+		if s.Completed[entry.Phase] == nil {
+			s.Completed[entry.Phase] = make(map[string]string)
+		}
+		s.Completed[entry.Phase][entry.Key] = entry.CompletedAt
+	}
+	if entry.Image != "" {
+		s.Containers[entry.Image] = entry.TargetRef
+	}
+}
+
+// appendJournal applies entries to the state and writes them to the journal,
+// the caller holds the lock
+func (s *Store) appendJournal(entries ...journalEntry) error {
+	if s.journal == nil {
+		if err := utils.RefuseWrite(s.journalPath); err != nil {
+			return err
+		}
+		if err := utils.EnsureDirExists(s.journalPath); err != nil {
+			return fmt.Errorf("failed to create state directory: %w", err)
+		}
+		journal, err := os.OpenFile(s.journalPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open state journal: %w", err)
+		}
+		s.journal = journal
+	}
+	var lines []byte
+	for _, entry := range entries {
+		s.apply(entry)
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal state entry: %w", err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	// One write per call keeps the lines of processes sharing the journal whole
+	if _, err := s.journal.Write(lines); err != nil {
+		return fmt.Errorf("failed to write state journal: %w", err)
+	}
+	s.dirty = true
+	return nil
+}
+
+// Reset forgets everything recorded for a phase and persists the state
+func (s *Store) Reset(phase string) error {
+	s.mu.Lock()
+	delete(s.Completed, phase)
+	s.dirty = true
+	s.mu.Unlock()
+	return s.Save()
+}
+
+// IsCompleted reports whether the phase already completed the given key
+func (s *Store) IsCompleted(phase, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.Completed[phase][key]
+	return ok
+}
+
+// HasPackage reports whether the phase completed any file of the package
+func (s *Store) HasPackage(phase, packageKey string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.Completed[phase] {
+		if strings.HasPrefix(key, packageKey+"|") {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkCompleted records the keys as completed for the phase in the journal
+func (s *Store) MarkCompleted(phase string, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Format(time.RFC3339)
+	entries := make([]journalEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, journalEntry{Phase: phase, Key: key, CompletedAt: now})
+	}
+	return s.appendJournal(entries...)
+}
+
+// ContainerRef returns the target reference an image was already recreated as
+func (s *Store) ContainerRef(imageKey string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref, ok := s.Containers[imageKey]
+	return ref, ok
+}
+
+// SetContainerRef records the target reference an image was recreated as in the journal
+func (s *Store) SetContainerRef(imageKey, targetRef string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appendJournal(journalEntry{Image: imageKey, TargetRef: targetRef})
+}
+
+// Snapshot returns the snapshot the phase operated on, empty when none was set
+func (s *Store) Snapshot(phase string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Snapshots[phase]
+}
+
+// SetSnapshot records the snapshot the phase operates on and persists the state
+func (s *Store) SetSnapshot(phase, snapshot string) error {
+	s.mu.Lock()
+	if snapshot == "" {
+		delete(s.Snapshots, phase)
+	} else {
+		s.Snapshots[phase] = snapshot
+	}
+	s.dirty = true
+	s.mu.Unlock()
+	return s.Save()
+}
+
+// Save writes the state atomically and removes the journal it now includes. It
+// does nothing when nothing was recorded since the last save.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	if err := utils.WriteFileAtomic(s.path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	if err := os.Remove(s.journalPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state journal: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
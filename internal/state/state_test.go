@@ -0,0 +1,143 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := Key("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz")
+	if err := store.MarkCompleted("pull", client); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetContainerRef("ghcr.io/mona/app@sha256:abc", "ghcr.io/octo/app@sha256:def"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A resumed run reads the state file again
+	reloaded, err := load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.IsCompleted("pull", client) {
+		t.Error("the completed file was not reloaded")
+	}
+	if reloaded.IsCompleted("sync", client) {
+		t.Error("a file pulled is completed for sync")
+	}
+	if !reloaded.HasPackage("pull", PackageKey("mona", "app", "npm", "client")) {
+		t.Error("HasPackage is false for a package with a completed file")
+	}
+	// A package whose name starts like another one has no completed file
+	if reloaded.HasPackage("pull", PackageKey("mona", "app", "npm", "cli")) {
+		t.Error("HasPackage matched a package name prefix")
+	}
+	if ref, ok := reloaded.ContainerRef("ghcr.io/mona/app@sha256:abc"); !ok || ref != "ghcr.io/octo/app@sha256:def" {
+		t.Errorf("ContainerRef = %s, %t", ref, ok)
+	}
+
+	if err := reloaded.Reset("pull"); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.IsCompleted("pull", client) {
+		t.Error("Reset kept the completed file")
+	}
+}
+
+func TestStateInterruptedWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	store, err := load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := Key("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz")
+	if err := store.MarkCompleted("sync", client); err != nil {
+		t.Fatal(err)
+	}
+
+	// A write interrupted before the rename leaves a truncated temporary file
+	// next to the state file, which keeps the last complete state
+	if err := os.WriteFile(path+".tmp", []byte(`{"completed": {"sync": {"mona|`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := load(path)
+	if err != nil {
+		t.Fatalf("load after an interrupted write: %v", err)
+	}
+	if !reloaded.IsCompleted("sync", client) {
+		t.Error("the last complete state was lost")
+	}
+	// Saves write their own temporary files, processes sharing the migration
+	// directory never write to each other's
+	if err := reloaded.MarkCompleted("sync", Key("mona", "app", "npm", "client", "1.1.0", "client-1.1.0.tgz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if leftover, _ := filepath.Glob(path + ".*.tmp"); len(leftover) > 0 {
+		t.Errorf("temporary files were left behind: %v", leftover)
+	}
+
+	// A truncated state file is reported instead of starting over silently
+	if err := os.WriteFile(path, []byte(`{"completed": {"sync"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := load(path); err == nil {
+		t.Error("load accepted a truncated state file")
+	}
+}
+
+func TestStateJournal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	store, err := load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := Key("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz")
+	if err := store.MarkCompleted("sync", client); err != nil {
+		t.Fatal(err)
+	}
+
+	// Completed files are appended to the journal, the state file is only
+	// written when the run saves it
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("MarkCompleted wrote the state file: %v", err)
+	}
+	// A run interrupted mid-way through a journal line keeps the whole ones
+	journal, err := os.OpenFile(filepath.Join(dir, JournalFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.WriteString(`{"phase":"sync","key":"mona|`)
+	journal.Close()
+	reloaded, err := load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.IsCompleted("sync", client) {
+		t.Error("the journaled file was not replayed")
+	}
+
+	if err := reloaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, JournalFileName)); !os.IsNotExist(err) {
+		t.Errorf("Save left the journal behind: %v", err)
+	}
+	saved, err := load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.IsCompleted("sync", client) {
+		t.Error("the saved state lost the completed file")
+	}
+}
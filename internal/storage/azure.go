@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// azureVersion is the Blob service API version used, the first one accepting
+// single request uploads of up to 5000 MiB
+const azureVersion = "2019-12-12"
+
+// azureStorage stores the packages in an Azure Blob Storage container. It
+// authenticates with a SAS token, AZURE_STORAGE_SAS_TOKEN, or an Entra ID
+// access token, AZURE_STORAGE_TOKEN. AZURE_STORAGE_ENDPOINT replaces
+// https://<account>.blob.core.windows.net, e.g. for Azurite.
+type azureStorage struct {
+	account   string
+	container string
+	prefix    string
+	endpoint  string
+	sas       url.Values
+	token     string
+}
+
+func newAzure(account, container, prefix string) (*azureStorage, error) {
+	s := &azureStorage{
+		account:   account,
+		container: container,
+		prefix:    prefix,
+		endpoint:  fmt.Sprintf("https://%s.blob.core.windows.net", account),
+		token:     os.Getenv("AZURE_STORAGE_TOKEN"),
+	}
+	if endpoint := os.Getenv("AZURE_STORAGE_ENDPOINT"); endpoint != "" {
+		s.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+	if sas := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); sas != "" {
+		values, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid AZURE_STORAGE_SAS_TOKEN: %w", err)
+		}
+		s.sas = values
+	}
+	if s.sas == nil && s.token == "" {
+		return nil, fmt.Errorf("azure storage: AZURE_STORAGE_SAS_TOKEN or AZURE_STORAGE_TOKEN is not set")
+	}
+	return s, nil
+}
+
+func (s *azureStorage) String() string {
+	return "azblob://" + objectKey(s.account+"/"+s.container, s.prefix)
+}
+
+func (s *azureStorage) request(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	target := s.endpoint + "/" + s.container
+	if key != "" {
+		target += "/" + escapePath(objectKey(s.prefix, key))
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+	for name, values := range s.sas {
+		query[name] = values
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return req, nil
+}
+
+func (s *azureStorage) Put(ctx context.Context, key string, content io.Reader, size int64) error {
+	// A zero ContentLength with a body would be sent chunked
+	if size == 0 {
+		content = http.NoBody
+	}
+	req, err := s.request(ctx, http.MethodPut, key, nil, content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	resp, err := do(req, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *azureStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *azureStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {objectKey(s.prefix, prefix)}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		req, err := s.request(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := do(req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Blobs []struct {
+				Name string `xml:"Name"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the container listing: %w", err)
+		}
+		for _, blob := range result.Blobs {
+			keys = append(keys, relativeKey(s.prefix, blob.Name))
+		}
+		if result.NextMarker == "" {
+			return keys, nil
+		}
+		marker = result.NextMarker
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcsMetadataTokenUrl hands out access tokens of the service account of a Google Cloud VM or pod
+const gcsMetadataTokenUrl = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcsStorage stores the packages in a Google Cloud Storage bucket. It uses the
+// access token in GOOGLE_OAUTH_ACCESS_TOKEN, or asks the metadata server of the
+// machine for one. STORAGE_EMULATOR_HOST replaces https://storage.googleapis.com.
+type gcsStorage struct {
+	bucket   string
+	prefix   string
+	endpoint string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newGCS(bucket, prefix string) (*gcsStorage, error) {
+	s := &gcsStorage{bucket: bucket, prefix: prefix, endpoint: "https://storage.googleapis.com"}
+	if emulator := os.Getenv("STORAGE_EMULATOR_HOST"); emulator != "" {
+		if !strings.Contains(emulator, "://") {
+			emulator = "http://" + emulator
+		}
+		s.endpoint = strings.TrimSuffix(emulator, "/")
+	}
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		s.token = token
+	}
+	return s, nil
+}
+
+func (s *gcsStorage) String() string {
+	return "gs://" + objectKey(s.bucket, s.prefix)
+}
+
+// accessToken returns the configured token, or a token of the metadata server
+// that is refreshed a minute before it expires
+func (s *gcsStorage) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && (s.expires.IsZero() || time.Now().Before(s.expires)) {
+		return s.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataTokenUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := do(req, http.StatusOK)
+	if err != nil {
+		return "", fmt.Errorf("gcs storage: GOOGLE_OAUTH_ACCESS_TOKEN is not set and the metadata server has no token: %w", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse the metadata server token: %w", err)
+	}
+	s.token = token.AccessToken
+	s.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+func (s *gcsStorage) request(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+func (s *gcsStorage) Put(ctx context.Context, key string, content io.Reader, size int64) error {
+	query := url.Values{"uploadType": {"media"}, "name": {objectKey(s.prefix, key)}}
+	// A zero ContentLength with a body would be sent chunked
+	if size == 0 {
+		content = http.NoBody
+	}
+	req, err := s.request(ctx, http.MethodPost, fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(s.bucket), query.Encode()), content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := do(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *gcsStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(objectKey(s.prefix, key))), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *gcsStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {objectKey(s.prefix, prefix)}, "fields": {"items(name),nextPageToken"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		req, err := s.request(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(s.bucket), query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := do(req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the bucket listing: %w", err)
+		}
+		for _, item := range result.Items {
+			keys = append(keys, relativeKey(s.prefix, item.Name))
+		}
+		if result.NextPageToken == "" {
+			return keys, nil
+		}
+		pageToken = result.NextPageToken
+	}
+}
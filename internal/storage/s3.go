@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/credentials"
+)
+
+// s3Storage stores the packages in an S3 bucket, or an S3 compatible store when
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL is set. Credentials and region come
+// from the standard AWS_* environment variables.
+type s3Storage struct {
+	bucket      string
+	prefix      string
+	region      string
+	credentials credentials.AWSCredentials
+	// baseUrl addresses the bucket, virtual hosted on AWS and by path on custom endpoints
+	baseUrl string
+}
+
+func newS3(bucket, prefix string) (*s3Storage, error) {
+	awsCredentials, region, err := credentials.AWSFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("s3 storage: %w", err)
+	}
+	baseUrl := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint != "" {
+		baseUrl = strings.TrimSuffix(endpoint, "/") + "/" + bucket
+	}
+	return &s3Storage{bucket: bucket, prefix: prefix, region: region, credentials: awsCredentials, baseUrl: baseUrl}, nil
+}
+
+func (s *s3Storage) String() string {
+	return "s3://" + objectKey(s.bucket, s.prefix)
+}
+
+func (s *s3Storage) request(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	target := s.baseUrl
+	if key != "" {
+		target += "/" + escapePath(objectKey(s.prefix, key))
+	}
+	if len(query) > 0 {
+		target += "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	// Package files are streamed, their digests are checked by pull and sync
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	return req, nil
+}
+
+func (s *s3Storage) sign(req *http.Request) {
+	credentials.SignV4(req, "UNSIGNED-PAYLOAD", s.credentials, s.region, "s3", time.Now())
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, content io.Reader, size int64) error {
+	// A zero ContentLength with a body would be sent chunked
+	if size == 0 {
+		content = http.NoBody
+	}
+	req, err := s.request(ctx, http.MethodPut, key, nil, content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	s.sign(req)
+	resp, err := do(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req)
+	resp, err := do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	continuation := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {objectKey(s.prefix, prefix)}}
+		if continuation != "" {
+			query.Set("continuation-token", continuation)
+		}
+		req, err := s.request(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.sign(req)
+		resp, err := do(req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the bucket listing: %w", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, relativeKey(s.prefix, object.Key))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		continuation = result.NextContinuationToken
+	}
+}
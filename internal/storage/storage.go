@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+// Storage holds the pulled package files outside of the migration directory,
+// so pull and sync can run on different machines. Keys are slash separated
+// paths relative to migration-packages/packages.
+type Storage interface {
+	// Put stores size bytes of content under key, replacing any existing object
+	Put(ctx context.Context, key string, content io.Reader, size int64) error
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the keys starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+	// String is the --storage location
+	String() string
+}
+
+// SCHEMES are the --storage locations supported
+var SCHEMES = []string{"s3://bucket/prefix", "azblob://account/container/prefix", "gs://bucket/prefix"}
+
+// New returns the storage backend of a --storage location, nil when it is empty
+// and the packages are only kept in the migration directory
+func New(location string) (Storage, error) {
+	if location == "" {
+		return nil, nil
+	}
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid --storage %q, expected one of: %s", location, strings.Join(SCHEMES, ", "))
+	}
+	prefix := strings.Trim(parsed.Path, "/")
+	switch parsed.Scheme {
+	case "s3":
+		return newS3(parsed.Host, prefix)
+	case "azblob":
+		container, prefix, _ := strings.Cut(prefix, "/")
+		if container == "" {
+			return nil, fmt.Errorf("invalid --storage %q, the container is missing: azblob://account/container/prefix", location)
+		}
+		return newAzure(parsed.Host, container, prefix)
+	case "gs":
+		return newGCS(parsed.Host, prefix)
+	}
+	return nil, fmt.Errorf("unsupported --storage %q, expected one of: %s", location, strings.Join(SCHEMES, ", "))
+}
+
+// objectKey prefixes a key with the path of the --storage location
+func objectKey(prefix, key string) string {
+	return strings.TrimPrefix(path.Join(prefix, key), "/")
+}
+
+// relativeKey strips the path of the --storage location from an object name
+func relativeKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return strings.TrimPrefix(name, prefix+"/")
+}
+
+// escapePath percent-encodes every byte of a key except unreserved characters
+// and slashes, the strictest encoding the object stores expect
+func escapePath(key string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// do sends a request and returns the response when its status is one of want,
+// an error with the start of the body otherwise
+func do(req *http.Request, want ...int) (*http.Response, error) {
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range want {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	// The query can hold a SAS token
+	location := url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path}
+	return nil, fmt.Errorf("%s %s returned %s: %s", req.Method, location.String(), resp.Status, strings.TrimSpace(string(body)))
+}
+
+// httpClient is used to reach the object stores
+var httpClient = utils.NewHTTPClient
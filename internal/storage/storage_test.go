@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "?sv=2022-11-02&sig=abc")
+
+	if store, err := New(""); store != nil || err != nil {
+		t.Errorf("New(\"\") = %v, %v, want no storage", store, err)
+	}
+	for location, want := range map[string]string{
+		"s3://bucket/migration/":              "s3://bucket/migration",
+		"azblob://account/packages/migration": "azblob://account/packages/migration",
+		"gs://bucket":                         "gs://bucket",
+	} {
+		store, err := New(location)
+		if err != nil {
+			t.Errorf("New(%s): %v", location, err)
+		} else if store.String() != want {
+			t.Errorf("New(%s) = %s, want %s", location, store, want)
+		}
+	}
+	for _, location := range []string{"./migration-packages", "ftp://host/path", "azblob://account", "s3:///prefix"} {
+		if _, err := New(location); err == nil {
+			t.Errorf("New(%s) accepted an invalid location", location)
+		}
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	if got := escapePath("octo/maven/app/1.0.0+build/app 1.jar"); got != "octo/maven/app/1.0.0%2Bbuild/app%201.jar" {
+		t.Errorf("escapePath = %s", got)
+	}
+}
+
+// fakeS3 serves path style requests for a single bucket
+func fakeS3(t *testing.T, objects map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") || r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/bucket"), "/")
+		switch {
+		case r.Method == http.MethodPut:
+			content, _ := io.ReadAll(r.Body)
+			if int64(len(content)) != r.ContentLength {
+				t.Errorf("PUT %s sent %d bytes with Content-Length %d", key, len(content), r.ContentLength)
+			}
+			objects[key] = string(content)
+		case key == "":
+			var names []string
+			for name := range objects {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			fmt.Fprint(w, "<ListBucketResult>")
+			for _, name := range names {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", name)
+			}
+			fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+		default:
+			content, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, content)
+		}
+	}))
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	objects := map[string]string{}
+	server := fakeS3(t, objects)
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	store, err := New("s3://bucket/run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for key, content := range map[string]string{
+		"octo/npm/app/1.0.0/app-1.0.0.tgz":                         "tarball",
+		"octo/container/app/v1/app-v1.oci/index.json":              "{}",
+		"octo/container/app/v1/app-v1.oci/blobs/sha256/0123456789": "",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	if _, ok := objects["run-1/octo/npm/app/1.0.0/app-1.0.0.tgz"]; !ok {
+		t.Errorf("objects are not stored under the prefix: %v", objects)
+	}
+
+	keys, err := store.List(ctx, "octo/container/app/v1")
+	if err != nil || len(keys) != 2 || keys[0] != "octo/container/app/v1/app-v1.oci/blobs/sha256/0123456789" {
+		t.Errorf("List = %v, %v", keys, err)
+	}
+
+	content, err := store.Get(ctx, "octo/npm/app/1.0.0/app-1.0.0.tgz")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer content.Close()
+	if got, _ := io.ReadAll(content); string(got) != "tarball" {
+		t.Errorf("Get = %q", got)
+	}
+	if _, err := store.Get(ctx, "octo/npm/app/2.0.0/app-2.0.0.tgz"); err == nil {
+		t.Error("Get returned a missing object")
+	}
+}
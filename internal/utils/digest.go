@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DigestHeaders returns the integrity headers of an upload: Content-MD5
+// (RFC 1864), Digest (RFC 3230) and the X-Checksum headers Artifactory reads.
+// Registries validating them reject content corrupted on the way instead of
+// storing it.
+func DigestHeaders(content io.Reader) (http.Header, error) {
+	md5Hash, sha1Hash, sha256Hash := md5.New(), sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha1Hash, sha256Hash), content); err != nil {
+		return nil, err
+	}
+	sha256Sum := sha256Hash.Sum(nil)
+	header := http.Header{}
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Hash.Sum(nil)))
+	header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sha256Sum))
+	header.Set("X-Checksum-Sha1", hex.EncodeToString(sha1Hash.Sum(nil)))
+	header.Set("X-Checksum-Sha256", hex.EncodeToString(sha256Sum))
+	return header, nil
+}
+
+// DigestValidated reports whether the registry answered an upload sent with
+// DigestHeaders with the digest of what it stored, and that digest is the one
+// sent: a registry echoing the digest computed it from the content it received.
+func DigestValidated(resp *http.Response) bool {
+	if resp == nil || resp.Request == nil {
+		return false
+	}
+	sent := resp.Request.Header
+	if digest := resp.Header.Get("X-Checksum-Sha256"); digest != "" {
+		return strings.EqualFold(digest, sent.Get("X-Checksum-Sha256"))
+	}
+	sentAlgorithm, sentValue, ok := strings.Cut(sent.Get("Digest"), "=")
+	if !ok {
+		return false
+	}
+	for _, digest := range strings.Split(resp.Header.Get("Digest"), ",") {
+		// Algorithms are case insensitive, the base64 values are not
+		algorithm, value, _ := strings.Cut(strings.TrimSpace(digest), "=")
+		if strings.EqualFold(algorithm, sentAlgorithm) && value == sentValue {
+			return true
+		}
+	}
+	return false
+}
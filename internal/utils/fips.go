@@ -0,0 +1,9 @@
+//go:build boringcrypto
+
+package utils
+
+// Restrict every TLS connection of the process to FIPS approved settings
+import _ "crypto/tls/fipsonly"
+
+// FIPSBuild reports whether the binary was built with a FIPS validated crypto module
+const FIPSBuild = true
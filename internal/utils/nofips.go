@@ -0,0 +1,6 @@
+//go:build !boringcrypto
+
+package utils
+
+// FIPSBuild reports whether the binary was built with a FIPS validated crypto module
+const FIPSBuild = false
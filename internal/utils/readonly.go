@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ErrReadOnly is returned by every write refused in read-only mode
+var ErrReadOnly = errors.New("refused in read-only mode")
+
+// ReadOnlyOutput is where a read-only run writes its results, the standard
+// output the console is moved away from
+var ReadOnlyOutput io.Writer = os.Stdout
+
+// ReadOnly reports whether GHMPKG_READ_ONLY is set: nothing is written to disk
+// and only reads are sent to the registries and APIs
+func ReadOnly() bool {
+	return viper.GetBool("GHMPKG_READ_ONLY")
+}
+
+// RefuseWrite returns an ErrReadOnly error naming the path in read-only mode
+func RefuseWrite(path string) error {
+	if ReadOnly() {
+		return fmt.Errorf("%w: write to %s", ErrReadOnly, path)
+	}
+	return nil
+}
+
+// GuardTransport wraps base with the guards every HTTP client of the tool goes
+// through: in read-only mode requests other than reads are refused before
+// they leave, and with GHMPKG_RECORD_HTTP the traffic is recorded
+func GuardTransport(base http.RoundTripper) http.RoundTripper {
+	return RecordingTransport(&readOnlyTransport{base: base})
+}
+
+type readOnlyTransport struct {
+	base http.RoundTripper
+}
+
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ReadOnly() {
+		checked, err := readOnlyRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		req = checked
+	}
+	return t.base.RoundTrip(req)
+}
+
+// readOnlyRequest returns the request when it only reads: GET, HEAD and
+// OPTIONS, and GraphQL queries, which are sent with POST. The body of a
+// GraphQL request is read to tell queries from mutations, the request
+// returned carries a copy of it.
+func readOnlyRequest(req *http.Request) (*http.Request, error) {
+	refused := fmt.Errorf("%w: %s %s", ErrReadOnly, req.Method, SanitizeURL(req.URL))
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req, nil
+	case http.MethodPost:
+		if !strings.HasSuffix(req.URL.Path, "/graphql") || req.Body == nil {
+			return nil, refused
+		}
+		content, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		var body struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(content, &body); err != nil || !isGraphQLQuery(body.Query) {
+			return nil, refused
+		}
+		checked := req.Clone(req.Context())
+		checked.Body = io.NopCloser(bytes.NewReader(content))
+		checked.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		}
+		return checked, nil
+	}
+	return nil, refused
+}
+
+// isGraphQLQuery reports whether a GraphQL document is a query, written as
+// query ... or in the shorthand { ... }, and not a mutation or subscription
+func isGraphQLQuery(document string) bool {
+	document = strings.TrimSpace(document)
+	return strings.HasPrefix(document, "{") || (strings.HasPrefix(document, "query") && !strings.Contains(document, "mutation"))
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestReadOnlyTransport(t *testing.T) {
+	defer viper.Set("GHMPKG_READ_ONLY", false)
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Method+" "+string(body))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: GuardTransport(http.DefaultTransport)}
+
+	send := func(method, path, body string) error {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	viper.Set("GHMPKG_READ_ONLY", true)
+	for _, allowed := range [][]string{
+		{http.MethodGet, "/orgs/mona/packages", ""},
+		{http.MethodHead, "/v2/app/manifests/1.0", ""},
+		{http.MethodPost, "/graphql", `{"query":"query($owner:String!){organization(login:$owner){id}}"}`},
+		{http.MethodPost, "/api/graphql", `{"query":"{viewer{login}}"}`},
+	} {
+		if err := send(allowed[0], allowed[1], allowed[2]); err != nil {
+			t.Errorf("%s %s refused: %v", allowed[0], allowed[1], err)
+		}
+	}
+	// The body of a query is read and sent as is
+	if last := received[len(received)-1]; last != `POST {"query":"{viewer{login}}"}` {
+		t.Errorf("server received %q", last)
+	}
+
+	sent := len(received)
+	for _, refused := range [][]string{
+		{http.MethodPut, "/npm/@mona/client", "{}"},
+		{http.MethodDelete, "/orgs/mona/packages/npm/client", ""},
+		{http.MethodPost, "/v2/app/blobs/uploads/", ""},
+		{http.MethodPost, "/graphql", `{"query":"mutation($input:DeletePackageVersionInput!){deletePackageVersion(input:$input){success}}"}`},
+		{http.MethodPost, "/graphql", "not json"},
+	} {
+		if err := send(refused[0], refused[1], refused[2]); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s %s = %v, want ErrReadOnly", refused[0], refused[1], err)
+		}
+	}
+	if len(received) != sent {
+		t.Errorf("refused requests reached the server: %v", received[sent:])
+	}
+
+	viper.Set("GHMPKG_READ_ONLY", false)
+	if err := send(http.MethodPut, "/npm/@mona/client", "{}"); err != nil {
+		t.Errorf("PUT without read-only = %v", err)
+	}
+}
+
+func TestReadOnlyWrites(t *testing.T) {
+	defer viper.Set("GHMPKG_READ_ONLY", false)
+	viper.Set("GHMPKG_READ_ONLY", true)
+	dir := t.TempDir()
+
+	if err := WriteFileAtomic(filepath.Join(dir, "nested", "report.json"), []byte("{}"), 0644); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("WriteFileAtomic = %v, want ErrReadOnly", err)
+	}
+	if err := EnsureDirExists(filepath.Join(dir, "nested", "file.tgz")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("EnsureDirExists = %v, want ErrReadOnly", err)
+	}
+	if FileExists(filepath.Join(dir, "nested")) {
+		t.Error("a directory was created in read-only mode")
+	}
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// HTTPRecord is the sanitized metadata of a single HTTP exchange, written when
+// GHMPKG_RECORD_HTTP is set. Bodies and credentials are never recorded.
+type HTTPRecord struct {
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	Status          int               `json:"status,omitempty"`
+	DurationMs      int64             `json:"duration_ms"`
+	RequestSize     int64             `json:"request_size"`
+	ResponseSize    int64             `json:"response_size"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// sensitiveFragments mark header and query parameter names whose values are redacted
+var sensitiveFragments = []string{"authorization", "cookie", "token", "key", "secret", "signature", "credential", "password"}
+
+var recorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// StartHTTPRecording opens the recording file for the command in the migration
+// directory and returns its path, every HTTP client created by this package
+// appends to it from then on
+func StartHTTPRecording(command string) (string, error) {
+	migrationPath := MigrationPath()
+	path := filepath.Join(migrationPath, "http", fmt.Sprintf("%s_%s.jsonl", time.Now().Format("2006-01-02_15-04-05"), command))
+	if err := RefuseWrite(path); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create recording file: %w", err)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.file != nil {
+		recorder.file.Close()
+	}
+	recorder.file = file
+	return path, nil
+}
+
+func writeHTTPRecord(record HTTPRecord) {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.file != nil {
+		recorder.file.Write(append(content, '\n'))
+	}
+}
+
+type recordingTransport struct {
+	base http.RoundTripper
+}
+
+// RecordingTransport wraps base so every exchange is recorded once recording has
+// started, it returns base unchanged when GHMPKG_RECORD_HTTP is not set
+func RecordingTransport(base http.RoundTripper) http.RoundTripper {
+	if !viper.GetBool("GHMPKG_RECORD_HTTP") {
+		return base
+	}
+	return &recordingTransport{base: base}
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	record := HTTPRecord{
+		Time:           start.UTC(),
+		Method:         req.Method,
+		URL:            SanitizeURL(req.URL),
+		DurationMs:     time.Since(start).Milliseconds(),
+		RequestSize:    req.ContentLength,
+		RequestHeaders: sanitizeHeaders(req.Header),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Status = resp.StatusCode
+		record.ResponseSize = resp.ContentLength
+		record.ResponseHeaders = sanitizeHeaders(resp.Header)
+	}
+	writeHTTPRecord(record)
+
+	return resp, err
+}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, fragment := range sensitiveFragments {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeURL returns the URL without user info and with sensitive query values redacted
+func SanitizeURL(u *url.URL) string {
+	sanitized := *u
+	sanitized.User = nil
+	if query := sanitized.Query(); len(query) > 0 {
+		for name := range query {
+			if isSensitive(name) {
+				query.Set(name, "REDACTED")
+			}
+		}
+		sanitized.RawQuery = query.Encode()
+	}
+	return sanitized.String()
+}
+
+func sanitizeHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string)
+	for name, values := range header {
+		if isSensitive(name) {
+			headers[name] = "REDACTED"
+			continue
+		}
+		value := strings.Join(values, ", ")
+		// Redirects to blob storage carry signed query strings
+		if strings.EqualFold(name, "Location") {
+			if location, err := url.Parse(value); err == nil {
+				value = SanitizeURL(location)
+			}
+		}
+		headers[name] = value
+	}
+	return headers
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/viper"
+)
+
+// Cipher policies accepted by GHMPKG_TLS_CIPHER_POLICY
+const (
+	CipherPolicyDefault = "default"
+	CipherPolicyFIPS    = "fips"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// fipsCipherSuites are the FIPS 140 approved TLS 1.2 suites. Go does not allow
+// TLS 1.3 suites to be configured, only a FIPS build restricts those as well.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+
+// TLSConfig builds the client TLS configuration from GHMPKG_TLS_MIN_VERSION and
+// GHMPKG_TLS_CIPHER_POLICY. It fails on values it does not recognize rather than
+// silently connecting with weaker settings.
+func TLSConfig() (*tls.Config, error) {
+	minVersion := viper.GetString("GHMPKG_TLS_MIN_VERSION")
+	if minVersion == "" {
+		minVersion = "1.2"
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS minimum version: %s (expected 1.2 or 1.3)", minVersion)
+	}
+	config := &tls.Config{MinVersion: version}
+
+	policy := viper.GetString("GHMPKG_TLS_CIPHER_POLICY")
+	if policy == "" && FIPSBuild {
+		policy = CipherPolicyFIPS
+	}
+	switch policy {
+	case "", CipherPolicyDefault:
+		if FIPSBuild {
+			return nil, fmt.Errorf("the %s cipher policy is not allowed in a FIPS build", CipherPolicyDefault)
+		}
+	case CipherPolicyFIPS:
+		config.CipherSuites = fipsCipherSuites
+		config.CurvePreferences = fipsCurves
+	default:
+		return nil, fmt.Errorf("unsupported TLS cipher policy: %s (expected %s or %s)", policy, CipherPolicyDefault, CipherPolicyFIPS)
+	}
+	return config, nil
+}
+
+// NewTransport returns an HTTP transport using the configured TLS settings. The
+// settings are validated when a command starts, so an invalid configuration
+// never reaches this point.
+func NewTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config, err := TLSConfig(); err == nil {
+		transport.TLSClientConfig = config
+	}
+	return transport
+}
+
+// NewHTTPClient returns an HTTP client using the configured TLS settings, recording
+// its traffic when GHMPKG_RECORD_HTTP is set and refusing writes in read-only mode
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: GuardTransport(NewTransport())}
+}
+
+// TLSDescription summarizes the TLS settings in use for the connection status output
+func TLSDescription() string {
+	config, err := TLSConfig()
+	if err != nil {
+		return err.Error()
+	}
+	policy := CipherPolicyDefault
+	if config.CipherSuites != nil {
+		policy = CipherPolicyFIPS
+	}
+	description := fmt.Sprintf("TLS >= %s, %s cipher policy", tls.VersionName(config.MinVersion), policy)
+	if FIPSBuild {
+		description += ", FIPS build"
+	}
+	return description
+}
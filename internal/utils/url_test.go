@@ -0,0 +1,57 @@
+package utils_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+func TestJoinUrlPath(t *testing.T) {
+	base, err := url.Parse("https://npm.pkg.github.com/")
+	if err != nil {
+		t.Fatalf("Failed to parse base url: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		segments []string
+		expected string
+	}{
+		{"plain segments", []string{"mona", "package", "1.0.0"}, "https://npm.pkg.github.com/mona/package/1.0.0"},
+		{"scoped npm name", []string{"@mona/package"}, "https://npm.pkg.github.com/@mona%2Fpackage"},
+		{"space", []string{"my package"}, "https://npm.pkg.github.com/my%20package"},
+		{"query and fragment characters", []string{"a?b", "c#d"}, "https://npm.pkg.github.com/a%3Fb/c%23d"},
+		{"percent sign", []string{"100%"}, "https://npm.pkg.github.com/100%25"},
+		{"mixed case", []string{"MyGem"}, "https://npm.pkg.github.com/MyGem"},
+		{"build metadata", []string{"1.0.0+build"}, "https://npm.pkg.github.com/1.0.0+build"},
+		{"empty segments skipped", []string{"", "mona", ""}, "https://npm.pkg.github.com/mona"},
+	}
+
+	for _, test := range tests {
+		joined := utils.JoinUrlPath(*base, test.segments...)
+		if got := joined.String(); got != test.expected {
+			t.Errorf("%s: JoinUrlPath returned %s, expected %s", test.name, got, test.expected)
+		}
+	}
+
+	// The base url must not be modified
+	if base.String() != "https://npm.pkg.github.com/" {
+		t.Errorf("JoinUrlPath modified the base url: %s", base.String())
+	}
+}
+
+func TestJoinUrlPathKeepsEscapedBase(t *testing.T) {
+	base, err := url.Parse("https://example.com/a%2Fb/")
+	if err != nil {
+		t.Fatalf("Failed to parse base url: %v", err)
+	}
+
+	joined := utils.JoinUrlPath(*base, "c d")
+	if got, expected := joined.String(), "https://example.com/a%2Fb/c%20d"; got != expected {
+		t.Errorf("JoinUrlPath returned %s, expected %s", got, expected)
+	}
+	if got, expected := joined.Path, "/a/b/c d"; got != expected {
+		t.Errorf("JoinUrlPath set path %s, expected %s", got, expected)
+	}
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,12 +17,16 @@ import (
 	"time"
 
 	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
 )
 
 const (
 	maxRequestsPerMinute = 5000  // Define a safe threshold
 	maxRequestsPerHour   = 10000 // Define a safe threshold
 	cachePath            = "./cache"
+
+	// DefaultMigrationPath is the migration directory used when none is configured
+	DefaultMigrationPath = "./migration-packages"
 )
 
 var (
@@ -39,6 +45,23 @@ func ResetRequestCounters() {
 	hourStart = time.Now()
 }
 
+// PackageTypeKey returns the package type specific variant of a GHMPKG_ setting,
+// e.g. GHMPKG_SOURCE_TOKEN becomes GHMPKG_NPM_SOURCE_TOKEN for npm
+func PackageTypeKey(key, packageType string) string {
+	return fmt.Sprintf("GHMPKG_%s_%s", strings.ToUpper(packageType), strings.TrimPrefix(key, "GHMPKG_"))
+}
+
+// GetPackageTypeString returns the package type specific value of a setting,
+// falling back to the global value when it is not configured for the type
+func GetPackageTypeString(key, packageType string) string {
+	if packageType != "" {
+		if value := viper.GetString(PackageTypeKey(key, packageType)); value != "" {
+			return value
+		}
+	}
+	return viper.GetString(key)
+}
+
 func ParseUrl(urlStr string) *url.URL {
 	parsedUrl, err := url.Parse(urlStr)
 	if err != nil {
@@ -47,6 +70,70 @@ func ParseUrl(urlStr string) *url.URL {
 	return parsedUrl
 }
 
+// FileDigest returns the sha256 digest of a file, in the sha256:<hex> form registries use
+func FileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// JoinUrlPath appends path segments to a url, escaping each one so names containing
+// "/", "?", "#", "%" or spaces stay a single segment. Empty segments are skipped.
+func JoinUrlPath(base url.URL, segments ...string) url.URL {
+	escapedPath := strings.TrimSuffix(base.EscapedPath(), "/")
+	unescapedPath := strings.TrimSuffix(base.Path, "/")
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		escapedPath += "/" + url.PathEscape(segment)
+		unescapedPath += "/" + segment
+	}
+	base.Path = unescapedPath
+	base.RawPath = escapedPath
+	return base
+}
+
+// WriteFileAtomic writes content to a temporary file next to filename and renames
+// it into place, so readers and a crash mid-write never see a truncated file
+func WriteFileAtomic(filename string, content []byte, perm os.FileMode) error {
+	if err := RefuseWrite(filename); err != nil {
+		return err
+	}
+	if err := EnsureDirExists(filename); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filename)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
 func RenameFileOccurances(filename, oldScope, newScope string, occurances int) error {
 
 	// Read the file
@@ -58,16 +145,14 @@ func RenameFileOccurances(filename, oldScope, newScope string, occurances int) e
 	newContent := strings.Replace(string(content), oldScope, newScope, occurances)
 
 	// Write back to file
-	err = os.WriteFile(filename, []byte(newContent), 0644)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return WriteFileAtomic(filename, []byte(newContent), 0644)
 }
 
 func CacheFile(path, content string, overwrite bool) (string, error) {
 	path = filepath.Join(cachePath, path)
+	if err := RefuseWrite(path); err != nil {
+		return "", err
+	}
 	// Check if the file exists
 	if FileExists(path) && !overwrite {
 		return path, nil
@@ -79,16 +164,8 @@ func CacheFile(path, content string, overwrite bool) (string, error) {
 		return "", fmt.Errorf("failed to create directories: %v", err)
 	}
 
-	// Create the file
-	file, err := os.Create(path)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file: %v", err)
-	}
-	defer file.Close()
-
 	// Write the content to the file
-	_, err = file.WriteString(content)
-	if err != nil {
+	if err := WriteFileAtomic(path, []byte(content), 0644); err != nil {
 		return "", fmt.Errorf("failed to write to file: %v", err)
 	}
 
@@ -162,9 +239,21 @@ func GetFlatListOfColumn(data [][]string, filters map[string]string, targetCol i
 	return result
 }
 
+// MigrationPath returns the configured migration directory, GHMPKG_MIGRATION_PATH,
+// falling back to the default
+func MigrationPath() string {
+	if migrationPath := viper.GetString("GHMPKG_MIGRATION_PATH"); migrationPath != "" {
+		return migrationPath
+	}
+	return DefaultMigrationPath
+}
+
 func EnsureDirExists(path string) error {
 	// Create the directory if it doesn't exist
 	dir := filepath.Dir(path)
+	if err := RefuseWrite(dir); err != nil {
+		return err
+	}
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directories: %v", err)
 	}
@@ -179,7 +268,30 @@ func DownloadFile(url, outputPath, token string) error {
 		return err
 	}
 
-	client := &http.Client{}
+	body, _, err := OpenDownload(url, token)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// Create the file
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer out.Close()
+
+	// Write the response body to the file
+	if _, err = io.Copy(out, body); err != nil {
+		return fmt.Errorf("failed to write to file: %v", err)
+	}
+	return nil
+}
+
+// OpenDownload starts downloading a file and returns its content with its size,
+// -1 when unknown. The caller must close the content.
+func OpenDownload(url, token string) (io.ReadCloser, int64, error) {
+	client := NewHTTPClient()
 
 	for {
 		// Check and update request count
@@ -192,7 +304,7 @@ func DownloadFile(url, outputPath, token string) error {
 		// Create a new HTTP request
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
-			return fmt.Errorf("failed to create request: %v", err)
+			return nil, 0, fmt.Errorf("failed to create request: %v", err)
 		}
 
 		if token != "" {
@@ -203,30 +315,17 @@ func DownloadFile(url, outputPath, token string) error {
 		// Perform the HTTP request
 		resp, err := client.Do(req)
 		if err != nil {
-			return fmt.Errorf("failed to perform request: %v", err)
+			return nil, 0, fmt.Errorf("failed to perform request: %v", err)
 		}
-		defer resp.Body.Close()
 		time.Sleep(500 * time.Millisecond)
 
 		// Check if the response status is OK
 		if resp.StatusCode == http.StatusOK {
-			// Create the file
-			out, err := os.Create(outputPath)
-			if err != nil {
-				return fmt.Errorf("failed to create file: %v", err)
-			}
-			defer out.Close()
-
-			// Write the response body to the file
-			_, err = io.Copy(out, resp.Body)
-			if err != nil {
-				return fmt.Errorf("failed to write to file: %v", err)
-			}
-
-			return nil
+			return resp.Body, resp.ContentLength, nil
 		}
+		resp.Body.Close()
 
-		return fmt.Errorf("failed to download file %s, status: %d, message: %s", url, resp.StatusCode, resp.Status)
+		return nil, 0, fmt.Errorf("failed to download file %s, status: %d, message: %s", url, resp.StatusCode, resp.Status)
 	}
 }
 
@@ -248,7 +347,24 @@ func UploadFile(url, inputPath, token string) (*http.Response, error) {
 		return nil, fmt.Errorf("failed to read file: %v", err)
 	}
 
-	client := &http.Client{}
+	// The whole file is at hand, its digests are sent for the registry to check
+	header, err := DigestHeaders(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash file: %v", err)
+	}
+	return uploadStream(url, inputPath, bytes.NewReader(content), stat.Size(), token, header)
+}
+
+// UploadStream PUTs content to url in a single request, without retrying as the
+// content can only be read once. size is -1 when unknown, the content type is
+// picked from the filename.
+func UploadStream(url, filename string, content io.Reader, size int64, token string) (*http.Response, error) {
+	return uploadStream(url, filename, content, size, token, nil)
+}
+
+// uploadStream is UploadStream with additional headers
+func uploadStream(url, filename string, content io.Reader, size int64, token string, header http.Header) (*http.Response, error) {
+	client := NewHTTPClient()
 
 	for {
 		// Check and update request count
@@ -258,18 +374,24 @@ func UploadFile(url, inputPath, token string) (*http.Response, error) {
 			continue
 		}
 
-		// Create a new HTTP request using the content buffer
-		req, err := http.NewRequest("PUT", url, bytes.NewReader(content))
+		// Create a new HTTP request using the content
+		req, err := http.NewRequest("PUT", url, content)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %v", err)
 		}
+		req.ContentLength = size
+		for name, values := range header {
+			req.Header[name] = values
+		}
 
 		// Add the authorization header
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-		req.Header.Set("Content-Length", fmt.Sprintf("%d", stat.Size()))
-		if strings.HasSuffix(inputPath, ".jar") {
+		if size >= 0 {
+			req.Header.Set("Content-Length", fmt.Sprintf("%d", size))
+		}
+		if strings.HasSuffix(filename, ".jar") {
 			req.Header.Set("Content-Type", "application/java-archive")
-		} else if strings.HasSuffix(inputPath, ".pom") {
+		} else if strings.HasSuffix(filename, ".pom") {
 			req.Header.Set("Content-Type", "application/xml")
 		} else {
 			req.Header.Set("Content-Type", "application/octet-stream")
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "nested", "report.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomic(filename, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFileAtomic: %v", err)
+		}
+		got, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("content = %q, want %q", got, content)
+		}
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v, want 0644", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestStreamDownloadToUpload(t *testing.T) {
+	const content = "<project></project>"
+	var uploaded, contentType, authorization string
+	var length int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			io.WriteString(w, content)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		uploaded, length = string(body), r.ContentLength
+		contentType, authorization = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	body, size, err := OpenDownload(server.URL+"/source.pom", "source")
+	if err != nil {
+		t.Fatalf("OpenDownload: %v", err)
+	}
+	defer body.Close()
+	if size != int64(len(content)) {
+		t.Errorf("OpenDownload size = %d, want %d", size, len(content))
+	}
+	resp, err := UploadStream(server.URL+"/target.pom", "target.pom", body, size, "target")
+	if err != nil {
+		t.Fatalf("UploadStream: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated || uploaded != content || length != size {
+		t.Errorf("uploaded %q (%d bytes, status %d), want %q", uploaded, length, resp.StatusCode, content)
+	}
+	if contentType != "application/xml" || !strings.HasSuffix(authorization, " target") {
+		t.Errorf("upload headers Content-Type=%q Authorization=%q", contentType, authorization)
+	}
+}
+
+func TestUploadFileDigestHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app-1.0.jar")
+	if err := os.WriteFile(path, []byte("jar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		echo  func(r *http.Request) string
+		valid bool
+	}{
+		{"echoes the digest sent", func(r *http.Request) string {
+			return "sha-256=" + strings.TrimPrefix(r.Header.Get("Digest"), "SHA-256=")
+		}, true},
+		{"echoes another digest", func(r *http.Request) string {
+			return "SHA-256=" + strings.ToLower(strings.TrimPrefix(r.Header.Get("Digest"), "SHA-256="))
+		}, false},
+		{"does not echo", func(r *http.Request) string { return "" }, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var contentMD5 string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentMD5 = r.Header.Get("Content-MD5")
+				if echo := tt.echo(r); echo != "" {
+					w.Header().Set("Digest", echo)
+				}
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			resp, err := UploadFile(server.URL+"/app-1.0.jar", path, "target")
+			if err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			resp.Body.Close()
+			// md5("jar")
+			if contentMD5 != "aJlfy/QySS0VSE0EqdKsQA==" {
+				t.Errorf("Content-MD5 = %q", contentMD5)
+			}
+			if DigestValidated(resp) != tt.valid {
+				t.Errorf("DigestValidated = %v, want %v", !tt.valid, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package capabilities
+
+import (
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// Support of a package type or feature on a server
+const (
+	Supported   = "yes"
+	Unsupported = "no"
+	Manual      = "manual"
+	Unknown     = "unknown"
+)
+
+// server is the GitHub instance of one side of the migration for a package type
+type server struct {
+	Hostname string
+	// Version is empty for GitHub.com and when it could not be detected
+	Version    string
+	Enterprise bool
+}
+
+func (s server) String() string {
+	if !s.Enterprise {
+		return "GitHub.com"
+	}
+	if s.Version == "" {
+		return fmt.Sprintf("GitHub Enterprise Server (unknown version) on %s", s.Hostname)
+	}
+	return fmt.Sprintf("GitHub Enterprise Server %s on %s", s.Version, s.Hostname)
+}
+
+// feature is something a migration carries over besides the package files
+type feature struct {
+	Name string
+	// PackageTypes the feature applies to, every type when empty
+	PackageTypes []string
+	// Status forces the support of the feature, whatever the server
+	Status string
+	// Minimum is the first GitHub Enterprise Server release supporting the
+	// feature, the registry of the package type is enough when empty
+	Minimum string
+	Detail  string
+}
+
+// FEATURES are the migration features reported by the capabilities command
+var FEATURES = []feature{
+	{Name: "repository linking", Detail: "packages are linked to the repository named in their metadata"},
+	{Name: "team access", Detail: "apply-permissions grants teams access to the linked repository"},
+	{Name: "visibility migration", Status: Manual, Detail: "the API cannot set package visibility, apply-permissions lists the differences"},
+	{Name: "oci artifacts", PackageTypes: []string{"container"}, Detail: "Helm charts and other OCI artifacts are copied registry to registry, without the Docker daemon"},
+	{Name: "referrers", PackageTypes: []string{"container"}, Status: Manual, Detail: "signatures and attestations attached to images are copied with --copy-referrers"},
+}
+
+// support tells whether a server supports a package type or feature introduced
+// in the given GitHub Enterprise Server release
+func support(s server, minimum string) string {
+	switch {
+	case !s.Enterprise:
+		return Supported
+	case s.Version == "":
+		return Unknown
+	case minimum == "" || common.VersionAtLeast(s.Version, minimum):
+		return Supported
+	default:
+		return fmt.Sprintf("%s (requires %s)", Unsupported, minimum)
+	}
+}
+
+// packageTypeRows is the support of every package type on both sides
+func packageTypeRows(packageTypes []string, sources, targets map[string]server) [][]string {
+	rows := [][]string{{"Package type", "Source", "Target"}}
+	for _, packageType := range packageTypes {
+		minimum := common.MINIMUM_SERVER_VERSIONS[packageType]
+		rows = append(rows, []string{packageType, support(sources[packageType], minimum), support(targets[packageType], minimum)})
+	}
+	return rows
+}
+
+// featureRows is the support of every feature for every package type it applies to.
+// A feature is supported when both sides support it.
+func featureRows(packageTypes []string, sources, targets map[string]server) [][]string {
+	rows := [][]string{{"Feature", "Package type", "Supported", "Detail"}}
+	for _, f := range FEATURES {
+		for _, packageType := range packageTypes {
+			if len(f.PackageTypes) > 0 && !utils.Contains(f.PackageTypes, packageType) {
+				continue
+			}
+			status := f.Status
+			if status == "" {
+				minimum := f.Minimum
+				if minimum == "" {
+					minimum = common.MINIMUM_SERVER_VERSIONS[packageType]
+				}
+				status = support(targets[packageType], minimum)
+				if source := support(sources[packageType], minimum); source != Supported && status == Supported {
+					status = source
+				}
+			}
+			rows = append(rows, []string{f.Name, packageType, status, f.Detail})
+		}
+	}
+	return rows
+}
+
+// detect finds the server of a side for every package type
+func detect(logger *zap.Logger, side string, packageTypes []string) map[string]server {
+	prefix := "GHMPKG_SOURCE_"
+	if side == "target" {
+		prefix = "GHMPKG_TARGET_"
+	}
+	servers := make(map[string]server)
+	for _, packageType := range packageTypes {
+		hostname := utils.GetPackageTypeString(prefix+"HOSTNAME", packageType)
+		s := server{Hostname: hostname, Enterprise: api.EnterpriseApiUrl(hostname) != ""}
+		if s.Enterprise {
+			version, err := common.ServerVersion(utils.GetPackageTypeString(prefix+"TOKEN", packageType), hostname)
+			if err != nil {
+				logger.Warn("Could not detect GitHub Enterprise Server version",
+					zap.String("side", side),
+					zap.String("hostname", hostname),
+					zap.Error(err))
+			}
+			s.Version = version
+		}
+		servers[packageType] = s
+	}
+	return servers
+}
+
+// Capabilities prints which package types and migration features the
+// configured source and target support, so the limits of a migration are known
+// before it starts
+func Capabilities(logger *zap.Logger) error {
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		return err
+	}
+
+	sources := detect(logger, "source", packageTypes)
+	targets := detect(logger, "target", packageTypes)
+
+	// Servers are usually the same for every package type, print each one once
+	printed := make(map[string]bool)
+	for _, side := range []struct {
+		name    string
+		servers map[string]server
+	}{{"Source", sources}, {"Target", targets}} {
+		for _, packageType := range packageTypes {
+			description := side.servers[packageType].String()
+			if printed[side.name+description] {
+				continue
+			}
+			printed[side.name+description] = true
+			pterm.Info.Printf("%s: %s\n", side.name, description)
+		}
+	}
+
+	fmt.Println()
+	if err := pterm.DefaultTable.WithHasHeader().WithData(packageTypeRows(packageTypes, sources, targets)).Render(); err != nil {
+		return err
+	}
+	fmt.Println()
+	return pterm.DefaultTable.WithHasHeader().WithData(featureRows(packageTypes, sources, targets)).Render()
+}
@@ -0,0 +1,53 @@
+package capabilities
+
+import "testing"
+
+func TestSupport(t *testing.T) {
+	tests := []struct {
+		server  server
+		minimum string
+		want    string
+	}{
+		{server{}, "3.5", Supported},
+		{server{Hostname: "ghes.example.com", Enterprise: true}, "3.5", Unknown},
+		{server{Hostname: "ghes.example.com", Version: "3.9.2", Enterprise: true}, "3.5", Supported},
+		{server{Hostname: "ghes.example.com", Version: "3.4.0", Enterprise: true}, "3.5", "no (requires 3.5)"},
+		{server{Hostname: "ghes.example.com", Version: "2.22.0", Enterprise: true}, "", Supported},
+	}
+	for _, tt := range tests {
+		if got := support(tt.server, tt.minimum); got != tt.want {
+			t.Errorf("support(%s, %q) = %q, want %q", tt.server, tt.minimum, got, tt.want)
+		}
+	}
+}
+
+func TestFeatureRows(t *testing.T) {
+	old := server{Hostname: "ghes.example.com", Version: "3.4.1", Enterprise: true}
+	sources := map[string]server{"container": {}, "npm": {}}
+	targets := map[string]server{"container": old, "npm": old}
+
+	rows := featureRows([]string{"container", "npm"}, sources, targets)
+	got := make(map[string]string)
+	for _, row := range rows[1:] {
+		got[row[0]+"|"+row[1]] = row[2]
+	}
+
+	want := map[string]string{
+		"repository linking|container":   "no (requires 3.5)",
+		"repository linking|npm":         Supported,
+		"visibility migration|npm":       Manual,
+		"referrers|container":            Manual,
+		"oci artifacts|container":        "no (requires 3.5)",
+		"team access|container":          "no (requires 3.5)",
+		"visibility migration|container": Manual,
+		"team access|npm":                Supported,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d feature rows, want %d: %v", len(got), len(want), got)
+	}
+	for key, status := range want {
+		if got[key] != status {
+			t.Errorf("%s = %q, want %q", key, got[key], status)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// PACKAGE_TYPE_ALIASES maps well-known synonyms onto the package type GitHub
+// uses for them. GHMPKG_PACKAGE_TYPE_ALIASES adds to or overrides them.
+var PACKAGE_TYPE_ALIASES = map[string]string{
+	"ghcr":   "container",
+	"oci":    "container",
+	"gradle": "maven",
+	"gem":    "rubygems",
+	"gems":   "rubygems",
+	"ruby":   "rubygems",
+	"node":   "npm",
+	"dotnet": "nuget",
+}
+
+// PackageTypeAliases returns the built-in aliases merged with the ones configured
+// as alias=type entries in GHMPKG_PACKAGE_TYPE_ALIASES
+func PackageTypeAliases() (map[string]string, error) {
+	aliases := make(map[string]string, len(PACKAGE_TYPE_ALIASES))
+	for alias, packageType := range PACKAGE_TYPE_ALIASES {
+		aliases[alias] = packageType
+	}
+	for _, value := range viper.GetStringSlice("GHMPKG_PACKAGE_TYPE_ALIASES") {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			alias, packageType, ok := strings.Cut(entry, "=")
+			alias = strings.ToLower(strings.TrimSpace(alias))
+			packageType = strings.ToLower(strings.TrimSpace(packageType))
+			if !ok || alias == "" {
+				return nil, fmt.Errorf("invalid package type alias %q, expected alias=type", entry)
+			}
+			if !utils.Contains(SUPPORTED_PACKAGE_TYPES, packageType) {
+				return nil, fmt.Errorf("package type alias %q maps to unsupported package type: %s", alias, packageType)
+			}
+			aliases[alias] = packageType
+		}
+	}
+	return aliases, nil
+}
+
+// announcedAliases are the aliases whose mapping was printed, once per run
+var announcedAliases sync.Map
+
+// deprecatedPackageType warns once that GHMPKG_PACKAGE_TYPE was used
+var deprecatedPackageType sync.Once
+
+// DesiredPackageTypes returns the package types to process as the user gave
+// them, comma or space separated, in GHMPKG_PACKAGE_TYPES. GHMPKG_PACKAGE_TYPE,
+// which sync used to read, is still honored when GHMPKG_PACKAGE_TYPES is not set.
+func DesiredPackageTypes() []string {
+	names := splitPackageTypes(viper.GetStringSlice("GHMPKG_PACKAGE_TYPES"))
+	if len(names) == 0 {
+		if names = splitPackageTypes(viper.GetStringSlice("GHMPKG_PACKAGE_TYPE")); len(names) > 0 {
+			deprecatedPackageType.Do(func() {
+				pterm.Warning.Println("⚠️  GHMPKG_PACKAGE_TYPE is deprecated, use GHMPKG_PACKAGE_TYPES")
+			})
+		}
+	}
+	return names
+}
+
+func splitPackageTypes(values []string) []string {
+	var names []string
+	for _, value := range values {
+		for _, name := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			if !utils.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// PackageTypeFilter returns the package types every command processes: the
+// desired ones, resolved, or every supported package type when none is given
+func PackageTypeFilter() ([]string, error) {
+	desired := DesiredPackageTypes()
+	if len(desired) == 0 {
+		return SUPPORTED_PACKAGE_TYPES, nil
+	}
+	return ResolvePackageTypes(desired)
+}
+
+// ResolvePackageType returns the supported package type a name given by the
+// user stands for, printing the mapping the first time it is an alias
+func ResolvePackageType(name string) (string, error) {
+	packageType := strings.ToLower(strings.TrimSpace(name))
+	if utils.Contains(SUPPORTED_PACKAGE_TYPES, packageType) {
+		return packageType, nil
+	}
+	aliases, err := PackageTypeAliases()
+	if err != nil {
+		return "", err
+	}
+	if resolved, ok := aliases[packageType]; ok {
+		if _, announced := announcedAliases.LoadOrStore(packageType, true); !announced {
+			pterm.Info.Printf("🔀 Package type %s is handled as %s\n", name, resolved)
+		}
+		return resolved, nil
+	}
+	return "", fmt.Errorf("unsupported package type: %s (supported: %s, aliases: %s)", name, strings.Join(SUPPORTED_PACKAGE_TYPES, ", "), describeAliases(aliases))
+}
+
+// ResolvePackageTypes resolves every name, dropping the duplicates aliases can introduce
+func ResolvePackageTypes(names []string) ([]string, error) {
+	var packageTypes []string
+	for _, name := range names {
+		packageType, err := ResolvePackageType(name)
+		if err != nil {
+			return nil, err
+		}
+		if !utils.Contains(packageTypes, packageType) {
+			packageTypes = append(packageTypes, packageType)
+		}
+	}
+	return packageTypes, nil
+}
+
+func describeAliases(aliases map[string]string) string {
+	var entries []string
+	for alias, packageType := range aliases {
+		entries = append(entries, alias+"="+packageType)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ", ")
+}
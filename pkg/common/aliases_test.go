@@ -0,0 +1,64 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestResolvePackageTypes(t *testing.T) {
+	viper.Set("GHMPKG_PACKAGE_TYPE_ALIASES", []string{"podman=container, jar=maven"})
+	defer viper.Set("GHMPKG_PACKAGE_TYPE_ALIASES", nil)
+
+	got, err := ResolvePackageTypes([]string{"ghcr", "container", "Gradle", "podman", "jar", "npm"})
+	if err != nil {
+		t.Fatalf("ResolvePackageTypes: %v", err)
+	}
+	if want := []string{"container", "maven", "npm"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ResolvePackageTypes = %v, want %v", got, want)
+	}
+
+	// docker is the legacy docker.pkg.github.com registry, not an alias of container
+	if got, err := ResolvePackageType("docker"); err != nil || got != "docker" {
+		t.Fatalf("ResolvePackageType(docker) = %s, %v, want docker", got, err)
+	}
+
+	if _, err := ResolvePackageType("pypi"); err == nil {
+		t.Fatal("ResolvePackageType(pypi) succeeded, want an unsupported package type error")
+	}
+
+	viper.Set("GHMPKG_PACKAGE_TYPE_ALIASES", []string{"wheel=pypi"})
+	if _, err := ResolvePackageType("wheel"); err == nil {
+		t.Fatal("alias to an unsupported package type was accepted")
+	}
+}
+
+func TestPackageTypeFilter(t *testing.T) {
+	defer viper.Reset()
+
+	tests := []struct {
+		types, legacy string
+		want          []string
+	}{
+		{"", "", SUPPORTED_PACKAGE_TYPES},
+		{"npm,oci", "", []string{"npm", "container"}},
+		{"npm oci, maven", "", []string{"npm", "container", "maven"}},
+		{"", "ghcr", []string{"container"}},
+		// GHMPKG_PACKAGE_TYPE is only read when GHMPKG_PACKAGE_TYPES is not set
+		{"nuget", "npm", []string{"nuget"}},
+	}
+	for _, test := range tests {
+		viper.Set("GHMPKG_PACKAGE_TYPES", test.types)
+		viper.Set("GHMPKG_PACKAGE_TYPE", test.legacy)
+		got, err := PackageTypeFilter()
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("PackageTypeFilter(%q, %q) = %v, %v, want %v", test.types, test.legacy, got, err, test.want)
+		}
+	}
+
+	viper.Set("GHMPKG_PACKAGE_TYPES", []string{"npm", "pypi"})
+	if _, err := PackageTypeFilter(); err == nil {
+		t.Error("PackageTypeFilter accepted an unsupported package type")
+	}
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+)
+
+// ExpectChecksums registers the checksums of the export for pull, and sync
+// --stream, to verify the downloads against. Container images are pulled by tag
+// and checked against the digest of the version they were exported as.
+func ExpectChecksums(rows [][]string) int {
+	expected := 0
+	for _, row := range rows {
+		if len(row) < 6 {
+			continue
+		}
+		if providers.IsImage(row[2]) {
+			_, tag, ok := strings.Cut(row[5], ":")
+			if ok && strings.HasPrefix(row[4], "sha256:") {
+				providers.ExpectChecksum(row[1], row[2], row[3], tag, row[5], row[4])
+				expected++
+			}
+			continue
+		}
+		if len(row) > ChecksumColumn && row[ChecksumColumn] != "" {
+			providers.ExpectChecksum(row[1], row[2], row[3], row[4], row[5], row[ChecksumColumn])
+			expected++
+		}
+	}
+	return expected
+}
@@ -1,32 +1,96 @@
 package common
 
 import (
+	"errors"
 	"fmt"
-
-	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/inventory"
+	"github.com/mona-actions/gh-migrate-packages/internal/ledger"
+	"github.com/mona-actions/gh-migrate-packages/internal/progress"
 	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
 	"github.com/mona-actions/gh-migrate-packages/internal/utils"
 	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
-var SUPPORTED_PACKAGE_TYPES = []string{"container", "rubygems", "maven", "npm", "nuget"}
+var SUPPORTED_PACKAGE_TYPES = []string{"container", "docker", "rubygems", "maven", "npm", "nuget"}
 
 const ARE_YOU_SURE_YOU_EXPORTED = "Are you sure you exported first? gh migrate-packages export --help"
 
 type Report struct {
-	PackageSuccess     int
-	VersionSuccess     int
-	FileSuccess        int
-	PackagesSkipped    int
-	VersionsSkipped    int
-	FilesSkipped       int
-	PackagesFailed     int
-	VersionsFailed     int
-	FilesFailed        int
-	PackagesByType     map[string]int
+	PackageSuccess  int
+	VersionSuccess  int
+	FileSuccess     int
+	PackagesSkipped int
+	VersionsSkipped int
+	FilesSkipped    int
+	PackagesFailed  int
+	VersionsFailed  int
+	FilesFailed     int
+	PackagesByType  map[string]int
+	// PackageStatesByType counts packages per package type and result state
+	PackageStatesByType map[string]map[providers.ResultState]int
+	// VersionsWithoutFiles counts versions that exist but have no files to migrate
+	VersionsWithoutFiles int
+	// SkipReasons counts the skipped items by the reason they were skipped for
+	SkipReasons map[providers.SkipReason]int
+	// Verifications counts the uploaded files by the outcome of reading them back from the target
+	Verifications map[string]int
+	// Items lists the result of every file (or version and package, when
+	// processing stopped before reaching its files)
+	Items              []Item `json:"-"`
 	currentPackageType string
+	mu                 sync.Mutex
+}
+
+// Item is the result of processing a single file, version or package
+type Item struct {
+	Organization string                `json:"organization"`
+	Repository   string                `json:"repository"`
+	PackageType  string                `json:"package_type"`
+	PackageName  string                `json:"package_name"`
+	Version      string                `json:"version,omitempty"`
+	Filename     string                `json:"filename,omitempty"`
+	State        providers.ResultState `json:"state"`
+	SkipReason   providers.SkipReason  `json:"skip_reason,omitempty"`
+	Verification string                `json:"verification,omitempty"`
+	UploadDigest string                `json:"upload_digest,omitempty"`
+	Error        string                `json:"error,omitempty"`
+}
+
+// Outcomes of reading an uploaded file back from the target
+const (
+	Verified         = "verified"
+	VerifyMismatch   = "mismatch"
+	VerifyUnverified = "unverified"
+)
+
+// NewItem describes the result of processing a file, err may be nil. A
+// providers.SkipError marks the item skipped with its reason.
+func NewItem(owner, repository, packageType, packageName, version, filename string, result providers.ResultState, err error) Item {
+	item := Item{
+		Organization: owner,
+		Repository:   repository,
+		PackageType:  packageType,
+		PackageName:  packageName,
+		Version:      version,
+		Filename:     filename,
+		State:        result,
+	}
+	var skip *providers.SkipError
+	if errors.As(err, &skip) {
+		item.State = providers.Skipped
+		item.SkipReason = skip.Reason
+	} else if err != nil {
+		item.Error = err.Error()
+	}
+	return item
 }
 
 func NewReport() *Report {
@@ -41,10 +105,17 @@ func NewReport() *Report {
 		VersionsFailed:  0,
 		FilesFailed:     0,
 		PackagesByType:  make(map[string]int),
+
+		PackageStatesByType: make(map[string]map[providers.ResultState]int),
+		SkipReasons:         make(map[providers.SkipReason]int),
+		Verifications:       make(map[string]int),
 	}
 }
 
 func (r *Report) Print(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	pterm.Info.Printf("%s Report\n", name)
 	pterm.Info.Println("Total Packages:", r.PackageSuccess+r.PackagesSkipped+r.PackagesFailed)
 	pterm.Info.Println("Total Versions:", r.VersionSuccess+r.VersionsSkipped+r.VersionsFailed)
@@ -61,6 +132,16 @@ func (r *Report) Print(name string) {
 }
 
 func (r *Report) IncPackages(result providers.ResultState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if packageType := r.currentPackageType; packageType != "" {
+		if r.PackageStatesByType[packageType] == nil {
+			r.PackageStatesByType[packageType] = make(map[providers.ResultState]int)
+		}
+		r.PackageStatesByType[packageType][result]++
+	}
+
 	switch result {
 	case providers.Success:
 		r.PackageSuccess++
@@ -75,6 +156,9 @@ func (r *Report) IncPackages(result providers.ResultState) {
 }
 
 func (r *Report) IncVersions(result providers.ResultState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	switch result {
 	case providers.Success:
 		r.VersionSuccess++
@@ -86,6 +170,9 @@ func (r *Report) IncVersions(result providers.ResultState) {
 }
 
 func (r *Report) IncFiles(result providers.ResultState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	switch result {
 	case providers.Success:
 		r.FileSuccess++
@@ -96,6 +183,158 @@ func (r *Report) IncFiles(result providers.ResultState) {
 	}
 }
 
+// Merge adds the counters of another report to this one
+func (r *Report) Merge(other *Report) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.PackageSuccess += other.PackageSuccess
+	r.VersionSuccess += other.VersionSuccess
+	r.FileSuccess += other.FileSuccess
+	r.PackagesSkipped += other.PackagesSkipped
+	r.VersionsSkipped += other.VersionsSkipped
+	r.FilesSkipped += other.FilesSkipped
+	r.PackagesFailed += other.PackagesFailed
+	r.VersionsFailed += other.VersionsFailed
+	r.FilesFailed += other.FilesFailed
+	r.VersionsWithoutFiles += other.VersionsWithoutFiles
+	r.Items = append(r.Items, other.Items...)
+	for reason, count := range other.SkipReasons {
+		r.SkipReasons[reason] += count
+	}
+	for verification, count := range other.Verifications {
+		r.Verifications[verification] += count
+	}
+	for packageType, count := range other.PackagesByType {
+		r.PackagesByType[packageType] += count
+	}
+	for packageType, states := range other.PackageStatesByType {
+		if r.PackageStatesByType[packageType] == nil {
+			r.PackageStatesByType[packageType] = make(map[providers.ResultState]int)
+		}
+		for result, count := range states {
+			r.PackageStatesByType[packageType][result] += count
+		}
+	}
+}
+
+// AddItem records the result of a single file, version or package
+func (r *Report) AddItem(item Item) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Items = append(r.Items, item)
+	if item.SkipReason != "" {
+		r.SkipReasons[item.SkipReason]++
+	}
+	if item.Verification != "" {
+		r.Verifications[item.Verification]++
+	}
+}
+
+// PrintVerifications lists how many uploaded files were read back from the target
+func (r *Report) PrintVerifications() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.Verifications) == 0 {
+		return
+	}
+	fmt.Printf("🔐 Verified on target: %d\n", r.Verifications[Verified])
+	if count := r.Verifications[VerifyMismatch]; count > 0 {
+		fmt.Printf("  ❌ content differs from the upload: %d\n", count)
+	}
+	if count := r.Verifications[VerifyUnverified]; count > 0 {
+		fmt.Printf("  ⚠️  could not be read back: %d\n", count)
+	}
+}
+
+// PrintUploadDigests lists how many files were uploaded with digest headers,
+// and how many of them the target registry validated
+func (r *Report) PrintUploadDigests() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, item := range r.Items {
+		if item.UploadDigest != "" {
+			counts[item.UploadDigest]++
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Printf("🧾 Uploaded with digest headers: %d (validated by the target: %d)\n", counts[providers.DigestValidated]+counts[providers.DigestSent], counts[providers.DigestValidated])
+}
+
+// PrintSkipReasons lists the skipped items by reason. Missing local files mean
+// data was not migrated, they are called out instead of passing as benign skips.
+func (r *Report) PrintSkipReasons() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.SkipReasons) == 0 {
+		return
+	}
+	var reasons []string
+	for reason := range r.SkipReasons {
+		reasons = append(reasons, string(reason))
+	}
+	sort.Strings(reasons)
+	fmt.Println("⏭️  Skipped by reason:")
+	for _, reason := range reasons {
+		count := r.SkipReasons[providers.SkipReason(reason)]
+		if providers.SkipReason(reason) == providers.SkipLocalFilesMissing {
+			fmt.Printf("  ⚠️  %s: %d (not migrated, pull them first)\n", reason, count)
+			continue
+		}
+		fmt.Printf("  %s: %d\n", reason, count)
+	}
+}
+
+// RecordFile counts a file result and records it as an item
+func (r *Report) RecordFile(item Item) {
+	r.IncFiles(item.State)
+	r.AddItem(item)
+}
+
+// SetPackageType sets the package type subsequent IncPackages calls are counted under
+func (r *Report) SetPackageType(packageType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.currentPackageType = packageType
+}
+
+// IncVersionsWithoutFiles counts a version that has no files to migrate
+func (r *Report) IncVersionsWithoutFiles() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.VersionsWithoutFiles++
+}
+
+// GetPackagesByTypeAndState returns the number of packages of a type that ended in the given state
+func (r *Report) GetPackagesByTypeAndState(packageType string, result providers.ResultState) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.PackageStatesByType[packageType][result]
+}
+
+// FilterByRepository returns the inventory rows of packages linked to one of the
+// repositories, or every row when there are none
+func FilterByRepository(packages [][]string, repositories []string) [][]string {
+	if len(repositories) == 0 {
+		return packages
+	}
+	var rows [][]string
+	for _, row := range packages {
+		if len(row) > 1 && MatchRepository(repositories, row[1]) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
 type ProcessCallback func(
 	logger *zap.Logger,
 	provider providers.Provider,
@@ -106,131 +345,477 @@ type ProcessCallback func(
 	version string,
 	filenames []string) error
 
-func ProcessPackages(logger *zap.Logger, packages [][]string, fn ProcessCallback, skipIfExists bool) (*Report, error) {
+// processRun carries the settings shared by every package of a ProcessPackages run
+type processRun struct {
+	logger       *zap.Logger
+	inventory    *inventoryIndex
+	fn           ProcessCallback
+	skipIfExists bool
+	// existingPolicy decides what happens to a package already on the target
+	existingPolicy string
+	phase          string
+	checkpoint     *state.Store
+	// store records the status of every file in the SQLite inventory, nil with the CSV one
+	store     *inventory.Store
+	resume    bool
+	retrying  bool
+	report    *Report
+	providers *providers.ProviderSet
+	// warmup paces the first uploads into the target, nil when disabled
+	warmup *warmup
+	// errors backs off from a registry failing most operations, nil when disabled
+	errors *errorRates
+	// transfer moves packages to other repositories of their organization
+	transfer bool
+	// packages and files are the totals the phase processes, for the progress socket
+	packages int
+	files    int
+	started  time.Time
+	// bars draw the progress of the phase in the terminal, nil when disabled
+	bars *progressBars
+}
+
+// ProcessPackages calls fn for every package version in the inventory. Up to
+// GHMPKG_CONCURRENCY packages are processed in parallel, at most
+// GHMPKG_<TYPE>_CONCURRENCY of them of a package type, the versions of a
+// package are always processed in order. Completed files are checkpointed under
+// the given phase so a run started with GHMPKG_RESUME picks up where the
+// previous one stopped. With GHMPKG_RETRY_FAILED only the entries that failed in
+// the given report of a previous run of the phase are processed.
+func ProcessPackages(logger *zap.Logger, packages [][]string, fn ProcessCallback, skipIfExists bool, phase string) (*Report, error) {
 	report := NewReport()
-	desiredPackageType := viper.GetString("GHMPKG_PACKAGE_TYPE")
-	var provider providers.Provider
+	desiredPackageTypes, err := PackageTypeFilter()
+	if err != nil {
+		return report, err
+	}
+	desiredRepositories := DesiredRepositories()
+
+	migrationPath := utils.MigrationPath()
+	checkpoint, err := state.Load(migrationPath)
+	if err != nil {
+		return report, err
+	}
+	store, err := OpenStore(migrationPath)
+	if err != nil {
+		return report, err
+	}
+	resume := viper.GetBool("GHMPKG_RESUME")
+	existingPolicy, err := ExistingPackagePolicy()
+	if err != nil {
+		return report, err
+	}
+
+	// Shards are dealt from the whole inventory, before a retry narrows it
+	shard, err := ParseShard()
+	if err != nil {
+		return report, err
+	}
+	if shard.Count > 1 {
+		splitTags, err := ShardSplitTags()
+		if err != nil {
+			return report, err
+		}
+		rows := shard.Filter(packages, splitTags)
+		logger.Info("Processing shard",
+			zap.String("shard", shard.String()),
+			zap.Int("splitTags", splitTags),
+			zap.Int("rows", len(rows)),
+			zap.Int("inventoryRows", len(packages)))
+		pterm.Info.Printf("🧩 Processing shard %s: %d of %d files\n", shard, len(rows), len(packages))
+		if split := SplitPackages(packages, splitTags); len(split) > 0 {
+			pterm.Info.Printf("🧩 %d container packages with more than %d tags are split across the shards by digest\n", len(split), splitTags)
+			if phase == "sync" && existingPolicy == ExistingSkip {
+				pterm.Warning.Println("⚠️  With --existing-packages skip, a shard skips the packages split across shards once another shard created them")
+			}
+		}
+		packages = rows
+	}
+
+	retryPath := viper.GetString("GHMPKG_RETRY_FAILED")
+	if retryPath != "" {
+		rows, failedItems, err := FilterFailed(packages, retryPath, phase)
+		if err != nil {
+			return report, err
+		}
+		logger.Info("Retrying failed entries",
+			zap.String("report", retryPath),
+			zap.Int("failedItems", failedItems),
+			zap.Int("rows", len(rows)))
+		pterm.Info.Printf("🔁 Retrying %d failed entries (%d files) from %s\n", failedItems, len(rows), retryPath)
+		packages = rows
+	}
+
+	critical, err := NewCriticalPackages()
+	if err != nil {
+		return report, err
+	}
+
+	// Skip the package types the server of this phase has no registry for
+	side := "source"
+	if phase == "sync" {
+		side = "target"
+	}
+	var packageTypes []string
+	for _, row := range packages {
+		if len(row) > 2 && !utils.Contains(packageTypes, row[2]) {
+			packageTypes = append(packageTypes, row[2])
+		}
+	}
+	if supported := SupportedPackageTypes(logger, side, packageTypes); len(supported) < len(packageTypes) {
+		var rows [][]string
+		for _, row := range packages {
+			if utils.Contains(supported, row[2]) {
+				rows = append(rows, row)
+			}
+		}
+		packages = rows
+	}
+
+	// A retry only touches failed entries, keep what the previous run completed
+	if !resume && retryPath == "" {
+		if err := checkpoint.Reset(phase); err != nil {
+			return report, err
+		}
+		if store != nil {
+			if err := store.ResetStatuses(phase); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	// The files completed under a snapshot belong to its export, not to another one
+	snapshot, err := Snapshot()
+	if err != nil {
+		return report, err
+	}
+	if recorded := checkpoint.Snapshot(phase); resume && recorded != "" && recorded != snapshot {
+		return report, fmt.Errorf("the interrupted %s operated on snapshot %s, resume it with --snapshot %s", phase, recorded, recorded)
+	}
+	if err := checkpoint.SetSnapshot(phase, snapshot); err != nil {
+		return report, err
+	}
+
+	concurrency := viper.GetInt("GHMPKG_CONCURRENCY")
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var pacing *warmup
+	if phase == "sync" {
+		if pacing, err = newWarmup(concurrency); err != nil {
+			return report, err
+		}
+		if pacing != nil {
+			pterm.Info.Printf("🔥 Warming up the target: the first %d versions start one at a time, %s apart, ramping up to a concurrency of %d\n", pacing.Operations, pacing.Interval, concurrency)
+		}
+	}
+
+	rates, err := newErrorRates(logger)
+	if err != nil {
+		return report, err
+	}
+
+	run := &processRun{
+		logger:         logger,
+		inventory:      newInventoryIndex(packages),
+		fn:             fn,
+		skipIfExists:   skipIfExists,
+		existingPolicy: existingPolicy,
+		phase:          phase,
+		checkpoint:     checkpoint,
+		store:          store,
+		resume:         resume,
+		retrying:       retryPath != "",
+		report:         report,
+		providers:      providers.NewProviderSet(),
+		warmup:         pacing,
+		errors:         rates,
+		transfer:       skipIfExists && providers.Transferring(),
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		fatalErr error
+		aborted  atomic.Bool
+	)
+	sem := make(chan struct{}, concurrency)
+	typeSems := typeSemaphores(concurrency)
+	process := func(pkg []string) {
+		if err := run.processPackage(pkg); err != nil {
+			errOnce.Do(func() {
+				fatalErr = err
+				aborted.Store(true)
+			})
+		}
+	}
 
 	pkgs := utils.GetListOfUniqueEntries(packages, []int{0, 1, 2, 3})
+	// Cutover blocking packages go first instead of waiting behind the others
+	pkgs, criticalCount := critical.Prioritize(pkgs)
+	if criticalCount > 0 {
+		logger.Info("Processing critical packages first", zap.Int("criticalPackages", criticalCount), zap.Strings("patterns", critical.Patterns))
+		pterm.Info.Printf("🚨 Processing %d critical packages first\n", criticalCount)
+	}
+
+	for _, pkg := range pkgs {
+		if utils.Contains(desiredPackageTypes, pkg[2]) && MatchRepository(desiredRepositories, pkg[1]) {
+			run.packages++
+			for _, version := range run.inventory.Versions(pkg[0], pkg[1], pkg[2], pkg[3]) {
+				run.files += len(run.inventory.Files(pkg[0], pkg[1], pkg[2], pkg[3], version))
+			}
+		}
+	}
+	run.publishPhase(progress.PhaseStarted)
+	defer run.publishPhase(progress.PhaseFinished)
+
+	var processed [][]string
+	for _, row := range packages {
+		if len(row) > 2 && utils.Contains(desiredPackageTypes, row[2]) && MatchRepository(desiredRepositories, row[1]) {
+			processed = append(processed, row)
+		}
+	}
+	run.bars = newProgressBars(phase, run.packages, processed)
+	defer run.bars.stop()
 
 	for i, pkg := range pkgs {
+		if aborted.Load() {
+			break
+		}
+
 		logger.Info("Processing package", zap.Int("index", i), zap.String("org", pkg[0]), zap.String("repo", pkg[1]), zap.String("type", pkg[2]), zap.String("name", pkg[3]))
 
-		owner := pkg[0]
 		repository := pkg[1]
 		packageType := pkg[2]
 		packageName := pkg[3]
 
-		if desiredPackageType != "" && packageType != desiredPackageType {
+		if !utils.Contains(desiredPackageTypes, packageType) {
 			continue
 		}
 
 		// Filter by repository if specified
-		desiredRepository := viper.GetString("GHMPKG_REPOSITORY")
-		if desiredRepository != "" && repository != desiredRepository {
+		if !MatchRepository(desiredRepositories, repository) {
 			logger.Info("Skipping package due to repository filter",
 				zap.String("repository", repository),
-				zap.String("desiredRepository", desiredRepository),
+				zap.Strings("desiredRepositories", desiredRepositories),
 				zap.String("packageName", packageName))
 			continue
 		}
 
-		if provider == nil || provider.GetPackageType() != packageType {
-			logger.Info("Creating provider", zap.String("packageType", packageType))
-			var err error
-			provider, err = providers.NewProvider(logger, packageType)
-			if err != nil {
-				logger.Error("Error creating provider", zap.Error(err))
-				report.IncPackages(providers.Failed)
-				return report, err
-			}
+		if typeSem := typeSems[limitedType(packageType)]; typeSem != nil {
+			// Limited package types wait for a slot of their own without holding
+			// up other packages
+			wg.Add(1)
+			go func(pkg []string) {
+				defer wg.Done()
+				typeSem <- struct{}{}
+				defer func() { <-typeSem }()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				if !aborted.Load() {
+					process(pkg)
+				}
+			}(pkg)
+			continue
+		}
 
-			if provider == nil {
-				logger.Error("Provider is nil")
-				report.IncPackages(providers.Failed)
-				return report, fmt.Errorf("provider is nil")
-			}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(pkg []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
 
-			if err = provider.Connect(logger); err != nil {
-				logger.Error("Error connecting to provider", zap.Error(err))
-				report.IncPackages(providers.Failed)
-				return report, err
-			}
-		}
+			process(pkg)
+		}(pkg)
+	}
 
-		// Only check on upload
-		if skipIfExists {
-			exists, err := api.PackageExists(packageName, packageType)
-			if err != nil {
-				logger.Error("Error checking if package exists", zap.Error(err))
-				report.IncPackages(providers.Failed)
-				return report, err
-			}
+	wg.Wait()
 
-			if exists {
-				report.IncPackages(providers.Skipped)
-				logger.Info("Package already exists, skipping...", zap.String("package", packageName))
-				continue
-			}
+	// The completed files and the digests recorded while processing are
+	// journaled, fold them into the state file and checksum ledger once for the
+	// whole run
+	if err := checkpoint.Save(); err != nil {
+		logger.Warn("Failed to save checkpoint", zap.Error(err))
+	}
+	if store, err := ledger.Load(migrationPath); err != nil {
+		logger.Warn("Failed to load checksum ledger", zap.Error(err))
+	} else if err := store.Save(); err != nil {
+		logger.Warn("Failed to save checksum ledger", zap.Error(err))
+	}
+	return report, fatalErr
+}
+
+// limitedType is the package type whose GHMPKG_<TYPE>_CONCURRENCY limits the
+// packages of a type. Images share the Docker daemon's storage, the container
+// limit applies to both kinds of images.
+func limitedType(packageType string) string {
+	if providers.IsImage(packageType) {
+		return "container"
+	}
+	return packageType
+}
+
+// typeSemaphores returns the semaphores of the package types processed fewer
+// at once than the concurrency of the run
+func typeSemaphores(concurrency int) map[string]chan struct{} {
+	sems := make(map[string]chan struct{})
+	for _, packageType := range SUPPORTED_PACKAGE_TYPES {
+		if limitedType(packageType) != packageType {
+			continue
+		}
+		if limit := viper.GetInt(utils.PackageTypeKey("GHMPKG_CONCURRENCY", packageType)); limit > 0 && limit < concurrency {
+			sems[packageType] = make(chan struct{}, limit)
 		}
+	}
+	return sems
+}
 
-		report.currentPackageType = packageType
+// finishPackage records the result of a package that stopped before its versions
+// were processed. It goes through a package report like every other package, so
+// it is counted under its package type.
+func (run *processRun) finishPackage(item Item) {
+	for _, version := range run.inventory.Versions(item.Organization, item.Repository, item.PackageType, item.PackageName) {
+		for _, filename := range run.inventory.Files(item.Organization, item.Repository, item.PackageType, item.PackageName, version) {
+			run.bars.done(false, state.Key(item.Organization, item.Repository, item.PackageType, item.PackageName, version, filename))
+		}
+	}
+	packageReport := NewReport()
+	packageReport.SetPackageType(item.PackageType)
+	packageReport.IncPackages(item.State)
+	packageReport.AddItem(item)
+	run.publishItems(packageReport.Items)
+	run.mergePackage(item.Organization, item.Repository, item.PackageType, item.PackageName, packageReport)
+}
 
-		versionFilters := map[string]string{
-			"0": owner,       // org
-			"1": repository,  // repo
-			"2": packageType, // package type
-			"3": packageName, // package name
+// processPackage processes every version of a single package. Results are
+// collected in a package report merged into the run report once done, so
+// concurrently processed packages don't interfere with each other's status.
+func (run *processRun) processPackage(pkg []string) error {
+	logger := run.logger
+	owner := pkg[0]
+	repository := pkg[1]
+	packageType := pkg[2]
+	packageName := pkg[3]
+
+	provider, err := run.providers.Get(logger, packageType)
+	if err != nil {
+		logger.Error("Error creating provider", zap.Error(err))
+		run.finishPackage(NewItem(owner, repository, packageType, packageName, "", "", providers.Failed, err))
+		return err
+	}
+
+	var existing *targetVersions
+	var skip providers.SkipReason
+	if run.transfer {
+		existing, skip, err = run.prepareTransfer(owner, repository, packageType, packageName)
+	} else {
+		var exists bool
+		if existing, exists, err = run.checkExisting(owner, repository, packageType, packageName); exists {
+			skip = providers.SkipPackageExistsOnTarget
 		}
-		versions := utils.GetFlatListOfColumn(packages, versionFilters, 4)
+	}
+	if err != nil {
+		run.finishPackage(NewItem(owner, repository, packageType, packageName, "", "", providers.Failed, err))
+		return err
+	}
+	if skip != "" {
+		run.finishPackage(NewItem(owner, repository, packageType, packageName, "", "", providers.Skipped, &providers.SkipError{Reason: skip}))
+		return nil
+	}
 
-		versionsSkipped := report.VersionsSkipped
-		versionsFailed := report.VersionsFailed
-		for i := len(versions) - 1; i >= 0; i-- {
-			version := versions[i]
-			fileFilters := map[string]string{
-				"0": owner,
-				"1": repository,
-				"2": packageType,
-				"3": packageName,
-				"4": version,
+	packageReport := NewReport()
+	packageReport.SetPackageType(packageType)
+
+	versions := run.inventory.Versions(owner, repository, packageType, packageName)
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		versionReport := NewReport()
+
+		var filenames, completedKeys []string
+		for _, filename := range run.inventory.Files(owner, repository, packageType, packageName, version) {
+			key := state.Key(owner, repository, packageType, packageName, version, filename)
+			if run.resume && run.completed(key) {
+				versionReport.RecordFile(NewItem(owner, repository, packageType, packageName, version, filename, providers.Skipped, &providers.SkipError{Reason: providers.SkipCompletedInPreviousRun}))
+				run.bars.done(false, key)
+				continue
+			}
+			if existing != nil && existing.Has(packageType, version, filename) {
+				versionReport.RecordFile(NewItem(owner, repository, packageType, packageName, version, filename, providers.Skipped, &providers.SkipError{Reason: providers.SkipExistsOnTarget}))
+				run.bars.done(false, key)
+				continue
 			}
-			filenames := utils.GetFlatListOfColumn(packages, fileFilters, 5)
-			filesSkipped := report.FilesSkipped
-			filesFailed := report.FilesFailed
-			err := fn(logger, provider, report, repository, packageType, packageName, version, filenames)
-			if err != nil {
-				logger.Error("Error processing version",
-					zap.String("package", packageName),
-					zap.String("version", version),
-					zap.Error(err))
-				report.IncVersions(providers.Failed)
-				continue // Skip this version but continue with others
+			filenames = append(filenames, filename)
+			completedKeys = append(completedKeys, key)
+		}
+		if len(filenames) == 0 {
+			logger.Info("Version already completed or on target, skipping...",
+				zap.String("package", packageName),
+				zap.String("version", version))
+			versionReport.IncVersions(providers.Skipped)
+			run.mergeVersion(packageReport, versionReport)
+			continue
+		}
+
+		if err := run.errors.wait(packageType); err != nil {
+			run.mergePackage(owner, repository, packageType, packageName, packageReport)
+			return err
+		}
+		run.warmup.acquire()
+		run.bars.start(packageName, version, filenames)
+		err := run.fn(logger, provider, versionReport, repository, packageType, packageName, version, filenames)
+		run.bars.done(true, completedKeys...)
+		run.warmup.release()
+		run.errors.record(packageType, err != nil || versionReport.FilesFailed > 0)
+		run.recordStatuses(versionReport, completedKeys, err)
+		if err != nil {
+			logger.Error("Error processing version",
+				zap.String("package", packageName),
+				zap.String("version", version),
+				zap.Error(err))
+			versionReport.IncVersions(providers.Failed)
+			// Keep the error when the callback failed before recording its files
+			if versionReport.FilesFailed == 0 {
+				versionReport.AddItem(NewItem(owner, repository, packageType, packageName, version, "", providers.Failed, err))
 			}
+			run.mergeVersion(packageReport, versionReport)
+			continue // Skip this version but continue with others
+		}
 
-			if report.FilesFailed > filesFailed {
-				report.IncVersions(providers.Failed)
-			} else if report.FilesSkipped > filesSkipped {
-				report.IncVersions(providers.Skipped)
-			} else {
-				report.IncVersions(providers.Success)
+		if versionReport.FilesFailed == 0 {
+			if err := run.checkpoint.MarkCompleted(run.phase, completedKeys...); err != nil {
+				logger.Warn("Failed to save checkpoint", zap.Error(err))
 			}
 		}
 
-		// Determine package status based on version results
-		if report.VersionsFailed > versionsFailed {
-			report.IncPackages(providers.Failed)
-		} else if report.VersionsSkipped > versionsSkipped {
-			report.IncPackages(providers.Skipped)
+		if versionReport.FilesFailed > 0 {
+			versionReport.IncVersions(providers.Failed)
+		} else if versionReport.FilesSkipped > 0 {
+			versionReport.IncVersions(providers.Skipped)
 		} else {
-			report.IncPackages(providers.Success)
+			versionReport.IncVersions(providers.Success)
 		}
+		run.mergeVersion(packageReport, versionReport)
 	}
 
-	return report, nil
+	// Determine package status based on version results
+	if packageReport.VersionsFailed > 0 {
+		packageReport.IncPackages(providers.Failed)
+	} else if packageReport.VersionsSkipped > 0 {
+		packageReport.IncPackages(providers.Skipped)
+	} else {
+		packageReport.IncPackages(providers.Success)
+	}
+	run.mergePackage(owner, repository, packageType, packageName, packageReport)
+
+	return nil
 }
 
 func (r *Report) GetPackages(state providers.ResultState) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	switch state {
 	case providers.Success:
 		return r.PackageSuccess
@@ -244,6 +829,8 @@ func (r *Report) GetPackages(state providers.ResultState) int {
 }
 
 func (r *Report) GetPackage(state providers.ResultState) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	switch state {
 	case providers.Success:
 		return r.PackageSuccess
@@ -257,13 +844,19 @@ func (r *Report) GetPackage(state providers.ResultState) int {
 }
 
 func (r *Report) GetTotalSuccess() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.PackageSuccess
 }
 
 func (r *Report) GetSuccessByType(packageType string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.PackagesByType[packageType]
 }
 
 func (r *Report) GetTotalFailures() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.PackagesFailed
 }
@@ -0,0 +1,29 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+)
+
+func TestReportSkipReasons(t *testing.T) {
+	_, skip := providers.Skip(providers.SkipLocalFilesMissing, "packages/npm/app/1.0.0 not found")
+	item := NewItem("mona-actions", "app", "npm", "app", "1.0.0", "app-1.0.0.tgz", providers.Skipped, skip)
+	if item.State != providers.Skipped || item.SkipReason != providers.SkipLocalFilesMissing || item.Error != "" {
+		t.Fatalf("NewItem with skip = %+v", item)
+	}
+
+	versionReport := NewReport()
+	versionReport.RecordFile(item)
+	versionReport.RecordFile(NewItem("mona-actions", "app", "npm", "app", "1.0.1", "app-1.0.1.tgz", providers.Skipped, &providers.SkipError{Reason: providers.SkipExistsOnTarget}))
+	versionReport.RecordFile(NewItem("mona-actions", "app", "npm", "app", "1.0.2", "app-1.0.2.tgz", providers.Success, nil))
+
+	report := NewReport()
+	report.Merge(versionReport)
+	if report.FilesSkipped != 2 || report.FileSuccess != 1 {
+		t.Fatalf("files skipped = %d, succeeded = %d, want 2 and 1", report.FilesSkipped, report.FileSuccess)
+	}
+	if report.SkipReasons[providers.SkipLocalFilesMissing] != 1 || report.SkipReasons[providers.SkipExistsOnTarget] != 1 {
+		t.Fatalf("SkipReasons = %v", report.SkipReasons)
+	}
+}
@@ -0,0 +1,28 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestTypeSemaphores(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_CONTAINER_CONCURRENCY", 2)
+	viper.Set("GHMPKG_NPM_CONCURRENCY", 1)
+	viper.Set("GHMPKG_MAVEN_CONCURRENCY", 8)
+	viper.Set("GHMPKG_DOCKER_CONCURRENCY", 1)
+
+	sems := typeSemaphores(4)
+	if cap(sems["container"]) != 2 || cap(sems["npm"]) != 1 {
+		t.Errorf("container and npm limits = %d and %d, want 2 and 1", cap(sems["container"]), cap(sems["npm"]))
+	}
+	// A limit at or above the concurrency of the run limits nothing
+	if sems["maven"] != nil {
+		t.Error("maven got a limit above the concurrency of the run")
+	}
+	// Docker images take the slots of container images
+	if sems["docker"] != nil || limitedType("docker") != "container" {
+		t.Error("docker images have a limit of their own")
+	}
+}
@@ -0,0 +1,150 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+)
+
+// Statuses of a run in its JSON report
+const (
+	// StatusSuccess: nothing failed
+	StatusSuccess = "success"
+	// StatusPassed: entries failed, within the success criteria
+	StatusPassed = "passed"
+	// StatusPartial: entries failed and no success criteria are set
+	StatusPartial = "partial"
+	// StatusFailed: the run did not meet its success criteria
+	StatusFailed = "failed"
+	// StatusError: the run stopped on an error
+	StatusError = "error"
+)
+
+// Threshold is the number of failures a run tolerates, or with Percent the
+// share of the processed entries
+type Threshold struct {
+	Value   float64
+	Percent bool
+}
+
+func (t Threshold) String() string {
+	value := strconv.FormatFloat(t.Value, 'f', -1, 64)
+	if t.Percent {
+		return value + "%"
+	}
+	return value
+}
+
+// exceeded reports whether failed of processed entries is more than the threshold
+func (t Threshold) exceeded(failed, processed int) bool {
+	if t.Percent {
+		return processed > 0 && float64(failed)*100/float64(processed) > t.Value
+	}
+	return float64(failed) > t.Value
+}
+
+// parseThreshold reads a threshold such as 1% or 10
+func parseThreshold(key, flag string) (*Threshold, error) {
+	value := strings.TrimSpace(viper.GetString(key))
+	if value == "" {
+		return nil, nil
+	}
+	number, percent := strings.CutSuffix(value, "%")
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || parsed < 0 || (percent && parsed > 100) || (!percent && parsed != float64(int(parsed))) {
+		return nil, fmt.Errorf("invalid --%s %q, expected a number of failures such as 10 or a share such as 1%%", flag, value)
+	}
+	return &Threshold{Value: parsed, Percent: percent}, nil
+}
+
+// SuccessCriteria decide whether a run that completed with failures passed,
+// from GHMPKG_MAX_FAILED_FILES, GHMPKG_MAX_FAILED_PACKAGES and
+// GHMPKG_FAIL_ON_CRITICAL
+type SuccessCriteria struct {
+	MaxFailedFiles    *Threshold
+	MaxFailedPackages *Threshold
+	// FailOnCritical fails the run when a package of GHMPKG_CRITICAL failed
+	FailOnCritical bool
+	critical       *CriticalPackages
+}
+
+// NewSuccessCriteria reads the success criteria, failing on invalid values
+func NewSuccessCriteria() (*SuccessCriteria, error) {
+	criteria := &SuccessCriteria{FailOnCritical: viper.GetBool("GHMPKG_FAIL_ON_CRITICAL")}
+	var err error
+	if criteria.MaxFailedFiles, err = parseThreshold("GHMPKG_MAX_FAILED_FILES", "max-failed-files"); err != nil {
+		return nil, err
+	}
+	if criteria.MaxFailedPackages, err = parseThreshold("GHMPKG_MAX_FAILED_PACKAGES", "max-failed-packages"); err != nil {
+		return nil, err
+	}
+	if criteria.FailOnCritical {
+		if criteria.critical, err = NewCriticalPackages(); err != nil {
+			return nil, err
+		}
+		if criteria.critical.IsEmpty() {
+			return nil, fmt.Errorf("--fail-on-critical needs the critical packages, set --critical or --critical-file")
+		}
+	}
+	return criteria, nil
+}
+
+// IsEmpty reports whether no success criteria are set, any failure then
+// makes the run partial
+func (c *SuccessCriteria) IsEmpty() bool {
+	return c.MaxFailedFiles == nil && c.MaxFailedPackages == nil && !c.FailOnCritical
+}
+
+// violations lists the criteria the report does not meet
+func (c *SuccessCriteria) violations(r *Report) []string {
+	var violations []string
+	if t := c.MaxFailedFiles; t != nil && t.exceeded(r.FilesFailed, r.FileSuccess+r.FilesFailed) {
+		violations = append(violations, fmt.Sprintf("%d of %d files failed, more than %s", r.FilesFailed, r.FileSuccess+r.FilesFailed, t))
+	}
+	if t := c.MaxFailedPackages; t != nil && t.exceeded(r.PackagesFailed, r.PackageSuccess+r.PackagesFailed) {
+		violations = append(violations, fmt.Sprintf("%d of %d packages failed, more than %s", r.PackagesFailed, r.PackageSuccess+r.PackagesFailed, t))
+	}
+	if c.FailOnCritical {
+		failed := make(map[string]bool)
+		for _, item := range r.Items {
+			if item.State == providers.Failed && c.critical.Match(item.PackageType, item.PackageName) {
+				failed[item.PackageType+"/"+item.PackageName] = true
+			}
+		}
+		names := make([]string, 0, len(failed))
+		for name := range failed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			violations = append(violations, fmt.Sprintf("critical package %s failed", name))
+		}
+	}
+	return violations
+}
+
+// Evaluate returns the status of the report against the success criteria and
+// the criteria it does not meet
+func (r *Report) Evaluate() (string, []string, error) {
+	criteria, err := NewSuccessCriteria()
+	if err != nil {
+		return "", nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.PackagesFailed+r.VersionsFailed+r.FilesFailed == 0 {
+		return StatusSuccess, nil, nil
+	}
+	if criteria.IsEmpty() {
+		return StatusPartial, nil, nil
+	}
+	if violations := criteria.violations(r); len(violations) > 0 {
+		return StatusFailed, violations, nil
+	}
+	return StatusPassed, nil, nil
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+)
+
+func TestParseThreshold(t *testing.T) {
+	defer viper.Set("GHMPKG_MAX_FAILED_FILES", "")
+	for value, want := range map[string]Threshold{"10": {10, false}, "1%": {1, true}, " 0.5 % ": {0.5, true}} {
+		viper.Set("GHMPKG_MAX_FAILED_FILES", value)
+		threshold, err := parseThreshold("GHMPKG_MAX_FAILED_FILES", "max-failed-files")
+		if err != nil || threshold == nil || *threshold != want {
+			t.Errorf("parseThreshold(%q) = %v, %v, want %v", value, threshold, err, want)
+		}
+	}
+	for _, value := range []string{"-1", "1.5", "150%", "some"} {
+		viper.Set("GHMPKG_MAX_FAILED_FILES", value)
+		if _, err := parseThreshold("GHMPKG_MAX_FAILED_FILES", "max-failed-files"); err == nil {
+			t.Errorf("parseThreshold(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+// criteriaReport has 200 files of 100 packages, the given number of them failed
+func criteriaReport(failed int) *Report {
+	report := NewReport()
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("app-%d", i)
+		state := providers.Success
+		if i < failed {
+			state = providers.Failed
+		}
+		report.IncPackages(state)
+		report.RecordFile(NewItem("mona-actions", "repo", "npm", name, "1.0.0", name+"-1.0.0.tgz", state, nil))
+		report.RecordFile(NewItem("mona-actions", "repo", "npm", name, "1.0.0", name+"-1.0.0.pom", providers.Success, nil))
+	}
+	return report
+}
+
+func TestEvaluate(t *testing.T) {
+	defer viper.Reset()
+
+	tests := []struct {
+		name                    string
+		maxFiles, maxPackages   string
+		critical                string
+		failed                  int
+		want                    string
+		wantExit, wantViolation int
+	}{
+		{"no failures", "1%", "", "", 0, StatusSuccess, ExitClean, 0},
+		{"no criteria", "", "", "", 1, StatusPartial, ExitPartial, 0},
+		{"within the share of files", "1%", "", "", 2, StatusPassed, ExitClean, 0},
+		{"more than the share of files", "1%", "", "", 3, StatusFailed, ExitCriteria, 1},
+		{"more than the packages", "1%", "2", "", 3, StatusFailed, ExitCriteria, 2},
+		{"critical package failed", "", "", "npm/app-1", 2, StatusFailed, ExitCriteria, 1},
+		{"critical package succeeded", "", "", "npm/app-9", 2, StatusPassed, ExitClean, 0},
+	}
+	for _, test := range tests {
+		viper.Set("GHMPKG_MAX_FAILED_FILES", test.maxFiles)
+		viper.Set("GHMPKG_MAX_FAILED_PACKAGES", test.maxPackages)
+		viper.Set("GHMPKG_CRITICAL", test.critical)
+		viper.Set("GHMPKG_FAIL_ON_CRITICAL", test.critical != "")
+
+		report := criteriaReport(test.failed)
+		status, violations, err := report.Evaluate()
+		if err != nil || status != test.want || len(violations) != test.wantViolation {
+			t.Errorf("%s: Evaluate = %s, %v, %v, want %s with %d violations", test.name, status, violations, err, test.want, test.wantViolation)
+		}
+		if code := ExitCode(report.Failures()); code != test.wantExit {
+			t.Errorf("%s: exit code %d, want %d", test.name, code, test.wantExit)
+		}
+	}
+
+	viper.Set("GHMPKG_CRITICAL", "")
+	if _, err := NewSuccessCriteria(); err == nil {
+		t.Error("--fail-on-critical without critical packages was accepted")
+	}
+}
+
+func TestDocumentFailures(t *testing.T) {
+	report := criteriaReport(3)
+	document := &ReportDocument{Status: StatusFailed, Criteria: []string{"3 of 200 files failed, more than 1%"}, Report: report}
+	var criteria *CriteriaError
+	if err := document.Failures(); !errors.As(err, &criteria) || criteria.Files != 3 || len(criteria.Violations) != 1 {
+		t.Errorf("Failures = %v, want the unmet criteria", err)
+	}
+	document.Status = StatusPassed
+	if err := document.Failures(); err != nil {
+		t.Errorf("Failures within the criteria = %v", err)
+	}
+	// Reports written before the status was recorded
+	document.Status = ""
+	if err := document.Failures(); ExitCode(err) != ExitPartial {
+		t.Errorf("Failures without status = %v, want partial failures", err)
+	}
+}
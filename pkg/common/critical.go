@@ -0,0 +1,103 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// CriticalPackages selects the packages of GHMPKG_CRITICAL and of the
+// GHMPKG_CRITICAL_FILE list, which a run processes before every other package.
+// Patterns are matched like --include ones, a package type prefix such as
+// "npm/web-*" only matches packages of that type.
+type CriticalPackages struct {
+	Patterns []string
+	matchers []criticalMatcher
+}
+
+type criticalMatcher struct {
+	packageType string
+	match       func(string) bool
+}
+
+// NewCriticalPackages builds the list from the settings, failing on invalid
+// patterns or an unreadable file
+func NewCriticalPackages() (*CriticalPackages, error) {
+	critical := &CriticalPackages{Patterns: patternList("GHMPKG_CRITICAL")}
+	if path := viper.GetString("GHMPKG_CRITICAL_FILE"); path != "" {
+		patterns, err := readPatternFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read critical packages file: %w", err)
+		}
+		critical.Patterns = append(critical.Patterns, patterns...)
+	}
+	for _, pattern := range critical.Patterns {
+		var matcher criticalMatcher
+		if packageType, name, ok := strings.Cut(pattern, "/"); ok && utils.Contains(SUPPORTED_PACKAGE_TYPES, packageType) {
+			matcher.packageType, pattern = packageType, name
+		}
+		match, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matcher.match = match
+		critical.matchers = append(critical.matchers, matcher)
+	}
+	return critical, nil
+}
+
+// readPatternFile reads a pattern per line, skipping blank lines and # comments
+func readPatternFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// IsEmpty reports whether no package is critical
+func (c *CriticalPackages) IsEmpty() bool {
+	return len(c.matchers) == 0
+}
+
+// Match reports whether a package is critical
+func (c *CriticalPackages) Match(packageType, name string) bool {
+	for _, matcher := range c.matchers {
+		if (matcher.packageType == "" || matcher.packageType == packageType) && matcher.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prioritize moves the rows of critical packages before the others, keeping
+// the order of both. It also returns the number of critical rows.
+func (c *CriticalPackages) Prioritize(rows [][]string) ([][]string, int) {
+	if c.IsEmpty() {
+		return rows, 0
+	}
+	var critical, others [][]string
+	for _, row := range rows {
+		if len(row) > 3 && c.Match(row[2], row[3]) {
+			critical = append(critical, row)
+		} else {
+			others = append(others, row)
+		}
+	}
+	return append(critical, others...), len(critical)
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestCriticalPackages(t *testing.T) {
+	defer viper.Reset()
+
+	file := filepath.Join(t.TempDir(), "critical.txt")
+	if err := os.WriteFile(file, []byte("# cutover blockers\nmaven/com.example.*\n\nre:auth-(api|ui)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("GHMPKG_CRITICAL", []string{"npm/web-*,base"})
+	viper.Set("GHMPKG_CRITICAL_FILE", file)
+
+	critical, err := NewCriticalPackages()
+	if err != nil {
+		t.Fatalf("NewCriticalPackages: %v", err)
+	}
+	tests := []struct {
+		packageType, name string
+		want              bool
+	}{
+		{"npm", "web-app", true},
+		{"nuget", "web-app", false},
+		{"container", "base", true},
+		{"maven", "com.example.app", true},
+		{"container", "auth-api", true},
+		{"container", "auth-worker", false},
+	}
+	for _, test := range tests {
+		if got := critical.Match(test.packageType, test.name); got != test.want {
+			t.Errorf("Match(%s, %s) = %v, want %v", test.packageType, test.name, got, test.want)
+		}
+	}
+
+	rows := [][]string{
+		{"mona", "repo", "nuget", "tools"},
+		{"mona", "repo", "npm", "web-app"},
+		{"mona", "repo", "container", "worker"},
+		{"mona", "repo", "container", "base"},
+	}
+	prioritized, count := critical.Prioritize(rows)
+	want := [][]string{rows[1], rows[3], rows[0], rows[2]}
+	if count != 2 || !reflect.DeepEqual(prioritized, want) {
+		t.Errorf("Prioritize = %v, %d, want %v", prioritized, count, want)
+	}
+
+	viper.Set("GHMPKG_CRITICAL_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	if _, err := NewCriticalPackages(); err == nil {
+		t.Error("NewCriticalPackages accepted a missing file")
+	}
+}
@@ -0,0 +1,24 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// DIST_TAGS_HEADER is the header of the dist-tags CSV written by export for
+// packages whose registry tags versions, such as npm. A row per tag.
+var DIST_TAGS_HEADER = []string{"organization", "repository", "package_type", "package_name", "tag", "version"}
+
+// FindDistTags returns the most recent dist-tags export of a package type, an
+// empty path when the export did not record any
+func FindDistTags(migrationPath, packageType, owner string) (string, error) {
+	dir := filepath.Join(migrationPath, "export", packageType)
+	matches, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("*_%s_%s_dist_tags.csv", owner, packageType)))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+	// Names start with the export timestamp
+	sort.Strings(matches)
+	return matches[len(matches)-1], nil
+}
@@ -0,0 +1,198 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Defaults of the error rate watch, used when the GHMPKG_ERROR_RATE_* settings are not set
+const (
+	DefaultErrorRateWindow    = 20
+	DefaultErrorRateThreshold = 50
+	DefaultErrorRateBackoff   = 30 * time.Second
+	maxErrorRateBackoff       = 10 * time.Minute
+)
+
+// errorRates watches the share of failed versions over the last operations of
+// every registry (package type). When it spikes, typically while the registry
+// has an incident, new operations of that registry are held back for a while
+// instead of failing one after the other. In a terminal the operator is asked
+// whether to carry on instead.
+type errorRates struct {
+	logger    *zap.Logger
+	window    int
+	threshold float64
+	backoff   time.Duration
+	// confirm asks whether to carry on after a spike, nil outside of a terminal
+	confirm func(message string) bool
+
+	mu         sync.Mutex
+	registries map[string]*registryErrors
+	aborted    error
+}
+
+// registryErrors is the rolling window of outcomes of a registry
+type registryErrors struct {
+	outcomes    []bool
+	next        int
+	recorded    int
+	failures    int
+	backoff     time.Duration
+	pausedUntil time.Time
+	tripped     bool
+}
+
+// newErrorRates reads the error rate settings, nil when the watch is disabled
+func newErrorRates(logger *zap.Logger) (*errorRates, error) {
+	threshold := DefaultErrorRateThreshold
+	if viper.IsSet("GHMPKG_ERROR_RATE_THRESHOLD") {
+		threshold = viper.GetInt("GHMPKG_ERROR_RATE_THRESHOLD")
+	}
+	if threshold < 0 || threshold > 100 {
+		return nil, fmt.Errorf("invalid --error-rate-threshold %d, expected a percentage between 0 and 100", threshold)
+	}
+	if threshold == 0 {
+		return nil, nil
+	}
+	window := DefaultErrorRateWindow
+	if viper.IsSet("GHMPKG_ERROR_RATE_WINDOW") {
+		window = viper.GetInt("GHMPKG_ERROR_RATE_WINDOW")
+	}
+	if window < 1 {
+		return nil, fmt.Errorf("invalid --error-rate-window %d, expected a positive number", window)
+	}
+	backoff := DefaultErrorRateBackoff
+	if value := viper.GetString("GHMPKG_ERROR_RATE_BACKOFF"); value != "" {
+		var err error
+		if backoff, err = time.ParseDuration(value); err != nil || backoff <= 0 {
+			return nil, fmt.Errorf("invalid --error-rate-backoff %q, expected a duration such as 30s", value)
+		}
+	}
+
+	rates := &errorRates{
+		logger:     logger,
+		window:     window,
+		threshold:  float64(threshold) / 100,
+		backoff:    backoff,
+		registries: make(map[string]*registryErrors),
+	}
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		rates.confirm = func(message string) bool {
+			result, err := pterm.DefaultInteractiveConfirm.WithDefaultValue(true).Show(message)
+			return err == nil && result
+		}
+	}
+	return rates, nil
+}
+
+// isTerminal reports whether a file is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (r *errorRates) registry(packageType string) *registryErrors {
+	registry, ok := r.registries[packageType]
+	if !ok {
+		registry = &registryErrors{outcomes: make([]bool, r.window), backoff: r.backoff}
+		r.registries[packageType] = registry
+	}
+	return registry
+}
+
+// rate is the share of failures in the window, once it is full
+func (e *registryErrors) rate() (float64, bool) {
+	if e.recorded < len(e.outcomes) {
+		return 0, false
+	}
+	return float64(e.failures) / float64(len(e.outcomes)), true
+}
+
+// reset starts a new window, so a registry is measured again after a pause
+func (e *registryErrors) reset() {
+	for i := range e.outcomes {
+		e.outcomes[i] = false
+	}
+	e.next, e.recorded, e.failures = 0, 0, 0
+}
+
+// wait blocks while the registry of a package type is backing off, and returns
+// an error once the operator chose to stop the run
+func (r *errorRates) wait(packageType string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	pausedUntil := r.registry(packageType).pausedUntil
+	aborted := r.aborted
+	r.mu.Unlock()
+	if aborted != nil {
+		return aborted
+	}
+	if wait := time.Until(pausedUntil); wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// record adds the outcome of an operation to the window of its registry, and
+// backs off (or asks the operator) when the error rate crosses the threshold
+func (r *errorRates) record(packageType string, failed bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registry := r.registry(packageType)
+	if registry.recorded == len(registry.outcomes) && registry.outcomes[registry.next] {
+		registry.failures--
+	}
+	registry.outcomes[registry.next] = failed
+	registry.next = (registry.next + 1) % len(registry.outcomes)
+	if registry.recorded < len(registry.outcomes) {
+		registry.recorded++
+	}
+	if failed {
+		registry.failures++
+	}
+
+	rate, full := registry.rate()
+	if !full {
+		return
+	}
+	if rate < r.threshold {
+		if registry.tripped {
+			registry.tripped = false
+			registry.backoff = r.backoff
+			pterm.Success.Printf("💚 %s error rate back to %.0f%%\n", packageType, rate*100)
+		}
+		return
+	}
+
+	registry.tripped = true
+	registry.reset()
+	r.logger.Warn("Error rate spike",
+		zap.String("packageType", packageType),
+		zap.Float64("rate", rate),
+		zap.Duration("backoff", registry.backoff))
+	if r.confirm != nil {
+		// Holding the lock pauses every other operation while the operator decides
+		if !r.confirm(fmt.Sprintf("%.0f%% of the last %d %s operations failed. Continue?", rate*100, len(registry.outcomes), packageType)) {
+			r.aborted = fmt.Errorf("stopped after %.0f%% of the last %d %s operations failed", rate*100, len(registry.outcomes), packageType)
+		}
+		return
+	}
+	pterm.Warning.Printf("⚠️  %.0f%% of the last %d %s operations failed, backing off for %s\n", rate*100, len(registry.outcomes), packageType, registry.backoff)
+	registry.pausedUntil = time.Now().Add(registry.backoff)
+	registry.backoff *= 2
+	if registry.backoff > maxErrorRateBackoff {
+		registry.backoff = maxErrorRateBackoff
+	}
+}
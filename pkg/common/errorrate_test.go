@@ -0,0 +1,65 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestErrorRatesBackOff(t *testing.T) {
+	rates := &errorRates{
+		logger:     zap.NewNop(),
+		window:     4,
+		threshold:  0.5,
+		backoff:    time.Minute,
+		registries: make(map[string]*registryErrors),
+	}
+
+	// A window that is not full yet never trips
+	rates.record("npm", true)
+	rates.record("npm", true)
+	if !rates.registry("npm").pausedUntil.IsZero() {
+		t.Fatal("backed off before the window was full")
+	}
+
+	rates.record("npm", false)
+	rates.record("npm", true)
+	npm := rates.registry("npm")
+	if time.Until(npm.pausedUntil) <= 0 {
+		t.Fatal("expected npm to back off after 3 of 4 operations failed")
+	}
+	if npm.backoff != 2*time.Minute {
+		t.Errorf("next backoff = %s, want 2m", npm.backoff)
+	}
+	if npm.recorded != 0 {
+		t.Errorf("window not reset after a spike, %d outcomes recorded", npm.recorded)
+	}
+	if !rates.registry("maven").pausedUntil.IsZero() {
+		t.Error("maven backed off for npm errors")
+	}
+
+	// A healthy window after the pause resets the backoff
+	for i := 0; i < 4; i++ {
+		rates.record("npm", false)
+	}
+	if npm.tripped || npm.backoff != time.Minute {
+		t.Errorf("tripped=%v backoff=%s after recovering, want false and 1m", npm.tripped, npm.backoff)
+	}
+}
+
+func TestErrorRatesConfirm(t *testing.T) {
+	rates := &errorRates{
+		logger:     zap.NewNop(),
+		window:     2,
+		threshold:  0.5,
+		backoff:    time.Minute,
+		registries: make(map[string]*registryErrors),
+		confirm:    func(string) bool { return false },
+	}
+	rates.record("container", true)
+	rates.record("container", true)
+	if err := rates.wait("container"); err == nil {
+		t.Fatal("expected the run to stop once the operator declined")
+	}
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Ways sync handles a package that already exists in the target organization
+const (
+	// ExistingNewVersions uploads the versions the target is missing
+	ExistingNewVersions = "new-versions"
+	// ExistingSkip skips the package as a whole
+	ExistingSkip = "skip"
+	// ExistingAll uploads every version without checking the target, the
+	// registry rejects the files it already has
+	ExistingAll = "all"
+)
+
+// EXISTING_PACKAGE_POLICIES are the values of GHMPKG_EXISTING_PACKAGES
+var EXISTING_PACKAGE_POLICIES = []string{ExistingNewVersions, ExistingSkip, ExistingAll}
+
+// ExistingPackagePolicy reads GHMPKG_EXISTING_PACKAGES, new-versions by default
+func ExistingPackagePolicy() (string, error) {
+	policy := viper.GetString("GHMPKG_EXISTING_PACKAGES")
+	if policy == "" {
+		return ExistingNewVersions, nil
+	}
+	if !utils.Contains(EXISTING_PACKAGE_POLICIES, policy) {
+		return "", fmt.Errorf("unsupported existing packages policy: %s (expected one of %v)", policy, EXISTING_PACKAGE_POLICIES)
+	}
+	return policy, nil
+}
+
+// targetVersions holds the versions a package already has on the target.
+// Container versions are named by a digest that changes when images are
+// rewritten for the target organization, they are matched on their tags.
+type targetVersions struct {
+	names map[string]bool
+	tags  map[string]bool
+	// untagged counts the container versions without a tag
+	untagged int
+}
+
+// fetchTargetVersions lists the versions of a package that exists on the target
+func fetchTargetVersions(packageType, packageName string) (*targetVersions, error) {
+	versions, err := api.FetchTargetPackageVersions(&github.Package{Name: &packageName, PackageType: &packageType})
+	if err != nil {
+		return nil, err
+	}
+	return newTargetVersions(packageType, versions), nil
+}
+
+func newTargetVersions(packageType string, versions []*github.PackageVersion) *targetVersions {
+	existing := &targetVersions{names: make(map[string]bool), tags: make(map[string]bool)}
+	for _, version := range versions {
+		existing.names[version.GetName()] = true
+		if version.Metadata != nil && version.Metadata.Container != nil {
+			for _, tag := range version.Metadata.Container.Tags {
+				existing.tags[tag] = true
+			}
+			if len(version.Metadata.Container.Tags) == 0 {
+				existing.untagged++
+			}
+		}
+	}
+	return existing
+}
+
+// Has reports whether the target has a file of the inventory, by its version or,
+// for containers, by the tag in its filename
+func (t *targetVersions) Has(packageType, version, filename string) bool {
+	if providers.IsImage(packageType) {
+		_, tag, ok := strings.Cut(filename, ":")
+		return ok && t.tags[tag]
+	}
+	// NuGet lists versions the way the registry normalized them on publish
+	if packageType == "nuget" {
+		version = providers.NormalizeName(packageType, providers.VersionField, version)
+	}
+	return t.names[version]
+}
+
+// HasAll reports whether the target has every file of the inventory index for a package
+func (t *targetVersions) HasAll(index *inventoryIndex, owner, repository, packageType, packageName string) bool {
+	for _, version := range index.Versions(owner, repository, packageType, packageName) {
+		for _, filename := range index.Files(owner, repository, packageType, packageName, version) {
+			if !t.Has(packageType, version, filename) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkExisting applies the existing packages policy to a package about to be
+// uploaded. It returns the versions the target already has, nil when every
+// version is to be uploaded, or reports that the package is skipped as a whole.
+func (run *processRun) checkExisting(owner, repository, packageType, packageName string) (*targetVersions, bool, error) {
+	logger := run.logger
+	// Only check on upload, a package this run already started (or a previous
+	// run partially migrated before failing) is not "existing"
+	if !run.skipIfExists || run.retrying || run.existingPolicy == ExistingAll {
+		return nil, false, nil
+	}
+	if run.resume && run.checkpoint.HasPackage(run.phase, state.PackageKey(owner, repository, packageType, packageName)) {
+		return nil, false, nil
+	}
+	// Other registries are not a GitHub organization, existing files are skipped as they are deployed
+	if !providers.TargetOnGitHub(packageType) {
+		return nil, false, nil
+	}
+
+	// Legacy docker images are looked up in the Container registry of the target,
+	// under the name the mapping file gives them
+	targetName := providers.TargetPackageName(packageType, packageName)
+	exists, err := api.PackageExists(targetName, providers.TargetPackageType(packageType))
+	if err != nil {
+		logger.Error("Error checking if package exists", zap.Error(err))
+		return nil, false, err
+	}
+	if !exists {
+		return nil, false, nil
+	}
+	if run.existingPolicy == ExistingSkip {
+		logger.Info("Package already exists, skipping...", zap.String("package", packageName))
+		return nil, true, nil
+	}
+
+	// A partially migrated package only gets the versions it is missing
+	existing, err := fetchTargetVersions(providers.TargetPackageType(packageType), targetName)
+	if err != nil {
+		logger.Error("Error listing versions on target", zap.Error(err))
+		return nil, false, err
+	}
+	if existing.HasAll(run.inventory, owner, repository, packageType, packageName) {
+		logger.Info("Package already exists with every version, skipping...", zap.String("package", packageName))
+		return nil, true, nil
+	}
+	logger.Info("Package already exists, syncing missing versions", zap.String("package", packageName))
+	return existing, false, nil
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestTargetVersions(t *testing.T) {
+	existing := newTargetVersions("container", []*github.PackageVersion{
+		{Name: github.String("sha256:111"), Metadata: &github.PackageMetadata{Container: &github.PackageContainerMetadata{Tags: []string{"1.0.0", "latest"}}}},
+	})
+	// The digest differs on the target, tags decide
+	if !existing.Has("container", "sha256:999", "app:1.0.0") || existing.Has("container", "sha256:111", "app:2.0.0") {
+		t.Errorf("container versions matched on digest instead of tag")
+	}
+
+	existing = newTargetVersions("nuget", []*github.PackageVersion{{Name: github.String("1.2.0")}})
+	if !existing.Has("nuget", "1.02.0.0", "lib.1.02.0.nupkg") {
+		t.Errorf("nuget version not matched after normalization")
+	}
+
+	index := newInventoryIndex([][]string{
+		{"mona-actions", "repo", "npm", "lib", "1.0.0", "lib-1.0.0.tgz"},
+		{"mona-actions", "repo", "npm", "lib", "2.0.0", "lib-2.0.0.tgz"},
+	})
+	existing = newTargetVersions("npm", []*github.PackageVersion{{Name: github.String("1.0.0")}})
+	if existing.HasAll(index, "mona-actions", "repo", "npm", "lib") {
+		t.Errorf("HasAll = true with version 2.0.0 missing on target")
+	}
+	existing.names["2.0.0"] = true
+	if !existing.HasAll(index, "mona-actions", "repo", "npm", "lib") {
+		t.Errorf("HasAll = false with every version on target")
+	}
+}
+
+func TestExistingPackagePolicy(t *testing.T) {
+	defer viper.Reset()
+
+	for value, want := range map[string]string{"": ExistingNewVersions, "skip": ExistingSkip, "all": ExistingAll} {
+		viper.Set("GHMPKG_EXISTING_PACKAGES", value)
+		if got, err := ExistingPackagePolicy(); err != nil || got != want {
+			t.Errorf("ExistingPackagePolicy(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	viper.Set("GHMPKG_EXISTING_PACKAGES", "overwrite")
+	if _, err := ExistingPackagePolicy(); err == nil {
+		t.Error("ExistingPackagePolicy accepted an unsupported policy")
+	}
+}
+
+func TestCheckExistingWithoutTargetQuery(t *testing.T) {
+	defer viper.Reset()
+
+	run := &processRun{logger: zap.NewNop(), skipIfExists: true, existingPolicy: ExistingAll}
+	// No token is set, any request to the target would fail
+	if existing, skip, err := run.checkExisting("mona", "repo", "npm", "lib"); existing != nil || skip || err != nil {
+		t.Errorf("policy all checked the target: %v, %v, %v", existing, skip, err)
+	}
+
+	run.existingPolicy = ExistingSkip
+	viper.Set("GHMPKG_TARGET_CONTAINER_REGISTRY", "docker.io/acme")
+	if existing, skip, err := run.checkExisting("mona", "repo", "container", "app"); existing != nil || skip || err != nil {
+		t.Errorf("a Docker Hub target was checked as a GitHub organization: %v, %v, %v", existing, skip, err)
+	}
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Exit codes of the commands, for the scripts and CI jobs running them
+const (
+	// ExitClean: the run completed without failures
+	ExitClean = 0
+	// ExitFatal: the run stopped on an error
+	ExitFatal = 1
+	// ExitPartial: the run completed, but packages, versions or files failed
+	ExitPartial = 2
+	// ExitCriteria: the run completed without meeting its success criteria
+	ExitCriteria = 3
+)
+
+// PartialFailureError is returned by runs that completed with failures, which
+// the report of the run lists
+type PartialFailureError struct {
+	Packages int
+	Versions int
+	Files    int
+}
+
+func (e *PartialFailureError) Error() string {
+	return fmt.Sprintf("completed with %d failed packages, %d failed versions and %d failed files", e.Packages, e.Versions, e.Files)
+}
+
+// CriteriaError is returned by runs that completed with more failures than
+// their success criteria tolerate
+type CriteriaError struct {
+	PartialFailureError
+	// Violations are the criteria the run did not meet
+	Violations []string
+}
+
+func (e *CriteriaError) Error() string {
+	return fmt.Sprintf("%s and did not meet its success criteria: %s", e.PartialFailureError.Error(), strings.Join(e.Violations, "; "))
+}
+
+// failures builds the error of a run with the given status and failure counts
+func failures(status string, violations []string, r *Report) error {
+	partial := PartialFailureError{Packages: r.PackagesFailed, Versions: r.VersionsFailed, Files: r.FilesFailed}
+	switch status {
+	case StatusPartial:
+		return &partial
+	case StatusFailed:
+		return &CriteriaError{PartialFailureError: partial, Violations: violations}
+	}
+	return nil
+}
+
+// Failures returns a CriteriaError when the report does not meet the success
+// criteria, a PartialFailureError when packages, versions or files failed and
+// no criteria are set, nil otherwise: nothing failed or the failures are
+// within the criteria
+func (r *Report) Failures() error {
+	status, violations, err := r.Evaluate()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return failures(status, violations, r)
+}
+
+// ExitCode returns the exit code of a run that ended with err
+func ExitCode(err error) int {
+	var criteria *CriteriaError
+	var partial *PartialFailureError
+	switch {
+	case err == nil:
+		return ExitClean
+	case errors.As(err, &criteria):
+		return ExitCriteria
+	case errors.As(err, &partial):
+		return ExitPartial
+	default:
+		return ExitFatal
+	}
+}
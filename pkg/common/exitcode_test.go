@@ -0,0 +1,40 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+)
+
+func TestExitCode(t *testing.T) {
+	report := NewReport()
+	report.RecordFile(NewItem("mona-actions", "app", "npm", "app", "1.0.0", "app-1.0.0.tgz", providers.Success, nil))
+	if err := report.Failures(); err != nil || ExitCode(err) != ExitClean {
+		t.Fatalf("Failures of a clean report = %v", err)
+	}
+
+	report.RecordFile(NewItem("mona-actions", "app", "npm", "app", "1.0.1", "app-1.0.1.tgz", providers.Failed, errors.New("503")))
+	report.IncVersions(providers.Failed)
+	failures := report.Failures()
+	var partial *PartialFailureError
+	if !errors.As(failures, &partial) || partial.Versions != 1 || partial.Files != 1 {
+		t.Fatalf("Failures = %v, want one failed version and file", failures)
+	}
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitClean},
+		{failures, ExitPartial},
+		{fmt.Errorf("sync %w", failures), ExitPartial},
+		{errors.New("no package export files found"), ExitFatal},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
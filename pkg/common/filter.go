@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// NameFilter selects packages by name with the GHMPKG_PACKAGES names and the
+// GHMPKG_INCLUDE and GHMPKG_EXCLUDE patterns. Patterns are globs (e.g.
+// "frontend-*") unless prefixed with "re:", which makes them regular
+// expressions matched against the whole name.
+type NameFilter struct {
+	Packages []string
+	Include  []string
+	Exclude  []string
+	include  []func(string) bool
+	exclude  []func(string) bool
+}
+
+// patternList reads a pattern setting, accepting comma separated values from the environment
+func patternList(key string) []string {
+	var patterns []string
+	for _, value := range viper.GetStringSlice(key) {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns
+}
+
+func compilePattern(pattern string) (func(string) bool, error) {
+	if expression, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile("^(?:" + expression + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", expression, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	return func(name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}, nil
+}
+
+// DesiredRepositories returns the repositories of GHMPKG_REPOSITORY, which
+// takes several comma separated names. None selects every repository.
+func DesiredRepositories() []string {
+	return patternList("GHMPKG_REPOSITORY")
+}
+
+// MatchRepository reports whether a repository is one of the desired
+// repositories, or there are none. Like on GitHub, names are not case sensitive.
+func MatchRepository(repositories []string, repository string) bool {
+	if len(repositories) == 0 {
+		return true
+	}
+	for _, desired := range repositories {
+		if strings.EqualFold(desired, repository) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewNameFilter builds the filter from the settings, failing on invalid patterns
+func NewNameFilter() (*NameFilter, error) {
+	filter := &NameFilter{
+		Packages: patternList("GHMPKG_PACKAGES"),
+		Include:  patternList("GHMPKG_INCLUDE"),
+		Exclude:  patternList("GHMPKG_EXCLUDE"),
+	}
+	for _, pattern := range filter.Include {
+		match, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.include = append(filter.include, match)
+	}
+	for _, pattern := range filter.Exclude {
+		match, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.exclude = append(filter.exclude, match)
+	}
+	return filter, nil
+}
+
+// IsEmpty reports whether the filter selects every package
+func (f *NameFilter) IsEmpty() bool {
+	return len(f.Packages) == 0 && len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// String describes the patterns for the console
+func (f *NameFilter) String() string {
+	var parts []string
+	if len(f.Packages) > 0 {
+		parts = append(parts, fmt.Sprintf("packages %s", strings.Join(f.Packages, ", ")))
+	}
+	if len(f.Include) > 0 {
+		parts = append(parts, fmt.Sprintf("include %s", strings.Join(f.Include, ", ")))
+	}
+	if len(f.Exclude) > 0 {
+		parts = append(parts, fmt.Sprintf("exclude %s", strings.Join(f.Exclude, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Match reports whether a package name is one of the packages (when there are
+// any), matches an include pattern (when there are any) and no exclude pattern
+func (f *NameFilter) Match(name string) bool {
+	if len(f.Packages) > 0 && !slices.Contains(f.Packages, name) {
+		return false
+	}
+	for _, match := range f.exclude {
+		if match(name) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, match := range f.include {
+		if match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRows returns the inventory rows whose package name matches the filter
+func (f *NameFilter) FilterRows(packages [][]string) [][]string {
+	if f.IsEmpty() {
+		return packages
+	}
+	var rows [][]string
+	for _, row := range packages {
+		if len(row) > 3 && f.Match(row[3]) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
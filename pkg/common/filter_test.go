@@ -0,0 +1,84 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestNameFilter(t *testing.T) {
+	defer viper.Reset()
+
+	rows := [][]string{
+		{"acme", "web", "npm", "frontend-app", "1.0.0"},
+		{"acme", "web", "npm", "frontend-app-snapshot", "1.0.0"},
+		{"acme", "api", "maven", "api-v2", "2.0.0"},
+		{"acme", "api", "maven", "api-client", "2.0.0"},
+	}
+	tests := []struct {
+		packages, include, exclude string
+		want                       []string
+	}{
+		{"", "", "", []string{"frontend-app", "frontend-app-snapshot", "api-v2", "api-client"}},
+		{"", "frontend-*", "*-snapshot", []string{"frontend-app"}},
+		{"", "re:api-v[0-9]+", "", []string{"api-v2"}},
+		{"api-client, frontend-app", "", "", []string{"frontend-app", "api-client"}},
+		// Names are matched exactly, not as globs
+		{"frontend-*", "", "", nil},
+		// Packages, include and exclude all have to agree
+		{"api-client,api-v2", "api-*", "api-v*", []string{"api-client"}},
+	}
+	for _, test := range tests {
+		viper.Set("GHMPKG_PACKAGES", test.packages)
+		viper.Set("GHMPKG_INCLUDE", test.include)
+		viper.Set("GHMPKG_EXCLUDE", test.exclude)
+		filter, err := NewNameFilter()
+		if err != nil {
+			t.Fatalf("NewNameFilter: %v", err)
+		}
+		var got []string
+		for _, row := range filter.FilterRows(rows) {
+			got = append(got, row[3])
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("packages %q, include %q, exclude %q selected %v, want %v", test.packages, test.include, test.exclude, got, test.want)
+		}
+	}
+
+	viper.Set("GHMPKG_PACKAGES", "")
+	viper.Set("GHMPKG_INCLUDE", "re:api-(")
+	if _, err := NewNameFilter(); err == nil {
+		t.Error("NewNameFilter accepted an invalid regular expression")
+	}
+}
+
+func TestFilterByRepository(t *testing.T) {
+	defer viper.Reset()
+
+	rows := [][]string{
+		{"acme", "web", "npm", "frontend-app", "1.0.0"},
+		{"acme", "api", "maven", "api-client", "2.0.0"},
+		{"acme", "tools", "nuget", "Acme.Tools", "3.0.0"},
+	}
+	tests := []struct {
+		repository any
+		want       []string
+	}{
+		{"", []string{"web", "api", "tools"}},
+		{"api", []string{"api"}},
+		{"web,Tools", []string{"web", "tools"}},
+		{[]string{"tools", "api"}, []string{"api", "tools"}},
+		{"docs", nil},
+	}
+	for _, test := range tests {
+		viper.Set("GHMPKG_REPOSITORY", test.repository)
+		var got []string
+		for _, row := range FilterByRepository(rows, DesiredRepositories()) {
+			got = append(got, row[1])
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("GHMPKG_REPOSITORY %v selected %v, want %v", test.repository, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+)
+
+// inventoryIndex groups the inventory rows by package and version once, so
+// processing a package does not scan every row of the export for each of its
+// versions. Versions and files keep the order of the export.
+type inventoryIndex struct {
+	versions  map[string][]string
+	files     map[string][]string
+	checksums map[string]string
+}
+
+func newInventoryIndex(packages [][]string) *inventoryIndex {
+	index := &inventoryIndex{
+		versions:  make(map[string][]string),
+		files:     make(map[string][]string),
+		checksums: make(map[string]string),
+	}
+	seen := make(map[string]bool)
+	for _, row := range packages {
+		if len(row) < 6 {
+			continue
+		}
+		packageKey := state.PackageKey(row[0], row[1], row[2], row[3])
+		versionKey := packageKey + "|" + row[4]
+		if !seen[versionKey] {
+			seen[versionKey] = true
+			index.versions[packageKey] = append(index.versions[packageKey], row[4])
+		}
+		fileKey := versionKey + "|" + row[5]
+		if !seen[fileKey] {
+			seen[fileKey] = true
+			index.files[versionKey] = append(index.files[versionKey], row[5])
+		}
+		if len(row) > ChecksumColumn && row[ChecksumColumn] != "" {
+			index.checksums[fileKey] = row[ChecksumColumn]
+		}
+	}
+	return index
+}
+
+// Versions returns the versions of a package
+func (index *inventoryIndex) Versions(owner, repository, packageType, packageName string) []string {
+	return index.versions[state.PackageKey(owner, repository, packageType, packageName)]
+}
+
+// Files returns the files of a package version
+func (index *inventoryIndex) Files(owner, repository, packageType, packageName, version string) []string {
+	return index.files[state.PackageKey(owner, repository, packageType, packageName)+"|"+version]
+}
+
+// Checksum returns the checksum the export recorded for a file, empty when unknown
+func (index *inventoryIndex) Checksum(owner, repository, packageType, packageName, version, filename string) string {
+	return index.checksums[state.PackageKey(owner, repository, packageType, packageName)+"|"+version+"|"+filename]
+}
@@ -0,0 +1,174 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+// EXPORT_FORMATS are the inventory formats export can write
+var EXPORT_FORMATS = []string{"csv", "json", "both"}
+
+// INVENTORY_HEADER is the header of the packages CSV, the columns a manifest is flattened to
+var INVENTORY_HEADER = []string{"organization", "repository", "package_type", "package_name", "package_version", "package_filename", "package_version_created_at", "package_version_updated_at", "package_file_sha256", "repository_visibility", "repository_archived", "package_file_size"}
+
+// Manifest is the JSON inventory of a package type: packages, their versions and
+// files, with the metadata the flat CSV cannot hold
+type Manifest struct {
+	Organization string             `json:"organization"`
+	PackageType  string             `json:"package_type"`
+	ExportedAt   string             `json:"exported_at"`
+	Run          *run.Metadata      `json:"run,omitempty"`
+	Packages     []*ManifestPackage `json:"packages"`
+}
+
+// ManifestPackage is a package of the manifest
+type ManifestPackage struct {
+	Name          string `json:"name"`
+	Repository    string `json:"repository"`
+	RepositoryURL string `json:"repository_url,omitempty"`
+	// RepositoryVisibility and RepositoryArchived describe the repository the
+	// package is linked to, RepositoryArchived is "true" or "false"
+	RepositoryVisibility string             `json:"repository_visibility,omitempty"`
+	RepositoryArchived   string             `json:"repository_archived,omitempty"`
+	URL                  string             `json:"url,omitempty"`
+	Visibility           string             `json:"visibility,omitempty"`
+	CreatedAt            string             `json:"created_at,omitempty"`
+	UpdatedAt            string             `json:"updated_at,omitempty"`
+	Versions             []*ManifestVersion `json:"versions"`
+}
+
+// ManifestVersion is a version of a manifest package. Container versions are
+// named by their manifest digest and list their tags.
+type ManifestVersion struct {
+	Name      string         `json:"name"`
+	Tags      []string       `json:"tags,omitempty"`
+	URL       string         `json:"url,omitempty"`
+	CreatedAt string         `json:"created_at,omitempty"`
+	UpdatedAt string         `json:"updated_at,omitempty"`
+	Files     []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a file of a manifest version
+type ManifestFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Size is the size in bytes of the file, 0 when unknown
+	Size int64 `json:"size,omitempty"`
+}
+
+// Rows flattens the manifest to inventory rows, header included, as pull and sync consume them
+func (m *Manifest) Rows() [][]string {
+	rows := [][]string{INVENTORY_HEADER}
+	for _, pkg := range m.Packages {
+		for _, version := range pkg.Versions {
+			for _, file := range version.Files {
+				rows = append(rows, []string{m.Organization, pkg.Repository, m.PackageType, pkg.Name, version.Name, file.Name, version.CreatedAt, version.UpdatedAt, file.SHA256,
+					pkg.RepositoryVisibility, pkg.RepositoryArchived, FormatFileSize(file.Size)})
+			}
+		}
+	}
+	return rows
+}
+
+// FormatFileSize writes a file size for the CSV, empty when unknown
+func FormatFileSize(size int64) string {
+	if size <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(size, 10)
+}
+
+// RowFileSize returns the size of the file of an inventory row, 0 when the
+// export did not record it
+func RowFileSize(row []string) int64 {
+	if len(row) <= FileSizeColumn {
+		return 0
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(row[FileSizeColumn]), 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// WriteManifest writes the manifest as indented JSON
+func WriteManifest(manifest *Manifest, filename string) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return utils.WriteFileAtomic(filename, content, 0644)
+}
+
+// ReadManifest reads a manifest written by export --format json
+func ReadManifest(filename string) (*Manifest, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", filename, err)
+	}
+	return &manifest, nil
+}
+
+// ReadInventory reads the rows of an export, a CSV file or a JSON manifest
+func ReadInventory(filename string) ([][]string, error) {
+	if strings.HasSuffix(filename, ".json") {
+		manifest, err := ReadManifest(filename)
+		if err != nil {
+			return nil, err
+		}
+		return manifest.Rows(), nil
+	}
+	return files.ReadCSV(filename)
+}
+
+// FindInventory returns the most recent export of a package type in the
+// migration directory, CSV or JSON. Exports named after the organization are
+// preferred over older ones without it. With a snapshot, only the exports
+// labeled with it are considered.
+func FindInventory(migrationPath, packageType, owner, snapshot string) (string, error) {
+	dir := filepath.Join(migrationPath, "export", packageType)
+	for _, prefix := range []string{fmt.Sprintf("*_%s_%s", owner, packageType), fmt.Sprintf("*_%s", packageType)} {
+		var matches []string
+		for _, ext := range []string{"csv", "json"} {
+			found, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s_packages.%s", prefix, ext)))
+			if err != nil {
+				return "", err
+			}
+			matches = append(matches, found...)
+		}
+		// Names start with the export timestamp, modification times are not kept
+		// when the migration directory is copied to another machine
+		sort.Slice(matches, func(i, j int) bool {
+			return filepath.Base(matches[i]) > filepath.Base(matches[j])
+		})
+		for _, match := range matches {
+			if snapshot == "" {
+				return match, nil
+			}
+			labeled, err := InventorySnapshot(match)
+			if err != nil {
+				return "", err
+			}
+			if labeled == snapshot {
+				return match, nil
+			}
+		}
+	}
+	if snapshot != "" {
+		return "", fmt.Errorf("no export of snapshot %s found for %s in %s", snapshot, packageType, dir)
+	}
+	return "", fmt.Errorf("no export found for %s in %s", packageType, dir)
+}
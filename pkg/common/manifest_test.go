@@ -0,0 +1,71 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManifestInventory(t *testing.T) {
+	manifest := &Manifest{
+		Organization: "mona-actions",
+		PackageType:  "container",
+		Packages: []*ManifestPackage{{
+			Name:                 "app",
+			Repository:           "app-repo",
+			RepositoryVisibility: "private",
+			RepositoryArchived:   "false",
+			Versions: []*ManifestVersion{{
+				Name:      "sha256:abc",
+				Tags:      []string{"1.0.0", "latest"},
+				CreatedAt: "2023-01-05T10:00:00Z",
+				Files:     []ManifestFile{{Name: "app:1.0.0", SHA256: "9f86d081", Size: 52428800}, {Name: "app:latest", Size: 52428800}},
+			}},
+		}},
+	}
+
+	dir := t.TempDir()
+	exportDir := filepath.Join(dir, "export", "container")
+	older := filepath.Join(exportDir, "2024-01-01_00-00-00_mona-actions_container_packages.csv")
+	newer := filepath.Join(exportDir, "2024-02-01_00-00-00_mona-actions_container_packages.json")
+	if err := WriteManifest(manifest, newer); err != nil {
+		t.Fatalf("WriteManifest: %v", err)
+	}
+	if err := os.WriteFile(older, []byte("organization\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A copy of the migration directory does not keep modification times
+	copied := time.Now().Add(time.Hour)
+	if err := os.Chtimes(older, copied, copied); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := FindInventory(dir, "container", "mona-actions", "")
+	if err != nil {
+		t.Fatalf("FindInventory: %v", err)
+	}
+	if found != newer {
+		t.Fatalf("FindInventory = %s, want %s", found, newer)
+	}
+
+	rows, err := ReadInventory(found)
+	if err != nil {
+		t.Fatalf("ReadInventory: %v", err)
+	}
+	want := [][]string{
+		INVENTORY_HEADER,
+		{"mona-actions", "app-repo", "container", "app", "sha256:abc", "app:1.0.0", "2023-01-05T10:00:00Z", "", "9f86d081", "private", "false", "52428800"},
+		{"mona-actions", "app-repo", "container", "app", "sha256:abc", "app:latest", "2023-01-05T10:00:00Z", "", "", "private", "false", "52428800"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("ReadInventory = %v, want %v", rows, want)
+	}
+	if size := RowFileSize(rows[1]); size != 52428800 {
+		t.Errorf("RowFileSize = %d, want 52428800", size)
+	}
+	if size := RowFileSize(rows[1][:FileSizeColumn]); size != 0 {
+		t.Errorf("RowFileSize of a row without sizes = %d, want 0", size)
+	}
+}
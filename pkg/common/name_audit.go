@@ -0,0 +1,37 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+)
+
+// WriteNameAudit writes every name the providers normalized during the command to
+// a CSV file in the migration directory and prints it with the summary. Nothing
+// is written when no name had to be changed.
+func WriteNameAudit(command string) error {
+	changes := providers.NameChanges()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	migrationPath := utils.MigrationPath()
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	filename := filepath.Join(migrationPath, "audit", fmt.Sprintf("%s_%s_normalized_names.csv", timestamp, command))
+
+	rows := [][]string{{"package_type", "field", "original", "normalized"}}
+	for _, change := range changes {
+		rows = append(rows, []string{change.PackageType, change.Field, change.Original, change.Normalized})
+	}
+	if err := files.CreateCSV(rows, filename); err != nil {
+		return fmt.Errorf("failed to write name audit: %w", err)
+	}
+
+	fmt.Printf("🔤 Normalized names: %d\n", len(changes))
+	fmt.Printf("  📄 %s\n", filename)
+	return nil
+}
@@ -0,0 +1,26 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// PERMISSIONS_HEADER is the header of the permissions CSV written by export --permissions.
+// Packages get a row per team with access to their repository, or a single row without team.
+var PERMISSIONS_HEADER = []string{"organization", "package_type", "package_name", "repository", "visibility", "team", "permission"}
+
+// FindPermissions returns the most recent permissions export of a package type
+func FindPermissions(migrationPath, packageType, owner string) (string, error) {
+	dir := filepath.Join(migrationPath, "export", packageType)
+	matches, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("*_%s_%s_permissions.csv", owner, packageType)))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no permissions export found for %s in %s, run export --permissions first", packageType, dir)
+	}
+	// Names start with the export timestamp
+	sort.Strings(matches)
+	return matches[len(matches)-1], nil
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// MINIMUM_SERVER_VERSIONS is the first GitHub Enterprise Server release with a
+// registry for each package type
+var MINIMUM_SERVER_VERSIONS = map[string]string{
+	"container": "3.5",
+	"maven":     "3.0",
+	"npm":       "3.0",
+	"nuget":     "3.0",
+	"rubygems":  "3.0",
+}
+
+// serverVersions caches the detected version of each server hostname
+var (
+	serverVersionsMu sync.Mutex
+	serverVersions   = make(map[string]string)
+)
+
+// ServerVersion returns the version of a GitHub Enterprise Server, detected once per hostname
+func ServerVersion(token, hostname string) (string, error) {
+	serverVersionsMu.Lock()
+	defer serverVersionsMu.Unlock()
+	if version, ok := serverVersions[hostname]; ok {
+		return version, nil
+	}
+	version, err := api.FetchServerVersion(token, hostname)
+	if err != nil {
+		return "", err
+	}
+	serverVersions[hostname] = version
+	return version, nil
+}
+
+// VersionAtLeast compares the major and minor parts of dotted versions
+func VersionAtLeast(version, minimum string) bool {
+	parse := func(v string) [2]int {
+		var parts [2]int
+		for i, part := range strings.SplitN(v, ".", 3) {
+			if i == len(parts) {
+				break
+			}
+			parts[i], _ = strconv.Atoi(part)
+		}
+		return parts
+	}
+	actual, wanted := parse(version), parse(minimum)
+	if actual[0] != wanted[0] {
+		return actual[0] > wanted[0]
+	}
+	return actual[1] >= wanted[1]
+}
+
+// SupportedPackageTypes drops the package types the GitHub Enterprise Server of
+// the "source" or "target" side has no registry for, with a warning, so a run
+// does not fail on every package of an unsupported type. GitHub.com supports
+// every type, and types are kept when the version cannot be detected.
+func SupportedPackageTypes(logger *zap.Logger, side string, packageTypes []string) []string {
+	prefix := "GHMPKG_SOURCE_"
+	if side == "target" {
+		prefix = "GHMPKG_TARGET_"
+	}
+
+	var supported []string
+	for _, packageType := range packageTypes {
+		hostname := utils.GetPackageTypeString(prefix+"HOSTNAME", packageType)
+		if api.EnterpriseApiUrl(hostname) == "" {
+			supported = append(supported, packageType)
+			continue
+		}
+
+		version, err := ServerVersion(utils.GetPackageTypeString(prefix+"TOKEN", packageType), hostname)
+		if err != nil || version == "" {
+			logger.Warn("Could not detect GitHub Enterprise Server version",
+				zap.String("hostname", hostname),
+				zap.Error(err))
+			supported = append(supported, packageType)
+			continue
+		}
+
+		// Legacy docker images are pushed to the Container registry of the target
+		registryType := packageType
+		if side == "target" {
+			registryType = providers.TargetPackageType(packageType)
+		}
+		minimum, ok := MINIMUM_SERVER_VERSIONS[registryType]
+		if !ok || VersionAtLeast(version, minimum) {
+			supported = append(supported, packageType)
+			continue
+		}
+
+		logger.Warn("Package type not supported by GitHub Enterprise Server",
+			zap.String("side", side),
+			zap.String("hostname", hostname),
+			zap.String("version", version),
+			zap.String("packageType", packageType),
+			zap.String("minimumVersion", minimum))
+		pterm.Warning.Println(fmt.Sprintf("⚠️  %s packages are not supported by GitHub Enterprise Server %s on %s (requires %s or later), skipping them", packageType, version, hostname, minimum))
+	}
+	return supported
+}
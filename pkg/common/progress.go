@@ -0,0 +1,89 @@
+package common
+
+import (
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/progress"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+)
+
+// counters fills the counters of a progress event from a report
+func (r *Report) counters(event progress.Event) progress.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.PackagesDone = r.PackageSuccess + r.PackagesSkipped + r.PackagesFailed
+	event.PackagesSuccess = r.PackageSuccess
+	event.PackagesSkipped = r.PackagesSkipped
+	event.PackagesFailed = r.PackagesFailed
+	event.FilesSuccess = r.FileSuccess
+	event.FilesSkipped = r.FilesSkipped
+	event.FilesFailed = r.FilesFailed
+	return event
+}
+
+// publishItems sends the results of a version or package to the progress socket
+func (run *processRun) publishItems(items []Item) {
+	if !progress.Enabled() {
+		return
+	}
+	for _, item := range items {
+		progress.Publish(progress.Event{
+			Type:         progress.ItemDone,
+			Phase:        run.phase,
+			Organization: item.Organization,
+			Repository:   item.Repository,
+			PackageType:  item.PackageType,
+			PackageName:  item.PackageName,
+			Version:      item.Version,
+			Filename:     item.Filename,
+			State:        item.State.String(),
+			SkipReason:   string(item.SkipReason),
+			Error:        item.Error,
+		})
+	}
+}
+
+// mergeVersion adds the results of a version to the report of its package
+func (run *processRun) mergeVersion(packageReport, versionReport *Report) {
+	run.publishItems(versionReport.Items)
+	packageReport.Merge(versionReport)
+}
+
+// mergePackage adds the results of a package to the run report and publishes
+// the counters of the phase
+func (run *processRun) mergePackage(owner, repository, packageType, packageName string, packageReport *Report) {
+	run.report.Merge(packageReport)
+	run.bars.packageDone()
+	if !progress.Enabled() {
+		return
+	}
+	state := providers.Success
+	if packageReport.PackagesFailed > 0 {
+		state = providers.Failed
+	} else if packageReport.PackagesSkipped > 0 {
+		state = providers.Skipped
+	}
+	progress.Publish(run.report.counters(progress.Event{
+		Type:         progress.PackageDone,
+		Phase:        run.phase,
+		Organization: owner,
+		Repository:   repository,
+		PackageType:  packageType,
+		PackageName:  packageName,
+		State:        state.String(),
+		Packages:     run.packages,
+		Files:        run.files,
+	}))
+}
+
+// publishPhase opens or closes the phase on the progress socket
+func (run *processRun) publishPhase(eventType string) {
+	event := progress.Event{Type: eventType, Phase: run.phase, Packages: run.packages, Files: run.files}
+	if eventType == progress.PhaseStarted {
+		run.started = time.Now()
+	} else {
+		event = run.report.counters(event)
+		event.DurationSeconds = int(time.Since(run.started).Seconds())
+	}
+	progress.Publish(event)
+}
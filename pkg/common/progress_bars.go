@@ -0,0 +1,286 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// When pull and sync draw progress bars
+const (
+	ProgressBarsAuto   = "auto"
+	ProgressBarsAlways = "always"
+	ProgressBarsNever  = "never"
+)
+
+// PROGRESS_BARS_MODES are the values accepted by --progress-bars
+var PROGRESS_BARS_MODES = []string{ProgressBarsAuto, ProgressBarsAlways, ProgressBarsNever}
+
+// ProgressBarsMode returns the GHMPKG_PROGRESS_BARS mode, auto when unset
+func ProgressBarsMode() (string, error) {
+	mode := strings.ToLower(viper.GetString("GHMPKG_PROGRESS_BARS"))
+	if mode == "" {
+		return ProgressBarsAuto, nil
+	}
+	if !utils.Contains(PROGRESS_BARS_MODES, mode) {
+		return "", fmt.Errorf("invalid --progress-bars %q, expected one of: %s", mode, strings.Join(PROGRESS_BARS_MODES, ", "))
+	}
+	return mode, nil
+}
+
+// ProgressBarsEnabled reports whether pull and sync draw progress bars instead
+// of a spinner, by default when the console is a terminal
+func ProgressBarsEnabled() bool {
+	mode, err := ProgressBarsMode()
+	if err != nil {
+		return false
+	}
+	switch mode {
+	case ProgressBarsAlways:
+		return true
+	case ProgressBarsNever:
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// transferEstimate tracks the files of a phase and, when the export recorded
+// the size of every one of them, their bytes. The rate and ETA are computed
+// from the files transferred by this run: files skipped because they were
+// completed before or are already on the target count as done but take no time.
+type transferEstimate struct {
+	// sizes are the bytes of every file, keyed like the state. Every tag of a
+	// container version is the same image, its bytes go to the first tag.
+	sizes map[string]int64
+	// sized is set when every file has a size, the estimate then counts bytes
+	sized bool
+
+	totalFiles       int
+	doneFiles        int
+	transferredFiles int
+	totalBytes       int64
+	doneBytes        int64
+	transferredBytes int64
+}
+
+// newTransferEstimate reads the sizes of the files the phase processes
+func newTransferEstimate(packages [][]string) *transferEstimate {
+	estimate := &transferEstimate{sizes: make(map[string]int64), sized: true}
+	versions := make(map[string]bool)
+	for _, row := range packages {
+		if len(row) < 6 {
+			continue
+		}
+		key := state.Key(row[0], row[1], row[2], row[3], row[4], row[5])
+		if _, ok := estimate.sizes[key]; ok {
+			continue
+		}
+		size := RowFileSize(row)
+		if providers.IsImage(row[2]) {
+			versionKey := state.PackageKey(row[0], row[1], row[2], row[3]) + "|" + row[4]
+			if versions[versionKey] {
+				// Known when the first tag of the version is
+				estimate.sizes[key] = 0
+				estimate.totalFiles++
+				continue
+			}
+			versions[versionKey] = true
+		}
+		if size == 0 {
+			estimate.sized = false
+		}
+		estimate.sizes[key] = size
+		estimate.totalFiles++
+		estimate.totalBytes += size
+	}
+	return estimate
+}
+
+// done counts files as processed, transferred by this run or skipped
+func (e *transferEstimate) done(transferred bool, keys ...string) {
+	for _, key := range keys {
+		size := e.sizes[key]
+		e.doneFiles++
+		e.doneBytes += size
+		if transferred {
+			e.transferredFiles++
+			e.transferredBytes += size
+		}
+	}
+}
+
+// remaining returns the work left and the work transferred so far, in bytes
+// when every file has a size and in files otherwise
+func (e *transferEstimate) remaining() (float64, float64) {
+	if e.sized {
+		return float64(e.totalBytes - e.doneBytes), float64(e.transferredBytes)
+	}
+	return float64(e.totalFiles - e.doneFiles), float64(e.transferredFiles)
+}
+
+// rate returns the work transferred per second
+func (e *transferEstimate) rate(elapsed time.Duration) float64 {
+	_, transferred := e.remaining()
+	if elapsed <= 0 {
+		return 0
+	}
+	return transferred / elapsed.Seconds()
+}
+
+// eta returns the time left at the rate of the run so far, false until
+// something was transferred
+func (e *transferEstimate) eta(elapsed time.Duration) (time.Duration, bool) {
+	remaining, _ := e.remaining()
+	rate := e.rate(elapsed)
+	if rate <= 0 {
+		return 0, remaining <= 0
+	}
+	return time.Duration(remaining / rate * float64(time.Second)), true
+}
+
+// summary describes the progress of the phase: the work done, the rate and the ETA
+func (e *transferEstimate) summary(elapsed time.Duration) string {
+	var parts []string
+	rate := e.rate(elapsed)
+	if e.sized {
+		parts = append(parts, fmt.Sprintf("%s of %s", formatBytes(e.doneBytes), formatBytes(e.totalBytes)))
+		parts = append(parts, formatBytes(int64(rate))+"/s")
+	} else {
+		parts = append(parts, fmt.Sprintf("%d of %d files", e.doneFiles, e.totalFiles))
+		parts = append(parts, fmt.Sprintf("%.1f files/s", rate))
+	}
+	if eta, ok := e.eta(elapsed); ok {
+		parts = append(parts, "ETA "+eta.Round(time.Second).String())
+	} else {
+		parts = append(parts, "ETA unknown")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatBytes writes a number of bytes in binary units
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, exponent := float64(bytes)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exponent])
+}
+
+// progressBars draws the progress of pull and sync in the terminal: the
+// packages done out of the packages of the phase, and the files or bytes
+// processed with the file being transferred, the rate and the ETA
+type progressBars struct {
+	mu       sync.Mutex
+	multi    *pterm.MultiPrinter
+	packages *pterm.ProgressbarPrinter
+	files    *pterm.ProgressbarPrinter
+	estimate *transferEstimate
+	started  time.Time
+	current  string
+}
+
+// newProgressBars starts the bars of a phase, nil when they are disabled
+func newProgressBars(phase string, packages int, rows [][]string) *progressBars {
+	if !ProgressBarsEnabled() || packages == 0 {
+		return nil
+	}
+	bars := &progressBars{
+		multi:    pterm.DefaultMultiPrinter.WithUpdateDelay(500 * time.Millisecond),
+		estimate: newTransferEstimate(rows),
+		started:  time.Now(),
+	}
+	bars.packages, _ = pterm.DefaultProgressbar.WithTotal(packages).WithWriter(bars.multi.NewWriter()).Start(fmt.Sprintf("%s packages", strings.ToUpper(phase[:1])+phase[1:]))
+	total := bars.estimate.totalFiles
+	if bars.estimate.sized {
+		total = int(bars.estimate.totalBytes)
+	}
+	bars.files, _ = pterm.DefaultProgressbar.WithTotal(max(total, 1)).WithShowCount(false).WithShowElapsedTime(false).WithWriter(bars.multi.NewWriter()).Start(bars.estimate.summary(0))
+	bars.multi.Start()
+	return bars
+}
+
+// start shows the files of a version as the ones being transferred
+func (b *progressBars) start(packageName, version string, filenames []string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = fmt.Sprintf("%s %s", packageName, version)
+	if len(filenames) == 1 {
+		b.current = filenames[0]
+	} else if len(filenames) > 1 {
+		b.current = fmt.Sprintf("%s (+%d more)", filenames[0], len(filenames)-1)
+	}
+	b.update(nil, false)
+}
+
+// done counts files as processed, transferred by this run or skipped
+func (b *progressBars) done(transferred bool, keys ...string) {
+	if b == nil || len(keys) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.update(keys, transferred)
+}
+
+// packageDone counts a package as processed
+func (b *progressBars) packageDone() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.packages.Increment()
+}
+
+// update refreshes the title of the files bar and moves it forward by the
+// processed files. The bar stops once full, its title is set first.
+func (b *progressBars) update(keys []string, transferred bool) {
+	before := b.progress()
+	b.estimate.done(transferred, keys...)
+	title := b.estimate.summary(time.Since(b.started))
+	if b.current != "" {
+		title += " · " + b.current
+	}
+	b.files.UpdateTitle(title)
+	if added := b.progress() - before; added > 0 {
+		b.files.Add(added)
+	}
+}
+
+// progress is the position of the files bar
+func (b *progressBars) progress() int {
+	if b.estimate.sized {
+		return int(b.estimate.doneBytes)
+	}
+	return b.estimate.doneFiles
+}
+
+// stop ends the bars, they stay on screen with the final counts
+func (b *progressBars) stop() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = ""
+	b.update(nil, false)
+	b.packages.Stop()
+	b.files.Stop()
+	b.multi.Stop()
+}
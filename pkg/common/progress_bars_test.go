@@ -0,0 +1,82 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/spf13/viper"
+)
+
+func TestTransferEstimate(t *testing.T) {
+	row := func(packageType, name, version, filename, size string) []string {
+		return []string{"mona-actions", "app", packageType, name, version, filename, "", "", "", "", "", size}
+	}
+	rows := [][]string{
+		row("npm", "client", "1.0.0", "client-1.0.0.tgz", "1000"),
+		row("npm", "client", "1.1.0", "client-1.1.0.tgz", "3000"),
+		// Every tag of a container version is the same image
+		row("container", "app", "sha256:abc", "app:1.0", "6000"),
+		row("container", "app", "sha256:abc", "app:latest", "6000"),
+	}
+	estimate := newTransferEstimate(rows)
+	if !estimate.sized || estimate.totalFiles != 4 || estimate.totalBytes != 10000 {
+		t.Fatalf("newTransferEstimate = %d files, %d bytes, sized %t, want 4 files of 10000 bytes", estimate.totalFiles, estimate.totalBytes, estimate.sized)
+	}
+	if _, ok := estimate.eta(time.Minute); ok {
+		t.Error("eta is known before anything was transferred")
+	}
+
+	// A file completed by a previous run is done but does not make the rate
+	estimate.done(false, state.Key("mona-actions", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz"))
+	estimate.done(true, state.Key("mona-actions", "app", "container", "app", "sha256:abc", "app:1.0"), state.Key("mona-actions", "app", "container", "app", "sha256:abc", "app:latest"))
+	if estimate.doneBytes != 7000 || estimate.transferredBytes != 6000 {
+		t.Errorf("done = %d bytes, %d transferred, want 7000 and 6000", estimate.doneBytes, estimate.transferredBytes)
+	}
+	if rate := estimate.rate(time.Minute); rate != 100 {
+		t.Errorf("rate = %v, want 100 bytes/s", rate)
+	}
+	if eta, ok := estimate.eta(time.Minute); !ok || eta != 30*time.Second {
+		t.Errorf("eta = %s, %t, want 30s", eta, ok)
+	}
+	if summary := estimate.summary(time.Minute); summary != "6.8 KiB of 9.8 KiB · 100 B/s · ETA 30s" {
+		t.Errorf("summary = %q", summary)
+	}
+
+	// Without the size of every file, the estimate counts files
+	estimate = newTransferEstimate(append(rows, row("maven", "lib", "1.0", "lib-1.0.jar", "")))
+	if estimate.sized {
+		t.Fatal("newTransferEstimate counts bytes with a file of unknown size")
+	}
+	estimate.done(true, state.Key("mona-actions", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz"))
+	if eta, ok := estimate.eta(10 * time.Second); !ok || eta != 40*time.Second {
+		t.Errorf("eta = %s, %t, want 40s for 4 files left at 0.1 files/s", eta, ok)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for bytes, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB", 3 << 40: "3.0 TiB"} {
+		if got := formatBytes(bytes); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", bytes, got, want)
+		}
+	}
+}
+
+func TestProgressBarsMode(t *testing.T) {
+	defer viper.Set("GHMPKG_PROGRESS_BARS", "")
+
+	for value, want := range map[string]string{"": ProgressBarsAuto, "Always": ProgressBarsAlways, "never": ProgressBarsNever} {
+		viper.Set("GHMPKG_PROGRESS_BARS", value)
+		if mode, err := ProgressBarsMode(); err != nil || mode != want {
+			t.Errorf("ProgressBarsMode(%q) = %s, %v, want %s", value, mode, err, want)
+		}
+	}
+	viper.Set("GHMPKG_PROGRESS_BARS", "never")
+	if ProgressBarsEnabled() {
+		t.Error("ProgressBarsEnabled with --progress-bars never")
+	}
+	viper.Set("GHMPKG_PROGRESS_BARS", "fancy")
+	if _, err := ProgressBarsMode(); err == nil {
+		t.Error("ProgressBarsMode accepted an unknown mode")
+	}
+}
@@ -0,0 +1,167 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// ReportDocument is the document written by --report-json
+type ReportDocument struct {
+	Command      string    `json:"command"`
+	Organization string    `json:"organization"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	Error        string    `json:"error,omitempty"`
+	// Status is the outcome of the run against its success criteria, Criteria
+	// the criteria it did not meet
+	Status   string        `json:"status,omitempty"`
+	Criteria []string      `json:"criteria,omitempty"`
+	Run      *run.Metadata `json:"run,omitempty"`
+	Report   *Report       `json:"report"`
+	Items    []Item        `json:"items"`
+}
+
+// WriteReportJSON writes the report with every item result to the path set in
+// GHMPKG_REPORT_JSON, nothing is written when it is not set. runErr is the error
+// the command stopped with, if any, so partial runs can be told apart.
+func WriteReportJSON(command string, startTime time.Time, report *Report, runErr error) error {
+	path := viper.GetString("GHMPKG_REPORT_JSON")
+	if path == "" || report == nil {
+		return nil
+	}
+
+	status, violations, evaluateErr := report.Evaluate()
+	if evaluateErr != nil {
+		return evaluateErr
+	}
+	if ExitCode(runErr) == ExitFatal {
+		status, violations = StatusError, nil
+	}
+
+	report.mu.Lock()
+	document := ReportDocument{
+		Command:      command,
+		Organization: viper.GetString("GHMPKG_SOURCE_ORGANIZATION"),
+		StartedAt:    startTime.UTC(),
+		FinishedAt:   time.Now().UTC(),
+		Status:       status,
+		Criteria:     violations,
+		Run:          run.Current(),
+		Report:       report,
+		Items:        append([]Item{}, report.Items...),
+	}
+	// Failures are in the report, the error is the one the run stopped on
+	if ExitCode(runErr) == ExitFatal {
+		document.Error = runErr.Error()
+	}
+	content, err := json.MarshalIndent(document, "", "  ")
+	report.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	fmt.Printf("📄 JSON report: %s\n", path)
+	return nil
+}
+
+// Failures returns the failures of the run the document reports, like
+// Report.Failures when it was written
+func (d *ReportDocument) Failures() error {
+	if d.Report == nil {
+		return nil
+	}
+	status := d.Status
+	// Reports written before the status was recorded
+	if status == "" || status == StatusError {
+		status = StatusSuccess
+		if d.Report.PackagesFailed+d.Report.VersionsFailed+d.Report.FilesFailed > 0 {
+			status = StatusPartial
+		}
+	}
+	return failures(status, d.Criteria, d.Report)
+}
+
+// ReadReportDocument reads back a whole report written by --report-json
+func ReadReportDocument(path string) (*ReportDocument, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var document ReportDocument
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	return &document, nil
+}
+
+// ReadReportJSON reads a report written by --report-json, returning the command
+// that wrote it and its items
+func ReadReportJSON(path string) (string, []Item, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var document struct {
+		Command string `json:"command"`
+		Items   []Item `json:"items"`
+	}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return "", nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	return document.Command, document.Items, nil
+}
+
+// matchesItem reports whether an inventory row belongs to the item. Items
+// without a filename (or version) stand for the whole version (or package).
+func matchesItem(row []string, item Item) bool {
+	if len(row) < 6 {
+		return false
+	}
+	if row[0] != item.Organization || row[1] != item.Repository || row[2] != item.PackageType || row[3] != item.PackageName {
+		return false
+	}
+	if item.Version != "" && row[4] != item.Version {
+		return false
+	}
+	return item.Filename == "" || row[5] == item.Filename
+}
+
+// FilterFailed returns the inventory rows that ended in Failed in the report
+// written by a previous run of the command
+func FilterFailed(packages [][]string, reportPath, command string) ([][]string, int, error) {
+	reportCommand, items, err := ReadReportJSON(reportPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	if reportCommand != command {
+		return nil, 0, fmt.Errorf("report %s was written by %s, not %s", reportPath, reportCommand, command)
+	}
+
+	var failed []Item
+	for _, item := range items {
+		if item.State == providers.Failed {
+			failed = append(failed, item)
+		}
+	}
+
+	var rows [][]string
+	for _, row := range packages {
+		for _, item := range failed {
+			if matchesItem(row, item) {
+				rows = append(rows, row)
+				break
+			}
+		}
+	}
+	return rows, len(failed), nil
+}
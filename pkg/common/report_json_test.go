@@ -0,0 +1,98 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+)
+
+func TestResultStateJSON(t *testing.T) {
+	for _, state := range []providers.ResultState{providers.Success, providers.Skipped, providers.Failed} {
+		content, err := json.Marshal(Item{State: state})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), `"state":"`+state.String()+`"`) {
+			t.Errorf("Marshal(%s) = %s, want the state by name", state, content)
+		}
+		var item Item
+		if err := json.Unmarshal(content, &item); err != nil || item.State != state {
+			t.Errorf("Unmarshal(%s) = %s, %v, want %s", content, item.State, err, state)
+		}
+	}
+	var item Item
+	if err := json.Unmarshal([]byte(`{"state":"Done"}`), &item); err == nil {
+		t.Error("Unmarshal of an unknown state succeeded")
+	}
+}
+
+func TestMatchesItem(t *testing.T) {
+	row := []string{"mona", "app-repo", "maven", "com.example.app", "1.0", "app-1.0.jar"}
+	tests := []struct {
+		name string
+		item Item
+		want bool
+	}{
+		{"file", NewItem("mona", "app-repo", "maven", "com.example.app", "1.0", "app-1.0.jar", providers.Failed, nil), true},
+		{"other file", NewItem("mona", "app-repo", "maven", "com.example.app", "1.0", "app-1.0.pom", providers.Failed, nil), false},
+		{"version", NewItem("mona", "app-repo", "maven", "com.example.app", "1.0", "", providers.Failed, nil), true},
+		{"other version", NewItem("mona", "app-repo", "maven", "com.example.app", "2.0", "", providers.Failed, nil), false},
+		{"package", NewItem("mona", "app-repo", "maven", "com.example.app", "", "", providers.Failed, nil), true},
+		{"other package", NewItem("mona", "app-repo", "maven", "com.example.lib", "", "", providers.Failed, nil), false},
+		{"other repository", NewItem("mona", "lib-repo", "maven", "com.example.app", "", "", providers.Failed, nil), false},
+	}
+	for _, test := range tests {
+		if got := matchesItem(row, test.item); got != test.want {
+			t.Errorf("%s: matchesItem = %v, want %v", test.name, got, test.want)
+		}
+	}
+	if matchesItem(row[:5], NewItem("mona", "app-repo", "maven", "com.example.app", "", "", providers.Failed, nil)) {
+		t.Error("matchesItem matched a short row")
+	}
+}
+
+func TestFilterFailed(t *testing.T) {
+	packages := [][]string{
+		{"mona", "app-repo", "maven", "com.example.app", "1.0", "app-1.0.pom"},
+		{"mona", "app-repo", "maven", "com.example.app", "1.0", "app-1.0.jar"},
+		{"mona", "app-repo", "maven", "com.example.app", "2.0", "app-2.0.jar"},
+		{"mona", "lib-repo", "npm", "lib", "1.0.0", "lib-1.0.0.tgz"},
+		{"mona", "lib-repo", "npm", "lib", "2.0.0", "lib-2.0.0.tgz"},
+		{"mona", "web-repo", "npm", "web", "1.0.0", "web-1.0.0.tgz"},
+	}
+	items := []Item{
+		// A version that failed before its files were processed
+		NewItem("mona", "app-repo", "maven", "com.example.app", "1.0", "", providers.Failed, nil),
+		NewItem("mona", "app-repo", "maven", "com.example.app", "2.0", "app-2.0.jar", providers.Success, nil),
+		// A package that failed before its versions were processed
+		NewItem("mona", "lib-repo", "npm", "lib", "", "", providers.Failed, nil),
+		NewItem("mona", "web-repo", "npm", "web", "1.0.0", "web-1.0.0.tgz", providers.Skipped, nil),
+	}
+	content, err := json.Marshal(map[string]interface{}{"command": "sync", "items": items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(reportPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, failed, err := FilterFailed(packages, reportPath, "sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{packages[0], packages[1], packages[3], packages[4]}
+	if failed != 2 || !reflect.DeepEqual(rows, want) {
+		t.Errorf("FilterFailed = %v, %d failed, want %v, 2 failed", rows, failed, want)
+	}
+
+	// The report of another command lists the results of another phase
+	if _, _, err := FilterFailed(packages, reportPath, "pull"); err == nil || !strings.Contains(err.Error(), "written by sync") {
+		t.Errorf("FilterFailed with a sync report for pull = %v, want an error", err)
+	}
+}
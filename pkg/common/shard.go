@@ -0,0 +1,122 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/spf13/viper"
+)
+
+// DefaultShardSplitTags is the number of tags above which the versions of a
+// container package are dealt to the shards one digest at a time
+const DefaultShardSplitTags = 100
+
+// Shard is the share of the inventory a run processes when a migration is
+// split into runs side by side, the Index-th of Count, counted from 1
+type Shard struct {
+	Index int
+	Count int
+}
+
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+// ParseShard reads GHMPKG_SHARD, e.g. 2/4. It is zero when the run is not sharded.
+func ParseShard() (Shard, error) {
+	value := strings.TrimSpace(viper.GetString("GHMPKG_SHARD"))
+	if value == "" {
+		return Shard{}, nil
+	}
+	index, count, ok := strings.Cut(value, "/")
+	shard := Shard{}
+	var indexErr, countErr error
+	shard.Index, indexErr = strconv.Atoi(strings.TrimSpace(index))
+	shard.Count, countErr = strconv.Atoi(strings.TrimSpace(count))
+	if !ok || indexErr != nil || countErr != nil || shard.Count < 1 || shard.Index < 1 || shard.Index > shard.Count {
+		return Shard{}, fmt.Errorf("invalid --shard %q, expected the shard and the number of shards such as 2/4", value)
+	}
+	return shard, nil
+}
+
+// ShardSplitTags reads GHMPKG_SHARD_SPLIT_TAGS, DefaultShardSplitTags when
+// unset. 0 keeps every package in a single shard.
+func ShardSplitTags() (int, error) {
+	value := strings.TrimSpace(viper.GetString("GHMPKG_SHARD_SPLIT_TAGS"))
+	if value == "" {
+		return DefaultShardSplitTags, nil
+	}
+	splitTags, err := strconv.Atoi(value)
+	if err != nil || splitTags < 0 {
+		return 0, fmt.Errorf("invalid --shard-split-tags %q, expected a number of tags, 0 to never split a package", value)
+	}
+	return splitTags, nil
+}
+
+// SplitPackages returns the keys, as state.PackageKey, of the container
+// packages with more than splitTags tags, whose versions are dealt to the
+// shards one at a time
+func SplitPackages(rows [][]string, splitTags int) map[string]bool {
+	split := make(map[string]bool)
+	if splitTags <= 0 {
+		return split
+	}
+	tags := make(map[string]int)
+	for _, row := range rows {
+		if len(row) >= 6 && row[2] == "container" {
+			key := state.PackageKey(row[0], row[1], row[2], row[3])
+			if tags[key]++; tags[key] > splitTags {
+				split[key] = true
+			}
+		}
+	}
+	return split
+}
+
+// AssignShards deals the inventory rows to the shards round robin, in the
+// order their packages first appear, and returns the shard of every row
+// counted from 0. A container package with more than splitTags tags would
+// weigh on a single shard, its versions are dealt one at a time instead: every
+// tag of a version, a digest, goes to the same shard since its image is pulled
+// and pushed once for all of them.
+func AssignShards(rows [][]string, shards, splitTags int) []int {
+	assigned := make([]int, len(rows))
+	if shards <= 1 {
+		return assigned
+	}
+	split := SplitPackages(rows, splitTags)
+	shardOf := make(map[string]int)
+	for i, row := range rows {
+		if len(row) < 5 {
+			continue
+		}
+		unit := state.PackageKey(row[0], row[1], row[2], row[3])
+		if split[unit] {
+			unit += "|" + row[4]
+		}
+		shard, ok := shardOf[unit]
+		if !ok {
+			shard = len(shardOf) % shards
+			shardOf[unit] = shard
+		}
+		assigned[i] = shard
+	}
+	return assigned
+}
+
+// Filter returns the inventory rows dealt to the shard. Every shard of a
+// migration must filter the same rows: the same export and filters.
+func (s Shard) Filter(rows [][]string, splitTags int) [][]string {
+	if s.Count <= 1 {
+		return rows
+	}
+	var kept [][]string
+	for i, shard := range AssignShards(rows, s.Count, splitTags) {
+		if shard == s.Index-1 {
+			kept = append(kept, rows[i])
+		}
+	}
+	return kept
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseShard(t *testing.T) {
+	defer viper.Set("GHMPKG_SHARD", "")
+	for value, want := range map[string]Shard{"": {}, "2/4": {2, 4}, " 1 / 1 ": {1, 1}} {
+		viper.Set("GHMPKG_SHARD", value)
+		if shard, err := ParseShard(); err != nil || shard != want {
+			t.Errorf("ParseShard(%q) = %v, %v, want %v", value, shard, err, want)
+		}
+	}
+	for _, value := range []string{"2", "0/4", "5/4", "a/b", "1/0"} {
+		viper.Set("GHMPKG_SHARD", value)
+		if _, err := ParseShard(); err == nil {
+			t.Errorf("ParseShard(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestAssignShards(t *testing.T) {
+	rows := [][]string{
+		{"mona", "repo", "npm", "web", "1.0.0", "web-1.0.0.tgz"},
+		{"mona", "repo", "npm", "web", "1.1.0", "web-1.1.0.tgz"},
+		{"mona", "repo", "npm", "api", "1.0.0", "api-1.0.0.tgz"},
+	}
+	// An image with 3 versions, the first one tagged twice
+	for i, tag := range []string{"1.0", "latest", "1.1", "1.2"} {
+		digest := fmt.Sprintf("sha256:%d", max(i, 1))
+		rows = append(rows, []string{"mona", "repo", "container", "app", digest, "app:" + tag})
+	}
+
+	// Packages are dealt whole when none has too many tags
+	if got, want := AssignShards(rows, 2, 0), []int{0, 0, 1, 0, 0, 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AssignShards without split = %v, want %v", got, want)
+	}
+	// The versions of app are dealt one at a time, the tags of a digest together
+	if got, want := AssignShards(rows, 2, 3), []int{0, 0, 1, 0, 0, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AssignShards with split = %v, want %v", got, want)
+	}
+	if split := SplitPackages(rows, 3); len(split) != 1 || !split["mona|repo|container|app"] {
+		t.Errorf("SplitPackages = %v, want app", split)
+	}
+
+	var total int
+	for index := 1; index <= 2; index++ {
+		total += len(Shard{index, 2}.Filter(rows, 3))
+	}
+	if total != len(rows) {
+		t.Errorf("the shards hold %d rows, want %d", total, len(rows))
+	}
+	if got := (Shard{}).Filter(rows, 3); len(got) != len(rows) {
+		t.Errorf("an unsharded run keeps %d rows, want %d", len(got), len(rows))
+	}
+}
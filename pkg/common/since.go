@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Optional inventory columns following the six positional ones, older exports do not have them
+const (
+	CreatedAtColumn = 6
+	UpdatedAtColumn = 7
+	ChecksumColumn  = 8
+	// The visibility and archived state of the repository a package is linked to
+	RepositoryVisibilityColumn = 9
+	RepositoryArchivedColumn   = 10
+	// The size in bytes of the file, of the image for container packages
+	FileSizeColumn = 11
+)
+
+// ParseSince reads the GHMPKG_SINCE cutoff, a date (2023-01-01) or an RFC 3339
+// timestamp. It is zero when no cutoff is set.
+func ParseSince() (time.Time, error) {
+	value := viper.GetString("GHMPKG_SINCE")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if since, err := time.Parse("2006-01-02", value); err == nil {
+		return since, nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q, expected a date such as 2023-01-01 or an RFC 3339 timestamp", value)
+	}
+	return since, nil
+}
+
+// FilterSince returns the inventory rows of versions created at or after the
+// cutoff. Rows without a creation time are kept and counted as undated.
+func FilterSince(packages [][]string, since time.Time) (rows [][]string, undated int) {
+	if since.IsZero() {
+		return packages, 0
+	}
+	for _, row := range packages {
+		if len(row) <= CreatedAtColumn || row[CreatedAtColumn] == "" {
+			undated++
+			rows = append(rows, row)
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, row[CreatedAtColumn])
+		if err != nil {
+			undated++
+			rows = append(rows, row)
+			continue
+		}
+		if !createdAt.Before(since) {
+			rows = append(rows, row)
+		}
+	}
+	return rows, undated
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/spf13/viper"
+)
+
+// snapshotPattern is what a snapshot name may contain, it is written to the
+// comments of CSV files
+var snapshotPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Snapshot reads GHMPKG_SNAPSHOT, the name an export is labeled with and the
+// export pull, sync and verify operate on. It is empty when no snapshot is set.
+func Snapshot() (string, error) {
+	snapshot := viper.GetString("GHMPKG_SNAPSHOT")
+	if snapshot != "" && !snapshotPattern.MatchString(snapshot) {
+		return "", fmt.Errorf("invalid --snapshot %q, expected letters, digits, dots, dashes and underscores such as wave-3-freeze", snapshot)
+	}
+	return snapshot, nil
+}
+
+// RequiredSnapshot reads GHMPKG_SNAPSHOT like Snapshot, failing when it is
+// empty while GHMPKG_REQUIRE_SNAPSHOT is set so that nobody operates on another
+// inventory than the frozen one during a cutover wave
+func RequiredSnapshot() (string, error) {
+	snapshot, err := Snapshot()
+	if err != nil {
+		return "", err
+	}
+	if snapshot == "" && viper.GetBool("GHMPKG_REQUIRE_SNAPSHOT") {
+		return "", fmt.Errorf("--snapshot is required, name the export to operate on")
+	}
+	return snapshot, nil
+}
+
+// InventorySnapshot returns the snapshot an export was labeled with, from the
+// run of a JSON manifest or the comments of a CSV file. It is empty for exports
+// without one.
+func InventorySnapshot(filename string) (string, error) {
+	if strings.HasSuffix(filename, ".json") {
+		manifest, err := ReadManifest(filename)
+		if err != nil {
+			return "", err
+		}
+		if manifest.Run == nil {
+			return "", nil
+		}
+		return manifest.Run.Snapshot, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	// The run metadata comments precede the header
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "#")
+		if !ok {
+			break
+		}
+		if snapshot, ok := strings.CutPrefix(strings.TrimSpace(line), run.SnapshotPrefix); ok {
+			return strings.TrimSpace(snapshot), nil
+		}
+	}
+	return "", scanner.Err()
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/spf13/viper"
+)
+
+func TestFindInventorySnapshot(t *testing.T) {
+	defer viper.Set("GHMPKG_SNAPSHOT", "")
+	dir := t.TempDir()
+	exportDir := filepath.Join(dir, "export", "npm")
+	wave3 := filepath.Join(exportDir, "2024-01-01_00-00-00_mona-actions_npm_packages.csv")
+	wave4 := filepath.Join(exportDir, "2024-02-01_00-00-00_mona-actions_npm_packages.json")
+	latest := filepath.Join(exportDir, "2024-03-01_00-00-00_mona-actions_npm_packages.csv")
+
+	viper.Set("GHMPKG_SNAPSHOT", "wave-3-freeze")
+	if err := files.CreateCSV([][]string{INVENTORY_HEADER}, wave3); err != nil {
+		t.Fatal(err)
+	}
+	manifest := &Manifest{Organization: "mona-actions", PackageType: "npm", Run: &run.Metadata{ID: "cutover", Snapshot: "wave-4"}}
+	if err := WriteManifest(manifest, wave4); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("GHMPKG_SNAPSHOT", "")
+	if err := files.CreateCSV([][]string{INVENTORY_HEADER}, latest); err != nil {
+		t.Fatal(err)
+	}
+
+	for snapshot, want := range map[string]string{"": latest, "wave-3-freeze": wave3, "wave-4": wave4} {
+		found, err := FindInventory(dir, "npm", "mona-actions", snapshot)
+		if err != nil {
+			t.Fatalf("FindInventory(%q): %v", snapshot, err)
+		}
+		if found != want {
+			t.Errorf("FindInventory(%q) = %s, want %s", snapshot, found, want)
+		}
+	}
+	if _, err := FindInventory(dir, "npm", "mona-actions", "wave-5"); err == nil {
+		t.Error("FindInventory(wave-5) succeeded, want an error")
+	}
+}
+
+func TestRequiredSnapshot(t *testing.T) {
+	defer viper.Set("GHMPKG_SNAPSHOT", "")
+	defer viper.Set("GHMPKG_REQUIRE_SNAPSHOT", false)
+
+	if snapshot, err := RequiredSnapshot(); snapshot != "" || err != nil {
+		t.Errorf("RequiredSnapshot() = %q, %v, want no snapshot", snapshot, err)
+	}
+	viper.Set("GHMPKG_REQUIRE_SNAPSHOT", true)
+	if _, err := RequiredSnapshot(); err == nil {
+		t.Error("RequiredSnapshot() succeeded without a snapshot, want an error")
+	}
+	viper.Set("GHMPKG_SNAPSHOT", "wave-3-freeze")
+	if snapshot, err := RequiredSnapshot(); snapshot != "wave-3-freeze" || err != nil {
+		t.Errorf("RequiredSnapshot() = %q, %v, want wave-3-freeze", snapshot, err)
+	}
+	viper.Set("GHMPKG_SNAPSHOT", "wave 3")
+	if _, err := RequiredSnapshot(); err == nil {
+		t.Error("RequiredSnapshot() accepted a name with a space")
+	}
+}
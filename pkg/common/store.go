@@ -0,0 +1,146 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/inventory"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	StoreCSV    = "csv"
+	StoreSQLite = "sqlite"
+)
+
+// STORES are the values of GHMPKG_STORE
+var STORES = []string{StoreCSV, StoreSQLite}
+
+// ErrNoInventory is wrapped by the errors of LoadInventory when the package
+// type was not exported
+var ErrNoInventory = errors.New("package type not exported")
+
+// InventoryStore reads GHMPKG_STORE, csv by default
+func InventoryStore() (string, error) {
+	store := viper.GetString("GHMPKG_STORE")
+	if store == "" {
+		return StoreCSV, nil
+	}
+	if !utils.Contains(STORES, store) {
+		return "", fmt.Errorf("unsupported store: %s (expected one of %v)", store, STORES)
+	}
+	return store, nil
+}
+
+// OpenStore opens the SQLite inventory of the migration directory with
+// GHMPKG_STORE sqlite, it returns nil for the CSV inventory
+func OpenStore(migrationPath string) (*inventory.Store, error) {
+	store, err := InventoryStore()
+	if store != StoreSQLite || err != nil {
+		return nil, err
+	}
+	return inventory.Open(migrationPath)
+}
+
+// LoadInventory reads the most recent export of a package type and returns
+// where it was read from: the SQLite inventory with GHMPKG_STORE sqlite, as
+// DATABASE#EXPORT, the export directory otherwise or when the package type is
+// not in the database
+func LoadInventory(logger *zap.Logger, migrationPath, packageType, owner, snapshot string) (string, [][]string, error) {
+	store, err := OpenStore(migrationPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if store != nil {
+		rows, name, err := store.Latest(owner, packageType, snapshot)
+		if err == nil {
+			return store.Path() + "#" + name, rows, nil
+		}
+		if !errors.Is(err, inventory.ErrNoExport) {
+			return "", nil, err
+		}
+		logger.Warn("Package type not in the inventory database, reading the export directory",
+			zap.String("packageType", packageType),
+			zap.Error(err))
+	}
+
+	filename, err := FindInventory(migrationPath, packageType, owner, snapshot)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrNoInventory, err)
+	}
+	rows, err := ReadInventory(filename)
+	return filename, rows, err
+}
+
+// StoreExport records the rows of an export, named like its files, in the
+// SQLite inventory with GHMPKG_STORE sqlite and returns the database written
+// to. It does nothing otherwise.
+func StoreExport(migrationPath, name, owner, packageType, snapshot string, rows [][]string) (string, error) {
+	store, err := OpenStore(migrationPath)
+	if store == nil || err != nil {
+		return "", err
+	}
+	if err := store.WriteExport(name, owner, packageType, snapshot, rows); err != nil {
+		return "", err
+	}
+	return store.Path(), nil
+}
+
+// recordStatuses records the status of the files of a version in the SQLite
+// inventory. A file fails with the error of its item, or of the callback when
+// it failed before recording its files. The other files are completed when
+// the whole version is, like in the state file, and incomplete otherwise.
+func (r *processRun) recordStatuses(report *Report, keys []string, callbackErr error) {
+	if r.store == nil {
+		return
+	}
+	failed := make(map[string]error)
+	for _, item := range report.Items {
+		if item.Filename != "" && item.State == providers.Failed {
+			failed[state.Key(item.Organization, item.Repository, item.PackageType, item.PackageName, item.Version, item.Filename)] = errors.New(item.Error)
+		}
+	}
+	if callbackErr != nil && len(failed) == 0 {
+		for _, key := range keys {
+			failed[key] = callbackErr
+		}
+	}
+	status := inventory.Completed
+	if len(failed) > 0 {
+		status = inventory.Incomplete
+	}
+	var rest []string
+	for _, key := range keys {
+		fileErr, ok := failed[key]
+		if !ok {
+			rest = append(rest, key)
+			continue
+		}
+		if err := r.store.SetStatus(r.phase, inventory.Failed, fileErr, key); err != nil {
+			r.logger.Warn("Failed to record the status of a file", zap.String("key", key), zap.Error(err))
+		}
+	}
+	if err := r.store.SetStatus(r.phase, status, nil, rest...); err != nil {
+		r.logger.Warn("Failed to record the status of files", zap.Error(err))
+	}
+}
+
+// completed reports whether a previous run of the phase completed a file,
+// according to the state file or the SQLite inventory
+func (r *processRun) completed(key string) bool {
+	if r.checkpoint.IsCompleted(r.phase, key) {
+		return true
+	}
+	if r.store == nil {
+		return false
+	}
+	status, _, err := r.store.Status(r.phase, key)
+	if err != nil {
+		r.logger.Warn("Failed to read the status of a file", zap.String("key", key), zap.Error(err))
+	}
+	return status == inventory.Completed
+}
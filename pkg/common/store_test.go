@@ -0,0 +1,53 @@
+//go:build cgo
+
+package common
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestLoadInventoryStore(t *testing.T) {
+	defer viper.Set("GHMPKG_STORE", "")
+	dir := t.TempDir()
+	stored := [][]string{INVENTORY_HEADER, {"mona-actions", "app-repo", "npm", "client", "1.0.0", "client-1.0.0.tgz", "", "", "", "private", "false", "10"}}
+	exported := [][]string{INVENTORY_HEADER, {"mona-actions", "lib-repo", "maven", "lib", "2.0.0", "lib-2.0.0.jar", "", "", "", "private", "false", "20"}}
+	csv := filepath.Join(dir, "export", "maven", "2024-01-01_00-00-00_mona-actions_maven_packages.csv")
+	if err := files.CreateCSV(exported, csv); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("GHMPKG_STORE", StoreSQLite)
+	if _, err := StoreExport(dir, "2024-01-01_00-00-00_mona-actions_npm_packages", "mona-actions", "npm", "", stored); err != nil {
+		t.Fatal(err)
+	}
+
+	source, rows, err := LoadInventory(zap.NewNop(), dir, "npm", "mona-actions", "")
+	if err != nil || !reflect.DeepEqual(rows, stored) {
+		t.Errorf("LoadInventory(npm) = %v, %v, want %v", rows, err, stored)
+	}
+	if want := filepath.Join(dir, "inventory.db") + "#2024-01-01_00-00-00_mona-actions_npm_packages"; source != want {
+		t.Errorf("LoadInventory(npm) source = %s, want %s", source, want)
+	}
+
+	// Package types the database has no export of are read from the export directory
+	source, rows, err = LoadInventory(zap.NewNop(), dir, "maven", "mona-actions", "")
+	if err != nil || source != csv || !reflect.DeepEqual(rows, exported) {
+		t.Errorf("LoadInventory(maven) = %s, %v, %v, want %s, %v", source, rows, err, csv, exported)
+	}
+
+	if _, _, err := LoadInventory(zap.NewNop(), dir, "nuget", "mona-actions", ""); !errors.Is(err, ErrNoInventory) {
+		t.Errorf("LoadInventory(nuget) error = %v, want ErrNoInventory", err)
+	}
+
+	viper.Set("GHMPKG_STORE", "postgres")
+	if _, _, err := LoadInventory(zap.NewNop(), dir, "npm", "mona-actions", ""); err == nil {
+		t.Error("LoadInventory with an unsupported store succeeded")
+	}
+}
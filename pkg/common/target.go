@@ -0,0 +1,120 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"go.uber.org/zap"
+)
+
+// PackageFiles maps package name -> version -> filenames, as returned by GraphQL
+type PackageFiles map[string]map[string][]string
+
+// FetchPackageFiles lists the files of every version of the packages of a type
+// of an organization
+func FetchPackageFiles(logger *zap.Logger, owner, token, packageType string) (PackageFiles, error) {
+	nodes, _, err := providers.FetchFromGraphQL(logger, owner, token, packageType)
+	if err != nil {
+		return nil, err
+	}
+	result := make(PackageFiles)
+	for _, pkg := range nodes {
+		versions := make(map[string][]string)
+		for _, version := range pkg.Versions.Nodes {
+			for _, file := range version.Files.Nodes {
+				versions[string(version.Version)] = append(versions[string(version.Version)], string(file.Name))
+			}
+		}
+		result[string(pkg.Name)] = versions
+	}
+	return result, nil
+}
+
+// TargetInventoryDir is the directory inventory-target writes the inventory of
+// a package type of the target organization to
+func TargetInventoryDir(migrationPath, packageType string) string {
+	return filepath.Join(migrationPath, "target", packageType)
+}
+
+// FindTargetInventory returns the most recent inventory of a package type of the
+// target organization, an empty path when inventory-target was not run
+func FindTargetInventory(migrationPath, packageType, owner string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(TargetInventoryDir(migrationPath, packageType), fmt.Sprintf("*_%s_%s_packages.csv", owner, packageType)))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+	// Names start with the inventory timestamp
+	sort.Strings(matches)
+	return matches[len(matches)-1], nil
+}
+
+// TargetContent is the content the target organization had when it was
+// inventoried, before the migration: packages and their version labels,
+// tags for container images
+type TargetContent struct {
+	// File is the inventory the content was read from
+	File     string
+	packages map[string]map[string]bool
+}
+
+// LoadTargetContent reads the most recent target inventory of a package type,
+// nil when there is none
+func LoadTargetContent(migrationPath, packageType, owner string) (*TargetContent, error) {
+	path, err := FindTargetInventory(migrationPath, packageType, owner)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	rows, err := ReadInventory(path)
+	if err != nil {
+		return nil, err
+	}
+	content := &TargetContent{File: path, packages: make(map[string]map[string]bool)}
+	for i, row := range rows {
+		if i == 0 || len(row) < 6 {
+			continue
+		}
+		key := targetKey(row[2], row[3])
+		if content.packages[key] == nil {
+			content.packages[key] = make(map[string]bool)
+		}
+		content.packages[key][VersionLabel(row[2], row[4], row[5])] = true
+	}
+	return content, nil
+}
+
+// Package names are compared case insensitively, the way the registries
+// reject a name that only differs by case
+func targetKey(packageType, packageName string) string {
+	return providers.TargetPackageType(packageType) + "/" + strings.ToLower(packageName)
+}
+
+// HasPackage reports whether the target had a package of this name
+func (c *TargetContent) HasPackage(packageType, packageName string) bool {
+	return c != nil && c.packages[targetKey(packageType, packageName)] != nil
+}
+
+// HasVersion reports whether the target had this version, or container tag, of a package
+func (c *TargetContent) HasVersion(packageType, packageName, label string) bool {
+	return c != nil && c.packages[targetKey(packageType, packageName)][label]
+}
+
+// Conflicts returns the inventory rows of the source that the target already
+// had under the name they are synced to: the version of a package merged from
+// another source, or published on the target before the migration
+func (c *TargetContent) Conflicts(rows [][]string) [][]string {
+	var conflicts [][]string
+	for _, row := range rows {
+		if len(row) < 6 {
+			continue
+		}
+		packageType := row[2]
+		targetName := providers.TargetPackageName(packageType, row[3])
+		if c.HasVersion(packageType, targetName, VersionLabel(packageType, row[4], row[5])) {
+			conflicts = append(conflicts, row)
+		}
+	}
+	return conflicts
+}
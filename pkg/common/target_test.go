@@ -0,0 +1,57 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestTargetContentConflicts(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+
+	migrationPath := t.TempDir()
+	dir := TargetInventoryDir(migrationPath, "container")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	older := "organization,repository,package_type,package_name,package_version,package_filename\nocto,,container,app,sha256:old,app:0.9\n"
+	inventory := "organization,repository,package_type,package_name,package_version,package_filename\n" +
+		"octo,tools,container,App,sha256:aaa,App:1.0\n" +
+		"octo,tools,container,App,sha256:aaa,App:latest\n"
+	for name, content := range map[string]string{
+		"2024-01-01_00-00-00_octo_container_packages.csv": older,
+		"2024-02-01_00-00-00_octo_container_packages.csv": inventory,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := LoadTargetContent(migrationPath, "container", "octo")
+	if err != nil || content == nil {
+		t.Fatalf("LoadTargetContent = %v, %v", content, err)
+	}
+	if filepath.Base(content.File) != "2024-02-01_00-00-00_octo_container_packages.csv" {
+		t.Errorf("read %s, want the most recent inventory", content.File)
+	}
+	if !content.HasPackage("docker", "app") || content.HasPackage("container", "web") {
+		t.Error("HasPackage does not match the inventory")
+	}
+
+	rows := [][]string{
+		{"mona", "tools", "docker", "app", "1.0", "app:1.0"},
+		{"mona", "tools", "container", "app", "sha256:bbb", "app:2.0"},
+		{"mona", "tools", "container", "app", "sha256:old", "app:0.9"},
+	}
+	if got := content.Conflicts(rows); !reflect.DeepEqual(got, rows[:1]) {
+		t.Errorf("Conflicts = %v, want %v", got, rows[:1])
+	}
+
+	if missing, err := LoadTargetContent(migrationPath, "npm", "octo"); missing != nil || err != nil {
+		t.Errorf("LoadTargetContent without an inventory = %v, %v", missing, err)
+	}
+}
@@ -0,0 +1,138 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// The target API calls of a transfer, variables so tests can fake the target
+var (
+	fetchTransferPackage  = api.FetchTargetPackage
+	fetchTransferVersions = fetchTargetVersions
+	deleteTransferPackage = api.DeleteTargetPackage
+)
+
+// prepareTransfer readies a package for sync --transfer. The source and target
+// organization are the same, so the package the upload publishes again is the
+// one being transferred. Images are pushed again into it; the packages of the
+// other registries are deleted first, with --transfer-delete, once every one of
+// their versions is known to be staged. It returns the versions the target
+// already has, like checkExisting, or the reason the package is skipped.
+func (run *processRun) prepareTransfer(owner, repository, packageType, packageName string) (*targetVersions, providers.SkipReason, error) {
+	logger := run.logger
+	if !providers.Transferred(repository) {
+		return nil, providers.SkipNotTransferred, nil
+	}
+	// A renamed package does not collide with the one it is copied from, which
+	// is left in place like in any migration
+	targetName := providers.TargetPackageName(packageType, packageName)
+	if targetName != packageName {
+		existing, skip, err := run.checkExisting(owner, repository, packageType, packageName)
+		if skip {
+			return nil, providers.SkipPackageExistsOnTarget, err
+		}
+		return existing, "", err
+	}
+	// The package was prepared by the run the checkpoint records
+	if run.resume && run.checkpoint.HasPackage(run.phase, state.PackageKey(owner, repository, packageType, packageName)) {
+		return nil, "", nil
+	}
+
+	targetType := providers.TargetPackageType(packageType)
+	pkg, err := fetchTransferPackage(targetType, packageName)
+	if err != nil {
+		logger.Error("Error fetching package", zap.Error(err))
+		return nil, "", err
+	}
+	if pkg == nil {
+		return nil, "", nil
+	}
+	existing, err := fetchTransferVersions(targetType, packageName)
+	if err != nil {
+		logger.Error("Error listing versions", zap.Error(err))
+		return nil, "", err
+	}
+
+	// An earlier run already published the package from its new repository
+	targetRepository := providers.TargetRepository(repository)
+	if strings.EqualFold(pkg.GetRepository().GetName(), targetRepository) {
+		if existing.HasAll(run.inventory, owner, repository, packageType, packageName) {
+			logger.Info("Package already transferred, skipping...", zap.String("package", packageName))
+			return nil, providers.SkipPackageExistsOnTarget, nil
+		}
+		logger.Info("Package partially transferred, syncing missing versions", zap.String("package", packageName))
+		return existing, "", nil
+	}
+
+	// Images are pushed again under their tags into the package they are in, with
+	// their source label pointing at the new repository: nothing is deleted, the
+	// tags the inventory does not have and the untagged versions are left in place
+	if providers.IsImage(packageType) {
+		logger.Info("Pushing the images of the package again to link it to its new repository",
+			zap.String("package", packageName),
+			zap.String("targetRepository", targetRepository))
+		return nil, "", nil
+	}
+
+	// The other registries refuse to publish a version again, the package has to
+	// be deleted first, which is only done when asked for
+	if !providers.TransferDeleting() {
+		err := fmt.Errorf("%s package %s can only be published again from %s once deleted, pass --transfer-delete to delete it", packageType, packageName, targetRepository)
+		logger.Error("Package can't be transferred", zap.String("package", packageName), zap.Error(err))
+		return nil, "", err
+	}
+	// A deleted public package may not let its name be reused, which would leave
+	// nothing to publish the package again under
+	if pkg.GetVisibility() == "public" {
+		err := fmt.Errorf("public package %s can't be deleted to be published again, its name may not be reusable once deleted", packageName)
+		logger.Error("Package can't be transferred", zap.String("package", packageName), zap.Error(err))
+		return nil, "", err
+	}
+	if err := run.checkTransferable(existing, owner, repository, packageType, packageName); err != nil {
+		logger.Error("Package can't be transferred", zap.String("package", packageName), zap.Error(err))
+		return nil, "", err
+	}
+
+	pterm.Warning.Printf("Deleting %s package %s to publish it again from %s\n", packageType, packageName, targetRepository)
+	logger.Warn("Deleting package to transfer it",
+		zap.String("package", packageName),
+		zap.String("repository", repository),
+		zap.String("targetRepository", targetRepository))
+	if err := deleteTransferPackage(targetType, packageName); err != nil {
+		logger.Error("Error deleting package", zap.Error(err))
+		return nil, "", err
+	}
+	return nil, "", nil
+}
+
+// checkTransferable checks that deleting a package loses nothing: every version
+// it has is in the inventory, and every file of the inventory was staged by pull
+// and matches the checksum the export recorded
+func (run *processRun) checkTransferable(existing *targetVersions, owner, repository, packageType, packageName string) error {
+	inventory := make(map[string]bool)
+	for _, version := range run.inventory.Versions(owner, repository, packageType, packageName) {
+		for _, filename := range run.inventory.Files(owner, repository, packageType, packageName, version) {
+			checksum := run.inventory.Checksum(owner, repository, packageType, packageName, version, filename)
+			if err := providers.VerifyStaged(run.logger, owner, packageType, packageName, version, filename, checksum); err != nil {
+				return err
+			}
+		}
+		if packageType == "nuget" {
+			version = providers.NormalizeName(packageType, providers.VersionField, version)
+		}
+		inventory[version] = true
+	}
+
+	for version := range existing.names {
+		if !inventory[version] {
+			return fmt.Errorf("version %s is not in the inventory, export the package again before transferring it", version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// stage writes a pulled file and the staging manifest of its version directory
+func stage(t *testing.T, dir, packageType, packageName, version string, files map[string]string) {
+	t.Helper()
+	versionDir := filepath.Join(dir, "packages", "mona", packageType, packageName, version)
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"scheme": "normalized", "files": {`
+	first := true
+	for filename, content := range files {
+		if err := os.WriteFile(filepath.Join(versionDir, filename), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !first {
+			manifest += ", "
+		}
+		manifest += `"` + filename + `": "` + filename + `"`
+		first = false
+	}
+	if err := os.WriteFile(filepath.Join(versionDir, providers.StagingManifestFile), []byte(manifest+"}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func transferRun(t *testing.T) (*processRun, string) {
+	t.Helper()
+	dir := t.TempDir()
+	mapping := filepath.Join(dir, "mapping.yaml")
+	if err := os.WriteFile(mapping, []byte("repositories:\n  monolith: api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("GHMPKG_MAPPING_FILE", mapping)
+	viper.Set("GHMPKG_MIGRATION_PATH", dir)
+	viper.Set("GHMPKG_TRANSFER", true)
+
+	run := &processRun{logger: zap.NewNop(), transfer: true, inventory: newInventoryIndex([][]string{
+		{"mona", "monolith", "npm", "lib", "1.0.0", "lib-1.0.0.tgz", "", "", checksum("tgz")},
+		{"mona", "monolith", "container", "app", "sha256:111", "app:1.0"},
+		{"mona", "monolith", "nuget", "Lib", "1.0", "Lib.1.0.nupkg"},
+		{"mona", "monolith", "nuget", "Lib", "1.0", "Lib.1.0.snupkg"},
+	})}
+	return run, dir
+}
+
+func TestCheckTransferable(t *testing.T) {
+	defer viper.Reset()
+	run, dir := transferRun(t)
+
+	existing := newTargetVersions("npm", []*github.PackageVersion{{Name: github.String("1.0.0")}})
+	if err := run.checkTransferable(existing, "mona", "monolith", "npm", "lib"); err == nil {
+		t.Error("a package that was not pulled is transferable")
+	}
+	// The version directory alone is not enough, the file must be staged
+	os.MkdirAll(filepath.Join(dir, "packages", "mona", "npm", "lib", "1.0.0"), 0755)
+	if err := run.checkTransferable(existing, "mona", "monolith", "npm", "lib"); err == nil {
+		t.Error("a version directory without its files is transferable")
+	}
+	stage(t, dir, "npm", "lib", "1.0.0", map[string]string{"lib-1.0.0.tgz": "corrupt"})
+	if err := run.checkTransferable(existing, "mona", "monolith", "npm", "lib"); err == nil {
+		t.Error("a staged file not matching the exported checksum is transferable")
+	}
+	stage(t, dir, "npm", "lib", "1.0.0", map[string]string{"lib-1.0.0.tgz": "tgz"})
+	if err := run.checkTransferable(existing, "mona", "monolith", "npm", "lib"); err != nil {
+		t.Errorf("checkTransferable = %v", err)
+	}
+	existing = newTargetVersions("npm", []*github.PackageVersion{{Name: github.String("1.0.0")}, {Name: github.String("2.0.0")}})
+	if err := run.checkTransferable(existing, "mona", "monolith", "npm", "lib"); err == nil {
+		t.Error("a package with versions missing from the inventory is transferable")
+	}
+
+	// Every file of a NuGet version is looked up under the version it was pulled
+	// as, the target lists it normalized
+	stage(t, dir, "nuget", "Lib", "1.0", map[string]string{"Lib.1.0.nupkg": "nupkg", "Lib.1.0.snupkg": "snupkg"})
+	existing = newTargetVersions("nuget", []*github.PackageVersion{{Name: github.String("1.0.0")}})
+	if err := run.checkTransferable(existing, "mona", "monolith", "nuget", "Lib"); err != nil {
+		t.Errorf("checkTransferable of a NuGet package = %v", err)
+	}
+}
+
+func TestPrepareTransfer(t *testing.T) {
+	defer viper.Reset()
+	fetch, list, remove := fetchTransferPackage, fetchTransferVersions, deleteTransferPackage
+	defer func() {
+		fetchTransferPackage, fetchTransferVersions, deleteTransferPackage = fetch, list, remove
+	}()
+
+	run, dir := transferRun(t)
+	// No token is set, any request to the target would fail
+	if _, skip, err := run.prepareTransfer("mona", "web", "npm", "lib"); skip != providers.SkipNotTransferred || err != nil {
+		t.Errorf("a repository the mapping does not move = %q, %v", skip, err)
+	}
+
+	visibility := "private"
+	fetchTransferPackage = func(packageType, packageName string) (*github.Package, error) {
+		return &github.Package{
+			Name:       github.String(packageName),
+			Visibility: github.String(visibility),
+			Repository: &github.Repository{Name: github.String("monolith")},
+		}, nil
+	}
+	fetchTransferVersions = func(packageType, packageName string) (*targetVersions, error) {
+		if packageType == "container" {
+			return newTargetVersions(packageType, []*github.PackageVersion{
+				{Name: github.String("sha256:111"), Metadata: &github.PackageMetadata{Container: &github.PackageContainerMetadata{Tags: []string{"1.0"}}}},
+				{Name: github.String("sha256:222"), Metadata: &github.PackageMetadata{Container: &github.PackageContainerMetadata{}}},
+			}), nil
+		}
+		return newTargetVersions(packageType, []*github.PackageVersion{{Name: github.String("1.0.0")}}), nil
+	}
+	var deleted []string
+	deleteTransferPackage = func(packageType, packageName string) error {
+		deleted = append(deleted, packageName)
+		return nil
+	}
+
+	// Images are pushed again into their package, untagged versions included
+	if existing, _, err := run.prepareTransfer("mona", "monolith", "container", "app"); existing != nil || err != nil || len(deleted) > 0 {
+		t.Errorf("prepareTransfer of an image = %v, %v, deleted %v", existing, err, deleted)
+	}
+
+	stage(t, dir, "npm", "lib", "1.0.0", map[string]string{"lib-1.0.0.tgz": "tgz"})
+	if _, _, err := run.prepareTransfer("mona", "monolith", "npm", "lib"); err == nil || len(deleted) > 0 {
+		t.Errorf("prepareTransfer without --transfer-delete = %v, deleted %v", err, deleted)
+	}
+	viper.Set("GHMPKG_TRANSFER_DELETE", true)
+	visibility = "public"
+	if _, _, err := run.prepareTransfer("mona", "monolith", "npm", "lib"); err == nil || len(deleted) > 0 {
+		t.Errorf("prepareTransfer of a public package = %v, deleted %v", err, deleted)
+	}
+	visibility = "private"
+	if _, _, err := run.prepareTransfer("mona", "monolith", "npm", "lib"); err != nil || len(deleted) != 1 {
+		t.Errorf("prepareTransfer with --transfer-delete = %v, deleted %v", err, deleted)
+	}
+
+	deleteTransferPackage = func(packageType, packageName string) error {
+		return errors.New("deleting is refused")
+	}
+	if _, _, err := run.prepareTransfer("mona", "monolith", "npm", "lib"); err == nil {
+		t.Error("a failed deletion is not reported")
+	}
+}
@@ -0,0 +1,341 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/spf13/viper"
+)
+
+// semver is a leniently parsed version: any number of numeric parts (maven and
+// nuget versions are not always three), an optional pre-release and build metadata
+type semver struct {
+	parts      []int
+	prerelease []string
+}
+
+var semverPattern = regexp.MustCompile(`^[vV]?(\d+(?:\.\d+)*)(?:[-.]?([0-9A-Za-z][0-9A-Za-z.-]*))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+func parseSemver(version string) (semver, bool) {
+	match := semverPattern.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return semver{}, false
+	}
+	var v semver
+	for _, part := range strings.Split(match[1], ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return semver{}, false
+		}
+		v.parts = append(v.parts, n)
+	}
+	if match[2] != "" {
+		v.prerelease = strings.Split(match[2], ".")
+	}
+	return v, true
+}
+
+// CompareVersions orders two versions the semver way, ok is false when either
+// of them is not a version
+func CompareVersions(a, b string) (int, bool) {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return va.compare(vb), true
+}
+
+// LatestVersion returns the highest of the versions the semver way, the highest
+// release when there is one as npm's latest tag would, empty when none is a version
+func LatestVersion(versions []string) string {
+	var latest, latestRelease string
+	var latestVersion, latestReleaseVersion semver
+	for _, version := range versions {
+		v, ok := parseSemver(version)
+		if !ok {
+			continue
+		}
+		if latest == "" || v.compare(latestVersion) > 0 {
+			latest, latestVersion = version, v
+		}
+		if len(v.prerelease) == 0 && (latestRelease == "" || v.compare(latestReleaseVersion) > 0) {
+			latestRelease, latestReleaseVersion = version, v
+		}
+	}
+	if latestRelease != "" {
+		return latestRelease
+	}
+	return latest
+}
+
+func (v semver) part(i int) int {
+	if i < len(v.parts) {
+		return v.parts[i]
+	}
+	return 0
+}
+
+// compare orders versions the semver way, a pre-release sorts before its release
+func (v semver) compare(other semver) int {
+	for i := 0; i < max(len(v.parts), len(other.parts)); i++ {
+		if a, b := v.part(i), other.part(i); a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < min(len(v.prerelease), len(other.prerelease)); i++ {
+		a, b := v.prerelease[i], other.prerelease[i]
+		if a == b {
+			continue
+		}
+		an, aErr := strconv.Atoi(a)
+		bn, bErr := strconv.Atoi(b)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case a < b:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return len(v.prerelease) - len(other.prerelease)
+}
+
+// constraint matches a single version
+type constraint struct {
+	text  string
+	match func(semver) bool
+}
+
+var constraintPattern = regexp.MustCompile(`latest:\s*\d+|(?:[<>]=?|!=|=|\^|~)?\s*[^\s,<>=!^~]+`)
+
+func parseConstraint(text string) (constraint, error) {
+	operator := ""
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if rest, ok := strings.CutPrefix(text, candidate); ok {
+			operator, text = candidate, strings.TrimSpace(rest)
+			break
+		}
+	}
+
+	// 1.x and 1.2.* match every version with the same leading parts
+	if wildcard := strings.TrimRight(text, ".xX*"); wildcard != text && operator == "" {
+		if wildcard == "" {
+			return constraint{text: text, match: func(semver) bool { return true }}, nil
+		}
+		prefix, ok := parseSemver(wildcard)
+		if !ok || len(prefix.prerelease) > 0 {
+			return constraint{}, fmt.Errorf("invalid version constraint %q", text)
+		}
+		return constraint{text: text, match: func(v semver) bool {
+			for i, part := range prefix.parts {
+				if v.part(i) != part {
+					return false
+				}
+			}
+			return true
+		}}, nil
+	}
+
+	bound, ok := parseSemver(text)
+	if !ok {
+		return constraint{}, fmt.Errorf("invalid version constraint %q", operator+text)
+	}
+	text = operator + text
+	switch operator {
+	case ">=":
+		return constraint{text, func(v semver) bool { return v.compare(bound) >= 0 }}, nil
+	case "<=":
+		return constraint{text, func(v semver) bool { return v.compare(bound) <= 0 }}, nil
+	case ">":
+		return constraint{text, func(v semver) bool { return v.compare(bound) > 0 }}, nil
+	case "<":
+		return constraint{text, func(v semver) bool { return v.compare(bound) < 0 }}, nil
+	case "!=":
+		return constraint{text, func(v semver) bool { return v.compare(bound) != 0 }}, nil
+	case "^":
+		// Changes that do not modify the left-most non-zero part: ^1.4 is >=1.4.0 <2.0.0, ^0.2 is >=0.2.0 <0.3.0
+		return constraint{text, func(v semver) bool {
+			if v.compare(bound) < 0 {
+				return false
+			}
+			for i := 0; i < max(len(bound.parts), 1); i++ {
+				if v.part(i) != bound.part(i) {
+					return false
+				}
+				if bound.part(i) != 0 {
+					return true
+				}
+			}
+			return true
+		}}, nil
+	case "~":
+		// Patch level changes when a minor version is given, minor ones otherwise: ~1.4 is >=1.4.0 <1.5.0
+		fixed := min(len(bound.parts), 2)
+		return constraint{text, func(v semver) bool {
+			if v.compare(bound) < 0 {
+				return false
+			}
+			for i := 0; i < fixed; i++ {
+				if v.part(i) != bound.part(i) {
+					return false
+				}
+			}
+			return true
+		}}, nil
+	default:
+		return constraint{text, func(v semver) bool { return v.compare(bound) == 0 }}, nil
+	}
+}
+
+// VersionFilter selects package versions with the GHMPKG_VERSIONS constraints.
+// Every constraint must match (e.g. ">=2.0.0, <3.0.0") and "latest:N" then keeps
+// the N highest remaining versions of each package. Versions that are not semver
+// never match a range, so only "latest:N" alone keeps them in play.
+type VersionFilter struct {
+	Constraints []string
+	constraints []constraint
+	latest      int
+}
+
+// NewVersionFilter builds the filter from the settings, failing on invalid constraints
+func NewVersionFilter() (*VersionFilter, error) {
+	filter := &VersionFilter{}
+	for _, value := range viper.GetStringSlice("GHMPKG_VERSIONS") {
+		if rest := strings.Trim(constraintPattern.ReplaceAllString(value, ""), " ,"); rest != "" {
+			return nil, fmt.Errorf("invalid version constraint %q", value)
+		}
+		for _, text := range constraintPattern.FindAllString(value, -1) {
+			text = strings.TrimSpace(text)
+			filter.Constraints = append(filter.Constraints, text)
+			if count, ok := strings.CutPrefix(text, "latest:"); ok {
+				latest, err := strconv.Atoi(strings.TrimSpace(count))
+				if err != nil || latest < 1 {
+					return nil, fmt.Errorf("invalid version constraint %q", text)
+				}
+				filter.latest = latest
+				continue
+			}
+			c, err := parseConstraint(text)
+			if err != nil {
+				return nil, err
+			}
+			filter.constraints = append(filter.constraints, c)
+		}
+	}
+	return filter, nil
+}
+
+// IsEmpty reports whether the filter selects every version
+func (f *VersionFilter) IsEmpty() bool {
+	return len(f.constraints) == 0 && f.latest == 0
+}
+
+// String describes the constraints for the console
+func (f *VersionFilter) String() string {
+	return strings.Join(f.Constraints, ", ")
+}
+
+func (f *VersionFilter) matches(version string) bool {
+	if len(f.constraints) == 0 {
+		return true
+	}
+	v, ok := parseSemver(version)
+	if !ok {
+		return false
+	}
+	for _, c := range f.constraints {
+		if !c.match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the versions of a single package the filter keeps
+func (f *VersionFilter) Select(versions []string) map[string]bool {
+	var matching []string
+	seen := make(map[string]bool)
+	for _, version := range versions {
+		if !seen[version] && f.matches(version) {
+			matching = append(matching, version)
+		}
+		seen[version] = true
+	}
+	if f.latest > 0 && len(matching) > f.latest {
+		// Highest first, versions that are not semver rank last
+		sort.SliceStable(matching, func(i, j int) bool {
+			a, aOk := parseSemver(matching[i])
+			b, bOk := parseSemver(matching[j])
+			if aOk != bOk {
+				return aOk
+			}
+			return aOk && a.compare(b) > 0
+		})
+		matching = matching[:f.latest]
+	}
+	selected := make(map[string]bool, len(matching))
+	for _, version := range matching {
+		selected[version] = true
+	}
+	return selected
+}
+
+// VersionLabel is the version a row is filtered on: the tag for container
+// images, whose version column holds the manifest digest, the version otherwise
+func VersionLabel(packageType, version, filename string) string {
+	if providers.IsImage(packageType) {
+		if _, tag, ok := strings.Cut(filename, ":"); ok {
+			return tag
+		}
+	}
+	return version
+}
+
+// FilterRows returns the inventory rows whose version the filter keeps, "latest:N" counting per package
+func (f *VersionFilter) FilterRows(packages [][]string) [][]string {
+	if f.IsEmpty() {
+		return packages
+	}
+	labels := make(map[string][]string)
+	for _, row := range packages {
+		if len(row) > 5 {
+			key := row[0] + "|" + row[1] + "|" + row[2] + "|" + row[3]
+			labels[key] = append(labels[key], VersionLabel(row[2], row[4], row[5]))
+		}
+	}
+	selected := make(map[string]map[string]bool)
+	for key, versions := range labels {
+		selected[key] = f.Select(versions)
+	}
+	var rows [][]string
+	for _, row := range packages {
+		if len(row) > 5 && selected[row[0]+"|"+row[1]+"|"+row[2]+"|"+row[3]][VersionLabel(row[2], row[4], row[5])] {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestVersionFilterSelect(t *testing.T) {
+	versions := []string{"1.3.9", "1.4.0", "1.4.7", "1.5.0", "2.0.0-rc.1", "2.0.0", "2.1.0", "nightly-20150101"}
+	tests := []struct {
+		constraints []string
+		want        []string
+	}{
+		{[]string{">=2.0.0"}, []string{"2.0.0", "2.1.0"}},
+		{[]string{"^1.4"}, []string{"1.4.0", "1.4.7", "1.5.0"}},
+		{[]string{"~1.4"}, []string{"1.4.0", "1.4.7"}},
+		{[]string{">= 1.4.0, <2.0.0"}, []string{"1.4.0", "1.4.7", "1.5.0", "2.0.0-rc.1"}},
+		{[]string{"1.x"}, []string{"1.3.9", "1.4.0", "1.4.7", "1.5.0"}},
+		{[]string{"latest:2"}, []string{"2.0.0", "2.1.0"}},
+		{[]string{"<2.0.0", "latest:1"}, []string{"2.0.0-rc.1"}},
+		{[]string{"1.4.7"}, []string{"1.4.7"}},
+	}
+	for _, test := range tests {
+		viper.Set("GHMPKG_VERSIONS", test.constraints)
+		filter, err := NewVersionFilter()
+		if err != nil {
+			t.Fatalf("NewVersionFilter(%v): %v", test.constraints, err)
+		}
+		selected := filter.Select(versions)
+		var got []string
+		for _, version := range versions {
+			if selected[version] {
+				got = append(got, version)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v selected %v, want %v", test.constraints, got, test.want)
+		}
+	}
+	viper.Set("GHMPKG_VERSIONS", nil)
+}
+
+func TestVersionFilterRejectsInvalidConstraints(t *testing.T) {
+	for _, constraints := range []string{">=abc", "latest:0", "^"} {
+		viper.Set("GHMPKG_VERSIONS", []string{constraints})
+		if _, err := NewVersionFilter(); err == nil {
+			t.Errorf("expected %q to be rejected", constraints)
+		}
+	}
+	viper.Set("GHMPKG_VERSIONS", nil)
+}
+
+func TestVersionFilterRowsUseContainerTags(t *testing.T) {
+	viper.Set("GHMPKG_VERSIONS", []string{"latest:1"})
+	defer viper.Set("GHMPKG_VERSIONS", nil)
+	filter, err := NewVersionFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]string{
+		{"org", "repo", "container", "app", "sha256:aaa", "app:1.0.0"},
+		{"org", "repo", "container", "app", "sha256:bbb", "app:1.1.0"},
+		{"org", "repo", "npm", "lib", "0.9.0", "lib-0.9.0.tgz"},
+		{"org", "repo", "npm", "lib", "0.10.0", "lib-0.10.0.tgz"},
+	}
+	want := [][]string{rows[1], rows[3]}
+	if got := filter.FilterRows(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterRows = %v, want %v", got, want)
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	tests := []struct {
+		versions []string
+		want     string
+	}{
+		{[]string{"1.10.0", "1.9.3", "2.0.0-rc.1", "1.2.0"}, "1.10.0"},
+		{[]string{"2.0.0-beta.1", "2.0.0-beta.10", "2.0.0-beta.2"}, "2.0.0-beta.10"},
+		{[]string{"nightly", "0.1.0"}, "0.1.0"},
+		{[]string{"nightly"}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := LatestVersion(test.versions); got != test.want {
+			t.Errorf("LatestVersion(%v) = %q, want %q", test.versions, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Defaults of the warm-up profile, used when GHMPKG_WARMUP is set without the details
+const (
+	DefaultWarmupOperations = 200
+	DefaultWarmupInterval   = 2 * time.Second
+)
+
+// WarmupProfile is how the first operations of a sync into a brand new
+// organization are paced, as abuse detection trips when thousands of publishes
+// arrive at once. It starts with one version at a time and the full interval
+// between them, and ramps up to the configured concurrency and no pacing over
+// its operations.
+type WarmupProfile struct {
+	Operations  int
+	Concurrency int
+	Interval    time.Duration
+}
+
+// ReadWarmupProfile reads the warm-up profile, nil when it is not enabled
+func ReadWarmupProfile(concurrency int) (*WarmupProfile, error) {
+	if !viper.GetBool("GHMPKG_WARMUP") {
+		return nil, nil
+	}
+	operations := DefaultWarmupOperations
+	if viper.IsSet("GHMPKG_WARMUP_OPERATIONS") {
+		operations = viper.GetInt("GHMPKG_WARMUP_OPERATIONS")
+	}
+	if operations < 1 {
+		return nil, fmt.Errorf("invalid --warmup-operations %d, expected a positive number", operations)
+	}
+	interval := DefaultWarmupInterval
+	if value := viper.GetString("GHMPKG_WARMUP_INTERVAL"); value != "" {
+		var err error
+		if interval, err = time.ParseDuration(value); err != nil || interval < 0 {
+			return nil, fmt.Errorf("invalid --warmup-interval %q, expected a duration such as 2s", value)
+		}
+	}
+	return &WarmupProfile{Operations: operations, Concurrency: concurrency, Interval: interval}, nil
+}
+
+// progress is the share of the warm-up done after started operations, from 0 to 1
+func (p *WarmupProfile) progress(started int) float64 {
+	if started >= p.Operations {
+		return 1
+	}
+	return float64(started) / float64(p.Operations)
+}
+
+// Allowed is the number of operations that may run at once after started operations
+func (p *WarmupProfile) Allowed(started int) int {
+	allowed := 1 + int(float64(p.Concurrency-1)*p.progress(started))
+	if allowed < 1 {
+		return 1
+	}
+	return allowed
+}
+
+// Pacing is the time to leave between the start of two operations after started operations
+func (p *WarmupProfile) Pacing(started int) time.Duration {
+	return time.Duration(float64(p.Interval) * (1 - p.progress(started)))
+}
+
+// warmup applies the warm-up profile to the operations of a sync
+type warmup struct {
+	*WarmupProfile
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	started   int
+	active    int
+	lastStart time.Time
+}
+
+// newWarmup reads the warm-up profile, nil when it is not enabled
+func newWarmup(concurrency int) (*warmup, error) {
+	profile, err := ReadWarmupProfile(concurrency)
+	if profile == nil || err != nil {
+		return nil, err
+	}
+	w := &warmup{WarmupProfile: profile}
+	w.cond = sync.NewCond(&w.mu)
+	return w, nil
+}
+
+// allowed is the number of operations that may run at once at this point of the warm-up
+func (w *warmup) allowed() int {
+	return w.Allowed(w.started)
+}
+
+// pacing is the time to leave between the start of two operations at this point of the warm-up
+func (w *warmup) pacing() time.Duration {
+	return w.Pacing(w.started)
+}
+
+// acquire blocks until the warm-up lets another operation start
+func (w *warmup) acquire() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		for w.active >= w.allowed() {
+			w.cond.Wait()
+		}
+		wait := w.pacing() - time.Since(w.lastStart)
+		if wait <= 0 {
+			break
+		}
+		w.mu.Unlock()
+		time.Sleep(wait)
+		w.mu.Lock()
+	}
+	w.started++
+	w.active++
+	w.lastStart = time.Now()
+	if w.started == w.Operations {
+		pterm.Info.Printf("🔥 Warm-up complete after %d versions, running at full concurrency\n", w.Operations)
+	}
+}
+
+// release marks an operation started with acquire as done
+func (w *warmup) release() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.active--
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
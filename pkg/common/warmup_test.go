@@ -0,0 +1,55 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestWarmupRamp(t *testing.T) {
+	viper.Set("GHMPKG_WARMUP", true)
+	viper.Set("GHMPKG_WARMUP_OPERATIONS", 10)
+	viper.Set("GHMPKG_WARMUP_INTERVAL", "1s")
+	defer func() {
+		viper.Set("GHMPKG_WARMUP", false)
+		viper.Set("GHMPKG_WARMUP_OPERATIONS", nil)
+		viper.Set("GHMPKG_WARMUP_INTERVAL", "")
+	}()
+
+	w, err := newWarmup(5)
+	if err != nil || w == nil {
+		t.Fatalf("newWarmup = %v, %v", w, err)
+	}
+	steps := []struct {
+		started int
+		allowed int
+		pacing  time.Duration
+	}{
+		{0, 1, time.Second},
+		{5, 3, 500 * time.Millisecond},
+		{10, 5, 0},
+		{50, 5, 0},
+	}
+	for _, step := range steps {
+		w.started = step.started
+		if w.allowed() != step.allowed || w.pacing() != step.pacing {
+			t.Errorf("after %d operations: allowed %d pacing %s, want %d and %s", step.started, w.allowed(), w.pacing(), step.allowed, step.pacing)
+		}
+	}
+
+	viper.Set("GHMPKG_WARMUP_INTERVAL", "soon")
+	if _, err := newWarmup(5); err == nil {
+		t.Error("newWarmup accepted an invalid interval")
+	}
+}
+
+func TestWarmupDisabled(t *testing.T) {
+	w, err := newWarmup(5)
+	if err != nil || w != nil {
+		t.Fatalf("newWarmup = %v, %v, want nil when disabled", w, err)
+	}
+	// A disabled warm-up never blocks
+	w.acquire()
+	w.release()
+}
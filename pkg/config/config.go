@@ -0,0 +1,312 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Sources of a setting, besides the config file named by its path
+const (
+	SourceEnvironment = "environment"
+	SourceDefault     = "default"
+)
+
+// Severities of a problem
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Setting is the effective value of a setting and where it comes from
+type Setting struct {
+	Key    Key
+	Value  string
+	Source string
+}
+
+// Problem is something wrong with a setting
+type Problem struct {
+	Name     string
+	Severity string
+	Message  string
+}
+
+// Result is the validated configuration
+type Result struct {
+	File     string
+	Settings []Setting
+	Problems []Problem
+}
+
+// HasErrors reports whether a setting is invalid or unknown
+func (r *Result) HasErrors() bool {
+	for _, problem := range r.Problems {
+		if problem.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Result) problem(name, severity, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, Problem{Name: name, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// readFile reads the settings of a .env or YAML file, keyed by their upper case name
+func readFile(file string) (map[string]string, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	values := make(map[string]string)
+	for _, name := range v.AllKeys() {
+		value := v.Get(name)
+		if list, ok := value.([]interface{}); ok {
+			var entries []string
+			for _, entry := range list {
+				entries = append(entries, fmt.Sprint(entry))
+			}
+			values[strings.ToUpper(name)] = strings.Join(entries, ",")
+			continue
+		}
+		values[strings.ToUpper(name)] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+// environment returns the settings set in the environment, which take precedence over the file
+func environment() map[string]string {
+	values := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, "GHMPKG_") || name == "RETRY_MAX" || name == "RETRY_DELAY" {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// Validate checks every setting of the config file, if any, and of the
+// environment: unknown names, with the closest known one, and values of the
+// wrong kind. The result lists the effective value of every setting.
+func Validate(file string) (*Result, error) {
+	result := &Result{File: file}
+	values := make(map[string]Setting)
+	if file != "" {
+		fileValues, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range fileValues {
+			values[name] = Setting{Value: value, Source: file}
+		}
+	}
+	for name, value := range environment() {
+		values[name] = Setting{Value: value, Source: SourceEnvironment}
+	}
+
+	var extra []Setting
+	for name, setting := range values {
+		key, ok := Lookup(name)
+		if !ok {
+			if suggestion := suggest(name); suggestion != "" {
+				result.problem(name, SeverityError, "unknown setting in %s, did you mean %s?", setting.Source, suggestion)
+			} else {
+				result.problem(name, SeverityError, "unknown setting in %s", setting.Source)
+			}
+			continue
+		}
+		if err := checkValue(key, setting.Value); err != nil {
+			result.problem(name, SeverityError, "%v", err)
+		}
+		setting.Key = key
+		values[name] = setting
+		if !isDocumented(name) {
+			extra = append(extra, setting)
+		}
+	}
+	checkPackageTypes(result, values)
+	sort.Slice(result.Problems, func(i, j int) bool { return result.Problems[i].Name < result.Problems[j].Name })
+	sort.Slice(extra, func(i, j int) bool { return extra[i].Key.Name < extra[j].Key.Name })
+
+	for _, key := range KEYS {
+		if setting, ok := values[key.Name]; ok {
+			result.Settings = append(result.Settings, setting)
+		} else if key.Default != "" {
+			result.Settings = append(result.Settings, Setting{Key: key, Value: key.Default, Source: SourceDefault})
+		}
+	}
+	result.Settings = append(result.Settings, extra...)
+	return result, nil
+}
+
+// isDocumented reports whether a setting is one of KEYS, not a variant of a side or package type
+func isDocumented(name string) bool {
+	for _, key := range KEYS {
+		if key.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkValue checks a value has the kind of its setting
+func checkValue(key Key, value string) error {
+	if value == "" {
+		return nil
+	}
+	switch key.Kind {
+	case Bool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+	case Int:
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("%q is not a positive number", value)
+		}
+	case Duration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 1h", value)
+		}
+	case Age:
+		days, isDays := strings.CutSuffix(value, "d")
+		if _, err := strconv.Atoi(days); isDays && err == nil {
+			return nil
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%q is not a duration such as 12h or 7d", value)
+		}
+	case Date:
+		if _, err := time.Parse("2006-01-02", value); err == nil {
+			return nil
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("%q is not a date such as 2023-01-01 or an RFC 3339 timestamp", value)
+		}
+	case Enum:
+		if !utils.Contains(key.Values, value) {
+			return fmt.Errorf("%q is not one of %s", value, strings.Join(key.Values, ", "))
+		}
+	case List:
+		if len(key.Values) == 0 {
+			return nil
+		}
+		for _, entry := range splitList(value) {
+			if !utils.Contains(key.Values, entry) {
+				return fmt.Errorf("%q is not one of %s", entry, strings.Join(key.Values, ", "))
+			}
+		}
+	case PackageTypes:
+		aliases, err := common.PackageTypeAliases()
+		if err != nil {
+			return err
+		}
+		// Package types can also be separated by spaces
+		for _, entry := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+			packageType := strings.ToLower(entry)
+			if _, ok := aliases[packageType]; !ok && !utils.Contains(common.SUPPORTED_PACKAGE_TYPES, packageType) {
+				return fmt.Errorf("%q is not a package type, expected one of %s or an alias", entry, strings.Join(common.SUPPORTED_PACKAGE_TYPES, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated value, ignoring empty entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// checkPackageTypes warns about the deprecated GHMPKG_PACKAGE_TYPE, which is
+// only read when GHMPKG_PACKAGE_TYPES is not set
+func checkPackageTypes(result *Result, values map[string]Setting) {
+	single, hasSingle := values["GHMPKG_PACKAGE_TYPE"]
+	list, hasList := values["GHMPKG_PACKAGE_TYPES"]
+	switch {
+	case hasSingle && hasList:
+		result.problem("GHMPKG_PACKAGE_TYPE", SeverityWarning, "is deprecated and ignored, every command processes GHMPKG_PACKAGE_TYPES=%s", list.Value)
+	case hasSingle:
+		result.problem("GHMPKG_PACKAGE_TYPE", SeverityWarning, "is deprecated, rename it to GHMPKG_PACKAGE_TYPES=%s", single.Value)
+	}
+}
+
+// suggest returns the known setting closest to an unknown name, if any is close
+func suggest(name string) string {
+	if _, ok := Lookup("GHMPKG_" + name); ok {
+		return "GHMPKG_" + name
+	}
+	best, bestDistance := "", len(name)/4+2
+	for _, key := range KEYS {
+		if distance := levenshtein(name, key.Name); distance < bestDistance {
+			best, bestDistance = key.Name, distance
+		}
+	}
+	return best
+}
+
+// levenshtein is the number of single character edits between two strings
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// Print lists the problems and the effective configuration, secrets masked
+func Print(result *Result) {
+	if result.File != "" {
+		pterm.Info.Printf("📄 Config file: %s\n", result.File)
+	} else {
+		pterm.Info.Println("📄 No config file, reading the environment only")
+	}
+
+	rows := [][]string{{"Setting", "Value", "Source", "Read by"}}
+	for _, setting := range result.Settings {
+		value := setting.Value
+		if setting.Key.IsSecret() && value != "" {
+			value = "********"
+		}
+		rows = append(rows, []string{setting.Key.Name, value, setting.Source, strings.Join(setting.Key.Commands, ", ")})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(rows).Render()
+
+	for _, problem := range result.Problems {
+		if problem.Severity == SeverityError {
+			pterm.Error.Printf("❌ %s: %s\n", problem.Name, problem.Message)
+		} else {
+			pterm.Warning.Printf("⚠️  %s: %s\n", problem.Name, problem.Message)
+		}
+	}
+	if !result.HasErrors() {
+		pterm.Success.Println("✅ Configuration is valid")
+	}
+}
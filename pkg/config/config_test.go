@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"GHMPKG_SOURCE_TOKEN", "ghmpkg_concurrency", "GHMPKG_NPM_TARGET_TOKEN", "GHMPKG_CONTAINER_SOURCE_HOSTNAME", "GHMPKG_TARGET_APP_ID", "GHMPKG_NPM_CONCURRENCY", "GHMPKG_MAVEN_FILE_CONCURRENCY"} {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("Lookup(%s) found nothing", name)
+		}
+	}
+	if key, _ := Lookup("GHMPKG_NPM_TARGET_TOKEN"); !key.IsSecret() {
+		t.Error("package type tokens are not secret")
+	}
+	for _, name := range []string{"GHMPKG_CONCURENCY", "GHMPKG_PYPI_SOURCE_TOKEN", "GHMPKG_WORK_DIR"} {
+		if _, ok := Lookup(name); ok {
+			t.Errorf("Lookup(%s) found a setting", name)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := map[string]string{
+		"GHMPKG_CONCURENCY":     "GHMPKG_CONCURRENCY",
+		"SOURCE_TOKEN":          "GHMPKG_SOURCE_TOKEN",
+		"GHMPKG_TARGET_REGITRY": "GHMPKG_TARGET_REGISTRY",
+		"HOME_DIRECTORY":        "",
+	}
+	for name, want := range tests {
+		if got := suggest(name); got != want {
+			t.Errorf("suggest(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func problems(result *Result) map[string]string {
+	found := make(map[string]string)
+	for _, problem := range result.Problems {
+		found[problem.Name] = problem.Severity
+	}
+	return found
+}
+
+func TestValidate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "ghmpkg_source_organization: mona\nghmpkg_source_token: ghp_secret\nghmpkg_package_types: [npm, docker]\nghmpkg_concurency: 4\nghmpkg_warmup: sometimes\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GHMPKG_MAX_INVENTORY_AGE", "3d")
+	t.Setenv("GHMPKG_CONFLICT_POLICY", "overwrite")
+
+	result, err := Validate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"GHMPKG_CONCURENCY":      SeverityError,
+		"GHMPKG_WARMUP":          SeverityError,
+		"GHMPKG_CONFLICT_POLICY": SeverityError,
+	}
+	found := problems(result)
+	if len(found) != len(want) {
+		t.Errorf("problems = %+v", result.Problems)
+	}
+	for name, severity := range want {
+		if found[name] != severity {
+			t.Errorf("%s: got %q, want %s", name, found[name], severity)
+		}
+	}
+	if !result.HasErrors() {
+		t.Error("HasErrors = false")
+	}
+
+	settings := make(map[string]Setting)
+	for _, setting := range result.Settings {
+		settings[setting.Key.Name] = setting
+	}
+	if setting := settings["GHMPKG_MAX_INVENTORY_AGE"]; setting.Value != "3d" || setting.Source != SourceEnvironment {
+		t.Errorf("GHMPKG_MAX_INVENTORY_AGE = %+v", setting)
+	}
+	if setting := settings["GHMPKG_PACKAGE_TYPES"]; setting.Value != "npm,docker" || setting.Source != file {
+		t.Errorf("GHMPKG_PACKAGE_TYPES = %+v", setting)
+	}
+	if setting := settings["GHMPKG_MIGRATION_PATH"]; setting.Source != SourceDefault {
+		t.Errorf("GHMPKG_MIGRATION_PATH = %+v", setting)
+	}
+}
+
+func TestValidatePackageTypeKeys(t *testing.T) {
+	t.Setenv("GHMPKG_PACKAGE_TYPE", "npm maven")
+	result, err := Validate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 1 || result.Problems[0].Name != "GHMPKG_PACKAGE_TYPE" || result.Problems[0].Severity != SeverityWarning {
+		t.Errorf("the deprecated GHMPKG_PACKAGE_TYPE is not reported: %+v", result.Problems)
+	}
+
+	t.Setenv("GHMPKG_PACKAGE_TYPE", "")
+	os.Unsetenv("GHMPKG_PACKAGE_TYPE")
+	t.Setenv("GHMPKG_PACKAGE_TYPES", "npm, docker")
+	if result, _ = Validate(""); len(result.Problems) != 0 {
+		t.Errorf("problems = %+v", result.Problems)
+	}
+	t.Setenv("GHMPKG_PACKAGE_TYPES", "npm pypi")
+	if result, _ = Validate(""); problems(result)["GHMPKG_PACKAGE_TYPES"] != SeverityError {
+		t.Errorf("an unknown package type is accepted: %+v", result.Problems)
+	}
+}
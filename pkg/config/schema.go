@@ -0,0 +1,218 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/credentials"
+	"github.com/mona-actions/gh-migrate-packages/internal/logging"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/mona-actions/gh-migrate-packages/pkg/references"
+)
+
+// Kinds of values a setting holds
+const (
+	String   = "string"
+	Secret   = "secret"
+	Bool     = "bool"
+	Int      = "int"
+	Duration = "duration"
+	// Age is a duration that also accepts days, e.g. 7d
+	Age  = "age"
+	Date = "date"
+	// Enum is one of the Values of the setting
+	Enum = "enum"
+	// List is comma separated, its entries one of the Values when there are
+	List = "list"
+	// PackageTypes is a list of package types or aliases
+	PackageTypes = "package-types"
+)
+
+// Key documents a setting: its kind, default and the commands reading it
+type Key struct {
+	Name        string
+	Kind        string
+	Default     string
+	Values      []string
+	Commands    []string
+	Description string
+}
+
+// every lists the commands reading the settings shared by all of them
+var every = []string{"all"}
+
+// packageTypeCommands are the commands filtering the package types they process
+var packageTypeCommands = []string{"export", "pull", "sync", "verify", "inventory-target", "apply-permissions", "capabilities", "migrate", "simulate", "references", "rewrite-references"}
+
+// KEYS are the settings read from flags, environment variables and the config file
+var KEYS = []Key{
+	{Name: "GHMPKG_SOURCE_ORGANIZATION", Kind: String, Commands: every, Description: "Organization the packages are migrated from"},
+	{Name: "GHMPKG_SOURCE_HOSTNAME", Kind: String, Commands: every, Description: "GitHub Enterprise Server hostname of the source, GitHub.com when empty"},
+	{Name: "GHMPKG_SOURCE_TOKEN", Kind: Secret, Commands: every, Description: "Token of the source organization"},
+	{Name: "GHMPKG_TARGET_ORGANIZATION", Kind: String, Commands: every, Description: "Organization the packages are migrated to"},
+	{Name: "GHMPKG_TARGET_HOSTNAME", Kind: String, Commands: every, Description: "GitHub Enterprise Server hostname of the target, GitHub.com when empty"},
+	{Name: "GHMPKG_SOURCE_SUBDOMAIN_ISOLATION", Kind: Bool, Default: "true", Commands: every, Description: "The source GitHub Enterprise Server serves registries on TYPE.HOSTNAME rather than HOSTNAME/_registry/TYPE"},
+	{Name: "GHMPKG_TARGET_SUBDOMAIN_ISOLATION", Kind: Bool, Default: "true", Commands: every, Description: "The target GitHub Enterprise Server serves registries on TYPE.HOSTNAME rather than HOSTNAME/_registry/TYPE"},
+	{Name: "GHMPKG_TARGET_TOKEN", Kind: Secret, Commands: every, Description: "Token of the target organization"},
+	{Name: "GHMPKG_SOURCE_CONTAINER_REGISTRY", Kind: String, Commands: []string{"pull", "sync", "migrate", "references", "rewrite-references"}, Description: "Registry images are pulled from, host[/namespace], ghcr.io or containers.HOSTNAME when empty"},
+	{Name: "GHMPKG_SOURCE_CONTAINER_REGISTRY_USER", Kind: String, Commands: []string{"pull", "sync", "migrate"}, Description: "User of the source container registry, the source organization when empty"},
+	{Name: "GHMPKG_SOURCE_CONTAINER_REGISTRY_PASSWORD", Kind: Secret, Commands: []string{"pull", "sync", "migrate"}, Description: "Password or token of the source container registry, the source token when empty"},
+	{Name: "GHMPKG_TARGET_CONTAINER_REGISTRY", Kind: String, Commands: []string{"sync", "migrate", "verify", "references", "rewrite-references"}, Description: "Registry images are pushed to, host[/namespace], ghcr.io or containers.HOSTNAME when empty"},
+	{Name: "GHMPKG_TARGET_CONTAINER_REGISTRY_USER", Kind: String, Commands: []string{"sync", "migrate", "verify"}, Description: "User of the target container registry, the target organization when empty"},
+	{Name: "GHMPKG_TARGET_CONTAINER_REGISTRY_PASSWORD", Kind: Secret, Commands: []string{"sync", "migrate", "verify"}, Description: "Password or token of the target container registry, the target token when empty"},
+	{Name: "GHMPKG_CONTAINER_STORAGE_LIMIT", Kind: String, Commands: []string{"pull", "sync", "migrate"}, Description: "Storage images may take in the Docker daemon before pulls wait, e.g. 50GB"},
+	{Name: "GHMPKG_COPY_REFERRERS", Kind: Bool, Default: "false", Commands: []string{"pull", "sync", "migrate"}, Description: "Copy the signatures, attestations and referrers attached to container images"},
+	{Name: "GHMPKG_KEEP_IMAGES", Kind: Bool, Default: "false", Commands: []string{"pull", "sync", "migrate"}, Description: "Keep images in the Docker daemon once saved or pushed"},
+	{Name: "GHMPKG_MIGRATION_PATH", Kind: String, Default: "./migration-packages", Commands: every, Description: "Migration directory"},
+	{Name: "GHMPKG_PACKAGE_TYPES", Kind: PackageTypes, Commands: packageTypeCommands, Description: "Package types to process"},
+	{Name: "GHMPKG_PACKAGE_TYPE", Kind: PackageTypes, Commands: packageTypeCommands, Description: "Deprecated, read when GHMPKG_PACKAGE_TYPES is not set"},
+	{Name: "GHMPKG_PACKAGE_TYPE_ALIASES", Kind: List, Commands: every, Description: "Extra package type aliases, alias=type"},
+	{Name: "GHMPKG_REPOSITORY", Kind: List, Commands: []string{"export", "pull", "sync", "migrate", "simulate", "references", "rewrite-references"}, Description: "Only process the packages of these repositories"},
+	{Name: "GHMPKG_PACKAGES", Kind: List, Commands: []string{"export", "pull", "sync", "migrate", "simulate"}, Description: "Only process the packages with these exact names"},
+	{Name: "GHMPKG_INCLUDE", Kind: List, Commands: []string{"export", "pull", "sync", "migrate", "simulate"}, Description: "Only process packages matching these globs"},
+	{Name: "GHMPKG_EXCLUDE", Kind: List, Commands: []string{"export", "pull", "sync", "migrate", "simulate"}, Description: "Skip packages matching these globs"},
+	{Name: "GHMPKG_VERSIONS", Kind: List, Commands: []string{"export", "pull", "sync", "migrate", "simulate"}, Description: "Only process versions matching these semver constraints"},
+	{Name: "GHMPKG_SINCE", Kind: Date, Commands: []string{"export", "pull", "sync", "migrate", "simulate"}, Description: "Only process versions created since this date"},
+	{Name: "GHMPKG_SNAPSHOT", Kind: String, Commands: []string{"export", "pull", "sync", "verify", "migrate", "simulate"}, Description: "Name the export is labeled with, and the export pull, sync and verify operate on"},
+	{Name: "GHMPKG_STORE", Kind: Enum, Default: "csv", Values: common.STORES, Commands: []string{"export", "pull", "sync", "migrate"}, Description: "Inventory store, sqlite also records the inventory and the status of every file in inventory.db in the migration path"},
+	{Name: "GHMPKG_REQUIRE_SNAPSHOT", Kind: Bool, Default: "false", Commands: []string{"pull", "sync", "verify", "migrate"}, Description: "Refuse to run without GHMPKG_SNAPSHOT"},
+	{Name: "GHMPKG_SHARD", Kind: String, Commands: []string{"pull", "sync", "migrate"}, Description: "Shard of the packages to process, e.g. 2/4"},
+	{Name: "GHMPKG_SHARD_SPLIT_TAGS", Kind: Int, Default: "100", Commands: []string{"pull", "sync", "migrate", "simulate"}, Description: "Tags above which the versions of a container package are dealt to the shards one digest at a time"},
+	{Name: "GHMPKG_CRITICAL", Kind: List, Commands: []string{"pull", "sync", "migrate"}, Description: "Packages processed before every other one"},
+	{Name: "GHMPKG_CRITICAL_FILE", Kind: String, Commands: []string{"pull", "sync", "migrate"}, Description: "File listing critical packages, one per line"},
+	{Name: "GHMPKG_VERIFY_CRITICAL", Kind: Bool, Default: "false", Commands: []string{"sync", "migrate"}, Description: "Read the files of critical packages back from the target even without GHMPKG_VERIFY_UPLOADS"},
+	{Name: "GHMPKG_USER", Kind: Bool, Default: "false", Commands: every, Description: "The source organization is a user account"},
+	{Name: "GHMPKG_RUN_ID", Kind: String, Commands: every, Description: "Identifier of the run recorded in its logs, reports and CSV files, generated when empty"},
+	{Name: "GHMPKG_CHECK_UPDATE", Kind: Bool, Default: "false", Commands: []string{"version"}, Description: "Check the releases of the extension for a newer version"},
+	{Name: "GHMPKG_CONCURRENCY", Kind: Int, Default: "1", Commands: []string{"pull", "sync", "migrate", "simulate"}, Description: "Packages processed in parallel"},
+	{Name: "GHMPKG_CONTAINER_CONCURRENCY", Kind: Int, Default: "0", Commands: []string{"pull", "sync", "migrate"}, Description: "Container images processed in parallel, 0 for no separate limit"},
+	{Name: "GHMPKG_FILE_CONCURRENCY", Kind: Int, Default: "5", Commands: []string{"pull", "sync", "migrate"}, Description: "Files of a version downloaded or uploaded in parallel"},
+	{Name: "RETRY_MAX", Kind: Int, Default: "3", Commands: every, Description: "Maximum retry attempts"},
+	{Name: "RETRY_DELAY", Kind: Duration, Default: "1s", Commands: every, Description: "Delay between retries"},
+	{Name: "GHMPKG_ERROR_RATE_THRESHOLD", Kind: Int, Default: "50", Commands: []string{"pull", "sync", "migrate"}, Description: "Percentage of failed operations backing off from a registry, 0 disables"},
+	{Name: "GHMPKG_ERROR_RATE_WINDOW", Kind: Int, Default: "20", Commands: []string{"pull", "sync", "migrate"}, Description: "Recent operations the error rate is measured over"},
+	{Name: "GHMPKG_ERROR_RATE_BACKOFF", Kind: Duration, Default: "30s", Commands: []string{"pull", "sync", "migrate"}, Description: "First pause after an error rate spike"},
+	{Name: "GHMPKG_TLS_MIN_VERSION", Kind: Enum, Default: "1.2", Values: []string{"1.2", "1.3"}, Commands: every, Description: "Minimum TLS version"},
+	{Name: "GHMPKG_TLS_CIPHER_POLICY", Kind: Enum, Values: []string{"default", "fips"}, Commands: every, Description: "TLS cipher policy"},
+	{Name: "GHMPKG_RECORD_HTTP", Kind: Bool, Default: "false", Commands: every, Description: "Record the metadata of every HTTP request"},
+	{Name: "GHMPKG_READ_ONLY", Kind: Bool, Default: "false", Commands: every, Description: "Audit the source without writing any file, credential or target"},
+	{Name: "GHMPKG_PROGRESS_SOCKET", Kind: String, Commands: every, Description: "Unix socket the progress of pull and sync is published on as JSON lines"},
+	{Name: "GHMPKG_PROGRESS_BARS", Kind: Enum, Default: common.ProgressBarsAuto, Values: common.PROGRESS_BARS_MODES, Commands: every, Description: "When pull and sync draw progress bars with an ETA instead of a spinner"},
+	{Name: "GHMPKG_LOG_LEVEL", Kind: Enum, Values: logging.LEVELS, Commands: every, Description: "Level of the log entries also written to stderr, none when empty"},
+	{Name: "GHMPKG_VERBOSE", Kind: Bool, Default: "false", Commands: every, Description: "Write debug log entries to stderr"},
+	{Name: "GHMPKG_LOG_FORMAT", Kind: Enum, Default: "console", Values: logging.FORMATS, Commands: every, Description: "Format of the log entries written to stderr"},
+	{Name: "GHMPKG_STORAGE", Kind: String, Commands: []string{"pull", "sync", "migrate"}, Description: "Object storage pulled files are copied to and synced from"},
+	{Name: "GHMPKG_CREDENTIAL_PROVIDER", Kind: Enum, Default: credentials.Env, Values: credentials.PROVIDERS, Commands: every, Description: "Where tokens come from"},
+	{Name: "GHMPKG_EXPORT_FORMAT", Kind: Enum, Default: "csv", Values: common.EXPORT_FORMATS, Commands: []string{"export"}, Description: "Inventory format"},
+	{Name: "GHMPKG_EXPORT_PERMISSIONS", Kind: Bool, Default: "false", Commands: []string{"export"}, Description: "Also export the visibility and team access of packages"},
+	{Name: "GHMPKG_REPORT_JSON", Kind: String, Commands: []string{"export", "pull", "sync", "migrate"}, Description: "Write the report as JSON to this path"},
+	{Name: "GHMPKG_RESUME", Kind: Bool, Default: "false", Commands: []string{"pull", "sync", "migrate"}, Description: "Resume an interrupted run"},
+	{Name: "GHMPKG_RETRY_FAILED", Kind: String, Commands: []string{"pull", "sync"}, Description: "Only process the entries that failed in this report"},
+	{Name: "GHMPKG_STAGING_NAMES", Kind: Enum, Default: providers.StagingNormalized, Values: providers.STAGING_NAME_SCHEMES, Commands: []string{"pull", "sync", "migrate"}, Description: "Naming scheme of the staged npm and container image tarballs"},
+	{Name: "GHMPKG_VERIFY_CHECKSUMS", Kind: Enum, Default: providers.ChecksumsFail, Values: providers.CHECKSUM_MODES, Commands: []string{"pull", "sync", "migrate"}, Description: "How to treat downloads not matching their exported checksum"},
+	{Name: "GHMPKG_KEEP_WORK_FILES", Kind: Bool, Default: "false", Commands: []string{"sync"}, Description: "Keep extracted archives and publish logs"},
+	{Name: "GHMPKG_EXISTING_PACKAGES", Kind: Enum, Default: "new-versions", Values: common.EXISTING_PACKAGE_POLICIES, Commands: []string{"sync", "migrate"}, Description: "How to handle packages already in the target: new-versions, skip or all"},
+	{Name: "GHMPKG_CONFLICT_POLICY", Kind: Enum, Default: "fail", Values: []string{"fail", "rename"}, Commands: []string{"sync", "migrate"}, Description: "How to handle package names deleted from the target"},
+	{Name: "GHMPKG_RENAME_SUFFIX", Kind: String, Default: "-migrated", Commands: []string{"sync", "migrate"}, Description: "Suffix of renamed packages"},
+	{Name: "GHMPKG_MAPPING_FILE", Kind: String, Commands: []string{"sync", "migrate", "verify"}, Description: "YAML or CSV file renaming repositories, packages and npm scopes on the target"},
+	{Name: "GHMPKG_TRANSFER", Kind: Bool, Default: "false", Commands: []string{"sync", "migrate"}, Description: "Move packages to the repositories the mapping file maps theirs to, within the source organization"},
+	{Name: "GHMPKG_TRANSFER_DELETE", Kind: Bool, Default: "false", Commands: []string{"sync", "migrate"}, Description: "Let --transfer delete the packages it can only publish again once deleted"},
+	{Name: "GHMPKG_MAX_INVENTORY_AGE", Kind: Age, Default: "7d", Commands: []string{"sync"}, Description: "Warn when the export is older than this"},
+	{Name: "GHMPKG_STRICT", Kind: Bool, Default: "false", Commands: []string{"sync"}, Description: "Fail instead of warning on a stale inventory"},
+	{Name: "GHMPKG_VERIFY_UPLOADS", Kind: Bool, Default: "true", Commands: []string{"sync", "migrate", "simulate"}, Description: "Read uploaded files back from the target"},
+	{Name: "GHMPKG_ROLLBACK_PARTIAL", Kind: Bool, Default: "false", Commands: []string{"sync", "migrate"}, Description: "Delete versions that fail after some of their files were uploaded from the target"},
+	{Name: "GHMPKG_SMOKE_TEST", Kind: Bool, Default: "false", Commands: []string{"sync", "migrate"}, Description: "Resolve a sample of the synced versions from the target with the client of their ecosystem"},
+	{Name: "GHMPKG_SMOKE_TEST_SAMPLE", Kind: Int, Default: "1", Commands: []string{"sync", "migrate"}, Description: "Synced versions of each package type smoke tested"},
+	{Name: "GHMPKG_WARMUP", Kind: Bool, Default: "false", Commands: []string{"sync", "simulate"}, Description: "Pace the first uploads into a new organization"},
+	{Name: "GHMPKG_WARMUP_OPERATIONS", Kind: Int, Default: "200", Commands: []string{"sync", "simulate"}, Description: "Versions the warm-up ramps up over"},
+	{Name: "GHMPKG_WARMUP_INTERVAL", Kind: Duration, Default: "2s", Commands: []string{"sync", "simulate"}, Description: "Time between uploads at the start of the warm-up"},
+	{Name: "GHMPKG_STREAM", Kind: Bool, Default: "false", Commands: []string{"sync"}, Description: "Copy files from the source without pulling them first"},
+	{Name: "GHMPKG_TARGET_REGISTRY", Kind: Enum, Default: providers.TargetGitHub, Values: providers.TARGET_REGISTRIES, Commands: []string{"sync", "simulate"}, Description: "Where the packages are published"},
+	{Name: "GHMPKG_ARTIFACTORY_URL", Kind: String, Commands: []string{"sync"}, Description: "JFrog Artifactory base URL"},
+	{Name: "GHMPKG_ARTIFACTORY_REPOS", Kind: List, Commands: []string{"sync"}, Description: "Artifactory repository of each package type, type=key"},
+	{Name: "GHMPKG_ARTIFACTORY_USER", Kind: String, Commands: []string{"sync"}, Description: "Artifactory user"},
+	{Name: "GHMPKG_ARTIFACTORY_API_KEY", Kind: Secret, Commands: []string{"sync"}, Description: "Artifactory API key"},
+	{Name: "GHMPKG_ARTIFACTORY_DOCKER_REGISTRY", Kind: String, Commands: []string{"sync"}, Description: "Artifactory Docker registry host"},
+	{Name: "GHMPKG_NEXUS_URL", Kind: String, Commands: []string{"sync"}, Description: "Sonatype Nexus Repository 3 base URL"},
+	{Name: "GHMPKG_NEXUS_REPOS", Kind: List, Commands: []string{"sync"}, Description: "Nexus hosted repository of each package type, type=repository"},
+	{Name: "GHMPKG_NEXUS_USER", Kind: String, Commands: []string{"sync"}, Description: "Nexus user"},
+	{Name: "GHMPKG_NEXUS_PASSWORD", Kind: Secret, Commands: []string{"sync"}, Description: "Nexus password or user token"},
+	{Name: "GHMPKG_AZURE_URL", Kind: String, Default: providers.DefaultAzureUrl, Commands: []string{"sync"}, Description: "Azure DevOps packaging URL"},
+	{Name: "GHMPKG_AZURE_ORGANIZATION", Kind: String, Commands: []string{"sync"}, Description: "Azure DevOps organization of the feed"},
+	{Name: "GHMPKG_AZURE_PROJECT", Kind: String, Commands: []string{"sync"}, Description: "Azure DevOps project of the feed, empty for an organization scoped feed"},
+	{Name: "GHMPKG_AZURE_FEED", Kind: String, Commands: []string{"sync"}, Description: "Azure Artifacts feed"},
+	{Name: "GHMPKG_AZURE_TOKEN", Kind: Secret, Commands: []string{"sync"}, Description: "Azure DevOps personal access token"},
+	{Name: "GHMPKG_CODEARTIFACT_DOMAIN", Kind: String, Commands: []string{"sync"}, Description: "AWS CodeArtifact domain"},
+	{Name: "GHMPKG_CODEARTIFACT_DOMAIN_OWNER", Kind: String, Commands: []string{"sync"}, Description: "AWS account owning the CodeArtifact domain"},
+	{Name: "GHMPKG_CODEARTIFACT_REPOSITORY", Kind: String, Commands: []string{"sync"}, Description: "AWS CodeArtifact repository"},
+	{Name: "GHMPKG_CODEARTIFACT_ROLE_ARN", Kind: String, Commands: []string{"sync"}, Description: "IAM role assumed to publish to CodeArtifact"},
+	{Name: "GHMPKG_VERIFY_SAMPLE", Kind: String, Commands: []string{"verify"}, Description: "Share of the synced files downloaded and compared, e.g. 5%"},
+	{Name: "GHMPKG_DRY_RUN", Kind: Bool, Default: "false", Commands: []string{"apply-permissions"}, Description: "Only print the grants that would be applied"},
+	{Name: "GHMPKG_LEDGER_SIGNING_KEY", Kind: Secret, Commands: []string{"ledger"}, Description: "ed25519 key the ledger is signed with"},
+	{Name: "GHMPKG_OPEN_PULL_REQUESTS", Kind: Bool, Default: "false", Commands: []string{"rewrite-references"}, Description: "Open pull requests instead of writing patches"},
+	{Name: "GHMPKG_REWRITE_BRANCH", Kind: String, Default: references.DefaultRewriteBranch, Commands: []string{"rewrite-references"}, Description: "Branch rewritten references are pushed to"},
+	{Name: "GHMPKG_MIGRATE_FROM", Kind: Enum, Values: []string{"export", "pull", "sync"}, Commands: []string{"migrate"}, Description: "Phase migrate starts from"},
+	{Name: "GHMPKG_MAX_FAILED_FILES", Kind: String, Commands: []string{"export", "pull", "sync", "migrate"}, Description: "Failed files a run tolerates, a number or a share such as 1%"},
+	{Name: "GHMPKG_MAX_FAILED_PACKAGES", Kind: String, Commands: []string{"export", "pull", "sync", "migrate"}, Description: "Failed packages a run tolerates, a number or a share such as 1%"},
+	{Name: "GHMPKG_FAIL_ON_CRITICAL", Kind: Bool, Default: "false", Commands: []string{"pull", "sync", "migrate"}, Description: "Fail the run when a critical package failed"},
+	{Name: "GHMPKG_FAIL_FAST", Kind: Bool, Default: "false", Commands: []string{"migrate"}, Description: "Stop after a phase with failed packages"},
+	{Name: "GHMPKG_WATCH", Kind: Bool, Default: "false", Commands: []string{"migrate"}, Description: "Migrate the versions published since the last cycle, every interval"},
+	{Name: "GHMPKG_WATCH_INTERVAL", Kind: Duration, Default: "6h", Commands: []string{"migrate"}, Description: "Time between the starts of two watch cycles"},
+	{Name: "GHMPKG_WATCH_UNTIL", Kind: Date, Commands: []string{"migrate"}, Description: "Time after which the watch starts no new cycle"},
+	{Name: "GHMPKG_SIMULATE_PHASES", Kind: List, Values: []string{"pull", "sync"}, Commands: []string{"simulate"}, Description: "Phases to simulate"},
+	{Name: "GHMPKG_SIMULATE_FILE_TIME", Kind: List, Commands: []string{"simulate"}, Description: "Time to transfer a file, per package type"},
+	{Name: "GHMPKG_SIMULATE_API_LATENCY", Kind: Duration, Default: "250ms", Commands: []string{"simulate"}, Description: "Time added for every request"},
+	{Name: "GHMPKG_SIMULATE_SHARDS", Kind: Int, Default: "1", Commands: []string{"simulate"}, Description: "Migrations run side by side"},
+	{Name: "GHMPKG_SIMULATE_BUCKET", Kind: Duration, Default: "1h", Commands: []string{"simulate"}, Description: "Length of a window of the timeline"},
+	{Name: "GHMPKG_SIMULATE_START", Kind: String, Commands: []string{"simulate"}, Description: "Planned start of the migration"},
+	{Name: "GHMPKG_SIMULATE_WINDOW", Kind: Duration, Commands: []string{"simulate"}, Description: "Length of the maintenance window"},
+	{Name: "GHMPKG_SIMULATE_OUTPUT", Kind: String, Commands: []string{"simulate"}, Description: "Write the simulation as JSON to this path"},
+}
+
+// sideKeys are the settings of a credential provider for each side, GHMPKG_<SIDE>_<setting>
+var sideKeys = []Key{
+	{Name: "APP_ID", Kind: String, Commands: every, Description: "GitHub App minting the token, with --credential-provider app"},
+	{Name: "APP_PRIVATE_KEY", Kind: Secret, Commands: every, Description: "Private key of the GitHub App"},
+	{Name: "APP_INSTALLATION_ID", Kind: Int, Commands: every, Description: "Installation of the GitHub App"},
+	{Name: "TOKEN_SECRET", Kind: String, Commands: every, Description: "Secret holding the token, with --credential-provider vault or aws"},
+}
+
+// packageTypeKeys can be set per package type, GHMPKG_<TYPE>_<setting>
+var packageTypeKeys = []string{"GHMPKG_SOURCE_TOKEN", "GHMPKG_SOURCE_HOSTNAME", "GHMPKG_TARGET_TOKEN", "GHMPKG_TARGET_HOSTNAME", "GHMPKG_CONCURRENCY", "GHMPKG_FILE_CONCURRENCY"}
+
+// Lookup returns the documentation of a setting, including the variants of a
+// side or a package type
+func Lookup(name string) (Key, bool) {
+	name = strings.ToUpper(name)
+	for _, key := range KEYS {
+		if key.Name == name {
+			return key, true
+		}
+	}
+	for _, side := range []string{"SOURCE", "TARGET"} {
+		for _, key := range sideKeys {
+			if name == "GHMPKG_"+side+"_"+key.Name {
+				key.Name = name
+				key.Description = strings.ToLower(side) + ": " + key.Description
+				return key, true
+			}
+		}
+	}
+	for _, packageType := range common.SUPPORTED_PACKAGE_TYPES {
+		prefix := "GHMPKG_" + strings.ToUpper(packageType) + "_"
+		for _, setting := range packageTypeKeys {
+			if name == prefix+strings.TrimPrefix(setting, "GHMPKG_") {
+				key, _ := Lookup(setting)
+				key.Name = name
+				key.Description = packageType + ": " + key.Description
+				return key, true
+			}
+		}
+	}
+	return Key{}, false
+}
+
+// IsSecret reports whether the value of a setting must not be printed
+func (k Key) IsSecret() bool {
+	return k.Kind == Secret
+}
@@ -3,11 +3,16 @@ package export
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/google/go-github/v62/github"
 	"github.com/mona-actions/gh-migrate-packages/internal/api"
 	"github.com/mona-actions/gh-migrate-packages/internal/files"
 	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
 	"github.com/mona-actions/gh-migrate-packages/pkg/common"
 
 	"github.com/pterm/pterm"
@@ -17,55 +22,229 @@ import (
 
 // var SUPPORTED_PACKAGE_TYPES = []string{"maven", "npm", "container", "rubygems", "nuget"}
 
-func Export(logger *zap.Logger) error {
+// formatTimestamp writes an API timestamp for the CSV, empty when the API did not return it
+func formatTimestamp(timestamp github.Timestamp) string {
+	if timestamp.IsZero() {
+		return ""
+	}
+	return timestamp.UTC().Format(time.RFC3339)
+}
+
+// fileChecksum returns the SHA-256 of a source file when the provider knows it.
+// Export goes on without it, pull then computes the digest itself.
+func fileChecksum(logger *zap.Logger, provider providers.Provider, owner, packageName, version, filename string) string {
+	checksummer, ok := provider.(providers.Checksummer)
+	if !ok {
+		return ""
+	}
+	checksum, err := checksummer.FileChecksum(logger, owner, packageName, version, filename)
+	if err != nil {
+		logger.Warn("Failed to fetch file checksum", zap.String("filename", filename), zap.Error(err))
+		return ""
+	}
+	return checksum
+}
+
+// fileSize returns the size of a source file when the provider knows it, 0 otherwise
+func fileSize(logger *zap.Logger, provider providers.Provider, owner, repository, packageName, version, filename string) int64 {
+	sizer, ok := provider.(providers.Sizer)
+	if !ok {
+		return 0
+	}
+	size, err := sizer.FileSize(logger, owner, repository, packageName, version, filename)
+	if err != nil {
+		logger.Warn("Failed to fetch file size", zap.String("filename", filename), zap.Error(err))
+		return 0
+	}
+	return size
+}
+
+// distTags returns the dist-tags of a package pointing at the exported versions,
+// tag to version, when the provider knows them
+func distTags(logger *zap.Logger, provider providers.Provider, owner string, pkg *github.Package, versions []*github.PackageVersion) map[string]string {
+	tagger, ok := provider.(providers.DistTagger)
+	if !ok {
+		return nil
+	}
+	tags, err := tagger.DistTags(logger, owner, pkg.GetName())
+	if err != nil {
+		logger.Warn("Failed to fetch dist-tags", zap.String("package", pkg.GetName()), zap.Error(err))
+		pterm.Warning.Printf("    ⚠️  Failed to fetch dist-tags: %v\n", err)
+		return nil
+	}
+	exported := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		exported[version.GetName()] = true
+	}
+	for tag, version := range tags {
+		if !exported[version] {
+			delete(tags, tag)
+		}
+	}
+	return tags
+}
+
+// selectVersions keeps the versions the filter selects, matching container
+// versions on their tags. It also returns the selected labels.
+func selectVersions(filter *common.VersionFilter, packageType string, versions []*github.PackageVersion) ([]*github.PackageVersion, map[string]bool) {
+	labels := func(version *github.PackageVersion) []string {
+		if packageType == "container" {
+			if version.Metadata == nil || version.Metadata.Container == nil {
+				return nil
+			}
+			return version.Metadata.Container.Tags
+		}
+		return []string{version.GetName()}
+	}
+
+	var all []string
+	for _, version := range versions {
+		all = append(all, labels(version)...)
+	}
+	selected := filter.Select(all)
+
+	var result []*github.PackageVersion
+	for _, version := range versions {
+		for _, label := range labels(version) {
+			if selected[label] {
+				result = append(result, version)
+				break
+			}
+		}
+	}
+	return result, selected
+}
+
+func Export(logger *zap.Logger) (err error) {
 	startTime := time.Now()
 	report := common.NewReport()
+	defer func() {
+		if jsonErr := common.WriteReportJSON("export", startTime, report, err); jsonErr != nil {
+			logger.Error("Failed to write JSON report", zap.Error(jsonErr))
+			pterm.Error.Printf("❌ Error writing JSON report: %v\n", jsonErr)
+		}
+	}()
 	packageStats := make(map[string]int)
 	totalPackages := 0
 	reposWithPackages := make(map[string]bool)
+	emptyVersionFiles := []string{}
+	manualExportFiles := []string{}
 	owner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
-	desiredPackageTypes := viper.GetStringSlice("GHMPKG_PACKAGE_TYPES")
+	desiredPackageTypes := common.DesiredPackageTypes()
+	desiredRepositories := common.DesiredRepositories()
+	format := viper.GetString("GHMPKG_EXPORT_FORMAT")
+	exportPermissions := viper.GetBool("GHMPKG_EXPORT_PERMISSIONS")
+	migrationPath := utils.MigrationPath()
+	// Repositories often hold packages of several types, their teams are listed once
+	teamsByRepository := make(map[string][]*github.Team)
+	repositories := newRepositoryStates(logger, api.FetchSourceRepositories)
+	if format == "" {
+		format = "csv"
+	}
+	if !utils.Contains(common.EXPORT_FORMATS, format) {
+		return fmt.Errorf("unsupported export format: %s (expected one of %v)", format, common.EXPORT_FORMATS)
+	}
+	if _, err := common.InventoryStore(); err != nil {
+		return err
+	}
+	if _, err := common.NewSuccessCriteria(); err != nil {
+		return err
+	}
+	// A read-only export writes nothing but its inventory, to stdout
+	readOnly := utils.ReadOnly()
+	if readOnly {
+		if err := checkReadOnly(format); err != nil {
+			return err
+		}
+	}
+	inventoryCSV := [][]string{common.INVENTORY_HEADER}
+	manifests := []*common.Manifest{}
 
-	pterm.Info.Println("Starting export to csv...")
+	pterm.Info.Println(fmt.Sprintf("Starting export to %s...", format))
 	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Exporting packages from source org: %s", owner))
 
 	// Create base export directory
-	baseDir := "./migration-packages/export"
-	if err := files.EnsureDir(baseDir); err != nil {
-		spinner.Fail(fmt.Sprintf("Error creating base directory: %v", err))
-		return err
+	baseDir := filepath.Join(migrationPath, "export")
+	if !readOnly {
+		if err := files.EnsureDir(baseDir); err != nil {
+			spinner.Fail(fmt.Sprintf("Error creating base directory: %v", err))
+			return err
+		}
 	}
 
-	// Validate and filter package types
-	packageTypes := make([]string, 0)
-	if len(desiredPackageTypes) > 0 {
-		pterm.Info.Println(fmt.Sprintf("🔍 Filtering for package types: %v", desiredPackageTypes))
-		// Validate each desired package type against supported types
-		for _, desired := range desiredPackageTypes {
-			isSupported := false
-			for _, supported := range common.SUPPORTED_PACKAGE_TYPES {
-				if desired == supported {
-					isSupported = true
-					packageTypes = append(packageTypes, desired)
-					break
-				}
-			}
-			if !isSupported {
-				spinner.Fail(fmt.Sprintf("❌ Unsupported package type: %s", desired))
-				return fmt.Errorf("unsupported package type: %s", desired)
+	// Validate and filter package types, resolving aliases such as docker
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("❌ %v", err))
+		return err
+	}
+	// A snapshot is frozen once exported, pull and sync must keep finding the same inventory
+	snapshot, err := common.Snapshot()
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("❌ %v", err))
+		return err
+	}
+	if snapshot != "" {
+		for _, packageType := range packageTypes {
+			if existing, err := common.FindInventory(migrationPath, packageType, owner, snapshot); err == nil {
+				err = fmt.Errorf("snapshot %s was already exported to %s, label this export with another name", snapshot, existing)
+				spinner.Fail(fmt.Sprintf("❌ %v", err))
+				return err
 			}
 		}
+		pterm.Info.Println(fmt.Sprintf("📸 Labeling the export as snapshot: %s", snapshot))
+	}
+	if len(desiredPackageTypes) > 0 {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering for package types: %v", desiredPackageTypes))
 	} else {
-		packageTypes = common.SUPPORTED_PACKAGE_TYPES // Use all supported types if none specified
 		pterm.Info.Println("📦 Exporting all supported package types")
 	}
+	if len(desiredRepositories) > 0 {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering for repositories: %s", strings.Join(desiredRepositories, ", ")))
+	}
+	nameFilter, err := common.NewNameFilter()
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("❌ %v", err))
+		return err
+	}
+	if !nameFilter.IsEmpty() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering package names: %s", nameFilter))
+	}
+	versionFilter, err := common.NewVersionFilter()
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("❌ %v", err))
+		return err
+	}
+	if !versionFilter.IsEmpty() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering versions: %s", versionFilter))
+	}
+	since, err := common.ParseSince()
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("❌ %v", err))
+		return err
+	}
+	if !since.IsZero() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering versions created since %s", since.Format(time.RFC3339)))
+	}
+	packageTypes = common.SupportedPackageTypes(logger, "source", packageTypes)
 
 	for _, packageType := range packageTypes {
 		pterm.Info.Println(fmt.Sprintf("📦 Processing %s packages...", packageType))
 
 		// Initialize CSV data for this package type
-		packagesCSV := [][]string{
-			{"organization", "repository", "package_type", "package_name", "package_version", "package_filename"},
+		packagesCSV := [][]string{common.INVENTORY_HEADER}
+		// The JSON manifest holds the same rows grouped by package and version
+		manifest := &common.Manifest{
+			Organization: owner,
+			PackageType:  packageType,
+			ExportedAt:   time.Now().UTC().Format(time.RFC3339),
+			Run:          run.Current(),
+			Packages:     []*common.ManifestPackage{},
+		}
+		distTagsCSV := [][]string{common.DIST_TAGS_HEADER}
+		// Versions without files produce no rows above, list them separately so they can be followed up
+		emptyVersionsCSV := [][]string{
+			{"organization", "repository", "package_type", "package_name", "package_version"},
 		}
 
 		provider, err := providers.NewProvider(logger, packageType)
@@ -80,6 +259,21 @@ func Export(logger *zap.Logger) error {
 			return err
 		}
 
+		// Only keep packages linked to the requested repositories and matching the name filter
+		if len(desiredRepositories) > 0 || !nameFilter.IsEmpty() {
+			var selected []*github.Package
+			for _, pkg := range packages {
+				if !common.MatchRepository(desiredRepositories, pkg.Repository.GetName()) {
+					continue
+				}
+				if !nameFilter.Match(pkg.GetName()) {
+					continue
+				}
+				selected = append(selected, pkg)
+			}
+			packages = selected
+		}
+
 		packageStats[packageType] = len(packages)
 		totalPackages += len(packages)
 		pterm.Info.Println(fmt.Sprintf("📊 Found %d %s packages", len(packages), packageType))
@@ -97,28 +291,125 @@ func Export(logger *zap.Logger) error {
 			}
 			pterm.Info.Printf("    Found %d versions\n", len(versions))
 
+			if !since.IsZero() {
+				var recent []*github.PackageVersion
+				for _, version := range versions {
+					if !version.GetCreatedAt().Before(since) {
+						recent = append(recent, version)
+					}
+				}
+				versions = recent
+			}
+
+			var selectedVersions map[string]bool
+			if !versionFilter.IsEmpty() {
+				versions, selectedVersions = selectVersions(versionFilter, packageType, versions)
+				pterm.Info.Printf("    Selected %d versions\n", len(versions))
+			}
+
+			repositoryVisibility, repositoryArchived := repositories.lookup(pkg.Repository)
+			manifestPackage := &common.ManifestPackage{
+				Name:                 pkg.GetName(),
+				Repository:           pkg.Repository.GetName(),
+				RepositoryURL:        pkg.Repository.GetHTMLURL(),
+				RepositoryVisibility: repositoryVisibility,
+				RepositoryArchived:   repositoryArchived,
+				URL:                  pkg.GetHTMLURL(),
+				Visibility:           pkg.GetVisibility(),
+				CreatedAt:            formatTimestamp(pkg.GetCreatedAt()),
+				UpdatedAt:            formatTimestamp(pkg.GetUpdatedAt()),
+				Versions:             []*common.ManifestVersion{},
+			}
+			manifest.Packages = append(manifest.Packages, manifestPackage)
+
+			tags := distTags(logger, provider, owner, pkg, versions)
+			tagNames := make([]string, 0, len(tags))
+			for tag := range tags {
+				tagNames = append(tagNames, tag)
+			}
+			sort.Strings(tagNames)
+			tagsByVersion := make(map[string][]string)
+			for _, tag := range tagNames {
+				distTagsCSV = append(distTagsCSV, []string{owner, pkg.Repository.GetName(), packageType, pkg.GetName(), tag, tags[tag]})
+				tagsByVersion[tags[tag]] = append(tagsByVersion[tags[tag]], tag)
+			}
+
+			packageReport := common.NewReport()
+			packageReport.SetPackageType(packageType)
 			for _, version := range versions {
+				manifestVersion := &common.ManifestVersion{
+					Name:      version.GetName(),
+					URL:       version.GetHTMLURL(),
+					CreatedAt: formatTimestamp(version.GetCreatedAt()),
+					UpdatedAt: formatTimestamp(version.GetUpdatedAt()),
+					Files:     []common.ManifestFile{},
+				}
+				if version.Metadata != nil && version.Metadata.Container != nil {
+					manifestVersion.Tags = version.Metadata.Container.Tags
+				} else if tags := tagsByVersion[version.GetName()]; len(tags) > 0 {
+					manifestVersion.Tags = tags
+				}
 				filenames, result, err := provider.FetchPackageFiles(logger, owner, pkg.Repository.GetName(), packageType, pkg.GetName(), version.GetName(), version.Metadata)
+				if err != nil {
+					logger.Error("Error fetching package files",
+						zap.String("package", pkg.GetName()),
+						zap.String("version", version.GetName()),
+						zap.Error(err))
+					result = providers.Failed
+				}
 				if result != providers.Success {
-					report.IncPackages(result)
-					report.IncVersions(result)
+					packageReport.AddItem(common.NewItem(owner, pkg.Repository.GetName(), packageType, pkg.GetName(), version.GetName(), "", result, err))
 					pterm.Warning.Printf("    ⚠️  Version %s: %s\n", version.GetName(), result)
-				}
-				if err != nil {
-					spinner.Fail(fmt.Sprintf("❌ Error fetching package files: %v", err))
-					return err
+				} else if len(filenames) == 0 {
+					packageReport.AddItem(common.NewItem(owner, pkg.Repository.GetName(), packageType, pkg.GetName(), version.GetName(), "", providers.Skipped, &providers.SkipError{Reason: providers.SkipNoFiles}))
+					packageReport.IncVersionsWithoutFiles()
+					emptyVersionsCSV = append(emptyVersionsCSV, []string{owner, pkg.Repository.GetName(), packageType, pkg.GetName(), version.GetName()})
+					logger.Warn("Package version has no files",
+						zap.String("package", pkg.GetName()),
+						zap.String("version", version.GetName()))
+					pterm.Warning.Printf("    ⚠️  Version %s: no files\n", version.GetName())
 				}
 
 				for _, filename := range filenames {
-					report.IncFiles(result)
-					packagesCSV = append(packagesCSV, []string{owner, pkg.Repository.GetName(), packageType, pkg.GetName(), version.GetName(), filename})
+					// Container versions carry several tags, only the selected ones are exported
+					if selectedVersions != nil && !selectedVersions[common.VersionLabel(packageType, version.GetName(), filename)] {
+						continue
+					}
+					checksum := fileChecksum(logger, provider, owner, pkg.GetName(), version.GetName(), filename)
+					size := fileSize(logger, provider, owner, pkg.Repository.GetName(), pkg.GetName(), version.GetName(), filename)
+					packageReport.RecordFile(common.NewItem(owner, pkg.Repository.GetName(), packageType, pkg.GetName(), version.GetName(), filename, result, nil))
+					packagesCSV = append(packagesCSV, []string{owner, pkg.Repository.GetName(), packageType, pkg.GetName(), version.GetName(), filename,
+						formatTimestamp(version.GetCreatedAt()), formatTimestamp(version.GetUpdatedAt()), checksum, repositoryVisibility, repositoryArchived, common.FormatFileSize(size)})
+					manifestVersion.Files = append(manifestVersion.Files, common.ManifestFile{Name: filename, SHA256: checksum, Size: size})
 					if result == providers.Success {
 						pterm.Success.Printf(" ✅ %s", filename)
 					}
 				}
-				report.IncVersions(providers.Success)
+				if len(manifestVersion.Files) > 0 {
+					manifestPackage.Versions = append(manifestPackage.Versions, manifestVersion)
+				}
+				packageReport.IncVersions(result)
 			}
-			report.IncPackages(providers.Success)
+
+			// Determine package status based on version results
+			if packageReport.VersionsFailed > 0 {
+				packageReport.IncPackages(providers.Failed)
+			} else if packageReport.VersionsSkipped > 0 {
+				packageReport.IncPackages(providers.Skipped)
+			} else {
+				packageReport.IncPackages(providers.Success)
+			}
+			report.Merge(packageReport)
+		}
+
+		// The inventory of a read-only export is written once every package type is listed
+		if readOnly {
+			inventoryCSV = append(inventoryCSV, packagesCSV[1:]...)
+			manifests = append(manifests, manifest)
+			if manualExports := providers.ManualExports(packageType); len(manualExports) > 0 {
+				pterm.Warning.Printf("⚠️  %d packages timed out and need a manual export\n", len(manualExports))
+			}
+			continue
 		}
 
 		// Create package type directory
@@ -128,18 +419,87 @@ func Export(logger *zap.Logger) error {
 			return err
 		}
 
-		// Create CSV file for this package type
+		// Create the inventory files for this package type
 		timestamp := time.Now().Format("2006-01-02_15-04-05")
-		csvName := fmt.Sprintf("%s_%s_%s_packages.csv", timestamp, owner, packageType)
-		filename := filepath.Join(packageDir, csvName)
-		if err := files.CreateCSV(packagesCSV, filename); err != nil {
-			spinner.Fail(fmt.Sprintf("❌ Error creating CSV: %v", err))
+		if format == "csv" || format == "both" {
+			csvName := fmt.Sprintf("%s_%s_%s_packages.csv", timestamp, owner, packageType)
+			if err := files.CreateCSV(packagesCSV, filepath.Join(packageDir, csvName)); err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error creating CSV: %v", err))
+				return err
+			}
+			pterm.Success.Printf("✅ Created CSV file: %s", csvName)
+			fmt.Println()
+		}
+		if format == "json" || format == "both" {
+			manifestName := fmt.Sprintf("%s_%s_%s_packages.json", timestamp, owner, packageType)
+			if err := common.WriteManifest(manifest, filepath.Join(packageDir, manifestName)); err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error creating manifest: %v", err))
+				return err
+			}
+			pterm.Success.Printf("✅ Created manifest file: %s", manifestName)
+			fmt.Println()
+		}
+
+		if stored, err := common.StoreExport(migrationPath, fmt.Sprintf("%s_%s_%s_packages", timestamp, owner, packageType), owner, packageType, snapshot, packagesCSV); err != nil {
+			spinner.Fail(fmt.Sprintf("❌ Error writing the inventory database: %v", err))
 			return err
+		} else if stored != "" {
+			pterm.Success.Printf("✅ Recorded the export in the inventory database: %s", stored)
+			fmt.Println()
+		}
+
+		if exportPermissions {
+			permissionsCSV := append([][]string{common.PERMISSIONS_HEADER}, permissionRows(logger, owner, packageType, packages, teamsByRepository)...)
+			permissionsName := fmt.Sprintf("%s_%s_%s_permissions.csv", timestamp, owner, packageType)
+			if err := files.CreateCSV(permissionsCSV, filepath.Join(packageDir, permissionsName)); err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error creating CSV: %v", err))
+				return err
+			}
+			pterm.Success.Printf("✅ Created permissions file: %s", permissionsName)
+			fmt.Println()
+		}
+
+		if len(distTagsCSV) > 1 {
+			distTagsName := fmt.Sprintf("%s_%s_%s_dist_tags.csv", timestamp, owner, packageType)
+			if err := files.CreateCSV(distTagsCSV, filepath.Join(packageDir, distTagsName)); err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error creating CSV: %v", err))
+				return err
+			}
+			pterm.Success.Printf("✅ Created dist-tags file: %s", distTagsName)
+			fmt.Println()
+		}
+
+		if manualExports := providers.ManualExports(packageType); len(manualExports) > 0 {
+			manualCSV := [][]string{{"organization", "package_type", "package_name", "reason"}}
+			for _, manual := range manualExports {
+				manualCSV = append(manualCSV, []string{owner, packageType, manual.Name, manual.Reason})
+			}
+			manualName := fmt.Sprintf("%s_%s_%s_needs_manual_export.csv", timestamp, owner, packageType)
+			if err := files.CreateCSV(manualCSV, filepath.Join(packageDir, manualName)); err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error creating CSV: %v", err))
+				return err
+			}
+			manualExportFiles = append(manualExportFiles, filepath.Join(packageDir, manualName))
+			pterm.Warning.Printf("⚠️  %d packages timed out and need a manual export, listed in: %s\n", len(manualExports), manualName)
+		}
+
+		if len(emptyVersionsCSV) > 1 {
+			emptyName := fmt.Sprintf("%s_%s_%s_empty_versions.csv", timestamp, owner, packageType)
+			if err := files.CreateCSV(emptyVersionsCSV, filepath.Join(packageDir, emptyName)); err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error creating CSV: %v", err))
+				return err
+			}
+			emptyVersionFiles = append(emptyVersionFiles, filepath.Join(packageDir, emptyName))
+			pterm.Warning.Printf("⚠️  %d versions without files listed in: %s\n", len(emptyVersionsCSV)-1, emptyName)
 		}
-		pterm.Success.Printf("✅ Created CSV file: %s", csvName)
-		fmt.Println()
 	}
 
+	if readOnly {
+		if err := writeReadOnlyInventory(utils.ReadOnlyOutput, format, inventoryCSV, manifests); err != nil {
+			spinner.Fail(fmt.Sprintf("❌ Error writing the inventory: %v", err))
+			return err
+		}
+	}
 	spinner.Success("Packages exported successfully")
 
 	// Calculate duration
@@ -158,14 +518,44 @@ func Export(logger *zap.Logger) error {
 		if count, exists := packageStats[pkgType]; exists && count > 0 {
 			emoji := "📦"
 			name := pkgType
-			fmt.Printf("  %s %s: %d\n", emoji, name, count)
+			fmt.Printf("  %s %s: %d (✅ %d, ⏭️  %d, ❌ %d)\n", emoji, name, count,
+				report.GetPackagesByTypeAndState(pkgType, providers.Success),
+				report.GetPackagesByTypeAndState(pkgType, providers.Skipped),
+				report.GetPackagesByTypeAndState(pkgType, providers.Failed))
 		}
 	}
 
+	fmt.Printf("⏭️  Skipped: %d packages\n", report.GetPackages(providers.Skipped))
 	fmt.Printf("❌ Failed to process: %d packages\n", report.GetPackages(providers.Failed))
+	fmt.Printf("🗃️ Versions: %d exported, %d skipped, %d failed\n", report.VersionSuccess, report.VersionsSkipped, report.VersionsFailed)
+	report.PrintSkipReasons()
+	if report.VersionsWithoutFiles > 0 {
+		fmt.Printf("⚠️  Versions with zero files: %d (cannot be migrated)\n", report.VersionsWithoutFiles)
+		for _, emptyFile := range emptyVersionFiles {
+			fmt.Printf("  📄 %s\n", emptyFile)
+		}
+	}
+	if len(manualExportFiles) > 0 {
+		fmt.Println("⚠️  Packages needing a manual export (GraphQL timeouts):")
+		for _, manualFile := range manualExportFiles {
+			fmt.Printf("  📄 %s\n", manualFile)
+		}
+	}
 	fmt.Printf("🔍 Repositories with packages: %d\n", len(reposWithPackages))
-	fmt.Printf("📁 Output directory: %s\n", baseDir)
+	if readOnly {
+		fmt.Println("🔒 Read-only: inventory written to stdout, nothing written to disk")
+	} else {
+		if err := common.WriteNameAudit("export"); err != nil {
+			logger.Error("Failed to write name audit", zap.Error(err))
+			pterm.Error.Printf("❌ Error writing name audit: %v\n", err)
+		}
+		fmt.Printf("📁 Output directory: %s\n", baseDir)
+	}
 	fmt.Printf("🕐 Total time: %dh %dm %ds\n\n", hours, minutes, seconds)
+	if failures := report.Failures(); failures != nil {
+		fmt.Printf("⚠️  Export %v\n", failures)
+		return failures
+	}
 	fmt.Println("✅ Export completed successfully!")
 
 	return nil
@@ -0,0 +1,42 @@
+package export
+
+import (
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// permissionRows lists who has access to each package, as far as the API tells.
+// Packages linked to a repository inherit the access of its teams, the access
+// granted on organization scoped packages is not exposed and only their
+// visibility is recorded.
+func permissionRows(logger *zap.Logger, owner, packageType string, packages []*github.Package, teamsByRepository map[string][]*github.Team) [][]string {
+	var rows [][]string
+	for _, pkg := range packages {
+		repository := pkg.GetRepository().GetName()
+		row := []string{owner, packageType, pkg.GetName(), repository, pkg.GetVisibility()}
+		if repository == "" {
+			rows = append(rows, append(row, "", ""))
+			continue
+		}
+
+		teams, ok := teamsByRepository[repository]
+		if !ok {
+			var err error
+			if teams, err = api.FetchRepositoryTeams(repository); err != nil {
+				logger.Warn("Failed to list repository teams", zap.String("repository", repository), zap.Error(err))
+				pterm.Warning.Printf("    ⚠️  Could not list the teams of %s: %v\n", repository, err)
+			}
+			teamsByRepository[repository] = teams
+		}
+		if len(teams) == 0 {
+			rows = append(rows, append(row, "", ""))
+			continue
+		}
+		for _, team := range teams {
+			rows = append(rows, append(append([]string{}, row...), team.GetSlug(), team.GetPermission()))
+		}
+	}
+	return rows
+}
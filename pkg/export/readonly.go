@@ -0,0 +1,41 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/spf13/viper"
+)
+
+// checkReadOnly refuses the export settings that write files, which a
+// read-only export does not allow
+func checkReadOnly(format string) error {
+	if format == "both" {
+		return fmt.Errorf("--read-only writes the inventory to stdout in a single format, csv or json")
+	}
+	if viper.GetBool("GHMPKG_EXPORT_PERMISSIONS") {
+		return fmt.Errorf("--permissions writes a permissions file, which --read-only does not allow")
+	}
+	if viper.GetString("GHMPKG_REPORT_JSON") != "" {
+		return fmt.Errorf("--report-json writes a report file, which --read-only does not allow")
+	}
+	if viper.GetString("GHMPKG_STORE") == common.StoreSQLite {
+		return fmt.Errorf("--store sqlite writes the inventory database, which --read-only does not allow")
+	}
+	return nil
+}
+
+// writeReadOnlyInventory writes the inventory of a read-only export: the rows
+// of every package type under a single CSV header, or their JSON manifests as
+// an array
+func writeReadOnlyInventory(w io.Writer, format string, rows [][]string, manifests []*common.Manifest) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(manifests)
+	}
+	return files.WriteCSV(w, rows)
+}
@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/spf13/viper"
+)
+
+func TestWriteReadOnlyInventory(t *testing.T) {
+	rows := [][]string{
+		common.INVENTORY_HEADER,
+		{"mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz", "", "", "", "", "", "1000"},
+		{"mona", "app", "container", "app", "sha256:abc", "app:1.0", "", "", "", "", "", "6000"},
+	}
+	var output bytes.Buffer
+	if err := writeReadOnlyInventory(&output, "csv", rows, nil); err != nil {
+		t.Fatal(err)
+	}
+	// A single header, whatever the package types
+	path := filepath.Join(t.TempDir(), "inventory.csv")
+	if err := os.WriteFile(path, output.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	read, err := files.ReadCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 3 || strings.Join(read[0], ",") != strings.Join(common.INVENTORY_HEADER, ",") || read[2][2] != "container" {
+		t.Errorf("CSV inventory = %v", read)
+	}
+
+	output.Reset()
+	manifests := []*common.Manifest{{Organization: "mona", PackageType: "npm"}, {Organization: "mona", PackageType: "container"}}
+	if err := writeReadOnlyInventory(&output, "json", nil, manifests); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []common.Manifest
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1].PackageType != "container" {
+		t.Errorf("JSON inventory = %v, %v", decoded, err)
+	}
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	defer viper.Reset()
+	if err := checkReadOnly("json"); err != nil {
+		t.Errorf("checkReadOnly(json) = %v", err)
+	}
+	if err := checkReadOnly("both"); err == nil {
+		t.Error("checkReadOnly accepted --format both")
+	}
+	viper.Set("GHMPKG_EXPORT_PERMISSIONS", true)
+	if err := checkReadOnly("csv"); err == nil {
+		t.Error("checkReadOnly accepted --permissions")
+	}
+}
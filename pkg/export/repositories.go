@@ -0,0 +1,57 @@
+package export
+
+import (
+	"strconv"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// repositoryStates looks up the visibility and archived state of the
+// repositories packages are linked to. The repository the packages API embeds
+// does not carry them, the repositories of the source are listed once, the
+// first time a package needs them.
+type repositoryStates struct {
+	logger *zap.Logger
+	fetch  func() ([]*github.Repository, error)
+	byName map[string]*github.Repository
+}
+
+func newRepositoryStates(logger *zap.Logger, fetch func() ([]*github.Repository, error)) *repositoryStates {
+	return &repositoryStates{logger: logger, fetch: fetch}
+}
+
+// lookup returns the visibility of a repository and whether it is archived,
+// "true" or "false". Both are empty for packages without a repository. When
+// the repositories cannot be listed, the visibility falls back to the private
+// flag of the package's repository and the archived state is left empty.
+func (s *repositoryStates) lookup(repository *github.Repository) (string, string) {
+	if repository.GetName() == "" {
+		return "", ""
+	}
+	if repository.Visibility != nil && repository.Archived != nil {
+		return repository.GetVisibility(), strconv.FormatBool(repository.GetArchived())
+	}
+	if s.byName == nil {
+		s.byName = make(map[string]*github.Repository)
+		repositories, err := s.fetch()
+		if err != nil {
+			s.logger.Warn("Failed to list source repositories, their archived state is not exported", zap.Error(err))
+			pterm.Warning.Printf("⚠️  Failed to list source repositories, their archived state is not exported: %v\n", err)
+		}
+		for _, listed := range repositories {
+			s.byName[listed.GetName()] = listed
+		}
+	}
+	if listed, ok := s.byName[repository.GetName()]; ok {
+		return listed.GetVisibility(), strconv.FormatBool(listed.GetArchived())
+	}
+	if repository.Private == nil {
+		return "", ""
+	}
+	if repository.GetPrivate() {
+		return "private", ""
+	}
+	return "public", ""
+}
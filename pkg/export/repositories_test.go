@@ -0,0 +1,49 @@
+package export
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"go.uber.org/zap"
+)
+
+func TestRepositoryStates(t *testing.T) {
+	fetches := 0
+	states := newRepositoryStates(zap.NewNop(), func() ([]*github.Repository, error) {
+		fetches++
+		return []*github.Repository{
+			{Name: github.String("app"), Visibility: github.String("internal"), Archived: github.Bool(true)},
+			{Name: github.String("lib"), Visibility: github.String("public"), Archived: github.Bool(false)},
+		}, nil
+	})
+
+	tests := []struct {
+		repository *github.Repository
+		visibility string
+		archived   string
+	}{
+		{&github.Repository{Name: github.String("app"), Private: github.Bool(true)}, "internal", "true"},
+		{&github.Repository{Name: github.String("lib")}, "public", "false"},
+		{&github.Repository{Name: github.String("gone"), Private: github.Bool(true)}, "private", ""},
+		{&github.Repository{Name: github.String("embedded"), Visibility: github.String("private"), Archived: github.Bool(false)}, "private", "false"},
+		{nil, "", ""},
+	}
+	for _, tt := range tests {
+		visibility, archived := states.lookup(tt.repository)
+		if visibility != tt.visibility || archived != tt.archived {
+			t.Errorf("lookup(%s) = %q, %q, want %q, %q", tt.repository.GetName(), visibility, archived, tt.visibility, tt.archived)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("repositories listed %d times, want once", fetches)
+	}
+
+	// Without the list the private flag of the package's repository is all there is
+	failing := newRepositoryStates(zap.NewNop(), func() ([]*github.Repository, error) {
+		return nil, errors.New("forbidden")
+	})
+	if visibility, archived := failing.lookup(&github.Repository{Name: github.String("app"), Private: github.Bool(false)}); visibility != "public" || archived != "" {
+		t.Errorf("lookup without the list = %q, %q, want public and no archived state", visibility, archived)
+	}
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// targetFilenames lists the files of a target version. The registry is not
+// read: maven files come from the GraphQL listing, container files are named
+// after the tags of the version and the other package types have one file
+// named after the version, the way their providers name the exported files.
+func targetFilenames(packageType string, pkg *github.Package, version *github.PackageVersion, mavenFiles common.PackageFiles) []string {
+	name := pkg.GetName()
+	switch packageType {
+	case "maven":
+		return mavenFiles[name][version.GetName()]
+	case "container", "docker":
+		var filenames []string
+		if version.Metadata != nil && version.Metadata.Container != nil {
+			for _, tag := range version.Metadata.Container.Tags {
+				filenames = append(filenames, fmt.Sprintf("%s:%s", name, tag))
+			}
+		}
+		return filenames
+	case "npm":
+		return []string{fmt.Sprintf("%s-%s.tgz", name, version.GetName())}
+	case "nuget":
+		return []string{fmt.Sprintf("%s-%s.nupkg", name, version.GetName())}
+	case "rubygems":
+		return []string{fmt.Sprintf("%s-%s.gem", name, version.GetName())}
+	}
+	return nil
+}
+
+// Target inventories the packages the target organization already has, in the
+// CSV schema of export. Sync reads it to report the versions it would publish
+// over content the target had before the migration, verify to tell them apart
+// from migrated ones.
+func Target(logger *zap.Logger) error {
+	startTime := time.Now()
+	owner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	migrationPath := utils.MigrationPath()
+
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		return err
+	}
+	packageTypes = common.SupportedPackageTypes(logger, "target", packageTypes)
+
+	pterm.Info.Println("Starting target inventory...")
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Inventorying packages of target org: %s", owner))
+
+	packageStats := make(map[string]int)
+	versionCount := 0
+	var inventoryFiles []string
+	for _, packageType := range packageTypes {
+		pterm.Info.Println(fmt.Sprintf("📦 Processing %s packages...", packageType))
+		packages, err := api.FetchTargetPackages(packageType)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("❌ Error getting target packages: %v", err))
+			return err
+		}
+		if len(packages) == 0 {
+			pterm.Info.Println(fmt.Sprintf("No %s packages on the target", packageType))
+			continue
+		}
+
+		var mavenFiles common.PackageFiles
+		if packageType == "maven" {
+			if mavenFiles, err = common.FetchPackageFiles(logger, owner, utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", packageType), packageType); err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error getting target files: %v", err))
+				return err
+			}
+		}
+
+		packagesCSV := [][]string{common.INVENTORY_HEADER}
+		for _, pkg := range packages {
+			spinner.UpdateText(fmt.Sprintf("Inventorying %s package(%s) of %s", pkg.GetName(), packageType, owner))
+			versions, err := api.FetchTargetPackageVersions(pkg)
+			if err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error getting target versions: %v", err))
+				return err
+			}
+			versionCount += len(versions)
+			for _, version := range versions {
+				for _, filename := range targetFilenames(packageType, pkg, version, mavenFiles) {
+					packagesCSV = append(packagesCSV, []string{owner, pkg.GetRepository().GetName(), packageType, pkg.GetName(), version.GetName(), filename,
+						formatTimestamp(version.GetCreatedAt()), formatTimestamp(version.GetUpdatedAt()), "", "", "", ""})
+				}
+			}
+		}
+		packageStats[packageType] = len(packages)
+
+		packageDir := common.TargetInventoryDir(migrationPath, packageType)
+		if err := files.EnsureDir(packageDir); err != nil {
+			spinner.Fail(fmt.Sprintf("❌ Error creating package directory: %v", err))
+			return err
+		}
+		timestamp := time.Now().Format("2006-01-02_15-04-05")
+		csvName := filepath.Join(packageDir, fmt.Sprintf("%s_%s_%s_packages.csv", timestamp, owner, packageType))
+		if err := files.CreateCSV(packagesCSV, csvName); err != nil {
+			spinner.Fail(fmt.Sprintf("❌ Error creating CSV: %v", err))
+			return err
+		}
+		inventoryFiles = append(inventoryFiles, csvName)
+	}
+	spinner.Success("Target inventoried")
+
+	duration := time.Since(startTime)
+	hours := int(duration.Hours())
+	minutes := int(duration.Minutes()) % 60
+	seconds := int(duration.Seconds()) % 60
+
+	fmt.Println("\n📊 Target Inventory Summary:")
+	for _, pkgType := range common.SUPPORTED_PACKAGE_TYPES {
+		if count := packageStats[pkgType]; count > 0 {
+			fmt.Printf("  📦 %s: %d\n", pkgType, count)
+		}
+	}
+	fmt.Printf("🗃️ Versions: %d\n", versionCount)
+	for _, inventoryFile := range inventoryFiles {
+		fmt.Printf("  📄 %s\n", inventoryFile)
+	}
+	fmt.Printf("🕐 Total time: %dh %dm %ds\n\n", hours, minutes, seconds)
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package inspect
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// readRecording parses a recording written with --record-http
+func readRecording(path string) ([]utils.HTTPRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	var records []utils.HTTPRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record utils.HTTPRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// matches applies the --status and --failed filters
+func matches(record utils.HTTPRecord, status int, failedOnly bool) bool {
+	if status != 0 && record.Status != status {
+		return false
+	}
+	if failedOnly && record.Error == "" && record.Status < 400 {
+		return false
+	}
+	return true
+}
+
+// decodedPath shows the unescaped path next to the raw one when they differ, which
+// is where package names with scopes or slashes usually go wrong
+func decodedPath(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.RawPath == "" || parsed.RawPath == parsed.Path {
+		return ""
+	}
+	return parsed.Path
+}
+
+// errForeignHost is returned by replay for the requests to hosts other than
+// the source ones: a recording can name any host, sync recordings those of the
+// target, and the source token must not be sent to them
+var errForeignHost = errors.New("not a host of the source")
+
+// replay reissues a recorded request with the source token, only GET and HEAD
+// requests are replayed so nothing is ever written to a registry. Only the
+// requests to the given source hosts are replayed.
+func replay(client *http.Client, record utils.HTTPRecord, sourceHosts []string) (int, error) {
+	req, err := http.NewRequest(record.Method, record.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	if !utils.Contains(sourceHosts, strings.ToLower(req.URL.Host)) {
+		return 0, fmt.Errorf("%s is %w", req.URL.Host, errForeignHost)
+	}
+	if token := viper.GetString("GHMPKG_SOURCE_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if accept, ok := record.RequestHeaders["Accept"]; ok {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func Inspect(logger *zap.Logger, path string, status int, failedOnly, replayRequests bool) error {
+	records, err := readRecording(path)
+	if err != nil {
+		return err
+	}
+
+	client := utils.NewHTTPClient()
+	sourceHosts := providers.SourceHosts(common.SUPPORTED_PACKAGE_TYPES)
+	// Redirects point at signed blob storage URLs that were redacted when recorded
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	statusCounts := make(map[int]int)
+	hostCounts := make(map[string]int)
+	shown, changed := 0, 0
+	for _, record := range records {
+		statusCounts[record.Status]++
+		if parsed, err := url.Parse(record.URL); err == nil {
+			hostCounts[parsed.Host]++
+		}
+		if !matches(record, status, failedOnly) {
+			continue
+		}
+		shown++
+
+		result := fmt.Sprintf("%d", record.Status)
+		if record.Error != "" {
+			result = "ERR"
+		}
+		fmt.Printf("%s %s %-6s %s (%dms)\n", record.Time.Format("15:04:05"), result, record.Method, record.URL, record.DurationMs)
+		if decoded := decodedPath(record.URL); decoded != "" {
+			fmt.Printf("    decoded path: %s\n", decoded)
+		}
+		if record.Error != "" {
+			fmt.Printf("    error: %s\n", record.Error)
+		}
+		if location, ok := record.ResponseHeaders["Location"]; ok {
+			fmt.Printf("    location: %s\n", location)
+		}
+
+		if !replayRequests {
+			continue
+		}
+		if record.Method != http.MethodGet && record.Method != http.MethodHead {
+			fmt.Println("    replay: skipped, only GET and HEAD requests are replayed")
+			continue
+		}
+		current, err := replay(client, record, sourceHosts)
+		if errors.Is(err, errForeignHost) {
+			fmt.Printf("    replay: skipped, %v\n", err)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("    replay: failed: %v\n", err)
+			logger.Warn("Failed to replay request", zap.String("url", record.URL), zap.Error(err))
+			continue
+		}
+		if current != record.Status {
+			changed++
+			pterm.Warning.Printf("    replay: recorded %d, now %d\n", record.Status, current)
+		} else {
+			fmt.Printf("    replay: %d (unchanged)\n", current)
+		}
+	}
+
+	fmt.Println("\n📊 Recording Summary:")
+	fmt.Printf("📄 Requests: %d (%d shown)\n", len(records), shown)
+	statuses := make([]int, 0, len(statusCounts))
+	for code := range statusCounts {
+		statuses = append(statuses, code)
+	}
+	sort.Ints(statuses)
+	for _, code := range statuses {
+		label := fmt.Sprintf("%d", code)
+		if code == 0 {
+			label = "errors"
+		}
+		fmt.Printf("  🔍 %s: %d\n", label, statusCounts[code])
+	}
+	hosts := make([]string, 0, len(hostCounts))
+	for host := range hostCounts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		fmt.Printf("  🌐 %s: %d\n", host, hostCounts[host])
+	}
+	if replayRequests {
+		fmt.Printf("🔁 Replayed requests with a different status: %d\n", changed)
+	}
+
+	return nil
+}
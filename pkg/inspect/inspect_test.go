@@ -0,0 +1,57 @@
+package inspect
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/spf13/viper"
+)
+
+func TestReplaySourceHostsOnly(t *testing.T) {
+	defer viper.Reset()
+	var authorization string
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer source.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("replayed a request to the target: %s %s with %q", r.Method, r.URL, r.Header.Get("Authorization"))
+	}))
+	defer target.Close()
+
+	sourceUrl, _ := url.Parse(source.URL)
+	viper.Set("GHMPKG_SOURCE_HOSTNAME", sourceUrl.Host)
+	viper.Set("GHMPKG_SOURCE_TOKEN", "ghp_source")
+	hosts := providers.SourceHosts([]string{"npm"})
+
+	status, err := replay(source.Client(), utils.HTTPRecord{Method: http.MethodGet, URL: source.URL + "/mona/app"}, hosts)
+	if err != nil || status != http.StatusNotFound {
+		t.Errorf("replay to the source = %d, %v, want 404", status, err)
+	}
+	if authorization != "Bearer ghp_source" {
+		t.Errorf("source request authorization = %q, want the source token", authorization)
+	}
+
+	if _, err := replay(target.Client(), utils.HTTPRecord{Method: http.MethodGet, URL: target.URL + "/mona/app"}, hosts); !errors.Is(err, errForeignHost) {
+		t.Errorf("replay to the target error = %v, want errForeignHost", err)
+	}
+}
+
+func TestSourceHosts(t *testing.T) {
+	defer viper.Reset()
+	hosts := providers.SourceHosts([]string{"npm", "container"})
+	for _, host := range []string{"github.com", "api.github.com", "npm.pkg.github.com", "ghcr.io"} {
+		if !utils.Contains(hosts, host) {
+			t.Errorf("SourceHosts = %v, missing %s", hosts, host)
+		}
+	}
+	if utils.Contains(hosts, "registry.npmjs.org") {
+		t.Errorf("SourceHosts = %v, includes a host of another registry", hosts)
+	}
+}
@@ -0,0 +1,172 @@
+package ledger
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	store "github.com/mona-actions/gh-migrate-packages/internal/ledger"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Entry is a ledger entry with the rewrite verdict spelled out
+type Entry struct {
+	store.Entry
+	Rewritten *bool `json:"rewritten"`
+}
+
+// Summary counts the files of the ledger by how far they got
+type Summary struct {
+	Files      int `json:"files"`
+	Pulled     int `json:"pulled"`
+	Synced     int `json:"synced"`
+	Rewritten  int `json:"rewritten"`
+	Unchanged  int `json:"unchanged"`
+	Incomplete int `json:"incomplete"`
+}
+
+// Document is the ledger written for auditors
+type Document struct {
+	SourceOrganization string        `json:"source_organization"`
+	TargetOrganization string        `json:"target_organization"`
+	GeneratedAt        string        `json:"generated_at"`
+	Run                *run.Metadata `json:"run,omitempty"`
+	Summary            Summary       `json:"summary"`
+	Entries            []Entry       `json:"entries"`
+}
+
+// loadSigningKey reads an ed25519 private key from a PKCS#8 PEM file
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an ed25519 key", path)
+	}
+	return privateKey, nil
+}
+
+func buildDocument(sourceOwner, targetOwner string, entries []store.Entry) Document {
+	document := Document{
+		SourceOrganization: sourceOwner,
+		TargetOrganization: targetOwner,
+		GeneratedAt:        time.Now().UTC().Format(time.RFC3339),
+		Entries:            []Entry{},
+	}
+	for _, entry := range entries {
+		if entry.Organization != sourceOwner {
+			continue
+		}
+		rewritten := entry.Rewritten()
+		document.Entries = append(document.Entries, Entry{Entry: entry, Rewritten: rewritten})
+
+		document.Summary.Files++
+		if entry.SourceDigest != "" {
+			document.Summary.Pulled++
+		}
+		if entry.TargetDigest != "" {
+			document.Summary.Synced++
+		}
+		switch {
+		case rewritten == nil:
+			document.Summary.Incomplete++
+		case *rewritten:
+			document.Summary.Rewritten++
+		default:
+			document.Summary.Unchanged++
+		}
+	}
+	sort.Slice(document.Entries, func(i, j int) bool {
+		a, b := document.Entries[i], document.Entries[j]
+		return state.Key(a.Organization, a.Repository, a.PackageType, a.PackageName, a.Version, a.Filename) <
+			state.Key(b.Organization, b.Repository, b.PackageType, b.PackageName, b.Version, b.Filename)
+	})
+	return document
+}
+
+// Ledger assembles the digests recorded by pull and sync into a single document
+// and signs it with the configured ed25519 key
+func Ledger(logger *zap.Logger) error {
+	sourceOwner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOwner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	signingKeyPath := viper.GetString("GHMPKG_LEDGER_SIGNING_KEY")
+	migrationPath := utils.MigrationPath()
+
+	pterm.Info.Println("Starting ledger process...")
+
+	// Load the key first so a bad key does not leave an unsigned ledger behind
+	var signingKey ed25519.PrivateKey
+	if signingKeyPath != "" {
+		var err error
+		if signingKey, err = loadSigningKey(signingKeyPath); err != nil {
+			return err
+		}
+	}
+
+	ledgerStore, err := store.Load(migrationPath)
+	if err != nil {
+		return err
+	}
+	document := buildDocument(sourceOwner, targetOwner, ledgerStore.List())
+	document.Run = run.Current()
+	logger.Info("Assembled ledger",
+		zap.String("sourceOrganization", sourceOwner),
+		zap.Int("files", document.Summary.Files))
+
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger: %w", err)
+	}
+
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	filename := filepath.Join(migrationPath, "ledger", fmt.Sprintf("%s_%s_%s_ledger.json", timestamp, sourceOwner, targetOwner))
+	if err := utils.WriteFileAtomic(filename, content, 0644); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+
+	signatureFile := ""
+	if signingKey != nil {
+		signatureFile = filename + ".sig"
+		if err := utils.WriteFileAtomic(signatureFile, ed25519.Sign(signingKey, content), 0644); err != nil {
+			return fmt.Errorf("failed to write ledger signature: %w", err)
+		}
+	} else {
+		pterm.Warning.Println("⚠️  No signing key configured, the ledger is not signed")
+	}
+
+	fmt.Println("\n📊 Ledger Summary:")
+	fmt.Printf("📄 Files: %d\n", document.Summary.Files)
+	fmt.Printf("⬇️  Pulled: %d\n", document.Summary.Pulled)
+	fmt.Printf("⬆️  Synced: %d\n", document.Summary.Synced)
+	fmt.Printf("✏️  Rewritten: %d\n", document.Summary.Rewritten)
+	fmt.Printf("🟰 Unchanged: %d\n", document.Summary.Unchanged)
+	fmt.Printf("⏳ Incomplete: %d\n", document.Summary.Incomplete)
+	fmt.Printf("📁 Ledger file: %s\n", filename)
+	if signatureFile != "" {
+		fmt.Printf("🔏 Signature file: %s\n", signatureFile)
+	}
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package ledger
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	store "github.com/mona-actions/gh-migrate-packages/internal/ledger"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestLedgerSignature(t *testing.T) {
+	dir := t.TempDir()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "ledger-key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ledgerStore, err := store.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledgerStore.RecordSource("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz", "sha256:aaa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledgerStore.RecordTarget("mona", "app", "npm", "client", "1.0.0", "client-1.0.0.tgz", "sha256:aaa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledgerStore.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "mona")
+	viper.Set("GHMPKG_TARGET_ORGANIZATION", "octo")
+	viper.Set("GHMPKG_MIGRATION_PATH", dir)
+	viper.Set("GHMPKG_LEDGER_SIGNING_KEY", keyPath)
+	t.Cleanup(viper.Reset)
+
+	if err := Ledger(zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	documents, err := filepath.Glob(filepath.Join(dir, "ledger", "*_mona_octo_ledger.json"))
+	if err != nil || len(documents) != 1 {
+		t.Fatalf("expected one ledger document, got %v (%v)", documents, err)
+	}
+	content, err := os.ReadFile(documents[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := os.ReadFile(documents[0] + ".sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ed25519.Verify(publicKey, content, signature) {
+		t.Fatal("the signature does not verify the ledger")
+	}
+	var document Document
+	if err := json.Unmarshal(content, &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.Summary.Files != 1 || document.Summary.Unchanged != 1 {
+		t.Errorf("Summary = %+v, want the unchanged file", document.Summary)
+	}
+
+	// Claim the file was uploaded with a different digest
+	tampered := bytes.Replace(content, []byte(`"target_digest": "sha256:aaa"`), []byte(`"target_digest": "sha256:bbb"`), 1)
+	if bytes.Equal(tampered, content) {
+		t.Fatal("the ledger has no target digest to tamper with")
+	}
+	if ed25519.Verify(publicKey, tampered, signature) {
+		t.Error("the signature verifies a tampered ledger")
+	}
+}
@@ -0,0 +1,240 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/mona-actions/gh-migrate-packages/pkg/export"
+	"github.com/mona-actions/gh-migrate-packages/pkg/pull"
+	"github.com/mona-actions/gh-migrate-packages/pkg/sync"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// phase is one of the commands chained by migrate
+type phase struct {
+	Name string
+	Run  func(logger *zap.Logger) error
+}
+
+// PHASES are run in order, a migration can be restarted from any of them
+var PHASES = []phase{
+	{"export", export.Export},
+	{"pull", pull.Pull},
+	{"sync", sync.Sync},
+}
+
+// phaseResult is the outcome of a phase in the combined report
+type phaseResult struct {
+	Phase string `json:"phase"`
+	// ReportPath is the --report-json report of the phase, it can be passed to --retry-failed
+	ReportPath string                 `json:"report_path"`
+	Error      string                 `json:"error,omitempty"`
+	Document   *common.ReportDocument `json:"document,omitempty"`
+}
+
+// combinedReport is the document written by migrate --report-json
+type combinedReport struct {
+	Command      string    `json:"command"`
+	Organization string    `json:"organization"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	Error        string    `json:"error,omitempty"`
+	// Status is the worst status of the phases, error when one stopped the migration
+	Status string        `json:"status,omitempty"`
+	Run    *run.Metadata `json:"run,omitempty"`
+	Phases []phaseResult `json:"phases"`
+}
+
+// phasesFrom returns the phases to run when starting from the given one
+func phasesFrom(from string) ([]phase, error) {
+	if from == "" {
+		return PHASES, nil
+	}
+	var names []string
+	for i, p := range PHASES {
+		if p.Name == from {
+			return PHASES[i:], nil
+		}
+		names = append(names, p.Name)
+	}
+	return nil, fmt.Errorf("invalid --from %q, expected one of: %s", from, strings.Join(names, ", "))
+}
+
+// failures counts the packages that failed in a phase
+func failures(document *common.ReportDocument) int {
+	if document == nil || document.Report == nil {
+		return 0
+	}
+	return document.Report.PackagesFailed
+}
+
+// Migrate runs export, pull and sync one after the other with the same
+// settings. Every phase writes its own JSON report, which migrate combines.
+// A phase that stops on an error stops the migration; one that completes with
+// failed packages only does with GHMPKG_FAIL_FAST. A migration whose phases
+// completed returns the failures of the last phase that had some.
+func Migrate(logger *zap.Logger) error {
+	results, err := runPhases(logger)
+	if err != nil {
+		return err
+	}
+	return phaseFailures(results)
+}
+
+// phaseFailures returns the failures of the last phase with failed packages,
+// versions or files, as a common.PartialFailureError, or a common.CriteriaError
+// when the phase did not meet the success criteria
+func phaseFailures(results []phaseResult) error {
+	for i := len(results) - 1; i >= 0; i-- {
+		if document := results[i].Document; document != nil {
+			if failures := document.Failures(); failures != nil {
+				return fmt.Errorf("%s %w", results[i].Phase, failures)
+			}
+		}
+	}
+	return nil
+}
+
+// runPhases runs the phases of a migration and returns their results
+func runPhases(logger *zap.Logger) (results []phaseResult, err error) {
+	startTime := time.Now()
+	phases, err := phasesFrom(viper.GetString("GHMPKG_MIGRATE_FROM"))
+	if err != nil {
+		return nil, err
+	}
+	// A transfer deletes packages, refuse it before export and pull run
+	if err := providers.CheckTransfer(); err != nil {
+		return nil, err
+	}
+	failFast := viper.GetBool("GHMPKG_FAIL_FAST")
+	migrationPath := utils.MigrationPath()
+	reportsDir := filepath.Join(migrationPath, "reports")
+	if err := files.EnsureDir(reportsDir); err != nil {
+		return nil, err
+	}
+
+	// Every phase writes its report to its own file, the path given to
+	// migrate is for the combined one
+	reportPath := viper.GetString("GHMPKG_REPORT_JSON")
+	defer viper.Set("GHMPKG_REPORT_JSON", reportPath)
+
+	stamp := startTime.Format("2006-01-02_15-04-05")
+	for i, p := range phases {
+		pterm.DefaultHeader.Printf("Phase %d/%d: %s", i+1, len(phases), p.Name)
+		fmt.Println()
+
+		result := phaseResult{Phase: p.Name, ReportPath: filepath.Join(reportsDir, fmt.Sprintf("%s_%s.json", stamp, p.Name))}
+		viper.Set("GHMPKG_REPORT_JSON", result.ReportPath)
+		phaseErr := p.Run(logger)
+		// A phase that completed with failures is told apart by its report
+		if common.ExitCode(phaseErr) != common.ExitFatal {
+			phaseErr = nil
+		}
+		if document, readErr := common.ReadReportDocument(result.ReportPath); readErr == nil {
+			result.Document = document
+		} else {
+			logger.Warn("Failed to read phase report", zap.String("phase", p.Name), zap.Error(readErr))
+		}
+
+		if phaseErr != nil {
+			result.Error = phaseErr.Error()
+			err = fmt.Errorf("%s failed: %w", p.Name, phaseErr)
+		} else if failed := phaseFailures([]phaseResult{result}); failed != nil && failFast {
+			// Stopping the migration on them makes the failures fatal
+			err = errors.New(failed.Error())
+		}
+		results = append(results, result)
+		if err != nil {
+			pterm.Error.Printf("❌ %v\n", err)
+			pterm.Info.Printf("Fix the failures and run migrate --from %s to carry on, or %s --retry-failed %s to retry the failed entries\n", p.Name, p.Name, result.ReportPath)
+			break
+		}
+	}
+
+	printSummary(results, phases)
+	if reportPath != "" {
+		if writeErr := writeCombinedReport(reportPath, startTime, results, err); writeErr != nil {
+			logger.Error("Failed to write JSON report", zap.Error(writeErr))
+			pterm.Error.Printf("❌ Error writing JSON report: %v\n", writeErr)
+		}
+	}
+	return results, err
+}
+
+func printSummary(results []phaseResult, phases []phase) {
+	fmt.Println("\n📊 Migrate Summary:")
+	for _, result := range results {
+		status := "✅"
+		if result.Error != "" {
+			status = "❌"
+		} else if failures(result.Document) > 0 {
+			status = "⚠️ "
+		}
+		if result.Document == nil || result.Document.Report == nil {
+			fmt.Printf("%s %s\n", status, result.Phase)
+			continue
+		}
+		report := result.Document.Report
+		fmt.Printf("%s %s: %d packages succeeded, %d skipped, %d failed (%s)\n", status, result.Phase,
+			report.GetPackages(providers.Success), report.GetPackages(providers.Skipped), report.GetPackages(providers.Failed), result.ReportPath)
+	}
+	for _, p := range phases[len(results):] {
+		fmt.Printf("⏹️  %s: not run\n", p.Name)
+	}
+}
+
+// combinedStatus is the status of a migration, the worst status of its phases
+func combinedStatus(results []phaseResult, runErr error) string {
+	if runErr != nil {
+		return common.StatusError
+	}
+	// From the best status to the worst
+	order := []string{common.StatusSuccess, common.StatusPassed, common.StatusPartial, common.StatusFailed}
+	worst := 0
+	for _, result := range results {
+		if result.Document == nil {
+			continue
+		}
+		for i, status := range order {
+			if status == result.Document.Status && i > worst {
+				worst = i
+			}
+		}
+	}
+	return order[worst]
+}
+
+func writeCombinedReport(path string, startTime time.Time, results []phaseResult, runErr error) error {
+	document := combinedReport{
+		Command:      "migrate",
+		Organization: viper.GetString("GHMPKG_SOURCE_ORGANIZATION"),
+		StartedAt:    startTime.UTC(),
+		FinishedAt:   time.Now().UTC(),
+		Status:       combinedStatus(results, runErr),
+		Run:          run.Current(),
+		Phases:       results,
+	}
+	if runErr != nil {
+		document.Error = runErr.Error()
+	}
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := utils.WriteFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	fmt.Printf("📄 JSON report: %s\n", path)
+	return nil
+}
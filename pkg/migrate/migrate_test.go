@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+)
+
+func TestPhasesFrom(t *testing.T) {
+	tests := []struct {
+		from string
+		want []string
+	}{
+		{"", []string{"export", "pull", "sync"}},
+		{"export", []string{"export", "pull", "sync"}},
+		{"pull", []string{"pull", "sync"}},
+		{"sync", []string{"sync"}},
+	}
+	for _, tt := range tests {
+		phases, err := phasesFrom(tt.from)
+		if err != nil {
+			t.Fatalf("phasesFrom(%q): %v", tt.from, err)
+		}
+		var names []string
+		for _, p := range phases {
+			names = append(names, p.Name)
+		}
+		if len(names) != len(tt.want) {
+			t.Fatalf("phasesFrom(%q) = %v, want %v", tt.from, names, tt.want)
+		}
+		for i := range names {
+			if names[i] != tt.want[i] {
+				t.Errorf("phasesFrom(%q) = %v, want %v", tt.from, names, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, err := phasesFrom("verify"); err == nil {
+		t.Error("expected an error for an unknown phase")
+	}
+}
+
+func TestPhaseFailures(t *testing.T) {
+	clean := &common.ReportDocument{Report: common.NewReport()}
+	failed := &common.ReportDocument{Report: common.NewReport()}
+	failed.Report.IncPackages(providers.Failed)
+
+	if err := phaseFailures([]phaseResult{{Phase: "export", Document: clean}, {Phase: "pull"}}); err != nil {
+		t.Errorf("phaseFailures of clean phases = %v", err)
+	}
+	err := phaseFailures([]phaseResult{{Phase: "export", Document: clean}, {Phase: "pull", Document: failed}, {Phase: "sync", Document: clean}})
+	if common.ExitCode(err) != common.ExitPartial || !strings.HasPrefix(err.Error(), "pull completed with 1 failed packages") {
+		t.Errorf("phaseFailures = %v, want the failures of pull", err)
+	}
+}
+
+func TestCombinedStatus(t *testing.T) {
+	passed := &common.ReportDocument{Status: common.StatusPassed, Report: common.NewReport()}
+	failed := &common.ReportDocument{Status: common.StatusFailed, Criteria: []string{"critical package npm/web failed"}, Report: common.NewReport()}
+	failed.Report.IncPackages(providers.Failed)
+
+	results := []phaseResult{{Phase: "export"}, {Phase: "pull", Document: passed}}
+	if status := combinedStatus(results, nil); status != common.StatusPassed {
+		t.Errorf("combinedStatus = %s, want %s", status, common.StatusPassed)
+	}
+	results = append(results, phaseResult{Phase: "sync", Document: failed})
+	if status := combinedStatus(results, nil); status != common.StatusFailed {
+		t.Errorf("combinedStatus = %s, want %s", status, common.StatusFailed)
+	}
+	if err := phaseFailures(results); common.ExitCode(err) != common.ExitCriteria || !strings.Contains(err.Error(), "critical package npm/web failed") {
+		t.Errorf("phaseFailures = %v, want the unmet criteria of sync", err)
+	}
+	if status := combinedStatus(results, errors.New("sync failed")); status != common.StatusError {
+		t.Errorf("combinedStatus of a stopped migration = %s", status)
+	}
+}
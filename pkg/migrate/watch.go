@@ -0,0 +1,147 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// DefaultWatchInterval is the time between the starts of two watch cycles
+const DefaultWatchInterval = 6 * time.Hour
+
+// watchOverlap is subtracted from the start of the last clean cycle when it
+// becomes the --since of the next one, so versions published while its export
+// listed the organization are not missed
+const watchOverlap = 10 * time.Minute
+
+// WatchInterval reads GHMPKG_WATCH_INTERVAL, 6h by default
+func WatchInterval() (time.Duration, error) {
+	value := viper.GetString("GHMPKG_WATCH_INTERVAL")
+	if value == "" {
+		return DefaultWatchInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid --interval %q, expected a duration such as 6h", value)
+	}
+	return interval, nil
+}
+
+// WatchUntil reads GHMPKG_WATCH_UNTIL, the time no new watch cycle starts
+// after: a date (2023-01-01) or an RFC 3339 timestamp. It is zero when the
+// watch runs until it is interrupted.
+func WatchUntil() (time.Time, error) {
+	value := viper.GetString("GHMPKG_WATCH_UNTIL")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if until, err := time.Parse("2006-01-02", value); err == nil {
+		return until, nil
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --watch-until %q, expected a date such as 2023-01-01 or an RFC 3339 timestamp", value)
+	}
+	return until, nil
+}
+
+// nextSince is the --since of the cycle following one that started at
+// started: the cycle only moves it forward when none of its packages failed,
+// so the versions that failed are processed again
+func nextSince(since, started time.Time, results []phaseResult, err error) time.Time {
+	if err != nil {
+		return since
+	}
+	for _, result := range results {
+		if failures(result.Document) > 0 {
+			return since
+		}
+	}
+	if next := started.Add(-watchOverlap); next.After(since) {
+		return next
+	}
+	return since
+}
+
+// Watch runs the migration in cycles, GHMPKG_WATCH_INTERVAL apart, until
+// GHMPKG_WATCH_UNTIL or until it is interrupted. The first cycle migrates
+// everything, later ones only the versions created since the last cycle that
+// completed without failures. A cycle that fails does not stop the watch.
+func Watch(logger *zap.Logger) error {
+	if viper.GetString("GHMPKG_SNAPSHOT") != "" {
+		return fmt.Errorf("--snapshot cannot be combined with --watch, a snapshot is a frozen inventory")
+	}
+	interval, err := WatchInterval()
+	if err != nil {
+		return err
+	}
+	until, err := WatchUntil()
+	if err != nil {
+		return err
+	}
+	since, err := common.ParseSince()
+	if err != nil {
+		return err
+	}
+
+	// The first interrupt lets the running cycle complete, the second one stops now
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer close(signals)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; !ok {
+			return
+		}
+		pterm.Warning.Println("⏹️  Stopping the watch once the current cycle completes, interrupt again to stop now")
+		close(stop)
+		if _, ok := <-signals; ok {
+			os.Exit(130)
+		}
+	}()
+
+	for cycle := 1; ; cycle++ {
+		started := time.Now()
+		if !since.IsZero() {
+			viper.Set("GHMPKG_SINCE", since.UTC().Format(time.RFC3339))
+		}
+		pterm.DefaultSection.Printf("👀 Watch cycle %d", cycle)
+		if !since.IsZero() {
+			pterm.Info.Printf("Migrating the versions created since %s\n", since.UTC().Format(time.RFC3339))
+		}
+		results, err := runPhases(logger)
+		if err != nil {
+			logger.Error("Watch cycle failed", zap.Int("cycle", cycle), zap.Error(err))
+		}
+		// --from only applies to the first cycle
+		viper.Set("GHMPKG_MIGRATE_FROM", "")
+
+		next := nextSince(since, started, results, err)
+		if next.Equal(since) {
+			pterm.Warning.Println("⚠️  The cycle did not complete without failures, the next one processes the same versions again")
+		}
+		since = next
+
+		nextCycle := started.Add(interval)
+		if !until.IsZero() && nextCycle.After(until) {
+			pterm.Success.Printf("✅ Watch ended after %d cycles, no cycle starts after %s\n", cycle, until.Format(time.RFC3339))
+			return nil
+		}
+		pterm.Info.Printf("💤 Next cycle at %s\n", nextCycle.Format(time.RFC3339))
+		logger.Info("Waiting for the next watch cycle", zap.Int("cycle", cycle), zap.Time("next", nextCycle), zap.Time("since", since))
+		select {
+		case <-stop:
+			pterm.Success.Printf("✅ Watch stopped after %d cycles\n", cycle)
+			return nil
+		case <-time.After(time.Until(nextCycle)):
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/spf13/viper"
+)
+
+func TestNextSince(t *testing.T) {
+	started := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	since := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	clean := []phaseResult{{Phase: "export"}, {Phase: "sync", Document: &common.ReportDocument{Report: &common.Report{PackageSuccess: 3}}}}
+	failed := []phaseResult{{Phase: "export"}, {Phase: "sync", Document: &common.ReportDocument{Report: &common.Report{PackagesFailed: 1}}}}
+
+	if got := nextSince(since, started, clean, nil); !got.Equal(started.Add(-watchOverlap)) {
+		t.Errorf("clean cycle: nextSince = %s, want the start of the cycle less the overlap", got)
+	}
+	if got := nextSince(time.Time{}, started, clean, nil); !got.Equal(started.Add(-watchOverlap)) {
+		t.Errorf("clean first cycle: nextSince = %s", got)
+	}
+	if got := nextSince(since, started, failed, nil); !got.Equal(since) {
+		t.Errorf("cycle with failed packages moved since to %s", got)
+	}
+	if got := nextSince(since, started, clean[:1], errors.New("sync failed")); !got.Equal(since) {
+		t.Errorf("failed cycle moved since to %s", got)
+	}
+	// A --since later than the cycle is kept
+	if got := nextSince(started, started, clean, nil); !got.Equal(started) {
+		t.Errorf("nextSince moved since back to %s", got)
+	}
+}
+
+func TestWatchSettings(t *testing.T) {
+	defer viper.Reset()
+	if interval, err := WatchInterval(); err != nil || interval != DefaultWatchInterval {
+		t.Errorf("WatchInterval = %s, %v, want the default", interval, err)
+	}
+	viper.Set("GHMPKG_WATCH_INTERVAL", "0s")
+	if _, err := WatchInterval(); err == nil {
+		t.Error("a zero interval was accepted")
+	}
+	viper.Set("GHMPKG_WATCH_UNTIL", "2025-03-01")
+	if until, err := WatchUntil(); err != nil || !until.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("WatchUntil = %s, %v", until, err)
+	}
+	viper.Set("GHMPKG_WATCH_UNTIL", "tomorrow")
+	if _, err := WatchUntil(); err == nil {
+		t.Error("an invalid --watch-until was accepted")
+	}
+}
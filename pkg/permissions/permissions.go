@@ -0,0 +1,194 @@
+package permissions
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Reasons a permission has to be granted by hand
+const (
+	PackageMissing    = "package_missing_on_target"
+	OrganizationScope = "organization_scoped"
+	NotLinked         = "not_linked_on_target"
+	VisibilityChanged = "visibility_differs"
+	GrantFailed       = "grant_failed"
+)
+
+// grant gives a team access to a target repository, which its linked packages inherit
+type grant struct {
+	Team       string
+	Repository string
+	Permission string
+}
+
+// manualRow is a permission the API cannot apply
+func manualRow(packageType, packageName, repository, team, permission, reason, detail, url string) []string {
+	return []string{packageType, packageName, repository, team, permission, reason, detail, url}
+}
+
+// settingsUrl points at the access settings of a target package
+func settingsUrl(pkg *github.Package) string {
+	if pkg.GetHTMLURL() == "" {
+		return ""
+	}
+	return pkg.GetHTMLURL() + "/settings"
+}
+
+// plan works out the grants for the rows of a permissions export, and the
+// permissions that have to be applied by hand because the API does not expose them
+func plan(rows [][]string, lookup func(packageType, packageName string) (*github.Package, error)) ([]grant, [][]string, error) {
+	var grants []grant
+	var manual [][]string
+	seen := make(map[grant]bool)
+	checked := make(map[string]bool)
+
+	for _, row := range rows {
+		if len(row) < len(common.PERMISSIONS_HEADER) {
+			continue
+		}
+		packageType, packageName, repository, visibility, team, permission := row[1], row[2], row[3], row[4], row[5], row[6]
+
+		target, err := lookup(packageType, packageName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get %s package %s from target: %w", packageType, packageName, err)
+		}
+		if target == nil {
+			manual = append(manual, manualRow(packageType, packageName, repository, team, permission, PackageMissing, "sync the package first", ""))
+			continue
+		}
+
+		// The package level checks are reported once, not for every team
+		packageKey := packageType + "|" + packageName
+		if !checked[packageKey] {
+			checked[packageKey] = true
+			if visibility != "" && target.GetVisibility() != visibility {
+				manual = append(manual, manualRow(packageType, packageName, repository, "", "", VisibilityChanged,
+					fmt.Sprintf("source=%s target=%s", visibility, target.GetVisibility()), settingsUrl(target)))
+			}
+			if repository == "" {
+				manual = append(manual, manualRow(packageType, packageName, "", "", "", OrganizationScope, "access granted on the package is not exposed by the API", settingsUrl(target)))
+			}
+		}
+		if team == "" {
+			continue
+		}
+
+		targetRepository := target.GetRepository().GetName()
+		if targetRepository == "" {
+			manual = append(manual, manualRow(packageType, packageName, repository, team, permission, NotLinked, "link the package to a repository or grant the team access to it", settingsUrl(target)))
+			continue
+		}
+		g := grant{Team: team, Repository: targetRepository, Permission: permission}
+		if !seen[g] {
+			seen[g] = true
+			grants = append(grants, g)
+		}
+	}
+	return grants, manual, nil
+}
+
+// ApplyPermissions re-grants on the target the access to packages recorded by
+// export --permissions. Teams get their permission on the repository the
+// package is linked to, everything else is written to a CSV to apply by hand.
+func ApplyPermissions(logger *zap.Logger) error {
+	sourceOwner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOwner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	dryRun := viper.GetBool("GHMPKG_DRY_RUN")
+	migrationPath := utils.MigrationPath()
+
+	pterm.Info.Println("Starting apply-permissions process...")
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		return err
+	}
+
+	// Packages have a row per team, each one is looked up once
+	targets := make(map[string]*github.Package)
+	lookup := func(packageType, packageName string) (*github.Package, error) {
+		key := packageType + "|" + packageName
+		if pkg, ok := targets[key]; ok {
+			return pkg, nil
+		}
+		pkg, err := api.FetchTargetPackage(packageType, packageName)
+		if err != nil {
+			return nil, err
+		}
+		targets[key] = pkg
+		return pkg, nil
+	}
+
+	var rows [][]string
+	for _, packageType := range packageTypes {
+		filename, err := common.FindPermissions(migrationPath, packageType, sourceOwner)
+		if err != nil {
+			logger.Info("No permissions export", zap.String("packageType", packageType), zap.Error(err))
+			continue
+		}
+		pterm.Info.Printf("📄 Reading %s\n", filename)
+		content, err := files.ReadCSV(filename)
+		if err != nil {
+			return err
+		}
+		if len(content) > 1 {
+			rows = append(rows, content[1:]...)
+		}
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("no permissions export found in %s, run export --permissions first", migrationPath)
+	}
+
+	grants, manual, err := plan(rows, lookup)
+	if err != nil {
+		return err
+	}
+
+	granted, failed := 0, 0
+	for _, g := range grants {
+		if dryRun {
+			pterm.Info.Printf("🔎 Would grant team %s %s on %s/%s\n", g.Team, g.Permission, targetOwner, g.Repository)
+			continue
+		}
+		if err := api.GrantTargetTeamRepository(g.Team, g.Repository, g.Permission); err != nil {
+			logger.Error("Failed to grant team access", zap.String("team", g.Team), zap.String("repository", g.Repository), zap.Error(err))
+			pterm.Error.Printf("❌ Failed to grant team %s %s on %s: %v\n", g.Team, g.Permission, g.Repository, err)
+			manual = append(manual, manualRow("", "", g.Repository, g.Team, g.Permission, GrantFailed, err.Error(), ""))
+			failed++
+			continue
+		}
+		pterm.Success.Printf("✅ Granted team %s %s on %s\n", g.Team, g.Permission, g.Repository)
+		granted++
+	}
+
+	if len(manual) > 0 {
+		outputDir := filepath.Join(migrationPath, "permissions")
+		if err := files.EnsureDir(outputDir); err != nil {
+			return err
+		}
+		outputPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s_manual_permissions.csv", time.Now().Format("2006-01-02_15-04-05"), targetOwner))
+		content := append([][]string{{"package_type", "package_name", "repository", "team", "permission", "reason", "detail", "settings_url"}}, manual...)
+		if err := files.CreateCSV(content, outputPath); err != nil {
+			return err
+		}
+		pterm.Warning.Printf("⚠️  %d permissions need to be applied by hand, listed in: %s\n", len(manual), outputPath)
+	}
+
+	fmt.Println("\n📊 Summary:")
+	if dryRun {
+		fmt.Printf("🔎 Team grants planned: %d\n", len(grants))
+	} else {
+		fmt.Printf("✅ Team grants applied: %d\n", granted)
+		fmt.Printf("❌ Team grants failed: %d\n", failed)
+	}
+	fmt.Printf("✋ Manual follow-ups: %d\n", len(manual))
+	return nil
+}
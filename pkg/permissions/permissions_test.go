@@ -0,0 +1,50 @@
+package permissions
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+)
+
+func TestPlan(t *testing.T) {
+	rows := [][]string{
+		{"mona-actions", "npm", "lib", "lib-repo", "private", "devs", "push"},
+		{"mona-actions", "npm", "lib", "lib-repo", "private", "ops", "admin"},
+		{"mona-actions", "maven", "app", "lib-repo", "private", "devs", "push"},
+		{"mona-actions", "npm", "shared", "", "internal", "", ""},
+		{"mona-actions", "npm", "gone", "gone-repo", "private", "devs", "pull"},
+		{"mona-actions", "container", "image", "image-repo", "public", "devs", "pull"},
+	}
+	targets := map[string]*github.Package{
+		"npm|lib":         {Visibility: github.String("private"), Repository: &github.Repository{Name: github.String("lib-repo")}},
+		"maven|app":       {Visibility: github.String("private"), Repository: &github.Repository{Name: github.String("lib-repo")}},
+		"npm|shared":      {Visibility: github.String("private"), HTMLURL: github.String("https://github.com/orgs/mona-emu/packages/npm/package/shared")},
+		"container|image": {Visibility: github.String("public")},
+	}
+	lookup := func(packageType, packageName string) (*github.Package, error) {
+		return targets[packageType+"|"+packageName], nil
+	}
+
+	grants, manual, err := plan(rows, lookup)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	// A team is granted once per repository, whatever the number of packages linked to it
+	wantGrants := []grant{{"devs", "lib-repo", "push"}, {"ops", "lib-repo", "admin"}}
+	if !reflect.DeepEqual(grants, wantGrants) {
+		t.Errorf("grants = %v, want %v", grants, wantGrants)
+	}
+
+	var reasons []string
+	for _, row := range manual {
+		reasons = append(reasons, row[1]+":"+row[5])
+	}
+	wantReasons := []string{"shared:" + VisibilityChanged, "shared:" + OrganizationScope, "gone:" + PackageMissing, "image:" + NotLinked}
+	if !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("manual = %v, want %v", reasons, wantReasons)
+	}
+	if manual[0][7] != "https://github.com/orgs/mona-emu/packages/npm/package/shared/settings" {
+		t.Errorf("settings url = %s", manual[0][7])
+	}
+}
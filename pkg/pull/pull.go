@@ -1,13 +1,14 @@
 package pull
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/mona-actions/gh-migrate-packages/internal/files"
 	"github.com/mona-actions/gh-migrate-packages/internal/providers"
 	"github.com/mona-actions/gh-migrate-packages/internal/utils"
 	"github.com/mona-actions/gh-migrate-packages/pkg/common"
@@ -47,8 +48,9 @@ func Download(logger *zap.Logger, provider providers.Provider, report *common.Re
 	// Create error channel to collect errors from workers
 	errChan := make(chan error, len(filenames))
 
-	// Create semaphore channel for concurrency control
-	sem := make(chan struct{}, 5)
+	// Create semaphore channel for concurrency control, GHMPKG_FILE_CONCURRENCY
+	// files of the version are downloaded at once
+	sem := make(chan struct{}, providers.FileConcurrency(packageType))
 
 	// Create wait group to track when all downloads are complete
 	var wg sync.WaitGroup
@@ -72,7 +74,7 @@ func Download(logger *zap.Logger, provider providers.Provider, report *common.Re
 				zap.String("version", version),
 				zap.String("filename", filename))
 
-			if packageType == "container" {
+			if providers.IsImage(packageType) {
 				// Extract semantic version from filename for containers
 				semanticVersion := strings.Split(filename, ":")[1]
 				logger.Info("Processing container package",
@@ -83,12 +85,13 @@ func Download(logger *zap.Logger, provider providers.Provider, report *common.Re
 					zap.String("owner", owner),
 					zap.String("repository", repository))
 
-				if result, err := provider.Download(logger, owner, repository, packageType, packageName, semanticVersion, filename); err != nil {
+				if result, err := provider.Download(logger, owner, repository, packageType, packageName, semanticVersion, filename); err != nil && !providers.IsSkip(err) {
 					logger.Error("Failed to download package", append(zapFields,
 						zap.String("filename", filename),
 						zap.String("semanticVersion", semanticVersion),
 						zap.Error(err))...)
 					pterm.Error.Println(fmt.Sprintf("    ❌ Failed to download: %s", filename))
+					report.RecordFile(common.NewItem(owner, repository, packageType, packageName, version, filename, providers.Failed, err))
 					errChan <- fmt.Errorf("failed to download %s: %w", filename, err)
 				} else {
 					logger.Info("Download result",
@@ -96,7 +99,7 @@ func Download(logger *zap.Logger, provider providers.Provider, report *common.Re
 						zap.String("version", semanticVersion),
 						zap.String("filename", filename),
 						zap.Any("result", result))
-					report.IncFiles(result)
+					report.RecordFile(common.NewItem(owner, repository, packageType, packageName, version, filename, result, err))
 					if result == providers.Success {
 						pterm.Success.Println(fmt.Sprintf("✅ %s", filename))
 					}
@@ -109,11 +112,12 @@ func Download(logger *zap.Logger, provider providers.Provider, report *common.Re
 					zap.String("filename", filename))
 
 				result, err := provider.Download(logger, owner, repository, packageType, packageName, version, filename)
-				if err != nil {
+				if err != nil && !providers.IsSkip(err) {
 					logger.Error("Failed to download package", append(zapFields,
 						zap.String("filename", filename),
 						zap.Error(err))...)
 					pterm.Error.Println(fmt.Sprintf("❌ Failed to download: %s", filename))
+					report.RecordFile(common.NewItem(owner, repository, packageType, packageName, version, filename, providers.Failed, err))
 					errChan <- fmt.Errorf("failed to download %s: %w", filename, err)
 				} else {
 					logger.Info("Download completed",
@@ -121,7 +125,7 @@ func Download(logger *zap.Logger, provider providers.Provider, report *common.Re
 						zap.String("version", version),
 						zap.String("filename", filename),
 						zap.Any("result", result))
-					report.IncFiles(result)
+					report.RecordFile(common.NewItem(owner, repository, packageType, packageName, version, filename, result, err))
 					if result == providers.Success {
 						pterm.Success.Println(fmt.Sprintf("✅ %s", filename))
 					}
@@ -150,29 +154,95 @@ func Download(logger *zap.Logger, provider providers.Provider, report *common.Re
 func Pull(logger *zap.Logger) error {
 	startTime := time.Now()
 	owner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
-	desiredPackageType := viper.GetString("GHMPKG_PACKAGE_TYPES")
+	desiredPackageTypes := common.DesiredPackageTypes()
+	desiredRepositories := common.DesiredRepositories()
+	migrationPath := utils.MigrationPath()
 
 	logger.Info("Starting pull process",
 		zap.String("owner", owner),
-		zap.String("desiredPackageType", desiredPackageType))
+		zap.Strings("desiredPackageTypes", desiredPackageTypes),
+		zap.Strings("desiredRepositories", desiredRepositories))
 
 	pterm.Info.Println("Starting pull process...")
 	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Pulling packages from source org: %s", owner))
 
 	// Add directory existence check
-	if _, err := os.Stat("./migration-packages"); os.IsNotExist(err) {
-		spinner.Fail("migration-packages directory not found")
-		return fmt.Errorf("migration-packages directory not found: %w", err)
+	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
+		spinner.Fail(fmt.Sprintf("%s directory not found", migrationPath))
+		return fmt.Errorf("%s directory not found: %w", migrationPath, err)
 	}
 
-	// Handle either specific package type or all package types
-	packageTypes := SUPPORTED_PACKAGE_TYPES
-	if desiredPackageType != "" {
-		if !utils.Contains(SUPPORTED_PACKAGE_TYPES, desiredPackageType) {
-			spinner.Fail(fmt.Sprintf("Unsupported package type: %s", desiredPackageType))
-			return fmt.Errorf("unsupported package type: %s", desiredPackageType)
-		}
-		packageTypes = []string{desiredPackageType}
+	// Handle either specific package types or all package types
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	snapshot, err := common.RequiredSnapshot()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	if snapshot != "" {
+		pterm.Info.Println(fmt.Sprintf("📸 Pulling snapshot: %s", snapshot))
+	}
+	if _, err := common.NewSuccessCriteria(); err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	if _, err := common.ProgressBarsMode(); err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+
+	if len(desiredRepositories) > 0 {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering for repositories: %s", strings.Join(desiredRepositories, ", ")))
+	}
+	nameFilter, err := common.NewNameFilter()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	if !nameFilter.IsEmpty() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering package names: %s", nameFilter))
+	}
+	versionFilter, err := common.NewVersionFilter()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	if !versionFilter.IsEmpty() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering versions: %s", versionFilter))
+	}
+	since, err := common.ParseSince()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	if !since.IsZero() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering versions created since %s", since.Format(time.RFC3339)))
+	}
+
+	checksumMode, err := providers.ChecksumMode()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	if _, err := providers.StagingNames(); err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	store, err := providers.ArtifactStorage()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	if store != nil {
+		pterm.Info.Println(fmt.Sprintf("☁️  Copying pulled files to %s", store))
+	}
+	if err := providers.RecordStagingLayout(logger, migrationPath, owner); err != nil {
+		spinner.Fail(fmt.Sprintf("Error recording the staging layout: %v", err))
+		return err
 	}
 
 	var allPackages [][]string
@@ -183,7 +253,7 @@ func Pull(logger *zap.Logger) error {
 		pterm.Info.Println(fmt.Sprintf("Processing %s packages...", pkgType))
 
 		// Check if package type directory exists
-		pkgTypeDir := fmt.Sprintf("./migration-packages/export/%s", pkgType)
+		pkgTypeDir := filepath.Join(migrationPath, "export", pkgType)
 		if _, err := os.Stat(pkgTypeDir); os.IsNotExist(err) {
 			logger.Warn("Package type directory not found",
 				zap.String("packageType", pkgType),
@@ -191,36 +261,24 @@ func Pull(logger *zap.Logger) error {
 			continue
 		}
 
-		// Look for the most recent CSV file in the package type directory
-		pattern := fmt.Sprintf("./migration-packages/export/%s/*_%s_%s_packages.csv", pkgType, owner, pkgType)
-		logger.Info("Searching for CSV with pattern", zap.String("pattern", pattern))
-
-		matches, err := utils.FindMostRecentFile(pattern)
+		// Look for the most recent export, in the inventory database with --store
+		// sqlite or as a CSV or JSON manifest in the package type directory
+		matches, packages, err := common.LoadInventory(logger, migrationPath, pkgType, owner, snapshot)
+		if errors.Is(err, common.ErrNoInventory) {
+			logger.Warn("No export file found for package type",
+				zap.String("packageType", pkgType),
+				zap.Error(err))
+			continue
+		}
 		if err != nil {
-			// Try alternate pattern without owner in filename
-			altPattern := fmt.Sprintf("./migration-packages/export/%s/*_%s_packages.csv", pkgType, pkgType)
-			logger.Info("Trying alternate pattern",
-				zap.String("altPattern", altPattern))
-
-			matches, err = utils.FindMostRecentFile(altPattern)
-			if err != nil {
-				logger.Warn("No export file found for package type",
-					zap.String("packageType", pkgType),
-					zap.Error(err))
-				continue
-			}
+			spinner.Fail(fmt.Sprintf("Error reading export file for %s: %v", pkgType, err))
+			return err
 		}
 
-		logger.Info("Found CSV file",
+		logger.Info("Found export file",
 			zap.String("packageType", pkgType),
 			zap.String("file", matches))
 
-		packages, err := files.ReadCSV(matches)
-		if err != nil {
-			spinner.Fail(fmt.Sprintf("Error reading CSV file for %s: %v", pkgType, err))
-			return err
-		}
-
 		// Log the content of the first few rows to verify data
 		logger.Info("CSV content sample",
 			zap.String("packageType", pkgType),
@@ -232,8 +290,33 @@ func Pull(logger *zap.Logger) error {
 			continue
 		}
 
-		allPackages = append(allPackages, packages[1:]...)
-		for _, pkg := range packages[1:] {
+		rows := packages[1:]
+		if len(desiredRepositories) > 0 {
+			rows = common.FilterByRepository(rows, desiredRepositories)
+			if len(rows) == 0 {
+				pterm.Info.Println(fmt.Sprintf("No %s packages found for repositories %s", pkgType, strings.Join(desiredRepositories, ", ")))
+				continue
+			}
+		}
+		if rows = nameFilter.FilterRows(rows); len(rows) == 0 {
+			pterm.Info.Println(fmt.Sprintf("No %s packages match the name filter", pkgType))
+			continue
+		}
+		rows, undated := common.FilterSince(rows, since)
+		if undated > 0 {
+			pterm.Warning.Printf("⚠️  %d %s rows have no creation time and are kept, re-export to filter them with --since\n", undated, pkgType)
+		}
+		if len(rows) == 0 {
+			pterm.Info.Println(fmt.Sprintf("No %s versions were created since %s", pkgType, since.Format(time.RFC3339)))
+			continue
+		}
+		if rows = versionFilter.FilterRows(rows); len(rows) == 0 {
+			pterm.Info.Println(fmt.Sprintf("No %s versions match the version filter", pkgType))
+			continue
+		}
+
+		allPackages = append(allPackages, rows...)
+		for _, pkg := range rows {
 			if _, ok := packageStats[pkgType]; ok {
 				if utils.Contains(packageStats[pkgType], pkg[3]) {
 					continue
@@ -268,7 +351,21 @@ func Pull(logger *zap.Logger) error {
 		return fmt.Errorf("no package export files found")
 	}
 
-	report, err := common.ProcessPackages(logger, allPackages, Download, false)
+	if checksumMode != providers.ChecksumsOff {
+		if expected := common.ExpectChecksums(allPackages); expected > 0 {
+			pterm.Info.Println(fmt.Sprintf("🔐 Verifying %d files against the exported checksums (%s on mismatch)", expected, checksumMode))
+		}
+	}
+
+	// The progress bars take over from the spinner while the packages are pulled
+	if common.ProgressBarsEnabled() {
+		spinner.Stop()
+	}
+	report, err := common.ProcessPackages(logger, allPackages, Download, false, "pull")
+	if jsonErr := common.WriteReportJSON("pull", startTime, report, err); jsonErr != nil {
+		logger.Error("Failed to write JSON report", zap.Error(jsonErr))
+		pterm.Error.Printf("❌ Error writing JSON report: %v\n", jsonErr)
+	}
 	if err != nil {
 		spinner.Fail(fmt.Sprintf("Error pulling package: %v", err))
 		return err
@@ -295,8 +392,17 @@ func Pull(logger *zap.Logger) error {
 		}
 	}
 
-	fmt.Println("📁 Output directory: migration-packages/packages")
+	report.PrintSkipReasons()
+	fmt.Printf("📁 Output directory: %s\n", filepath.Join(migrationPath, "packages"))
+	if err := common.WriteNameAudit("pull"); err != nil {
+		logger.Error("Failed to write name audit", zap.Error(err))
+		pterm.Error.Printf("❌ Error writing name audit: %v\n", err)
+	}
 	fmt.Printf("🕐 Total time: %dh %dm %ds\n\n", hours, minutes, seconds)
+	if failures := report.Failures(); failures != nil {
+		fmt.Printf("⚠️  Pull %v\n", failures)
+		return failures
+	}
 	fmt.Println("✅ Pull completed successfully!")
 
 	return nil
@@ -0,0 +1,302 @@
+package references
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// HEADER is the header of the references report
+var HEADER = []string{"repository", "path", "line", "package_type", "reference", "replacement", "url"}
+
+// maxFileSize is the size past which a file is not scanned, workflows and
+// Dockerfiles are much smaller
+const maxFileSize = 1 << 20
+
+// referenceDelimiters end a reference within a line
+const referenceDelimiters = " \t\r\"'`,;()[]{}<>"
+
+// pattern is a prefix of references to the source registries, such as
+// ghcr.io/old-org or the @old-org npm scope
+type pattern struct {
+	packageType string
+	find        string
+	// rewrite turns the rest of a reference, after find, into the reference on
+	// the target. Nil when the target is unknown.
+	rewrite func(rest string) string
+}
+
+// match is a reference found in a file
+type match struct {
+	line        int
+	packageType string
+	reference   string
+	replacement string
+}
+
+// patterns builds the references to look for from the source registries of the
+// package types, and how they are rewritten for the target when it is known
+func patterns(packageTypes []string, sourceOwner, targetOwner string) []pattern {
+	var found []pattern
+	prefix := func(replace string) func(string) string {
+		if targetOwner == "" {
+			return nil
+		}
+		return func(rest string) string { return replace + rest }
+	}
+	targetImages := ""
+	if targetOwner != "" {
+		target := providers.TargetContainerRegistry()
+		targetImages = path.Join(target.Host, target.Repository(strings.ToLower(targetOwner), "", ""))
+	}
+
+	for _, packageType := range packageTypes {
+		switch packageType {
+		case "container":
+			source := providers.SourceContainerRegistry()
+			found = append(found, pattern{
+				packageType: packageType,
+				find:        path.Join(source.Host, source.Repository(strings.ToLower(sourceOwner), "", "")),
+				rewrite:     prefix(targetImages),
+			})
+		case "docker":
+			// Legacy images are named after their repository, which the Container registry drops
+			source := providers.SourceLegacyDockerRegistry()
+			var rewrite func(string) string
+			if targetOwner != "" {
+				rewrite = func(rest string) string {
+					if image, ok := strings.CutPrefix(rest, "/"); ok {
+						if _, image, ok = strings.Cut(image, "/"); ok {
+							return targetImages + "/" + image
+						}
+					}
+					return targetImages + rest
+				}
+			}
+			found = append(found, pattern{
+				packageType: packageType,
+				find:        path.Join(source.Host, strings.ToLower(sourceOwner)),
+				rewrite:     rewrite,
+			})
+		case "npm":
+			// Registry URLs of npm carry no owner, packages are found by their scope
+			found = append(found, pattern{
+				packageType: packageType,
+				find:        "@" + strings.ToLower(sourceOwner),
+				rewrite:     prefix("@" + strings.ToLower(targetOwner)),
+			})
+		default:
+			base := providers.NewBaseProvider(packageType, "", "", false)
+			found = append(found, pattern{
+				packageType: packageType,
+				find:        path.Join(base.SourceRegistryUrl.Host, base.SourceRegistryUrl.Path, sourceOwner),
+				rewrite:     prefix(path.Join(base.TargetRegistryUrl.Host, base.TargetRegistryUrl.Path, targetOwner)),
+			})
+		}
+	}
+	return found
+}
+
+// isScanned reports whether a repository file is a workflow, a Dockerfile, an
+// .npmrc or a Maven pom.xml
+func isScanned(filePath string) bool {
+	name := strings.ToLower(path.Base(filePath))
+	if name == ".npmrc" || name == "pom.xml" {
+		return true
+	}
+	if path.Dir(filePath) == ".github/workflows" {
+		return strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")
+	}
+	for _, dockerfile := range []string{"dockerfile", "containerfile"} {
+		if name == dockerfile || strings.HasPrefix(name, dockerfile+".") || strings.HasSuffix(name, "."+dockerfile) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNameChar reports whether a character can be part of a host, owner or package name
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+// asciiLower lowercases the ASCII letters of s only, so offsets in the result
+// are offsets in s
+func asciiLower(s string) string {
+	lower := []byte(s)
+	for i, c := range lower {
+		if c >= 'A' && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+	return string(lower)
+}
+
+// span is where a pattern matched in a line, the reference runs from start to end
+type span struct {
+	start, end int
+	pattern    pattern
+}
+
+// lineReferences finds the references to the source registries in a line.
+// Patterns only match on name boundaries: ghcr.io/old-org does not match
+// ghcr.io/old-org-tools nor @old-org an e-mail address.
+func lineReferences(line string, patterns []pattern) []span {
+	var spans []span
+	lower := asciiLower(line)
+	for _, p := range patterns {
+		find := asciiLower(p.find)
+		for offset := 0; ; {
+			index := strings.Index(lower[offset:], find)
+			if index < 0 {
+				break
+			}
+			start := offset + index
+			end := start + len(find)
+			offset = end
+			if start > 0 && isNameChar(line[start-1]) {
+				continue
+			}
+			if end < len(line) && isNameChar(line[end]) {
+				continue
+			}
+			length := strings.IndexAny(line[start:], referenceDelimiters)
+			if length < 0 {
+				length = len(line) - start
+			}
+			spans = append(spans, span{start: start, end: start + length, pattern: p})
+		}
+	}
+	return spans
+}
+
+// replacement is the reference on the target of a reference found by a pattern,
+// empty when the target is unknown
+func (s span) replacement(line string) string {
+	if s.pattern.rewrite == nil {
+		return ""
+	}
+	return s.pattern.rewrite(line[s.start+len(s.pattern.find) : s.end])
+}
+
+// scan finds the references to the source registries in the content of a file
+func scan(content string, patterns []pattern) []match {
+	var matches []match
+	for number, line := range strings.Split(content, "\n") {
+		for _, s := range lineReferences(line, patterns) {
+			matches = append(matches, match{
+				line:        number + 1,
+				packageType: s.pattern.packageType,
+				reference:   line[s.start:s.end],
+				replacement: s.replacement(line),
+			})
+		}
+	}
+	return matches
+}
+
+// References scans the workflows, Dockerfiles, .npmrc files and Maven poms of
+// the source repositories for references to the source registries: images, npm
+// scopes and registry URLs.
+// The files needing an update after the cutover are written to a CSV, with the
+// reference each one becomes on the target when the target organization is set.
+func References(logger *zap.Logger) error {
+	sourceOwner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOwner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	migrationPath := utils.MigrationPath()
+
+	pterm.Info.Println("Starting references scan...")
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		return err
+	}
+	searched := patterns(packageTypes, sourceOwner, targetOwner)
+
+	spinner, _ := pterm.DefaultSpinner.Start("Listing repositories...")
+	repositories, err := api.FetchSourceRepositories()
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to list repositories: %v", err))
+		return err
+	}
+	spinner.Success(fmt.Sprintf("Found %d repositories", len(repositories)))
+	desiredRepositories := common.DesiredRepositories()
+
+	var rows [][]string
+	scannedRepositories, scannedFiles, filesToUpdate := 0, 0, 0
+	for _, repository := range repositories {
+		name := repository.GetName()
+		if !common.MatchRepository(desiredRepositories, name) {
+			continue
+		}
+		branch := repository.GetDefaultBranch()
+		entries, truncated, err := api.FetchSourceTree(name, branch)
+		if err != nil {
+			logger.Error("Failed to list repository files", zap.String("repository", name), zap.Error(err))
+			pterm.Error.Printf("❌ Failed to list the files of %s: %v\n", name, err)
+			continue
+		}
+		if truncated {
+			pterm.Warning.Printf("⚠️  %s has too many files to list them all, some files were not scanned\n", name)
+		}
+		scannedRepositories++
+
+		for _, entry := range entries {
+			if entry.GetType() != "blob" || !isScanned(entry.GetPath()) {
+				continue
+			}
+			if entry.GetSize() > maxFileSize {
+				logger.Warn("Skipping large file", zap.String("repository", name), zap.String("path", entry.GetPath()), zap.Int("size", entry.GetSize()))
+				continue
+			}
+			content, err := api.FetchSourceBlob(name, entry.GetSHA())
+			if err != nil {
+				logger.Error("Failed to read file", zap.String("repository", name), zap.String("path", entry.GetPath()), zap.Error(err))
+				pterm.Error.Printf("❌ Failed to read %s/%s: %v\n", name, entry.GetPath(), err)
+				continue
+			}
+			scannedFiles++
+
+			matches := scan(string(content), searched)
+			if len(matches) == 0 {
+				continue
+			}
+			filesToUpdate++
+			pterm.Info.Printf("🔗 %s/%s: %d references\n", name, entry.GetPath(), len(matches))
+			for _, m := range matches {
+				url := fmt.Sprintf("%s/blob/%s/%s#L%d", repository.GetHTMLURL(), branch, entry.GetPath(), m.line)
+				rows = append(rows, []string{name, entry.GetPath(), strconv.Itoa(m.line), m.packageType, m.reference, m.replacement, url})
+			}
+		}
+	}
+
+	if len(rows) > 0 {
+		outputDir := filepath.Join(migrationPath, "references")
+		if err := files.EnsureDir(outputDir); err != nil {
+			return err
+		}
+		outputPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s_references.csv", time.Now().Format("2006-01-02_15-04-05"), sourceOwner))
+		if err := files.CreateCSV(append([][]string{HEADER}, rows...), outputPath); err != nil {
+			return err
+		}
+		pterm.Warning.Printf("⚠️  %d files reference the source registries, listed in: %s\n", filesToUpdate, outputPath)
+	}
+
+	fmt.Println("\n📊 Summary:")
+	fmt.Printf("🔍 Repositories scanned: %d\n", scannedRepositories)
+	fmt.Printf("📄 Files scanned: %d\n", scannedFiles)
+	fmt.Printf("✏️  Files needing updates: %d\n", filesToUpdate)
+	fmt.Printf("🔗 References: %d\n", len(rows))
+	return nil
+}
@@ -0,0 +1,145 @@
+package references
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestIsScanned(t *testing.T) {
+	tests := map[string]bool{
+		".github/workflows/build.yml":          true,
+		".github/workflows/release.yaml":       true,
+		".github/workflows/scripts/build.sh":   false,
+		".github/dependabot.yml":               false,
+		"Dockerfile":                           true,
+		"services/api/Dockerfile.prod":         true,
+		"build/api.dockerfile":                 true,
+		"Containerfile":                        true,
+		"docs/dockerfiles.md":                  false,
+		"services/api/.github/workflows/x.yml": false,
+		".npmrc":                               true,
+		"web/.npmrc":                           true,
+		"services/api/pom.xml":                 true,
+		"services/api/pom.xml.bak":             false,
+	}
+	for filePath, want := range tests {
+		if got := isScanned(filePath); got != want {
+			t.Errorf("isScanned(%s) = %v, want %v", filePath, got, want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "Old-Org")
+
+	content := `FROM ghcr.io/old-org/base:1.2 AS build
+RUN npm install @old-org/ui "@Old-Org/tools@2"
+COPY --from=ghcr.io/old-org-tools/lint:1 /lint /lint
+# maintained by dev@old-org.example.com
+FROM docker.pkg.github.com/old-org/api/server:3.0
+RUN dotnet nuget add source https://nuget.pkg.github.com/old-org/index.json
+`
+	searched := patterns([]string{"container", "docker", "npm", "nuget"}, "Old-Org", "new-org")
+	got := scan(content, searched)
+	want := []match{
+		{line: 1, packageType: "container", reference: "ghcr.io/old-org/base:1.2", replacement: "ghcr.io/new-org/base:1.2"},
+		{line: 2, packageType: "npm", reference: "@old-org/ui", replacement: "@new-org/ui"},
+		{line: 2, packageType: "npm", reference: "@Old-Org/tools@2", replacement: "@new-org/tools@2"},
+		{line: 5, packageType: "docker", reference: "docker.pkg.github.com/old-org/api/server:3.0", replacement: "ghcr.io/new-org/server:3.0"},
+		// Registry URLs are matched from their host, the scheme stays in front
+		{line: 6, packageType: "nuget", reference: "nuget.pkg.github.com/old-org/index.json", replacement: "nuget.pkg.github.com/new-org/index.json"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scan() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Without a target organization references are listed without a replacement
+	for _, m := range scan(content, patterns([]string{"container"}, "Old-Org", "")) {
+		if m.replacement != "" {
+			t.Errorf("replacement %q without a target organization", m.replacement)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("GHMPKG_SOURCE_ORGANIZATION", "old-org")
+
+	content := "@old-org:registry=https://npm.pkg.github.com\r\n" +
+		"FROM ghcr.io/old-org/base:1.2 AS build\r\n" +
+		"FROM docker.pkg.github.com/old-org/api/server:3.0\r\n" +
+		"<url>https://maven.pkg.github.com/old-org/api</url>\r\n" +
+		"COPY --from=ghcr.io/old-org-tools/lint:1 /lint /lint\r\n"
+	searched := patterns([]string{"container", "docker", "npm", "maven"}, "old-org", "new-org")
+	got, matches := rewrite(content, searched)
+	want := "@new-org:registry=https://npm.pkg.github.com\r\n" +
+		"FROM ghcr.io/new-org/base:1.2 AS build\r\n" +
+		"FROM ghcr.io/new-org/server:3.0\r\n" +
+		"<url>https://maven.pkg.github.com/new-org/api</url>\r\n" +
+		"COPY --from=ghcr.io/old-org-tools/lint:1 /lint /lint\r\n"
+	if got != want {
+		t.Errorf("rewrite() =\n%q\nwant\n%q", got, want)
+	}
+	if len(matches) != 4 {
+		t.Errorf("rewrite() replaced %d references, want 4", len(matches))
+	}
+
+	// Without a target nothing is rewritten
+	if got, matches := rewrite(content, patterns([]string{"container"}, "old-org", "")); got != content || len(matches) != 0 {
+		t.Errorf("rewrite() without a target = %q, %d references", got, len(matches))
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nold 1\nc\nd\ne\nf\ng\nh\ni\nj\nk\nold 2"
+	after := "a\nb\nnew 1\nc\nd\ne\nf\ng\nh\ni\nj\nk\nnew 2"
+	want := `diff --git a/Dockerfile b/Dockerfile
+--- a/Dockerfile
++++ b/Dockerfile
+@@ -1,6 +1,6 @@
+ a
+ b
+-old 1
++new 1
+ c
+ d
+ e
+@@ -10,4 +10,4 @@
+ i
+ j
+ k
+-old 2
+\ No newline at end of file
++new 2
+\ No newline at end of file
+`
+	if got := unifiedDiff("Dockerfile", before, after); got != want {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Changes closer than twice the context share a hunk
+	before = "a\nold 1\nb\nc\nold 2\nd\n"
+	after = "a\nnew 1\nb\nc\nnew 2\nd\n"
+	want = `diff --git a/x.yml b/x.yml
+--- a/x.yml
++++ b/x.yml
+@@ -1,6 +1,6 @@
+ a
+-old 1
++new 1
+ b
+ c
+-old 2
++new 2
+ d
+`
+	if got := unifiedDiff("x.yml", before, after); got != want {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+	if got := unifiedDiff("x.yml", before, before); got != "" {
+		t.Errorf("unifiedDiff() of an unchanged file = %q", got)
+	}
+}
@@ -0,0 +1,268 @@
+package references
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// DefaultRewriteBranch is the branch pull requests are opened from
+const DefaultRewriteBranch = "migrate-packages/registry-references"
+
+// diffContext is the number of unchanged lines around the changes of a patch
+const diffContext = 3
+
+// rewrite replaces the references to the source registries in the content of a
+// file with their reference on the target, returning the rewritten content and
+// the references replaced. A reference matched by several patterns is replaced
+// once.
+func rewrite(content string, patterns []pattern) (string, []match) {
+	var matches []match
+	lines := strings.Split(content, "\n")
+	for number, line := range lines {
+		spans := lineReferences(line, patterns)
+		sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+		var rewritten strings.Builder
+		last := 0
+		for _, s := range spans {
+			if s.start < last || s.pattern.rewrite == nil {
+				continue
+			}
+			replacement := s.replacement(line)
+			if replacement == line[s.start:s.end] {
+				continue
+			}
+			matches = append(matches, match{line: number + 1, packageType: s.pattern.packageType, reference: line[s.start:s.end], replacement: replacement})
+			rewritten.WriteString(line[last:s.start])
+			rewritten.WriteString(replacement)
+			last = s.end
+		}
+		if last > 0 {
+			rewritten.WriteString(line[last:])
+			lines[number] = rewritten.String()
+		}
+	}
+	return strings.Join(lines, "\n"), matches
+}
+
+// unifiedDiff is the patch of a file rewritten line by line, for git apply.
+// Both contents have the same lines, references are replaced within a line.
+func unifiedDiff(filePath, before, after string) string {
+	oldLines := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	newLines := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+	noNewline := !strings.HasSuffix(before, "\n")
+	var changed []int
+	for i := range oldLines {
+		if oldLines[i] != newLines[i] {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", filePath, filePath, filePath, filePath)
+	writeLine := func(prefix string, i int, text string) {
+		diff.WriteString(prefix + text + "\n")
+		if noNewline && i == len(oldLines)-1 {
+			diff.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	for h := 0; h < len(changed); {
+		// Changes closer than twice the context share a hunk
+		last, next := changed[h], h+1
+		for next < len(changed) && changed[next]-last-1 <= 2*diffContext {
+			last = changed[next]
+			next++
+		}
+		start := max(changed[h]-diffContext, 0)
+		stop := min(last+diffContext+1, len(oldLines))
+		fmt.Fprintf(&diff, "@@ -%d,%d +%d,%d @@\n", start+1, stop-start, start+1, stop-start)
+		for i := start; i < stop; {
+			if oldLines[i] == newLines[i] {
+				writeLine(" ", i, oldLines[i])
+				i++
+				continue
+			}
+			end := i
+			for end < stop && oldLines[end] != newLines[end] {
+				end++
+			}
+			for j := i; j < end; j++ {
+				writeLine("-", j, oldLines[j])
+			}
+			for j := i; j < end; j++ {
+				writeLine("+", j, newLines[j])
+			}
+			i = end
+		}
+		h = next
+	}
+	return diff.String()
+}
+
+// RewriteReferences updates the workflows, Dockerfiles, .npmrc files and Maven
+// poms of the target repositories still referencing the source registries to
+// use the target registries. The changes are written as a patch per repository
+// to apply with git apply, or pushed to a branch of every repository with a pull
+// request opened when GHMPKG_OPEN_PULL_REQUESTS is set.
+func RewriteReferences(logger *zap.Logger) error {
+	sourceOwner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOwner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	openPullRequests := viper.GetBool("GHMPKG_OPEN_PULL_REQUESTS")
+	branch := viper.GetString("GHMPKG_REWRITE_BRANCH")
+	if branch == "" {
+		branch = DefaultRewriteBranch
+	}
+	migrationPath := utils.MigrationPath()
+	if targetOwner == "" {
+		return fmt.Errorf("a target organization is required to rewrite references")
+	}
+
+	pterm.Info.Println("Starting references rewrite...")
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		return err
+	}
+	searched := patterns(packageTypes, sourceOwner, targetOwner)
+	patchDir := filepath.Join(migrationPath, "rewrite", fmt.Sprintf("%s_%s", time.Now().Format("2006-01-02_15-04-05"), targetOwner))
+
+	spinner, _ := pterm.DefaultSpinner.Start("Listing target repositories...")
+	repositories, err := api.FetchTargetRepositories()
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to list repositories: %v", err))
+		return err
+	}
+	spinner.Success(fmt.Sprintf("Found %d repositories", len(repositories)))
+	desiredRepositories := common.DesiredRepositories()
+
+	scannedRepositories, rewrittenFiles, replaced, changes, failed := 0, 0, 0, 0, 0
+	for _, repository := range repositories {
+		name := repository.GetName()
+		if !common.MatchRepository(desiredRepositories, name) {
+			continue
+		}
+		if repository.GetArchived() {
+			logger.Info("Skipping archived repository", zap.String("repository", name))
+			continue
+		}
+		base := repository.GetDefaultBranch()
+		entries, truncated, err := api.FetchTargetTree(name, base)
+		if err != nil {
+			logger.Error("Failed to list repository files", zap.String("repository", name), zap.Error(err))
+			pterm.Error.Printf("❌ Failed to list the files of %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		if truncated {
+			pterm.Warning.Printf("⚠️  %s has too many files to list them all, some files were not scanned\n", name)
+		}
+		scannedRepositories++
+
+		var patch strings.Builder
+		var updated []*github.TreeEntry
+		var summary []string
+		unreadable, repositoryReplaced := 0, 0
+		for _, entry := range entries {
+			if entry.GetType() != "blob" || !isScanned(entry.GetPath()) {
+				continue
+			}
+			if entry.GetSize() > maxFileSize {
+				logger.Warn("Skipping large file", zap.String("repository", name), zap.String("path", entry.GetPath()), zap.Int("size", entry.GetSize()))
+				continue
+			}
+			content, err := api.FetchTargetBlob(name, entry.GetSHA())
+			if err != nil {
+				logger.Error("Failed to read file", zap.String("repository", name), zap.String("path", entry.GetPath()), zap.Error(err))
+				pterm.Error.Printf("❌ Failed to read %s/%s: %v\n", name, entry.GetPath(), err)
+				unreadable++
+				continue
+			}
+
+			rewritten, matches := rewrite(string(content), searched)
+			if len(matches) == 0 {
+				continue
+			}
+			repositoryReplaced += len(matches)
+			for _, m := range matches {
+				logger.Info("Rewriting reference",
+					zap.String("repository", name),
+					zap.String("path", entry.GetPath()),
+					zap.Int("line", m.line),
+					zap.String("reference", m.reference),
+					zap.String("replacement", m.replacement))
+			}
+			patch.WriteString(unifiedDiff(entry.GetPath(), string(content), rewritten))
+			updated = append(updated, &github.TreeEntry{
+				Path:    entry.Path,
+				Mode:    entry.Mode,
+				Type:    github.String("blob"),
+				Content: github.String(rewritten),
+			})
+			summary = append(summary, fmt.Sprintf("- `%s`: %d references", entry.GetPath(), len(matches)))
+		}
+		if unreadable > 0 {
+			// A partial rewrite would leave references behind in the files that
+			// could not be read, the repository is left for the next run
+			pterm.Error.Printf("❌ %s was not rewritten, %d files could not be read\n", name, unreadable)
+			failed++
+			continue
+		}
+		if len(updated) == 0 {
+			continue
+		}
+		rewrittenFiles += len(updated)
+		replaced += repositoryReplaced
+
+		if openPullRequests {
+			title := fmt.Sprintf("Use the %s package registries", targetOwner)
+			body := fmt.Sprintf("The packages of %s were migrated to %s. This updates the references to the %s registries:\n\n%s\n",
+				sourceOwner, targetOwner, sourceOwner, strings.Join(summary, "\n"))
+			url, err := api.CreateTargetPullRequest(name, base, branch, title, body, updated)
+			if err != nil {
+				logger.Error("Failed to open pull request", zap.String("repository", name), zap.Error(err))
+				pterm.Error.Printf("❌ Failed to open a pull request on %s: %v\n", name, err)
+				failed++
+				continue
+			}
+			logger.Info("Opened pull request", zap.String("repository", name), zap.String("url", url))
+			pterm.Success.Printf("🔀 %s: %s\n", name, url)
+		} else {
+			patchPath := filepath.Join(patchDir, name+".patch")
+			if err := utils.WriteFileAtomic(patchPath, []byte(patch.String()), 0644); err != nil {
+				return err
+			}
+			pterm.Success.Printf("📝 %s: %s\n", name, patchPath)
+		}
+		changes++
+	}
+
+	fmt.Println("\n📊 Summary:")
+	fmt.Printf("🔍 Repositories scanned: %d\n", scannedRepositories)
+	fmt.Printf("✏️  Files rewritten: %d\n", rewrittenFiles)
+	fmt.Printf("🔗 References replaced: %d\n", replaced)
+	if openPullRequests {
+		fmt.Printf("🔀 Pull requests: %d\n", changes)
+	} else {
+		fmt.Printf("📝 Patches: %d\n", changes)
+		if changes > 0 {
+			fmt.Printf("📁 Output directory: %s (apply with git apply)\n", patchDir)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d repositories could not be rewritten", failed)
+	}
+	return nil
+}
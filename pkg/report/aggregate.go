@@ -0,0 +1,444 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"go.uber.org/zap"
+)
+
+// Source is a report the aggregate was built from: a --report-json report, or
+// a phase of a migrate report
+type Source struct {
+	Path         string    `json:"path"`
+	Command      string    `json:"command"`
+	Organization string    `json:"organization"`
+	RunID        string    `json:"run_id,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	Error        string    `json:"error,omitempty"`
+	Items        int       `json:"items"`
+	// Filters are the settings selecting the packages of the run, its shard boundary
+	Filters map[string]string `json:"filters,omitempty"`
+}
+
+// SHARD_SETTINGS are the settings sharded runs split the packages with
+var SHARD_SETTINGS = []string{"GHMPKG_SHARD", "GHMPKG_PACKAGE_TYPES", "GHMPKG_REPOSITORY", "GHMPKG_PACKAGES", "GHMPKG_INCLUDE", "GHMPKG_EXCLUDE"}
+
+// Totals counts the distinct items a command processed across the reports,
+// each with the state of the most recent report processing it
+type Totals struct {
+	Command     string                       `json:"command"`
+	Reports     int                          `json:"reports"`
+	Success     int                          `json:"success"`
+	Skipped     int                          `json:"skipped"`
+	Failed      int                          `json:"failed"`
+	SkipReasons map[providers.SkipReason]int `json:"skip_reasons,omitempty"`
+}
+
+// Failure is an item whose most recent result is Failed
+type Failure struct {
+	Command string      `json:"command"`
+	Item    common.Item `json:"item"`
+	// Report is the report the failure was last recorded in
+	Report string `json:"report"`
+}
+
+// Duplicate is an item more than one report processed successfully, work done
+// twice by overlapping shards
+type Duplicate struct {
+	Command string      `json:"command"`
+	Item    common.Item `json:"item"`
+	Reports []string    `json:"reports"`
+}
+
+// Overlap is the work two reports both completed, the packages the boundaries
+// of their shards have in common
+type Overlap struct {
+	Command string `json:"command"`
+	// Reports are the two reports, the one that finished last second
+	Reports  []string `json:"reports"`
+	Items    int      `json:"items"`
+	Packages []string `json:"packages"`
+	// Suggestion is the --exclude that keeps the shard of the second report
+	// off the packages of the first one
+	Suggestion string `json:"suggestion"`
+}
+
+// Aggregate is the consolidated view of the reports of sharded runs
+type Aggregate struct {
+	Reports    []Source    `json:"reports"`
+	Totals     []*Totals   `json:"totals"`
+	Failures   []Failure   `json:"failures"`
+	Duplicates []Duplicate `json:"duplicates"`
+	Overlaps   []*Overlap  `json:"overlaps"`
+}
+
+// MaxListed is the most failures and duplicates listed in the console summary
+const MaxListed = 20
+
+// document is a report read from disk with the source describing it
+type document struct {
+	source Source
+	items  []common.Item
+}
+
+// itemKey identifies an item across reports
+func itemKey(command string, item common.Item) string {
+	return strings.Join([]string{command, item.Organization, item.Repository, item.PackageType, item.PackageName, item.Version, item.Filename}, "\x00")
+}
+
+// readDocuments reads the reports of a file: a --report-json report, or every
+// phase of a migrate report. Other JSON files are ignored.
+func readDocuments(logger *zap.Logger, path string) ([]document, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var combined struct {
+		Phases []struct {
+			Phase    string                 `json:"phase"`
+			Document *common.ReportDocument `json:"document"`
+		} `json:"phases"`
+		common.ReportDocument
+	}
+	if err := json.Unmarshal(content, &combined); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+
+	var reports []*common.ReportDocument
+	var names []string
+	if combined.Phases != nil {
+		for _, phase := range combined.Phases {
+			if phase.Document != nil {
+				reports = append(reports, phase.Document)
+				names = append(names, fmt.Sprintf("%s#%s", path, phase.Phase))
+			}
+		}
+	} else if combined.Command != "" && combined.Report != nil {
+		reports = append(reports, &combined.ReportDocument)
+		names = append(names, path)
+	} else {
+		logger.Warn("Not a report, ignoring it", zap.String("path", path))
+		return nil, nil
+	}
+
+	var documents []document
+	for i, report := range reports {
+		source := Source{
+			Path:         names[i],
+			Command:      report.Command,
+			Organization: report.Organization,
+			StartedAt:    report.StartedAt,
+			FinishedAt:   report.FinishedAt,
+			Error:        report.Error,
+			Items:        len(report.Items),
+		}
+		if report.Run != nil {
+			source.RunID = report.Run.ID
+			for _, key := range SHARD_SETTINGS {
+				if value := report.Run.Config[key]; value != "" {
+					if source.Filters == nil {
+						source.Filters = make(map[string]string)
+					}
+					source.Filters[key] = value
+				}
+			}
+		}
+		documents = append(documents, document{source: source, items: report.Items})
+	}
+	return documents, nil
+}
+
+// reportFiles expands the paths given to aggregate, directories to the JSON
+// files they hold
+func reportFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(filePath, ".json") {
+				files = append(files, filePath)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Build aggregates the reports of the given files and directories. Items
+// processed by several reports take the state of the one that finished last,
+// so a shard retrying the failures of another one clears them, unless it
+// only skipped an item an earlier report completed.
+func Build(logger *zap.Logger, paths []string) (*Aggregate, error) {
+	files, err := reportFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	var documents []document
+	seen := make(map[string]bool)
+	for _, file := range files {
+		found, err := readDocuments(logger, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range found {
+			// migrate writes the report of every phase next to its own, holding them too
+			id := strings.Join([]string{doc.source.Command, doc.source.RunID, doc.source.StartedAt.String()}, "\x00")
+			if seen[id] {
+				logger.Info("Report already aggregated, ignoring it", zap.String("path", doc.source.Path))
+				continue
+			}
+			seen[id] = true
+			documents = append(documents, doc)
+		}
+	}
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("no report found in %s", strings.Join(paths, ", "))
+	}
+	sort.SliceStable(documents, func(i, j int) bool {
+		return documents[i].source.FinishedAt.Before(documents[j].source.FinishedAt)
+	})
+
+	type result struct {
+		command string
+		item    common.Item
+		report  string
+		// succeeded lists the reports the item succeeded in
+		succeeded []string
+	}
+	results := make(map[string]*result)
+	var keys []string
+	aggregate := &Aggregate{}
+	totals := make(map[string]*Totals)
+	for _, doc := range documents {
+		aggregate.Reports = append(aggregate.Reports, doc.source)
+		if totals[doc.source.Command] == nil {
+			totals[doc.source.Command] = &Totals{Command: doc.source.Command, SkipReasons: make(map[providers.SkipReason]int)}
+		}
+		totals[doc.source.Command].Reports++
+		for _, item := range doc.items {
+			key := itemKey(doc.source.Command, item)
+			current, ok := results[key]
+			if !ok {
+				current = &result{command: doc.source.Command}
+				results[key] = current
+				keys = append(keys, key)
+			}
+			if item.State == providers.Success {
+				current.succeeded = append(current.succeeded, doc.source.Path)
+			}
+			// A later run skipping what an earlier one completed does not undo it
+			if ok && item.State == providers.Skipped && current.item.State == providers.Success {
+				continue
+			}
+			current.item, current.report = item, doc.source.Path
+		}
+	}
+
+	for _, key := range keys {
+		current := results[key]
+		total := totals[current.command]
+		switch current.item.State {
+		case providers.Success:
+			total.Success++
+		case providers.Skipped:
+			total.Skipped++
+			if current.item.SkipReason != "" {
+				total.SkipReasons[current.item.SkipReason]++
+			}
+		case providers.Failed:
+			total.Failed++
+			aggregate.Failures = append(aggregate.Failures, Failure{Command: current.command, Item: current.item, Report: current.report})
+		}
+		if len(current.succeeded) > 1 {
+			aggregate.Duplicates = append(aggregate.Duplicates, Duplicate{Command: current.command, Item: current.item, Reports: current.succeeded})
+		}
+	}
+	aggregate.Overlaps = overlaps(aggregate.Reports, aggregate.Duplicates)
+	for _, overlap := range aggregate.Overlaps {
+		logger.Warn("Shards did the same work",
+			zap.String("command", overlap.Command),
+			zap.Strings("reports", overlap.Reports),
+			zap.Int("items", overlap.Items),
+			zap.String("suggestion", overlap.Suggestion))
+	}
+	for _, command := range []string{"export", "pull", "sync"} {
+		if total, ok := totals[command]; ok {
+			aggregate.Totals = append(aggregate.Totals, total)
+			delete(totals, command)
+		}
+	}
+	var others []string
+	for command := range totals {
+		others = append(others, command)
+	}
+	sort.Strings(others)
+	for _, command := range others {
+		aggregate.Totals = append(aggregate.Totals, totals[command])
+	}
+	return aggregate, nil
+}
+
+// overlaps groups the duplicate work by the pair of reports that did it. The
+// reports of a duplicate are in the order they finished, the shard finishing
+// last is the one told to exclude the shared packages.
+func overlaps(sources []Source, duplicates []Duplicate) []*Overlap {
+	filters := make(map[string]map[string]string)
+	for _, source := range sources {
+		filters[source.Path] = source.Filters
+	}
+	found := make(map[string]*Overlap)
+	var result []*Overlap
+	names := make(map[*Overlap][]string)
+	for _, duplicate := range duplicates {
+		for i, first := range duplicate.Reports {
+			for _, second := range duplicate.Reports[i+1:] {
+				key := strings.Join([]string{duplicate.Command, first, second}, "\x00")
+				overlap, ok := found[key]
+				if !ok {
+					overlap = &Overlap{Command: duplicate.Command, Reports: []string{first, second}}
+					found[key] = overlap
+					result = append(result, overlap)
+				}
+				overlap.Items++
+				if name := duplicate.Item.PackageType + "/" + duplicate.Item.PackageName; !utils.Contains(overlap.Packages, name) {
+					overlap.Packages = append(overlap.Packages, name)
+				}
+				if !utils.Contains(names[overlap], duplicate.Item.PackageName) {
+					names[overlap] = append(names[overlap], duplicate.Item.PackageName)
+				}
+			}
+		}
+	}
+	for _, overlap := range result {
+		sort.Strings(overlap.Packages)
+		exclude := names[overlap]
+		sort.Strings(exclude)
+		if existing := filters[overlap.Reports[1]]["GHMPKG_EXCLUDE"]; existing != "" {
+			exclude = append([]string{existing}, exclude...)
+		}
+		overlap.Suggestion = "--exclude " + strings.Join(exclude, ",")
+	}
+	return result
+}
+
+// describeFilters describes the shard boundary of a report for the summary
+func describeFilters(filters map[string]string) string {
+	if len(filters) == 0 {
+		return "every package"
+	}
+	var parts []string
+	for _, key := range SHARD_SETTINGS {
+		if value, ok := filters[key]; ok {
+			parts = append(parts, strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, "GHMPKG_"), "_", "-"))+" "+value)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// itemName describes an item in the summary
+func itemName(item common.Item) string {
+	parts := []string{item.PackageType, item.PackageName}
+	for _, part := range []string{item.Version, item.Filename} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Print writes the consolidated summary to the console
+func (a *Aggregate) Print() {
+	fmt.Printf("\n📊 Aggregated Summary of %d reports:\n", len(a.Reports))
+	for _, source := range a.Reports {
+		status := "✅"
+		if source.Error != "" {
+			status = "❌"
+		}
+		fmt.Printf("  %s %s %s (%d items, finished %s)\n", status, source.Command, source.Path, source.Items, source.FinishedAt.Format(time.RFC3339))
+	}
+	for _, total := range a.Totals {
+		fmt.Printf("📦 %s: %d reports, %d succeeded, %d skipped, %d failed\n", total.Command, total.Reports, total.Success, total.Skipped, total.Failed)
+		var reasons []string
+		for reason := range total.SkipReasons {
+			reasons = append(reasons, string(reason))
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Printf("  ⏭️  %s: %d\n", reason, total.SkipReasons[providers.SkipReason(reason)])
+		}
+	}
+	if len(a.Failures) > 0 {
+		fmt.Printf("❌ Failed: %d items\n", len(a.Failures))
+		for i, failure := range a.Failures {
+			if i == MaxListed {
+				fmt.Printf("  ... and %d more, write the aggregate with --output to list them all\n", len(a.Failures)-MaxListed)
+				break
+			}
+			fmt.Printf("  %s %s: %s (%s)\n", failure.Command, itemName(failure.Item), failure.Item.Error, failure.Report)
+		}
+	}
+	if len(a.Duplicates) > 0 {
+		fmt.Printf("🔁 Duplicate work: %d items processed by more than one report, check the shards do not overlap\n", len(a.Duplicates))
+		for i, duplicate := range a.Duplicates {
+			if i == MaxListed {
+				fmt.Printf("  ... and %d more, write the aggregate with --output to list them all\n", len(a.Duplicates)-MaxListed)
+				break
+			}
+			fmt.Printf("  %s %s: %s\n", duplicate.Command, itemName(duplicate.Item), strings.Join(duplicate.Reports, ", "))
+		}
+	}
+	if len(a.Overlaps) > 0 {
+		filters := make(map[string]map[string]string)
+		for _, source := range a.Reports {
+			filters[source.Path] = source.Filters
+		}
+		fmt.Printf("🧩 Overlapping shards: %d pairs of reports did the same work\n", len(a.Overlaps))
+		for _, overlap := range a.Overlaps {
+			first, second := overlap.Reports[0], overlap.Reports[1]
+			fmt.Printf("  %s %s (%s) and %s (%s): %d items of %d packages\n", overlap.Command, first, describeFilters(filters[first]), second, describeFilters(filters[second]), overlap.Items, len(overlap.Packages))
+			packages := overlap.Packages
+			if len(packages) > MaxListed {
+				packages = append(packages[:MaxListed:MaxListed], fmt.Sprintf("and %d more", len(overlap.Packages)-MaxListed))
+			}
+			fmt.Printf("    %s\n", strings.Join(packages, ", "))
+			fmt.Printf("    💡 Run the shard of %s with %s\n", second, overlap.Suggestion)
+		}
+	}
+	fmt.Println()
+}
+
+// Write writes the aggregate as indented JSON
+func (a *Aggregate) Write(path string) error {
+	content, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal aggregate: %w", err)
+	}
+	if err := utils.WriteFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write aggregate: %w", err)
+	}
+	fmt.Printf("📄 JSON report: %s\n", path)
+	return nil
+}
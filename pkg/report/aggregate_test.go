@@ -0,0 +1,100 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"go.uber.org/zap"
+)
+
+func writeReport(t *testing.T, path string, document any) {
+	t.Helper()
+	content, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2025, 1, 11, 12, 0, 0, 0, time.UTC)
+	item := func(filename string, state providers.ResultState, reason providers.SkipReason) common.Item {
+		return common.Item{Organization: "mona", Repository: "repo", PackageType: "npm", PackageName: "web", Version: "1.0.0", Filename: filename, State: state, SkipReason: reason}
+	}
+	shard := func(id string, finished time.Time, items ...common.Item) *common.ReportDocument {
+		return &common.ReportDocument{Command: "sync", Organization: "mona", StartedAt: start, FinishedAt: finished, Run: &run.Metadata{ID: id}, Report: common.NewReport(), Items: items}
+	}
+
+	shard1 := shard("shard-1", start.Add(time.Hour),
+		item("a.tgz", providers.Success, ""),
+		item("b.tgz", providers.Failed, ""),
+		item("c.tgz", providers.Failed, ""))
+	shard1.Run.Config = map[string]string{"GHMPKG_INCLUDE": "a*,b*,c*", "GHMPKG_TARGET_TOKEN": "***"}
+	shard2 := shard("shard-2", start.Add(2*time.Hour),
+		item("a.tgz", providers.Success, ""),
+		item("b.tgz", providers.Success, ""),
+		item("d.tgz", providers.Skipped, providers.SkipExistsOnTarget))
+	retry := shard("retry", start.Add(3*time.Hour),
+		item("a.tgz", providers.Skipped, providers.SkipExistsOnTarget))
+	shard2.Run.Config = map[string]string{"GHMPKG_EXCLUDE": "legacy-*"}
+	writeReport(t, filepath.Join(dir, "shard-1.json"), shard1)
+	writeReport(t, filepath.Join(dir, "nested", "shard-2.json"), shard2)
+	// migrate holds the reports of its phases, also written on their own
+	writeReport(t, filepath.Join(dir, "migrate.json"), map[string]any{
+		"command": "migrate",
+		"phases":  []map[string]any{{"phase": "sync", "document": retry}},
+	})
+	writeReport(t, filepath.Join(dir, "retry.json"), retry)
+	writeReport(t, filepath.Join(dir, "layout.json"), map[string]any{"version": 1})
+
+	aggregate, err := Build(zap.NewNop(), []string{dir})
+	if err != nil {
+		t.Fatalf("Build returned an error: %v", err)
+	}
+	if len(aggregate.Reports) != 3 {
+		t.Errorf("aggregated %d reports, want 3: %+v", len(aggregate.Reports), aggregate.Reports)
+	}
+	if len(aggregate.Totals) != 1 {
+		t.Fatalf("totals = %+v, want sync only", aggregate.Totals)
+	}
+	total := aggregate.Totals[0]
+	if total.Reports != 3 || total.Success != 2 || total.Skipped != 1 || total.Failed != 1 || total.SkipReasons[providers.SkipExistsOnTarget] != 1 {
+		t.Errorf("sync totals = %+v, want 2 succeeded (a, b), 1 skipped (d), 1 failed (c)", total)
+	}
+	if len(aggregate.Failures) != 1 || aggregate.Failures[0].Item.Filename != "c.tgz" {
+		t.Errorf("failures = %+v, want c.tgz", aggregate.Failures)
+	}
+	if len(aggregate.Duplicates) != 1 || aggregate.Duplicates[0].Item.Filename != "a.tgz" || len(aggregate.Duplicates[0].Reports) != 2 {
+		t.Errorf("duplicates = %+v, want a.tgz synced by both shards", aggregate.Duplicates)
+	}
+
+	if len(aggregate.Overlaps) != 1 {
+		t.Fatalf("overlaps = %+v, want shard-1 and shard-2", aggregate.Overlaps)
+	}
+	overlap := aggregate.Overlaps[0]
+	if overlap.Items != 1 || len(overlap.Packages) != 1 || overlap.Packages[0] != "npm/web" || overlap.Reports[1] != filepath.Join(dir, "nested", "shard-2.json") {
+		t.Errorf("overlap = %+v, want a.tgz of npm/web, shard-2 finishing last", overlap)
+	}
+	if overlap.Suggestion != "--exclude legacy-*,web" {
+		t.Errorf("suggestion = %q, want shard-2 to exclude web on top of its own exclusions", overlap.Suggestion)
+	}
+	if filters := aggregate.Reports[0].Filters; len(filters) != 1 || filters["GHMPKG_INCLUDE"] != "a*,b*,c*" {
+		t.Errorf("filters of shard-1 = %v, want its include patterns only", filters)
+	}
+
+	if _, err := Build(zap.NewNop(), []string{filepath.Join(dir, "layout.json")}); err == nil {
+		t.Error("Build accepted a directory without reports")
+	}
+}
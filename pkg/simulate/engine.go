@@ -0,0 +1,314 @@
+package simulate
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/state"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+)
+
+// Phases that can be simulated, in the order migrate runs them
+var PHASES = []string{"pull", "sync"}
+
+// Settings are the knobs of a simulated run
+type Settings struct {
+	Concurrency int
+	// Warmup paces the first versions of the sync phase, nil when disabled
+	Warmup *common.WarmupProfile
+	// FileTimes is the time to transfer a file by package type, "" for the other types
+	FileTimes map[string]time.Duration
+	// APILatency is added for every API or registry request
+	APILatency time.Duration
+	// VerifyUploads reads every uploaded file back from the target
+	VerifyUploads bool
+	// CheckExisting asks the target whether each package exists before syncing it
+	CheckExisting bool
+	Shards        int
+	// SplitTags is the number of tags above which the versions of a container
+	// package are dealt to the shards one at a time
+	SplitTags int
+	Bucket    time.Duration
+}
+
+// fileTime is the time to transfer a single file of a package type
+func (s Settings) fileTime(packageType string) time.Duration {
+	if fileTime, ok := s.FileTimes[packageType]; ok {
+		return fileTime
+	}
+	return s.FileTimes[""]
+}
+
+// Duration is a time.Duration written to JSON as text, e.g. 1h30m0s
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Calls counts the requests of a run: REST and GraphQL requests, which count
+// against the API rate limit, and package registry requests
+type Calls struct {
+	REST     int `json:"rest"`
+	Registry int `json:"registry"`
+}
+
+func (c *Calls) add(other Calls) {
+	c.REST += other.REST
+	c.Registry += other.Registry
+}
+
+// Bucket is what a run starts in a window of the timeline
+type Bucket struct {
+	Start    Duration `json:"start"`
+	Versions int      `json:"versions"`
+	Files    int      `json:"files"`
+	Calls
+}
+
+// Projection is the projected run of a phase
+type Projection struct {
+	Phase    string   `json:"phase"`
+	Packages int      `json:"packages"`
+	Versions int      `json:"versions"`
+	Files    int      `json:"files"`
+	Start    Duration `json:"start"`
+	Duration Duration `json:"duration"`
+	Calls
+}
+
+// Plan is the projected migration of an inventory
+type Plan struct {
+	Concurrency int          `json:"concurrency"`
+	Shards      int          `json:"shards"`
+	Duration    Duration     `json:"duration"`
+	Phases      []Projection `json:"phases"`
+	// ShardDurations is how long each shard runs, every phase included
+	ShardDurations []Duration `json:"shard_durations"`
+	Timeline       []Bucket   `json:"timeline"`
+	// PeakRESTPerHour is the highest rate of REST and GraphQL requests over the timeline
+	PeakRESTPerHour int `json:"peak_rest_per_hour"`
+}
+
+// simVersion is a version to transfer, with its requests and the time they take
+type simVersion struct {
+	files    int
+	calls    Calls
+	duration time.Duration
+}
+
+// simPackage is a package whose versions are processed one after the other
+type simPackage struct {
+	versions []simVersion
+}
+
+// buildPackages turns the inventory rows into the work of a phase. Versions are
+// processed from the last row to the first, like ProcessPackages does.
+func buildPackages(rows [][]string, phase string, settings Settings) []simPackage {
+	var order []string
+	versions := make(map[string][]string)
+	files := make(map[string]int)
+	packageTypes := make(map[string]string)
+	for _, row := range rows {
+		if len(row) < 6 {
+			continue
+		}
+		packageKey := state.PackageKey(row[0], row[1], row[2], row[3])
+		versionKey := packageKey + "|" + row[4]
+		if _, ok := versions[packageKey]; !ok {
+			order = append(order, packageKey)
+			packageTypes[packageKey] = row[2]
+		}
+		if _, ok := files[versionKey]; !ok {
+			versions[packageKey] = append(versions[packageKey], row[4])
+		}
+		files[versionKey]++
+	}
+
+	var packages []simPackage
+	for _, packageKey := range order {
+		fileTime := settings.fileTime(packageTypes[packageKey])
+		var pkg simPackage
+		names := versions[packageKey]
+		for i := len(names) - 1; i >= 0; i-- {
+			version := simVersion{files: files[packageKey+"|"+names[i]]}
+			switch phase {
+			case "pull":
+				version.calls.Registry = version.files
+			case "sync":
+				version.calls.Registry = version.files
+				if settings.VerifyUploads {
+					version.calls.Registry += version.files
+				}
+				// The existence check happens before the first version
+				if settings.CheckExisting && len(pkg.versions) == 0 {
+					version.calls.REST++
+				}
+			}
+			version.duration = time.Duration(version.files)*fileTime + time.Duration(version.calls.REST+version.calls.Registry)*settings.APILatency
+			pkg.versions = append(pkg.versions, version)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages
+}
+
+// slot is a package being processed by one of the concurrent workers
+type slot struct {
+	pkg     *simPackage
+	next    int
+	running bool
+	until   time.Duration
+}
+
+// run plays the packages through the workers, starting at offset, and returns
+// when the last version finishes. A version starts when a worker has it and
+// the warm-up, if any, lets another operation start.
+func run(packages []simPackage, concurrency int, warmup *common.WarmupProfile, offset time.Duration, start func(at time.Duration, version simVersion)) time.Duration {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limits := func(started int) (int, time.Duration) {
+		if warmup == nil {
+			return concurrency, 0
+		}
+		return warmup.Allowed(started), warmup.Pacing(started)
+	}
+
+	slots := make([]slot, concurrency)
+	now := offset
+	queued, started, active := 0, 0, 0
+	var lastStart time.Duration
+	for {
+		for i := range slots {
+			if slots[i].pkg == nil && queued < len(packages) {
+				slots[i] = slot{pkg: &packages[queued]}
+				queued++
+			}
+		}
+
+		// Start every version the workers and the warm-up allow
+		allowed, pacing := limits(started)
+		for i := range slots {
+			s := &slots[i]
+			if s.pkg == nil || s.running || active >= allowed || (started > 0 && now < lastStart+pacing) {
+				continue
+			}
+			version := s.pkg.versions[s.next]
+			start(now, version)
+			s.running, s.until = true, now+version.duration
+			started++
+			active++
+			lastStart = now
+			allowed, pacing = limits(started)
+		}
+
+		next := time.Duration(-1)
+		waiting := false
+		for i := range slots {
+			if slots[i].running && (next < 0 || slots[i].until < next) {
+				next = slots[i].until
+			}
+			waiting = waiting || (slots[i].pkg != nil && !slots[i].running)
+		}
+		if waiting && active < allowed && (next < 0 || lastStart+pacing < next) {
+			next = lastStart + pacing
+		}
+		if next < 0 {
+			return now
+		}
+		now = next
+
+		for i := range slots {
+			s := &slots[i]
+			if !s.running || s.until > now {
+				continue
+			}
+			s.running = false
+			active--
+			if s.next++; s.next == len(s.pkg.versions) {
+				s.pkg = nil
+			}
+		}
+	}
+}
+
+// Project simulates the phases of a migration of the inventory rows, without
+// any network access. Packages are dealt round robin to the shards like
+// common.AssignShards does, and the shards run at the same time, each running
+// the phases one after the other.
+func Project(rows [][]string, phases []string, settings Settings) Plan {
+	shards := settings.Shards
+	if shards < 1 {
+		shards = 1
+	}
+	bucketSize := settings.Bucket
+	if bucketSize <= 0 {
+		bucketSize = time.Hour
+	}
+	plan := Plan{Concurrency: settings.Concurrency, Shards: shards}
+
+	// Deal the rows like sharded pull and sync runs, keeping the order of the inventory
+	shardRows := make([][][]string, shards)
+	for i, shard := range common.AssignShards(rows, shards, settings.SplitTags) {
+		if len(rows[i]) < 6 {
+			continue
+		}
+		shardRows[shard] = append(shardRows[shard], rows[i])
+	}
+
+	buckets := make(map[int]*Bucket)
+	record := func(at time.Duration, version simVersion) {
+		index := int(at / bucketSize)
+		if buckets[index] == nil {
+			buckets[index] = &Bucket{Start: Duration(time.Duration(index) * bucketSize)}
+		}
+		buckets[index].Versions++
+		buckets[index].Files += version.files
+		buckets[index].Calls.add(version.calls)
+	}
+
+	ends := make([]time.Duration, shards)
+	for _, phase := range phases {
+		projection := Projection{Phase: phase, Start: -1}
+		var end time.Duration
+		for shard := range shardRows {
+			packages := buildPackages(shardRows[shard], phase, settings)
+			var warmup *common.WarmupProfile
+			if phase == "sync" {
+				warmup = settings.Warmup
+			}
+			if start := Duration(ends[shard]); projection.Start < 0 || start < projection.Start {
+				projection.Start = start
+			}
+			ends[shard] = run(packages, settings.Concurrency, warmup, ends[shard], func(at time.Duration, version simVersion) {
+				record(at, version)
+				projection.Versions++
+				projection.Files += version.files
+				projection.Calls.add(version.calls)
+			})
+			projection.Packages += len(packages)
+			end = max(end, ends[shard])
+		}
+		projection.Duration = Duration(end - time.Duration(projection.Start))
+		plan.Phases = append(plan.Phases, projection)
+	}
+
+	for _, end := range ends {
+		plan.ShardDurations = append(plan.ShardDurations, Duration(end))
+		plan.Duration = max(plan.Duration, Duration(end))
+	}
+	last := -1
+	for index := range buckets {
+		last = max(last, index)
+	}
+	for index := 0; index <= last; index++ {
+		bucket := Bucket{Start: Duration(time.Duration(index) * bucketSize)}
+		if buckets[index] != nil {
+			bucket = *buckets[index]
+		}
+		plan.Timeline = append(plan.Timeline, bucket)
+		plan.PeakRESTPerHour = max(plan.PeakRESTPerHour, int(float64(bucket.REST)*float64(time.Hour)/float64(bucketSize)))
+	}
+	return plan
+}
@@ -0,0 +1,320 @@
+package simulate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Defaults of the simulation, images are pulled and pushed layer by layer
+const (
+	DefaultFileTime   = 2 * time.Second
+	DefaultImageTime  = 20 * time.Second
+	DefaultAPILatency = 250 * time.Millisecond
+)
+
+// RESTRequestsPerHour is the primary rate limit of a GitHub token
+const RESTRequestsPerHour = 5000
+
+// MaxSuggestedShards is the most shards tried when the migration does not fit in --window
+const MaxSuggestedShards = 32
+
+// ParseFileTimes reads GHMPKG_SIMULATE_FILE_TIME, type=duration entries or a
+// bare duration for every package type
+func ParseFileTimes() (map[string]time.Duration, error) {
+	fileTimes := map[string]time.Duration{"": DefaultFileTime, "container": DefaultImageTime, "docker": DefaultImageTime}
+	for _, value := range viper.GetStringSlice("GHMPKG_SIMULATE_FILE_TIME") {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			packageType, text, ok := strings.Cut(entry, "=")
+			if !ok {
+				packageType, text = "", entry
+			}
+			fileTime, err := time.ParseDuration(strings.TrimSpace(text))
+			if err != nil || fileTime < 0 {
+				return nil, fmt.Errorf("invalid file time %q, expected type=duration such as container=30s", entry)
+			}
+			if packageType = strings.TrimSpace(packageType); packageType == "" {
+				for key := range fileTimes {
+					fileTimes[key] = fileTime
+				}
+				continue
+			}
+			if packageType, err = common.ResolvePackageType(packageType); err != nil {
+				return nil, err
+			}
+			fileTimes[packageType] = fileTime
+		}
+	}
+	return fileTimes, nil
+}
+
+// readSettings reads the settings of the simulation, the same ones pull and sync use
+func readSettings() (Settings, error) {
+	settings := Settings{
+		Concurrency:   max(viper.GetInt("GHMPKG_CONCURRENCY"), 1),
+		APILatency:    DefaultAPILatency,
+		VerifyUploads: viper.GetBool("GHMPKG_VERIFY_UPLOADS"),
+		CheckExisting: !providers.ExternalTarget(),
+		Shards:        max(viper.GetInt("GHMPKG_SIMULATE_SHARDS"), 1),
+		Bucket:        time.Hour,
+	}
+	var err error
+	if settings.SplitTags, err = common.ShardSplitTags(); err != nil {
+		return settings, err
+	}
+	if settings.Warmup, err = common.ReadWarmupProfile(settings.Concurrency); err != nil {
+		return settings, err
+	}
+	if settings.FileTimes, err = ParseFileTimes(); err != nil {
+		return settings, err
+	}
+	if value := viper.GetString("GHMPKG_SIMULATE_API_LATENCY"); value != "" {
+		if settings.APILatency, err = time.ParseDuration(value); err != nil || settings.APILatency < 0 {
+			return settings, fmt.Errorf("invalid --api-latency %q, expected a duration such as 250ms", value)
+		}
+	}
+	if value := viper.GetString("GHMPKG_SIMULATE_BUCKET"); value != "" {
+		if settings.Bucket, err = time.ParseDuration(value); err != nil || settings.Bucket <= 0 {
+			return settings, fmt.Errorf("invalid --bucket %q, expected a duration such as 1h", value)
+		}
+	}
+	return settings, nil
+}
+
+// readPhases reads GHMPKG_SIMULATE_PHASES, pull and sync by default
+func readPhases() ([]string, error) {
+	var phases []string
+	for _, value := range viper.GetStringSlice("GHMPKG_SIMULATE_PHASES") {
+		for _, phase := range strings.Split(value, ",") {
+			phase = strings.ToLower(strings.TrimSpace(phase))
+			if phase == "" {
+				continue
+			}
+			if !utils.Contains(PHASES, phase) {
+				return nil, fmt.Errorf("unsupported phase: %s (expected one of %v)", phase, PHASES)
+			}
+			phases = append(phases, phase)
+		}
+	}
+	if len(phases) == 0 {
+		return PHASES, nil
+	}
+	// Phases always run in the order migrate runs them
+	var ordered []string
+	for _, phase := range PHASES {
+		if utils.Contains(phases, phase) {
+			ordered = append(ordered, phase)
+		}
+	}
+	return ordered, nil
+}
+
+// readStart reads the planned start of the run, zero when the timeline is relative
+func readStart() (time.Time, error) {
+	value := viper.GetString("GHMPKG_SIMULATE_START")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if start, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return start, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --start %q, expected a time such as 2024-06-01T22:00", value)
+}
+
+// loadInventory reads the exported rows that pull and sync would process, with
+// the same package type, repository, name, version and date filters
+func loadInventory(logger *zap.Logger) ([][]string, error) {
+	migrationPath := utils.MigrationPath()
+	owner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+
+	nameFilter, err := common.NewNameFilter()
+	if err != nil {
+		return nil, err
+	}
+	versionFilter, err := common.NewVersionFilter()
+	if err != nil {
+		return nil, err
+	}
+	since, err := common.ParseSince()
+	if err != nil {
+		return nil, err
+	}
+
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := common.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, packageType := range packageTypes {
+		inventory, err := common.FindInventory(migrationPath, packageType, owner, snapshot)
+		if err != nil {
+			logger.Info("No export to simulate", zap.String("packageType", packageType), zap.Error(err))
+			continue
+		}
+		packages, err := common.ReadInventory(inventory)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", inventory, err)
+		}
+		if len(packages) <= 1 {
+			continue
+		}
+		packageRows := nameFilter.FilterRows(packages[1:])
+		packageRows, _ = common.FilterSince(packageRows, since)
+		packageRows = versionFilter.FilterRows(packageRows)
+		packageRows = common.FilterByRepository(packageRows, common.DesiredRepositories())
+		pterm.Info.Printf("Simulating %d %s files from %s\n", len(packageRows), packageType, inventory)
+		rows = append(rows, packageRows...)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no packages to simulate in %s. %s", migrationPath, common.ARE_YOU_SURE_YOU_EXPORTED)
+	}
+	return rows, nil
+}
+
+// Simulate projects the timeline and API requests of pulling and syncing the
+// exported packages with the configured concurrency and pacing. Nothing is
+// downloaded or uploaded, only the export is read.
+func Simulate(logger *zap.Logger) error {
+	settings, err := readSettings()
+	if err != nil {
+		return err
+	}
+	phases, err := readPhases()
+	if err != nil {
+		return err
+	}
+	start, err := readStart()
+	if err != nil {
+		return err
+	}
+	if viper.GetString("GHMPKG_SIMULATE_OUTPUT") != "" && utils.ReadOnly() {
+		return fmt.Errorf("--output writes a file, which --read-only does not allow")
+	}
+	var window time.Duration
+	if value := viper.GetString("GHMPKG_SIMULATE_WINDOW"); value != "" {
+		if window, err = time.ParseDuration(value); err != nil || window <= 0 {
+			return fmt.Errorf("invalid --window %q, expected a duration such as 8h", value)
+		}
+	}
+
+	rows, err := loadInventory(logger)
+	if err != nil {
+		return err
+	}
+
+	plan := Project(rows, phases, settings)
+	logger.Info("Simulated migration",
+		zap.Strings("phases", phases),
+		zap.Int("concurrency", plan.Concurrency),
+		zap.Int("shards", plan.Shards),
+		zap.Duration("duration", time.Duration(plan.Duration)))
+	printPlan(plan, settings, start)
+
+	if plan.PeakRESTPerHour > RESTRequestsPerHour {
+		pterm.Warning.Printf("⚠️  Up to %d REST requests per hour, more than the %d an hour of a GitHub token: use a token per shard or lower --concurrency\n", plan.PeakRESTPerHour, RESTRequestsPerHour)
+	}
+	if window > 0 {
+		if time.Duration(plan.Duration) <= window {
+			pterm.Success.Printf("✅ The migration fits in the %s window\n", window)
+		} else if shards := fittingShards(rows, phases, settings, window); shards > 0 {
+			pterm.Warning.Printf("⚠️  The migration does not fit in the %s window, %d shards would\n", window, shards)
+		} else {
+			pterm.Warning.Printf("⚠️  The migration does not fit in the %s window, even with %d shards\n", window, MaxSuggestedShards)
+		}
+	}
+
+	if output := viper.GetString("GHMPKG_SIMULATE_OUTPUT"); output != "" {
+		if err := files.CreateJSON(plan, output); err != nil {
+			return fmt.Errorf("failed to write the simulation to %s: %w", output, err)
+		}
+		pterm.Info.Printf("📝 Simulation written to %s\n", output)
+	}
+	return nil
+}
+
+// fittingShards is the smallest number of shards running the migration within
+// the window, 0 when even MaxSuggestedShards do not
+func fittingShards(rows [][]string, phases []string, settings Settings, window time.Duration) int {
+	for shards := settings.Shards + 1; shards <= MaxSuggestedShards; shards++ {
+		settings.Shards = shards
+		if time.Duration(Project(rows, phases, settings).Duration) <= window {
+			return shards
+		}
+	}
+	return 0
+}
+
+// windowLabel names a window of the timeline, by clock time when the start is known
+func windowLabel(start time.Time, offset Duration) string {
+	if start.IsZero() {
+		return "+" + time.Duration(offset).String()
+	}
+	return start.Add(time.Duration(offset)).Format("2006-01-02 15:04")
+}
+
+func printPlan(plan Plan, settings Settings, start time.Time) {
+	pterm.Info.Printf("🧮 Simulated with a concurrency of %d, %d shard(s), %s per request\n", plan.Concurrency, plan.Shards, settings.APILatency)
+	if settings.Warmup != nil {
+		pterm.Info.Printf("🔥 Sync warm-up over %d versions, %s apart at first\n", settings.Warmup.Operations, settings.Warmup.Interval)
+	}
+
+	phaseRows := [][]string{{"Phase", "Packages", "Versions", "Files", "Starts", "Duration", "REST requests", "Registry requests"}}
+	for _, projection := range plan.Phases {
+		phaseRows = append(phaseRows, []string{
+			projection.Phase,
+			fmt.Sprint(projection.Packages),
+			fmt.Sprint(projection.Versions),
+			fmt.Sprint(projection.Files),
+			windowLabel(start, projection.Start),
+			time.Duration(projection.Duration).String(),
+			fmt.Sprint(projection.REST),
+			fmt.Sprint(projection.Registry),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(phaseRows).Render()
+
+	timelineRows := [][]string{{"Window", "Versions started", "Files", "REST requests", "Registry requests"}}
+	for _, bucket := range plan.Timeline {
+		timelineRows = append(timelineRows, []string{
+			windowLabel(start, bucket.Start),
+			fmt.Sprint(bucket.Versions),
+			fmt.Sprint(bucket.Files),
+			fmt.Sprint(bucket.REST),
+			fmt.Sprint(bucket.Registry),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(timelineRows).Render()
+
+	if plan.Shards > 1 {
+		var durations []string
+		for _, duration := range plan.ShardDurations {
+			durations = append(durations, time.Duration(duration).String())
+		}
+		pterm.Info.Printf("🧩 Shard durations: %s\n", strings.Join(durations, ", "))
+	}
+	pterm.Info.Printf("⏱️  Projected duration: %s", time.Duration(plan.Duration))
+	if !start.IsZero() {
+		fmt.Printf(", finishing %s", start.Add(time.Duration(plan.Duration)).Format("2006-01-02 15:04"))
+	}
+	fmt.Println()
+	pterm.Info.Printf("📈 Peak REST requests per hour: %d\n", plan.PeakRESTPerHour)
+}
@@ -0,0 +1,155 @@
+package simulate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/spf13/viper"
+)
+
+// inventory is a package inventory of packages with the given number of versions and files each
+func inventory(packages, versions, files int) [][]string {
+	var rows [][]string
+	for p := 0; p < packages; p++ {
+		for v := 0; v < versions; v++ {
+			for f := 0; f < files; f++ {
+				rows = append(rows, []string{"mona", "repo", "npm", fmt.Sprintf("app-%d", p), fmt.Sprintf("1.%d.0", v), fmt.Sprintf("file-%d", f)})
+			}
+		}
+	}
+	return rows
+}
+
+func settings(concurrency int) Settings {
+	return Settings{Concurrency: concurrency, FileTimes: map[string]time.Duration{"": 10 * time.Second}}
+}
+
+func TestProjectConcurrency(t *testing.T) {
+	tests := []struct {
+		name                      string
+		packages, versions, files int
+		concurrency               int
+		want                      time.Duration
+	}{
+		{"one at a time", 4, 1, 1, 1, 40 * time.Second},
+		{"two at a time", 4, 1, 1, 2, 20 * time.Second},
+		{"more workers than packages", 4, 1, 1, 8, 10 * time.Second},
+		{"versions of a package in order", 1, 3, 2, 4, 60 * time.Second},
+	}
+	for _, test := range tests {
+		plan := Project(inventory(test.packages, test.versions, test.files), []string{"pull"}, settings(test.concurrency))
+		if time.Duration(plan.Duration) != test.want {
+			t.Errorf("%s: duration %s, want %s", test.name, time.Duration(plan.Duration), test.want)
+		}
+	}
+}
+
+func TestProjectCalls(t *testing.T) {
+	s := settings(1)
+	s.VerifyUploads = true
+	s.CheckExisting = true
+	s.APILatency = time.Second
+	plan := Project(inventory(1, 2, 2), PHASES, s)
+	if len(plan.Phases) != 2 {
+		t.Fatalf("phases = %v", plan.Phases)
+	}
+	pull, sync := plan.Phases[0], plan.Phases[1]
+	if pull.REST != 0 || pull.Registry != 4 || time.Duration(pull.Duration) != 44*time.Second {
+		t.Errorf("pull = %+v", pull)
+	}
+	// One existence check, then an upload and a read back per file
+	if sync.REST != 1 || sync.Registry != 8 || sync.Versions != 2 || sync.Files != 4 {
+		t.Errorf("sync = %+v", sync)
+	}
+	if time.Duration(sync.Start) != 44*time.Second || time.Duration(plan.Duration) != 44*time.Second+49*time.Second {
+		t.Errorf("sync starts at %s, the migration takes %s", time.Duration(sync.Start), time.Duration(plan.Duration))
+	}
+}
+
+func TestProjectShards(t *testing.T) {
+	s := settings(1)
+	s.Shards = 2
+	plan := Project(inventory(5, 1, 1), []string{"pull"}, s)
+	if len(plan.ShardDurations) != 2 || time.Duration(plan.ShardDurations[0]) != 30*time.Second || time.Duration(plan.ShardDurations[1]) != 20*time.Second {
+		t.Errorf("shard durations = %v", plan.ShardDurations)
+	}
+	if time.Duration(plan.Duration) != 30*time.Second || plan.Phases[0].Packages != 5 {
+		t.Errorf("plan = %+v", plan)
+	}
+}
+
+func TestProjectSplitTags(t *testing.T) {
+	var rows [][]string
+	for v := 0; v < 4; v++ {
+		rows = append(rows, []string{"mona", "repo", "container", "app", fmt.Sprintf("sha256:%d", v), fmt.Sprintf("app:1.%d", v)})
+	}
+	s := settings(1)
+	s.Shards = 2
+	// A single package runs on one shard
+	if plan := Project(rows, []string{"pull"}, s); time.Duration(plan.Duration) != 40*time.Second {
+		t.Errorf("duration %s, want 40s", time.Duration(plan.Duration))
+	}
+	// Its versions are dealt to both shards once it has too many tags
+	s.SplitTags = 2
+	if plan := Project(rows, []string{"pull"}, s); time.Duration(plan.Duration) != 20*time.Second {
+		t.Errorf("duration with split tags %s, want 20s", time.Duration(plan.Duration))
+	}
+}
+
+func TestProjectWarmup(t *testing.T) {
+	s := Settings{
+		Concurrency: 3,
+		FileTimes:   map[string]time.Duration{"": time.Second},
+		Warmup:      &common.WarmupProfile{Operations: 2, Concurrency: 3, Interval: 10 * time.Second},
+	}
+	// The second version waits half the interval, the third starts with it
+	if plan := Project(inventory(3, 1, 1), []string{"sync"}, s); time.Duration(plan.Duration) != 6*time.Second {
+		t.Errorf("duration with warm-up %s, want 6s", time.Duration(plan.Duration))
+	}
+	// The warm-up only paces uploads
+	if plan := Project(inventory(3, 1, 1), []string{"pull"}, s); time.Duration(plan.Duration) != time.Second {
+		t.Errorf("pull duration %s, want 1s", time.Duration(plan.Duration))
+	}
+}
+
+func TestProjectTimeline(t *testing.T) {
+	s := settings(2)
+	s.CheckExisting = true
+	s.Bucket = 10 * time.Second
+	plan := Project(inventory(4, 1, 1), []string{"sync"}, s)
+	if len(plan.Timeline) != 2 {
+		t.Fatalf("timeline = %+v", plan.Timeline)
+	}
+	for i, bucket := range plan.Timeline {
+		if time.Duration(bucket.Start) != time.Duration(i)*10*time.Second || bucket.Versions != 2 || bucket.REST != 2 {
+			t.Errorf("bucket %d = %+v", i, bucket)
+		}
+	}
+	// Two requests in ten seconds
+	if plan.PeakRESTPerHour != 720 {
+		t.Errorf("peak = %d, want 720", plan.PeakRESTPerHour)
+	}
+}
+
+func TestParseFileTimes(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("GHMPKG_SIMULATE_FILE_TIME", []string{"5s, oci=1m"})
+	fileTimes, err := ParseFileTimes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileTimes[""] != 5*time.Second || fileTimes["npm"] != 0 || fileTimes["container"] != time.Minute {
+		t.Errorf("ParseFileTimes = %v", fileTimes)
+	}
+	if (Settings{FileTimes: fileTimes}).fileTime("npm") != 5*time.Second {
+		t.Error("package types without a time do not use the default")
+	}
+
+	viper.Set("GHMPKG_SIMULATE_FILE_TIME", []string{"npm=fast"})
+	if _, err := ParseFileTimes(); err == nil {
+		t.Error("ParseFileTimes accepted an invalid duration")
+	}
+}
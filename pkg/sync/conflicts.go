@@ -0,0 +1,45 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// targetConflicts returns the rows about to be synced whose version the target
+// already had when inventory-target listed its content, and the inventory they
+// were found in. Nothing is returned when the target was not inventoried.
+func targetConflicts(logger *zap.Logger, migrationPath, packageType, targetOwner string, rows [][]string) ([][]string, string) {
+	if !providers.TargetOnGitHub(packageType) {
+		return nil, ""
+	}
+	content, err := common.LoadTargetContent(migrationPath, packageType, targetOwner)
+	if err != nil {
+		logger.Warn("Failed to read target inventory", zap.String("packageType", packageType), zap.Error(err))
+		pterm.Warning.Printf("⚠️  Failed to read the %s target inventory, conflicts are not reported: %v\n", packageType, err)
+		return nil, ""
+	}
+	if content == nil {
+		return nil, ""
+	}
+	return content.Conflicts(rows), content.File
+}
+
+// reportConflicts lists the conflicting rows in a CSV next to the target
+// inventories. The registries do not replace a version, so these keep the
+// content the target had and need a review.
+func reportConflicts(conflicts [][]string, migrationPath, owner, targetOwner string) (string, error) {
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	filename := filepath.Join(migrationPath, "target", fmt.Sprintf("%s_%s_%s_conflicts.csv", timestamp, owner, targetOwner))
+	if err := files.CreateCSV(append([][]string{common.INVENTORY_HEADER}, conflicts...), filename); err != nil {
+		return "", err
+	}
+	pterm.Warning.Printf("⚠️  %d files to sync belong to versions that already existed on %s before the migration and keep the content the target has, review them in: %s\n", len(conflicts), targetOwner, filename)
+	return filename, nil
+}
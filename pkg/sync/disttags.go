@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// distTagged lists the packages of a package type sync processed, whose
+// dist-tags are applied after all the versions are published
+type distTagged struct {
+	packageType string
+	packages    []distTaggedPackage
+}
+
+type distTaggedPackage struct {
+	repository string
+	name       string
+}
+
+func (p distTaggedPackage) key() string {
+	return p.repository + "/" + p.name
+}
+
+func newDistTagged(packageType string, rows [][]string) distTagged {
+	seen := make(map[string]bool)
+	tagged := distTagged{packageType: packageType}
+	for _, row := range rows {
+		pkg := distTaggedPackage{repository: row[1], name: row[3]}
+		if !seen[pkg.key()] {
+			seen[pkg.key()] = true
+			tagged.packages = append(tagged.packages, pkg)
+		}
+	}
+	return tagged
+}
+
+// readDistTags reads the dist-tags export recorded, package to tag to version
+func readDistTags(logger *zap.Logger, migrationPath, packageType, owner string) map[string]map[string]string {
+	path, err := common.FindDistTags(migrationPath, packageType, owner)
+	if err != nil || path == "" {
+		logger.Info("No dist-tags export found", zap.String("packageType", packageType), zap.Error(err))
+		return nil
+	}
+	rows, err := files.ReadCSV(path)
+	if err != nil {
+		logger.Error("Failed to read dist-tags export", zap.String("file", path), zap.Error(err))
+		pterm.Warning.Printf("⚠️  Failed to read %s, only latest is set: %v\n", path, err)
+		return nil
+	}
+	tags := make(map[string]map[string]string)
+	for i, row := range rows {
+		if i == 0 || len(row) < len(common.DIST_TAGS_HEADER) {
+			continue
+		}
+		key := distTaggedPackage{repository: row[1], name: row[3]}.key()
+		if tags[key] == nil {
+			tags[key] = make(map[string]string)
+		}
+		tags[key][row[4]] = row[5]
+	}
+	return tags
+}
+
+// applyDistTags points the dist-tags export recorded at the same versions on the
+// target. Publishing moves latest to the last published version, whatever its
+// number, so the tags are applied once every version is published. Packages
+// without a recorded latest tag get it on the newest release the target has.
+// It returns the number of tags applied and failed.
+func applyDistTags(logger *zap.Logger, migrationPath, sourceOwner, targetOwner string, tagged distTagged) (int, int) {
+	provider, err := providers.NewProvider(logger, tagged.packageType)
+	if err != nil {
+		return 0, 0
+	}
+	tagger, ok := provider.(providers.DistTagger)
+	if !ok {
+		return 0, 0
+	}
+	recorded := readDistTags(logger, migrationPath, tagged.packageType, sourceOwner)
+
+	applied, failed := 0, 0
+	for _, pkg := range tagged.packages {
+		tags := make(map[string]string, len(recorded[pkg.key()])+1)
+		for tag, version := range recorded[pkg.key()] {
+			tags[tag] = version
+		}
+		if _, ok := tags["latest"]; !ok {
+			versions, err := tagger.TargetVersions(logger, targetOwner, pkg.name)
+			if err != nil {
+				logger.Warn("Failed to list target versions", zap.String("package", pkg.name), zap.Error(err))
+			} else if latest := common.LatestVersion(versions); latest != "" {
+				tags["latest"] = latest
+			}
+		}
+
+		names := make([]string, 0, len(tags))
+		for tag := range tags {
+			names = append(names, tag)
+		}
+		sort.Strings(names)
+		for _, tag := range names {
+			version := tags[tag]
+			if err := tagger.SetDistTag(logger, targetOwner, pkg.name, tag, version); err != nil {
+				logger.Warn("Failed to apply dist-tag",
+					zap.String("package", pkg.name),
+					zap.String("tag", tag),
+					zap.String("version", version),
+					zap.Error(err))
+				pterm.Warning.Println(fmt.Sprintf("⚠️  Failed to tag %s@%s as %s: %v", pkg.name, version, tag, err))
+				failed++
+				continue
+			}
+			applied++
+		}
+	}
+	return applied, failed
+}
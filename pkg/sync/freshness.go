@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// inventory is the export CSV sync read for a package type, with the names of
+// the packages it lists after the name filter
+type inventory struct {
+	packageType string
+	path        string
+	packages    []string
+}
+
+func newInventory(packageType, path string, rows [][]string) inventory {
+	var names []string
+	for _, row := range rows {
+		if !utils.Contains(names, row[3]) {
+			names = append(names, row[3])
+		}
+	}
+	return inventory{packageType: packageType, path: path, packages: names}
+}
+
+// parseAge reads a duration, also accepting a number of days such as "7d"
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid inventory age %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid inventory age %q", value)
+	}
+	return age, nil
+}
+
+// exportedAt reads the export time from the timestamp prefix of the CSV name,
+// falling back to the modification time of the file
+func exportedAt(path string) (time.Time, error) {
+	name := filepath.Base(path)
+	// Exports read from the inventory database are named like their files
+	if database, export, ok := strings.Cut(path, "#"); ok {
+		name, path = export, database
+	}
+	if len(name) >= 19 {
+		if t, err := time.ParseInLocation("2006-01-02_15-04-05", name[:19], time.Local); err == nil {
+			return t, nil
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// packageDrift compares the packages of an inventory with those currently in the source organization
+func packageDrift(inv inventory, nameFilter *common.NameFilter) (added, removed []string, err error) {
+	packages, err := api.FetchPackages(inv.packageType)
+	if err != nil {
+		return nil, nil, err
+	}
+	repositories := common.DesiredRepositories()
+	var current []string
+	for _, pkg := range packages {
+		if !common.MatchRepository(repositories, pkg.Repository.GetName()) {
+			continue
+		}
+		if !nameFilter.Match(pkg.GetName()) {
+			continue
+		}
+		current = append(current, pkg.GetName())
+		if !utils.Contains(inv.packages, pkg.GetName()) {
+			added = append(added, pkg.GetName())
+		}
+	}
+	for _, name := range inv.packages {
+		if !utils.Contains(current, name) {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed, nil
+}
+
+// checkFreshness warns when the inventories are older than GHMPKG_MAX_INVENTORY_AGE
+// or when the source organization gained or lost packages since they were
+// exported. With GHMPKG_STRICT it fails instead, so a stale inventory is never
+// migrated at cutover.
+func checkFreshness(logger *zap.Logger, inventories []inventory, nameFilter *common.NameFilter) error {
+	maxAge, err := parseAge(viper.GetString("GHMPKG_MAX_INVENTORY_AGE"))
+	if err != nil {
+		return err
+	}
+	checkDrift := viper.GetString("GHMPKG_SOURCE_TOKEN") != ""
+	if !checkDrift {
+		pterm.Info.Println("ℹ️  Set --source-token to check the inventory against the source organization")
+	}
+
+	var problems []string
+	for _, inv := range inventories {
+		exported, err := exportedAt(inv.path)
+		if err != nil {
+			return err
+		}
+		if age := time.Since(exported); maxAge > 0 && age > maxAge {
+			problems = append(problems, fmt.Sprintf("%s inventory %s was exported %s ago", inv.packageType, filepath.Base(inv.path), age.Round(time.Minute)))
+		}
+
+		if !checkDrift {
+			continue
+		}
+		added, removed, err := packageDrift(inv, nameFilter)
+		if err != nil {
+			logger.Warn("Failed to compare inventory with source organization",
+				zap.String("packageType", inv.packageType),
+				zap.Error(err))
+			pterm.Warning.Printf("⚠️  Could not compare %s inventory with the source organization: %v\n", inv.packageType, err)
+			continue
+		}
+		if len(added) > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s packages were added to the source since export: %s", len(added), inv.packageType, summarizeNames(added)))
+		}
+		if len(removed) > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s packages were removed from the source since export: %s", len(removed), inv.packageType, summarizeNames(removed)))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	for _, problem := range problems {
+		logger.Warn("Stale inventory", zap.String("problem", problem))
+		pterm.Warning.Printf("⚠️  %s\n", problem)
+	}
+	if viper.GetBool("GHMPKG_STRICT") {
+		return fmt.Errorf("inventory is stale, run a new export before syncing (%d problems found)", len(problems))
+	}
+	pterm.Warning.Println("⚠️  Consider running a delta export before cutover, or use --strict to stop on a stale inventory")
+	return nil
+}
+
+// summarizeNames lists the first few names for the console
+func summarizeNames(names []string) string {
+	const shown = 5
+	if len(names) <= shown {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:shown], ", "), len(names)-shown)
+}
@@ -0,0 +1,138 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// The target API calls of a rollback, variables so tests can fake the target
+var (
+	fetchTargetPackage         = api.FetchTargetPackage
+	fetchTargetPackageVersions = api.FetchTargetPackageVersions
+	deleteTargetPackageVersion = api.DeleteTargetPackageVersion
+)
+
+// rollbacks counts the versions --rollback-partial deleted from the target, the
+// ones it kept as the last version of their package and the ones it could not
+var rollbacks struct {
+	done   atomic.Int64
+	kept   atomic.Int64
+	failed atomic.Int64
+}
+
+// errLastVersion is returned by rollbackVersion for the only version of a
+// package on the target: GitHub deletes the package with its last version and
+// the name of a deleted package cannot be published again
+var errLastVersion = errors.New("the only version of the package on the target")
+
+// partialUpload reports whether a version failed after some of its files were
+// uploaded by this run, leaving a broken version on the target. Versions that
+// failed before uploading anything are left alone, the target may already have
+// had them complete.
+func partialUpload(report *common.Report, err error) bool {
+	return (err != nil || report.FilesFailed > 0) && report.FileSuccess > 0
+}
+
+// findTargetVersion returns the target version the files of a version were
+// uploaded to, container versions are matched on the tags in their filenames
+func findTargetVersion(versions []*github.PackageVersion, packageType, version string, filenames []string) *github.PackageVersion {
+	if packageType == "nuget" {
+		version = providers.NormalizeName(packageType, providers.VersionField, version)
+	}
+	for _, targetVersion := range versions {
+		if !providers.IsImage(packageType) {
+			if targetVersion.GetName() == version {
+				return targetVersion
+			}
+			continue
+		}
+		if targetVersion.Metadata == nil || targetVersion.Metadata.Container == nil {
+			continue
+		}
+		for _, filename := range filenames {
+			_, tag, ok := strings.Cut(filename, ":")
+			if ok && utils.Contains(targetVersion.Metadata.Container.Tags, tag) {
+				return targetVersion
+			}
+		}
+	}
+	return nil
+}
+
+// rollbackVersion deletes a partially uploaded version from the target so that
+// it is either complete or absent, and a later sync uploads it again as a whole.
+// The only version of a package is kept, deleting it would delete the package.
+func rollbackVersion(logger *zap.Logger, packageType, packageName, version string, filenames []string) error {
+	if !providers.TargetOnGitHub(packageType) {
+		return fmt.Errorf("%s packages cannot be rolled back on %s", packageType, providers.TargetRegistry())
+	}
+	targetType := providers.TargetPackageType(packageType)
+	targetName := providers.TargetPackageName(packageType, packageName)
+
+	pkg, err := fetchTargetPackage(targetType, targetName)
+	if err != nil {
+		return err
+	}
+	if pkg == nil {
+		return nil
+	}
+	versions, err := fetchTargetPackageVersions(&github.Package{Name: &targetName, PackageType: &targetType})
+	if err != nil {
+		return err
+	}
+	targetVersion := findTargetVersion(versions, packageType, version, filenames)
+	if targetVersion == nil {
+		logger.Info("Partially uploaded version not found on target, nothing to roll back",
+			zap.String("packageName", targetName),
+			zap.String("version", version))
+		return nil
+	}
+
+	if len(versions) == 1 {
+		return fmt.Errorf("%s %s is %w", targetName, version, errLastVersion)
+	}
+
+	logger.Info("Rolling back partially uploaded version",
+		zap.String("packageType", targetType),
+		zap.String("packageName", targetName),
+		zap.String("version", version),
+		zap.Int64("versionID", targetVersion.GetID()))
+	return deleteTargetPackageVersion(targetType, targetName, targetVersion.GetID(), false)
+}
+
+// rollbackPartial rolls back a version that failed halfway with --rollback-partial
+func rollbackPartial(logger *zap.Logger, report *common.Report, packageType, packageName, version string, filenames []string, uploadErr error) {
+	if !partialUpload(report, uploadErr) {
+		return
+	}
+	err := rollbackVersion(logger, packageType, packageName, version, filenames)
+	if errors.Is(err, errLastVersion) {
+		rollbacks.kept.Add(1)
+		logger.Warn("Kept partially uploaded version, the only one of its package",
+			zap.String("packageName", packageName),
+			zap.String("version", version))
+		pterm.Warning.Println(fmt.Sprintf("⚠️  Kept %s %s, the only version of its package on the target: deleting it would delete the package, whose name cannot be reused. Sync it again with --existing-packages all to upload its missing files", packageName, version))
+		return
+	}
+	if err != nil {
+		rollbacks.failed.Add(1)
+		logger.Error("Failed to roll back partially uploaded version",
+			zap.String("packageName", packageName),
+			zap.String("version", version),
+			zap.Error(err))
+		pterm.Error.Println(fmt.Sprintf("❌ Failed to roll back %s %s, delete it from the target before syncing it again: %v", packageName, version, err))
+		return
+	}
+	rollbacks.done.Add(1)
+	pterm.Warning.Println(fmt.Sprintf("↩️  Rolled back %s %s, %d of its files were uploaded before it failed", packageName, version, report.FileSuccess))
+}
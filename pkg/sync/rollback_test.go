@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"go.uber.org/zap"
+)
+
+func TestPartialUpload(t *testing.T) {
+	item := func(filename string, state providers.ResultState) common.Item {
+		return common.NewItem("mona-actions", "app-repo", "maven", "com.example.app", "1.0", filename, state, nil)
+	}
+	tests := []struct {
+		name  string
+		items []common.Item
+		err   error
+		want  bool
+	}{
+		{"pom uploaded, jar failed", []common.Item{item("app-1.0.pom", providers.Success), item("app-1.0.jar", providers.Failed)}, nil, true},
+		{"batch failed after the pom", []common.Item{item("app-1.0.pom", providers.Success)}, errors.New("502"), true},
+		{"nothing uploaded", []common.Item{item("app-1.0.pom", providers.Failed)}, errors.New("502"), false},
+		{"complete", []common.Item{item("app-1.0.pom", providers.Success), item("app-1.0.jar", providers.Success)}, nil, false},
+	}
+	for _, test := range tests {
+		report := common.NewReport()
+		for _, item := range test.items {
+			report.RecordFile(item)
+		}
+		if got := partialUpload(report, test.err); got != test.want {
+			t.Errorf("%s: partialUpload = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRollbackVersion(t *testing.T) {
+	fetchPackage, fetchVersions, deleteVersion := fetchTargetPackage, fetchTargetPackageVersions, deleteTargetPackageVersion
+	defer func() {
+		fetchTargetPackage, fetchTargetPackageVersions, deleteTargetPackageVersion = fetchPackage, fetchVersions, deleteVersion
+	}()
+	versions := []*github.PackageVersion{
+		{ID: github.Int64(1), Name: github.String("1.0")},
+		{ID: github.Int64(2), Name: github.String("sha256:abc"), Metadata: &github.PackageMetadata{Container: &github.PackageContainerMetadata{Tags: []string{"2.0", "latest"}}}},
+	}
+	fetchTargetPackage = func(packageType, packageName string) (*github.Package, error) {
+		return &github.Package{Name: &packageName}, nil
+	}
+	fetchTargetPackageVersions = func(pkg *github.Package) ([]*github.PackageVersion, error) {
+		return versions, nil
+	}
+
+	tests := []struct {
+		name, packageType, version, filename string
+		deleted                              int64
+	}{
+		{"maven version", "maven", "1.0", "app-1.0.jar", 1},
+		{"container tag", "container", "sha256:def", "app:2.0", 2},
+		{"not on target", "maven", "3.0", "app-3.0.jar", 0},
+	}
+	for _, test := range tests {
+		var deleted int64
+		deleteTargetPackageVersion = func(packageType, packageName string, versionID int64, lastVersion bool) error {
+			deleted = versionID
+			return nil
+		}
+		if err := rollbackVersion(zap.NewNop(), test.packageType, "app", test.version, []string{test.filename}); err != nil {
+			t.Errorf("%s: rollbackVersion returned an error: %v", test.name, err)
+		}
+		if deleted != test.deleted {
+			t.Errorf("%s: deleted version %d, want %d", test.name, deleted, test.deleted)
+		}
+	}
+}
+
+func TestRollbackLastVersion(t *testing.T) {
+	fetchPackage, fetchVersions, deleteVersion := fetchTargetPackage, fetchTargetPackageVersions, deleteTargetPackageVersion
+	defer func() {
+		fetchTargetPackage, fetchTargetPackageVersions, deleteTargetPackageVersion = fetchPackage, fetchVersions, deleteVersion
+	}()
+	fetchTargetPackage = func(packageType, packageName string) (*github.Package, error) {
+		return &github.Package{Name: &packageName}, nil
+	}
+	fetchTargetPackageVersions = func(pkg *github.Package) ([]*github.PackageVersion, error) {
+		return []*github.PackageVersion{{ID: github.Int64(1), Name: github.String("1.0")}}, nil
+	}
+	deleteTargetPackageVersion = func(packageType, packageName string, versionID int64, lastVersion bool) error {
+		t.Errorf("deleted version %d of %s, the only version of the package", versionID, packageName)
+		return nil
+	}
+
+	err := rollbackVersion(zap.NewNop(), "maven", "app", "1.0", []string{"app-1.0.jar"})
+	if !errors.Is(err, errLastVersion) {
+		t.Errorf("rollbackVersion = %v, want errLastVersion", err)
+	}
+
+	rollbacks.kept.Store(0)
+	report := common.NewReport()
+	report.RecordFile(common.NewItem("mona-actions", "app-repo", "maven", "app", "1.0", "app-1.0.pom", providers.Success, nil))
+	rollbackPartial(zap.NewNop(), report, "maven", "app", "1.0", []string{"app-1.0.jar"}, errors.New("502"))
+	if kept := rollbacks.kept.Load(); kept != 1 {
+		t.Errorf("rollbacks kept = %d, want 1", kept)
+	}
+}
@@ -0,0 +1,205 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// smokeTimeout bounds a single smoke test, a client resolving a version
+var smokeTimeout = 5 * time.Minute
+
+// Outcomes of a smoke test
+const (
+	SmokePassed  = "passed"
+	SmokeFailed  = "failed"
+	SmokeSkipped = "skipped"
+)
+
+// smokeVersion is a version synced by the run, with the files of its items
+type smokeVersion struct {
+	repository  string
+	packageType string
+	packageName string
+	version     string
+	filenames   []string
+}
+
+// smokeResult is the outcome of resolving a sampled version from the target
+type smokeResult struct {
+	smokeVersion
+	client string
+	result string
+	detail string
+}
+
+func (r smokeResult) row() []string {
+	return []string{r.packageType, r.packageName, r.version, r.client, r.result, r.detail}
+}
+
+// sampleSmokeVersions picks up to perType versions of every package type among
+// the versions the run uploaded files of without any failure
+func sampleSmokeVersions(items []common.Item, perType int) map[string][]smokeVersion {
+	type key struct{ repository, packageType, packageName, version string }
+	versions := make(map[key]*smokeVersion)
+	var order []key
+	failed := make(map[key]bool)
+	uploaded := make(map[key]bool)
+	for _, item := range items {
+		if item.Version == "" || item.Filename == "" {
+			continue
+		}
+		k := key{item.Repository, item.PackageType, item.PackageName, item.Version}
+		if _, ok := versions[k]; !ok {
+			versions[k] = &smokeVersion{item.Repository, item.PackageType, item.PackageName, item.Version, nil}
+			order = append(order, k)
+		}
+		versions[k].filenames = append(versions[k].filenames, item.Filename)
+		switch item.State {
+		case providers.Failed:
+			failed[k] = true
+		case providers.Success:
+			uploaded[k] = true
+		}
+	}
+
+	sampled := make(map[string][]smokeVersion)
+	for _, k := range order {
+		if uploaded[k] && !failed[k] {
+			sampled[k.packageType] = append(sampled[k.packageType], *versions[k])
+		}
+	}
+	for packageType, candidates := range sampled {
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		sampled[packageType] = candidates[:min(len(candidates), perType)]
+	}
+	return sampled
+}
+
+// runConsume runs a smoke test command, killed after smokeTimeout, and returns
+// its output
+func runConsume(cmd *exec.Cmd) (string, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Children of a killed client, the JVM of mvn, must not hold up the result
+	cmd.WaitDelay = 5 * time.Second
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	timer := time.AfterFunc(smokeTimeout, func() { cmd.Process.Kill() })
+	err := cmd.Wait()
+	if !timer.Stop() {
+		err = fmt.Errorf("timed out after %s", smokeTimeout)
+	}
+	return output.String(), err
+}
+
+// lastLine is the last line of the output of a client, usually the one
+// explaining why it failed
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// smokeTest resolves a synced version from the target with the client of its
+// ecosystem, in a workspace of its own
+func smokeTest(logger *zap.Logger, consumer providers.Consumer, targetOwner string, version smokeVersion) smokeResult {
+	result := smokeResult{smokeVersion: version}
+	workspace, err := os.MkdirTemp("", "ghmpkg-smoke-")
+	if err != nil {
+		result.result, result.detail = SmokeFailed, err.Error()
+		return result
+	}
+	defer os.RemoveAll(workspace)
+
+	cmd, err := consumer.ConsumeCommand(logger, workspace, targetOwner, version.repository, version.packageName, version.version, version.filenames)
+	if err != nil {
+		result.result, result.detail = SmokeSkipped, err.Error()
+		return result
+	}
+	result.client = filepath.Base(cmd.Path)
+	if _, err := exec.LookPath(cmd.Path); err != nil {
+		result.result, result.detail = SmokeSkipped, fmt.Sprintf("%s is not installed", result.client)
+		return result
+	}
+
+	logger.Info("Running smoke test",
+		zap.String("packageType", version.packageType),
+		zap.String("packageName", version.packageName),
+		zap.String("version", version.version),
+		zap.String("client", result.client))
+	output, err := runConsume(cmd)
+	if err != nil {
+		logger.Error("Smoke test failed",
+			zap.String("packageType", version.packageType),
+			zap.String("packageName", version.packageName),
+			zap.String("version", version.version),
+			zap.String("output", output),
+			zap.Error(err))
+		result.result, result.detail = SmokeFailed, err.Error()
+		if line := lastLine(output); line != "" {
+			result.detail += ": " + line
+		}
+		return result
+	}
+	result.result = SmokePassed
+	return result
+}
+
+// runSmokeTests resolves a sample of the versions the run synced from the
+// target, with mvn, npm, gem or docker as their consumers would, and writes
+// the results to a CSV file. It returns the number of versions per outcome and
+// the file.
+func runSmokeTests(logger *zap.Logger, report *common.Report, migrationPath, sourceOwner, targetOwner string, perType int) (map[string]int, string, error) {
+	sampled := sampleSmokeVersions(report.Items, perType)
+	packageTypes := make([]string, 0, len(sampled))
+	for packageType := range sampled {
+		packageTypes = append(packageTypes, packageType)
+	}
+	sort.Strings(packageTypes)
+
+	counts := make(map[string]int)
+	rows := [][]string{{"package_type", "package_name", "package_version", "client", "result", "detail"}}
+	for _, packageType := range packageTypes {
+		provider, err := providers.NewProvider(logger, packageType)
+		if err != nil {
+			return counts, "", err
+		}
+		consumer, ok := provider.(providers.Consumer)
+		if !ok {
+			pterm.Warning.Printf("⚠️  Smoke tests are not supported for %s packages\n", packageType)
+			continue
+		}
+		pterm.Info.Printf("🧪 Smoke testing %d synced %s versions\n", len(sampled[packageType]), packageType)
+		for _, version := range sampled[packageType] {
+			result := smokeTest(logger, consumer, targetOwner, version)
+			counts[result.result]++
+			rows = append(rows, result.row())
+			switch result.result {
+			case SmokePassed:
+				pterm.Success.Printf("✅ %s %s resolved with %s\n", version.packageName, version.version, result.client)
+			case SmokeFailed:
+				pterm.Error.Printf("❌ %s %s could not be resolved with %s: %s\n", version.packageName, version.version, result.client, result.detail)
+			default:
+				pterm.Warning.Printf("⚠️  %s %s not smoke tested: %s\n", version.packageName, version.version, result.detail)
+			}
+		}
+	}
+
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	filename := filepath.Join(migrationPath, "smoke", fmt.Sprintf("%s_%s_%s_smoke.csv", timestamp, sourceOwner, targetOwner))
+	return counts, filename, files.CreateCSV(rows, filename)
+}
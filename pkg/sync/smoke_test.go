@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"go.uber.org/zap"
+)
+
+type fakeConsumer struct {
+	name string
+	args []string
+	err  error
+}
+
+func (c fakeConsumer) ConsumeCommand(logger *zap.Logger, workspace, owner, repository, packageName, version string, filenames []string) (*exec.Cmd, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Dir = workspace
+	return cmd, nil
+}
+
+func TestSampleSmokeVersions(t *testing.T) {
+	items := []common.Item{
+		common.NewItem("mona-actions", "app", "maven", "com.example.app", "1.0", "app-1.0.pom", providers.Success, nil),
+		common.NewItem("mona-actions", "app", "maven", "com.example.app", "1.0", "app-1.0.jar", providers.Success, nil),
+		common.NewItem("mona-actions", "app", "maven", "com.example.app", "2.0", "app-2.0.pom", providers.Success, nil),
+		common.NewItem("mona-actions", "app", "maven", "com.example.app", "2.0", "app-2.0.jar", providers.Failed, errors.New("413")),
+		common.NewItem("mona-actions", "web", "npm", "web", "1.0.0", "web-1.0.0.tgz", providers.Skipped, &providers.SkipError{Reason: providers.SkipExistsOnTarget}),
+		common.NewItem("mona-actions", "web", "npm", "web", "1.1.0", "web-1.1.0.tgz", providers.Success, nil),
+		common.NewItem("mona-actions", "web", "npm", "web", "1.2.0", "web-1.2.0.tgz", providers.Success, nil),
+	}
+
+	sampled := sampleSmokeVersions(items, 1)
+	// A version with a failed file and one already on the target are never sampled
+	if len(sampled["maven"]) != 1 || sampled["maven"][0].version != "1.0" || len(sampled["maven"][0].filenames) != 2 {
+		t.Errorf("maven sample = %+v, want 1.0 with its 2 files", sampled["maven"])
+	}
+	if len(sampled["npm"]) != 1 || sampled["npm"][0].version == "1.0.0" {
+		t.Errorf("npm sample = %+v, want one of the uploaded versions", sampled["npm"])
+	}
+	if sampled = sampleSmokeVersions(items, 5); len(sampled["npm"]) != 2 {
+		t.Errorf("npm sample of 5 = %+v, want both uploaded versions", sampled["npm"])
+	}
+}
+
+func TestSmokeTest(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell to run the clients with")
+	}
+	defer func(timeout time.Duration) { smokeTimeout = timeout }(smokeTimeout)
+	smokeTimeout = time.Second
+	version := smokeVersion{"web", "npm", "web", "1.1.0", []string{"web-1.1.0.tgz"}}
+
+	tests := []struct {
+		name     string
+		consumer fakeConsumer
+		want     string
+		detail   string
+	}{
+		{"resolved", fakeConsumer{name: "sh", args: []string{"-c", "echo ok"}}, SmokePassed, ""},
+		{"not found", fakeConsumer{name: "sh", args: []string{"-c", "echo fetching; echo 'npm error 404 Not Found' >&2; exit 1"}}, SmokeFailed, "exit status 1: npm error 404 Not Found"},
+		{"hanging", fakeConsumer{name: "sh", args: []string{"-c", "exec sleep 5"}}, SmokeFailed, "timed out after 1s"},
+		{"client missing", fakeConsumer{name: "ghmpkg-no-such-client"}, SmokeSkipped, "ghmpkg-no-such-client is not installed"},
+		{"no coordinates", fakeConsumer{err: errors.New("no pom")}, SmokeSkipped, "no pom"},
+	}
+	for _, test := range tests {
+		result := smokeTest(zap.NewNop(), test.consumer, "mona-emu", version)
+		if result.result != test.want || result.detail != test.detail {
+			t.Errorf("%s: smokeTest = %s %q, want %s %q", test.name, result.result, result.detail, test.want, test.detail)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"strings"
 	"time"
 
-	"github.com/mona-actions/gh-migrate-packages/internal/files"
 	"github.com/mona-actions/gh-migrate-packages/internal/providers"
 	"github.com/mona-actions/gh-migrate-packages/internal/utils"
 	"github.com/mona-actions/gh-migrate-packages/pkg/common"
@@ -17,21 +17,24 @@ import (
 
 var SUPPORTED_PACKAGE_TYPES = common.SUPPORTED_PACKAGE_TYPES
 
-func checkPath(logger *zap.Logger) {
-	if !utils.FileExists("./tool/gpr") {
-		utils.EnsureDirExists("./tool")
-		installCmd := exec.Command("dotnet", "tool", "install", "gpr", "--add-source", "https://api.nuget.org/v3/index.json", "--tool-path", "./tool")
-		installCmd.Stdout = os.Stdout
-		installCmd.Stderr = os.Stderr
-		if err := installCmd.Run(); err != nil {
-			fmt.Println("Error installing gpr tool for nuget packages migration")
-			logger.Error("Error installing gpr tool for nuget packages migration", zap.Error(err))
-		}
+// CONFLICT_POLICIES are the ways sync can handle a package name the target cannot reuse
+var CONFLICT_POLICIES = []string{"fail", "rename"}
+
+// Upload uploads the files of a version to the target. With --rollback-partial,
+// a version that fails after some of its files were uploaded is deleted from
+// the target again.
+func Upload(logger *zap.Logger, provider providers.Provider, report *common.Report, repository, packageType, packageName, version string, filenames []string) error {
+	err := upload(logger, provider, report, repository, packageType, packageName, version, filenames)
+	if viper.GetBool("GHMPKG_ROLLBACK_PARTIAL") {
+		rollbackPartial(logger, report, packageType, packageName, version, filenames, err)
 	}
+	return err
 }
 
-func Upload(logger *zap.Logger, provider providers.Provider, report *common.Report, repository, packageType, packageName, version string, filenames []string) error {
+func upload(logger *zap.Logger, provider providers.Provider, report *common.Report, repository, packageType, packageName, version string, filenames []string) error {
 	owner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	// Items are reported against the source inventory rows
+	sourceOwner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
 	zapFields := []zap.Field{
 		zap.String("owner", owner),
 		zap.String("repository", repository),
@@ -49,67 +52,196 @@ func Upload(logger *zap.Logger, provider providers.Provider, report *common.Repo
 		pterm.Info.Println("📂 repository: (n/a, org scoped)")
 	}
 
+	// Streaming copies each file straight from the source registry
+	if viper.GetBool("GHMPKG_STREAM") {
+		streamer, ok := provider.(providers.Streamer)
+		for _, filename := range filenames {
+			if !ok {
+				_, err := providers.Skip(providers.SkipStreamUnsupported, fmt.Sprintf("%s packages cannot be streamed, sync them without --stream", packageType))
+				recordUpload(logger, provider, report, repository, packageType, packageName, version, filename, providers.Skipped, err)
+				continue
+			}
+			result, err := streamer.Stream(logger, sourceOwner, repository, packageType, packageName, version, filename)
+			if err != nil && !providers.IsSkip(err) {
+				logger.Error("Failed to stream package", append(zapFields,
+					zap.String("filename", filename),
+					zap.Error(err))...)
+				pterm.Error.Println(fmt.Sprintf("❌ Failed to stream: %s", filename))
+				report.RecordFile(common.NewItem(sourceOwner, repository, packageType, packageName, version, filename, providers.Failed, err))
+				return err
+			}
+			recordUpload(logger, provider, report, repository, packageType, packageName, version, filename, result, err)
+		}
+		return nil
+	}
+
 	// Special case for Maven packages
 	if mavenProvider, ok := provider.(*providers.MavenProvider); ok {
-		results, err := mavenProvider.UploadBatch(logger, owner, repository, packageType, packageName, version, filenames)
+		results, skips, err := mavenProvider.UploadBatch(logger, owner, repository, packageType, packageName, version, filenames)
 		if err != nil {
+			// Record the files uploaded before the batch failed
+			for i, result := range results {
+				if result != providers.Failed {
+					recordUpload(logger, provider, report, repository, packageType, packageName, version, filenames[i], result, skips[i])
+				}
+			}
 			return err
 		}
 		for i, result := range results {
-			report.IncFiles(result)
-			if result == providers.Success {
-				pterm.Success.Println(fmt.Sprintf("✅ %s", filenames[i]))
-			}
+			recordUpload(logger, provider, report, repository, packageType, packageName, version, filenames[i], result, skips[i])
 		}
 		return nil
 	}
 
 	// Regular sequential upload for other package types
-	var err error
 	for _, filename := range filenames {
 		result, err := provider.Upload(logger, owner, repository, packageType, packageName, version, filename)
-		if err != nil {
+		if err != nil && !providers.IsSkip(err) {
 			logger.Error("Failed to upload package", append(zapFields,
 				zap.String("filename", filename),
 				zap.Error(err))...)
 			pterm.Error.Println(fmt.Sprintf("❌ Failed to upload: %s", filename))
+			err = providers.CheckNameReused(packageName, err)
+			report.RecordFile(common.NewItem(sourceOwner, repository, packageType, packageName, version, filename, providers.Failed, err))
+			var reused *providers.NameReusedError
+			if errors.As(err, &reused) {
+				pterm.Error.Println(fmt.Sprintf("❌ %s: %s was deleted from %s and its name cannot be reused. Restore the deleted package from the organization settings, or re-run sync with --conflict-policy rename to publish it under a new name.",
+					providers.NameReusedCode, packageName, owner))
+			}
 			return err
 		}
-		report.IncFiles(result)
-		if result == providers.Success {
-			pterm.Success.Println(fmt.Sprintf("✅ %s", filename))
-		}
+		recordUpload(logger, provider, report, repository, packageType, packageName, version, filename, result, err)
 	}
 
-	return err
+	return nil
 }
 
 func Sync(logger *zap.Logger) error {
 	startTime := time.Now()
 	utils.ResetRequestCounters()
-	checkPath(logger)
 	owner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
 	targetOwner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
-	desiredPackageType := viper.GetString("GHMPKG_PACKAGE_TYPE")
-	migrationPath := viper.GetString("GHMPKG_MIGRATION_PATH")
-	if migrationPath == "" {
-		migrationPath = "./migration-packages"
+	migrationPath := utils.MigrationPath()
+
+	if policy := viper.GetString("GHMPKG_CONFLICT_POLICY"); policy != "" && !utils.Contains(CONFLICT_POLICIES, policy) {
+		return fmt.Errorf("unsupported conflict policy: %s (expected one of %v)", policy, CONFLICT_POLICIES)
+	}
+	if _, err := common.ExistingPackagePolicy(); err != nil {
+		return err
+	}
+	if _, err := common.NewSuccessCriteria(); err != nil {
+		return err
+	}
+	if _, err := common.ProgressBarsMode(); err != nil {
+		return err
+	}
+
+	snapshot, err := common.RequiredSnapshot()
+	if err != nil {
+		return err
+	}
+	nameFilter, err := common.NewNameFilter()
+	if err != nil {
+		return err
+	}
+	versionFilter, err := common.NewVersionFilter()
+	if err != nil {
+		return err
+	}
+	since, err := common.ParseSince()
+	if err != nil {
+		return err
+	}
+
+	mapping, err := providers.TargetMapping()
+	if err != nil {
+		return err
+	}
+	if criticalPackages, err = common.NewCriticalPackages(); err != nil {
+		return err
+	}
+
+	if err := providers.CheckTargetRegistry(); err != nil {
+		return err
+	}
+	if providers.ExternalTarget() {
+		if viper.GetBool("GHMPKG_STREAM") {
+			return fmt.Errorf("--stream is not supported when publishing to %s", providers.TargetRegistry())
+		}
+		targetOwner = providers.TargetUrl()
+	}
+
+	if err := providers.CheckTransfer(); err != nil {
+		return err
+	}
+
+	stream := viper.GetBool("GHMPKG_STREAM")
+	if stream {
+		if _, err := providers.ChecksumMode(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := providers.StagingNames(); err != nil {
+		return err
+	}
+
+	store, err := providers.ArtifactStorage()
+	if err != nil {
+		return err
+	}
+	if !stream {
+		// A staging store copied from another machine names the organization it was pulled from
+		stagedOwner, err := providers.StagedOrganization(migrationPath, owner)
+		if err != nil {
+			return err
+		}
+		if stagedOwner != owner {
+			pterm.Info.Println(fmt.Sprintf("📁 Syncing the packages staged for %s", stagedOwner))
+			owner = stagedOwner
+			viper.Set("GHMPKG_SOURCE_ORGANIZATION", owner)
+		}
 	}
 
 	pterm.Info.Println("Starting sync process...")
 	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Syncing packages to target org: %s", targetOwner))
+	if snapshot != "" {
+		pterm.Info.Println(fmt.Sprintf("📸 Syncing snapshot: %s", snapshot))
+	}
+	desiredRepositories := common.DesiredRepositories()
+	if len(desiredRepositories) > 0 {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering for repositories: %s", strings.Join(desiredRepositories, ", ")))
+	}
+	if !nameFilter.IsEmpty() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering package names: %s", nameFilter))
+	}
+	if !versionFilter.IsEmpty() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering versions: %s", versionFilter))
+	}
+	if !since.IsZero() {
+		pterm.Info.Println(fmt.Sprintf("🔍 Filtering versions created since %s", since.Format(time.RFC3339)))
+	}
+	if !mapping.IsEmpty() {
+		pterm.Info.Println(fmt.Sprintf("🗺️ Renaming with mapping file: %s", viper.GetString("GHMPKG_MAPPING_FILE")))
+	}
+	if providers.Transferring() {
+		pterm.Warning.Println("🚚 Transferring packages: each package is deleted before it is published again from its new repository")
+	}
 
-	packageTypes := SUPPORTED_PACKAGE_TYPES
-	if desiredPackageType != "" {
-		if !utils.Contains(SUPPORTED_PACKAGE_TYPES, desiredPackageType) {
-			spinner.Fail(fmt.Sprintf("Unsupported package type: %s", desiredPackageType))
-			return fmt.Errorf("unsupported package type: %s", desiredPackageType)
-		}
-		packageTypes = []string{desiredPackageType}
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	if unprefixed := mapping.UnprefixedTypes(packageTypes); len(unprefixed) > 0 {
+		pterm.Warning.Printf("⚠️  The mapping prefix %q is not applied to %s packages, their names are part of the published files\n", mapping.Prefix, strings.Join(unprefixed, ", "))
 	}
 
 	var allPackages [][]string
+	var inventories []inventory
 	packageStats := make(map[string][]string)
+	var distTaggedTypes []distTagged
+	var conflicts [][]string
 
 	for _, pkgType := range packageTypes {
 		logger.Info("Processing package type", zap.String("type", pkgType))
@@ -122,35 +254,24 @@ func Sync(logger *zap.Logger) error {
 			continue
 		}
 
-		pattern := fmt.Sprintf("%s/export/%s/*_%s_%s_packages.csv", migrationPath, desiredPackageType, owner, desiredPackageType)
-		logger.Info("Searching for CSV with pattern", zap.String("pattern", pattern))
-
-		matches, err := utils.FindMostRecentFile(pattern)
+		// Look for the most recent export, in the inventory database with --store
+		// sqlite or as a CSV or JSON manifest in the package type directory
+		matches, packages, err := common.LoadInventory(logger, migrationPath, pkgType, owner, snapshot)
+		if errors.Is(err, common.ErrNoInventory) {
+			logger.Warn("No export file found for package type",
+				zap.String("packageType", pkgType),
+				zap.Error(err))
+			continue
+		}
 		if err != nil {
-			// Try alternate pattern without owner in filename
-			altPattern := fmt.Sprintf("%s/export/%s/*_%s_packages.csv", migrationPath, pkgType, pkgType)
-			logger.Info("Trying alternate pattern",
-				zap.String("altPattern", altPattern))
-
-			matches, err = utils.FindMostRecentFile(altPattern)
-			if err != nil {
-				logger.Warn("No export file found for package type",
-					zap.String("packageType", pkgType),
-					zap.Error(err))
-				continue
-			}
+			spinner.Fail(fmt.Sprintf("Error reading export file: %v", err))
+			return err
 		}
 
-		logger.Info("Found CSV file",
+		logger.Info("Found export file",
 			zap.String("packageType", pkgType),
 			zap.String("file", matches))
 
-		packages, err := files.ReadCSV(matches)
-		if err != nil {
-			spinner.Fail(fmt.Sprintf("Error reading CSV file: %v", err))
-			return err
-		}
-
 		logger.Info("CSV content sample",
 			zap.String("packageType", pkgType),
 			zap.Int("totalRows", len(packages)),
@@ -161,8 +282,43 @@ func Sync(logger *zap.Logger) error {
 			continue
 		}
 
-		allPackages = append(allPackages, packages[1:]...)
-		for _, pkg := range packages[1:] {
+		rows := common.FilterByRepository(packages[1:], desiredRepositories)
+		if len(rows) == 0 {
+			pterm.Info.Println(fmt.Sprintf("No %s packages found for repositories %s", pkgType, strings.Join(desiredRepositories, ", ")))
+			continue
+		}
+		if rows = nameFilter.FilterRows(rows); len(rows) == 0 {
+			pterm.Info.Println(fmt.Sprintf("No %s packages match the name filter", pkgType))
+			continue
+		}
+		inventories = append(inventories, newInventory(pkgType, matches, rows))
+		rows, undated := common.FilterSince(rows, since)
+		if undated > 0 {
+			pterm.Warning.Printf("⚠️  %d %s rows have no creation time and are kept, re-export to filter them with --since\n", undated, pkgType)
+		}
+		if len(rows) == 0 {
+			pterm.Info.Println(fmt.Sprintf("No %s versions were created since %s", pkgType, since.Format(time.RFC3339)))
+			continue
+		}
+		if rows = versionFilter.FilterRows(rows); len(rows) == 0 {
+			pterm.Info.Println(fmt.Sprintf("No %s versions match the version filter", pkgType))
+			continue
+		}
+
+		// The target of a transfer is the source, every version is already there
+		if !providers.Transferring() {
+			if conflicting, inventoryFile := targetConflicts(logger, migrationPath, pkgType, viper.GetString("GHMPKG_TARGET_ORGANIZATION"), rows); len(conflicting) > 0 {
+				logger.Warn("Versions already on the target before the migration",
+					zap.String("packageType", pkgType),
+					zap.String("targetInventory", inventoryFile),
+					zap.Int("versions", len(conflicting)))
+				conflicts = append(conflicts, conflicting...)
+			}
+		}
+
+		allPackages = append(allPackages, rows...)
+		distTaggedTypes = append(distTaggedTypes, newDistTagged(pkgType, rows))
+		for _, pkg := range rows {
 			if _, ok := packageStats[pkgType]; ok {
 				if utils.Contains(packageStats[pkgType], pkg[3]) {
 					continue
@@ -177,9 +333,43 @@ func Sync(logger *zap.Logger) error {
 		pterm.Info.Println(fmt.Sprintf("Found %d packages in CSV for %s", len(packageStats[pkgType]), pkgType))
 	}
 
-	var report *common.Report
-	var err error
-	if report, err = common.ProcessPackages(logger, allPackages, Upload, true); err != nil {
+	if err := checkFreshness(logger, inventories, nameFilter); err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+
+	if len(conflicts) > 0 {
+		if _, err := reportConflicts(conflicts, migrationPath, owner, viper.GetString("GHMPKG_TARGET_ORGANIZATION")); err != nil {
+			logger.Error("Failed to write conflicts", zap.Error(err))
+			pterm.Error.Printf("❌ Error writing conflicts: %v\n", err)
+		}
+	}
+
+	if store != nil && !stream {
+		pterm.Info.Println(fmt.Sprintf("☁️  Fetching pulled files missing from %s/packages from %s", migrationPath, store))
+	}
+	if stream {
+		pterm.Info.Println(fmt.Sprintf("🌊 Streaming files from %s without storing them in %s/packages", owner, migrationPath))
+		if checksumMode, _ := providers.ChecksumMode(); checksumMode != providers.ChecksumsOff {
+			if expected := common.ExpectChecksums(allPackages); expected > 0 {
+				pterm.Info.Println(fmt.Sprintf("🔐 Verifying %d files against the exported checksums (%s on mismatch)", expected, checksumMode))
+			}
+		}
+	}
+
+	// The progress bars take over from the spinner while the packages are synced
+	if common.ProgressBarsEnabled() {
+		spinner.Stop()
+	}
+	rollbacks.done.Store(0)
+	rollbacks.kept.Store(0)
+	rollbacks.failed.Store(0)
+	report, err := common.ProcessPackages(logger, allPackages, Upload, true, "sync")
+	if jsonErr := common.WriteReportJSON("sync", startTime, report, err); jsonErr != nil {
+		logger.Error("Failed to write JSON report", zap.Error(jsonErr))
+		pterm.Error.Printf("❌ Error writing JSON report: %v\n", jsonErr)
+	}
+	if err != nil {
 		spinner.Fail(fmt.Sprintf("Error syncing package: %v", err))
 		return err
 	}
@@ -191,6 +381,24 @@ func Sync(logger *zap.Logger) error {
 		spinner.Success("Sync completed")
 	}
 
+	tagsApplied, tagsFailed := 0, 0
+	for _, tagged := range distTaggedTypes {
+		applied, failed := applyDistTags(logger, migrationPath, owner, viper.GetString("GHMPKG_TARGET_ORGANIZATION"), tagged)
+		tagsApplied += applied
+		tagsFailed += failed
+	}
+
+	var smokeCounts map[string]int
+	var smokeFile string
+	if viper.GetBool("GHMPKG_SMOKE_TEST") {
+		if providers.ExternalTarget() {
+			pterm.Warning.Printf("⚠️  Smoke tests are not supported when publishing to %s\n", providers.TargetRegistry())
+		} else if smokeCounts, smokeFile, err = runSmokeTests(logger, report, migrationPath, owner, viper.GetString("GHMPKG_TARGET_ORGANIZATION"), max(viper.GetInt("GHMPKG_SMOKE_TEST_SAMPLE"), 1)); err != nil {
+			logger.Error("Failed to run smoke tests", zap.Error(err))
+			pterm.Error.Printf("❌ Error running smoke tests: %v\n", err)
+		}
+	}
+
 	// Calculate duration
 	duration := time.Since(startTime)
 	hours := int(duration.Hours())
@@ -209,8 +417,32 @@ func Sync(logger *zap.Logger) error {
 		}
 	}
 
+	if tagsApplied+tagsFailed > 0 {
+		fmt.Printf("🏷️  Dist-tags: %d applied, %d failed\n", tagsApplied, tagsFailed)
+	}
+
+	if rolledBack, rollbackKept, rollbackFailed := rollbacks.done.Load(), rollbacks.kept.Load(), rollbacks.failed.Load(); rolledBack+rollbackKept+rollbackFailed > 0 {
+		fmt.Printf("↩️  Rolled back: %d partially uploaded versions, %d kept as the last version of their package, %d failed\n", rolledBack, rollbackKept, rollbackFailed)
+	}
+
+	if smokeFile != "" {
+		fmt.Printf("🧪 Smoke tests: %d passed, %d failed, %d skipped\n", smokeCounts[SmokePassed], smokeCounts[SmokeFailed], smokeCounts[SmokeSkipped])
+		fmt.Printf("📁 Smoke test results: %s\n", smokeFile)
+	}
+
+	report.PrintSkipReasons()
+	report.PrintVerifications()
+	report.PrintUploadDigests()
 	//fmt.Printf("📁 Output directory: migration-packages/packages/(%s)\n", strings.Join(packageTypes, ", "))
+	if err := common.WriteNameAudit("sync"); err != nil {
+		logger.Error("Failed to write name audit", zap.Error(err))
+		pterm.Error.Printf("❌ Error writing name audit: %v\n", err)
+	}
 	fmt.Printf("🕐 Total time: %dh %dm %ds\n\n", hours, minutes, seconds)
+	if failures := report.Failures(); failures != nil {
+		fmt.Printf("⚠️  Sync %v\n", failures)
+		return failures
+	}
 	fmt.Println("✅ Sync completed successfully!")
 
 	return nil
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/ledger"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// verifyAttempts is how often an uploaded file is read back, registries can
+// take a moment before serving what was just published
+const verifyAttempts = 3
+
+var verifyRetryDelay = 2 * time.Second
+
+// criticalPackages are read back from the target with --verify-critical, even
+// when --verify-uploads is off
+var criticalPackages = &common.CriticalPackages{}
+
+// verifyUpload reads an uploaded file back from the target and compares its
+// digest with the one recorded in the ledger when it was uploaded
+func verifyUpload(logger *zap.Logger, reader providers.TargetReader, migrationPath, sourceOwner, targetOwner, repository, packageType, packageName, version, filename string) (string, error) {
+	store, err := ledger.Load(migrationPath)
+	if err != nil {
+		return common.VerifyUnverified, err
+	}
+	entry, ok := store.Get(sourceOwner, repository, packageType, packageName, version, filename)
+	if !ok || entry.TargetDigest == "" {
+		return common.VerifyUnverified, fmt.Errorf("no digest recorded for the upload of %s", filename)
+	}
+
+	var digest string
+	for attempt := 1; attempt <= verifyAttempts; attempt++ {
+		if digest, err = reader.TargetDigest(logger, targetOwner, repository, packageName, version, filename); err == nil {
+			break
+		}
+		if attempt < verifyAttempts {
+			time.Sleep(verifyRetryDelay * time.Duration(attempt))
+		}
+	}
+	if err != nil {
+		return common.VerifyUnverified, err
+	}
+	if digest != entry.TargetDigest {
+		return common.VerifyMismatch, fmt.Errorf("%s on target differs from the upload: uploaded=%s target=%s", filename, entry.TargetDigest, digest)
+	}
+	return common.Verified, nil
+}
+
+// recordUpload records the result of uploading a file. Unless --verify-uploads
+// is off, uploaded files are read back from the target first, and fail when
+// the registry does not serve what was uploaded, which fails the version.
+// --verify-critical keeps reading back the files of critical packages.
+func recordUpload(logger *zap.Logger, provider providers.Provider, report *common.Report, repository, packageType, packageName, version, filename string, result providers.ResultState, err error) {
+	sourceOwner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	item := common.NewItem(sourceOwner, repository, packageType, packageName, version, filename, result, err)
+	item.UploadDigest = providers.UploadDigest(repository, packageType, packageName, version, filename)
+
+	reader, ok := provider.(providers.TargetReader)
+	verify := viper.GetBool("GHMPKG_VERIFY_UPLOADS") || viper.GetBool("GHMPKG_VERIFY_CRITICAL") && criticalPackages.Match(packageType, packageName)
+	if result == providers.Success && ok && verify {
+		migrationPath := utils.MigrationPath()
+		verification, verifyErr := verifyUpload(logger, reader, migrationPath, sourceOwner, viper.GetString("GHMPKG_TARGET_ORGANIZATION"), repository, packageType, packageName, version, filename)
+		item.Verification = verification
+		switch verification {
+		case common.VerifyMismatch:
+			logger.Error("Uploaded file differs on target", zap.String("filename", filename), zap.Error(verifyErr))
+			pterm.Error.Println(fmt.Sprintf("❌ %v", verifyErr))
+			item.State = providers.Failed
+			item.Error = verifyErr.Error()
+		case common.VerifyUnverified:
+			logger.Warn("Could not verify uploaded file", zap.String("filename", filename), zap.Error(verifyErr))
+			pterm.Warning.Println(fmt.Sprintf("⚠️  Could not verify %s on target: %v", filename, verifyErr))
+		}
+	}
+
+	report.RecordFile(item)
+	if item.State == providers.Success {
+		pterm.Success.Println(fmt.Sprintf("✅ %s", filename))
+	}
+}
@@ -0,0 +1,52 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/ledger"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"go.uber.org/zap"
+)
+
+type fakeReader struct {
+	digest string
+	err    error
+	calls  int
+}
+
+func (r *fakeReader) TargetDigest(logger *zap.Logger, owner, repository, packageName, version, filename string) (string, error) {
+	r.calls++
+	return r.digest, r.err
+}
+
+func TestVerifyUpload(t *testing.T) {
+	verifyRetryDelay = 0
+	dir := t.TempDir()
+	store, err := ledger.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RecordTarget("mona-actions", "lib-repo", "npm", "lib", "1.0.0", "lib-1.0.0.tgz", "sha256:aaa"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		reader *fakeReader
+		file   string
+		want   string
+		calls  int
+	}{
+		{"same digest", &fakeReader{digest: "sha256:aaa"}, "lib-1.0.0.tgz", common.Verified, 1},
+		{"mangled on target", &fakeReader{digest: "sha256:bbb"}, "lib-1.0.0.tgz", common.VerifyMismatch, 1},
+		{"not readable", &fakeReader{err: errors.New("404")}, "lib-1.0.0.tgz", common.VerifyUnverified, verifyAttempts},
+		{"not in ledger", &fakeReader{digest: "sha256:aaa"}, "lib-2.0.0.tgz", common.VerifyUnverified, 0},
+	}
+	for _, test := range tests {
+		got, _ := verifyUpload(zap.NewNop(), test.reader, dir, "mona-actions", "mona-emu", "lib-repo", "npm", "lib", "1.0.0", test.file)
+		if got != test.want || test.reader.calls != test.calls {
+			t.Errorf("%s: verifyUpload = %s after %d reads, want %s after %d", test.name, got, test.reader.calls, test.want, test.calls)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package verify
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/ledger"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"go.uber.org/zap"
+)
+
+// SampleFailed is reported when a sampled file cannot be read back from the target
+const SampleFailed = "sample_failed"
+
+// parseSample reads a sampling rate such as "5%" (the % is optional) as a fraction
+func parseSample(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "%")), 64)
+	if err != nil || percent <= 0 || percent > 100 {
+		return 0, fmt.Errorf("invalid --verify-sample %q, expected a percentage such as 5%%", value)
+	}
+	return percent / 100, nil
+}
+
+// sampleEntries picks the given fraction of the synced ledger entries of every
+// package type, at least one per type that has any
+func sampleEntries(entries []ledger.Entry, sourceOwner string, packageTypes []string, rate float64) (sampled map[string][]ledger.Entry, synced map[string]int) {
+	byType := make(map[string][]ledger.Entry)
+	for _, entry := range entries {
+		if entry.Organization != sourceOwner || entry.TargetDigest == "" || !utils.Contains(packageTypes, entry.PackageType) {
+			continue
+		}
+		byType[entry.PackageType] = append(byType[entry.PackageType], entry)
+	}
+
+	sampled = make(map[string][]ledger.Entry)
+	synced = make(map[string]int)
+	for packageType, candidates := range byType {
+		synced[packageType] = len(candidates)
+		size := int(math.Ceil(float64(len(candidates)) * rate))
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		sampled[packageType] = candidates[:size]
+	}
+	return sampled, synced
+}
+
+// verifySample downloads a sampled file from the target and compares its digest
+// with the one recorded in the ledger when it was uploaded
+func verifySample(logger *zap.Logger, reader providers.TargetReader, targetOwner string, entry ledger.Entry) *Difference {
+	digest, err := reader.TargetDigest(logger, targetOwner, entry.Repository, entry.PackageName, entry.Version, entry.Filename)
+	if err != nil {
+		logger.Warn("Failed to read sampled file from target",
+			zap.String("packageType", entry.PackageType),
+			zap.String("filename", entry.Filename),
+			zap.Error(err))
+		return &Difference{entry.PackageType, entry.PackageName, entry.Version, entry.Filename, SampleFailed, err.Error()}
+	}
+	if digest != entry.TargetDigest {
+		return &Difference{entry.PackageType, entry.PackageName, entry.Version, entry.Filename, DigestMismatch, fmt.Sprintf("uploaded=%s target=%s", entry.TargetDigest, digest)}
+	}
+	return nil
+}
+
+// verifySampled checks a random sample of the files synced for every package
+// type against the target registry, instead of comparing the full listings
+func verifySampled(logger *zap.Logger, migrationPath, sourceOwner, targetOwner string, packageTypes []string, rate float64, report *common.Report) ([]Difference, error) {
+	store, err := ledger.Load(migrationPath)
+	if err != nil {
+		return nil, err
+	}
+	sampled, synced := sampleEntries(store.List(), sourceOwner, packageTypes, rate)
+
+	var diffs []Difference
+	for _, packageType := range packageTypes {
+		entries := sampled[packageType]
+		if len(entries) == 0 {
+			pterm.Warning.Printf("⚠️  No synced %s files recorded in the ledger, run sync first\n", packageType)
+			continue
+		}
+		pterm.Info.Printf("🎲 Sampling %d of %d synced %s files\n", len(entries), synced[packageType], packageType)
+
+		provider, err := providers.NewProvider(logger, packageType)
+		if err != nil {
+			return diffs, err
+		}
+		reader, ok := provider.(providers.TargetReader)
+		if !ok {
+			pterm.Warning.Printf("⚠️  Sampling is not supported for %s packages\n", packageType)
+			continue
+		}
+		for _, entry := range entries {
+			if diff := verifySample(logger, reader, targetOwner, entry); diff != nil {
+				report.IncFiles(providers.Failed)
+				diffs = append(diffs, *diff)
+				continue
+			}
+			report.IncFiles(providers.Success)
+		}
+	}
+	return diffs, nil
+}
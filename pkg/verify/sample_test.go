@@ -0,0 +1,42 @@
+package verify
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/ledger"
+)
+
+func TestParseSample(t *testing.T) {
+	for value, want := range map[string]float64{"5%": 0.05, "50": 0.5, " 100% ": 1} {
+		got, err := parseSample(value)
+		if err != nil || got != want {
+			t.Errorf("parseSample(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"0%", "101%", "five"} {
+		if _, err := parseSample(value); err == nil {
+			t.Errorf("parseSample(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestSampleEntries(t *testing.T) {
+	var entries []ledger.Entry
+	for i := 0; i < 40; i++ {
+		entries = append(entries, ledger.Entry{Organization: "mona-actions", PackageType: "npm", Filename: string(rune('a' + i%26)), TargetDigest: "sha256:x"})
+	}
+	entries = append(entries,
+		ledger.Entry{Organization: "mona-actions", PackageType: "maven", Filename: "app.jar", TargetDigest: "sha256:y"},
+		ledger.Entry{Organization: "mona-actions", PackageType: "maven", Filename: "app.pom"},
+		ledger.Entry{Organization: "other", PackageType: "maven", Filename: "lib.jar", TargetDigest: "sha256:z"},
+	)
+
+	sampled, synced := sampleEntries(entries, "mona-actions", []string{"npm", "maven"}, 0.05)
+	if len(sampled["npm"]) != 2 || synced["npm"] != 40 {
+		t.Errorf("npm sample = %d of %d, want 2 of 40", len(sampled["npm"]), synced["npm"])
+	}
+	// Files never synced or from another organization are not sampled, at least one file is
+	if len(sampled["maven"]) != 1 || sampled["maven"][0].Filename != "app.jar" {
+		t.Errorf("maven sample = %+v, want app.jar only", sampled["maven"])
+	}
+}
@@ -0,0 +1,59 @@
+package verify
+
+import (
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+)
+
+// snapshotInventory holds the versions of every package in the export of a
+// snapshot. Packages and versions published at the source after the snapshot
+// was exported were not meant to be migrated and are not compared. A nil
+// inventory holds everything.
+type snapshotInventory map[string]map[string]bool
+
+// loadSnapshotInventory reads the export of a snapshot for a package type
+func loadSnapshotInventory(migrationPath, packageType, owner, snapshot string) (snapshotInventory, error) {
+	filename, err := common.FindInventory(migrationPath, packageType, owner, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := common.ReadInventory(filename)
+	if err != nil {
+		return nil, err
+	}
+	inventory := make(snapshotInventory)
+	for i, row := range rows {
+		// Skip the header and rows without a version
+		if i == 0 || len(row) < 5 {
+			continue
+		}
+		if inventory[row[3]] == nil {
+			inventory[row[3]] = make(map[string]bool)
+		}
+		inventory[row[3]][row[4]] = true
+	}
+	return inventory, nil
+}
+
+// hasPackage reports whether the snapshot holds the package
+func (s snapshotInventory) hasPackage(packageName string) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[packageName]
+	return ok
+}
+
+// versions returns the versions of the package the snapshot holds
+func (s snapshotInventory) versions(packageName string, versions []*github.PackageVersion) []*github.PackageVersion {
+	if s == nil {
+		return versions
+	}
+	var kept []*github.PackageVersion
+	for _, version := range versions {
+		if s[packageName][version.GetName()] {
+			kept = append(kept, version)
+		}
+	}
+	return kept
+}
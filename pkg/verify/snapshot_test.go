@@ -0,0 +1,45 @@
+package verify
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+)
+
+func TestSnapshotInventory(t *testing.T) {
+	dir := t.TempDir()
+	manifest := &common.Manifest{
+		Organization: "mona-actions",
+		PackageType:  "npm",
+		Run:          &run.Metadata{ID: "cutover", Snapshot: "wave-3-freeze"},
+		Packages: []*common.ManifestPackage{{
+			Name:     "web",
+			Versions: []*common.ManifestVersion{{Name: "1.0.0", Files: []common.ManifestFile{{Name: "web-1.0.0.tgz"}}}},
+		}},
+	}
+	if err := common.WriteManifest(manifest, filepath.Join(dir, "export", "npm", "2024-01-01_00-00-00_mona-actions_npm_packages.json")); err != nil {
+		t.Fatal(err)
+	}
+	inventory, err := loadSnapshotInventory(dir, "npm", "mona-actions", "wave-3-freeze")
+	if err != nil {
+		t.Fatalf("loadSnapshotInventory: %v", err)
+	}
+	if !inventory.hasPackage("web") || inventory.hasPackage("api") {
+		t.Errorf("hasPackage: web=%v api=%v, want only web", inventory.hasPackage("web"), inventory.hasPackage("api"))
+	}
+	// 1.1.0 was published after the snapshot was exported
+	versions := inventory.versions("web", []*github.PackageVersion{{Name: github.String("1.0.0")}, {Name: github.String("1.1.0")}})
+	if len(versions) != 1 || versions[0].GetName() != "1.0.0" {
+		t.Errorf("versions = %v, want 1.0.0", versions)
+	}
+	var everything snapshotInventory
+	if !everything.hasPackage("api") || len(everything.versions("web", versions)) != 1 {
+		t.Error("a nil inventory must hold everything")
+	}
+	if _, err := loadSnapshotInventory(dir, "npm", "mona-actions", "wave-4"); err == nil {
+		t.Error("loadSnapshotInventory(wave-4) succeeded, want an error")
+	}
+}
@@ -0,0 +1,327 @@
+package verify
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/files"
+	"github.com/mona-actions/gh-migrate-packages/internal/providers"
+	"github.com/mona-actions/gh-migrate-packages/internal/utils"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Kinds of differences reported by verify
+const (
+	MissingPackage = "missing_package"
+	MissingVersion = "missing_version"
+	MissingFile    = "missing_file"
+	CountMismatch  = "count_mismatch"
+	DigestMismatch = "digest_mismatch"
+	PreExisting    = "pre_existing"
+)
+
+// Difference describes a single discrepancy between the source and target organizations
+type Difference struct {
+	PackageType string
+	PackageName string
+	Version     string
+	Filename    string
+	Kind        string
+	Detail      string
+}
+
+func (d Difference) row() []string {
+	return []string{d.PackageType, d.PackageName, d.Version, d.Filename, d.Kind, d.Detail}
+}
+
+// containerTags maps every tag of a container package to the digest (version name) it points at
+func containerTags(versions []*github.PackageVersion) map[string]string {
+	tags := make(map[string]string)
+	for _, version := range versions {
+		if version.Metadata == nil || version.Metadata.Container == nil {
+			continue
+		}
+		for _, tag := range version.Metadata.Container.Tags {
+			tags[tag] = version.GetName()
+		}
+	}
+	return tags
+}
+
+func compareContainer(packageName string, sourceVersions, targetVersions []*github.PackageVersion, compareDigests bool) []Difference {
+	var diffs []Difference
+	targetTags := containerTags(targetVersions)
+	for tag, digest := range containerTags(sourceVersions) {
+		filename := fmt.Sprintf("%s:%s", packageName, tag)
+		targetDigest, ok := targetTags[tag]
+		if !ok {
+			diffs = append(diffs, Difference{"container", packageName, digest, filename, MissingVersion, "tag not found on target"})
+			continue
+		}
+		// Digests only survive the migration when the image labels were not rewritten
+		if compareDigests && targetDigest != digest {
+			diffs = append(diffs, Difference{"container", packageName, digest, filename, DigestMismatch, fmt.Sprintf("target=%s", targetDigest)})
+		}
+	}
+	return diffs
+}
+
+func compareFiles(packageType, packageName, version string, sourceFiles, targetFiles []string) []Difference {
+	var diffs []Difference
+	for _, filename := range sourceFiles {
+		if !utils.Contains(targetFiles, filename) {
+			diffs = append(diffs, Difference{packageType, packageName, version, filename, MissingFile, "file not found on target"})
+		}
+	}
+	if len(sourceFiles) != len(targetFiles) {
+		diffs = append(diffs, Difference{packageType, packageName, version, "", CountMismatch, fmt.Sprintf("files source=%d target=%d", len(sourceFiles), len(targetFiles))})
+	}
+	return diffs
+}
+
+func comparePackage(sourcePkg *github.Package, sourceVersions, targetVersions []*github.PackageVersion, sourceFiles, targetFiles common.PackageFiles, compareDigests bool) []Difference {
+	packageType := sourcePkg.GetPackageType()
+	packageName := sourcePkg.GetName()
+
+	var diffs []Difference
+	if len(sourceVersions) != len(targetVersions) {
+		diffs = append(diffs, Difference{packageType, packageName, "", "", CountMismatch, fmt.Sprintf("versions source=%d target=%d", len(sourceVersions), len(targetVersions))})
+	}
+
+	if packageType == "container" {
+		return append(diffs, compareContainer(packageName, sourceVersions, targetVersions, compareDigests)...)
+	}
+
+	targetByName := make(map[string]bool)
+	for _, version := range targetVersions {
+		targetByName[version.GetName()] = true
+	}
+	for _, version := range sourceVersions {
+		if !targetByName[version.GetName()] {
+			diffs = append(diffs, Difference{packageType, packageName, version.GetName(), "", MissingVersion, "version not found on target"})
+			continue
+		}
+		if sourceFiles != nil {
+			diffs = append(diffs, compareFiles(packageType, packageName, version.GetName(), sourceFiles[packageName][version.GetName()], targetFiles[packageName][version.GetName()])...)
+		}
+	}
+	return diffs
+}
+
+// preExisting reports the source versions the target already had when
+// inventory-target listed it. They match by name but the target kept its own
+// content, they were not migrated from the source.
+func preExisting(content *common.TargetContent, sourcePkg *github.Package, targetName string, sourceVersions []*github.PackageVersion) []Difference {
+	packageType := sourcePkg.GetPackageType()
+	var diffs []Difference
+	for _, version := range sourceVersions {
+		labels := []string{version.GetName()}
+		if packageType == "container" {
+			labels = nil
+			if version.Metadata != nil && version.Metadata.Container != nil {
+				labels = version.Metadata.Container.Tags
+			}
+		}
+		for _, label := range labels {
+			if content.HasVersion(packageType, targetName, label) {
+				diffs = append(diffs, Difference{packageType, sourcePkg.GetName(), version.GetName(), "", PreExisting, fmt.Sprintf("%s was on target before the migration (%s)", label, filepath.Base(content.File))})
+			}
+		}
+	}
+	return diffs
+}
+
+func Verify(logger *zap.Logger) error {
+	startTime := time.Now()
+	report := common.NewReport()
+	sourceOwner := viper.GetString("GHMPKG_SOURCE_ORGANIZATION")
+	targetOwner := viper.GetString("GHMPKG_TARGET_ORGANIZATION")
+	migrationPath := utils.MigrationPath()
+	mapping, err := providers.TargetMapping()
+	if err != nil {
+		return err
+	}
+	// Container digests change whenever labels are rewritten for a new org or
+	// by the mapping file
+	compareDigests := sourceOwner == targetOwner && mapping.IsEmpty()
+	var sampleRate float64
+	if value := viper.GetString("GHMPKG_VERIFY_SAMPLE"); value != "" {
+		if sampleRate, err = parseSample(value); err != nil {
+			return err
+		}
+	}
+
+	snapshot, err := common.RequiredSnapshot()
+	if err != nil {
+		return err
+	}
+
+	pterm.Info.Println("Starting verify process...")
+	if snapshot != "" {
+		pterm.Info.Println(fmt.Sprintf("📸 Verifying snapshot: %s", snapshot))
+	}
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Comparing %s with %s", sourceOwner, targetOwner))
+
+	packageTypes, err := common.PackageTypeFilter()
+	if err != nil {
+		spinner.Fail(err.Error())
+		return err
+	}
+	// Types missing on either side cannot be compared
+	packageTypes = common.SupportedPackageTypes(logger, "target", common.SupportedPackageTypes(logger, "source", packageTypes))
+
+	diffsCSV := [][]string{
+		{"package_type", "package_name", "package_version", "package_filename", "difference", "detail"},
+	}
+	diffsByKind := make(map[string]int)
+
+	if sampleRate > 0 {
+		diffs, err := verifySampled(logger, migrationPath, sourceOwner, targetOwner, packageTypes, sampleRate, report)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("❌ Error verifying sample: %v", err))
+			return err
+		}
+		for _, diff := range diffs {
+			diffsByKind[diff.Kind]++
+			diffsCSV = append(diffsCSV, diff.row())
+			pterm.Warning.Printf("    ⚠️  %s %s: %s\n", diff.PackageName, diff.Filename, diff.Kind)
+		}
+	} else {
+		for _, packageType := range packageTypes {
+			pterm.Info.Println(fmt.Sprintf("📦 Verifying %s packages...", packageType))
+
+			var inventory snapshotInventory
+			if snapshot != "" {
+				if inventory, err = loadSnapshotInventory(migrationPath, packageType, sourceOwner, snapshot); err != nil {
+					logger.Warn("No export of the snapshot for package type", zap.String("packageType", packageType), zap.Error(err))
+					pterm.Warning.Printf("⚠️  Skipping %s packages: %v\n", packageType, err)
+					continue
+				}
+			}
+
+			sourcePackages, err := api.FetchPackages(packageType)
+			if err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error getting source packages: %v", err))
+				return err
+			}
+			targetPackages, err := api.FetchTargetPackages(packageType)
+			if err != nil {
+				spinner.Fail(fmt.Sprintf("❌ Error getting target packages: %v", err))
+				return err
+			}
+			targetContent, err := common.LoadTargetContent(migrationPath, packageType, targetOwner)
+			if err != nil {
+				logger.Warn("Failed to read target inventory", zap.String("packageType", packageType), zap.Error(err))
+			}
+			targetByName := make(map[string]*github.Package)
+			for _, pkg := range targetPackages {
+				targetByName[pkg.GetName()] = pkg
+			}
+
+			// Only maven versions carry more than one file, list them from both sides
+			var sourceFiles, targetFiles common.PackageFiles
+			if packageType == "maven" && len(sourcePackages) > 0 {
+				if sourceFiles, err = common.FetchPackageFiles(logger, sourceOwner, utils.GetPackageTypeString("GHMPKG_SOURCE_TOKEN", packageType), packageType); err != nil {
+					spinner.Fail(fmt.Sprintf("❌ Error getting source files: %v", err))
+					return err
+				}
+				if targetFiles, err = common.FetchPackageFiles(logger, targetOwner, utils.GetPackageTypeString("GHMPKG_TARGET_TOKEN", packageType), packageType); err != nil {
+					spinner.Fail(fmt.Sprintf("❌ Error getting target files: %v", err))
+					return err
+				}
+			}
+
+			for _, sourcePkg := range sourcePackages {
+				if !inventory.hasPackage(sourcePkg.GetName()) {
+					continue
+				}
+				spinner.UpdateText(fmt.Sprintf("Verifying %s package(%s)", sourcePkg.GetName(), packageType))
+
+				var diffs []Difference
+				targetName := providers.TargetPackageName(packageType, sourcePkg.GetName())
+				targetPkg, ok := targetByName[targetName]
+				if !ok {
+					diffs = append(diffs, Difference{packageType, sourcePkg.GetName(), "", "", MissingPackage, "package not found on target"})
+				} else {
+					sourceVersions, err := api.FetchPackageVersions(sourcePkg)
+					if err != nil {
+						spinner.Fail(fmt.Sprintf("❌ Error getting source versions: %v", err))
+						return err
+					}
+					sourceVersions = inventory.versions(sourcePkg.GetName(), sourceVersions)
+					targetVersions, err := api.FetchTargetPackageVersions(targetPkg)
+					if err != nil {
+						spinner.Fail(fmt.Sprintf("❌ Error getting target versions: %v", err))
+						return err
+					}
+					diffs = comparePackage(sourcePkg, sourceVersions, targetVersions, sourceFiles, targetFiles, compareDigests)
+					diffs = append(diffs, preExisting(targetContent, sourcePkg, targetName, sourceVersions)...)
+				}
+
+				logger.Info("Verified package",
+					zap.String("packageType", packageType),
+					zap.String("packageName", sourcePkg.GetName()),
+					zap.Int("differences", len(diffs)))
+
+				if len(diffs) == 0 {
+					report.IncPackages(providers.Success)
+					continue
+				}
+				report.IncPackages(providers.Failed)
+				for _, diff := range diffs {
+					diffsByKind[diff.Kind]++
+					diffsCSV = append(diffsCSV, diff.row())
+					pterm.Warning.Printf("    ⚠️  %s %s %s: %s\n", diff.PackageName, strings.TrimSpace(diff.Version+" "+diff.Filename), diff.Kind, diff.Detail)
+				}
+			}
+		}
+	}
+
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	filename := filepath.Join(migrationPath, "verify", fmt.Sprintf("%s_%s_%s_verify.csv", timestamp, sourceOwner, targetOwner))
+	if err := files.CreateCSV(diffsCSV, filename); err != nil {
+		spinner.Fail(fmt.Sprintf("❌ Error creating CSV: %v", err))
+		return err
+	}
+
+	if report.PackagesFailed > 0 || report.FilesFailed > 0 {
+		spinner.Warning("Verify completed with differences")
+	} else {
+		spinner.Success("Verify completed")
+	}
+
+	// Calculate duration
+	duration := time.Since(startTime)
+	hours := int(duration.Hours())
+	minutes := int(duration.Minutes()) % 60
+	seconds := int(duration.Seconds()) % 60
+
+	fmt.Println("\n📊 Verify Summary:")
+	if snapshot != "" {
+		fmt.Printf("📸 Snapshot: %s\n", snapshot)
+	}
+	if sampleRate > 0 {
+		fmt.Printf("🎲 Sampled files: %d\n", report.FileSuccess+report.FilesFailed)
+		fmt.Printf("✅ Matching files: %d\n", report.FileSuccess)
+		fmt.Printf("❌ Files with differences: %d\n", report.FilesFailed)
+	} else {
+		fmt.Printf("✅ Matching packages: %d\n", report.PackageSuccess)
+		fmt.Printf("❌ Packages with differences: %d\n", report.PackagesFailed)
+	}
+	for _, kind := range []string{MissingPackage, MissingVersion, MissingFile, CountMismatch, DigestMismatch, PreExisting, SampleFailed} {
+		if count := diffsByKind[kind]; count > 0 {
+			fmt.Printf("  🔍 %s: %d\n", kind, count)
+		}
+	}
+	fmt.Printf("📁 Differences file: %s\n", filename)
+	fmt.Printf("🕐 Total time: %dh %dm %ds\n\n", hours, minutes, seconds)
+
+	return nil
+}
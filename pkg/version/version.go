@@ -0,0 +1,84 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/api"
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+	"github.com/mona-actions/gh-migrate-packages/pkg/common"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Repository is the repository the extension is released from
+const Repository = "mona-actions/gh-migrate-packages"
+
+// rows lists the build metadata as name, value table rows
+func rows(build run.BuildInfo) [][]string {
+	commit := build.Commit
+	if commit == "" {
+		commit = "unknown"
+	} else if build.Modified {
+		commit += " (modified)"
+	}
+	date := build.Date
+	if date == "" {
+		date = "unknown"
+	}
+	return [][]string{
+		{"Version", build.Version},
+		{"Commit", commit},
+		{"Built", date},
+		{"Go", build.GoVersion},
+		{"Platform", build.Platform},
+	}
+}
+
+// pseudoVersion matches the versions the go command stamps on builds from a
+// commit rather than a release tag
+var pseudoVersion = regexp.MustCompile(`-(0\.)?\d{14}-[0-9a-f]{12}(\+dirty)?$`)
+
+// updateStatus compares the version with the latest release, ok is false when
+// the version is not a release, such as a build from source
+func updateStatus(version, latest string) (newer bool, ok bool) {
+	if pseudoVersion.MatchString(version) {
+		return false, false
+	}
+	comparison, ok := common.CompareVersions(version, latest)
+	if !ok {
+		return false, false
+	}
+	return comparison < 0, true
+}
+
+// Version prints the build metadata of the tool, and whether a newer release
+// is available when GHMPKG_CHECK_UPDATE is set
+func Version(logger *zap.Logger) error {
+	build := run.Build()
+	if err := pterm.DefaultTable.WithData(rows(build)).Render(); err != nil {
+		return err
+	}
+
+	if !viper.GetBool("GHMPKG_CHECK_UPDATE") {
+		return nil
+	}
+	fmt.Println()
+	release, err := api.FetchLatestRelease(Repository)
+	if err != nil {
+		logger.Error("Failed to check for updates", zap.Error(err))
+		return err
+	}
+	latest := release.GetTagName()
+	newer, ok := updateStatus(build.Version, latest)
+	switch {
+	case !ok:
+		pterm.Info.Printf("The latest release is %s (%s), this build can't be compared with it\n", latest, release.GetHTMLURL())
+	case newer:
+		pterm.Warning.Printf("⬆️  %s is available (%s), upgrade with: gh extension upgrade gh-migrate-packages\n", latest, release.GetHTMLURL())
+	default:
+		pterm.Success.Printf("✅ %s is the latest release\n", build.Version)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-packages/internal/run"
+)
+
+func TestUpdateStatus(t *testing.T) {
+	tests := []struct {
+		version, latest string
+		newer, ok       bool
+	}{
+		{"v1.2.0", "v1.3.0", true, true},
+		{"v1.3.0", "v1.3.0", false, true},
+		{"v1.4.0-rc.1", "v1.3.0", false, true},
+		{"v1.3.0-rc.1", "v1.3.0", true, true},
+		{"devel", "v1.3.0", false, false},
+		{"v0.0.0-20261016185120-024a295433e1+dirty", "v1.3.0", false, false},
+		{"v1.3.1-0.20261016185120-024a295433e1", "v1.3.0", false, false},
+	}
+	for _, test := range tests {
+		newer, ok := updateStatus(test.version, test.latest)
+		if newer != test.newer || ok != test.ok {
+			t.Errorf("updateStatus(%q, %q) = %v, %v, want %v, %v", test.version, test.latest, newer, ok, test.newer, test.ok)
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	got := rows(run.BuildInfo{Version: "v1.3.0", Commit: "024a295433e1", Modified: true, GoVersion: "go1.23.4", Platform: "linux/amd64"})
+	want := map[string]string{
+		"Version":  "v1.3.0",
+		"Commit":   "024a295433e1 (modified)",
+		"Built":    "unknown",
+		"Go":       "go1.23.4",
+		"Platform": "linux/amd64",
+	}
+	for _, row := range got {
+		if want[row[0]] != row[1] {
+			t.Errorf("%s = %q, want %q", row[0], row[1], want[row[0]])
+		}
+	}
+}